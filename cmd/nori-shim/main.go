@@ -0,0 +1,82 @@
+// nori-shim is the executable nori copies into the shims directory on Windows as
+// <bin>.exe. It runs the command in the <bin>.shim file beside it, which nori writes,
+// with its own arguments appended, and exits with the command's status. Unlike .cmd
+// wrappers it can be spawned directly by other programs, and its arguments reach the
+// command without passing through cmd.exe's quoting.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "nori-shim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the shim executable: %w", err)
+	}
+	shimFile := strings.TrimSuffix(self, filepath.Ext(self)) + ".shim"
+	data, err := os.ReadFile(shimFile)
+	if err != nil {
+		return fmt.Errorf("failed to read shim: %w (run `nori reshim`)", err)
+	}
+	command, err := parseCommand(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid shim %s: %w", shimFile, err)
+	}
+
+	cmd := exec.Command(command[0], append(command[1:], os.Args[1:]...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// The console sends Ctrl+C to the command as well; let it decide whether to exit
+	signal.Ignore(os.Interrupt)
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", command[0], err)
+	}
+	return nil
+}
+
+// parseCommand splits the first line of a shim file into words. Words containing
+// spaces are wrapped in double quotes, which can't appear inside them.
+func parseCommand(line string) ([]string, error) {
+	line, _, _ = strings.Cut(line, "\n")
+	line = strings.TrimSpace(line)
+
+	var words []string
+	for line != "" {
+		var word string
+		if rest, ok := strings.CutPrefix(line, `"`); ok {
+			end := strings.IndexByte(rest, '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			word, line = rest[:end], rest[end+1:]
+		} else {
+			word, line, _ = strings.Cut(line, " ")
+		}
+		words = append(words, word)
+		line = strings.TrimLeft(line, " ")
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("no command")
+	}
+	return words, nil
+}
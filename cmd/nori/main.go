@@ -2,64 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/chirag-bruno/nori/internal/cli"
-	urfavecli "github.com/urfave/cli/v3"
 )
 
 func main() {
-	app := &urfavecli.Command{
-		Name:  "nori",
-		Usage: "deterministic package manager",
-		Commands: []*urfavecli.Command{
-			{
-				Name:   "init",
-				Usage:  "add ~/.nori/shims to PATH",
-				Action: cli.InitCommand,
-			},
-			{
-				Name:   "update",
-				Usage:  "pull latest registry index + manifests",
-				Action: cli.UpdateCommand,
-			},
-			{
-				Name:   "search",
-				Usage:  "find packages by name/desc",
-				Action: cli.SearchCommand,
-			},
-			{
-				Name:   "info",
-				Usage:  "show versions, platforms, bins",
-				Action: cli.InfoCommand,
-			},
-			{
-				Name:   "install",
-				Usage:  "install for current OS/arch",
-				Action: cli.InstallCommand,
-			},
-			{
-				Name:   "use",
-				Usage:  "set global active version",
-				Action: cli.UseCommand,
-			},
-			{
-				Name:   "list",
-				Usage:  "list installed versions for current OS/arch",
-				Action: cli.ListCommand,
-			},
-			{
-				Name:   "which",
-				Usage:  "show path of the active binary target",
-				Action: cli.WhichCommand,
-			},
-		},
-	}
+	app := cli.App()
 
 	if err := app.Run(context.Background(), os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		// Checks such as verify and doctor report failure categories in the exit status
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
-
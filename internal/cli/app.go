@@ -0,0 +1,645 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/project"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// App returns the nori command tree
+func App() *urfavecli.Command {
+	app := &urfavecli.Command{
+		Name:  "nori",
+		Usage: "deterministic package manager",
+		// Adds `nori completion <shell>` and dynamic package/version completion
+		EnableShellCompletion: true,
+		// Create the root privately and bring on-disk formats up to date before any command reads them
+		Before: prepareRoot,
+		Flags: []urfavecli.Flag{
+			&urfavecli.BoolFlag{
+				Name:  "no-pager",
+				Usage: "do not pipe long output into $NORI_PAGER or $PAGER",
+			},
+			&urfavecli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "print mirror selection and other download details",
+			},
+			&urfavecli.StringFlag{
+				Name:  "log-file",
+				Usage: "append a JSON Lines log of each command's events to `FILE`",
+			},
+			&urfavecli.StringFlag{
+				Name:  "limit-rate",
+				Usage: "download no faster than `RATE` bytes per second, e.g. 500k or 2M, overriding the limit_rate setting",
+			},
+		},
+		Commands: []*urfavecli.Command{
+			{
+				Name:  "init",
+				Usage: "add ~/.nori/shims to PATH",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "system",
+						Usage: "use a machine-wide shims directory on the system PATH (Windows, requires elevation)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "project",
+						Usage: "pin the tool versions this directory already declares in its " + project.ProjectFileName,
+					},
+					&urfavecli.BoolFlag{
+						Name:  "lock",
+						Usage: "with --project, also lock the pinned versions in " + project.LockFileName,
+					},
+				},
+				Action: InitCommand,
+			},
+			{
+				Name:  "update",
+				Usage: "pull latest registry index + manifests",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "full",
+						Usage: "also prefetch the latest assets of installed packages into the cache",
+					},
+				},
+				Action: UpdateCommand,
+			},
+			{
+				Name:      "prefetch",
+				Usage:     "download assets into the cache without installing",
+				ArgsUsage: "<package>[@<version>]...",
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringSliceFlag{
+						Name:  "platform",
+						Usage: "prefetch for `OS-ARCH` instead of the current platform; repeatable",
+					},
+				},
+				Action:        PrefetchCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:  "daemon",
+				Usage: "keep the registry cache fresh and prefetch configured packages in the background",
+				Flags: daemonFlags(
+					&urfavecli.BoolFlag{
+						Name:  "once",
+						Usage: "refresh once and exit",
+					},
+				),
+				Action: DaemonCommand,
+				Commands: []*urfavecli.Command{
+					{
+						Name:  "unit",
+						Usage: "print a systemd user service or launchd agent that runs the daemon",
+						Flags: daemonFlags(
+							&urfavecli.StringFlag{
+								Name:  "format",
+								Value: defaultUnitFormat(),
+								Usage: "the service manager to write for: systemd or launchd",
+							},
+							&urfavecli.BoolFlag{
+								Name:  "write",
+								Usage: "install the unit where the service manager looks for it",
+							},
+						),
+						Action: DaemonUnitCommand,
+					},
+				},
+			},
+			{
+				Name:  "search",
+				Usage: "find packages by name/desc",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:    "long",
+						Aliases: []string{"l"},
+						Usage:   "show extra columns and never truncate",
+					},
+					&urfavecli.StringFlag{
+						Name:  "sort",
+						Usage: "order results by registry stats: popularity or updated",
+					},
+					&urfavecli.StringFlag{
+						Name:  "platform",
+						Usage: "only show packages with assets for `OS-ARCH`, or current",
+					},
+				},
+				Action: SearchCommand,
+			},
+			{
+				Name:  "info",
+				Usage: "show versions, platforms, bins",
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "platform",
+						Usage: "only show versions with assets for `OS-ARCH`, or current",
+					},
+				},
+				Action:        InfoCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:      "versions",
+				Usage:     "list the versions of a package, one per line",
+				ArgsUsage: "<package>",
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "platform",
+						Usage: "only list versions with assets for `OS-ARCH`, or current",
+					},
+					&urfavecli.StringFlag{
+						Name:    "constraint",
+						Aliases: []string{"c"},
+						Usage:   "only list versions in `RANGE`, e.g. ^20 or \">=1.2 <2\"",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "installed",
+						Usage: "only list installed versions",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "json",
+						Usage: "print JSON with each version's platforms and install state",
+					},
+				},
+				Action:        VersionsCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:      "install",
+				Usage:     "install for current OS/arch, or with no arguments every version pinned here",
+				ArgsUsage: "[<package>[@<version>]...]",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "use",
+						Usage: "activate the version immediately after install",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "allow-downgrade",
+						Usage: "allow activating an older version than the active one in strict mode",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "skip-smoke-test",
+						Usage: "don't run the package's smoke test after installing",
+					},
+					&urfavecli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   defaultInstallJobs,
+						Usage:   "install up to `N` packages at once when several are given",
+					},
+				},
+				Action:        InstallCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:   "reshim",
+				Usage:  "rewrite the shims of active packages to pick versions when they run",
+				Action: ReshimCommand,
+			},
+			{
+				Name:            "exec",
+				Usage:           "run a command with packages on PATH, installing them if needed but not activating them",
+				ArgsUsage:       "<package>[@<version>]... -- <command> [<arg>...]",
+				SkipFlagParsing: true,
+				Action:          ExecCommand,
+			},
+			{
+				Name:      "shell",
+				Usage:     "start your shell with packages on PATH, installing them if needed but not activating them",
+				ArgsUsage: "<package>[@<version>]...",
+				Action:    ShellCommand,
+			},
+			{
+				Name:      "env",
+				Usage:     "print shell commands that put this directory's pinned versions, or the given packages, on PATH",
+				ArgsUsage: "[<package>[@<version>]...]",
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "shell",
+						Usage: "shell to print commands for: bash, zsh, fish or pwsh (default: your shell)",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "direnv",
+						Usage: "print a direnv extension that lets .envrc files say `use nori`",
+					},
+				},
+				Action: EnvCommand,
+			},
+			{
+				Name:            "run",
+				Usage:           "run a task from " + project.ProjectFileName + " with this directory's pinned versions on PATH",
+				ArgsUsage:       "[<task> [<arg>...]]",
+				SkipFlagParsing: true,
+				Action:          RunCommand,
+			},
+			{
+				Name:      "relocate",
+				Usage:     "update the paths in a nori root that has moved",
+				ArgsUsage: "<old> <new>",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only print the files and links that would be rewritten",
+					},
+				},
+				Action: RelocateCommand,
+			},
+			{
+				Name:            "exec-shim",
+				Usage:           "run the version of a shimmed binary in effect here (used by shims)",
+				ArgsUsage:       "<binary> [<arg>...]",
+				Hidden:          true,
+				SkipFlagParsing: true,
+				Action:          ExecShimCommand,
+			},
+			{
+				Name:   "lock",
+				Usage:  "record the exact builds of this directory's pinned versions in " + project.LockFileName,
+				Action: LockCommand,
+			},
+			{
+				Name:   "sync",
+				Usage:  "install exactly the builds recorded in " + project.LockFileName,
+				Action: SyncCommand,
+			},
+			{
+				Name:      "apply",
+				Usage:     "install, activate and remove versions to match a file",
+				ArgsUsage: "<file>",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only print the changes that would be made",
+					},
+				},
+				Action: ApplyCommand,
+			},
+			{
+				Name:      "uninstall",
+				Usage:     "remove an installed version and, if it was active, its shims",
+				ArgsUsage: "<package>@<version> | <group>",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "remove every installed version of the package",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "autoremove",
+						Usage: "also remove versions only installed for groups or projects that no longer need them",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "force",
+						Usage: "remove the version even while its binaries are running",
+					},
+				},
+				Action:        UninstallCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:      "local",
+				Usage:     "pin a version in the current directory's " + project.FileName,
+				ArgsUsage: "<package>[@<version>]",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "unset",
+						Usage: "remove the package's pin instead",
+					},
+				},
+				Action:        LocalCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:  "use",
+				Usage: "set global active version",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "install",
+						Usage: "install the version first if it isn't installed, without asking",
+					},
+				},
+				Action:        UseCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:  "list",
+				Usage: "list installed versions for current OS/arch",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "list every package in the registry, not just installed ones",
+					},
+					&urfavecli.BoolFlag{
+						Name:    "long",
+						Aliases: []string{"l"},
+						Usage:   "show extra columns and never truncate",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "outdated-shims",
+						Usage: "list shims that no longer run an active version, instead of packages",
+					},
+				},
+				Action: ListCommand,
+			},
+			{
+				Name:  "outdated",
+				Usage: "list installed packages behind the latest version in the registry",
+				Flags: []urfavecli.Flag{
+					jsonFlag(),
+				},
+				Action: OutdatedCommand,
+			},
+			{
+				Name:  "current",
+				Usage: "show the versions in effect for the current directory",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "explain",
+						Usage: "show every version file consulted and which entries won",
+					},
+				},
+				Action: CurrentCommand,
+			},
+			{
+				Name:          "which",
+				Usage:         "show path of the active binary target",
+				Action:        WhichCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:          "why",
+				Usage:         "explain why the installed versions of a package are there",
+				ArgsUsage:     "<package>[@<version>]",
+				Action:        WhyCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:      "dockerfile",
+				Usage:     "print Dockerfile lines that install the pinned versions by digest",
+				ArgsUsage: "[<package>[@<version>]...]",
+				Flags: []urfavecli.Flag{
+					&urfavecli.StringFlag{
+						Name:  "platform",
+						Value: "linux-amd64",
+						Usage: "platform of the image, as `OS-ARCH`",
+					},
+					&urfavecli.StringFlag{
+						Name:  "copy-from",
+						Usage: "copy the nori binary from `IMAGE` instead of the build context",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "multi-stage",
+						Usage: "install in a separate nori-tools stage to copy into the final image",
+					},
+					&urfavecli.StringFlag{
+						Name:  "base",
+						Value: "debian:bookworm-slim",
+						Usage: "base `IMAGE` of the nori-tools stage",
+					},
+				},
+				Action:        DockerfileCommand,
+				ShellComplete: completePackageArg(false),
+			},
+			{
+				Name:  "direnv",
+				Usage: "add a block to .envrc that puts this project's pinned versions on PATH",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "export",
+						Usage: "print the exports for the pinned versions instead of editing .envrc",
+					},
+				},
+				Action: DirenvCommand,
+			},
+			{
+				Name:          "path",
+				Usage:         "print the bin directory of the active or given version, for use without shims",
+				ArgsUsage:     "<package>[@<version>]",
+				Action:        PathCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:      "verify",
+				Usage:     "check installed files against their install receipt",
+				ArgsUsage: "<package>[@<version>]",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "repair",
+						Usage: "restore damaged files from the cached archive",
+					},
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "verify every installed version",
+					},
+					jsonFlag(),
+				},
+				Action:        VerifyCommand,
+				ShellComplete: completePackageArg(true),
+			},
+			{
+				Name:  "doctor",
+				Usage: "check shims, PATH and configuration for problems",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "also verify every installed version against its receipt",
+					},
+					jsonFlag(),
+				},
+				Action: DoctorCommand,
+			},
+			{
+				Name:  "status",
+				Usage: "show active packages and whether their shims are sound",
+				Flags: []urfavecli.Flag{
+					&urfavecli.BoolFlag{
+						Name:  "all",
+						Usage: "include installed packages without an active version",
+					},
+					jsonFlag(),
+				},
+				Action: StatusCommand,
+			},
+			{
+				Name:  "cache",
+				Usage: "inspect and clean the archive cache",
+				Commands: []*urfavecli.Command{
+					{
+						Name:   "info",
+						Usage:  "show how many archives are cached and how much space they take",
+						Action: CacheInfoCommand,
+					},
+					{
+						Name:  "clean",
+						Usage: "remove the archives of versions no longer installed",
+						Flags: []urfavecli.Flag{
+							&urfavecli.BoolFlag{
+								Name:  "all",
+								Usage: "remove every archive, including those `nori verify --repair` restores from",
+							},
+						},
+						Action: CacheCleanCommand,
+					},
+				},
+			},
+			{
+				Name:  "state",
+				Usage: "manage the index of installed packages",
+				Commands: []*urfavecli.Command{
+					{
+						Name:   "rebuild",
+						Usage:  "re-derive the index from the installs on disk",
+						Action: StateRebuildCommand,
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "view, edit and import settings",
+				Commands: []*urfavecli.Command{
+					{
+						Name:   "export",
+						Usage:  "print the effective settings and where each comes from",
+						Action: ConfigExportCommand,
+					},
+					{
+						Name:   "edit",
+						Usage:  "open the settings in $EDITOR, saving them once they are valid",
+						Action: ConfigEditCommand,
+					},
+					{
+						Name:      "import",
+						Usage:     "validate the settings in a file, or - for stdin, and save them",
+						ArgsUsage: "<file>",
+						Flags: []urfavecli.Flag{
+							&urfavecli.BoolFlag{
+								Name:  "replace",
+								Usage: "discard the settings the file doesn't set, instead of keeping them",
+							},
+						},
+						Action: ConfigImportCommand,
+					},
+				},
+			},
+			{
+				Name:  "registry",
+				Usage: "manage the registries packages are looked up in",
+				Commands: []*urfavecli.Command{
+					{
+						Name:   "list",
+						Usage:  "list the registries in lookup order",
+						Action: RegistryListCommand,
+					},
+					{
+						Name:      "add",
+						Usage:     "look packages up in another registry before the default one",
+						ArgsUsage: "<name> <url>",
+						Flags: []urfavecli.Flag{
+							&urfavecli.StringFlag{
+								Name:  "prefix",
+								Usage: "only find the registry's packages by qualified names, such as `corp`/tooling",
+							},
+						},
+						Action: RegistryAddCommand,
+					},
+					{
+						Name:      "remove",
+						Usage:     "stop looking packages up in a registry and drop its cache",
+						ArgsUsage: "<name>",
+						Action:    RegistryRemoveCommand,
+					},
+					{
+						Name:      "set-default",
+						Usage:     "point the default registry at another URL",
+						ArgsUsage: "<url>",
+						Flags: []urfavecli.Flag{
+							&urfavecli.BoolFlag{
+								Name:  "unset",
+								Usage: "go back to nori's own registry",
+							},
+						},
+						Action: RegistrySetDefaultCommand,
+					},
+				},
+			},
+			{
+				Name:  "manifest",
+				Usage: "draft registry manifests from other package managers",
+				Commands: []*urfavecli.Command{
+					{
+						Name:      "from-brew",
+						Usage:     "seed a manifest from a Homebrew formula's bottles",
+						ArgsUsage: "<formula>",
+						Flags: []urfavecli.Flag{
+							outputFlag(),
+						},
+						Action: ManifestFromBrewCommand,
+					},
+					{
+						Name:      "from-scoop",
+						Usage:     "convert a Scoop app manifest, from a file or URL",
+						ArgsUsage: "<file-or-url>",
+						Flags: []urfavecli.Flag{
+							outputFlag(),
+							&urfavecli.StringFlag{
+								Name:  "name",
+								Usage: "package name, instead of the manifest's file name",
+							},
+						},
+						Action: ManifestFromScoopCommand,
+					},
+				},
+			},
+			{
+				Name:   "bench",
+				Usage:  "measure end-to-end install performance against a local fixture registry",
+				Hidden: true,
+				Flags: []urfavecli.Flag{
+					&urfavecli.IntFlag{
+						Name:  "size",
+						Usage: "size of the fixture binary in MB",
+						Value: 16,
+					},
+					&urfavecli.IntFlag{
+						Name:  "iterations",
+						Usage: "number of installs to average over",
+						Value: 3,
+					},
+				},
+				Action: BenchCommand,
+			},
+		},
+	}
+
+	logEvents(app)
+	return app
+}
+
+// jsonFlag is shared by the read-only checks, whose reports can be consumed by scripts
+func jsonFlag() urfavecli.Flag {
+	return &urfavecli.BoolFlag{
+		Name:  "json",
+		Usage: "print a JSON report instead of text",
+	}
+}
+
+// outputFlag is the --output flag shared by commands that generate files
+func outputFlag() urfavecli.Flag {
+	return &urfavecli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "write to `FILE` instead of stdout",
+	}
+}
+
+// daemonFlags returns the flags shared by `nori daemon` and the units that run it, followed by extra
+func daemonFlags(extra ...urfavecli.Flag) []urfavecli.Flag {
+	return append([]urfavecli.Flag{
+		&urfavecli.DurationFlag{
+			Name:  "interval",
+			Value: 6 * time.Hour,
+			Usage: "refresh every `DURATION`, at least " + minDaemonInterval.String(),
+		},
+	}, extra...)
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// applyFile is the desired state read by `nori apply`. Entries are <package>@<version>;
+// versions may be ranges or digests, and a package without one means its latest version,
+// or for uninstall every installed version.
+type applyFile struct {
+	Install   []string `yaml:"install"`
+	Use       []string `yaml:"use"`
+	Uninstall []string `yaml:"uninstall"`
+}
+
+// applyStep is one change `nori apply` makes
+type applyStep struct {
+	action  string // install, use or uninstall
+	m       *manifest.Manifest
+	version string
+}
+
+// applySymbols marks each kind of step in the plan
+var applySymbols = map[string]string{"install": "+", "use": "~", "uninstall": "-"}
+
+// ApplyCommand handles the `nori apply` command. It reads a file describing which versions
+// should be installed, active and removed, prints the changes needed to get there, and
+// makes them. Running it again once the machine matches changes nothing.
+func ApplyCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("usage: nori apply <file>")
+	}
+	path := c.Args().Get(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var desired applyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&desired); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	steps, err := planApply(ctx, paths, &desired)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Printf("Nothing to do; this machine matches %s\n", path)
+		return nil
+	}
+
+	fmt.Println("Plan:")
+	for _, step := range steps {
+		fmt.Printf("  %s %s %s@%s\n", applySymbols[step.action], step.action, step.m.Name, step.version)
+	}
+	if c.Bool("dry-run") {
+		return nil
+	}
+	fmt.Println()
+
+	p := platform.Detect()
+	cfg := config.New(paths)
+	for _, step := range steps {
+		installPath := paths.InstallPath(step.m.Name, step.version, p.String())
+		switch step.action {
+		case "install":
+			err = installVersion(ctx, c, paths, step.m, step.version, false, state.Reason{})
+		case "use":
+			if err = activate(paths, step.m.Name, step.version, step.m.Bins, installPath); err == nil {
+				fmt.Printf("Using %s@%s\n", step.m.Name, step.version)
+			}
+		case "uninstall":
+			active, _ := cfg.GetActive(step.m.Name)
+			if err = uninstallVersion(paths, step.m.Name, step.version, active == step.version, p, false); err == nil {
+				fmt.Printf("Uninstalled %s@%s\n", step.m.Name, step.version)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s@%s: %w", step.action, step.m.Name, step.version, err)
+		}
+	}
+
+	fmt.Printf("\nApplied %d change(s) from %s\n", len(steps), path)
+	return nil
+}
+
+// planApply works out the steps that bring this machine to the desired state: installs
+// first, then activations, then removals, leaving out anything already in place
+func planApply(ctx context.Context, paths platform.Paths, desired *applyFile) ([]applyStep, error) {
+	plat := platform.Detect().String()
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, err
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.New(paths)
+	active, err := cfg.ListActive()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var installs, uses, uninstalls []applyStep
+	wanted := make(map[string]bool)
+	planned := make(map[string]bool)
+	addInstall := func(m *manifest.Manifest, version string) {
+		ref := m.Name + "@" + version
+		wanted[ref] = true
+		if !planned[ref] && !dirExists(paths.InstallPath(m.Name, version, plat)) {
+			planned[ref] = true
+			installs = append(installs, applyStep{"install", m, version})
+		}
+	}
+
+	for _, entry := range desired.Install {
+		m, version, err := resolveApplyEntry(ctx, reg, settings, entry, plat)
+		if err != nil {
+			return nil, err
+		}
+		addInstall(m, version)
+	}
+
+	inUse := make(map[string]string)
+	for _, entry := range desired.Use {
+		m, version, err := resolveApplyEntry(ctx, reg, settings, entry, plat)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := inUse[m.Name]; ok && other != version {
+			return nil, fmt.Errorf("%s is listed under use twice, at %s and %s", m.Name, other, version)
+		}
+		inUse[m.Name] = version
+		addInstall(m, version)
+		if active[m.Name] != version {
+			uses = append(uses, applyStep{"use", m, version})
+		}
+	}
+
+	for _, entry := range desired.Uninstall {
+		name, version, hasVersion := strings.Cut(entry, "@")
+		versions := st.Versions(name, plat)
+		if hasVersion {
+			versions = nil
+			if dirExists(paths.InstallPath(name, version, plat)) {
+				versions = []string{version}
+			}
+		}
+		if len(versions) == 0 {
+			continue
+		}
+
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load package %s: %w", name, err)
+		}
+		for _, v := range versions {
+			if wanted[name+"@"+v] {
+				if hasVersion {
+					return nil, fmt.Errorf("%s@%s is listed to be both installed and uninstalled", name, v)
+				}
+				// Removing every version of a package spares the ones also listed to keep
+				continue
+			}
+			uninstalls = append(uninstalls, applyStep{"uninstall", m, v})
+		}
+	}
+
+	return append(append(installs, uses...), uninstalls...), nil
+}
+
+// resolveApplyEntry loads the package of a <package>[@<version>] entry and resolves its
+// version for plat, as `nori install` would
+func resolveApplyEntry(ctx context.Context, reg *registry.Registry, settings *config.Settings, entry, plat string) (*manifest.Manifest, string, error) {
+	name, spec, hasVersion := strings.Cut(entry, "@")
+	m, err := reg.LoadPackage(ctx, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load package %s: %w", name, err)
+	}
+	if m.IsGroup() {
+		return nil, "", fmt.Errorf("%s is a package group; list its members instead", name)
+	}
+
+	var version string
+	switch {
+	case !hasVersion:
+		version, _, err = defaultVersion(settings, m, plat)
+	case manifest.IsDigest(spec):
+		version, err = m.FindDigest(spec, plat)
+	default:
+		version, err = m.ResolveVersion(spec, plat)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return m, version, nil
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/extract"
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+const benchPackage = "nori-bench"
+
+// BenchCommand handles the hidden `nori bench` command. It measures an end-to-end
+// install from an in-process fixture registry into a throwaway nori root.
+func BenchCommand(ctx context.Context, c *urfavecli.Command) error {
+	sizeMB := c.Int("size")
+	iterations := c.Int("iterations")
+	if sizeMB <= 0 || iterations <= 0 {
+		return fmt.Errorf("--size and --iterations must be positive")
+	}
+
+	// Never touch the user's real nori root
+	root, err := os.MkdirTemp("", "nori-bench-*")
+	if err != nil {
+		return fmt.Errorf("failed to create bench root: %w", err)
+	}
+	defer os.RemoveAll(root)
+	paths := platform.NewPaths(root)
+
+	archive, err := benchArchive(int(sizeMB) * 1024 * 1024)
+	if err != nil {
+		return fmt.Errorf("failed to build fixture archive: %w", err)
+	}
+	hash := sha256.Sum256(archive)
+	checksum := "sha256:" + hex.EncodeToString(hash[:])
+
+	p := platform.Detect()
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			fmt.Fprintf(w, "packages:\n  - name: %s\n    description: benchmark fixture\n", benchPackage)
+		case "/packages/" + benchPackage + ".yaml":
+			fmt.Fprintf(w, "schema: 1\nname: %s\nbins:\n  - bin/%s\nversions:\n  \"1.0.0\":\n    platforms:\n      %s:\n        type: tar\n        url: %s/asset.tar.gz\n        checksum: %s\n",
+				benchPackage, benchPackage, p.String(), server.URL, checksum)
+		case "/asset.tar.gz":
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	reg := registry.NewWithClient(server.URL, paths, server.Client())
+	fetcher := fetch.NewWithClient(server.Client())
+	extractor := extract.New()
+	installer := install.New(paths)
+
+	fmt.Printf("Benchmarking install of a %d MB archive, %d iteration(s)\n\n", sizeMB, iterations)
+
+	phases := []string{"manifest", "download", "extract", "install"}
+	totals := make(map[string]time.Duration)
+
+	for i := 0; i < iterations; i++ {
+		// Drop the cached manifest so every iteration exercises the registry
+		os.Remove(paths.PackageManifestPath(benchPackage))
+
+		start := time.Now()
+		m, err := reg.LoadPackage(ctx, benchPackage)
+		if err != nil {
+			return fmt.Errorf("manifest phase failed: %w", err)
+		}
+		totals["manifest"] += time.Since(start)
+
+		asset, err := m.GetAsset("1.0.0", p.String())
+		if err != nil {
+			return err
+		}
+
+		start = time.Now()
+		data, err := fetcher.Fetch(ctx, asset.URL, asset.Checksum)
+		if err != nil {
+			return fmt.Errorf("download phase failed: %w", err)
+		}
+		totals["download"] += time.Since(start)
+
+		start = time.Now()
+		extractDir, err := extractor.Extract(data, asset.Type, asset.Checksum)
+		if err != nil {
+			return fmt.Errorf("extract phase failed: %w", err)
+		}
+		totals["extract"] += time.Since(start)
+
+		start = time.Now()
+		installPath, err := installer.Install(ctx, m, "1.0.0", p, extractDir)
+		os.RemoveAll(extractDir)
+		if err != nil {
+			return fmt.Errorf("install phase failed: %w", err)
+		}
+		totals["install"] += time.Since(start)
+
+		os.RemoveAll(installPath)
+	}
+
+	var total time.Duration
+	for _, phase := range phases {
+		avg := totals[phase] / time.Duration(iterations)
+		total += avg
+		fmt.Printf("  %-10s %v\n", phase, avg.Round(time.Microsecond))
+	}
+	fmt.Printf("  %-10s %v\n", "total", total.Round(time.Microsecond))
+
+	return nil
+}
+
+// benchArchive builds a tar.gz containing a single incompressible binary of size bytes
+func benchArchive(size int) ([]byte, error) {
+	payload := make([]byte, size)
+	if _, err := rand.Read(payload); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	hdr := &tar.Header{
+		Name: benchPackage + "-1.0.0/bin/" + benchPackage,
+		Size: int64(size),
+		Mode: 0755,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
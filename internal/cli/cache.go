@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// cachedFile is an archive in the archive cache
+type cachedFile struct {
+	path string
+	size int64
+	used bool // an installed version was installed from it
+}
+
+// CacheInfoCommand handles the `nori cache info` command. It reports how many archives
+// the archive cache holds and how much of it `nori cache clean` would free.
+func CacheInfoCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	files, err := cachedArchives(paths)
+	if err != nil {
+		return err
+	}
+
+	var total, unused int64
+	count := 0
+	for _, f := range files {
+		total += f.size
+		if !f.used {
+			unused += f.size
+			count++
+		}
+	}
+	fmt.Printf("%s: %d archive(s), %s\n", paths.ArchiveDir(), len(files), megabytes(total))
+	fmt.Printf("%d of them (%s) are of versions no longer installed; `nori cache clean` removes those\n", count, megabytes(unused))
+	return nil
+}
+
+// CacheCleanCommand handles the `nori cache clean` command. It removes the archives of
+// versions no longer installed, or with --all every archive, which `nori verify --repair`
+// would otherwise restore installed versions from.
+func CacheCleanCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	files, err := cachedArchives(paths)
+	if err != nil {
+		return err
+	}
+
+	var freed int64
+	removed := 0
+	for _, f := range files {
+		if f.used && !c.Bool("all") {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cached archive: %w", err)
+		}
+		freed += f.size
+		removed++
+	}
+	fmt.Printf("Removed %d archive(s), freeing %s\n", removed, megabytes(freed))
+	return nil
+}
+
+// cachedArchives lists the archives in the archive cache. Downloads still in progress,
+// such as those of `nori prefetch`, are left out.
+func cachedArchives(paths platform.Paths) ([]cachedFile, error) {
+	entries, err := os.ReadDir(paths.ArchiveDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the archive cache: %w", err)
+	}
+
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, pkg := range st.Packages {
+		for _, inst := range pkg.Installs {
+			used[strings.ToLower(inst.Checksum)] = true
+		}
+	}
+
+	var files []cachedFile
+	for _, entry := range entries {
+		checksum := "sha256:" + entry.Name()
+		if !entry.Type().IsRegular() || !manifest.IsDigest(checksum) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{path: filepath.Join(paths.ArchiveDir(), entry.Name()), size: info.Size(), used: used[checksum]})
+	}
+	return files, nil
+}
+
+// megabytes formats a size in bytes as MB
+func megabytes(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
@@ -0,0 +1,3331 @@
+package cli_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/cli"
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/testsupport"
+)
+
+// TestMain lets this test binary stand in for nori when a shim runs it: shims exec the
+// executable that wrote them, which under test is this binary
+func TestMain(m *testing.M) {
+	// Shims, nori exec, run and shell replace the process, so tests run them as this binary
+	if len(os.Args) > 1 && slices.Contains([]string{"exec-shim", "exec", "run", "shell"}, os.Args[1]) {
+		if err := cli.App().Run(context.Background(), os.Args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executes nori with args and returns its standard output
+func run(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var err error
+	out := testsupport.CaptureStdout(t, func() {
+		err = cli.App().Run(context.Background(), append([]string{"nori"}, args...))
+	})
+	if err != nil {
+		t.Fatalf("nori %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+// runErr executes nori with args and returns the error it produced
+func runErr(t *testing.T, args ...string) error {
+	t.Helper()
+
+	var err error
+	testsupport.CaptureStdout(t, func() {
+		err = cli.App().Run(context.Background(), append([]string{"nori"}, args...))
+	})
+	return err
+}
+
+// runResult executes nori with args and returns both its standard output and error
+func runResult(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var err error
+	out := testsupport.CaptureStdout(t, func() {
+		err = cli.App().Run(context.Background(), append([]string{"nori"}, args...))
+	})
+	return out, err
+}
+
+// exitCode returns the exit status nori would use for err
+func exitCode(err error) int {
+	var exitErr *cli.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// shimOutput executes a shim and returns its trimmed output
+func shimOutput(t *testing.T, root, bin string) string {
+	t.Helper()
+
+	out, err := exec.Command(filepath.Join(root, "shims", bin)).Output()
+	if err != nil {
+		t.Fatalf("running shim %s failed: %v", bin, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// lineWith returns the first line of out that starts with prefix, ignoring indentation
+func lineWith(out, prefix string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestInstallUseWhichFlow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{
+		Name:        "hello",
+		Description: "prints a greeting",
+		Versions:    []string{"1.0.0", "2.0.0"},
+	})
+
+	run(t, "update")
+
+	// The first install is activated automatically
+	run(t, "install", "hello@1.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after first install = %q, want %q", got, "hello 1.0.0")
+	}
+
+	// Later installs leave the active version alone
+	run(t, "install", "hello@2.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after second install = %q, want %q", got, "hello 1.0.0")
+	}
+
+	run(t, "use", "hello@2.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after use = %q, want %q", got, "hello 2.0.0")
+	}
+
+	out := run(t, "which", "hello")
+	want := filepath.Join(root, "installs", "hello", "2.0.0", testsupport.Platform(), "bin", "hello")
+	if strings.TrimSpace(out) != want {
+		t.Errorf("which = %q, want %q", strings.TrimSpace(out), want)
+	}
+
+	out = run(t, "list", "hello")
+	if line := lineWith(out, "2.0.0"); !strings.Contains(line, "active") || !strings.Contains(out, "1.0.0") {
+		t.Errorf("list output = %q, want both versions with 2.0.0 active", out)
+	}
+	if line := lineWith(out, "1.0.0"); strings.Contains(line, "active") {
+		t.Errorf("list output = %q, want 1.0.0 inactive", out)
+	}
+}
+
+func TestInstallWithUseFlag(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{
+		Name:     "hello",
+		Versions: []string{"1.0.0", "2.0.0"},
+	})
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0", "--use")
+
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after install --use = %q, want %q", got, "hello 2.0.0")
+	}
+}
+
+func TestUseInstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{
+		Name:     "hello",
+		Versions: []string{"1.0.0", "2.0.0"},
+	})
+
+	// Without a terminal to ask at, use only installs when told to
+	if err := runErr(t, "use", "hello@1.0.0"); err == nil || !strings.Contains(err.Error(), "--install") {
+		t.Errorf("use of a version that isn't installed = %v, want a hint to pass --install", err)
+	}
+	run(t, "use", "--install", "hello@1.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after use --install = %q, want %q", got, "hello 1.0.0")
+	}
+
+	// A range nothing installed matches installs its highest release
+	if err := runErr(t, "use", "hello@2"); err == nil || !strings.Contains(err.Error(), "--install to install hello@2.0.0") {
+		t.Errorf("use of an uninstalled range = %v, want a hint to install 2.0.0", err)
+	}
+	out := run(t, "use", "--install", "hello@2")
+	if !strings.Contains(out, "Resolved hello@2 to 2.0.0") {
+		t.Errorf("use --install output = %q, want the resolved version", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after use --install of a range = %q, want %q", got, "hello 2.0.0")
+	}
+}
+
+func TestInstallUnknownVersion(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{
+		Name:     "hello",
+		Versions: []string{"1.0.0"},
+	})
+
+	if err := runErr(t, "install", "hello@9.9.9"); err == nil {
+		t.Error("install of an unknown version should fail")
+	}
+}
+
+func TestUpdateDoesNotTouchHome(t *testing.T) {
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+
+	run(t, "update")
+
+	if _, err := os.Stat(filepath.Join(root, "registry", "index.yaml")); err != nil {
+		t.Errorf("index.yaml not cached under NORI_ROOT: %v", err)
+	}
+}
+
+func TestInitSystemRequiresWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exercises the non-Windows error path")
+	}
+
+	testsupport.IsolateRoot(t)
+	if err := runErr(t, "init", "--system"); err == nil {
+		t.Error("init --system should fail outside Windows")
+	}
+}
+
+func TestSearchColumns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Description: "prints a greeting", Versions: []string{"1.0.0", "1.10.0"}},
+		testsupport.Package{Name: "help", Description: strings.Repeat("very long description ", 10), Versions: []string{"0.1.0"}},
+	)
+	t.Setenv("COLUMNS", "60")
+
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+
+	out := run(t, "search", "hel")
+	if line := lineWith(out, "hello"); !strings.Contains(line, "1.10.0") || !strings.Contains(line, "✓ 1.0.0") {
+		t.Errorf("search row for hello = %q, want latest 1.10.0 and active 1.0.0", line)
+	}
+	if line := lineWith(out, "help"); !strings.HasSuffix(line, "…") {
+		t.Errorf("search row for help = %q, want the description truncated", line)
+	}
+
+	out = run(t, "search", "--long", "hel")
+	if line := lineWith(out, "help"); strings.HasSuffix(line, "…") {
+		t.Errorf("search --long row for help = %q, want the full description", line)
+	}
+}
+
+func TestSearchSort(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "fd", Description: "find files", Versions: []string{"1.0.0"}, Downloads: 1500, Updated: "2024-06-01"},
+		testsupport.Package{Name: "findutils", Description: "find files, GNU", Versions: []string{"4.9.0"}, Downloads: 2_300_000, Updated: "2023-02-14"},
+		testsupport.Package{Name: "fzf", Description: "fuzzy find", Versions: []string{"0.50.0"}},
+	)
+
+	run(t, "update")
+
+	order := func(out string) string {
+		var names []string
+		for _, line := range strings.Split(out, "\n")[1:] {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				names = append(names, fields[0])
+			}
+		}
+		return strings.Join(names, ",")
+	}
+
+	out := run(t, "search", "--sort", "popularity", "find")
+	if got := order(out); got != "findutils,fd,fzf" {
+		t.Errorf("search --sort popularity order = %s, want findutils,fd,fzf", got)
+	}
+	if line := lineWith(out, "findutils"); !strings.Contains(line, "2.3M") || !strings.Contains(line, "2023-02-14") {
+		t.Errorf("search row for findutils = %q, want downloads and update date", line)
+	}
+
+	if got := order(run(t, "search", "--sort", "updated", "find")); got != "fd,findutils,fzf" {
+		t.Errorf("search --sort updated order = %s, want fd,findutils,fzf", got)
+	}
+
+	if err := runErr(t, "search", "--sort", "stars", "find"); err == nil {
+		t.Error("search --sort stars should fail")
+	}
+
+	if out := run(t, "info", "fd"); !strings.Contains(out, "Downloads: 1.5k") {
+		t.Errorf("info fd = %q, want the download count", out)
+	}
+}
+
+func TestSearchBins(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "helix", Description: "modal text editor", Versions: []string{"24.7.0"}, Bins: []string{"bin/hx"}},
+		testsupport.Package{Name: "fd", Description: "find files", Versions: []string{"1.0.0"}},
+	)
+
+	run(t, "update")
+	out := run(t, "search", "hx")
+	if lineWith(out, "helix") == "" || lineWith(out, "fd") != "" {
+		t.Errorf("search hx = %q, want helix by its bin", out)
+	}
+	// which finds the package from the index, though nothing is installed yet
+	if err := runErr(t, "which", "hx"); err == nil || !strings.Contains(err.Error(), "helix") {
+		t.Errorf("which hx error = %v, want helix without an active version", err)
+	}
+}
+
+func TestSearchPlatform(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	other := "windows-arm64"
+	if testsupport.Platform() == other {
+		other = "linux-arm64"
+	}
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "tool-native", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "tool-foreign", Versions: []string{"1.0.0"}, Platforms: []string{other}},
+	)
+
+	out := run(t, "update")
+	if !strings.Contains(out, "Refreshed 2 package(s): 3 version(s), 3 asset(s) across 2 platform(s)") {
+		t.Errorf("update output = %q, want refresh totals", out)
+	}
+	if lineWith(out, "tool-foreign") == "" {
+		t.Errorf("update output = %q, want a coverage warning for tool-foreign", out)
+	}
+
+	out = run(t, "search", "--platform", "current", "tool")
+	if lineWith(out, "tool-native") == "" || lineWith(out, "tool-foreign") != "" {
+		t.Errorf("search --platform current = %q, want only tool-native", out)
+	}
+
+	out = run(t, "search", "--platform", other, "tool")
+	if lineWith(out, "tool-foreign") == "" || lineWith(out, "tool-native") != "" {
+		t.Errorf("search --platform %s = %q, want only tool-foreign", other, out)
+	}
+
+	if err := runErr(t, "search", "--platform", "amiga", "tool"); err == nil {
+		t.Error("search --platform amiga should fail")
+	}
+
+	if out := run(t, "info", "--platform", other, "tool-native"); !strings.Contains(out, "No versions ship assets for "+other) {
+		t.Errorf("info --platform %s = %q, want no versions", other, out)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"2.0.0"}},
+	)
+
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+
+	out := run(t, "list", "--all", "--no-pager")
+	if line := lineWith(out, "hello"); !strings.Contains(line, "✓ 1.0.0") {
+		t.Errorf("list --all row for hello = %q, want it marked installed", line)
+	}
+	if line := lineWith(out, "world"); line == "" || strings.Contains(line, "✓") {
+		t.Errorf("list --all row for world = %q, want it listed but not installed", line)
+	}
+}
+
+func TestStateIndex(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0", "--use")
+
+	// which answers from the index without consulting the registry
+	manifests := reg.Requests("/packages/hello.yaml")
+	os.Remove(filepath.Join(root, "registry", "packages", "hello.yaml"))
+	out := run(t, "which", "hello")
+	if want := filepath.Join(root, "installs", "hello", "2.0.0", testsupport.Platform(), "bin", "hello"); strings.TrimSpace(out) != want {
+		t.Errorf("which = %q, want %q", strings.TrimSpace(out), want)
+	}
+	if reg.Requests("/packages/hello.yaml") != manifests {
+		t.Error("which fetched the manifest instead of using the state index")
+	}
+
+	// Removing an install behind nori's back leaves the index stale until it is rebuilt
+	os.RemoveAll(filepath.Join(root, "installs", "hello", "1.0.0"))
+	if out := run(t, "list", "hello"); !strings.Contains(out, "1.0.0") {
+		t.Errorf("list output = %q, want the indexed 1.0.0 before a rebuild", out)
+	}
+	out, err := runResult(t, "doctor")
+	if !strings.Contains(out, "state index is out of date") || exitCode(err)&cli.ExitMisconfigured == 0 {
+		t.Errorf("doctor output = %q (%v), want a stale state index reported", out, err)
+	}
+
+	out = run(t, "state", "rebuild")
+	if !strings.Contains(out, "1 package(s), 1 install(s)") {
+		t.Errorf("state rebuild output = %q, want one package and one install", out)
+	}
+	if out := run(t, "list", "hello"); strings.Contains(out, "1.0.0") {
+		t.Errorf("list output after rebuild = %q, want 1.0.0 gone", out)
+	}
+}
+
+func TestPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}, Bins: []string{"bin/tool", "sbin/toold", "bin/tool-extra"}},
+	)
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+	run(t, "install", "tool@1.0.0")
+
+	plat := testsupport.Platform()
+	out := strings.TrimSpace(run(t, "path", "hello"))
+	if want := filepath.Join(root, "installs", "hello", "1.0.0", plat, "bin"); out != want {
+		t.Errorf("path hello = %q, want the active version's bin directory %q", out, want)
+	}
+
+	// The directory works on PATH without any shims
+	t.Setenv("PATH", out+string(os.PathListSeparator)+"/usr/bin:/bin")
+	if got, err := exec.Command("hello").Output(); err != nil || strings.TrimSpace(string(got)) != "hello 1.0.0" {
+		t.Errorf("hello via nori path = %q, %v", got, err)
+	}
+
+	out = strings.TrimSpace(run(t, "path", "hello@2.0.0"))
+	if want := filepath.Join(root, "installs", "hello", "2.0.0", plat, "bin"); out != want {
+		t.Errorf("path hello@2.0.0 = %q, want %q", out, want)
+	}
+
+	// Bins spread over several directories give a PATH list
+	out = strings.TrimSpace(run(t, "path", "tool"))
+	install := filepath.Join(root, "installs", "tool", "1.0.0", plat)
+	if want := filepath.Join(install, "bin") + string(os.PathListSeparator) + filepath.Join(install, "sbin"); out != want {
+		t.Errorf("path tool = %q, want %q", out, want)
+	}
+
+	if err := runErr(t, "path", "hello@3.0.0"); err == nil {
+		t.Error("path should fail for a version that is not installed")
+	}
+	if err := runErr(t, "path", "missing"); err == nil {
+		t.Error("path should fail for a package with no active version")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	logPath := filepath.Join(t.TempDir(), "nori.jsonl")
+
+	run(t, "update")
+	run(t, "--log-file", logPath, "install", "hello@1.0.0")
+	runErr(t, "--log-file", logPath, "install", "hello@9.9.9")
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read event log: %v", err)
+	}
+	var got []string
+	var last map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("event log line %q is not JSON: %v", line, err)
+		}
+		name := e["event"].(string)
+		if phase, ok := e["phase"]; ok {
+			name += ":" + phase.(string)
+		}
+		got = append(got, name)
+		last = e
+	}
+
+	want := []string{
+		"command_start",
+		// Tar archives are extracted as they download
+		"phase_start:download", "phase_start:extract", "phase_end:extract", "phase_end:download",
+		"phase_start:install", "phase_end:install",
+		"phase_start:activate", "phase_end:activate",
+		"command_end",
+		"command_start", "command_end",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if last["command"] != "nori install" || last["error"] == nil || last["exit_code"] != float64(1) {
+		t.Errorf("last event = %v, want the failed install with its error and exit code", last)
+	}
+}
+
+func TestInstallLatest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.2.0", "1.10.0", "1.9.0"}})
+	run(t, "update")
+
+	out := run(t, "install", "hello")
+	if !strings.Contains(out, "Resolved hello to the latest version, 1.10.0") {
+		t.Errorf("install output = %q, want the highest version by semver chosen", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.10.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.10.0")
+	}
+}
+
+func TestInstallMany(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0", "1.1.0"}},
+		testsupport.Package{Name: "tools", Versions: []string{"3.0.0"}},
+	)
+	run(t, "update")
+
+	out := run(t, "install", "hello@1.0.0", "world@1", "tools", "hello@1.0.0", "--jobs", "2")
+	for _, want := range []string{"Resolved world@1 to 1.1.0", "Installing 3 package(s)", "hello@1.0.0: Installed", "world@1.1.0: Installed", "tools@3.0.0: Installed", "Using tools@3.0.0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("install output = %q, want it to contain %q", out, want)
+		}
+	}
+	for bin, want := range map[string]string{"hello": "hello 1.0.0", "world": "world 1.1.0", "tools": "tools 3.0.0"} {
+		if got := shimOutput(t, root, bin); got != want {
+			t.Errorf("%s shim = %q, want %q", bin, got, want)
+		}
+	}
+
+	// Installed versions aren't downloaded again, and new ones respect the active version
+	out = run(t, "install", "hello@2.0.0", "world@1.1.0")
+	if !strings.Contains(out, "world@1.1.0 is already installed") || !strings.Contains(out, "Active version is still 1.0.0") {
+		t.Errorf("second install output = %q, want world skipped and hello left active", out)
+	}
+
+	if err := runErr(t, "install", "hello@2.0.0", "world@9.9.9"); err == nil {
+		t.Error("install should fail when any version can't be resolved")
+	}
+	if err := runErr(t, "install", "hello", "world", "--jobs", "0"); err == nil {
+		t.Error("install should reject --jobs 0")
+	}
+}
+
+func TestUpgradeNotice(t *testing.T) {
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	stderr := func(args ...string) string {
+		return testsupport.CaptureStderr(t, func() { runResult(t, args...) })
+	}
+	settings := func(yaml string) {
+		os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(yaml), 0644)
+	}
+
+	if out := stderr("list"); strings.Contains(out, "Note:") {
+		t.Errorf("stderr = %q, want no notice unless enabled", out)
+	}
+
+	settings("upgrade_notice: true\n")
+	if out := stderr("list"); !strings.Contains(out, "Note: 1 installed tool(s) have updates; run `nori outdated`") {
+		t.Errorf("stderr = %q, want an upgrade notice", out)
+	}
+	if out := stderr("list"); strings.Contains(out, "Note:") {
+		t.Errorf("stderr = %q, want the notice shown at most once a day", out)
+	}
+
+	settings("upgrade_notice: true\nupgrade_notice_interval: 1ns\n")
+	if out := stderr("list"); !strings.Contains(out, "Note: 1 installed tool(s)") {
+		t.Errorf("stderr = %q, want the notice again after the configured interval", out)
+	}
+	if out := stderr("outdated"); strings.Contains(out, "Note:") {
+		t.Errorf("stderr = %q, want no notice from outdated itself", out)
+	}
+
+	settings("upgrade_notice: true\nupgrade_notice_interval: often\n")
+	if out := stderr("list"); !strings.Contains(out, "invalid upgrade_notice_interval") {
+		t.Errorf("stderr = %q, want the invalid interval reported", out)
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0"}},
+	)
+	run(t, "update")
+
+	if out := run(t, "outdated"); !strings.Contains(out, "No packages installed") {
+		t.Errorf("outdated output = %q, want no packages", out)
+	}
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "world@1.0.0")
+	out := run(t, "outdated")
+	if !strings.Contains(out, "1 of 2 package(s) are behind") {
+		t.Errorf("outdated output = %q, want hello reported behind", out)
+	}
+
+	var rows []struct {
+		Name, Current, Latest, State string
+	}
+	if err := json.Unmarshal([]byte(run(t, "outdated", "--json")), &rows); err != nil {
+		t.Fatalf("outdated --json is not JSON: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Name != "hello" || rows[0].Latest != "2.0.0" || rows[0].State != "behind" || rows[1].State != "up to date" {
+		t.Errorf("outdated --json = %+v, want hello behind 2.0.0 and world up to date", rows)
+	}
+
+	run(t, "install", "hello@2.0.0", "--use")
+	if out := run(t, "outdated"); !strings.Contains(out, "Everything is up to date") {
+		t.Errorf("outdated output = %q, want everything up to date", out)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.2.0", "1.10.0", "1.9.0", "2.0.0"}})
+	run(t, "update")
+	run(t, "install", "hello@1.9.0")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"versions", "hello"}, "1.2.0\n1.9.0\n1.10.0\n2.0.0\n"},
+		{[]string{"versions", "hello", "--constraint", "^1.5"}, "1.9.0\n1.10.0\n"},
+		{[]string{"versions", "hello", "--installed"}, "1.9.0\n"},
+		{[]string{"versions", "hello", "--platform", "windows-arm64"}, ""},
+	}
+	for _, tt := range tests {
+		if got := run(t, tt.args...); got != tt.want {
+			t.Errorf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	var infos []struct {
+		Version   string
+		Platforms []string
+		Installed bool
+		Active    bool
+	}
+	if err := json.Unmarshal([]byte(run(t, "versions", "hello", "-c", "1.9", "--json")), &infos); err != nil {
+		t.Fatalf("versions --json is not JSON: %v", err)
+	}
+	if len(infos) != 1 || !infos[0].Installed || !infos[0].Active || len(infos[0].Platforms) != 1 || infos[0].Platforms[0] != testsupport.Platform() {
+		t.Errorf("versions --json = %+v, want 1.9.0 installed and active", infos)
+	}
+
+	if err := runErr(t, "versions", "hello", "--constraint", "^x"); err == nil {
+		t.Error("versions should reject an invalid constraint")
+	}
+}
+
+func TestVersionRanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.2.0", "1.2.3", "1.10.0", "2.0.0"}})
+	run(t, "update")
+
+	if out := run(t, "install", "hello@^1"); !strings.Contains(out, "Resolved hello@^1 to 1.10.0") {
+		t.Errorf("install hello@^1 output = %q, want 1.10.0", out)
+	}
+	if out := run(t, "install", "hello@~1.2"); !strings.Contains(out, "Resolved hello@~1.2 to 1.2.3") {
+		t.Errorf("install hello@~1.2 output = %q, want 1.2.3", out)
+	}
+	if err := runErr(t, "install", "hello@^3"); err == nil {
+		t.Error("install should fail when no version matches")
+	}
+
+	// use resolves against installed versions only
+	run(t, "use", "hello@1.2")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.2.3" {
+		t.Errorf("shim after use hello@1.2 = %q, want %q", got, "hello 1.2.3")
+	}
+	run(t, "use", "hello@>=1.0 <2")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.10.0" {
+		t.Errorf("shim after use hello@>=1.0 <2 = %q, want %q", got, "hello 1.10.0")
+	}
+	if err := runErr(t, "use", "hello@2"); err == nil || !strings.Contains(err.Error(), "installed: 1.2.3, 1.10.0") {
+		t.Errorf("use hello@2 = %v, want an error listing the installed versions", err)
+	}
+}
+
+func TestInstallTmpDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+
+	// By default archives are staged under the nori root
+	run(t, "install", "hello@1.0.0")
+	if entries, err := os.ReadDir(filepath.Join(root, "tmp")); err != nil || len(entries) != 0 {
+		t.Errorf("staging under the nori root = %v, %v, want an empty tmp directory", entries, err)
+	}
+
+	staging := filepath.Join(t.TempDir(), "staging")
+	t.Setenv("NORI_TMPDIR", staging)
+	run(t, "install", "hello@2.0.0", "--use")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 2.0.0")
+	}
+
+	// Staging happened in NORI_TMPDIR and was cleaned up afterwards
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		t.Fatalf("NORI_TMPDIR was not used: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("NORI_TMPDIR has %d leftover entries, want none", len(entries))
+	}
+}
+
+func TestInstallThroughProxy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+
+	// The proxy fetches the original URL on nori's behalf, like a caching server would
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		original := r.Header.Get("X-Nori-Original-URL")
+		proxied = append(proxied, original)
+		resp, err := reg.Client().Get(original)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+	t.Setenv("NORI_ASSET_PROXY", proxy.URL+"/cache")
+
+	run(t, "install", "hello@1.0.0", "--use")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.0.0")
+	}
+	if len(proxied) == 0 || !strings.HasPrefix(proxied[len(proxied)-1], reg.URL+"/") {
+		t.Errorf("proxied requests = %v, want the asset fetched through the proxy", proxied)
+	}
+
+	// An unusable proxy is reported rather than silently bypassed
+	t.Setenv("NORI_ASSET_PROXY", "cache.example.com")
+	if err := runErr(t, "install", "hello@2.0.0"); err == nil || !strings.Contains(err.Error(), "proxy") {
+		t.Errorf("install with an invalid proxy = %v, want a proxy error", err)
+	}
+}
+
+func TestRootPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+
+	root := filepath.Join(t.TempDir(), "nori")
+	t.Setenv("NORI_ROOT", root)
+	testsupport.NewRegistry(t)
+
+	run(t, "list")
+	if info, err := os.Stat(root); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("new nori root = %v, %v, want mode 0700", info, err)
+	}
+
+	os.Chmod(root, 0777)
+	out, err := runResult(t, "doctor")
+	if !strings.Contains(out, root+" is writable by other users") || exitCode(err)&cli.ExitMisconfigured == 0 {
+		t.Errorf("doctor output = %q (%v), want the writable root reported", out, err)
+	}
+	os.Chmod(root, 0700)
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	t.Setenv("NORI_ROOT", shared)
+	t.Setenv("NORI_ROOT_MODE", "0755")
+	run(t, "list")
+	if info, err := os.Stat(shared); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("root with NORI_ROOT_MODE=0755 = %v, %v, want mode 0755", info, err)
+	}
+}
+
+func TestCompleteVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.2.0", "1.10.0", "1.9.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"2.0.0"}},
+	)
+
+	run(t, "update")
+	run(t, "install", "hello@1.9.0")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"install"}, "hello world"},
+		{[]string{"install", "hello", "@"}, "1.10.0 1.9.0 1.2.0"},
+		{[]string{"install", "hello@"}, "hello@1.10.0 hello@1.9.0 hello@1.2.0"},
+		{[]string{"use", "hello", "@"}, "1.9.0"},
+		{[]string{"use"}, "hello"},
+	}
+
+	for _, tt := range tests {
+		out := run(t, append(tt.args, "--generate-shell-completion")...)
+		if got := strings.Join(strings.Fields(out), " "); got != tt.want {
+			t.Errorf("completion for %v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyRepair(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "verify", "hello")
+
+	bin := filepath.Join(root, "installs", "hello", "1.0.0", testsupport.Platform(), "bin", "hello")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho broken\n"), 0755); err != nil {
+		t.Fatalf("failed to corrupt binary: %v", err)
+	}
+
+	if err := runErr(t, "verify", "hello"); err == nil {
+		t.Fatal("verify should fail for a modified binary")
+	}
+
+	downloads := reg.Requests("/assets/hello-1.0.0.tar.gz")
+	out := run(t, "verify", "hello@1.0.0", "--repair")
+	if !strings.Contains(out, "repaired 1 file(s)") {
+		t.Errorf("verify --repair output = %q, want one repaired file", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after repair = %q, want %q", got, "hello 1.0.0")
+	}
+	if reg.Requests("/assets/hello-1.0.0.tar.gz") != downloads {
+		t.Error("repair downloaded the archive again instead of using the cached copy")
+	}
+}
+
+func TestInstallRecompressedArchive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	// The manifest lists the archive as first published; upstream has since recompressed it
+	published := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", "1.0.0")})
+	gz, err := gzip.NewReader(bytes.NewReader(published))
+	if err != nil {
+		t.Fatalf("failed to read fixture archive: %v", err)
+	}
+	payload, _ := io.ReadAll(gz)
+	var recompressed bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&recompressed, gzip.NoCompression)
+	gw.Write(payload)
+	gw.Close()
+	reg.SetFile("/assets/tool.tar.gz", recompressed.Bytes())
+
+	manifest := func(extra string) []byte {
+		return []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      ` + testsupport.Platform() + `:
+        type: tar
+        url: ` + reg.URL + `/assets/tool.tar.gz
+        checksum: ` + testsupport.Checksum(published) + `
+` + extra)
+	}
+	reg.SetFile("/packages/tool.yaml", manifest(""))
+	if err := runErr(t, "install", "tool@1.0.0"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("install = %v, want a checksum mismatch", err)
+	}
+
+	reg.SetFile("/packages/tool.yaml", manifest("        payload_checksum: "+testsupport.Checksum(payload)+"\n"))
+	run(t, "update")
+	run(t, "install", "tool@1.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "tool 1.0.0")
+	}
+
+	// Repair downloads again, since the archive cache is keyed by the published checksum
+	bin := filepath.Join(root, "installs", "tool", "1.0.0", testsupport.Platform(), "bin", "tool")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho broken\n"), 0755); err != nil {
+		t.Fatalf("failed to corrupt binary: %v", err)
+	}
+	if out := run(t, "verify", "tool@1.0.0", "--repair"); !strings.Contains(out, "repaired 1 file(s)") {
+		t.Errorf("verify --repair output = %q, want one repaired file", out)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim after repair = %q, want %q", got, "tool 1.0.0")
+	}
+}
+
+func TestInstallSmokeTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	// 2.0.0 was published broken: its binary reports the wrong version
+	manifest := "schema: 1\nname: tool\nbins:\n  - bin/tool\nsmoke_test:\n  command: [tool]\n  expect: \"^tool {version}$\"\nversions:\n"
+	for version, build := range map[string]string{"1.0.0": "1.0.0", "2.0.0": "1.9.9"} {
+		archive := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", build)})
+		reg.SetFile("/assets/tool-"+version+".tar.gz", archive)
+		manifest += fmt.Sprintf("  %q:\n    platforms:\n      %s:\n        type: tar\n        url: %s/assets/tool-%s.tar.gz\n        checksum: %s\n",
+			version, testsupport.Platform(), reg.URL, version, testsupport.Checksum(archive))
+	}
+	reg.SetFile("/packages/tool.yaml", []byte(manifest))
+
+	run(t, "install", "tool@1.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "tool 1.0.0")
+	}
+
+	err := runErr(t, "install", "--use", "tool@2.0.0")
+	if err == nil || !strings.Contains(err.Error(), "failed its smoke test") || !strings.Contains(err.Error(), "tool@1.0.0 is active again") {
+		t.Fatalf("install of a broken version = %v, want a smoke test failure that restores 1.0.0", err)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim after failed smoke test = %q, want %q", got, "tool 1.0.0")
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "tool", "2.0.0")); !os.IsNotExist(err) {
+		t.Errorf("broken version should be removed, stat = %v", err)
+	}
+
+	run(t, "install", "--use", "--skip-smoke-test", "tool@2.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.9.9" {
+		t.Errorf("shim with --skip-smoke-test = %q, want %q", got, "tool 1.9.9")
+	}
+
+	// Without an earlier version to go back to, the package is left inactive
+	run(t, "uninstall", "--all", "tool")
+	if err := runErr(t, "install", "tool@2.0.0"); err == nil {
+		t.Fatal("install of a broken version should fail")
+	}
+	if _, err := os.Lstat(filepath.Join(root, "shims", "tool")); !os.IsNotExist(err) {
+		t.Errorf("shim of a broken version should be removed, stat = %v", err)
+	}
+}
+
+func TestActivationRollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0", "2.0.0"}, Bins: []string{"bin/aa", "bin/zz"}})
+	activeVersion := func() string {
+		active, _ := config.New(platform.NewPaths(root)).GetActive("tool")
+		return active
+	}
+	// A directory in the way of a shim makes writing the shims fail partway
+	blockShim := func() {
+		if err := os.MkdirAll(filepath.Join(root, "shims", "zz", "in-the-way"), 0755); err != nil {
+			t.Fatalf("failed to block shim: %v", err)
+		}
+	}
+
+	run(t, "install", "tool@1.0.0")
+	os.Remove(filepath.Join(root, "shims", "zz"))
+	blockShim()
+	if err := runErr(t, "install", "--use", "tool@2.0.0"); err == nil || !strings.Contains(err.Error(), "failed to update shims") {
+		t.Fatalf("install with a blocked shim = %v, want a shim failure", err)
+	}
+	if active := activeVersion(); active != "1.0.0" {
+		t.Errorf("active version after a failed activation = %q, want 1.0.0", active)
+	}
+	if got := shimOutput(t, root, "aa"); got != "tool 1.0.0" {
+		t.Errorf("shim after a failed activation = %q, want %q", got, "tool 1.0.0")
+	}
+
+	// With nothing active before, the shims written partway are removed
+	os.RemoveAll(filepath.Join(root, "shims", "zz"))
+	run(t, "uninstall", "tool@1.0.0")
+	blockShim()
+	if err := runErr(t, "use", "tool@2.0.0"); err == nil {
+		t.Fatal("use with a blocked shim should fail")
+	}
+	if active := activeVersion(); active != "" {
+		t.Errorf("active version = %q, want none", active)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "shims", "aa")); !os.IsNotExist(err) {
+		t.Errorf("shim written before the failure should be removed, stat = %v", err)
+	}
+}
+
+func TestDownloadConcurrency(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+
+	t.Setenv("NORI_DOWNLOAD_CONCURRENCY", "none")
+	if err := runErr(t, "install", "hello@1.0.0"); err == nil || !strings.Contains(err.Error(), "NORI_DOWNLOAD_CONCURRENCY") {
+		t.Errorf("install with a bad NORI_DOWNLOAD_CONCURRENCY = %v, want it named in the error", err)
+	}
+
+	// The fixture archive is below the segment threshold, so it downloads as usual
+	t.Setenv("NORI_DOWNLOAD_CONCURRENCY", "4")
+	run(t, "install", "hello@1.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.0.0")
+	}
+}
+
+func TestInstallLimitRate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+
+	if err := runErr(t, "install", "hello@1.0.0", "--limit-rate", "fast"); err == nil || !strings.Contains(err.Error(), "invalid rate") {
+		t.Errorf("install --limit-rate fast = %v, want an invalid rate", err)
+	}
+	run(t, "install", "hello@1.0.0", "--limit-rate", "10M")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.0.0")
+	}
+
+	// The setting applies when the flag isn't given, and the flag overrides it
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("limit_rate: fast\n"), 0644)
+	if err := runErr(t, "install", "hello@2.0.0"); err == nil || !strings.Contains(err.Error(), "limit_rate") {
+		t.Errorf("install with a bad limit_rate = %v, want the setting named in the error", err)
+	}
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("limit_rate: 10M\n"), 0644)
+	run(t, "install", "hello@2.0.0", "--limit-rate", "20M")
+}
+
+func TestRegistryAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	reg.RequireHeader("Authorization", "Bearer s3cret")
+
+	if err := runErr(t, "install", "hello@1.0.0"); err == nil {
+		t.Fatal("install from a private registry without credentials succeeded")
+	}
+
+	// The token reaches the index, manifests and assets alike
+	t.Setenv("NORI_REGISTRY_TOKEN", "s3cret")
+	run(t, "install", "hello@1.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.0.0")
+	}
+
+	// Headers of the auth setting are sent to their host
+	t.Setenv("NORI_REGISTRY_TOKEN", "")
+	host := strings.TrimPrefix(reg.URL, "https://")
+	yaml := "auth:\n  " + host + ":\n    headers:\n      Authorization: Bearer s3cret\n"
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(yaml), 0644)
+	run(t, "install", "hello@2.0.0")
+
+	// As is the login for the registry's host in .netrc
+	reg.AddPackage(testsupport.Package{Name: "world", Versions: []string{"1.0.0"}})
+	os.Remove(filepath.Join(root, "config", "config.yaml"))
+	reg.RequireHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("ci:p4ss")))
+	os.WriteFile(filepath.Join(root, "netrc"), []byte("machine 127.0.0.1 login ci password p4ss\n"), 0600)
+	run(t, "install", "world")
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(yaml), 0644)
+
+	// Credentials aren't exported
+	if out := run(t, "config", "export"); strings.Contains(out, "s3cret") || !strings.Contains(out, "for 1 host(s), are left out") {
+		t.Errorf("config export = %q, want the credentials left out", out)
+	}
+}
+
+func TestRegistrySettingErrors(t *testing.T) {
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+
+	// Settings the registry can't be reached with are reported rather than ignored
+	for _, tt := range []struct{ yaml, want string }{
+		{"proxy: not a url\n", "proxy setting"},
+		{"ca_file: " + filepath.Join(root, "missing.pem") + "\n", "ca_file"},
+		{"registries:\n  - name: ../corp\n    url: https://example.com\n", "registries setting"},
+	} {
+		os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(tt.yaml), 0644)
+		if err := runErr(t, "search", "hello"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("search with %q = %v, want an error containing %q", tt.yaml, err, tt.want)
+		}
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	corp := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Description: "corp greeting", Versions: []string{"2.0.0"}},
+	)
+	team := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "lint", Description: "team linter", Versions: []string{"1.0.0"}},
+	)
+	public := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Description: "prints a greeting", Versions: []string{"1.0.0"}},
+		testsupport.Package{Name: "fd", Description: "find files", Versions: []string{"1.0.0"}},
+	)
+	settings := "registries:\n  - name: corp\n    url: " + corp.URL + "\n  - name: team\n    url: " + team.URL + "\n    prefix: team\n"
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(settings), 0644)
+
+	run(t, "update")
+	if _, err := os.Stat(filepath.Join(root, "registry", "registries", "corp", "index.yaml")); err != nil {
+		t.Errorf("corp index was not cached apart from the default registry's: %v", err)
+	}
+
+	// Search lists both hellos with their origin
+	out := run(t, "search", "e")
+	if !strings.Contains(out, "REGISTRY") || !strings.Contains(lineWith(out, "team/lint"), "team") {
+		t.Errorf("search output = %q, want team/lint with its registry", out)
+	}
+	if strings.Count(out, "hello") != 2 || !strings.Contains(lineWith(out, "hello"), "corp") {
+		t.Errorf("search output = %q, want corp's hello first, then the default registry's", out)
+	}
+
+	// The first registry with a package wins; others are still found
+	run(t, "install", "hello")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("hello shim = %q, want corp's 2.0.0", got)
+	}
+	run(t, "install", "fd")
+	if public.Requests("/packages/fd.yaml") == 0 || corp.Requests("/packages/fd.yaml") != 0 {
+		t.Error("fd was not looked up in the default registry only, as the corp index lacks it")
+	}
+
+	// Prefixed packages only go by their qualified names
+	if err := runErr(t, "install", "lint"); err == nil {
+		t.Error("install lint found a package only the prefixed team registry has")
+	}
+	run(t, "install", "team/lint")
+	if got := shimOutput(t, root, "lint"); got != "lint 1.0.0" {
+		t.Errorf("lint shim = %q, want %q", got, "lint 1.0.0")
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "@team", "lint", "1.0.0")); err != nil {
+		t.Errorf("team/lint was not installed under @team: %v", err)
+	}
+	if out := run(t, "info", "team/lint"); !strings.Contains(out, "Registry: team") {
+		t.Errorf("info team/lint = %q, want its registry", out)
+	}
+
+	run(t, "state", "rebuild")
+	if out := run(t, "list"); lineWith(out, "team/lint") == "" {
+		t.Errorf("list after state rebuild = %q, want team/lint", out)
+	}
+	run(t, "uninstall", "team/lint@1.0.0")
+	if _, err := os.Stat(filepath.Join(root, "installs", "@team")); !os.IsNotExist(err) {
+		t.Errorf("@team is left behind after uninstalling team/lint: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(settings+"  - name: default\n    url: https://example.com\n"), 0644)
+	if err := runErr(t, "doctor"); err == nil {
+		t.Error("doctor accepted a registry named default")
+	}
+}
+
+func TestRegistryCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	corp := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"2.0.0"}},
+	)
+	mirror := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "fd", Versions: []string{"1.0.0"}},
+	)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}},
+	)
+	settingsPath := filepath.Join(root, "config", "config.yaml")
+	os.MkdirAll(filepath.Dir(settingsPath), 0755)
+	os.WriteFile(settingsPath, []byte("# mine\nauto_use: true\n"), 0644)
+
+	run(t, "registry", "add", "corp", corp.URL, "--prefix", "corp")
+	if data, _ := os.ReadFile(settingsPath); !strings.HasPrefix(string(data), "# mine\nauto_use: true\n") {
+		t.Errorf("config.yaml = %q, want its comment kept", data)
+	}
+	for _, args := range [][]string{
+		{"registry", "add", "corp", "https://other.example.com"},
+		{"registry", "add", "team", "ftp://example.com"},
+		{"registry", "add", "team"},
+	} {
+		if err := runErr(t, args...); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+	out := run(t, "registry", "list")
+	if !strings.Contains(lineWith(out, "corp"), corp.URL) || strings.Index(out, "corp") > strings.Index(out, "default") {
+		t.Errorf("registry list = %q, want corp looked up before the default registry", out)
+	}
+
+	run(t, "update")
+	run(t, "install", "corp/hello")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("hello shim = %q, want corp's 2.0.0", got)
+	}
+
+	// Removing a registry drops its cache but not what was installed from it
+	run(t, "registry", "remove", "corp")
+	if data, _ := os.ReadFile(settingsPath); strings.Contains(string(data), "corp") {
+		t.Errorf("config.yaml = %q, want corp removed", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "registry", "registries", "corp")); !os.IsNotExist(err) {
+		t.Errorf("corp's cache is left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "@corp", "hello", "2.0.0")); err != nil {
+		t.Errorf("removing corp uninstalled corp/hello: %v", err)
+	}
+	for _, args := range [][]string{{"registry", "remove", "corp"}, {"registry", "remove", "default"}} {
+		if err := runErr(t, args...); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+
+	// The default registry's URL is a setting, which NORI_REGISTRY_URL overrides
+	run(t, "registry", "set-default", mirror.URL)
+	if _, err := os.Stat(filepath.Join(root, "registry", "index.yaml")); !os.IsNotExist(err) {
+		t.Errorf("the previous default registry's index is still cached: %v", err)
+	}
+	if err := runErr(t, "info", "fd"); err == nil {
+		t.Error("info fd found a package of the new default registry while NORI_REGISTRY_URL is set")
+	}
+	t.Setenv("NORI_REGISTRY_URL", "")
+	run(t, "update")
+	if out := run(t, "info", "fd"); !strings.Contains(out, "fd") {
+		t.Errorf("info fd = %q, want the new default registry's fd", out)
+	}
+	if out := run(t, "registry", "list"); !strings.Contains(lineWith(out, "default"), mirror.URL) {
+		t.Errorf("registry list = %q, want the default registry at %s", out, mirror.URL)
+	}
+	if err := runErr(t, "registry", "set-default", "registry.example.com"); err == nil {
+		t.Error("set-default accepted a URL without a scheme")
+	}
+
+	run(t, "registry", "set-default", "--unset")
+	if data, _ := os.ReadFile(settingsPath); strings.Contains(string(data), "registry_url") {
+		t.Errorf("config.yaml = %q, want registry_url unset", data)
+	}
+}
+
+func TestConfigImportAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	settingsPath := filepath.Join(root, "config", "config.yaml")
+	os.MkdirAll(filepath.Dir(settingsPath), 0755)
+	os.WriteFile(settingsPath, []byte("auto_use: true\n"), 0644)
+
+	// Credentials make config.yaml readable by its owner only
+	imported := filepath.Join(t.TempDir(), "team.yaml")
+	os.WriteFile(imported, []byte("auth:\n  registry.example.com:\n    token: s3cret\n"), 0644)
+	run(t, "config", "import", imported)
+	if info, err := os.Stat(settingsPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("config.yaml mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	// A config directory readable by everyone, as older releases created it, is reported
+	if out, err := runResult(t, "doctor"); err == nil || !strings.Contains(out, filepath.Dir(settingsPath)+" holds credentials but is readable by other users") {
+		t.Errorf("doctor = %q, %v, want the readable config directory reported", out, err)
+	}
+	os.Chmod(filepath.Dir(settingsPath), 0700)
+	if out, _ := runResult(t, "doctor"); !strings.Contains(out, "credentials are not readable by other users") {
+		t.Errorf("doctor = %q, want the credentials reported as private", out)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	settingsPath := filepath.Join(root, "config", "config.yaml")
+	os.MkdirAll(filepath.Dir(settingsPath), 0755)
+	os.WriteFile(settingsPath, []byte("# mine\nauto_use: true\nlimit_rate: 1M\n"), 0644)
+
+	// Export marks settings overridden by the environment and flags
+	t.Setenv("NORI_TMPDIR", "/scratch")
+	out := run(t, "config", "export", "--limit-rate", "2M")
+	for _, want := range []string{"auto_use: true # config.yaml", "limit_rate: 2M # --limit-rate", "tmp_dir: /scratch # NORI_TMPDIR"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("config export = %q, want a line %q", out, want)
+		}
+	}
+
+	// Import keeps the settings the file doesn't set, unless told to replace them
+	imported := filepath.Join(t.TempDir(), "team.yaml")
+	os.WriteFile(imported, []byte("strict: true\n"), 0644)
+	run(t, "config", "import", imported)
+	if data, _ := os.ReadFile(settingsPath); !strings.Contains(string(data), "strict: true") || !strings.Contains(string(data), "# mine\nauto_use: true") {
+		t.Errorf("config.yaml = %q, want both settings and the comment kept", data)
+	}
+	run(t, "config", "import", "--replace", imported)
+	if data, _ := os.ReadFile(settingsPath); strings.Contains(string(data), "auto_use") {
+		t.Errorf("config.yaml = %q, want auto_use dropped", data)
+	}
+	for _, bad := range []string{"strcit: true\n", "limit_rate: fast\n", "ca_file: /nonexistent/ca.pem\n"} {
+		os.WriteFile(imported, []byte(bad), 0644)
+		if err := runErr(t, "config", "import", imported); err == nil {
+			t.Errorf("config import of %q succeeded, want an error", bad)
+		}
+	}
+
+	// Edit saves what the editor writes, comments and all, only if it is valid
+	edited := filepath.Join(t.TempDir(), "edited.yaml")
+	editor := filepath.Join(t.TempDir(), "editor")
+	os.WriteFile(editor, []byte("#!/bin/sh\ncp "+edited+" \"$1\"\n"), 0755)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor)
+
+	os.WriteFile(edited, []byte("# for the proxy\nproxy: ftp://proxy.example.com\n"), 0644)
+	if err := runErr(t, "config", "edit"); err == nil || !strings.Contains(err.Error(), "proxy setting") {
+		t.Errorf("config edit with an invalid proxy = %v, want the setting named", err)
+	}
+	os.WriteFile(edited, []byte("# for the proxy\nproxy: http://proxy.example.com:3128\n"), 0644)
+	run(t, "config", "edit")
+	if data, _ := os.ReadFile(settingsPath); string(data) != "# for the proxy\nproxy: http://proxy.example.com:3128\n" {
+		t.Errorf("config.yaml = %q, want the edited file", data)
+	}
+	if copies, _ := filepath.Glob(filepath.Join(root, "config", "config-*.yaml")); len(copies) != 0 {
+		t.Errorf("edit copies %v left behind", copies)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{
+		Name:      "hello",
+		Versions:  []string{"1.0.0", "2.0.0"},
+		Platforms: []string{testsupport.Platform(), "windows-arm64"},
+	})
+	run(t, "update")
+
+	out := run(t, "prefetch", "hello@1.0.0", "--platform", "current", "--platform", "windows-arm64", "--limit-rate", "10M")
+	if !strings.Contains(out, "Prefetched 1 asset(s), 1 already cached") {
+		t.Errorf("prefetch output = %q, want one download shared by both platforms", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs")); !os.IsNotExist(err) {
+		t.Error("prefetch should not install anything")
+	}
+
+	// Installing a prefetched version doesn't touch the network
+	downloads := reg.Requests("/assets/hello-1.0.0.tar.gz")
+	if out := run(t, "install", "hello@1.0.0"); !strings.Contains(out, "Using cached archive") {
+		t.Errorf("install output = %q, want the cached archive used", out)
+	}
+	if reg.Requests("/assets/hello-1.0.0.tar.gz") != downloads {
+		t.Error("install downloaded a prefetched archive again")
+	}
+
+	// update --full prefetches the latest version of what's installed
+	out = run(t, "update", "--full")
+	if !strings.Contains(out, "Prefetching hello@2.0.0") {
+		t.Errorf("update --full output = %q, want hello@2.0.0 prefetched", out)
+	}
+	if out := run(t, "update", "--full"); !strings.Contains(out, "hello@2.0.0 for "+testsupport.Platform()+" is already cached") {
+		t.Errorf("second update --full output = %q, want nothing downloaded again", out)
+	}
+
+	if err := runErr(t, "prefetch", "hello@1.0.0", "--limit-rate", "fast"); err == nil {
+		t.Error("prefetch should reject an invalid rate")
+	}
+	if err := runErr(t, "prefetch", "hello@9.9.9"); err == nil {
+		t.Error("prefetch should fail for an unknown version")
+	}
+}
+
+func TestCacheClean(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+	if out := run(t, "cache", "info"); !strings.Contains(out, "0 archive(s)") {
+		t.Errorf("cache info of an empty cache = %q", out)
+	}
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "prefetch", "hello@2.0.0")
+	os.WriteFile(filepath.Join(root, "cache", "sha256", "download.part"), []byte("partial"), 0644)
+	if out := run(t, "cache", "info"); !strings.Contains(out, "2 archive(s)") || !strings.Contains(out, "1 of them") {
+		t.Errorf("cache info = %q, want 2 archives, 1 of a version not installed", out)
+	}
+
+	// Only the archives of installed versions are kept, unless --all
+	if out := run(t, "cache", "clean"); !strings.Contains(out, "Removed 1 archive(s)") {
+		t.Errorf("cache clean = %q, want the prefetched archive removed", out)
+	}
+	if out := run(t, "cache", "info"); !strings.Contains(out, "1 archive(s)") || !strings.Contains(out, "0 of them") {
+		t.Errorf("cache info after clean = %q, want the installed version's archive kept", out)
+	}
+	if out := run(t, "cache", "clean", "--all"); !strings.Contains(out, "Removed 1 archive(s)") {
+		t.Errorf("cache clean --all = %q, want the last archive removed", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cache", "sha256", "download.part")); err != nil {
+		t.Errorf("cache clean removed a download in progress: %v", err)
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("daemon_prefetch: [hello, missing]\n"), 0644)
+
+	out := run(t, "daemon", "--once")
+	if !strings.Contains(out, "Refreshed 1 package(s)") || !strings.Contains(out, "Prefetching hello@2.0.0") {
+		t.Errorf("daemon output = %q, want the registry refreshed and hello@2.0.0 prefetched", out)
+	}
+	downloads := reg.Requests("/assets/hello-2.0.0.tar.gz")
+	if downloads == 0 {
+		t.Error("daemon did not download the latest hello")
+	}
+	if out := run(t, "daemon", "--once"); !strings.Contains(out, "already cached") || reg.Requests("/assets/hello-2.0.0.tar.gz") != downloads {
+		t.Errorf("second daemon output = %q, want nothing downloaded again", out)
+	}
+
+	if err := runErr(t, "daemon", "--interval", "1m"); err == nil {
+		t.Error("daemon should reject an interval shorter than the minimum")
+	}
+
+	t.Setenv("NORI_ROOT", root)
+	unit := run(t, "daemon", "unit", "--format", "systemd", "--interval", "2h")
+	for _, want := range []string{"daemon --interval 2h0m0s", "Environment=NORI_ROOT=" + root, "WantedBy=default.target"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("systemd unit = %q, want it to contain %q", unit, want)
+		}
+	}
+	if plist := run(t, "daemon", "unit", "--format", "launchd"); !strings.Contains(plist, "<string>daemon</string>") || !strings.Contains(plist, "<key>NORI_ROOT</key>") {
+		t.Errorf("launchd plist = %q, want the daemon command and environment", plist)
+	}
+	if err := runErr(t, "daemon", "unit", "--format", "upstart"); err == nil {
+		t.Error("daemon unit should reject an unknown format")
+	}
+}
+
+func TestDaemonAPI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+
+	testsupport.CaptureStdout(t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- cli.App().Run(ctx, []string{"nori", "daemon"}) }()
+		defer func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("daemon failed: %v", err)
+			}
+		}()
+
+		var conn net.Conn
+		for deadline := time.Now().Add(10 * time.Second); conn == nil; {
+			var err error
+			if conn, err = net.Dial("unix", filepath.Join(root, "daemon.sock")); err != nil {
+				if time.Now().After(deadline) {
+					t.Fatalf("daemon socket never came up: %v", err)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+		defer conn.Close()
+		responses := json.NewDecoder(conn)
+		call := func(request string) map[string]any {
+			t.Helper()
+			if _, err := io.WriteString(conn, request+"\n"); err != nil {
+				t.Fatal(err)
+			}
+			var resp map[string]any
+			if err := responses.Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			return resp
+		}
+
+		// The first refresh runs as the daemon starts
+		for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+			status := call(`{"jsonrpc": "2.0", "id": 1, "method": "status"}`)
+			if result, _ := status["result"].(map[string]any); result["last_refresh"] != nil {
+				if result["packages"] != 1.0 || result["root"] != root {
+					t.Errorf("status = %v, want one package refreshed in %s", result, root)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("status = %v, want a refresh", status)
+			}
+		}
+
+		for request, want := range map[string]string{
+			`{"jsonrpc": "2.0", "id": 2, "method": "installed"}`:                          `[{"active":"1.0.0","name":"hello","versions":["1.0.0"]}]`,
+			`{"jsonrpc": "2.0", "id": 3, "method": "outdated"}`:                           `[{"active":true,"current":"1.0.0","latest":"2.0.0","name":"hello","state":"behind"}]`,
+			`{"jsonrpc": "2.0", "id": 4, "method": "search", "params": {"query": "hel"}}`: `[{"description":"","latest":"2.0.0","name":"hello","registry":"default"}]`,
+		} {
+			resp := call(request)
+			if got, _ := json.Marshal(resp["result"]); string(got) != want {
+				t.Errorf("%s = %v, want result %s", request, resp, want)
+			}
+		}
+
+		for request, code := range map[string]float64{
+			`{"jsonrpc": "2.0", "id": 5, "method": "refresh"}`:               -32000, // just refreshed
+			`{"jsonrpc": "2.0", "id": 6, "method": "install"}`:               -32601,
+			`{"jsonrpc": "2.0", "id": 7, "method": "search", "params": [1]}`: -32602,
+			`{"id": 8, "method": "status"}`:                                  -32600,
+			`{not json`:                                                      -32700,
+		} {
+			resp := call(request)
+			if e, _ := resp["error"].(map[string]any); e["code"] != code {
+				t.Errorf("%s = %v, want error %v", request, resp, code)
+			}
+		}
+	})
+}
+
+func TestInitProject(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "node", Versions: []string{"20.9.0", "20.10.0", "22.2.0"}},
+		testsupport.Package{Name: "go", Versions: []string{"1.22.5"}},
+	)
+	run(t, "update")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile(".nvmrc", []byte("v20\n"), 0644)
+	os.WriteFile("go.mod", []byte("module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.5\n"), 0644)
+	os.WriteFile(".python-version", []byte("3.12.0\n"), 0644)
+
+	out := run(t, "init", "--project")
+	if !strings.Contains(out, "Pinned node 20.10.0 (from .nvmrc)") || !strings.Contains(out, "Pinned go 1.22.5 (from go.mod)") {
+		t.Errorf("init --project output = %q, want node and go pinned", out)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "nori.yaml"))
+	if err != nil || !strings.Contains(string(data), "versions:\n  go: 1.22.5\n  node: 20.10.0\n") || strings.Contains(string(data), "python") {
+		t.Errorf("nori.yaml = %q, %v, want go and node pinned, and python skipped as unknown", data, err)
+	}
+	if out := run(t, "which", "node"); !strings.Contains(out, "20.10.0") {
+		t.Errorf("which node = %q, want the version pinned in nori.yaml", out)
+	}
+
+	// Running it again keeps what the directory already pins, and the rest of nori.yaml
+	os.WriteFile(".nvmrc", []byte("22.2.0\n"), 0644)
+	os.WriteFile(".nori-versions", []byte("go: 1.22.5\n"), 0644)
+	os.WriteFile("nori.yaml", append(data, "tasks:\n  test: go test ./... # everything\n"...), 0644)
+	out = run(t, "init", "--project", "--lock")
+	if !strings.Contains(out, "Kept node 20.10.0 (already in nori.yaml)") || !strings.Contains(out, "Kept go 1.22.5 (already in .nori-versions)") {
+		t.Errorf("second init --project output = %q, want node and go kept", out)
+	}
+	if data, _ := os.ReadFile("nori.yaml"); !strings.Contains(string(data), "test: go test ./... # everything") {
+		t.Errorf("nori.yaml = %q, want its tasks kept", data)
+	}
+
+	// --lock locks what is pinned
+	lock, err := project.LoadLock(filepath.Join(dir, "nori.lock"))
+	if err != nil || lock.Packages["node"].Version != "20.10.0" || lock.Packages["go"].Version != "1.22.5" {
+		t.Errorf("nori.lock = %+v, %v, want node and go locked", lock, err)
+	}
+}
+
+func TestInstallGroupRange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "1.1.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0"}},
+	)
+	reg.SetFile("/packages/kit.yaml", []byte("schema: 1\nname: kit\nmembers:\n  hello: ^1\n  world: 1.0.0\n"))
+
+	// A member's range installs its highest matching release
+	if out := run(t, "install", "kit"); !strings.Contains(out, "Resolved hello@^1 to 1.1.0") {
+		t.Errorf("install kit = %q, want hello's range resolved", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.1.0" {
+		t.Errorf("hello = %q, want %q", got, "hello 1.1.0")
+	}
+
+	// Installing the group again after an update moves the member to the newest match
+	reg.AddPackage(testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"}})
+	run(t, "update")
+	run(t, "install", "kit")
+	if got := shimOutput(t, root, "hello"); got != "hello 1.2.0" {
+		t.Errorf("hello after update = %q, want %q", got, "hello 1.2.0")
+	}
+}
+
+func TestWhy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0"}},
+	)
+	reg.SetFile("/packages/kit.yaml", []byte("schema: 1\nname: kit\nmembers:\n  hello: 1.0.0\n  world: 1.0.0\n"))
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "kit")
+	dir := t.TempDir()
+	t.Chdir(dir)
+	run(t, "local", "hello@2.0.0")
+	run(t, "install")
+
+	out := run(t, "why", "hello")
+	if !strings.Contains(out, "hello@1.0.0\n  requested by name\n  member of group kit\n  the active version\n") {
+		t.Errorf("why hello = %q, want 1.0.0 requested, in kit and active", out)
+	}
+	if !strings.Contains(out, "hello@2.0.0\n  pinned by "+filepath.Join(dir, ".nori-versions")+"\n") {
+		t.Errorf("why hello = %q, want 2.0.0 pinned by the project", out)
+	}
+	if out := run(t, "why", "world"); strings.Contains(out, "requested") || !strings.Contains(out, "member of group kit") {
+		t.Errorf("why world = %q, want only the group", out)
+	}
+
+	// Reasons outlive a rebuild of the state index, which can't find them on disk
+	run(t, "state", "rebuild")
+	if out := run(t, "why", "hello@1.0.0"); !strings.Contains(out, "requested by name") {
+		t.Errorf("why hello@1.0.0 after state rebuild = %q, want the recorded reasons", out)
+	}
+
+	if err := runErr(t, "why", "hello@3.0.0"); err == nil {
+		t.Error("why hello@3.0.0 should fail for a version that isn't installed")
+	}
+	if err := runErr(t, "why", "other"); err == nil {
+		t.Error("why other should fail for a package that isn't installed")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "1.2.0", "2.0.0"}},
+		testsupport.Package{Name: "other", Versions: []string{"1.0.0"}},
+	)
+	run(t, "update")
+	run(t, "install", "hello@2.0.0")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := runErr(t, "install"); err == nil {
+		t.Error("install without arguments should fail when nothing is pinned")
+	}
+
+	if out := run(t, "local", "hello@^1"); !strings.Contains(out, "Pinned hello 1.2.0") || !strings.Contains(out, "Run `nori install`") {
+		t.Errorf("local hello@^1 output = %q, want 1.2.0 pinned and an install hint", out)
+	}
+	run(t, "local", "other")
+	data, _ := os.ReadFile(filepath.Join(dir, ".nori-versions"))
+	if string(data) != "hello: 1.2.0\nother: 1.0.0\n" {
+		t.Errorf(".nori-versions = %q, want hello and other pinned", data)
+	}
+
+	run(t, "install")
+	if out := run(t, "which", "hello"); !strings.Contains(out, filepath.Join("hello", "1.2.0")) {
+		t.Errorf("which hello in project = %q, want the pinned 1.2.0 installed", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "config", "active.yaml")); !strings.Contains(string(data), "hello: 2.0.0") {
+		t.Errorf("active.yaml = %q, want hello 2.0.0 left active", data)
+	}
+
+	run(t, "local", "other", "--unset")
+	data, _ = os.ReadFile(filepath.Join(dir, ".nori-versions"))
+	if string(data) != "hello: 1.2.0\n" {
+		t.Errorf(".nori-versions after --unset = %q, want only hello", data)
+	}
+	if err := runErr(t, "local", "other", "--unset"); err == nil {
+		t.Error("local --unset should fail for a package that isn't pinned")
+	}
+}
+
+func TestDynamicShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0", "3.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+	shim := filepath.Join(root, "shims", "hello")
+
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want the active 1.0.0", got)
+	}
+	before, _ := os.ReadFile(shim)
+	run(t, "use", "hello@2.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after use = %q, want 2.0.0", got)
+	}
+	if after, _ := os.ReadFile(shim); string(after) != string(before) {
+		t.Errorf("shim changed from %q to %q, want it left alone when switching versions", before, after)
+	}
+
+	// Project pins and environment overrides apply when the shim runs
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile(".nori-versions", []byte("hello: 1.0.0\n"), 0644)
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim in project = %q, want the pinned 1.0.0", got)
+	}
+	t.Setenv("NORI_HELLO_VERSION", "2.0.0")
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim with NORI_HELLO_VERSION = %q, want 2.0.0", got)
+	}
+	t.Setenv("NORI_HELLO_VERSION", "3.0.0")
+	out, err := exec.Command(shim).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "hello@3.0.0 is selected by") || !strings.Contains(string(out), "nori install hello@3.0.0") {
+		t.Errorf("shim for an uninstalled version = %q, %v, want an install hint", out, err)
+	}
+}
+
+func TestReshim(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	run(t, "install", "hello@1.0.0")
+
+	// Shims written by earlier releases link straight to a version
+	shimsDir := filepath.Join(root, "shims")
+	os.Remove(filepath.Join(shimsDir, "hello"))
+	os.Symlink(filepath.Join(root, "installs", "hello", "1.0.0", testsupport.Platform(), "bin", "hello"), filepath.Join(shimsDir, "hello"))
+	os.WriteFile(filepath.Join(shimsDir, "gone"), []byte("#!/bin/sh\nexec \"/nowhere/gone\" \"$@\"\n"), 0755)
+	out, err := runResult(t, "doctor")
+	if exitCode(err)&cli.ExitMisconfigured == 0 || !strings.Contains(out, "run a fixed version") {
+		t.Errorf("doctor = %q, %v, want the fixed shim reported", out, err)
+	}
+
+	if out := run(t, "reshim"); !strings.Contains(out, "Rewrote 1 shim(s), removed 1 stale") {
+		t.Errorf("reshim output = %q, want hello rewritten and gone removed", out)
+	}
+	if info, err := os.Lstat(filepath.Join(shimsDir, "hello")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Error("reshim should replace the symlinked shim")
+	}
+	if _, err := os.Stat(filepath.Join(shimsDir, "gone")); !os.IsNotExist(err) {
+		t.Error("reshim should remove the shim no package provides")
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want hello 1.0.0", got)
+	}
+	if out, _ := runResult(t, "doctor"); !strings.Contains(out, "0 shim(s) from an earlier nori") || !strings.Contains(out, "0 dangling shim(s)") {
+		t.Errorf("doctor after reshim = %q, want no fixed or dangling shims", out)
+	}
+
+	// Uninstalling the active version removes its shims
+	run(t, "uninstall", "hello@1.0.0")
+	if _, err := os.Lstat(filepath.Join(shimsDir, "hello")); !os.IsNotExist(err) {
+		t.Error("uninstall should remove the shim of the active version")
+	}
+}
+
+func TestSystemDefaultVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	t.Chdir(t.TempDir())
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+
+	system := filepath.Join(root, "system")
+	os.MkdirAll(system, 0755)
+	os.WriteFile(filepath.Join(system, "active.yaml"), []byte("hello: 2.0.0\n"), 0644)
+
+	// The user's choice wins, and --explain shows both layers
+	out := run(t, "current", "--explain", "hello")
+	if !strings.Contains(out, filepath.Join(root, "config", "active.yaml")+" (your active versions)") || !strings.Contains(out, "hello 1.0.0 (used)") {
+		t.Errorf("current --explain = %q, want the user's version used", out)
+	}
+	if !strings.Contains(out, filepath.Join(system, "active.yaml")+" (machine-wide defaults)") || !strings.Contains(out, "hello 2.0.0 (overridden)") {
+		t.Errorf("current --explain = %q, want the default overridden", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want the user's version", got)
+	}
+
+	// Without a choice of the user's, the default applies
+	if out := run(t, "uninstall", "hello@1.0.0"); !strings.Contains(out, "hello@2.0.0, the machine-wide default, is active now") {
+		t.Errorf("uninstall output = %q, want the default reported", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after uninstall = %q, want the default", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "config", "active.yaml")); strings.Contains(string(data), "hello") {
+		t.Errorf("active.yaml = %q, want the default left out", data)
+	}
+}
+
+func TestOutdatedShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}}, testsupport.Package{Name: "world", Versions: []string{"1.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+	run(t, "install", "world@1.0.0")
+	if out := run(t, "list", "--outdated-shims"); !strings.Contains(out, "All shims are up to date") {
+		t.Errorf("list --outdated-shims = %q, want nothing stale", out)
+	}
+
+	// A bin an older version had, and a shim that was deleted
+	shimsDir := filepath.Join(root, "shims")
+	os.WriteFile(filepath.Join(shimsDir, "dropped"), []byte("#!/bin/sh\nexec nori exec-shim dropped \"$@\"\n"), 0755)
+	os.Remove(filepath.Join(shimsDir, "hello"))
+	out := run(t, "list", "--outdated-shims")
+	if !strings.Contains(lineWith(out, "dropped"), "no active package provides it") || !strings.Contains(lineWith(out, "hello"), "missing, though hello@1.0.0 provides it") {
+		t.Errorf("list --outdated-shims = %q, want dropped and hello listed", out)
+	}
+
+	// Switching versions repairs them, and leaves the shims that are fine alone
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(filepath.Join(shimsDir, "world"), old, old)
+	run(t, "use", "hello@2.0.0")
+	if _, err := os.Stat(filepath.Join(shimsDir, "dropped")); !os.IsNotExist(err) {
+		t.Error("use should remove the shim no active package provides")
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim = %q, want hello 2.0.0", got)
+	}
+	if info, err := os.Stat(filepath.Join(shimsDir, "world")); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("use rewrote the shim of world, which wasn't stale")
+	}
+
+	// So does uninstalling
+	os.WriteFile(filepath.Join(shimsDir, "dropped"), []byte("#!/bin/sh\nexec nori exec-shim dropped \"$@\"\n"), 0755)
+	run(t, "uninstall", "hello@1.0.0")
+	if _, err := os.Stat(filepath.Join(shimsDir, "dropped")); !os.IsNotExist(err) {
+		t.Error("uninstall should remove the shim no active package provides")
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after uninstall = %q, want hello 2.0.0", got)
+	}
+}
+
+func TestLockSync(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}, Platforms: []string{testsupport.Platform(), "windows-arm64"}})
+	run(t, "update")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := runErr(t, "lock"); err == nil {
+		t.Error("lock should fail when nothing is pinned")
+	}
+	if err := runErr(t, "sync"); err == nil {
+		t.Error("sync should fail without a lockfile")
+	}
+
+	os.WriteFile(".nori-versions", []byte("hello: 1.0.0\n"), 0644)
+	if out := run(t, "lock"); !strings.Contains(out, "Locked hello 1.0.0 (2 platform(s))") {
+		t.Errorf("lock output = %q, want hello locked for both platforms", out)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "nori.lock"))
+	if err != nil || !strings.Contains(string(data), "checksum: sha256:") {
+		t.Fatalf("nori.lock = %q, %v, want checksums recorded", data, err)
+	}
+
+	if out := run(t, "sync"); !strings.Contains(out, "1 installed, 0 already present") {
+		t.Errorf("sync output = %q, want hello installed", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "hello", "1.0.0")); err != nil {
+		t.Errorf("hello 1.0.0 should be installed: %v", err)
+	}
+	if out := run(t, "sync"); !strings.Contains(out, "0 installed, 1 already present") {
+		t.Errorf("second sync output = %q, want nothing installed", out)
+	}
+
+	// A lock that no longer matches the registry must not install anything
+	tampered := regexp.MustCompile(`sha256:[0-9a-f]{64}`).ReplaceAllString(string(data), "sha256:"+strings.Repeat("0", 64))
+	os.WriteFile(filepath.Join(dir, "nori.lock"), []byte(tampered), 0644)
+	if err := runErr(t, "sync"); err == nil || !strings.Contains(err.Error(), "nothing was installed") {
+		t.Errorf("sync with a tampered lock = %v, want a checksum failure", err)
+	}
+}
+
+func TestLockChannel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+	publish := func(build string) string {
+		archive := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", build)})
+		reg.SetFile("/nightly/tool.tar.gz", archive)
+		reg.SetFile("/nightly/SHA256SUMS", []byte(strings.TrimPrefix(testsupport.Checksum(archive), "sha256:")+"  tool.tar.gz\n"))
+		return testsupport.Checksum(archive)
+	}
+	build1 := publish("build-1")
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions: {}
+channels:
+  nightly:
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: `+reg.URL+`/nightly/tool.tar.gz
+        checksums_url: `+reg.URL+`/nightly/SHA256SUMS
+`))
+	run(t, "update")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile(".nori-versions", []byte("tool: nightly\n"), 0644)
+	if out := run(t, "lock"); !strings.Contains(out, "Locked tool nightly to its current build") {
+		t.Errorf("lock output = %q, want the channel locked", out)
+	}
+	if data, _ := os.ReadFile("nori.lock"); !strings.Contains(string(data), build1) {
+		t.Fatalf("nori.lock = %q, want the digest of the current build", data)
+	}
+
+	// A digest pin locks the channel build it names
+	os.WriteFile(".nori-versions", []byte("tool: "+build1+"\n"), 0644)
+	if out := run(t, "lock"); !strings.Contains(out, "Locked tool nightly") {
+		t.Errorf("lock of a digest pin = %q, want the channel locked", out)
+	}
+	os.WriteFile(".nori-versions", []byte("tool: nightly\n"), 0644)
+
+	// Once the channel moves on, sync refuses rather than install another build
+	publish("build-2")
+	if err := runErr(t, "sync"); err == nil || !strings.Contains(err.Error(), "nothing was installed") {
+		t.Errorf("sync after a new build = %v, want a refusal", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "tool")); !os.IsNotExist(err) {
+		t.Error("sync should install nothing once the channel has moved on")
+	}
+
+	publish("build-1")
+	if out := run(t, "sync"); !strings.Contains(out, "1 installed") {
+		t.Errorf("sync output = %q, want tool installed", out)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool build-1" {
+		t.Errorf("shim = %q, want %q", got, "tool build-1")
+	}
+}
+
+func TestApply(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "other", Versions: []string{"1.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0"}},
+	)
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "other@1.0.0")
+
+	ops := filepath.Join(t.TempDir(), "ops.yaml")
+	os.WriteFile(ops, []byte("install:\n  - world\nuse:\n  - hello@^2\nuninstall:\n  - hello@1.0.0\n  - other\n"), 0644)
+
+	out := run(t, "apply", ops, "--dry-run")
+	for _, want := range []string{"+ install world@1.0.0", "+ install hello@2.0.0", "~ use hello@2.0.0", "- uninstall hello@1.0.0", "- uninstall other@1.0.0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("apply --dry-run output = %q, want %q", out, want)
+		}
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after --dry-run = %q, want nothing changed", got)
+	}
+
+	run(t, "apply", ops)
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim after apply = %q, want %q", got, "hello 2.0.0")
+	}
+	for _, gone := range []string{filepath.Join("hello", "1.0.0"), "other"} {
+		if _, err := os.Stat(filepath.Join(root, "installs", gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should be uninstalled", gone)
+		}
+	}
+	if out := run(t, "apply", ops); !strings.Contains(out, "Nothing to do") {
+		t.Errorf("second apply output = %q, want nothing to do", out)
+	}
+
+	os.WriteFile(ops, []byte("install:\n  - hello@2.0.0\nuninstall:\n  - hello@2.0.0\n"), 0644)
+	if err := runErr(t, "apply", ops); err == nil {
+		t.Error("apply should refuse to install and uninstall the same version")
+	}
+	os.WriteFile(ops, []byte("instal:\n  - hello\n"), 0644)
+	if err := runErr(t, "apply", ops); err == nil {
+		t.Error("apply should reject unknown keys")
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "other", Versions: []string{"1.0.0"}},
+	)
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0", "--use")
+	run(t, "install", "other@1.0.0")
+	shim := filepath.Join(root, "shims", "hello")
+
+	// Removing an inactive version leaves the active one alone
+	run(t, "uninstall", "hello@1.0.0")
+	if _, err := os.Stat(filepath.Join(root, "installs", "hello", "1.0.0")); !os.IsNotExist(err) {
+		t.Error("uninstall should remove the install directory")
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 2.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 2.0.0")
+	}
+
+	// Removing the active version removes its shims and active entry
+	out := run(t, "uninstall", "hello@2.0.0")
+	if !strings.Contains(out, "hello has no active version now") {
+		t.Errorf("uninstall output = %q, want a hint about the active version", out)
+	}
+	if _, err := os.Lstat(shim); !os.IsNotExist(err) {
+		t.Error("uninstalling the active version should remove its shim")
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "config", "active.yaml")); strings.Contains(string(data), "hello") {
+		t.Errorf("active.yaml = %q, want hello cleared", data)
+	}
+	if out := run(t, "list"); strings.Contains(out, "hello") || !strings.Contains(out, "other") {
+		t.Errorf("list after uninstall = %q, want only other", out)
+	}
+	if got := shimOutput(t, root, "other"); got != "other 1.0.0" {
+		t.Errorf("other shim = %q, want it untouched", got)
+	}
+
+	// --all removes every version
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+	if out := run(t, "uninstall", "hello", "--all"); strings.Count(out, "Uninstalled hello@") != 2 {
+		t.Errorf("uninstall --all output = %q, want both versions removed", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "hello")); !os.IsNotExist(err) {
+		t.Error("uninstall --all should remove the package directory")
+	}
+
+	for _, args := range [][]string{{"uninstall", "hello@1.0.0"}, {"uninstall", "hello"}, {"uninstall", "hello", "--all"}} {
+		if err := runErr(t, args...); err == nil {
+			t.Errorf("%v should fail when nothing is installed", args)
+		}
+	}
+}
+
+func TestUninstallAutoremove(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0"}},
+	)
+	reg.SetFile("/packages/kit.yaml", []byte("schema: 1\nname: kit\nmembers:\n  hello: 1.0.0\n  world: 1.0.0\n"))
+
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "kit")
+	dir := t.TempDir()
+	t.Chdir(dir)
+	run(t, "local", "hello@2.0.0")
+	run(t, "install")
+
+	// Uninstalling a group keeps its members, pointing out those nothing else needs
+	out := run(t, "uninstall", "kit")
+	if !strings.Contains(out, "Uninstalled group kit") || !strings.Contains(out, "No longer needed: world@1.0.0;") {
+		t.Errorf("uninstall kit output = %q, want world pointed out", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "world", "1.0.0")); err != nil {
+		t.Errorf("uninstall kit without --autoremove removed a member: %v", err)
+	}
+	if out := run(t, "why", "world"); !strings.Contains(out, "nothing needs it anymore") {
+		t.Errorf("why world = %q, want it reported as unneeded", out)
+	}
+
+	// A version pinned by a project is needed until the project's version file goes
+	os.Remove(filepath.Join(dir, ".nori-versions"))
+	out = run(t, "uninstall", "--autoremove")
+	for _, want := range []string{"Removed hello@2.0.0", "Removed world@1.0.0"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("uninstall --autoremove output = %q, want %q", out, want)
+		}
+	}
+	if strings.Contains(out, "hello@1.0.0") {
+		t.Errorf("uninstall --autoremove output = %q, want hello@1.0.0 kept as it was requested by name", out)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "world")); !os.IsNotExist(err) {
+		t.Error("uninstall --autoremove should remove world")
+	}
+	if out := run(t, "uninstall", "--autoremove"); !strings.Contains(out, "Nothing to remove") {
+		t.Errorf("second uninstall --autoremove output = %q, want nothing removed", out)
+	}
+
+	// --autoremove with a group removes its members right away
+	run(t, "install", "kit")
+	out = run(t, "uninstall", "kit", "--autoremove")
+	if !strings.Contains(out, "Removed world@1.0.0") || strings.Contains(out, "hello@1.0.0") {
+		t.Errorf("uninstall kit --autoremove output = %q, want only world removed", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("hello shim = %q, want hello 1.0.0 still active", got)
+	}
+
+	if err := runErr(t, "uninstall"); err == nil {
+		t.Error("uninstall without arguments or --autoremove should fail")
+	}
+}
+
+func TestDirenv(t *testing.T) {
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+		testsupport.Package{Name: "other", Versions: []string{"1.0.0"}},
+	)
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0", "--use")
+	run(t, "install", "other@1.0.0")
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := runErr(t, "direnv"); err == nil {
+		t.Error("direnv should fail without a version file")
+	}
+
+	os.WriteFile(".nori-versions", []byte("hello: 1.0.0\n"), 0644)
+	os.WriteFile(".envrc", []byte("export FOO=bar"), 0644)
+	run(t, "direnv")
+	run(t, "direnv")
+
+	data, _ := os.ReadFile(".envrc")
+	envrc := string(data)
+	if !strings.HasPrefix(envrc, "export FOO=bar\n") || strings.Count(envrc, "# nori:begin") != 1 {
+		t.Errorf(".envrc = %q, want the existing line kept and a single nori block", envrc)
+	}
+	if !strings.Contains(envrc, "watch_file '.nori-versions'") || !strings.Contains(envrc, `eval "$(nori direnv --export)"`) {
+		t.Errorf(".envrc = %q, want the version file watched and exports evaluated", envrc)
+	}
+
+	// Only the pinned version is exported; globally active tools stay on shims
+	out := run(t, "direnv", "--export")
+	binDir := filepath.Join(root, "installs", "hello", "1.0.0", testsupport.Platform(), "bin")
+	if !strings.HasPrefix(out, "export PATH='"+binDir+"'") || strings.Contains(out, "other") {
+		t.Errorf("direnv --export = %q, want only %s prepended to PATH", out, binDir)
+	}
+}
+
+func TestEnvDirenv(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("needs bash")
+	}
+
+	// A stand-in nori that shows how the extension calls it
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "nori"), []byte("#!/bin/sh\necho \"export CALLED='$*'\"\n"), 0755)
+	lib := filepath.Join(t.TempDir(), "nori.sh")
+	os.WriteFile(lib, []byte(run(t, "env", "--direnv")), 0644)
+
+	dir := filepath.Join(t.TempDir(), "project", "app")
+	os.MkdirAll(dir, 0755)
+	script := `watch_file() { printf '%s\n' "$@" >> watched; }
+. "$1"
+use_nori && echo "$CALLED"
+use_nori node@22 python && echo "$CALLED"`
+	cmd := exec.Command(bash, "-c", script, "bash", lib)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := cmd.Output()
+	if err != nil || string(out) != "direnv --export\nenv --shell bash node@22 python\n" {
+		t.Errorf("use nori = %q, %v, want the pinned exports, then the named packages", out, err)
+	}
+	watched, _ := os.ReadFile(filepath.Join(dir, "watched"))
+	for _, want := range []string{filepath.Join(dir, ".nori-versions"), filepath.Join(filepath.Dir(dir), ".tool-versions"), "/.nori-versions"} {
+		if !slices.Contains(strings.Split(string(watched), "\n"), want) {
+			t.Errorf("watched %q, want %s among them", watched, want)
+		}
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{
+		Name:      "hello",
+		Versions:  []string{"1.0.0", "2.0.0"},
+		Platforms: []string{"linux-amd64", "linux-arm64"},
+	})
+	run(t, "update")
+
+	t.Chdir(t.TempDir())
+	if err := runErr(t, "dockerfile"); err == nil {
+		t.Error("dockerfile should fail when nothing is pinned")
+	}
+	os.WriteFile(".nori-versions", []byte("hello: 1.0.0\n"), 0644)
+
+	out := run(t, "dockerfile", "--platform", "linux-arm64")
+	for _, want := range []string{
+		"# Generated by `nori dockerfile` for linux-arm64: hello@1.0.0",
+		"COPY nori /usr/local/bin/nori",
+		"ENV NORI_ROOT=/opt/nori",
+		"RUN nori update \\\n && nori install hello@sha256:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dockerfile output = %q, want it to contain %q", out, want)
+		}
+	}
+
+	out = run(t, "dockerfile", "hello@2.0.0", "--multi-stage", "--copy-from", "example/nori:1")
+	for _, want := range []string{
+		"FROM debian:bookworm-slim AS nori-tools",
+		"COPY --from=example/nori:1 /usr/local/bin/nori /usr/local/bin/nori",
+		"# COPY --from=nori-tools /opt/nori /opt/nori",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dockerfile --multi-stage output = %q, want it to contain %q", out, want)
+		}
+	}
+
+	if err := runErr(t, "dockerfile", "--platform", "darwin-arm64"); err == nil {
+		t.Error("dockerfile should fail for a platform without assets")
+	}
+}
+
+func TestRootWithoutHome(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user config directory only outlives $HOME through $XDG_CONFIG_HOME")
+	}
+	testsupport.IsolateRoot(t)
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+	t.Setenv("NORI_ROOT", "")
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// Without anywhere to put the root, commands fail rather than use the current directory
+	if err := runErr(t, "list"); err == nil || !strings.Contains(err.Error(), "set NORI_ROOT") {
+		t.Errorf("list without a home = %v, want an error naming NORI_ROOT", err)
+	}
+	if entries, _ := os.ReadDir(cwd); len(entries) != 0 {
+		t.Errorf("the current directory has %d entries, want none", len(entries))
+	}
+
+	// The user config directory stands in for the home directory
+	configDir, _ := filepath.EvalSymlinks(t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	run(t, "list")
+	if _, err := os.Stat(filepath.Join(configDir, "nori")); err != nil {
+		t.Errorf("no nori root in %s: %v", configDir, err)
+	}
+	t.Setenv("SHELL", "/bin/bash")
+	if out := run(t, "init"); !strings.Contains(out, "No home directory") || !strings.Contains(out, "add "+filepath.Join(configDir, "nori", "shims")+" to your PATH") {
+		t.Errorf("init without a home = %q, want the shims directory to add by hand", out)
+	}
+	if entries, _ := os.ReadDir(cwd); len(entries) != 0 {
+		t.Errorf("the current directory has %d entries, want none", len(entries))
+	}
+}
+
+func TestPathHint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	shimsDir := filepath.Join(root, "shims")
+	stderr := func(args ...string) string {
+		return testsupport.CaptureStderr(t, func() { runResult(t, args...) })
+	}
+
+	other := t.TempDir()
+	t.Setenv("PATH", other)
+	if out := stderr("list"); !strings.Contains(out, "Hint: "+shimsDir+" is not on PATH") {
+		t.Errorf("stderr = %q, want a hint that the shims directory is not on PATH", out)
+	}
+	if out := stderr("list"); strings.Contains(out, "Hint:") {
+		t.Errorf("stderr = %q, want the check cached for the same PATH", out)
+	}
+
+	// A tool earlier in PATH shadows the shim
+	os.WriteFile(filepath.Join(other, "hello"), []byte("#!/bin/sh\n"), 0755)
+	t.Setenv("PATH", other+string(os.PathListSeparator)+shimsDir)
+	if out := stderr("list"); !strings.Contains(out, "Hint: hello runs "+filepath.Join(other, "hello")+" instead of nori's shim") {
+		t.Errorf("stderr = %q, want a hint about the shadowed shim", out)
+	}
+	out, err := runResult(t, "doctor")
+	if exitCode(err)&cli.ExitMisconfigured == 0 || !strings.Contains(out, "hello runs") {
+		t.Errorf("doctor = %q, %v, want the shadowed shim reported as misconfigured", out, err)
+	}
+
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("no_path_check: true\n"), 0644)
+	t.Setenv("PATH", other+string(os.PathListSeparator)+shimsDir+string(os.PathListSeparator)+other)
+	if out := stderr("list"); strings.Contains(out, "Hint:") {
+		t.Errorf("stderr = %q, want no hint with no_path_check", out)
+	}
+}
+
+func TestChecksExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+	run(t, "install", "hello@1.0.0")
+	run(t, "install", "hello@2.0.0")
+
+	t.Setenv("PATH", filepath.Join(root, "shims")+string(os.PathListSeparator)+os.Getenv("PATH"))
+	for _, args := range [][]string{{"doctor", "--all"}, {"status", "--all"}, {"verify", "--all"}} {
+		if _, err := runResult(t, args...); err != nil {
+			t.Errorf("nori %s on a healthy root failed: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	// Damage an inactive version and remove the active one
+	bin := filepath.Join(root, "installs", "hello", "2.0.0", testsupport.Platform(), "bin", "hello")
+	os.WriteFile(bin, []byte("tampered"), 0755)
+	os.RemoveAll(filepath.Join(root, "installs", "hello", "1.0.0"))
+
+	out, err := runResult(t, "verify", "--all", "--json")
+	if got := exitCode(err); got != cli.ExitDamaged {
+		t.Errorf("verify --all exit code = %d, want %d", got, cli.ExitDamaged)
+	}
+	var report struct {
+		Checked  int
+		Findings []cli.Finding
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("verify --json output is not JSON: %v\n%s", err, out)
+	}
+	if report.Checked != 1 || len(report.Findings) != 1 || report.Findings[0].Category != "damaged" || report.Findings[0].Path != "bin/hello" {
+		t.Errorf("verify --json report = %+v, want one damaged bin/hello", report)
+	}
+
+	_, err = runResult(t, "status")
+	if got := exitCode(err); got != cli.ExitMissing {
+		t.Errorf("status exit code = %d, want %d", got, cli.ExitMissing)
+	}
+
+	t.Setenv("PATH", "/usr/bin:/bin")
+	_, err = runResult(t, "doctor", "--all")
+	want := cli.ExitDamaged | cli.ExitMisconfigured | cli.ExitMissing
+	if got := exitCode(err); got != want {
+		t.Errorf("doctor --all exit code = %d, want %d", got, want)
+	}
+}
+
+func TestManifestFromBrew(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/formula/jq.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"name": "jq", "desc": "JSON processor", "versions": {"stable": "1.7.1"},
+  "bottle": {"stable": {"files": {"x86_64_linux": {"cellar": ":any_skip_relocation",
+    "url": "https://example.com/jq.tar.gz", "sha256": "` + strings.Repeat("ab", 32) + `"}}}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("NORI_BREW_API_URL", server.URL)
+
+	out := run(t, "manifest", "from-brew", "jq")
+	if !strings.HasPrefix(out, "# Draft generated from Homebrew formula jq.") || !strings.Contains(out, "1.7.1/bin/jq") {
+		t.Errorf("manifest from-brew output = %q, want a draft manifest", out)
+	}
+
+	output := filepath.Join(t.TempDir(), "jq.yaml")
+	run(t, "manifest", "from-brew", "-o", output, "jq")
+	data, err := os.ReadFile(output)
+	if err != nil || !strings.Contains(string(data), "linux-amd64:") {
+		t.Errorf("manifest from-brew -o wrote %q, %v", data, err)
+	}
+
+	if err := runErr(t, "manifest", "from-brew", "missing"); err == nil {
+		t.Error("manifest from-brew should fail for an unknown formula")
+	}
+}
+
+func TestManifestFromScoop(t *testing.T) {
+	testsupport.IsolateRoot(t)
+	src := filepath.Join(t.TempDir(), "jq.json")
+	os.WriteFile(src, []byte(`{"version": "1.7.1", "description": "JSON processor", "bin": "jq.exe",
+  "architecture": {"64bit": {"url": "https://example.com/jq-win64.zip", "hash": "`+strings.Repeat("cd", 32)+`"}}}`), 0644)
+
+	out := run(t, "manifest", "from-scoop", "--name", "jq-cli", src)
+	if !strings.Contains(out, "name: jq-cli") || !strings.Contains(out, "windows-amd64:") || strings.Contains(out, "REVIEW") {
+		t.Errorf("manifest from-scoop output = %q, want a complete jq-cli manifest", out)
+	}
+}
+
+func TestInstallChannel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	publish := func(build string) string {
+		archive := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", build)})
+		reg.SetFile("/nightly/tool.tar.gz", archive)
+		reg.SetFile("/nightly/SHA256SUMS", []byte(strings.TrimPrefix(testsupport.Checksum(archive), "sha256:")+"  tool.tar.gz\n"))
+		return testsupport.Checksum(archive)
+	}
+	publish("build-1")
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+smoke_test:
+  command: [tool]
+  expect: "^tool build-"
+versions: {}
+channels:
+  nightly:
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: `+reg.URL+`/nightly/tool.tar.gz
+        checksums_url: `+reg.URL+`/nightly/SHA256SUMS
+`))
+
+	out := run(t, "install", "tool@nightly")
+	if !strings.Contains(out, "rolling channel") {
+		t.Errorf("install output = %q, want a non-determinism warning", out)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool build-1" {
+		t.Errorf("shim = %q, want %q", got, "tool build-1")
+	}
+
+	if out := run(t, "install", "tool@nightly"); !strings.Contains(out, "already installed") {
+		t.Errorf("reinstall of the same build = %q, want already installed", out)
+	}
+
+	build2 := publish("build-2")
+	if err := runErr(t, "install", "tool@"+testsupport.Checksum([]byte("build-1"))); err == nil {
+		t.Error("install of a digest that is not the current build should fail")
+	}
+	if out := run(t, "install", "tool@"+build2); !strings.Contains(out, "Updating tool@nightly") {
+		t.Errorf("install of a new build = %q, want an update", out)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool build-2" {
+		t.Errorf("shim after update = %q, want %q", got, "tool build-2")
+	}
+
+	// A new build that doesn't verify, or fails its smoke test, leaves the installed one
+	publish("build-3")
+	reg.SetFile("/nightly/tool.tar.gz", []byte("corrupted"))
+	if err := runErr(t, "install", "tool@nightly"); err == nil {
+		t.Error("install of a build that doesn't match its checksum should fail")
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool build-2" {
+		t.Errorf("shim after a failed download = %q, want %q", got, "tool build-2")
+	}
+	publish("broken")
+	err := runErr(t, "install", "tool@nightly")
+	if err == nil || !strings.Contains(err.Error(), "the build it replaced was restored") {
+		t.Errorf("install of a build that fails its smoke test = %v, want the old build restored", err)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool build-2" {
+		t.Errorf("shim after a failed smoke test = %q, want %q", got, "tool build-2")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "tmp")); len(entries) != 0 {
+		t.Errorf("tmp holds %d entries after the update, want none", len(entries))
+	}
+
+	if out := run(t, "info", "tool"); !strings.Contains(out, "Channels (rolling builds, not reproducible):\n  nightly") {
+		t.Errorf("info output = %q, want the nightly channel", out)
+	}
+}
+
+func TestInstallByDigest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "update")
+
+	m, err := registry.New(os.Getenv("NORI_REGISTRY_URL"), platform.NewPaths(root)).CachedPackage("hello")
+	if err != nil {
+		t.Fatalf("CachedPackage() failed: %v", err)
+	}
+	digest := m.Versions["1.0.0"].Platforms[testsupport.Platform()].Checksum
+
+	out := run(t, "install", "hello@"+digest)
+	if !strings.Contains(out, "is hello@1.0.0") {
+		t.Errorf("install by digest output = %q, want the resolved version", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "hello 1.0.0")
+	}
+
+	run(t, "install", "--use", "hello@2.0.0")
+	run(t, "use", "hello@"+digest)
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim after use by digest = %q, want %q", got, "hello 1.0.0")
+	}
+
+	if err := runErr(t, "install", "hello@sha256:"+strings.Repeat("0", 64)); err == nil {
+		t.Error("install of an unknown digest should fail")
+	}
+	if err := runErr(t, "install", "hello@sha256:1234"); err == nil {
+		t.Error("install of a malformed digest should fail")
+	}
+}
+
+func TestPackageSettings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "1.2.0", "2.0.0"}},
+		testsupport.Package{Name: "world", Versions: []string{"1.0.0", "2.0.0"}},
+	)
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte(`auto_use: true
+packages:
+  hello:
+    constraint: ^1
+    env:
+      HELLO_GREETING: hi
+  world:
+    auto_use: false
+`), 0644)
+
+	// The constraint bounds what an install without a version picks, and what counts as behind
+	if out := run(t, "install", "hello"); !strings.Contains(out, "Resolved hello to the newest version matching ^1, 1.2.0") {
+		t.Errorf("install = %q, want 1.2.0 picked by the constraint", out)
+	}
+	if out := run(t, "install", "hello@2.0.0"); !strings.Contains(out, "Installed hello@2.0.0") {
+		t.Errorf("install = %q, want a named version installed regardless of the constraint", out)
+	}
+	run(t, "use", "hello@1.2.0")
+	if out := run(t, "outdated"); !strings.Contains(lineWith(out, "hello"), "up to date") {
+		t.Errorf("outdated = %q, want hello up to date within its constraint", out)
+	}
+
+	// A package's auto_use overrides the global one
+	run(t, "install", "world@1.0.0")
+	run(t, "install", "world@2.0.0")
+	if out := run(t, "current", "world"); !strings.Contains(out, "1.0.0") {
+		t.Errorf("current = %q, want world left at 1.0.0", out)
+	}
+
+	// env reaches the binary through its shim
+	bin := filepath.Join(root, "installs", "hello", "1.2.0", testsupport.Platform(), "bin", "hello")
+	os.WriteFile(bin, []byte("#!/bin/sh\necho \"$HELLO_GREETING\"\n"), 0755)
+	if got := shimOutput(t, root, "hello"); got != "hi" {
+		t.Errorf("shim = %q, want the configured env", got)
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	old := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	os.WriteFile(filepath.Join(old, "config", "config.yaml"), []byte("tmp_dir: "+old+"/tmp\n"), 0644)
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(old, moved); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NORI_ROOT", moved)
+	if out := testsupport.CaptureStderr(t, func() { run(t, "list") }); !strings.Contains(out, "nori relocate "+old+" "+moved) {
+		t.Errorf("stderr = %q, want a hint to relocate", out)
+	}
+
+	if out := run(t, "relocate", "--dry-run", old, moved); !strings.Contains(out, "Would rewrite settings") {
+		t.Errorf("relocate --dry-run = %q, want the settings listed", out)
+	}
+	if out := run(t, "relocate", old, moved); !strings.Contains(out, "Relocated 1 path(s)") {
+		t.Errorf("relocate = %q, want the settings rewritten", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(moved, "config", "config.yaml")); string(data) != "tmp_dir: "+moved+"/tmp\n" {
+		t.Errorf("config.yaml = %q, want tmp_dir under the new root", data)
+	}
+	if out := testsupport.CaptureStderr(t, func() { run(t, "list") }); strings.Contains(out, "relocate") {
+		t.Errorf("stderr = %q, want no hint once relocated", out)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	// The nori run below doesn't trust the fixture registry, so it installs from the cache
+	run(t, "prefetch", "hello@2.0.0")
+	nori := func(args ...string) (string, string, error) {
+		var stdout, stderr strings.Builder
+		cmd := exec.Command(os.Args[0], append([]string{"exec"}, args...)...)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	stdout, stderr, err := nori("hello@2.0.0", "--", "hello")
+	if err != nil || stdout != "hello 2.0.0\n" {
+		t.Fatalf("exec = %q, %v, want only the command's output\n%s", stdout, err, stderr)
+	}
+	if !strings.Contains(stderr, "hello@2.0.0: Installed") {
+		t.Errorf("stderr = %q, want the install reported", stderr)
+	}
+	if out := run(t, "current", "hello"); !strings.Contains(out, "1.0.0") {
+		t.Errorf("current = %q, want the active version unchanged", out)
+	}
+	if got := shimOutput(t, root, "hello"); got != "hello 1.0.0" {
+		t.Errorf("shim = %q, want the active 1.0.0", got)
+	}
+
+	// An installed version runs straight away, and the command's exit status is kept
+	stdout, stderr, err = nori("hello@2", "sh", "-c", "hello; exit 3")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || stdout != "hello 2.0.0\n" || stderr != "" {
+		t.Errorf("exec = %q, %q, %v, want the installed 2.0.0 run and exit status 3", stdout, stderr, err)
+	}
+
+	if _, stderr, err := nori("hello@2.0.0", "--"); err == nil || !strings.Contains(stderr, "usage: nori exec") {
+		t.Errorf("exec without a command = %q, %v, want usage", stderr, err)
+	}
+}
+
+func TestShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0"}})
+	archive := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", "2.0.0")})
+	reg.SetFile("/tool.tar.gz", archive)
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+env:
+  TOOL_HOME: "{install}"
+versions:
+  "2.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: `+reg.URL+`/tool.tar.gz
+        checksum: `+testsupport.Checksum(archive)+`
+`))
+	run(t, "install", "hello@1.0.0")
+	// The nori shell below doesn't trust the fixture registry, so it installs from the cache
+	run(t, "prefetch", "tool")
+
+	cmd := exec.Command(os.Args[0], "shell", "hello", "tool")
+	cmd.Env = append(os.Environ(), "SHELL=sh", "NORI_SHELL=outer@1.0.0")
+	cmd.Stdin = strings.NewReader("hello; tool; echo \"$TOOL_HOME|$NORI_SHELL\"\n")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("shell failed: %v\n%s", err, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 || lines[0] != "hello 1.0.0" || lines[1] != "tool 2.0.0" {
+		t.Fatalf("shell output = %q, want both packages on PATH", out)
+	}
+	home, nested, _ := strings.Cut(lines[2], "|")
+	if !strings.HasSuffix(home, filepath.Join("installs", "tool", "2.0.0", testsupport.Platform())) {
+		t.Errorf("TOOL_HOME = %q, want the install directory from the manifest's env", home)
+	}
+	if nested != "outer@1.0.0 hello@1.0.0 tool@2.0.0" {
+		t.Errorf("NORI_SHELL = %q, want the outer shell's packages and these", nested)
+	}
+	if !strings.Contains(stderr.String(), "Starting sh with hello@1.0.0, tool@2.0.0") {
+		t.Errorf("stderr = %q, want the subshell announced", stderr.String())
+	}
+	if out := run(t, "current", "tool"); strings.Contains(out, "2.0.0") {
+		t.Errorf("current = %q, want tool left inactive", out)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := runErr(t, "env"); err == nil || !strings.Contains(err.Error(), "no versions are pinned") {
+		t.Errorf("env without pins = %v, want an error", err)
+	}
+
+	os.WriteFile(".nori-versions", []byte("hello: 2.0.0\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("packages:\n  hello:\n    env:\n      GREETING: it's me\n"), 0644)
+	var out string
+	stderr := testsupport.CaptureStderr(t, func() { out = run(t, "env", "--shell", "bash") })
+	if !strings.Contains(stderr, "hello@2.0.0: Installed") {
+		t.Errorf("stderr = %q, want the pinned version installed", stderr)
+	}
+	script := filepath.Join(dir, "env.sh")
+	os.WriteFile(script, []byte(out), 0644)
+	got, err := exec.Command("sh", "-c", `. ./env.sh && hello && echo "$GREETING"`).Output()
+	if err != nil || string(got) != "hello 2.0.0\nit's me\n" {
+		t.Errorf("sourcing %q = %q, %v, want the pinned version and its env", out, got, err)
+	}
+	if out := run(t, "current", "hello"); !strings.Contains(out, "2.0.0") {
+		t.Errorf("current = %q, want the pin in effect", out)
+	}
+
+	if out := run(t, "env", "--shell", "fish", "hello@1.0.0"); !strings.HasPrefix(out, "set -gx PATH '") || !strings.Contains(out, `set -gx GREETING 'it\'s me'`) {
+		t.Errorf("env --shell fish = %q", out)
+	}
+	if out := run(t, "env", "--shell", "pwsh", "hello@1.0.0"); !strings.Contains(out, "+ [IO.Path]::PathSeparator + $env:PATH") || !strings.Contains(out, `$env:GREETING = 'it''s me'`) {
+		t.Errorf("env --shell pwsh = %q", out)
+	}
+	if err := runErr(t, "env", "--shell", "tcsh"); err == nil {
+		t.Error("env --shell tcsh should fail")
+	}
+}
+
+func TestManifestEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t)
+	archive := testsupport.TarGz(map[string]string{"tool/bin/tool": "#!/bin/sh\necho \"$TOOL_HOME\"\n"})
+	reg.SetFile("/tool.tar.gz", archive)
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+env:
+  TOOL_HOME: "{install}"
+versions:
+  "2.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: `+reg.URL+`/tool.tar.gz
+        checksum: `+testsupport.Checksum(archive)+`
+`))
+	run(t, "install", "tool@2.0.0")
+	home := filepath.Join(root, "installs", "tool", "2.0.0", testsupport.Platform())
+
+	if got := shimOutput(t, root, "tool"); got != home {
+		t.Errorf("shim output = %q, want TOOL_HOME set to %s", got, home)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".nori-versions"), []byte("tool: 2.0.0\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "nori.yaml"), []byte("tasks:\n  home: tool\n"), 0644)
+	t.Chdir(dir)
+	if out, err := exec.Command(os.Args[0], "run", "home").Output(); err != nil || strings.TrimSpace(string(out)) != home {
+		t.Errorf("run home = %q, %v, want TOOL_HOME set to %s", out, err, home)
+	}
+	if out := run(t, "direnv", "--export"); !strings.Contains(out, "export TOOL_HOME='"+home+"'") {
+		t.Errorf("direnv --export = %q, want TOOL_HOME exported", out)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	testsupport.IsolateRoot(t)
+	testsupport.NewRegistry(t, testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}})
+	run(t, "install", "hello@1.0.0")
+	// The nori run below doesn't trust the fixture registry, so it installs from the cache
+	run(t, "prefetch", "hello@2.0.0")
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".nori-versions"), []byte("hello: 2.0.0\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "nori.yaml"), []byte(`tasks:
+  greet: hello
+  say: echo said
+  where: pwd
+  fail: |
+    hello
+    exit "$1"
+`), 0644)
+	sub := filepath.Join(dir, "src")
+	os.MkdirAll(sub, 0755)
+	t.Chdir(sub)
+	nori := func(args ...string) (string, string, error) {
+		var stdout, stderr strings.Builder
+		cmd := exec.Command(os.Args[0], append([]string{"run"}, args...)...)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	stdout, stderr, err := nori("greet")
+	if err != nil || stdout != "hello 2.0.0\n" {
+		t.Fatalf("run greet = %q, %v, want the pinned version run\n%s", stdout, err, stderr)
+	}
+	if !strings.Contains(stderr, "hello@2.0.0: Installed") {
+		t.Errorf("stderr = %q, want the pinned version installed", stderr)
+	}
+	if out := run(t, "current", "hello"); !strings.Contains(out, "2.0.0") {
+		t.Errorf("current = %q, want the pin in effect", out)
+	}
+
+	if stdout, _, err := nori("say", "--verbose", "a b"); err != nil || stdout != "said --verbose a b\n" {
+		t.Errorf("run say = %q, %v, want the extra arguments appended", stdout, err)
+	}
+	if stdout, _, err := nori("where"); err != nil || strings.TrimSpace(stdout) != dir {
+		t.Errorf("run where = %q, %v, want the task run in %s", stdout, err, dir)
+	}
+	stdout, _, err = nori("fail", "4")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 4 || stdout != "hello 2.0.0\n" {
+		t.Errorf("run fail = %q, %v, want exit status 4", stdout, err)
+	}
+
+	if out := run(t, "run"); lineWith(out, "fail") != "fail\thello" || lineWith(out, "greet") != "greet\thello" {
+		t.Errorf("run = %q, want the tasks listed", out)
+	}
+	if err := runErr(t, "run", "deploy"); err == nil || !strings.Contains(err.Error(), `no task "deploy"`) {
+		t.Errorf("run deploy = %v, want an unknown task error", err)
+	}
+}
+
+func TestInstallBaselineBuild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t)
+	builds := make(map[string]string)
+	for _, build := range []string{"optimized", "baseline"} {
+		archive := testsupport.TarGz(map[string]string{"tool/bin/tool": testsupport.BinScript("tool", build)})
+		reg.SetFile("/"+build+".tar.gz", archive)
+		builds[build] = testsupport.Checksum(archive)
+	}
+	publish := func(name, baseline string) {
+		reg.SetFile("/packages/"+name+".yaml", []byte(`schema: 1
+name: `+name+`
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: `+reg.URL+`/optimized.tar.gz
+        checksum: `+builds["optimized"]+`
+        cpu_features: [avx10.2]
+`+baseline))
+	}
+	publish("fast", "")
+	publish("tool", `        baseline:
+          type: tar
+          url: `+reg.URL+`/baseline.tar.gz
+          checksum: `+builds["baseline"]+`
+`)
+
+	if err := runErr(t, "install", "fast@1.0.0"); err == nil || !strings.Contains(err.Error(), "lacks avx10.2") {
+		t.Errorf("install = %v, want the missing CPU feature named", err)
+	}
+
+	stderr := testsupport.CaptureStderr(t, func() { run(t, "install", "tool@1.0.0") })
+	if !strings.Contains(stderr, "installing the baseline build of tool@1.0.0") {
+		t.Errorf("stderr = %q, want a note about the baseline build", stderr)
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool baseline" {
+		t.Errorf("shim = %q, want the baseline build", got)
+	}
+}
+
+// signedProvenance returns a DSSE envelope of a SLSA provenance statement about the
+// artifact with checksum, signed by key
+func signedProvenance(t *testing.T, key ed25519.PrivateKey, checksum string) []byte {
+	t.Helper()
+	statement, _ := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"subject":       []map[string]any{{"name": "archive", "digest": map[string]string{"sha256": strings.TrimPrefix(checksum, "sha256:")}}},
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate":     map[string]any{"runDetails": map[string]any{"builder": map[string]string{"id": "https://ci.example.com/release"}}},
+	})
+	payloadType := "application/vnd.in-toto+json"
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(statement), statement)
+	envelope, _ := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(pae)))}},
+	})
+	return envelope
+}
+
+func TestInstallProvenance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t,
+		testsupport.Package{Name: "hello", Versions: []string{"1.0.0", "2.0.0"}},
+	)
+	_, trusted, _ := ed25519.GenerateKey(nil)
+	_, other, _ := ed25519.GenerateKey(nil)
+
+	// 1.0.0 is attested by the trusted key, 2.0.0 by another
+	resp, err := reg.Client().Get(reg.URL + "/packages/hello.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for version, key := range map[string]ed25519.PrivateKey{"1.0.0": trusted, "2.0.0": other} {
+		resp, err := reg.Client().Get(reg.URL + "/assets/hello-" + version + ".tar.gz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		checksum := testsupport.Checksum(archive)
+		attestation := "/attestations/hello-" + version + ".intoto.jsonl"
+		reg.SetFile(attestation, signedProvenance(t, key, checksum))
+		manifest = bytes.Replace(manifest, []byte("checksum: "+checksum+"\n"), []byte("checksum: "+checksum+"\n        provenance: "+reg.URL+attestation+"\n"), 1)
+	}
+	reg.SetFile("/packages/hello.yaml", manifest)
+	run(t, "update")
+
+	// Without a provenance setting nothing is checked
+	run(t, "install", "hello@2.0.0")
+	if reg.Requests("/attestations/hello-2.0.0.intoto.jsonl") != 0 {
+		t.Error("an attestation was fetched without a provenance setting")
+	}
+	run(t, "uninstall", "hello@2.0.0")
+
+	der, _ := x509.MarshalPKIXPublicKey(trusted.Public())
+	keyPath := filepath.Join(t.TempDir(), "release.pub")
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+	settings := func(mode string) {
+		os.MkdirAll(filepath.Join(root, "config"), 0755)
+		os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("provenance:\n  mode: "+mode+"\n  keys:\n    - "+keyPath+"\n"), 0644)
+	}
+
+	settings("enforce")
+	if out := run(t, "install", "hello@1.0.0"); !strings.Contains(out, "Verified provenance: built by https://ci.example.com/release") {
+		t.Errorf("install output = %q, want the verified builder", out)
+	}
+	// The archive of 2.0.0 is cached, and checked all the same
+	err = runErr(t, "install", "hello@2.0.0")
+	if err == nil || !strings.Contains(err.Error(), "provenance of hello@2.0.0 from registry default") || !strings.Contains(err.Error(), "not signed by a trusted key") {
+		t.Fatalf("install with enforced provenance = %v, want it refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "hello", "2.0.0")); !os.IsNotExist(err) {
+		t.Errorf("hello@2.0.0 was installed despite its provenance: %v", err)
+	}
+
+	settings("warn")
+	stderr := testsupport.CaptureStderr(t, func() { run(t, "install", "hello@2.0.0") })
+	if !strings.Contains(stderr, "Warning: provenance of hello@2.0.0") {
+		t.Errorf("stderr = %q, want a provenance warning", stderr)
+	}
+
+	settings("strict")
+	if err := runErr(t, "config", "import", filepath.Join(root, "config", "config.yaml")); err == nil || !strings.Contains(err.Error(), "provenance setting") {
+		t.Errorf("config import of an unknown provenance mode = %v", err)
+	}
+}
+
+func TestInstallGitHubRelease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+	t.Setenv("NORI_GITHUB_API_URL", reg.URL+"/api")
+
+	archive := testsupport.TarGz(map[string]string{"tool-1.0.0/bin/tool": testsupport.BinScript("tool", "1.0.0")})
+	reg.SetFile("/download/tool-1.0.0-"+testsupport.Platform()+".tar.gz", archive)
+	reg.SetFile("/api/repos/example/tool/releases/tags/v1.0.0", []byte(`{"assets": [
+		{"name": "tool-1.0.0-`+testsupport.Platform()+`.tar.gz", "browser_download_url": "`+reg.URL+`/download/tool-1.0.0-`+testsupport.Platform()+`.tar.gz"},
+		{"name": "tool-1.0.0-`+testsupport.Platform()+`.tar.gz.sig", "browser_download_url": "`+reg.URL+`/download/tool.sig"}
+	]}`))
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        github: example/tool
+        asset_pattern: tool-{version}-*.tar.gz
+        checksum: `+testsupport.Checksum(archive)+`
+  "2.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        github: example/tool
+        tag: release-{version}
+        asset_pattern: tool-{version}-*.tar.gz
+        checksum: sha256:`+strings.Repeat("0", 64)+`
+`))
+	reg.SetFile("/api/repos/example/tool/releases/tags/release-2.0.0", []byte(`{"assets": [{"name": "SHA256SUMS", "browser_download_url": "`+reg.URL+`/download/SHA256SUMS"}]}`))
+	run(t, "update")
+
+	// The pattern has to pick out an asset of the release
+	if err := runErr(t, "install", "tool@2.0.0"); err == nil || !strings.Contains(err.Error(), "release release-2.0.0 of example/tool has no asset matching tool-2.0.0-*.tar.gz") {
+		t.Errorf("install of a release without the asset = %v", err)
+	}
+
+	run(t, "install", "tool@1.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "tool 1.0.0")
+	}
+	if reg.Requests("/api/repos/example/tool/releases/tags/v1.0.0") == 0 {
+		t.Error("the release was not looked up")
+	}
+
+	// A lock records where the release asset is downloaded from
+	t.Chdir(t.TempDir())
+	os.WriteFile(".nori-versions", []byte("tool: 1.0.0\n"), 0644)
+	run(t, "lock")
+	if data, _ := os.ReadFile("nori.lock"); !strings.Contains(string(data), "url: "+reg.URL+"/download/tool-1.0.0-") {
+		t.Errorf("nori.lock = %q, want the release asset's URL", data)
+	}
+
+	// Once the archive is cached, the release needn't be reachable
+	run(t, "uninstall", "tool@1.0.0")
+	t.Setenv("NORI_GITHUB_API_URL", "https://127.0.0.1:1")
+	run(t, "install", "tool@1.0.0")
+}
+
+func TestInstallMirrorFailover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	// The primary host refuses connections, so every download comes from the mirror
+	archive := testsupport.TarGz(map[string]string{"tool-1.0.0/bin/tool": testsupport.BinScript("tool", "1.0.0")})
+	reg.SetFile("/mirror/tool-1.0.0.tar.gz", archive)
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: tar
+        url: https://127.0.0.1:1/tool-1.0.0.tar.gz
+        mirrors:
+          - `+reg.URL+`/mirror/tool-1.0.0.tar.gz
+        checksum: `+testsupport.Checksum(archive)+`
+`))
+	run(t, "update")
+	run(t, "install", "tool@1.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "tool 1.0.0")
+	}
+
+	// Repair fails over too once the archive is no longer cached
+	if err := os.RemoveAll(filepath.Join(root, "cache", "sha256")); err != nil {
+		t.Fatal(err)
+	}
+	downloads := reg.Requests("/mirror/tool-1.0.0.tar.gz")
+	bin := filepath.Join(root, "installs", "tool", "1.0.0", testsupport.Platform(), "bin", "tool")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho broken\n"), 0755); err != nil {
+		t.Fatalf("failed to corrupt binary: %v", err)
+	}
+	if out := run(t, "verify", "tool@1.0.0", "--repair"); !strings.Contains(out, "repaired 1 file(s)") {
+		t.Errorf("verify --repair output = %q, want one repaired file", out)
+	}
+	if reg.Requests("/mirror/tool-1.0.0.tar.gz") == downloads {
+		t.Error("repair did not download from the mirror")
+	}
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim after repair = %q, want %q", got, "tool 1.0.0")
+	}
+}
+
+func TestInstallDeb(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("deb assets are for linux platforms")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	// Laid out from the root of the filesystem, so usr becomes the install root
+	deb := testsupport.Deb(map[string]string{
+		"usr/bin/tool":                 testsupport.BinScript("tool", "1.0.0"),
+		"usr/share/doc/tool/copyright": "MIT",
+	})
+	reg.SetFile("/assets/tool_1.0.0_amd64.deb", deb)
+	reg.SetFile("/packages/tool.yaml", []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      `+testsupport.Platform()+`:
+        type: deb
+        url: `+reg.URL+`/assets/tool_1.0.0_amd64.deb
+        checksum: `+testsupport.Checksum(deb)+`
+`))
+	run(t, "update")
+	run(t, "install", "tool@1.0.0")
+	if got := shimOutput(t, root, "tool"); got != "tool 1.0.0" {
+		t.Errorf("shim = %q, want %q", got, "tool 1.0.0")
+	}
+	installPath := filepath.Join(root, "installs", "tool", "1.0.0", testsupport.Platform())
+	if _, err := os.Stat(filepath.Join(installPath, "share", "doc", "tool", "copyright")); err != nil {
+		t.Errorf("the rest of the payload was not installed: %v", err)
+	}
+}
+
+func TestInstallInnerArchive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture binaries are shell scripts")
+	}
+
+	root := testsupport.IsolateRoot(t)
+	reg := testsupport.NewRegistry(t, testsupport.Package{Name: "tool", Versions: []string{"1.0.0"}})
+
+	// 1.0.0's zip holds nothing but the tarball; 2.0.0's has a signature beside it. Only
+	// 2.0.0 names its inner archive.
+	tarball := func(version string) string {
+		return string(testsupport.TarGz(map[string]string{"tool-" + version + "/bin/tool": testsupport.BinScript("tool", version)}))
+	}
+	lone := testsupport.Zip(map[string]string{"tool-1.0.0.tar.gz": tarball("1.0.0")})
+	signed := testsupport.Zip(map[string]string{
+		"dist/tool-2.0.0.tar.gz":     tarball("2.0.0"),
+		"dist/tool-2.0.0.tar.gz.sig": "signature",
+	})
+	reg.SetFile("/assets/tool-1.0.0.zip", lone)
+	reg.SetFile("/assets/tool-2.0.0.zip", signed)
+	manifest := func(inner string) []byte {
+		return []byte(`schema: 1
+name: tool
+bins:
+  - bin/tool
+versions:
+  "1.0.0":
+    platforms:
+      ` + testsupport.Platform() + `:
+        type: zip
+        url: ` + reg.URL + `/assets/tool-1.0.0.zip
+        checksum: ` + testsupport.Checksum(lone) + `
+  "2.0.0":
+    platforms:
+      ` + testsupport.Platform() + `:
+        type: zip
+        url: ` + reg.URL + `/assets/tool-2.0.0.zip
+        checksum: ` + testsupport.Checksum(signed) + `
+` + inner)
+	}
+	reg.SetFile("/packages/tool.yaml", manifest(""))
+	run(t, "update")
+
+	// Without inner, even a lone tarball is installed as it is
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		if err := runErr(t, "install", "tool@"+version); err == nil || !strings.Contains(err.Error(), "bin/tool") {
+			t.Errorf("install of tool@%s without inner = %v, want the bin missing", version, err)
+		}
+	}
+	reg.SetFile("/packages/tool.yaml", manifest("        inner: dist/tool-{version}.tar.gz\n"))
+	run(t, "update")
+	run(t, "install", "tool@2.0.0")
+	installPath := filepath.Join(root, "installs", "tool", "2.0.0", testsupport.Platform())
+	if _, err := os.Stat(filepath.Join(installPath, "dist")); !os.IsNotExist(err) {
+		t.Errorf("the outer archive's files were installed: %v", err)
+	}
+
+	// Repair unwraps the inner archive again
+	bin := filepath.Join(installPath, "bin", "tool")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho broken\n"), 0755); err != nil {
+		t.Fatalf("failed to corrupt binary: %v", err)
+	}
+	if out := run(t, "verify", "tool@2.0.0", "--repair"); !strings.Contains(out, "repaired 1 file(s)") {
+		t.Errorf("verify --repair output = %q, want one repaired file", out)
+	}
+	if out, err := exec.Command(bin).Output(); err != nil || string(out) != "tool 2.0.0\n" {
+		t.Errorf("bin after repair printed %q, %v", out, err)
+	}
+}
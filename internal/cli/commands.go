@@ -3,21 +3,30 @@ package cli
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/events"
 	"github.com/chirag-bruno/nori/internal/extract"
 	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/fsutil"
 	"github.com/chirag-bruno/nori/internal/install"
 	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/migrate"
 	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/receipt"
 	"github.com/chirag-bruno/nori/internal/registry"
 	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
 	urfavecli "github.com/urfave/cli/v3"
 )
 
@@ -27,39 +36,64 @@ var (
 
 // InitCommand handles the `nori init` command
 func InitCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.Bool("system") {
+		return initSystem()
+	}
+	if c.Bool("project") {
+		return initProject(ctx, c)
+	}
+
+	paths, err := platform.DefaultPaths()
+	if err != nil {
+		return err
+	}
 	shell := detectShell()
-	shimsDir := platform.ShimsDir()
+	shimsDir := paths.ShimsDir()
+	layer := platform.DetectPOSIXLayer()
 
 	// Ensure shims directory exists
 	if err := os.MkdirAll(shimsDir, 0755); err != nil {
 		return fmt.Errorf("failed to create shims directory: %w", err)
 	}
 
+	// Git Bash/MSYS and Cygwin read profiles from their own $HOME and need
+	// POSIX-style PATH entries (/c/Users/...) instead of C:\ paths
+	home, _ := os.UserHomeDir()
+	shimsEntry := "$HOME/.nori/shims"
+	if layer != "" {
+		if h := os.Getenv("HOME"); h != "" {
+			home = h
+		}
+		shimsEntry = platform.POSIXPath(shimsDir, layer)
+	}
+
+	// Services and containers may have no home, and so no profile to edit; a relative
+	// path would write one into the current directory
+	if !filepath.IsAbs(home) {
+		fmt.Printf("No home directory to find a shell profile in. Please manually add %s to your PATH.\n", shimsDir)
+		return nil
+	}
+
 	var profilePath string
 	var pathLine string
 	var added bool
-	var err error
 
 	switch shell {
 	case "zsh":
-		home, _ := os.UserHomeDir()
 		profilePath = filepath.Join(home, ".zshrc")
-		pathLine = `export PATH="$HOME/.nori/shims:$PATH"`
+		pathLine = fmt.Sprintf(`export PATH="%s:$PATH"`, shimsEntry)
 		added, err = addToProfile(profilePath, pathLine)
 	case "bash":
-		home, _ := os.UserHomeDir()
 		profilePath = filepath.Join(home, ".bashrc")
-		pathLine = `export PATH="$HOME/.nori/shims:$PATH"`
+		pathLine = fmt.Sprintf(`export PATH="%s:$PATH"`, shimsEntry)
 		added, err = addToProfile(profilePath, pathLine)
 	case "fish":
-		home, _ := os.UserHomeDir()
 		profilePath = filepath.Join(home, ".config", "fish", "config.fish")
-		pathLine = `set -gx PATH $HOME/.nori/shims $PATH`
+		pathLine = fmt.Sprintf(`set -gx PATH %s $PATH`, shimsEntry)
 		added, err = addToProfile(profilePath, pathLine)
 	case "powershell":
 		profilePath = os.Getenv("PROFILE")
 		if profilePath == "" {
-			home, _ := os.UserHomeDir()
 			profilePath = filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
 		}
 		pathLine = `$env:PATH = "$HOME\.nori\shims;" + $env:PATH`
@@ -75,7 +109,11 @@ func InitCommand(ctx context.Context, c *urfavecli.Command) error {
 
 	if added {
 		fmt.Printf("✓ Added nori shims to PATH in %s\n", profilePath)
-		fmt.Printf("\nPlease run: source %s\n", profilePath)
+		sourcePath := profilePath
+		if layer != "" {
+			sourcePath = platform.POSIXPath(profilePath, layer)
+		}
+		fmt.Printf("\nPlease run: source %s\n", sourcePath)
 		if shell == "powershell" {
 			fmt.Printf("Or restart your PowerShell session.\n")
 		}
@@ -88,6 +126,158 @@ func InitCommand(ctx context.Context, c *urfavecli.Command) error {
 	return nil
 }
 
+// initSystem sets up the machine-wide shims directory on Windows, relaunching
+// nori elevated when the current process cannot write to it
+func initSystem() error {
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("--system is only supported on Windows")
+	}
+
+	shimsDir := platform.SystemShimsDir()
+	if !canWriteDir(shimsDir) {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate nori executable: %w", err)
+		}
+
+		fmt.Printf("Administrator rights are required to write to %s; requesting elevation...\n", shimsDir)
+		script := fmt.Sprintf(`$p = Start-Process -FilePath %s -ArgumentList 'init','--system' -Verb RunAs -Wait -PassThru; exit $p.ExitCode`, psQuote(exe))
+		cmd := exec.Command("powershell", "-NoProfile", "-Command", script)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("elevated init failed: %w", err)
+		}
+		return nil
+	}
+
+	// Append to the machine PATH so services and every user see the shims
+	script := fmt.Sprintf(`$d = %s; $p = [Environment]::GetEnvironmentVariable('Path', 'Machine'); `+
+		`if (($p -split ';') -notcontains $d) { [Environment]::SetEnvironmentVariable('Path', $p.TrimEnd(';') + ';' + $d, 'Machine') }`,
+		psQuote(shimsDir))
+	if out, err := exec.Command("powershell", "-NoProfile", "-Command", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update machine PATH: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	paths, err := platform.DefaultPaths()
+	if err != nil {
+		return err
+	}
+	cfg := config.New(paths)
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return err
+	}
+	settings.SystemShims = true
+	if err := cfg.UpdateSettings(settings); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Added %s to the machine PATH\n", shimsDir)
+	fmt.Printf("\nShims will be created there from now on. Run `nori use <package>@<version>` to move existing shims.\n")
+
+	return nil
+}
+
+// initProject pins the tools that files like .nvmrc, go.mod and rust-toolchain.toml
+// already ask for in the current directory's nori.yaml, creating it if needed. Packages
+// already pinned by the directory's version files are kept. With --lock, the pinned
+// versions are then locked.
+func initProject(ctx context.Context, c *urfavecli.Command) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	path := filepath.Join(dir, project.ProjectFileName)
+	f := &project.File{Path: path, Versions: make(map[string]string)}
+	files, err := project.Find(dir)
+	if err != nil {
+		return err
+	}
+	pinned := make(map[string]*project.File) // the file pinning each package in this directory
+	for _, file := range files {
+		if filepath.Dir(file.Path) != dir {
+			continue
+		}
+		if file.Path == path {
+			f = file
+		}
+		for pkg := range file.Versions {
+			if _, ok := pinned[pkg]; !ok {
+				pinned[pkg] = file
+			}
+		}
+	}
+
+	detected, err := project.Detect(dir)
+	if err != nil {
+		return fmt.Errorf("failed to detect tool versions: %w", err)
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	for _, d := range detected {
+		source := filepath.Base(d.Source)
+		if file, ok := pinned[d.Package]; ok {
+			fmt.Printf("Kept %s %s (already in %s)\n", d.Package, file.Versions[d.Package], filepath.Base(file.Path))
+			continue
+		}
+
+		m, err := reg.LoadPackage(ctx, d.Package)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s %s from %s: %v\n", d.Package, d.Version, source, err)
+			continue
+		}
+		version, err := m.ResolveVersion(d.Version, platform.Detect().String())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s %s from %s: %v\n", d.Package, d.Version, source, err)
+			continue
+		}
+
+		f.Versions[d.Package] = version
+		fmt.Printf("Pinned %s %s (from %s)\n", d.Package, version, source)
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+	if len(f.Versions) == 0 && len(pinned) == 0 {
+		fmt.Printf("No tool versions detected; add them to %s\n", path)
+		return nil
+	}
+	fmt.Printf("Wrote %s\n", path)
+	if c.Bool("lock") {
+		return lockProject(ctx, c, dir)
+	}
+	return nil
+}
+
+// canWriteDir reports whether dir can be created and written to by the current process
+func canWriteDir(dir string) bool {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false
+	}
+	f, err := os.CreateTemp(dir, ".nori-write-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// psQuote quotes s as a PowerShell single-quoted string
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // addToProfile adds a line to a shell profile file if it doesn't already exist
 func addToProfile(profilePath, line string) (bool, error) {
 	// Read existing profile
@@ -124,14 +314,43 @@ func addToProfile(profilePath, line string) (bool, error) {
 
 // UpdateCommand handles the `nori update` command
 func UpdateCommand(ctx context.Context, c *urfavecli.Command) error {
-	reg := registry.NewFromEnv()
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("Updating registry...")
-	if err := reg.Update(ctx); err != nil {
+	summary, err := reg.Update(ctx)
+	if err != nil {
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
 	fmt.Println("Registry updated successfully")
+	fmt.Printf("Refreshed %d package(s): %d version(s), %d asset(s) across %d platform(s)\n",
+		summary.Packages, summary.Versions, summary.Assets, summary.Platforms)
+	if summary.Unchanged > 0 {
+		fmt.Printf("%d of them unchanged since the last update\n", summary.Unchanged)
+	}
+	if summary.Skipped > 0 {
+		fmt.Printf("Skipped %d package(s) with unusable manifests\n", summary.Skipped)
+	}
+
+	if len(summary.Gaps) > 0 {
+		fmt.Printf("\n%d package(s) lack assets for common platforms in their latest version:\n", len(summary.Gaps))
+		t := newTable("NAME", "LATEST", "MISSING")
+		for _, gap := range summary.Gaps {
+			t.addRow(style.Render(gap.Package), gap.Version, staleStyle.Render(strings.Join(gap.Missing, ", ")))
+		}
+		t.render(os.Stdout, terminalWidth())
+	}
+
+	if c.Bool("full") {
+		return prefetchInstalled(ctx, c, paths, reg)
+	}
 	return nil
 }
 
@@ -142,7 +361,14 @@ func SearchCommand(ctx context.Context, c *urfavecli.Command) error {
 	}
 
 	query := c.Args().Get(0)
-	reg := registry.NewFromEnv()
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
 
 	results, err := reg.Search(ctx, query)
 	if err != nil {
@@ -154,14 +380,162 @@ func SearchCommand(ctx context.Context, c *urfavecli.Command) error {
 		return nil
 	}
 
-	fmt.Printf("Found %d package(s):\n\n", len(results))
-	for _, pkg := range results {
-		fmt.Printf("  %s - %s\n", style.Render(pkg.Name), pkg.Description)
+	plat, err := platformFilter(c)
+	if err != nil {
+		return err
+	}
+	if plat != "" {
+		results = filterPlatform(ctx, reg, results, plat)
+		if len(results) == 0 {
+			fmt.Printf("No packages matching %q ship assets for %s\n", query, plat)
+			return nil
+		}
+	}
+
+	sortBy := c.String("sort")
+	if sortBy != "" {
+		if err := registry.SortPackages(results, sortBy); err != nil {
+			return err
+		}
 	}
 
+	renderPackages(paths, reg, results, terminalWidth(), c.Bool("long"), sortBy != "")
+
 	return nil
 }
 
+// platformFilter returns the --platform flag as an os-arch string, resolving
+// "current" to the platform nori is running on. It is empty when no filter is set.
+func platformFilter(c *urfavecli.Command) (string, error) {
+	plat := c.String("platform")
+	switch {
+	case plat == "":
+		return "", nil
+	case plat == "current":
+		return platform.Detect().String(), nil
+	case strings.Count(plat, "-") != 1:
+		return "", fmt.Errorf("invalid platform %q: expected <os>-<arch>, e.g. darwin-arm64, or current", plat)
+	}
+	return plat, nil
+}
+
+// filterPlatform keeps the packages that ship an asset for plat in at least one version.
+// Groups are kept when every member ships for plat in a version the group allows.
+func filterPlatform(ctx context.Context, reg *registry.Registry, pkgs []registry.PackageMeta, plat string) []registry.PackageMeta {
+	ships := func(name, version string) bool {
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			return false
+		}
+		if version == "" {
+			if m.IsGroup() {
+				return false
+			}
+			return len(m.VersionsFor(plat)) > 0
+		}
+		version, err = m.ResolveVersion(version, plat)
+		if err != nil {
+			return false
+		}
+		_, ok := m.Versions[version].Platforms[plat]
+		return ok
+	}
+
+	var kept []registry.PackageMeta
+	for _, pkg := range pkgs {
+		// Entries from the search index already list their platforms
+		if len(pkg.Platforms) > 0 {
+			if slices.Contains(pkg.Platforms, plat) {
+				kept = append(kept, pkg)
+			}
+			continue
+		}
+
+		m, err := reg.LoadPackage(ctx, pkg.Name)
+		if err != nil {
+			continue
+		}
+
+		ok := true
+		if m.IsGroup() {
+			for name, version := range m.Members {
+				ok = ok && ships(name, version)
+			}
+		} else {
+			ok = len(m.VersionsFor(plat)) > 0
+		}
+		if ok {
+			kept = append(kept, pkg)
+		}
+	}
+	return kept
+}
+
+// renderPackages prints registry packages as a table with their latest cached
+// version and installed state, fitted to width unless long. With stats, the registry's
+// download counts and update dates are shown too. With more than one registry, so is
+// each package's.
+func renderPackages(paths platform.Paths, reg *registry.Registry, pkgs []registry.PackageMeta, width int, long, stats bool) {
+	cfg := config.New(paths)
+	p := platform.Detect()
+	st, err := state.New(paths).Load()
+	if err != nil {
+		st = &state.State{}
+	}
+
+	headers := []string{"NAME", "LATEST", "INSTALLED"}
+	origin := len(reg.Registries()) > 1
+	if origin {
+		headers = append(headers, "REGISTRY")
+	}
+	if stats || long {
+		headers = append(headers, "DOWNLOADS", "UPDATED")
+	}
+	if long {
+		headers = append(headers, "HOMEPAGE")
+	}
+	t := newTable(append(headers, "DESCRIPTION")...)
+
+	for _, pkg := range pkgs {
+		// The search index has the latest version; only packages it lacks, such as
+		// before the first update, consult their cached manifest
+		latest, homepage := pkg.Latest, pkg.Homepage
+		if latest == "" {
+			if m, err := reg.CachedPackage(pkg.Name); err == nil {
+				latest = m.LatestVersion()
+				homepage = m.Homepage
+			}
+		}
+		if latest == "" {
+			latest = "-"
+		}
+
+		marker := ""
+		if active, _ := cfg.GetActive(pkg.Name); active != "" {
+			marker = activeStyle.Render("✓ " + active)
+		} else if len(st.Versions(pkg.Name, p.String())) > 0 {
+			marker = "✓"
+		}
+
+		row := []string{style.Render(pkg.Name), latest, marker}
+		if origin {
+			row = append(row, pkg.Registry)
+		}
+		if stats || long {
+			row = append(row, formatCount(pkg.Downloads), formatDate(pkg.Updated))
+		}
+		if long {
+			row = append(row, homepage)
+		}
+		t.addRow(append(row, pkg.Description)...)
+	}
+
+	if long {
+		width = 0
+	}
+	t.render(os.Stdout, width)
+}
+
 // InfoCommand handles the `nori info` command
 func InfoCommand(ctx context.Context, c *urfavecli.Command) error {
 	if c.NArg() == 0 {
@@ -169,13 +543,22 @@ func InfoCommand(ctx context.Context, c *urfavecli.Command) error {
 	}
 
 	pkgName := c.Args().Get(0)
-	reg := registry.NewFromEnv()
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
 
 	m, err := reg.LoadPackage(ctx, pkgName)
 	if err != nil {
 		return fmt.Errorf("failed to load package: %w", err)
 	}
 
+	defer startPager(c)()
+
 	fmt.Printf("%s: %s\n", style.Render(m.Name), m.Description)
 	if m.Homepage != "" {
 		fmt.Printf("Homepage: %s\n", m.Homepage)
@@ -183,38 +566,197 @@ func InfoCommand(ctx context.Context, c *urfavecli.Command) error {
 	if m.License != "" {
 		fmt.Printf("License: %s\n", m.License)
 	}
+	if len(m.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(m.Tags, ", "))
+	}
+	if index, err := reg.CachedIndex(); err == nil {
+		if meta := index.Find(m.Name); meta != nil {
+			if len(reg.Registries()) > 1 {
+				fmt.Printf("Registry: %s\n", meta.Registry)
+			}
+			if meta.Downloads > 0 {
+				fmt.Printf("Downloads: %s\n", formatCount(meta.Downloads))
+			}
+			if !meta.Updated.IsZero() {
+				fmt.Printf("Updated: %s\n", formatDate(meta.Updated))
+			}
+		}
+	}
+
+	if m.IsGroup() {
+		fmt.Printf("\nGroup members:\n")
+		names := make([]string, 0, len(m.Members))
+		for name := range m.Members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s@%s\n", name, m.Members[name])
+		}
+		return nil
+	}
 
 	fmt.Printf("\nBinaries: %s\n", strings.Join(m.Bins, ", "))
 
-	fmt.Printf("\nVersions:\n")
-	for version := range m.Versions {
-		fmt.Printf("  %s\n", version)
+	plat, err := platformFilter(c)
+	if err != nil {
+		return err
+	}
+	if plat == "" {
+		fmt.Printf("\nVersions:\n")
+		for _, version := range m.SortedVersions() {
+			fmt.Printf("  %s\n", version)
+		}
+	} else if versions := m.VersionsFor(plat); len(versions) == 0 {
+		fmt.Printf("\nNo versions ship assets for %s\n", plat)
+	} else {
+		fmt.Printf("\nVersions for %s:\n", plat)
+		for _, version := range versions {
+			fmt.Printf("  %s\n", version)
+		}
+	}
+
+	var channels []string
+	for name, ch := range m.Channels {
+		if _, ok := ch.Platforms[plat]; ok || plat == "" {
+			channels = append(channels, name)
+		}
+	}
+	if len(channels) > 0 {
+		sort.Strings(channels)
+		fmt.Printf("\nChannels (rolling builds, not reproducible):\n")
+		for _, name := range channels {
+			fmt.Printf("  %s\n", name)
+		}
 	}
 
 	return nil
 }
 
-// InstallCommand handles the `nori install` command
+// InstallCommand handles the `nori install` command. Without arguments it installs
+// the versions pinned for the current directory.
 func InstallCommand(ctx context.Context, c *urfavecli.Command) error {
 	if c.NArg() == 0 {
-		return fmt.Errorf("usage: nori install <package>@<version>")
+		return installProject(ctx, c)
+	}
+	if c.NArg() > 1 {
+		return installMany(ctx, c, c.Args().Slice())
 	}
 
-	arg := c.Args().Get(0)
-	parts := strings.Split(arg, "@")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid format: expected <package>@<version>")
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	jobs, err := resolveInstallArg(ctx, c, paths, reg, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if group := jobs[0].reason.Group; group != "" {
+		return installGroup(ctx, c, paths, group, jobs)
+	}
+	job := jobs[0]
+	return installVersion(ctx, c, paths, job.m, job.version, job.use, job.reason)
+}
+
+// resolveDigest finds the version whose asset for this platform has the given digest.
+// A channel matches if its current build has the digest; its checksum is then pinned
+// so the download is verified against the digest rather than the latest checksums file.
+func resolveDigest(ctx context.Context, fetcher *fetch.Fetcher, m *manifest.Manifest, digest string) (string, error) {
+	if !manifest.IsDigest(digest) {
+		return "", fmt.Errorf("invalid digest %q: expected sha256:<64 hex characters>", digest)
 	}
+	digest = strings.ToLower(digest)
+	platformStr := platform.Detect().String()
 
-	pkgName, version := parts[0], parts[1]
+	version, err := m.FindDigest(digest, platformStr)
+	if err == nil {
+		fmt.Printf("%s is %s@%s\n", digest, m.Name, version)
+		return version, nil
+	}
+
+	channels := make([]string, 0, len(m.Channels))
+	for name := range m.Channels {
+		channels = append(channels, name)
+	}
+	sort.Strings(channels)
+	for _, name := range channels {
+		asset, ok := m.Channels[name].Platforms[platformStr]
+		if !ok || asset.Checksum != "" || asset.ChecksumsURL == "" {
+			continue
+		}
+		if current, cerr := fetcher.FetchChecksum(ctx, asset.ChecksumsURL, asset.URL); cerr == nil && current == digest {
+			asset.Checksum = digest
+			m.Channels[name].Platforms[platformStr] = asset
+			fmt.Printf("%s is the current %s@%s build\n", digest, m.Name, name)
+			return name, nil
+		}
+	}
 
-	reg := registry.NewFromEnv()
+	return "", err
+}
 
-	// Load manifest
-	m, err := reg.LoadPackage(ctx, pkgName)
+// installGroup installs the members of the package group, as resolveInstallArg
+// resolved them
+func installGroup(ctx context.Context, c *urfavecli.Command, paths platform.Paths, group string, members []installJob) error {
+	fmt.Printf("Installing group %s (%d packages)...\n", group, len(members))
+
+	for _, member := range members {
+		if err := installVersion(ctx, c, paths, member.m, member.version, member.use, member.reason); err != nil {
+			return fmt.Errorf("failed to install group member %s: %w", member.m.Name, err)
+		}
+	}
+
+	fmt.Printf("Installed group %s\n", group)
+	return nil
+}
+
+// installVersion downloads, extracts and installs a single package version,
+// activating it when use is set or the settings ask for it, and records reason as one
+// of the reasons it is installed, even if it already was
+func installVersion(ctx context.Context, c *urfavecli.Command, paths platform.Paths, m *manifest.Manifest, version string, use bool, reason state.Reason) error {
+	plan, err := planInstall(ctx, c, paths, m, version, use)
 	if err != nil {
-		return fmt.Errorf("failed to load package: %w", err)
+		return err
+	}
+
+	if plan != nil {
+		fmt.Printf("Installing %s@%s for %s...\n", m.Name, version, plan.platform)
+		installPath, err := fetchAndInstall(ctx, c, paths, plan, &barDisplay{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+			return err
+		}
+		if err := finishInstall(ctx, paths, plan, installPath); err != nil {
+			return err
+		}
 	}
+	require(paths, m.Name, version, reason)
+	return nil
+}
+
+// installPlan is a version planInstall has cleared for download and install
+type installPlan struct {
+	m         *manifest.Manifest
+	source    *registry.Registry // the registry m comes from, whose policies apply
+	version   string
+	asset     *manifest.Asset
+	platform  platform.Platform
+	active    string // the active version before the install
+	activate  bool
+	skipSmoke bool   // don't run the manifest's smoke test, see checkInstall
+	replace   bool   // install over a different build of a channel that is installed
+	previous  string // where the build replace swapped out is kept until the new one checks out
+}
+
+// planInstall checks that version can be installed and works out whether to activate it.
+// A version that is already installed is activated if needed and a nil plan returned.
+func planInstall(ctx context.Context, c *urfavecli.Command, paths platform.Paths, m *manifest.Manifest, version string, use bool) (*installPlan, error) {
+	pkgName := m.Name
+	cfg := config.New(paths)
 
 	// Detect platform
 	p := platform.Detect()
@@ -222,78 +764,368 @@ func InstallCommand(ctx context.Context, c *urfavecli.Command) error {
 
 	// Validate version/platform
 	if err := manifest.ValidateVersion(m, version, platformStr); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Get asset
 	asset, err := m.GetAsset(version, platformStr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	fmt.Printf("Installing %s@%s for %s...\n", pkgName, version, platformStr)
-
-	// Fetch with progress
-	fetcher := fetch.New()
-	
-	// Get content length for progress bar
-	var totalSize int64
-	req, _ := http.NewRequestWithContext(ctx, "HEAD", asset.URL, nil)
-	if resp, err := http.DefaultClient.Do(req); err == nil {
-		totalSize = resp.ContentLength
-		resp.Body.Close()
+	if asset, err = hostBuild(m, version, asset); err != nil {
+		return nil, err
 	}
-	
-	downloadBar := NewProgressBar(totalSize, "Downloading")
-	data, err := fetcher.FetchWithProgress(ctx, asset.URL, asset.Checksum, downloadBar)
+
+	source, err := manifestSource(paths, m)
 	if err != nil {
-		downloadBar.Finish()
-		fmt.Fprintf(os.Stderr, "\nError: download failed: %v\n", err)
-		return fmt.Errorf("download failed: %w", err)
+		return nil, err
 	}
-	downloadBar.Finish()
 
-	// Extract with progress
-	extractor := extract.New()
-	
-	// File count progress (unknown total, will show count)
-	extractBar := NewFileProgressBar(0, "Extracting")
-	fileCount := 0
-	
-	extractDir, err := extractor.ExtractWithProgress(data, asset.Type, asset.Checksum, func() {
-		fileCount++
-		extractBar.SetCurrent(fileCount)
-	})
-	if err != nil {
-		extractBar.Finish()
-		fmt.Fprintf(os.Stderr, "\nError: extraction failed: %v\n", err)
-		return fmt.Errorf("extraction failed: %w", err)
+	channel := m.IsChannel(version)
+	if err := resolveAsset(ctx, c, paths, m, version, asset); err != nil {
+		return nil, err
+	}
+	if channel {
+		fmt.Printf("Warning: %s@%s is a rolling channel; its contents change with each build and are not reproducible\n", pkgName, version)
 	}
-	extractBar.Finish()
-	defer os.RemoveAll(extractDir)
 
-	// Install
-	installer := install.New()
-	fmt.Println("Installing...")
-	installPath, err := installer.Install(ctx, m, version, p, extractDir)
+	// Activate when requested, when configured to, or when nothing is active yet
+	settings, err := cfg.LoadSettings()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: installation failed: %v\n", err)
-		return fmt.Errorf("installation failed: %w", err)
+		return nil, err
 	}
+	active, _ := cfg.GetActive(pkgName)
+	shouldActivate := use || settings.AutoUseFor(pkgName) || active == "" || active == version
 
-	// Create shims
-	shimsDir := platform.ShimsDir()
-	shim := shims.New(shimsDir)
-	if err := shim.UpdateShims(pkgName, version, m.Bins, installPath); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to create shims: %v\n", err)
-		return fmt.Errorf("failed to create shims: %w", err)
+	// Refuse silent downgrades of the active version
+	if shouldActivate && active != "" && !channel && manifest.CompareVersions(version, active) < 0 {
+		if settings.Strict && !c.Bool("allow-downgrade") {
+			return nil, fmt.Errorf("refusing to downgrade %s from %s to %s in strict mode (use --allow-downgrade)", pkgName, active, version)
+		}
+		fmt.Printf("Warning: this downgrades %s from %s to %s\n", pkgName, active, version)
 	}
 
-	fmt.Printf("Installed %s@%s to %s\n", pkgName, version, installPath)
-	return nil
-}
+	// Skip the download when this version is already installed. A channel is replaced
+	// when its build has changed since it was installed, once the new build is verified.
+	installPath := paths.InstallPath(pkgName, version, platformStr)
+	replace := false
+	if channel && dirExists(installPath) {
+		if r, err := receipt.Load(installPath); err != nil || r.Checksum != asset.Checksum {
+			fmt.Printf("Updating %s@%s to the current build\n", pkgName, version)
+			replace = true
+		}
+	}
+	if dirExists(installPath) && !replace {
+		fmt.Printf("%s@%s is already installed\n", pkgName, version)
+		if !shouldActivate {
+			return nil, nil
+		}
+		if err := activate(paths, pkgName, version, m.Bins, installPath); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Using %s@%s\n", pkgName, version)
+		return nil, nil
+	}
+
+	return &installPlan{m: m, source: source, version: version, asset: asset, platform: p, active: active, activate: shouldActivate, skipSmoke: c.Bool("skip-smoke-test"), replace: replace}, nil
+}
+
+// manifestSource returns the registry m was loaded from, whose settings decide how its
+// assets are checked
+func manifestSource(paths platform.Paths, m *manifest.Manifest) (*registry.Registry, error) {
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, err
+	}
+	source := reg.Source(m)
+	if source == nil {
+		return nil, fmt.Errorf("%s comes from registry %q, which is no longer configured", m.Name, m.Registry)
+	}
+	return source, nil
+}
+
+// hostInfo describes this machine to the requirements of assets
+func hostInfo() manifest.Host {
+	return manifest.Host{OSVersion: platform.OSVersion(), CPUFeatures: platform.CPUFeatures()}
+}
+
+// hostBuild returns the build of asset this machine can run, noting when that is a
+// baseline build rather than the asset itself
+func hostBuild(m *manifest.Manifest, version string, asset *manifest.Asset) (*manifest.Asset, error) {
+	host := hostInfo()
+	build, err := asset.ForHost(host)
+	if err != nil {
+		return nil, fmt.Errorf("cannot install %s@%s: %w", m.Name, version, err)
+	}
+	if build != asset {
+		fmt.Fprintf(os.Stderr, "Note: installing the baseline build of %s@%s, as this machine lacks %s\n", m.Name, version, strings.Join(asset.Unmet(host), ", "))
+	}
+	return build, nil
+}
+
+// resolveAsset fills in what an asset leaves to install time: the download URL of a
+// GitHub release asset, and the checksum of the current build when version is a rolling
+// channel, which publishes a new one with every build
+func resolveAsset(ctx context.Context, c *urfavecli.Command, paths platform.Paths, m *manifest.Manifest, version string, asset *manifest.Asset) error {
+	release := asset.URL == "" && asset.GitHub != ""
+	channelBuild := m.IsChannel(version) && asset.Checksum == ""
+	if !release && !channelBuild {
+		return nil
+	}
+	fetcher, err := newFetcher(c, paths)
+	if err != nil {
+		return err
+	}
+	// A cached archive can still be installed when the lookup fails, as when offline
+	if release {
+		err := resolveReleaseAsset(ctx, fetcher, version, asset)
+		if err != nil && (channelBuild || cachedArchive(paths, asset.Checksum) == nil) {
+			return fmt.Errorf("failed to find the %s@%s download: %w", m.Name, version, err)
+		}
+	}
+	if !channelBuild {
+		return nil
+	}
+	checksum, err := fetcher.FetchChecksum(ctx, asset.ChecksumsURL, asset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the current %s@%s build: %w", m.Name, version, err)
+	}
+	asset.Checksum = checksum
+	return nil
+}
+
+// resolveReleaseAsset sets the URL of an asset downloaded from GitHub releases
+func resolveReleaseAsset(ctx context.Context, fetcher *fetch.Fetcher, version string, asset *manifest.Asset) error {
+	if asset.URL != "" || asset.GitHub == "" {
+		return nil
+	}
+	downloadURL, err := fetcher.ResolveReleaseAsset(ctx, asset.GitHub, asset.ReleaseTag(version), asset.ReleaseAssetPattern(version))
+	if err != nil {
+		return err
+	}
+	asset.URL = downloadURL
+	return nil
+}
+
+// fetchAndInstall downloads, extracts and installs a planned version, reporting progress
+// to display, and returns where it was installed. It doesn't activate the version, so
+// several can run at once.
+func fetchAndInstall(ctx context.Context, c *urfavecli.Command, paths platform.Paths, plan *installPlan, display installDisplay) (string, error) {
+	pkgName, version, asset := plan.m.Name, plan.version, plan.asset
+	if err := checkProvenance(ctx, c, paths, plan, display); err != nil {
+		return "", err
+	}
+
+	// An archive left by `nori prefetch` or an earlier install saves the download
+	log := events.FromContext(ctx)
+	extractor := newExtractor(paths)
+	extractor.SetPayloadChecksum(asset.PayloadChecksum)
+	var extractDir string
+	data := cachedArchive(paths, asset.Checksum)
+	if data != nil {
+		display.Status("Using cached archive")
+	} else {
+		fetcher, err := newFetcher(c, paths)
+		if err != nil {
+			return "", err
+		}
+
+		fetcher.SetPayloadChecksum(asset.PayloadChecksum)
+		phase := log.Begin(events.Event{Package: pkgName, Version: version, Phase: "download", URL: asset.URL})
+		progress := display.StartDownload(fetcher.ContentLength(ctx, asset.URL))
+		var files int
+		if asset.Type == "tar" {
+			extractDir, files, data, err = streamExtract(ctx, fetcher, extractor, plan, progress)
+		} else {
+			data, err = fetcher.FetchFromMirrors(ctx, asset.URLs(), asset.Checksum, progress)
+		}
+		phase.Bytes = int64(len(data))
+		phase.End(err)
+		display.EndDownload()
+		if err != nil {
+			return "", fmt.Errorf("download failed: %w", err)
+		}
+		if extractDir != "" {
+			display.Extracted(files)
+			display.EndExtract()
+		}
+	}
+
+	// Extract with progress, unless that happened during the download; the file count
+	// isn't known up front
+	if extractDir == "" {
+		fileCount := 0
+		phase := log.Begin(events.Event{Package: pkgName, Version: version, Phase: "extract"})
+		var err error
+		extractDir, err = extractor.ExtractWithProgress(data, asset.Type, asset.Checksum, func() {
+			fileCount++
+			display.Extracted(fileCount)
+		})
+		phase.Files = fileCount
+		phase.End(err)
+		display.EndExtract()
+		if err != nil {
+			return "", fmt.Errorf("extraction failed: %w", err)
+		}
+	}
+	defer os.RemoveAll(extractDir)
+
+	// Unwrap an archive packed inside the asset, such as a tarball inside a zip
+	if _, err := extractor.ExtractInner(extractDir, asset.InnerPattern(version), nil); err != nil {
+		return "", fmt.Errorf("extraction failed: %w", err)
+	}
+
+	// Install
+	installer := install.New(paths)
+	display.Status("Installing...")
+	phase := log.Begin(events.Event{Package: pkgName, Version: version, Phase: "install"})
+	var installPath string
+	var err error
+	if plan.replace {
+		installPath, plan.previous, err = installer.Replace(ctx, plan.m, version, plan.platform, extractDir)
+	} else {
+		installPath, err = installer.Install(ctx, plan.m, version, plan.platform, extractDir)
+	}
+	phase.End(err)
+	if err != nil {
+		return "", fmt.Errorf("installation failed: %w", err)
+	}
+
+	// Record per-file hashes so `nori verify` can detect and repair damage later
+	if r, err := receipt.New(pkgName, version, plan.platform.String(), asset, installPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		r.Bins, r.Env = plan.m.Bins, plan.m.Env
+		if err := r.Save(installPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	saveArchive(paths, asset.Checksum, data)
+	return installPath, nil
+}
+
+// streamExtract downloads the tar archive of plan and extracts it as it arrives,
+// returning the extracted directory, its file count and the verified archive. The
+// directory is only returned once the archive has verified; if streaming didn't work
+// out, it is "" and the archive is left for the caller to extract.
+func streamExtract(ctx context.Context, fetcher *fetch.Fetcher, extractor *extract.Extractor, plan *installPlan, progress io.Writer) (string, int, []byte, error) {
+	var extractDir string
+	fileCount := 0
+	data, streamed, err := fetcher.FetchStreaming(ctx, plan.asset.URLs(), plan.asset.Checksum, progress, func(r io.Reader) error {
+		phase := events.FromContext(ctx).Begin(events.Event{Package: plan.m.Name, Version: plan.version, Phase: "extract"})
+		var err error
+		extractDir, err = extractor.ExtractTarStream(r, func() { fileCount++ })
+		phase.Files = fileCount
+		phase.End(err)
+		return err
+	})
+	if !streamed {
+		if extractDir != "" {
+			os.RemoveAll(extractDir)
+		}
+		return "", 0, data, err
+	}
+	return extractDir, fileCount, data, nil
+}
+
+// finishInstall reports an installed version and activates it if the plan says to
+func finishInstall(ctx context.Context, paths platform.Paths, plan *installPlan, installPath string) error {
+	pkgName, version := plan.m.Name, plan.version
+	fmt.Printf("Installed %s@%s to %s\n", pkgName, version, installPath)
+
+	// The build a channel update replaced is kept until the new one has been checked
+	defer func() {
+		if plan.previous != "" {
+			os.RemoveAll(plan.previous)
+		}
+	}()
+
+	if !plan.activate {
+		if err := checkInstall(ctx, paths, plan, installPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return err
+		}
+		fmt.Printf("Active version is still %s; run `nori use %s@%s` to switch\n", plan.active, pkgName, version)
+		return nil
+	}
+
+	phase := events.FromContext(ctx).Begin(events.Event{Package: pkgName, Version: version, Phase: "activate"})
+	err := activate(paths, pkgName, version, plan.m.Bins, installPath)
+	phase.End(err)
+	if err == nil {
+		err = checkInstall(ctx, paths, plan, installPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+
+	fmt.Printf("Using %s@%s\n", pkgName, version)
+	return nil
+}
+
+// activate records version as the active one for pkgName and points its shims at
+// installPath. It is all or nothing: if a step fails, the previously active version and
+// the shims as they were are restored, so a half-written activation is never left behind.
+func activate(paths platform.Paths, pkgName, version string, bins []string, installPath string) (err error) {
+	cfg := config.New(paths)
+	previous, _ := cfg.GetActive(pkgName)
+	shim := shims.New(paths.ShimsDir())
+	shimNames := make([]string, len(bins))
+	for i, bin := range bins {
+		shimNames[i] = filepath.Base(bin)
+	}
+	snapshot, err := shim.Snapshot(shimNames)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if restoreErr := restoreActivation(cfg, pkgName, previous, snapshot); restoreErr != nil {
+				err = fmt.Errorf("%w; restoring the previous activation also failed: %v", err, restoreErr)
+			}
+		}
+	}()
+
+	if err := cfg.SetActive(pkgName, version); err != nil {
+		return fmt.Errorf("failed to set active version: %w", err)
+	}
+
+	if err := shim.UpdateShims(pkgName, version, bins, installPath); err != nil {
+		return fmt.Errorf("failed to update shims: %w", err)
+	}
+
+	err = state.New(paths).Update(func(st *state.State) error {
+		st.SetActive(pkgName, version)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update state index: %w", err)
+	}
+
+	// Bins the previous version had and this one dropped leave shims behind
+	repairShims(paths, platform.Detect())
+	return nil
+}
 
-// UseCommand handles the `nori use` command
+// restoreActivation undoes a failed activate: previous becomes the active version of
+// pkgName again, or none if it is "", and the shims are put back from snapshot. The
+// state index is written last by activate, so it never needs restoring.
+func restoreActivation(cfg *config.Config, pkgName, previous string, snapshot *shims.Snapshot) error {
+	var err error
+	if previous == "" {
+		err = cfg.ClearActive(pkgName)
+	} else {
+		err = cfg.SetActive(pkgName, previous)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore active version: %w", err)
+	}
+	return snapshot.Restore()
+}
+
+// UseCommand handles the `nori use` command. A version that isn't installed is installed
+// and activated with --install, or if the user agrees when asked.
 func UseCommand(ctx context.Context, c *urfavecli.Command) error {
 	if c.NArg() == 0 {
 		return fmt.Errorf("usage: nori use <package>@<version>")
@@ -308,7 +1140,14 @@ func UseCommand(ctx context.Context, c *urfavecli.Command) error {
 	pkgName, version := parts[0], parts[1]
 
 	// Load manifest and validate version exists
-	reg := registry.NewFromEnv()
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
 	m, err := reg.LoadPackage(ctx, pkgName)
 	if err != nil {
 		return fmt.Errorf("failed to load package: %w", err)
@@ -317,31 +1156,79 @@ func UseCommand(ctx context.Context, c *urfavecli.Command) error {
 	// Detect platform and validate version/platform
 	p := platform.Detect()
 	platformStr := p.String()
+	if manifest.IsDigest(version) {
+		if version, err = m.FindDigest(version, platformStr); err != nil {
+			return err
+		}
+	} else if _, ok := m.Versions[version]; !ok && !m.IsChannel(version) {
+		// A range picks the highest matching version that is installed, or else the
+		// highest matching release, if it may be installed
+		spec := version
+		if version, err = resolveInstalled(paths, m, spec, platformStr); err != nil {
+			resolved, resolveErr := m.ResolveVersion(spec, platformStr)
+			if resolveErr != nil {
+				return err
+			}
+			if !installForUse(c, pkgName, resolved, err) {
+				return fmt.Errorf("%w (pass --install to install %s@%s)", err, pkgName, resolved)
+			}
+			fmt.Printf("Resolved %s@%s to %s\n", pkgName, spec, resolved)
+			return installVersion(ctx, c, paths, m, resolved, true, state.Reason{})
+		}
+	}
 	if err := manifest.ValidateVersion(m, version, platformStr); err != nil {
 		return fmt.Errorf("version %q does not exist for package %q on platform %q", version, pkgName, platformStr)
 	}
 
 	// Verify installation exists
-	installPath := platform.InstallPath(pkgName, version, p.String())
+	installPath := paths.InstallPath(pkgName, version, p.String())
 	if _, err := os.Stat(installPath); os.IsNotExist(err) {
-		return fmt.Errorf("package %s@%s is not installed", pkgName, version)
+		err := fmt.Errorf("package %s@%s is not installed", pkgName, version)
+		if !installForUse(c, pkgName, version, err) {
+			return fmt.Errorf("%w (pass --install to install it)", err)
+		}
+		return installVersion(ctx, c, paths, m, version, true, state.Reason{})
 	}
 
-	// Set active
-	if err := config.SetActive(pkgName, version); err != nil {
-		return fmt.Errorf("failed to set active version: %w", err)
+	// Set active and update shims (use manifest we already loaded)
+	if err := activate(paths, pkgName, version, m.Bins, installPath); err != nil {
+		return err
 	}
 
-	// Update shims (use manifest we already loaded)
+	fmt.Printf("Using %s@%s\n", pkgName, version)
+	return nil
+}
+
+// installForUse reports whether `nori use` should install pkgName@version, which isn't
+// installed for reason: with --install, or if the user agrees when asked
+func installForUse(c *urfavecli.Command, pkgName, version string, reason error) bool {
+	if c.Bool("install") {
+		return true
+	}
+	return interactive() && confirm(fmt.Sprintf("%v. Install %s@%s and use it?", reason, pkgName, version))
+}
 
-	shimsDir := platform.ShimsDir()
-	shim := shims.New(shimsDir)
-	if err := shim.UpdateShims(pkgName, version, m.Bins, installPath); err != nil {
-		return fmt.Errorf("failed to update shims: %w", err)
+// resolveInstalled returns the highest installed version of m for plat in the range spec
+func resolveInstalled(paths platform.Paths, m *manifest.Manifest, spec, plat string) (string, error) {
+	r, err := manifest.ParseRange(spec)
+	if err != nil {
+		return "", err
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return "", err
 	}
 
-	fmt.Printf("Using %s@%s\n", pkgName, version)
-	return nil
+	installed := st.Versions(m.Name, plat)
+	version := r.Highest(installed)
+	if version == "" {
+		if len(installed) == 0 {
+			return "", fmt.Errorf("no version of %s is installed", m.Name)
+		}
+		return "", fmt.Errorf("no installed version of %s matches %s (installed: %s)", m.Name, spec, strings.Join(installed, ", "))
+	}
+	fmt.Printf("Resolved %s@%s to %s\n", m.Name, spec, version)
+	return version, nil
 }
 
 // ListCommand handles the `nori list` command
@@ -351,57 +1238,211 @@ func ListCommand(ctx context.Context, c *urfavecli.Command) error {
 		pkgName = c.Args().Get(0)
 	}
 
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
 	p := platform.Detect()
-	installsDir := platform.InstallsDir()
+	cfg := config.New(paths)
+	long := c.Bool("long")
+	// Measured before the pager takes over stdout
+	width := terminalWidth()
+	if long {
+		width = 0
+	}
+
+	if c.Bool("all") {
+		// Every package in the registry, installed or not
+		reg, err := newRegistry(paths)
+		if err != nil {
+			return err
+		}
+		pkgs, err := reg.Search(ctx, "")
+		if err != nil {
+			return fmt.Errorf("failed to load registry index: %w", err)
+		}
+		defer startPager(c)()
+		renderPackages(paths, reg, pkgs, width, long, false)
+		return nil
+	}
+
+	defer startPager(c)()
+
+	if c.Bool("outdated-shims") {
+		return listStaleShims(paths, p, width)
+	}
+
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return err
+	}
 
 	if pkgName != "" {
 		// List versions for specific package
-		pkgDir := filepath.Join(installsDir, pkgName)
-		entries, err := os.ReadDir(pkgDir)
-		if os.IsNotExist(err) {
+		versions := st.Versions(pkgName, p.String())
+		if len(versions) == 0 {
 			fmt.Printf("Package %s is not installed\n", pkgName)
 			return nil
 		}
-		if err != nil {
-			return fmt.Errorf("failed to read installs: %w", err)
-		}
-
-		fmt.Printf("Installed versions of %s:\n", pkgName)
-		for _, entry := range entries {
-			if entry.IsDir() {
-				versionDir := filepath.Join(pkgDir, entry.Name())
-				platformDir := filepath.Join(versionDir, p.String())
-				if _, err := os.Stat(platformDir); err == nil {
-					active, _ := config.GetActive(pkgName)
-					marker := ""
-					if active == entry.Name() {
-						marker = " (active)"
-					}
-					fmt.Printf("  %s%s\n", entry.Name(), marker)
-				}
+
+		active, _ := cfg.GetActive(pkgName)
+
+		var t *table
+		if long {
+			t = newTable("VERSION", "STATUS", "PATH")
+		} else {
+			t = newTable("VERSION", "STATUS")
+		}
+		for _, version := range versions {
+			status := ""
+			if version == active {
+				status = activeStyle.Render("active")
+			}
+			if long {
+				t.addRow(version, status, paths.InstallPath(pkgName, version, p.String()))
+			} else {
+				t.addRow(version, status)
 			}
 		}
+
+		fmt.Printf("Installed versions of %s:\n\n", pkgName)
+		t.render(os.Stdout, width)
+		return nil
+	}
+
+	// List all installed packages
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+
+	var t *table
+	if long {
+		t = newTable("NAME", "ACTIVE", "INSTALLED", "LATEST", "PATH")
 	} else {
-		// List all installed packages
-		entries, err := os.ReadDir(installsDir)
-		if os.IsNotExist(err) {
-			fmt.Println("No packages installed")
-			return nil
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read installs: %w", err)
+		t = newTable("NAME", "ACTIVE", "INSTALLED", "LATEST")
+	}
+	for _, name := range st.Installed(p.String()) {
+		versions := st.Versions(name, p.String())
+
+		active, _ := cfg.GetActive(name)
+		activeCell, pathCell := "-", ""
+		if active != "" {
+			activeCell = activeStyle.Render(active)
+			pathCell = paths.InstallPath(name, active, p.String())
 		}
 
-		for _, entry := range entries {
-			if entry.IsDir() {
-				fmt.Printf("  %s\n", entry.Name())
+		// Highlight when the cached registry knows a newer version than any installed one
+		latest := "-"
+		if m, err := reg.CachedPackage(name); err == nil && m.LatestVersion() != "" {
+			latest = m.LatestVersion()
+			if manifest.CompareVersions(latest, versions[len(versions)-1]) > 0 {
+				latest = staleStyle.Render(latest)
 			}
 		}
+
+		if long {
+			t.addRow(style.Render(name), activeCell, strings.Join(versions, ", "), latest, pathCell)
+		} else {
+			t.addRow(style.Render(name), activeCell, strings.Join(versions, ", "), latest)
+		}
+	}
+
+	if len(t.rows) == 0 {
+		fmt.Println("No packages installed")
+		return nil
 	}
+	t.render(os.Stdout, width)
 
 	return nil
 }
 
+// CurrentCommand handles the `nori current` command
+func CurrentCommand(ctx context.Context, c *urfavecli.Command) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	result, err := project.Resolve(paths, cwd)
+	if err != nil {
+		return fmt.Errorf("failed to resolve versions: %w", err)
+	}
+
+	pkgNames := c.Args().Slice()
+	if len(pkgNames) == 0 {
+		for name := range result.Versions {
+			pkgNames = append(pkgNames, name)
+		}
+		sort.Strings(pkgNames)
+	}
+
+	if c.Bool("explain") {
+		explainResolution(paths, result, pkgNames)
+		return nil
+	}
+
+	if len(pkgNames) == 0 {
+		fmt.Println("No active versions")
+		return nil
+	}
+
+	for _, name := range pkgNames {
+		res, ok := result.Versions[name]
+		if !ok {
+			fmt.Printf("  %s (none)\n", name)
+			continue
+		}
+		fmt.Printf("  %s %s (%s)\n", style.Render(name), res.Version, res.Source)
+	}
+
+	return nil
+}
+
+// explainResolution prints every file in the resolution chain and which entries won
+func explainResolution(paths platform.Paths, result *project.Result, pkgNames []string) {
+	wanted := make(map[string]bool, len(pkgNames))
+	for _, name := range pkgNames {
+		wanted[name] = true
+	}
+
+	fmt.Println("Resolution chain (nearest first, first match wins):")
+	for i, f := range result.Chain {
+		switch f.Path {
+		case paths.ActiveConfigPath():
+			fmt.Printf("\n%d. %s (your active versions)\n", i+1, f.Path)
+		case paths.SystemActiveConfigPath():
+			fmt.Printf("\n%d. %s (machine-wide defaults)\n", i+1, f.Path)
+		default:
+			fmt.Printf("\n%d. %s\n", i+1, f.Path)
+		}
+
+		names := make([]string, 0, len(f.Versions))
+		for name := range f.Versions {
+			if wanted[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			fmt.Println("     (no matching entries)")
+			continue
+		}
+		for _, name := range names {
+			status := "overridden"
+			if result.Versions[name].Source == f.Path {
+				status = "used"
+			}
+			fmt.Printf("     %s %s (%s)\n", name, f.Versions[name], status)
+		}
+	}
+}
+
 // WhichCommand handles the `nori which` command
 func WhichCommand(ctx context.Context, c *urfavecli.Command) error {
 	if c.NArg() == 0 {
@@ -410,22 +1451,42 @@ func WhichCommand(ctx context.Context, c *urfavecli.Command) error {
 
 	binName := c.Args().Get(0)
 
-	// Find which package provides this binary
-	reg := registry.NewFromEnv()
+	// Find which package provides this binary, asking the state index before the registry
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
 
-	// Load index to find packages
-	results, err := reg.Search(ctx, "")
+	st, err := state.New(paths).Load()
 	if err != nil {
-		return fmt.Errorf("failed to search registry: %w", err)
+		st = &state.State{}
 	}
+	pkgName, _ := st.Provides(binName, p.String())
 
-	var pkgName string
-	for _, pkg := range results {
-		m, err := reg.LoadPackage(ctx, pkg.Name)
+	var results []registry.PackageMeta
+	if pkgName == "" {
+		results, err = reg.Search(ctx, "")
 		if err != nil {
-			continue
+			return fmt.Errorf("failed to search registry: %w", err)
+		}
+	}
+
+	for _, pkg := range results {
+		// Entries from the search index already list their bins
+		bins := pkg.Bins
+		if bins == nil {
+			m, err := reg.LoadPackage(ctx, pkg.Name)
+			if err != nil {
+				continue
+			}
+			bins = m.Bins
 		}
-		for _, bin := range m.Bins {
+		for _, bin := range bins {
 			if filepath.Base(bin) == binName {
 				pkgName = pkg.Name
 				break
@@ -440,24 +1501,38 @@ func WhichCommand(ctx context.Context, c *urfavecli.Command) error {
 		return fmt.Errorf("binary %q not found in any package", binName)
 	}
 
-	// Get active version
-	version, err := config.GetActive(pkgName)
-	if err != nil || version == "" {
+	// Resolve the version in effect here (environment, project files, then global)
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	result, err := project.ResolveCached(paths, cwd)
+	if err != nil {
+		return fmt.Errorf("failed to resolve versions: %w", err)
+	}
+	res, ok := result.Versions[pkgName]
+	if !ok {
 		return fmt.Errorf("package %s has no active version", pkgName)
 	}
+	version := res.Version
 
 	// Resolve path
-	p := platform.Detect()
-	installPath := platform.InstallPath(pkgName, version, p.String())
+	installPath := paths.InstallPath(pkgName, version, p.String())
 
-	m, err := reg.LoadPackage(ctx, pkgName)
-	if err != nil {
-		return fmt.Errorf("failed to load manifest: %w", err)
+	// Find bin path, from the install record when it has one
+	var bins []string
+	if inst := st.Find(pkgName, version, p.String()); inst != nil && len(inst.Bins) > 0 {
+		bins = inst.Bins
+	} else {
+		m, err := reg.LoadPackage(ctx, pkgName)
+		if err != nil {
+			return fmt.Errorf("failed to load manifest: %w", err)
+		}
+		bins = m.Bins
 	}
 
-	// Find bin path
 	var binPath string
-	for _, bin := range m.Bins {
+	for _, bin := range bins {
 		if filepath.Base(bin) == binName {
 			binPath = filepath.Join(installPath, bin)
 			break
@@ -472,6 +1547,290 @@ func WhichCommand(ctx context.Context, c *urfavecli.Command) error {
 	return nil
 }
 
+// PathCommand handles the `nori path` command. It prints the directory holding the bins
+// of the version in effect here, or of the given version, so that scripts can put a
+// tool on PATH directly without going through shims.
+func PathCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori path <package>[@<version>]")
+	}
+
+	parts := strings.SplitN(c.Args().Get(0), "@", 2)
+	pkgName := parts[0]
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
+
+	var version string
+	if len(parts) == 2 {
+		version = parts[1]
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		result, err := project.ResolveCached(paths, cwd)
+		if err != nil {
+			return fmt.Errorf("failed to resolve versions: %w", err)
+		}
+		res, ok := result.Versions[pkgName]
+		if !ok {
+			return fmt.Errorf("package %s has no active version", pkgName)
+		}
+		version = res.Version
+	}
+
+	installPath := paths.InstallPath(pkgName, version, p.String())
+	if !dirExists(installPath) {
+		return fmt.Errorf("%s@%s is not installed for %s", pkgName, version, p.String())
+	}
+
+	bins, err := installedBins(ctx, paths, pkgName, version, p.String())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(strings.Join(install.BinDirs(installPath, bins), string(os.PathListSeparator)))
+	return nil
+}
+
+// installedBins returns the bins of an installed version. They come from the install
+// record, or the manifest for installs the index lacks them for.
+func installedBins(ctx context.Context, paths platform.Paths, pkgName, version, plat string) ([]string, error) {
+	if st, err := state.New(paths).Load(); err == nil {
+		if inst := st.Find(pkgName, version, plat); inst != nil && len(inst.Bins) > 0 {
+			return inst.Bins, nil
+		}
+	}
+
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, err
+	}
+	m, err := reg.LoadPackage(ctx, pkgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	return m.Bins, nil
+}
+
+// installedEnv returns the env the manifest declares for an installed version, sorted by
+// name. It comes from the install record, or the manifest for installs the index lacks.
+func installedEnv(ctx context.Context, paths platform.Paths, pkgName, version, plat string) ([][2]string, error) {
+	installPath := paths.InstallPath(pkgName, version, plat)
+	if st, err := state.New(paths).Load(); err == nil {
+		if inst := st.Find(pkgName, version, plat); inst != nil {
+			return sortedVars(manifest.ExpandEnv(inst.Env, installPath)), nil
+		}
+	}
+
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, err
+	}
+	m, err := reg.LoadPackage(ctx, pkgName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+	return sortedVars(m.EnvFor(installPath)), nil
+}
+
+// newFetcher returns a proxied fetcher that reports mirror details with --verbose and
+// downloads no faster than --limit-rate
+func newFetcher(c *urfavecli.Command, paths platform.Paths) (*fetch.Fetcher, error) {
+	fetcher, err := proxiedFetcher(paths)
+	if err != nil {
+		return nil, err
+	}
+	if limit := c.String("limit-rate"); limit != "" {
+		rate, err := fetch.ParseRate(limit)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.SetRateLimit(rate)
+	}
+	if c.Bool("verbose") {
+		fetcher.SetVerbose(os.Stderr)
+	}
+	return fetcher, nil
+}
+
+// mirrorHealth is shared by the fetchers of a command, so its downloads probe each mirror
+// host once. `nori daemon` forgets it at the start of every cycle.
+var mirrorHealth = fetch.NewHostHealth()
+
+// proxiedFetcher returns a fetcher that keeps checksums files and asset sizes in the
+// HTTP cache and downloads through the caching proxy in $NORI_ASSET_PROXY or the
+// asset_proxy setting, if one is configured, and the HTTP proxy of the proxy setting,
+// trusting the CA and presenting the client certificate of the TLS settings, sending
+// the credentials of authHeaders, splitting large downloads across the connections in
+// $NORI_DOWNLOAD_CONCURRENCY or the download_concurrency setting and assembling them in
+// stagingDir, and throttling them to the limit_rate setting. Mirrors are ranked by the
+// health in mirrorHealth.
+func proxiedFetcher(paths platform.Paths) (*fetch.Fetcher, error) {
+	fetcher := fetch.New()
+	fetcher.SetHostHealth(mirrorHealth)
+	fetcher.SetCacheDir(filepath.Join(paths.CacheDir(), "http"))
+	if api := os.Getenv("NORI_GITHUB_API_URL"); api != "" {
+		fetcher.SetGitHubAPI(api)
+	}
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		settings = &config.Settings{}
+	}
+	proxy := os.Getenv("NORI_ASSET_PROXY")
+	if proxy == "" {
+		proxy = settings.AssetProxy
+	}
+	if proxy != "" {
+		if err := fetcher.SetProxy(proxy); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Proxy != "" {
+		if err := fetcher.SetHTTPProxy(settings.Proxy, settings.NoProxy); err != nil {
+			return nil, fmt.Errorf("proxy setting: %w", err)
+		}
+	}
+	tlsConfig, err := fetch.TLSConfig(settings.CAFile, settings.ClientCert, settings.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("ca_file, client_cert or client_key setting: %w", err)
+	}
+	if tlsConfig != nil {
+		fetcher.SetTLSConfig(tlsConfig)
+	}
+	if headers := authHeaders(settings); len(headers) > 0 {
+		fetcher.SetAuth(headers)
+	}
+
+	concurrency := settings.DownloadConcurrency
+	if env := os.Getenv("NORI_DOWNLOAD_CONCURRENCY"); env != "" {
+		if concurrency, err = fetch.ParseConcurrency(env); err != nil {
+			return nil, fmt.Errorf("NORI_DOWNLOAD_CONCURRENCY: %w", err)
+		}
+	}
+	fetcher.SetConcurrency(concurrency)
+	fetcher.SetDownloadDir(stagingDir(paths, settings))
+
+	if settings.LimitRate != "" {
+		rate, err := fetch.ParseRate(settings.LimitRate)
+		if err != nil {
+			return nil, fmt.Errorf("limit_rate setting: %w", err)
+		}
+		fetcher.SetRateLimit(rate)
+	}
+	return fetcher, nil
+}
+
+// newExtractor returns an extractor that stages archives in $NORI_TMPDIR or the
+// tmp_dir setting, and otherwise beneath the nori root so installs are a rename away
+func newExtractor(paths platform.Paths) *extract.Extractor {
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		settings = &config.Settings{}
+	}
+	extractor := extract.New()
+	extractor.SetTempDir(stagingDir(paths, settings))
+	return extractor
+}
+
+// stagingDir returns where downloads and archives are staged: $NORI_TMPDIR, the tmp_dir
+// setting, or else beneath the nori root
+func stagingDir(paths platform.Paths, settings *config.Settings) string {
+	if dir := os.Getenv("NORI_TMPDIR"); dir != "" {
+		return dir
+	}
+	if settings.TmpDir != "" {
+		return settings.TmpDir
+	}
+	return paths.TmpDir()
+}
+
+// dirExists reports whether path exists and is a directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// loadPaths returns the nori paths, honoring the system_shims setting
+func loadPaths() (platform.Paths, error) {
+	paths, err := platform.DefaultPaths()
+	if err != nil {
+		return paths, err
+	}
+	if settings, err := config.New(paths).LoadSettings(); err == nil && settings.SystemShims {
+		paths.Shims = platform.SystemShimsDir()
+	}
+	return paths, nil
+}
+
+// prepareRoot creates the nori root if needed, warns about directories other users could
+// tamper with, migrates the registry cache and config written by other nori releases,
+// and hints when PATH would keep installed tools from running or upgrades are available
+func prepareRoot(ctx context.Context, c *urfavecli.Command) (context.Context, error) {
+	paths, err := loadPaths()
+	if err != nil {
+		return ctx, err
+	}
+
+	// Shims run on every tool invocation, so they stay fast and quiet
+	if c.Args().First() == "exec-shim" {
+		return ctx, nil
+	}
+
+	if _, err := os.Stat(paths.Root); os.IsNotExist(err) {
+		perm, err := platform.RootPerm()
+		if err != nil {
+			return ctx, err
+		}
+		if err := os.MkdirAll(paths.Root, perm); err != nil {
+			return ctx, fmt.Errorf("failed to create nori root: %w", err)
+		}
+	}
+	for _, dir := range looseDirs(paths) {
+		fmt.Fprintf(os.Stderr, "Warning: %s is writable by other users (run `chmod go-w %s`)\n", dir, dir)
+	}
+
+	notes, err := migrate.Run(paths)
+	if err != nil {
+		return ctx, err
+	}
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+	if c.Args().First() != "relocate" {
+		checkRelocated(paths)
+	}
+
+	// Commands that report on or fix PATH themselves don't need the hint
+	switch c.Args().First() {
+	case "init", "doctor", "completion", "exec", "run", "shell", "env", "":
+	default:
+		checkPath(paths)
+	}
+
+	// Nor do commands that already show what is behind
+	switch c.Args().First() {
+	case "outdated", "completion", "daemon", "":
+	default:
+		noticeUpgrades(paths)
+	}
+	return ctx, nil
+}
+
+// looseDirs returns the nori directories that users other than the owner can write to
+func looseDirs(paths platform.Paths) []string {
+	var dirs []string
+	for _, dir := range []string{paths.Root, paths.ConfigDir(), paths.ShimsDir()} {
+		if fsutil.WritableByOthers(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
 // detectShell detects the current shell
 func detectShell() string {
 	shell := os.Getenv("SHELL")
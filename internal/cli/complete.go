@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// completePackageArg suggests package names, then versions once "pkg@" has been typed.
+// Only local data is consulted so completion never waits on the network.
+// With installedOnly, suggestions are limited to installed packages and versions.
+func completePackageArg(installedOnly bool) urfavecli.ShellCompleteFunc {
+	return func(ctx context.Context, c *urfavecli.Command) {
+		paths, err := loadPaths()
+		if err != nil {
+			return
+		}
+		p := platform.Detect()
+		w := c.Root().Writer
+		args := c.Args().Slice()
+
+		// Bash treats "@" as a word break, so "node@" arrives as "node" "@" and
+		// only the version is completed; other shells pass "node@" as one word
+		pkgName, prefix := "", ""
+		switch {
+		case len(args) >= 2 && args[len(args)-1] == "@":
+			pkgName = args[len(args)-2]
+		case len(args) >= 1 && strings.HasSuffix(args[len(args)-1], "@"):
+			pkgName = strings.TrimSuffix(args[len(args)-1], "@")
+			prefix = args[len(args)-1]
+		}
+
+		if pkgName == "" {
+			for _, name := range completionPackages(paths, installedOnly) {
+				fmt.Fprintln(w, name)
+			}
+			return
+		}
+
+		for _, version := range completionVersions(paths, pkgName, p, installedOnly) {
+			fmt.Fprintln(w, prefix+version)
+		}
+	}
+}
+
+// completionPackages lists installed packages, or every package in the cached index
+func completionPackages(paths platform.Paths, installedOnly bool) []string {
+	var names []string
+	if installedOnly {
+		st, err := state.New(paths).Load()
+		if err != nil {
+			return nil
+		}
+		return st.Installed(platform.Detect().String())
+	}
+
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil
+	}
+	index, err := reg.CachedIndex()
+	if err != nil {
+		return nil
+	}
+	for _, pkg := range index.Packages {
+		names = append(names, pkg.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionVersions lists the versions of pkg for p, newest first
+func completionVersions(paths platform.Paths, pkg string, p platform.Platform, installedOnly bool) []string {
+	var versions []string
+	if installedOnly {
+		st, err := state.New(paths).Load()
+		if err != nil {
+			return nil
+		}
+		versions = st.Versions(pkg, p.String())
+	} else {
+		reg, err := newRegistry(paths)
+		if err != nil {
+			return nil
+		}
+		m, err := reg.CachedPackage(pkg)
+		if err != nil {
+			return nil
+		}
+		versions = m.VersionsFor(p.String())
+	}
+
+	// Newest first
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	return versions
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/provenance"
+	"github.com/chirag-bruno/nori/internal/registry"
+	urfavecli "github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigExportCommand handles the `nori config export` command. It prints the settings
+// nori runs with: config.yaml with the environment variables and flags that override it,
+// each marked with where it comes from, in a form `nori config import` reads back.
+func ConfigExportCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	settings, origins, err := effectiveSettings(c, paths)
+	if err != nil {
+		return err
+	}
+
+	// Credentials stay on this machine
+	hosts := len(settings.Auth)
+	settings.Auth = nil
+
+	var doc yaml.Node
+	if err := doc.Encode(settings); err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+	fmt.Printf("# Effective nori settings, from %s unless marked otherwise\n", paths.SettingsPath())
+	if hosts > 0 {
+		fmt.Printf("# The credentials in auth, for %d host(s), are left out\n", hosts)
+	}
+	if len(doc.Content) == 0 {
+		fmt.Println("# None are set, so every setting has its default")
+		return nil
+	}
+	for i := 0; i < len(doc.Content); i += 2 {
+		origin := origins[doc.Content[i].Value]
+		if origin == "" {
+			origin = "config.yaml"
+		}
+		doc.Content[i].LineComment = origin
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+	return enc.Close()
+}
+
+// ConfigEditCommand handles the `nori config edit` command. It opens a copy of
+// config.yaml in $VISUAL or $EDITOR and only saves it once it is valid, offering to
+// edit it again when it isn't.
+func ConfigEditCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	path := paths.SettingsPath()
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	// Edit a copy, so a mistake never reaches config.yaml
+	if err := os.MkdirAll(paths.ConfigDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	draft, err := os.CreateTemp(paths.ConfigDir(), "config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create a copy of the settings: %w", err)
+	}
+	defer os.Remove(draft.Name())
+	_, err = draft.Write(original)
+	if closeErr := draft.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create a copy of the settings: %w", err)
+	}
+
+	var edited []byte
+	for {
+		if err := runEditor(draft.Name()); err != nil {
+			return err
+		}
+		if edited, err = os.ReadFile(draft.Name()); err != nil {
+			return fmt.Errorf("failed to read the edited settings: %w", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Printf("%s is unchanged\n", path)
+			return nil
+		}
+		err = checkSettings(edited)
+		if err == nil {
+			break
+		}
+		if !interactive() {
+			return fmt.Errorf("%s left unchanged: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !confirm("Edit the settings again?") {
+			return fmt.Errorf("%s left unchanged: %w", path, err)
+		}
+	}
+
+	if err := config.New(paths).WriteSettings(edited); err != nil {
+		return err
+	}
+	fmt.Printf("Saved %s\n", path)
+	return nil
+}
+
+// ConfigImportCommand handles the `nori config import` command. It validates the
+// settings in a file, or standard input for -, and saves them over the current ones,
+// keeping the comments of config.yaml; --replace writes the file as config.yaml instead.
+func ConfigImportCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("usage: nori config import <file>")
+	}
+	source := c.Args().Get(0)
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cfg := config.New(paths)
+	settings := &config.Settings{}
+	if !c.Bool("replace") {
+		if settings, err = cfg.LoadSettings(); err != nil {
+			return fmt.Errorf("%w (use --replace to discard the current settings)", err)
+		}
+	}
+	if err := config.DecodeSettings(data, settings); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if err := validateSettings(settings); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	if c.Bool("replace") {
+		err = cfg.WriteSettings(data)
+	} else {
+		err = cfg.UpdateSettings(settings)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s into %s\n", source, paths.SettingsPath())
+	return nil
+}
+
+// effectiveSettings returns config.yaml with the environment variables and flags that
+// override settings applied, and the origin of each overridden setting by its key
+func effectiveSettings(c *urfavecli.Command, paths platform.Paths) (*config.Settings, map[string]string, error) {
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	origins := make(map[string]string)
+	if dir := os.Getenv("NORI_TMPDIR"); dir != "" {
+		settings.TmpDir = dir
+		origins["tmp_dir"] = "NORI_TMPDIR"
+	}
+	if baseURL := os.Getenv("NORI_REGISTRY_URL"); baseURL != "" {
+		settings.RegistryURL = baseURL
+		origins["registry_url"] = "NORI_REGISTRY_URL"
+	}
+	if proxy := os.Getenv("NORI_ASSET_PROXY"); proxy != "" {
+		settings.AssetProxy = proxy
+		origins["asset_proxy"] = "NORI_ASSET_PROXY"
+	}
+	if env := os.Getenv("NORI_DOWNLOAD_CONCURRENCY"); env != "" {
+		if settings.DownloadConcurrency, err = fetch.ParseConcurrency(env); err != nil {
+			return nil, nil, fmt.Errorf("NORI_DOWNLOAD_CONCURRENCY: %w", err)
+		}
+		origins["download_concurrency"] = "NORI_DOWNLOAD_CONCURRENCY"
+	}
+	if rate := c.String("limit-rate"); rate != "" {
+		settings.LimitRate = rate
+		origins["limit_rate"] = "--limit-rate"
+	}
+	if path := c.String("log-file"); path != "" {
+		settings.LogFile = path
+		origins["log_file"] = "--log-file"
+	}
+	return settings, origins, nil
+}
+
+// checkSettings reports why data isn't a config.yaml that nori can use
+func checkSettings(data []byte) error {
+	var settings config.Settings
+	if err := config.DecodeSettings(data, &settings); err != nil {
+		return err
+	}
+	return validateSettings(&settings)
+}
+
+// validateSettings returns an error for the first setting whose value nori would reject
+// when it came to use it
+func validateSettings(settings *config.Settings) error {
+	if settings.AssetProxy != "" {
+		if err := fetch.New().SetProxy(settings.AssetProxy); err != nil {
+			return fmt.Errorf("asset_proxy setting: %w", err)
+		}
+	}
+	if settings.Proxy != "" {
+		if _, err := fetch.ProxyTransport(nil, settings.Proxy, settings.NoProxy); err != nil {
+			return fmt.Errorf("proxy setting: %w", err)
+		}
+	}
+	if _, err := fetch.TLSConfig(settings.CAFile, settings.ClientCert, settings.ClientKey); err != nil {
+		return fmt.Errorf("ca_file, client_cert or client_key setting: %w", err)
+	}
+	if settings.DownloadConcurrency < 0 {
+		return fmt.Errorf("download_concurrency setting: want a number of connections, 1 or more")
+	}
+	if settings.LimitRate != "" {
+		if _, err := fetch.ParseRate(settings.LimitRate); err != nil {
+			return fmt.Errorf("limit_rate setting: %w", err)
+		}
+	}
+	if settings.UpgradeNoticeInterval != "" {
+		if interval, err := time.ParseDuration(settings.UpgradeNoticeInterval); err != nil || interval <= 0 {
+			return fmt.Errorf("upgrade_notice_interval setting: invalid duration %q, expected one such as 24h", settings.UpgradeNoticeInterval)
+		}
+	}
+	if settings.RegistryURL != "" {
+		if err := registry.ValidateURL(settings.RegistryURL); err != nil {
+			return fmt.Errorf("registry_url setting: %w", err)
+		}
+	}
+	if err := registry.ValidateSources(registrySources(settings)); err != nil {
+		return fmt.Errorf("registries setting: %w", err)
+	}
+	if err := provenance.ValidatePolicy(settings.Provenance); err != nil {
+		return fmt.Errorf("provenance setting: %w", err)
+	}
+	for _, source := range settings.Registries {
+		if err := provenance.ValidatePolicy(source.Provenance); err != nil {
+			return fmt.Errorf("provenance setting of registry %s: %w", source.Name, err)
+		}
+	}
+	for host, auth := range settings.Auth {
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return fmt.Errorf("auth setting: %q is not a host name, such as artifacts.example.com", host)
+		}
+		for name, value := range auth.Headers {
+			if name == "" || strings.ContainsAny(name, ": \t\r\n") || strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("auth setting of %s: invalid header %q", host, name)
+			}
+		}
+		if strings.ContainsAny(auth.Token, "\r\n ") {
+			return fmt.Errorf("auth setting of %s: invalid token", host)
+		}
+	}
+	for name, ps := range settings.Packages {
+		for key := range ps.Env {
+			if key == "" || strings.ContainsAny(key, "=\x00") {
+				return fmt.Errorf("env setting of %s: invalid variable name %q", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, which may include arguments such as
+// `code --wait`, falling back to notepad on Windows and vi elsewhere
+func runEditor(path string) error {
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run editor %s: %w", editor, err)
+	}
+	return nil
+}
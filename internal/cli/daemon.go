@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// minDaemonInterval keeps the daemon from polling the registry more than a few times an hour
+const minDaemonInterval = 15 * time.Minute
+
+// daemonLabel names the service in generated systemd and launchd units
+const daemonLabel = "dev.nori.daemon"
+
+// daemonEnv are the variables a generated unit carries over, when set, so the daemon
+// sees the same root, registry and proxy as the shell that generated it
+var daemonEnv = []string{"NORI_ROOT", "NORI_REGISTRY_URL", "NORI_ASSET_PROXY"}
+
+// DaemonCommand handles the `nori daemon` command. It refreshes the registry cache and
+// prefetches the latest versions of the packages in the daemon_prefetch setting every
+// --interval, until it is stopped, and meanwhile answers the JSON-RPC API on the daemon
+// socket.
+func DaemonCommand(ctx context.Context, c *urfavecli.Command) error {
+	interval := c.Duration("interval")
+	if interval < minDaemonInterval {
+		return fmt.Errorf("--interval must be at least %s to go easy on the registry", minDaemonInterval)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	d := &daemon{c: c, paths: paths}
+	if !c.Bool("once") {
+		if err := d.serveAPI(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("Serving the JSON-RPC API on %s\n", paths.DaemonSocket())
+	}
+	for {
+		err := d.refresh(ctx, 0)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if c.Bool("once") {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+
+		next := time.Now().Add(interval)
+		d.scheduled(next)
+		fmt.Printf("Next refresh at %s\n", next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// daemonCycle refreshes the registry cache once and prefetches the configured packages,
+// returning how many packages were refreshed. Mirrors that failed in an earlier cycle
+// are given another chance.
+func daemonCycle(ctx context.Context, c *urfavecli.Command, paths platform.Paths) (int, error) {
+	fmt.Printf("%s Refreshing the registry...\n", time.Now().Format(time.RFC3339))
+	mirrorHealth.Reset()
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return 0, err
+	}
+	summary, err := reg.Update(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update registry: %w", err)
+	}
+	fmt.Printf("Refreshed %d package(s)\n", summary.Packages)
+
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return summary.Packages, err
+	}
+	if len(settings.DaemonPrefetch) == 0 {
+		return summary.Packages, nil
+	}
+	return summary.Packages, prefetchLatest(ctx, c, paths, reg, settings.DaemonPrefetch)
+}
+
+// DaemonUnitCommand handles the `nori daemon unit` command. It prints a systemd user
+// service on Linux or a launchd agent on macOS that runs `nori daemon`, or with --write
+// installs it where the service manager looks for it.
+func DaemonUnitCommand(ctx context.Context, c *urfavecli.Command) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the nori executable: %w", err)
+	}
+	args := []string{exe, "daemon", "--interval", c.Duration("interval").String()}
+	if limit := c.String("limit-rate"); limit != "" {
+		args = append(args, "--limit-rate", limit)
+	}
+
+	var env []string
+	for _, name := range daemonEnv {
+		if value := os.Getenv(name); value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to find home directory: %w", err)
+	}
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+
+	var unit, path, enable string
+	switch c.String("format") {
+	case "systemd":
+		unit = systemdUnit(args, env)
+		path = filepath.Join(home, ".config", "systemd", "user", "nori-daemon.service")
+		enable = "systemctl --user daemon-reload && systemctl --user enable --now nori-daemon"
+	case "launchd":
+		unit = launchdPlist(args, env, filepath.Join(paths.Root, "daemon.log"))
+		path = filepath.Join(home, "Library", "LaunchAgents", daemonLabel+".plist")
+		enable = "launchctl load -w " + path
+	default:
+		return fmt.Errorf("invalid format %q: expected systemd or launchd", c.String("format"))
+	}
+
+	if !c.Bool("write") {
+		fmt.Print(unit)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("Wrote %s\nStart it with `%s`\n", path, enable)
+	return nil
+}
+
+// defaultUnitFormat is the service manager of this OS
+func defaultUnitFormat() string {
+	if runtime.GOOS == "darwin" {
+		return "launchd"
+	}
+	return "systemd"
+}
+
+// systemdUnit returns a user service that runs args
+func systemdUnit(args, env []string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=Keep the nori registry cache fresh\nAfter=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	for _, kv := range env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv))
+	}
+	b.WriteString("Restart=on-failure\nRestartSec=5min\nNice=10\n\n")
+	b.WriteString("[Install]\nWantedBy=default.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes s for a systemd unit file when it needs it
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"\\'") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// launchdPlist returns a launch agent that runs args, logging to logPath
+func launchdPlist(args, env []string, logPath string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", daemonLabel)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	if len(env) > 0 {
+		b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+		for _, kv := range env {
+			name, value, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", name, xmlEscape(value))
+		}
+		b.WriteString("\t</dict>\n")
+	}
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	b.WriteString("\t<key>ProcessType</key>\n\t<string>Background</string>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", xmlEscape(logPath))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", xmlEscape(logPath))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// xmlEscape escapes s for XML character data
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
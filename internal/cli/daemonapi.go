@@ -0,0 +1,305 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// minAPIRefreshGap is how long after a refresh the API's refresh method refuses another
+const minAPIRefreshGap = time.Minute
+
+// maxRPCRequest bounds the size of one request line
+const maxRPCRequest = 1 << 20
+
+// rpcRequest is a JSON-RPC 2.0 request; one without an ID is a notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response, holding either a result or an error
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed JSON-RPC call
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// daemon is what `nori daemon` shares between its refresh loop and its API
+type daemon struct {
+	c     *urfavecli.Command
+	paths platform.Paths
+
+	cycle sync.Mutex // held while a refresh runs
+
+	mu          sync.Mutex // guards the fields below
+	lastRefresh time.Time
+	nextRefresh time.Time
+	packages    int
+	lastErr     error
+}
+
+// daemonStatus is the result of the status method
+type daemonStatus struct {
+	Root        string     `json:"root"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	NextRefresh *time.Time `json:"next_refresh,omitempty"`
+	Packages    int        `json:"packages"` // refreshed by the last refresh
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// searchResult is one result of the search method
+type searchResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Latest      string `json:"latest,omitempty"`
+	Registry    string `json:"registry"`
+}
+
+// installedPackage is one result of the installed method
+type installedPackage struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+	Active   string   `json:"active,omitempty"`
+}
+
+// refresh runs a daemon cycle and records how it went, unless the last one ended less
+// than minGap ago
+func (d *daemon) refresh(ctx context.Context, minGap time.Duration) error {
+	d.cycle.Lock()
+	defer d.cycle.Unlock()
+
+	d.mu.Lock()
+	since := time.Since(d.lastRefresh)
+	d.mu.Unlock()
+	if minGap > 0 && since < minGap {
+		return &rpcError{rpcServerError, fmt.Sprintf("the registry was refreshed %s ago; try again in %s", since.Round(time.Second), (minGap - since).Round(time.Second))}
+	}
+
+	packages, err := daemonCycle(ctx, d.c, d.paths)
+	d.mu.Lock()
+	d.lastRefresh, d.packages, d.lastErr = time.Now(), packages, err
+	d.mu.Unlock()
+	return err
+}
+
+// scheduled records when the refresh loop runs next
+func (d *daemon) scheduled(next time.Time) {
+	d.mu.Lock()
+	d.nextRefresh = next
+	d.mu.Unlock()
+}
+
+// status reports the daemon's refreshes
+func (d *daemon) status() daemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := daemonStatus{Root: d.paths.Root, Packages: d.packages}
+	if !d.lastRefresh.IsZero() {
+		last := d.lastRefresh
+		status.LastRefresh = &last
+	}
+	if !d.nextRefresh.IsZero() {
+		next := d.nextRefresh
+		status.NextRefresh = &next
+	}
+	if d.lastErr != nil {
+		status.LastError = d.lastErr.Error()
+	}
+	return status
+}
+
+// serveAPI answers JSON-RPC 2.0 requests, one JSON object per line, on the daemon socket
+// until ctx is done
+func (d *daemon) serveAPI(ctx context.Context) error {
+	sock := d.paths.DaemonSocket()
+	// A socket left by a daemon that didn't exit cleanly is replaced, but not one in use
+	if conn, err := net.Dial("unix", sock); err == nil {
+		conn.Close()
+		return fmt.Errorf("another nori daemon is listening on %s", sock)
+	}
+	os.Remove(sock)
+	if err := os.MkdirAll(d.paths.Root, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", d.paths.Root, err)
+	}
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", sock, err)
+	}
+	// Only the user the daemon runs as may call it
+	if err := os.Chmod(sock, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to restrict %s: %w", sock, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serveConn(ctx, conn)
+		}
+	}()
+	return nil
+}
+
+// serveConn answers the requests on conn until the caller hangs up
+func (d *daemon) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxRPCRequest)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if resp := d.handle(ctx, line); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle answers one request, or returns nil for a notification
+func (d *daemon) handle(ctx context.Context, line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, "parse error: " + err.Error()}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{rpcInvalidRequest, `invalid request: expected "jsonrpc": "2.0" and a method`}}
+	}
+
+	result, err := d.call(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{rpcServerError, err.Error()}
+		}
+		resp.Error = rerr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// call runs an API method
+func (d *daemon) call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "status":
+		return d.status(), nil
+	case "refresh":
+		if err := d.refresh(ctx, minAPIRefreshGap); err != nil {
+			return nil, err
+		}
+		return d.status(), nil
+	case "search":
+		var p struct {
+			Query string `json:"query"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return d.search(ctx, p.Query)
+	case "installed":
+		return d.installed()
+	case "outdated":
+		rows, _, err := outdatedPackages(d.paths)
+		if rows == nil {
+			rows = []outdatedPackage{}
+		}
+		return rows, err
+	}
+	return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("method %q not found", method)}
+}
+
+// decodeParams decodes the params of a call into v, if there are any
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{rpcInvalidParams, "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// search finds packages as `nori search` does
+func (d *daemon) search(ctx context.Context, query string) ([]searchResult, error) {
+	reg, err := newRegistry(d.paths)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := reg.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]searchResult, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		results = append(results, searchResult{Name: pkg.Name, Description: pkg.Description, Latest: pkg.Latest, Registry: pkg.Registry})
+	}
+	return results, nil
+}
+
+// installed lists the installed packages for this platform, with their active versions
+func (d *daemon) installed() ([]installedPackage, error) {
+	st, err := state.New(d.paths).Load()
+	if err != nil {
+		return nil, err
+	}
+	plat := platform.Detect().String()
+	names := st.Installed(plat)
+	pkgs := make([]installedPackage, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, installedPackage{Name: name, Versions: st.Versions(name, plat), Active: st.Active(name)})
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// Markers around the block `nori direnv` manages in .envrc
+const (
+	envrcBegin = "# nori:begin - managed by `nori direnv`, changes here are overwritten"
+	envrcEnd   = "# nori:end"
+)
+
+// DirenvCommand handles the `nori direnv` command. It adds a block to .envrc that puts
+// the bin directories of the project's pinned versions on PATH, so direnv users get
+// them without shims. With --export it prints the exports the block evaluates.
+func DirenvCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	if c.Bool("export") {
+		exports, err := direnvExports(ctx, paths, cwd)
+		if err != nil {
+			return err
+		}
+		fmt.Print(exports)
+		return nil
+	}
+
+	files, err := project.Find(cwd)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no %s or %s file found; pin versions first, e.g. with `nori init --project`", project.FileName, project.ProjectFileName)
+	}
+
+	// Reload whenever a version file changes, including ones further up the tree
+	var block strings.Builder
+	block.WriteString(envrcBegin + "\n")
+	for _, f := range files {
+		watch := f.Path
+		if rel, err := filepath.Rel(cwd, f.Path); err == nil {
+			watch = filepath.ToSlash(rel)
+		}
+		fmt.Fprintf(&block, "watch_file %s\n", shQuote(watch))
+	}
+	block.WriteString("eval \"$(nori direnv --export)\"\n")
+	block.WriteString(envrcEnd + "\n")
+
+	envrc := filepath.Join(cwd, ".envrc")
+	updated, err := writeEnvrcBlock(envrc, block.String())
+	if err != nil {
+		return err
+	}
+	if updated {
+		fmt.Printf("Updated the nori block in %s\n", envrc)
+	} else {
+		fmt.Printf("Added nori to %s\n", envrc)
+	}
+	fmt.Println("Run `direnv allow` to load it")
+	return nil
+}
+
+// direnvLib is a direnv extension defining `use nori`, printed by `nori env --direnv`.
+// Without arguments it watches the version files from the working directory up and puts
+// the pinned versions on PATH, as the block `nori direnv` writes does; with packages it
+// uses those instead, installing them if needed.
+var direnvLib = `# nori extension for direnv: save as ~/.config/direnv/lib/nori.sh, then put
+# "use nori" or "use nori <package>@<version>..." in .envrc
+use_nori() {
+  if [ "$#" -gt 0 ]; then
+    eval "$(nori env --shell bash "$@")"
+    return
+  fi
+  local dir=$PWD
+  while :; do
+    watch_file "${dir%/}/` + project.FileName + `" "${dir%/}/` + project.ProjectFileName + `" "${dir%/}/` + project.ToolVersionsName + `"
+    [ "$dir" = / ] && break
+    dir=$(dirname "$dir")
+  done
+  eval "$(nori direnv --export)"
+}
+`
+
+// writeEnvrcBlock replaces the nori block in the .envrc at path, or appends one, keeping
+// everything else in the file. It reports whether an existing block was replaced.
+func writeEnvrcBlock(path, block string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content := string(data)
+
+	updated := false
+	start := strings.Index(content, envrcBegin)
+	end := strings.Index(content, envrcEnd)
+	if start >= 0 && end > start {
+		end += len(envrcEnd)
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		content = content[:start] + block + content[end:]
+		updated = true
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		content += block
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return updated, nil
+}
+
+// direnvExports returns shell exports prepending the bin directories of the versions
+// pinned for dir to PATH and setting their env: what each manifest declares, then the
+// package's settings. Globally active versions are left to the shims.
+func direnvExports(ctx context.Context, paths platform.Paths, dir string) (string, error) {
+	result, err := project.ResolveCached(paths, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve versions: %w", err)
+	}
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(result.Versions))
+	for name, res := range result.Versions {
+		if res.Source != paths.ActiveConfigPath() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	plat := platform.Detect().String()
+	env := &packagesEnv{}
+	for _, name := range names {
+		version := result.Versions[name].Version
+		installPath := paths.InstallPath(name, version, plat)
+		if !dirExists(installPath) {
+			fmt.Fprintf(os.Stderr, "nori: %s@%s is not installed; run `nori install %s@%s`\n", name, version, name, version)
+			continue
+		}
+		bins, err := installedBins(ctx, paths, name, version, plat)
+		if err != nil {
+			return "", err
+		}
+		env.binDirs = append(env.binDirs, install.BinDirs(installPath, bins)...)
+		vars, err := installedEnv(ctx, paths, name, version, plat)
+		if err != nil {
+			return "", err
+		}
+		env.vars = append(env.vars, vars...)
+		env.vars = append(env.vars, sortedVars(settings.Package(name).Env)...)
+	}
+	return posixEnv(env), nil
+}
+
+// shQuote quotes s for a POSIX shell
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
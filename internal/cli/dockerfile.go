@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// containerRoot is where the generated snippets install nori's root inside an image
+const containerRoot = "/opt/nori"
+
+// DockerfileCommand handles the `nori dockerfile` command. It prints Dockerfile lines
+// that install the given versions, or the ones pinned for this directory, by archive
+// digest, so an image gets byte-for-byte the same toolchains as the developer machine.
+func DockerfileCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+
+	plat := c.String("platform")
+	if strings.Count(plat, "-") != 1 {
+		return fmt.Errorf("invalid platform %q: expected <os>-<arch>, e.g. linux-arm64", plat)
+	}
+
+	pins, err := dockerfilePins(paths, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	// Pin by digest so the image fails to build rather than silently getting other bits
+	var installs []string
+	for _, pin := range pins {
+		name, version, _ := strings.Cut(pin, "@")
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to load package: %w", err)
+		}
+		if m.IsGroup() {
+			return fmt.Errorf("%s is a package group; list its members instead", name)
+		}
+		if version == "" {
+			version = m.LatestVersion()
+		}
+		asset, err := m.GetAsset(version, plat)
+		if err != nil {
+			return err
+		}
+
+		ref := name + "@" + asset.Checksum
+		if asset.Checksum == "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s@%s is a rolling channel and cannot be pinned by digest\n", name, version)
+			ref = name + "@" + version
+		}
+		installs = append(installs, fmt.Sprintf("nori install %s --use", ref))
+	}
+
+	// The nori binary comes from the build context or from another image
+	copyNori := "COPY nori /usr/local/bin/nori"
+	if from := c.String("copy-from"); from != "" {
+		copyNori = fmt.Sprintf("COPY --from=%s /usr/local/bin/nori /usr/local/bin/nori", from)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by `nori dockerfile` for %s: %s\n", plat, strings.Join(pins, " "))
+	if c.Bool("multi-stage") {
+		fmt.Fprintf(&b, "FROM %s AS nori-tools\n", c.String("base"))
+	}
+	fmt.Fprintf(&b, "%s\n", copyNori)
+	fmt.Fprintf(&b, "ENV NORI_ROOT=%s\n", containerRoot)
+	fmt.Fprintf(&b, "ENV PATH=%s/shims:$PATH\n", containerRoot)
+	fmt.Fprintf(&b, "RUN nori update \\\n && %s\n", strings.Join(installs, " \\\n && "))
+	if c.Bool("multi-stage") {
+		b.WriteString("\n# In the final stage:\n")
+		fmt.Fprintf(&b, "# COPY --from=nori-tools %s %s\n", containerRoot, containerRoot)
+		fmt.Fprintf(&b, "# ENV PATH=%s/shims:$PATH\n", containerRoot)
+	}
+
+	fmt.Print(b.String())
+	return nil
+}
+
+// dockerfilePins returns the pkg@version arguments, or the versions pinned for the
+// current directory when there are none. A package named without a version gets the
+// version pinned here, if any, and otherwise the latest one.
+func dockerfilePins(paths platform.Paths, args []string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	result, err := project.Resolve(paths, cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve versions: %w", err)
+	}
+
+	if len(args) > 0 {
+		pins := make([]string, len(args))
+		for i, arg := range args {
+			pins[i] = arg
+			if res, ok := result.Versions[arg]; ok && !strings.Contains(arg, "@") && res.Source != paths.ActiveConfigPath() {
+				pins[i] = arg + "@" + res.Version
+			}
+		}
+		return pins, nil
+	}
+
+	var pins []string
+	for name, res := range result.Versions {
+		if res.Source != paths.ActiveConfigPath() {
+			pins = append(pins, name+"@"+res.Version)
+		}
+	}
+	if len(pins) == 0 {
+		return nil, fmt.Errorf("no versions pinned here: name packages as <package>@<version> or add a %s file", project.FileName)
+	}
+	sort.Strings(pins)
+	return pins, nil
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// DoctorCommand handles the `nori doctor` command. It checks the environment nori
+// depends on and, with --all, also verifies every installation against its receipt.
+func DoctorCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
+	rep := newReport("doctor", c.Bool("json"))
+	cfg := config.New(paths)
+
+	check := func(ok bool, msg string) {
+		rep.Checked++
+		mark := activeStyle.Render("✓")
+		if !ok {
+			mark = staleStyle.Render("✗")
+		}
+		fmt.Fprintf(rep.out, "%s %s\n", mark, msg)
+	}
+
+	check(true, "nori root: "+paths.Root)
+
+	// Shims directory and PATH
+	shimsDir := paths.ShimsDir()
+	if dirExists(shimsDir) {
+		check(true, "shims directory exists: "+shimsDir)
+	} else {
+		check(false, "shims directory is missing: "+shimsDir+" (run `nori init`)")
+		rep.add(ExitMisconfigured, Finding{Path: shimsDir, Message: "shims directory is missing"})
+	}
+	if onPath(shimsDir) {
+		check(true, "shims directory is on PATH")
+	} else {
+		check(false, "shims directory is not on PATH (run `nori init`)")
+		rep.add(ExitMisconfigured, Finding{Path: shimsDir, Message: "shims directory is not on PATH"})
+	}
+	if bin, found := shadowedShim(shimsDir); bin != "" {
+		check(false, fmt.Sprintf("%s runs %s instead of nori's shim (move the shims directory earlier in PATH)", bin, found))
+		rep.add(ExitMisconfigured, Finding{Path: found, Message: "shadows the " + bin + " shim"})
+	} else {
+		check(true, "no shims are shadowed by earlier PATH entries")
+	}
+
+	// What keeps shims from running on Windows, with the command that fixes each
+	if runtime.GOOS == "windows" {
+		layout := "per-user shims"
+		if paths.Shims != "" {
+			layout = "system shims on the machine PATH"
+		}
+		check(true, "layout: "+layout)
+		for _, problem := range windowsProblems(readWindowsSetup(paths)) {
+			check(false, problem.message)
+			fmt.Fprintf(rep.out, "    %s\n", problem.fix)
+			rep.add(ExitMisconfigured, Finding{Path: problem.path, Message: problem.message, Fix: problem.fix})
+		}
+	}
+
+	// Directories other users could use to swap out tools
+	if loose := looseDirs(paths); len(loose) > 0 {
+		for _, dir := range loose {
+			check(false, dir+" is writable by other users (run `chmod go-w "+dir+"`)")
+			rep.add(ExitMisconfigured, Finding{Path: dir, Message: "writable by other users"})
+		}
+	} else {
+		check(true, "nori directories are not writable by other users")
+	}
+
+	// Configuration files
+	settings, err := cfg.LoadSettings()
+	if err == nil {
+		err = validateSettings(settings)
+	}
+	if err != nil {
+		check(false, "settings: "+err.Error())
+		rep.add(ExitMisconfigured, Finding{Path: paths.SettingsPath(), Message: err.Error()})
+	} else {
+		check(true, "settings are readable")
+		if len(settings.Auth) > 0 {
+			exposed := false
+			for _, path := range []string{paths.SettingsPath(), paths.ConfigDir()} {
+				if fsutil.ReadableByOthers(path) {
+					exposed = true
+					check(false, path+" holds credentials but is readable by other users (run `chmod go-rwx "+path+"`)")
+					rep.add(ExitMisconfigured, Finding{Path: path, Message: "holds credentials but is readable by other users"})
+				}
+			}
+			if !exposed {
+				check(true, "credentials are not readable by other users")
+			}
+		}
+	}
+	active, err := cfg.ListActive()
+	if err != nil {
+		check(false, "active versions: "+err.Error())
+		rep.add(ExitMisconfigured, Finding{Path: paths.ActiveConfigPath(), Message: err.Error()})
+	}
+
+	// Every active version is installed and shimmed
+	names := make([]string, 0, len(active))
+	for name := range active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		before := len(rep.Findings)
+		status := checkActive(paths, reg, p, name, active[name], rep)
+		check(len(rep.Findings) == before, fmt.Sprintf("%s@%s: %s", name, active[name], status))
+	}
+
+	// The state index agrees with the installs on disk
+	store := state.New(paths)
+	st, err := store.Load()
+	if err != nil {
+		return err
+	}
+	scanned, err := store.Scan()
+	if err != nil {
+		return err
+	}
+	if !sameInstalls(st, scanned, p) {
+		check(false, "state index is out of date (run `nori state rebuild`)")
+		rep.add(ExitMisconfigured, Finding{Path: paths.StatePath(), Message: "state index does not match the installs on disk"})
+	} else {
+		check(true, "state index matches the installs on disk")
+	}
+
+	// Shims whose target has disappeared, and shims that run a fixed version
+	shim := shims.New(shimsDir)
+	entries, _ := os.ReadDir(shimsDir)
+	dangling, fixed := 0, 0
+	for _, entry := range entries {
+		binName, ok := shims.BinName(entry.Name())
+		if !ok {
+			continue
+		}
+		target, err := shim.Target(binName)
+		if err != nil {
+			continue
+		}
+		dynamic := shim.Dynamic(binName)
+		if _, err := os.Stat(target); err != nil {
+			dangling++
+			msg := "shim target does not exist: " + target
+			if dynamic {
+				msg = "shim runs a nori executable that no longer exists: " + target + " (run `nori reshim`)"
+			}
+			rep.add(ExitMissing, Finding{Path: binName, Message: msg})
+		} else if !dynamic {
+			fixed++
+			rep.add(ExitMisconfigured, Finding{Path: binName, Message: "shim always runs " + target + ", ignoring project versions (run `nori reshim`)"})
+		}
+	}
+	check(dangling == 0, fmt.Sprintf("%d dangling shim(s)", dangling))
+	check(fixed == 0, fmt.Sprintf("%d shim(s) from an earlier nori run a fixed version (run `nori reshim`)", fixed))
+
+	if c.Bool("all") {
+		fmt.Fprintln(rep.out)
+		for _, target := range allInstalls(scanned, p) {
+			if err := verifyVersion(ctx, paths, target[0], target[1], p, false, rep); err != nil {
+				return err
+			}
+		}
+	}
+
+	return rep.finish()
+}
+
+// sameInstalls reports whether a and b record the same installed versions for p
+func sameInstalls(a, b *state.State, p platform.Platform) bool {
+	names := a.Installed(p.String())
+	if !slices.Equal(names, b.Installed(p.String())) {
+		return false
+	}
+	for _, name := range names {
+		if !slices.Equal(a.Versions(name, p.String()), b.Versions(name, p.String())) {
+			return false
+		}
+	}
+	return true
+}
+
+// onPath reports whether dir is listed in $PATH
+func onPath(dir string) bool {
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if samePath(entry, dir) {
+			return true
+		}
+	}
+	return false
+}
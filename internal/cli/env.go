@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/project"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// EnvCommand handles `nori env [<package>[@<version>]...]`. It prints the commands that
+// put the packages' bin directories first on PATH and set their env, for the shell given
+// with --shell or the user's own, so `eval "$(nori env)"` uses them without shims.
+// Without packages, it uses the versions pinned for the current directory. Versions that
+// aren't installed are installed first, without activating them. With --direnv, it
+// prints a direnv extension defining `use nori` instead.
+func EnvCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.Bool("direnv") {
+		fmt.Print(direnvLib)
+		return nil
+	}
+
+	shell := c.String("shell")
+	if shell == "" {
+		shell = detectShell()
+	}
+	format, ok := envFormats[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q: use bash, zsh, fish or pwsh", shell)
+	}
+
+	pkgs := c.Args().Slice()
+	if len(pkgs) == 0 {
+		var err error
+		if pkgs, err = pinnedPackages(); err != nil {
+			return err
+		}
+	}
+	env, err := resolvePackagesEnv(ctx, c, pkgs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(format(env))
+	return nil
+}
+
+// pinnedPackages returns <package>@<version> for each version pinned for the current
+// directory. Globally active versions are left to the shims.
+func pinnedPackages() ([]string, error) {
+	paths, err := loadPaths()
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	result, err := project.ResolveCached(paths, cwd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve versions: %w", err)
+	}
+
+	var pkgs []string
+	for name, res := range result.Versions {
+		if res.Source != paths.ActiveConfigPath() {
+			pkgs = append(pkgs, name+"@"+res.Version)
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no versions are pinned for %s; name packages or pin some with `nori local`", cwd)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// envFormats render a packagesEnv as commands for each shell
+var envFormats = map[string]func(*packagesEnv) string{
+	"bash":       posixEnv,
+	"zsh":        posixEnv,
+	"fish":       fishEnv,
+	"pwsh":       pwshEnv,
+	"powershell": pwshEnv,
+}
+
+// posixEnv renders env for bash, zsh and other POSIX shells
+func posixEnv(env *packagesEnv) string {
+	var b strings.Builder
+	if len(env.binDirs) > 0 {
+		fmt.Fprintf(&b, "export PATH=%s%c\"$PATH\"\n", shQuote(strings.Join(env.binDirs, string(os.PathListSeparator))), os.PathListSeparator)
+	}
+	for _, kv := range env.vars {
+		fmt.Fprintf(&b, "export %s=%s\n", kv[0], shQuote(kv[1]))
+	}
+	return b.String()
+}
+
+// fishEnv renders env for fish, whose PATH is a list
+func fishEnv(env *packagesEnv) string {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	var b strings.Builder
+	if len(env.binDirs) > 0 {
+		b.WriteString("set -gx PATH")
+		for _, dir := range env.binDirs {
+			b.WriteString(" " + quote(dir))
+		}
+		b.WriteString(" $PATH\n")
+	}
+	for _, kv := range env.vars {
+		fmt.Fprintf(&b, "set -gx %s %s\n", kv[0], quote(kv[1]))
+	}
+	return b.String()
+}
+
+// pwshEnv renders env for PowerShell
+func pwshEnv(env *packagesEnv) string {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	var b strings.Builder
+	if len(env.binDirs) > 0 {
+		fmt.Fprintf(&b, "$env:PATH = %s + [IO.Path]::PathSeparator + $env:PATH\n", quote(strings.Join(env.binDirs, string(os.PathListSeparator))))
+	}
+	for _, kv := range env.vars {
+		fmt.Fprintf(&b, "$env:%s = %s\n", kv[0], quote(kv[1]))
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/events"
+	"github.com/chirag-bruno/nori/internal/platform"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// logEvents wraps the actions of cmd and its subcommands so that each run is recorded
+// in the event log, when one is configured
+func logEvents(cmd *urfavecli.Command) {
+	// Shims aren't logged; they run the tool, not a nori command
+	if cmd.Name == "exec-shim" {
+		return
+	}
+	if action := cmd.Action; action != nil {
+		cmd.Action = func(ctx context.Context, c *urfavecli.Command) error {
+			return runLogged(ctx, c, action)
+		}
+	}
+	for _, sub := range cmd.Commands {
+		logEvents(sub)
+	}
+}
+
+// runLogged runs action with an event log from --log-file or the log_file setting in its
+// context, bracketed by command_start and command_end events
+func runLogged(ctx context.Context, c *urfavecli.Command, action urfavecli.ActionFunc) error {
+	path := c.String("log-file")
+	if path == "" {
+		if paths, err := platform.DefaultPaths(); err == nil {
+			if settings, err := config.New(paths).LoadSettings(); err == nil {
+				path = settings.LogFile
+			}
+		}
+	}
+	if path == "" {
+		return action(ctx, c)
+	}
+
+	log, err := events.Open(path, c.FullName())
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	start := time.Now()
+	log.Emit(events.Event{Event: events.CommandStart, Args: c.Args().Slice()})
+
+	err = action(events.NewContext(ctx, log), c)
+
+	end := events.Event{Event: events.CommandEnd, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		end.Error = err.Error()
+		end.ExitCode = 1
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			end.ExitCode = exitErr.Code
+		}
+	}
+	log.Emit(end)
+	return err
+}
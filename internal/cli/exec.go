@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// ExecCommand handles `nori exec <package>[@<version>]... -- <command> [<arg>...]`. It
+// installs each package if needed, without activating it or touching shims, and runs
+// command with the packages' bin directories first on PATH and their env set. Without
+// `--`, the first argument is the only package.
+func ExecCommand(ctx context.Context, c *urfavecli.Command) error {
+	args := c.Args().Slice()
+	var pkgs, command []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		pkgs, command = args[:i], args[i+1:]
+	} else if len(args) > 0 {
+		pkgs, command = args[:1], args[1:]
+	}
+	if len(pkgs) == 0 || len(command) == 0 {
+		return fmt.Errorf("usage: nori exec <package>[@<version>]... -- <command> [<arg>...]")
+	}
+
+	if _, err := usePackages(ctx, c, pkgs); err != nil {
+		return err
+	}
+	binPath, err := exec.LookPath(command[0])
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", command[0], err)
+	}
+	return execBinary(binPath, command[1:])
+}
+
+// packagesEnv is the environment that a set of packages runs with
+type packagesEnv struct {
+	used    []string    // <package>@<version> of each package
+	binDirs []string    // to put first on PATH
+	vars    [][2]string // in the order they apply, so a later value of a name wins
+}
+
+// resolvePackagesEnv installs each package if needed, without activating it, and
+// returns the environment they run with. The env a manifest declares comes before the
+// package's settings, so the settings can override it.
+func resolvePackagesEnv(ctx context.Context, c *urfavecli.Command, pkgs []string) (*packagesEnv, error) {
+	paths, err := loadPaths()
+	if err != nil {
+		return nil, err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, err
+	}
+	plat := platform.Detect().String()
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	env := &packagesEnv{}
+	for _, pkg := range pkgs {
+		m, version, err := resolveApplyEntry(ctx, reg, settings, pkg, plat)
+		if err != nil {
+			return nil, err
+		}
+		installPath, err := ensureInstalled(ctx, c, paths, m, version, state.Reason{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s@%s: %w", m.Name, version, err)
+		}
+		for _, bin := range m.Bins {
+			if dir := filepath.Dir(filepath.Join(installPath, bin)); !slices.Contains(env.binDirs, dir) {
+				env.binDirs = append(env.binDirs, dir)
+			}
+		}
+		env.vars = append(env.vars, sortedVars(m.EnvFor(installPath))...)
+		env.vars = append(env.vars, sortedVars(settings.Package(m.Name).Env)...)
+		env.used = append(env.used, m.Name+"@"+version)
+	}
+	return env, nil
+}
+
+// usePackages installs each package if needed, without activating it, puts their bin
+// directories first on PATH and adds their env to nori's environment, so commands nori
+// runs inherit them. It returns the <package>@<version> of each.
+func usePackages(ctx context.Context, c *urfavecli.Command, pkgs []string) ([]string, error) {
+	env, err := resolvePackagesEnv(ctx, c, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	if err := setVars(env.vars); err != nil {
+		return nil, err
+	}
+	pathList := append(env.binDirs, filepath.SplitList(os.Getenv("PATH"))...)
+	if err := os.Setenv("PATH", strings.Join(pathList, string(os.PathListSeparator))); err != nil {
+		return nil, fmt.Errorf("failed to set PATH: %w", err)
+	}
+	return env.used, nil
+}
+
+// setVars adds vars to nori's environment, in order
+func setVars(vars [][2]string) error {
+	for _, kv := range vars {
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return fmt.Errorf("failed to set %s: %w", kv[0], err)
+		}
+	}
+	return nil
+}
+
+// sortedVars returns the variables in env sorted by name
+func sortedVars(env map[string]string) [][2]string {
+	vars := make([][2]string, 0, len(env))
+	for key, value := range env {
+		vars = append(vars, [2]string{key, value})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i][0] < vars[j][0] })
+	return vars
+}
+
+// ensureInstalled installs version of m unless it already is, without activating it,
+// recording reason as the reason it was installed, and returns its install path.
+// Progress goes to stderr, leaving stdout to the command.
+func ensureInstalled(ctx context.Context, c *urfavecli.Command, paths platform.Paths, m *manifest.Manifest, version string, reason state.Reason) (string, error) {
+	p := platform.Detect()
+	if err := manifest.ValidateVersion(m, version, p.String()); err != nil {
+		return "", err
+	}
+	installPath := paths.InstallPath(m.Name, version, p.String())
+	if dirExists(installPath) {
+		return installPath, nil
+	}
+
+	asset, err := m.GetAsset(version, p.String())
+	if err != nil {
+		return "", err
+	}
+	if asset, err = hostBuild(m, version, asset); err != nil {
+		return "", err
+	}
+	source, err := manifestSource(paths, m)
+	if err != nil {
+		return "", err
+	}
+	if err := resolveAsset(ctx, c, paths, m, version, asset); err != nil {
+		return "", err
+	}
+	display := &stepDisplay{w: os.Stderr, name: m.Name + "@" + version}
+	plan := &installPlan{m: m, source: source, version: version, asset: asset, platform: p}
+	installPath, err = fetchAndInstall(ctx, c, paths, plan, display)
+	if err != nil {
+		return "", err
+	}
+	if err := checkInstall(ctx, paths, plan, installPath); err != nil {
+		return "", err
+	}
+	display.Status("Installed")
+	require(paths, m.Name, version, reason)
+	return installPath, nil
+}
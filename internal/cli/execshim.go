@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// ExecShimCommand handles `nori exec-shim <bin> [args...]`, which every shim runs. It
+// finds the package whose active version provides bin, resolves the version in effect
+// for the working directory as `nori which` does, and runs that version's binary in
+// place of nori, with the env its manifest declares and then the env from the package's
+// settings.
+func ExecShimCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori exec-shim <binary> [<arg>...]")
+	}
+	args := c.Args().Slice()
+	binName := args[0]
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	plat := platform.Detect().String()
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return err
+	}
+	pkgName, _ := st.Provides(binName, plat)
+	if pkgName == "" {
+		return fmt.Errorf("no active package provides %s; remove the stale shim with `nori reshim`", binName)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	result, err := project.ResolveCached(paths, cwd)
+	if err != nil {
+		return fmt.Errorf("failed to resolve versions: %w", err)
+	}
+	res, ok := result.Versions[pkgName]
+	if !ok {
+		return fmt.Errorf("package %s has no active version", pkgName)
+	}
+
+	inst := st.Find(pkgName, res.Version, plat)
+	if inst == nil {
+		return fmt.Errorf("%s@%s is selected by %s but not installed; run `nori install %s@%s`", pkgName, res.Version, res.Source, pkgName, res.Version)
+	}
+	var binPath string
+	for _, bin := range inst.Bins {
+		if filepath.Base(bin) == binName {
+			binPath = filepath.Join(paths.InstallPath(pkgName, res.Version, plat), bin)
+			break
+		}
+	}
+	if binPath == "" {
+		return fmt.Errorf("%s@%s, selected by %s, has no %s binary", pkgName, res.Version, res.Source, binName)
+	}
+	if runtime.GOOS == "windows" && filepath.Ext(binPath) != ".exe" {
+		if _, err := os.Stat(binPath + ".exe"); err == nil {
+			binPath += ".exe"
+		}
+	}
+
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return err
+	}
+	if err := setVars(sortedVars(manifest.ExpandEnv(inst.Env, paths.InstallPath(pkgName, res.Version, plat)))); err != nil {
+		return err
+	}
+	if err := setPackageEnv(settings, pkgName); err != nil {
+		return err
+	}
+	return execBinary(binPath, args[1:])
+}
+
+// ReshimCommand handles the `nori reshim` command. It rewrites the shim of every bin of
+// every active package to run this nori, converting the fixed shims of earlier releases
+// and repairing shims after nori itself has moved, and removes shims no active package
+// provides.
+func ReshimCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	rewrote, removed, err := reshim(paths, platform.Detect())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rewrote %d shim(s), removed %d stale\n", rewrote, removed)
+	return nil
+}
+
+// reshim rewrites the shims of every active package and removes the rest, returning
+// how many shims it rewrote and removed
+func reshim(paths platform.Paths, p platform.Platform) (rewrote, removed int, err error) {
+	pkgs, missing, err := activePackages(paths, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, ref := range missing {
+		fmt.Fprintf(os.Stderr, "Warning: skipping %s: the active version is not installed\n", ref)
+	}
+
+	shim := shims.New(paths.ShimsDir())
+	wanted := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if err := shim.UpdateShims(pkg.name, pkg.version, pkg.bins, paths.InstallPath(pkg.name, pkg.version, p.String())); err != nil {
+			return 0, 0, fmt.Errorf("failed to update shims for %s: %w", pkg.name, err)
+		}
+		for _, bin := range pkg.bins {
+			wanted[filepath.Base(bin)] = true
+		}
+	}
+
+	// Shims left behind by packages that are no longer active
+	var stale []string
+	entries, _ := os.ReadDir(paths.ShimsDir())
+	for _, entry := range entries {
+		name, ok := shims.BinName(entry.Name())
+		if !ok {
+			continue
+		}
+		if !wanted[name] && !strings.HasPrefix(name, ".") {
+			stale = append(stale, name)
+		}
+	}
+	if err := shim.RemoveShims(stale); err != nil {
+		return 0, 0, err
+	}
+	return len(wanted), len(stale), nil
+}
+
+// execBinary replaces nori with the binary at path. Windows can't replace a process, so
+// there the binary runs as a child and nori exits with its status.
+func execBinary(path string, args []string) error {
+	if runtime.GOOS != "windows" {
+		err := syscall.Exec(path, append([]string{path}, args...), os.Environ())
+		return fmt.Errorf("failed to run %s: %w", path, err)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", path, err)
+	}
+	os.Exit(0)
+	return nil
+}
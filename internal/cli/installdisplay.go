@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+// redrawInterval throttles how often a multiDisplay repaints the terminal
+const redrawInterval = 100 * time.Millisecond
+
+// installDisplay shows the progress of one install as fetchAndInstall works through it
+type installDisplay interface {
+	// Status reports a step without progress of its own, such as using a cached archive
+	Status(msg string)
+	// StartDownload begins a download of total bytes (0 if unknown) and returns where to write progress
+	StartDownload(total int64) io.Writer
+	EndDownload()
+	// Extracted reports the number of files extracted so far
+	Extracted(files int)
+	EndExtract()
+}
+
+// barDisplay shows one install at a time with a progress bar per step
+type barDisplay struct {
+	download *ProgressBar
+	extract  *FileProgressBar
+}
+
+func (d *barDisplay) Status(msg string) {
+	fmt.Println(msg)
+}
+
+func (d *barDisplay) StartDownload(total int64) io.Writer {
+	d.download = NewProgressBar(total, "Downloading")
+	return d.download
+}
+
+func (d *barDisplay) EndDownload() {
+	d.download.Finish()
+}
+
+func (d *barDisplay) Extracted(files int) {
+	if d.extract == nil {
+		d.extract = NewFileProgressBar(0, "Extracting")
+	}
+	d.extract.SetCurrent(files)
+}
+
+func (d *barDisplay) EndExtract() {
+	if d.extract == nil {
+		d.extract = NewFileProgressBar(0, "Extracting")
+	}
+	d.extract.Finish()
+}
+
+// multiDisplay shows several installs running at once, one line each. On a terminal the
+// lines are repainted in place; otherwise each change of step is printed as it happens.
+type multiDisplay struct {
+	mu    sync.Mutex
+	lines []*lineDisplay
+	width int // of the longest name
+	tty   bool
+	drawn int // lines painted by the last redraw
+	last  time.Time
+}
+
+// newMultiDisplay creates a display for installs written to stdout
+func newMultiDisplay() *multiDisplay {
+	return &multiDisplay{tty: term.IsTerminal(os.Stdout.Fd())}
+}
+
+// Add adds a line for the install of name
+func (d *multiDisplay) Add(name string) *lineDisplay {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	line := &lineDisplay{display: d, name: name, status: "Waiting"}
+	d.lines = append(d.lines, line)
+	d.width = max(d.width, len(name))
+	return line
+}
+
+// Close paints the final state of every line
+func (d *multiDisplay) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tty {
+		d.redraw()
+	}
+}
+
+// update applies change to a line under the lock and repaints. Changes of step are
+// always shown; progress within a step is throttled.
+func (d *multiDisplay) update(line *lineDisplay, step bool, change func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	change()
+	switch {
+	case !d.tty:
+		if step {
+			fmt.Printf("%s: %s\n", line.name, line.status)
+		}
+	case step || time.Since(d.last) >= redrawInterval:
+		d.redraw()
+	}
+}
+
+// redraw moves the cursor back over the lines painted last time and paints them again
+func (d *multiDisplay) redraw() {
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.drawn)
+	}
+	for _, line := range d.lines {
+		fmt.Fprintf(&b, "\r\x1b[2K%s\n", line.render(d.width))
+	}
+	fmt.Print(b.String())
+	d.drawn = len(d.lines)
+	d.last = time.Now()
+}
+
+// lineDisplay is one install's line in a multiDisplay
+type lineDisplay struct {
+	display *multiDisplay
+	name    string
+	status  string
+	total   int64
+	current int64
+	files   int
+}
+
+func (l *lineDisplay) Status(msg string) {
+	l.display.update(l, true, func() { l.status = msg })
+}
+
+func (l *lineDisplay) StartDownload(total int64) io.Writer {
+	l.display.update(l, true, func() { l.status, l.total = "Downloading", total })
+	return l
+}
+
+// Write counts downloaded bytes
+func (l *lineDisplay) Write(b []byte) (int, error) {
+	l.display.update(l, false, func() { l.current += int64(len(b)) })
+	return len(b), nil
+}
+
+func (l *lineDisplay) EndDownload() {}
+
+func (l *lineDisplay) Extracted(files int) {
+	step := files == 1
+	l.display.update(l, step, func() { l.status, l.files = "Extracting", files })
+}
+
+func (l *lineDisplay) EndExtract() {}
+
+// Done reports how the install ended
+func (l *lineDisplay) Done(err error) {
+	l.display.update(l, true, func() {
+		l.status = "Installed"
+		if err != nil {
+			l.status = "Failed: " + err.Error()
+		}
+	})
+}
+
+// render formats the line, padding the name to width
+func (l *lineDisplay) render(width int) string {
+	text := fmt.Sprintf("%-*s  %s", width, l.name, l.status)
+	switch {
+	case l.status == "Downloading" && l.total > 0:
+		percent := min(float64(l.current)/float64(l.total), 1.0)
+		filled := int(30 * percent)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", 30-filled)
+		text += fmt.Sprintf(" [%s] %.1f%%", lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Render(bar), percent*100)
+	case l.status == "Downloading":
+		text += fmt.Sprintf(" %.1f MB", float64(l.current)/(1024*1024))
+	case l.status == "Extracting":
+		text += fmt.Sprintf(" %d files", l.files)
+	}
+	return text
+}
+
+// stepDisplay prints each step of an install as a line to w, without progress, for
+// commands whose own output must stay clean
+type stepDisplay struct {
+	w    io.Writer
+	name string
+}
+
+func (d *stepDisplay) Status(msg string) {
+	fmt.Fprintf(d.w, "%s: %s\n", d.name, msg)
+}
+
+func (d *stepDisplay) StartDownload(total int64) io.Writer {
+	d.Status("Downloading")
+	return io.Discard
+}
+
+func (d *stepDisplay) EndDownload() {}
+
+func (d *stepDisplay) Extracted(files int) {
+	if files == 1 {
+		d.Status("Extracting")
+	}
+}
+
+func (d *stepDisplay) EndExtract() {}
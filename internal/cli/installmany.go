@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// defaultInstallJobs bounds how many installs `nori install` runs at once
+const defaultInstallJobs = 4
+
+// installJob is one version named on the command line, or a member of a named group
+type installJob struct {
+	m       *manifest.Manifest
+	version string
+	use     bool
+	reason  state.Reason
+}
+
+// installMany installs every <package>[@<version>] in args. Versions are resolved and
+// checked one at a time, then downloaded, extracted and installed by a bounded pool of
+// workers, and finally activated in the order they were given. A failed install doesn't
+// stop the others.
+func installMany(ctx context.Context, c *urfavecli.Command, args []string) error {
+	workers := c.Int("jobs")
+	if workers < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+
+	var jobs []installJob
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		resolved, err := resolveInstallArg(ctx, c, paths, reg, arg)
+		if err != nil {
+			return err
+		}
+		for _, job := range resolved {
+			if ref := job.m.Name + "@" + job.version; !seen[ref] {
+				seen[ref] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	var plans []*installPlan
+	for _, job := range jobs {
+		plan, err := planInstall(ctx, c, paths, job.m, job.version, job.use)
+		if err != nil {
+			return err
+		}
+		if plan != nil {
+			plans = append(plans, plan)
+		}
+	}
+	if len(plans) == 0 {
+		requireJobs(paths, jobs, nil)
+		return nil
+	}
+
+	fmt.Printf("Installing %d package(s) for %s...\n", len(plans), platform.Detect())
+	display := newMultiDisplay()
+	lines := make([]*lineDisplay, len(plans))
+	for i, plan := range plans {
+		lines[i] = display.Add(plan.m.Name + "@" + plan.version)
+	}
+
+	installPaths := make([]string, len(plans))
+	errs := make([]error, len(plans))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(plans)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				installPaths[i], errs[i] = fetchAndInstall(ctx, c, paths, plans[i], lines[i])
+				lines[i].Done(errs[i])
+			}
+		}()
+	}
+	for i := range plans {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	display.Close()
+	fmt.Println()
+
+	// Activate one at a time, as shims and active versions are shared
+	var failed []string
+	for i, plan := range plans {
+		err := errs[i]
+		if err == nil {
+			err = finishInstall(ctx, paths, plan, installPaths[i])
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Error: failed to install %s@%s: %v\n", plan.m.Name, plan.version, err)
+			failed = append(failed, plan.m.Name+"@"+plan.version)
+		}
+	}
+
+	requireJobs(paths, jobs, failed)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %d of %d package(s): %s", len(failed), len(plans), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// requireJobs records why each job's version is installed, except for the
+// <package>@<version> in failed
+func requireJobs(paths platform.Paths, jobs []installJob, failed []string) {
+	for _, job := range jobs {
+		if !slices.Contains(failed, job.m.Name+"@"+job.version) {
+			require(paths, job.m.Name, job.version, job.reason)
+		}
+	}
+}
+
+// resolveInstallArg resolves a <package>[@<version>] argument of `nori install`: no
+// version means the latest for this platform or what the package's settings prefer, a
+// sha256: digest the version it identifies, a range the highest matching release, and a
+// group each of its members at the highest release matching their declared versions
+func resolveInstallArg(ctx context.Context, c *urfavecli.Command, paths platform.Paths, reg *registry.Registry, arg string) ([]installJob, error) {
+	name, spec, hasVersion := strings.Cut(arg, "@")
+	if strings.Contains(spec, "@") {
+		return nil, fmt.Errorf("invalid format %q: expected <package>@<version>", arg)
+	}
+	m, err := reg.LoadPackage(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package %s: %w", name, err)
+	}
+
+	if m.IsGroup() {
+		if hasVersion {
+			return nil, fmt.Errorf("package group %q has no versions: use `nori install %s`", name, name)
+		}
+		members := make([]string, 0, len(m.Members))
+		for member := range m.Members {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+
+		plat := platform.Detect().String()
+		jobs := make([]installJob, 0, len(members))
+		for _, member := range members {
+			mm, err := reg.LoadPackage(ctx, member)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load group member %s: %w", member, err)
+			}
+			if mm.IsGroup() {
+				return nil, fmt.Errorf("group member %s is itself a group; nested groups are not supported", member)
+			}
+			spec := m.Members[member]
+			version, err := mm.ResolveVersion(spec, plat)
+			if err != nil {
+				return nil, err
+			}
+			if version != spec {
+				fmt.Printf("Resolved %s@%s to %s\n", member, spec, version)
+			}
+			// Members are always activated so the group stays in sync
+			jobs = append(jobs, installJob{mm, version, true, state.Reason{Group: m.Name}})
+		}
+		return jobs, nil
+	}
+
+	plat := platform.Detect().String()
+	var version string
+	switch {
+	case !hasVersion:
+		settings, err := config.New(paths).LoadSettings()
+		if err != nil {
+			return nil, err
+		}
+		var how string
+		if version, how, err = defaultVersion(settings, m, plat); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Resolved %s to %s, %s\n", name, how, version)
+	case strings.HasPrefix(spec, "sha256:"):
+		fetcher, err := newFetcher(c, paths)
+		if err != nil {
+			return nil, err
+		}
+		if version, err = resolveDigest(ctx, fetcher, m, spec); err != nil {
+			return nil, err
+		}
+	default:
+		// A range such as 22 or ^20.1 installs the highest matching release
+		if version, err = m.ResolveVersion(spec, plat); err != nil {
+			return nil, err
+		}
+		if version != spec {
+			fmt.Printf("Resolved %s@%s to %s\n", name, spec, version)
+		}
+	}
+	return []installJob{{m, version, c.Bool("use"), state.Reason{}}}, nil
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// LocalCommand handles the `nori local` command. It pins a version in the version file
+// of the current directory, creating the file if needed; ranges are resolved to the
+// version they match now, so everyone working in the directory gets the same build.
+func LocalCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori local <package>[@<version>] or nori local <package> --unset")
+	}
+
+	pkgName, spec, hasVersion := strings.Cut(c.Args().Get(0), "@")
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	path := filepath.Join(cwd, project.FileName)
+	f := &project.File{Path: path, Versions: make(map[string]string)}
+	if _, err := os.Stat(path); err == nil {
+		if f, err = project.Load(path); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("unset") {
+		if hasVersion {
+			return fmt.Errorf("--unset removes the pin: use `nori local %s --unset`", pkgName)
+		}
+		if _, ok := f.Versions[pkgName]; !ok {
+			return fmt.Errorf("%s is not pinned in %s", pkgName, path)
+		}
+		delete(f.Versions, pkgName)
+		if err := f.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Unpinned %s in %s\n", pkgName, path)
+		return nil
+	}
+
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	m, err := reg.LoadPackage(ctx, pkgName)
+	if err != nil {
+		return fmt.Errorf("failed to load package: %w", err)
+	}
+	if m.IsGroup() {
+		return fmt.Errorf("%s is a package group; pin its members instead", pkgName)
+	}
+
+	plat := platform.Detect().String()
+	var version string
+	if hasVersion {
+		version, err = m.ResolveVersion(spec, plat)
+	} else {
+		var settings *config.Settings
+		if settings, err = config.New(paths).LoadSettings(); err != nil {
+			return err
+		}
+		version, _, err = defaultVersion(settings, m, plat)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.Versions[pkgName] = version
+	if err := f.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Pinned %s %s in %s\n", pkgName, version, path)
+	if !dirExists(paths.InstallPath(pkgName, version, plat)) {
+		fmt.Println("Run `nori install` to install it")
+	}
+	return nil
+}
+
+// installProject installs every version pinned for the current directory by version
+// files or NORI_<PKG>_VERSION variables. Globally active versions are already installed.
+func installProject(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	result, err := project.Resolve(paths, cwd)
+	if err != nil {
+		return fmt.Errorf("failed to resolve versions: %w", err)
+	}
+
+	var names []string
+	for name, res := range result.Versions {
+		if res.Source != paths.ActiveConfigPath() {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("usage: nori install <package>[@<version>], or pin versions for this directory with `nori local` first")
+	}
+	sort.Strings(names)
+
+	// Keep going past failures so one bad pin doesn't hold up the rest
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, name := range names {
+		version := result.Versions[name].Version
+		m, err := reg.LoadPackage(ctx, name)
+		if err == nil && m.IsGroup() {
+			err = fmt.Errorf("%s is a package group; pin its members instead", name)
+		}
+		if err == nil {
+			err = installVersion(ctx, c, paths, m, version, c.Bool("use"), state.Reason{Project: result.Versions[name].Source})
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Error: failed to install %s@%s: %v\n", name, version, err)
+			failed = append(failed, name+"@"+version)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %d of %d pinned package(s): %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
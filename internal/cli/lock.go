@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// LockCommand handles the `nori lock` command. It records the URL and checksum of every
+// platform's build of the versions pinned for the current directory in nori.lock, next
+// to the nearest version file unless a lockfile already exists further up. A rolling
+// channel is locked to the digest of its current build.
+func LockCommand(ctx context.Context, c *urfavecli.Command) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return lockProject(ctx, c, cwd)
+}
+
+// lockProject writes the lockfile of the versions pinned for dir
+func lockProject(ctx context.Context, c *urfavecli.Command, cwd string) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	pins, projectDir, err := lockablePins(cwd)
+	if err != nil {
+		return err
+	}
+	if len(pins) == 0 {
+		return fmt.Errorf("no versions pinned here: pin some with `nori local` or in a %s file", project.FileName)
+	}
+
+	path, err := project.FindLock(cwd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = filepath.Join(projectDir, project.LockFileName)
+	}
+
+	fetcher, err := newFetcher(c, paths)
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	plat := platform.Detect().String()
+	lock := &project.Lock{Path: path, Packages: make(map[string]project.LockedPackage)}
+	for _, pin := range pins {
+		name, version := pin.Package, pin.Version
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to load package %s: %w", name, err)
+		}
+		if manifest.IsDigest(version) {
+			version, err = resolveDigest(ctx, fetcher, m, version)
+		} else {
+			version, err = m.ResolveVersion(version, plat)
+		}
+		if err != nil {
+			return err
+		}
+
+		locked := project.LockedPackage{Version: version, Platforms: make(map[string]project.LockedAsset)}
+		ver, ok := m.Versions[version]
+		if !ok {
+			ver = m.Channels[version]
+		}
+		for p, asset := range ver.Platforms {
+			// Record where a GitHub release asset is downloaded from, not just its checksum
+			if err := resolveReleaseAsset(ctx, fetcher, version, &asset); err != nil {
+				return fmt.Errorf("failed to find the %s@%s download for %s: %w", name, version, p, err)
+			}
+			if m.IsChannel(version) && asset.Checksum == "" {
+				if asset.Checksum, err = fetcher.FetchChecksum(ctx, asset.ChecksumsURL, asset.URL); err != nil {
+					return fmt.Errorf("failed to resolve the current %s@%s build for %s: %w", name, version, p, err)
+				}
+			}
+			locked.Platforms[p] = project.LockedAsset{URL: asset.URL, Checksum: asset.Checksum}
+		}
+		lock.Packages[name] = locked
+		if m.IsChannel(version) {
+			fmt.Printf("Locked %s %s to its current build (%d platform(s))\n", name, version, len(locked.Platforms))
+			continue
+		}
+		fmt.Printf("Locked %s %s (%d platform(s))\n", name, version, len(locked.Platforms))
+	}
+
+	if err := lock.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
+
+// SyncCommand handles the `nori sync` command. It installs the builds recorded in the
+// nearest nori.lock for this platform, after checking that the registry still serves
+// them and that installed copies are the same builds. Nothing is installed if any differ,
+// as when a locked rolling channel has published a new build since.
+func SyncCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	path, err := project.FindLock(cwd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("no %s found; create one with `nori lock`", project.LockFileName)
+	}
+	lock, err := project.LoadLock(path)
+	if err != nil {
+		return err
+	}
+
+	// Warn when the version files have moved on since the lock was written
+	if pins, _, err := lockablePins(cwd); err == nil {
+		for _, pin := range pins {
+			if _, err := manifest.ParseVersion(pin.Version); err != nil {
+				continue // ranges and channels are resolved when locking
+			}
+			if locked, ok := lock.Packages[pin.Package]; ok && locked.Version != pin.Version {
+				fmt.Fprintf(os.Stderr, "Warning: %s pins %s %s but %s has %s; run `nori lock` to update it\n", filepath.Base(pin.Source), pin.Package, pin.Version, project.LockFileName, locked.Version)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(lock.Packages))
+	for name := range lock.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Check everything before installing anything
+	fetcher, err := newFetcher(c, paths)
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	plat := platform.Detect().String()
+	manifests := make(map[string]*manifest.Manifest)
+	var missing []string
+	var problems []error
+	for _, name := range names {
+		locked := lock.Packages[name]
+		ref := name + "@" + locked.Version
+		want, ok := locked.Platforms[plat]
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s has no %s build; run `nori lock` again", ref, plat))
+			continue
+		}
+
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("failed to load package %s: %w", name, err))
+			continue
+		}
+		asset, err := m.GetAsset(locked.Version, plat)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if m.IsChannel(locked.Version) {
+			if asset.Checksum == "" {
+				if asset.Checksum, err = fetcher.FetchChecksum(ctx, asset.ChecksumsURL, asset.URL); err != nil {
+					problems = append(problems, fmt.Errorf("failed to resolve the current %s build: %w", ref, err))
+					continue
+				}
+			}
+			if asset.Checksum != want.Checksum {
+				problems = append(problems, fmt.Errorf("%s has published a new build since it was locked: %s has %s, the current build is %s; run `nori lock` again", ref, project.LockFileName, want.Checksum, asset.Checksum))
+				continue
+			}
+			// Pin the channel so the download is verified against the locked digest
+			m.Channels[locked.Version].Platforms[plat] = *asset
+		}
+		if asset.Checksum != want.Checksum {
+			problems = append(problems, fmt.Errorf("%s checksum differs: %s has %s, the registry has %s", ref, project.LockFileName, want.Checksum, asset.Checksum))
+			continue
+		}
+		manifests[name] = m
+
+		installPath := paths.InstallPath(name, locked.Version, plat)
+		if !dirExists(installPath) {
+			missing = append(missing, name)
+			continue
+		}
+		if r, err := receipt.Load(installPath); err == nil && !asset.HasChecksum(r.Checksum) {
+			problems = append(problems, fmt.Errorf("%s is installed from a different build (%s); uninstall it first", ref, r.Checksum))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", problem)
+		}
+		return fmt.Errorf("this machine can't match %s (%d problem(s)); nothing was installed", path, len(problems))
+	}
+
+	for _, name := range missing {
+		if err := installVersion(ctx, c, paths, manifests[name], lock.Packages[name].Version, false, state.Reason{Project: path}); err != nil {
+			return fmt.Errorf("failed to install %s@%s: %w", name, lock.Packages[name].Version, err)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(missing, name) {
+			require(paths, name, lock.Packages[name].Version, state.Reason{Project: path})
+		}
+	}
+	fmt.Printf("In sync with %s: %d installed, %d already present\n", path, len(missing), len(names)-len(missing))
+	return nil
+}
+
+// lockablePins returns the versions pinned by version files for dir, sorted by package,
+// and the directory of the nearest version file. Global versions and environment
+// overrides are not part of a project.
+func lockablePins(dir string) ([]project.Resolution, string, error) {
+	files, err := project.Find(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(files) == 0 {
+		return nil, "", nil
+	}
+
+	merged := project.Merge(files)
+	pins := make([]project.Resolution, 0, len(merged))
+	for _, res := range merged {
+		pins = append(pins, res)
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Package < pins[j].Package
+	})
+	return pins, filepath.Dir(files[0].Path), nil
+}
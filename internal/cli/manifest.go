@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chirag-bruno/nori/internal/importer"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// ManifestFromBrewCommand handles the `nori manifest from-brew` command. It seeds a
+// manifest from a Homebrew formula's bottles for a registry maintainer to review.
+func ManifestFromBrewCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori manifest from-brew <formula>")
+	}
+
+	baseURL := os.Getenv("NORI_BREW_API_URL")
+	if baseURL == "" {
+		baseURL = importer.DefaultBrewAPI
+	}
+
+	d, err := importer.NewBrew(baseURL).Formula(ctx, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	return writeDraft(c, d)
+}
+
+// ManifestFromScoopCommand handles the `nori manifest from-scoop` command. It converts a
+// Scoop app manifest, from a bucket checkout or URL, into a manifest for review.
+func ManifestFromScoopCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori manifest from-scoop <file-or-url>")
+	}
+
+	d, err := importer.LoadScoop(ctx, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if name := c.String("name"); name != "" {
+		d.Manifest.Name = name
+	}
+	return writeDraft(c, d)
+}
+
+// writeDraft writes a draft manifest to --output, or stdout, and lists what needs review on stderr
+func writeDraft(c *urfavecli.Command, d *importer.Draft) error {
+	data, err := d.YAML()
+	if err != nil {
+		return err
+	}
+
+	if output := c.String("output"); output != "" {
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", output)
+	} else {
+		os.Stdout.Write(data)
+	}
+
+	if len(d.Review) > 0 {
+		fmt.Fprintf(os.Stderr, "%d item(s) need review before publishing; see the comments at the top of the manifest\n", len(d.Review))
+	}
+	return nil
+}
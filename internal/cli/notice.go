@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+// defaultNoticeInterval is how often the upgrade notice may appear unless configured otherwise
+const defaultNoticeInterval = 24 * time.Hour
+
+// noticeUpgrades prints a one-line note when installed packages are behind the cached
+// registry, if the upgrade_notice setting asks for it. It never touches the network and
+// runs at most once per upgrade_notice_interval, whether or not anything is behind.
+func noticeUpgrades(paths platform.Paths) {
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil || !settings.UpgradeNotice {
+		return
+	}
+
+	interval := defaultNoticeInterval
+	if settings.UpgradeNoticeInterval != "" {
+		interval, err = time.ParseDuration(settings.UpgradeNoticeInterval)
+		if err != nil || interval <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid upgrade_notice_interval %q: expected a duration such as 24h\n", settings.UpgradeNoticeInterval)
+			return
+		}
+	}
+
+	stampPath := filepath.Join(paths.CacheDir(), "upgrade-notice")
+	if info, err := os.Stat(stampPath); err == nil && time.Since(info.ModTime()) < interval {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(stampPath), 0755); err != nil {
+		return
+	}
+	if err := os.WriteFile(stampPath, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
+		return
+	}
+
+	_, behind, err := outdatedPackages(paths)
+	if err != nil || behind == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Note: %d installed tool(s) have updates; run `nori outdated` to see them\n", behind)
+}
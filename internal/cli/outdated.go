@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// States reported by `nori outdated`
+const (
+	outdatedBehind  = "behind"
+	outdatedCurrent = "up to date"
+	outdatedChannel = "channel"
+	outdatedUnknown = "unknown"
+)
+
+// outdatedPackage is one row of `nori outdated`
+type outdatedPackage struct {
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Active  bool   `json:"active"`
+	Latest  string `json:"latest,omitempty"`
+	State   string `json:"state"`
+}
+
+// OutdatedCommand handles the `nori outdated` command. It compares the active version of
+// every installed package, or its newest installed one when none is active, with the
+// latest version the cached registry has for this platform.
+func OutdatedCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	rows, behind, err := outdatedPackages(paths)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No packages installed")
+		return nil
+	}
+
+	t := newTable("NAME", "CURRENT", "LATEST", "STATE")
+	for _, row := range rows {
+		current := row.Current
+		if !row.Active {
+			current += " (inactive)"
+		}
+		latest, state := row.Latest, row.State
+		if latest == "" {
+			latest = "-"
+		}
+		switch row.State {
+		case outdatedBehind:
+			latest, state = staleStyle.Render(latest), staleStyle.Render(state)
+		case outdatedCurrent:
+			state = activeStyle.Render(state)
+		}
+		t.addRow(style.Render(row.Name), current, latest, state)
+	}
+	t.render(os.Stdout, terminalWidth())
+
+	fmt.Println()
+	if behind == 0 {
+		fmt.Println("Everything is up to date with the cached registry (refresh it with `nori update`)")
+	} else {
+		fmt.Printf("%d of %d package(s) are behind; upgrade one with `nori install <package> --use`\n", behind, len(rows))
+	}
+	return nil
+}
+
+// outdatedPackages compares every package installed for this platform with the cached
+// registry, returning a row for each and how many are behind
+func outdatedPackages(paths platform.Paths) ([]outdatedPackage, int, error) {
+	plat := platform.Detect().String()
+
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return nil, 0, err
+	}
+	cfg := config.New(paths)
+	active, err := cfg.ListActive()
+	if err != nil {
+		return nil, 0, err
+	}
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	names := st.Installed(plat)
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return nil, 0, err
+	}
+	manifests := reg.CachedPackages(names)
+
+	rows := make([]outdatedPackage, 0, len(names))
+	behind := 0
+	for _, name := range names {
+		row := outdatedPackage{Name: name, Current: active[name], Active: active[name] != ""}
+		if !row.Active {
+			versions := st.Versions(name, plat)
+			row.Current = versions[len(versions)-1]
+		}
+		row.State = outdatedState(&row, manifests[name], settings, plat)
+		if row.State == outdatedBehind {
+			behind++
+		}
+		rows = append(rows, row)
+	}
+	return rows, behind, nil
+}
+
+// outdatedState fills in the latest version of row's package for plat, within any
+// constraint in its settings, and returns how its current version compares. m is nil
+// when the package's manifest isn't cached.
+func outdatedState(row *outdatedPackage, m *manifest.Manifest, settings *config.Settings, plat string) string {
+	if m == nil {
+		return outdatedUnknown
+	}
+	row.Latest = m.LatestVersionFor(plat)
+	if constraint := settings.Package(m.Name).Constraint; constraint != "" {
+		row.Latest, _ = m.ResolveVersion(constraint, plat)
+	}
+	switch {
+	case m.IsChannel(row.Current):
+		return outdatedChannel
+	case row.Latest == "":
+		return outdatedUnknown
+	case manifest.CompareVersions(row.Latest, row.Current) > 0:
+		return outdatedBehind
+	}
+	return outdatedCurrent
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/charmbracelet/x/term"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// pagerCommand returns the pager to use: $NORI_PAGER, then $PAGER, then a platform
+// default. An empty result or "cat" means output should not be paged.
+func pagerCommand() string {
+	if pager, ok := os.LookupEnv("NORI_PAGER"); ok {
+		return pager
+	}
+	if pager, ok := os.LookupEnv("PAGER"); ok {
+		return pager
+	}
+	if runtime.GOOS == "windows" {
+		return "more"
+	}
+	return "less"
+}
+
+// startPager redirects stdout into the pager when stdout is a terminal and
+// --no-pager was not given. The returned function flushes the output and waits
+// for the pager to exit; it must always be called.
+func startPager(c *urfavecli.Command) func() {
+	pager := pagerCommand()
+	if c.Bool("no-pager") || pager == "" || pager == "cat" || !term.IsTerminal(os.Stdout.Fd()) {
+		return func() {}
+	}
+
+	// Run through the shell so $PAGER may carry arguments, as git does
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", pager)
+	} else {
+		cmd = exec.Command("sh", "-c", pager)
+	}
+
+	// Like git: quit if the output fits on one screen, keep colors, don't clear the screen
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	stdout := os.Stdout
+	cmd.Stdin = r
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		// No usable pager; print directly
+		r.Close()
+		w.Close()
+		return func() {}
+	}
+	r.Close()
+
+	os.Stdout = w
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		cmd.Wait()
+	}
+}
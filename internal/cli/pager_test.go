@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestPagerCommand(t *testing.T) {
+	t.Setenv("NORI_PAGER", "most")
+	t.Setenv("PAGER", "less -S")
+	if got := pagerCommand(); got != "most" {
+		t.Errorf("pagerCommand() = %q, want $NORI_PAGER", got)
+	}
+
+	// An empty NORI_PAGER explicitly disables paging
+	t.Setenv("NORI_PAGER", "")
+	if got := pagerCommand(); got != "" {
+		t.Errorf("pagerCommand() = %q, want empty", got)
+	}
+}
+
+func TestPagerCommandDefault(t *testing.T) {
+	t.Setenv("PAGER", "less -S")
+	unsetenv(t, "NORI_PAGER")
+	if got := pagerCommand(); got != "less -S" {
+		t.Errorf("pagerCommand() = %q, want $PAGER", got)
+	}
+
+	unsetenv(t, "PAGER")
+	want := "less"
+	if runtime.GOOS == "windows" {
+		want = "more"
+	}
+	if got := pagerCommand(); got != want {
+		t.Errorf("pagerCommand() = %q, want %q", got, want)
+	}
+}
+
+// unsetenv removes key for the duration of the test
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/shims"
+)
+
+// pathCheckInterval is how long a passed or reported PATH check is trusted for the same PATH
+const pathCheckInterval = 24 * time.Hour
+
+// checkPath prints a one-line hint when the shims directory is missing from PATH or a
+// shimmed tool is shadowed by an earlier PATH entry, the most common reason installed
+// tools don't run. It runs at most once a day for a given PATH so commands stay fast.
+func checkPath(paths platform.Paths) {
+	if settings, err := config.New(paths).LoadSettings(); err == nil && settings.NoPathCheck {
+		return
+	}
+
+	// Nothing can be misconfigured until something has been installed
+	if entries, err := os.ReadDir(paths.ShimsDir()); err != nil || len(entries) == 0 {
+		return
+	}
+
+	stampPath := filepath.Join(paths.CacheDir(), "path-check")
+	sum := sha256.Sum256([]byte(os.Getenv("PATH")))
+	stamp := hex.EncodeToString(sum[:])
+	if data, err := os.ReadFile(stampPath); err == nil && string(data) == stamp {
+		if info, err := os.Stat(stampPath); err == nil && time.Since(info.ModTime()) < pathCheckInterval {
+			return
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(stampPath), 0755); err == nil {
+		os.WriteFile(stampPath, []byte(stamp), 0644)
+	}
+
+	shimsDir := paths.ShimsDir()
+	if !onPath(shimsDir) {
+		fmt.Fprintf(os.Stderr, "Hint: %s is not on PATH, so installed tools won't run; run `nori init` (see `nori doctor`)\n", shimsDir)
+		return
+	}
+	if bin, found := shadowedShim(shimsDir); bin != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s runs %s instead of nori's shim; move %s earlier in PATH (see `nori doctor`)\n", bin, found, shimsDir)
+	}
+}
+
+// shadowedShim returns the first shim that an executable in an earlier PATH entry
+// takes precedence over, along with that executable, or "" if none is shadowed
+func shadowedShim(shimsDir string) (string, string) {
+	var before []string
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if samePath(entry, shimsDir) {
+			break
+		}
+		before = append(before, entry)
+	}
+
+	entries, err := os.ReadDir(shimsDir)
+	if err != nil {
+		return "", ""
+	}
+	for _, entry := range entries {
+		bin, ok := shims.BinName(entry.Name())
+		if !ok {
+			continue
+		}
+		for _, dir := range before {
+			if found := executableIn(dir, bin); found != "" {
+				return bin, found
+			}
+		}
+	}
+	return "", ""
+}
+
+// executableIn returns the path of the executable named bin in dir, or ""
+func executableIn(dir, bin string) string {
+	candidates := []string{bin}
+	if runtime.GOOS == "windows" {
+		candidates = []string{bin + ".exe", bin + ".cmd", bin + ".bat"}
+	}
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if runtime.GOOS == "windows" || info.Mode()&0111 != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
+// samePath reports whether two PATH entries name the same directory
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || (runtime.GOOS == "windows" && strings.EqualFold(a, b))
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// defaultVersion returns the version of m to install for plat when none is named, and
+// how it was picked: the channel set in the package's settings, else the newest release
+// matching its constraint, else the latest release
+func defaultVersion(settings *config.Settings, m *manifest.Manifest, plat string) (string, string, error) {
+	ps := settings.Package(m.Name)
+	switch {
+	case ps.Channel != "":
+		if !m.IsChannel(ps.Channel) {
+			return "", "", fmt.Errorf("the channel setting of %s names %q, which it does not publish", m.Name, ps.Channel)
+		}
+		return ps.Channel, "the configured channel", nil
+	case ps.Constraint != "":
+		version, err := m.ResolveVersion(ps.Constraint, plat)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to resolve the constraint setting of %s: %w", m.Name, err)
+		}
+		return version, fmt.Sprintf("the newest version matching %s", ps.Constraint), nil
+	}
+
+	version := m.LatestVersionFor(plat)
+	if version == "" {
+		return "", "", fmt.Errorf("package %q has no versions for %s", m.Name, plat)
+	}
+	return version, "the latest version", nil
+}
+
+// setPackageEnv adds the env setting of pkg to nori's environment, so the binary it runs
+// inherits it
+func setPackageEnv(settings *config.Settings, pkg string) error {
+	for key, value := range settings.Package(pkg).Env {
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s for %s: %w", key, pkg, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/events"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// prefetchTarget is one asset to download into the cache
+type prefetchTarget struct {
+	m        *manifest.Manifest
+	version  string
+	platform string
+}
+
+// PrefetchCommand handles the `nori prefetch` command. It downloads assets into the
+// archive cache without installing them, so later installs work offline.
+func PrefetchCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori prefetch <package>[@<version>]...")
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+
+	platforms, err := prefetchPlatforms(c)
+	if err != nil {
+		return err
+	}
+
+	var targets []prefetchTarget
+	for _, arg := range c.Args().Slice() {
+		name, version, _ := strings.Cut(arg, "@")
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to load package: %w", err)
+		}
+
+		// Groups prefetch every member at the highest release matching its declared version
+		members := map[string]string{name: version}
+		if m.IsGroup() {
+			if version != "" {
+				return fmt.Errorf("package group %q has no versions: use `nori prefetch %s`", name, name)
+			}
+			members = m.Members
+		}
+
+		names := make([]string, 0, len(members))
+		for member := range members {
+			names = append(names, member)
+		}
+		sort.Strings(names)
+
+		for _, member := range names {
+			version := members[member]
+			mm := m
+			if member != name {
+				if mm, err = reg.LoadPackage(ctx, member); err != nil {
+					return fmt.Errorf("failed to load group member %s: %w", member, err)
+				}
+			}
+			if version == "" {
+				version = mm.LatestVersion()
+			}
+			for _, plat := range platforms {
+				version := version
+				if m.IsGroup() {
+					if version, err = mm.ResolveVersion(version, plat); err != nil {
+						return err
+					}
+				}
+				if err := manifest.ValidateVersion(mm, version, plat); err != nil {
+					return err
+				}
+				targets = append(targets, prefetchTarget{m: mm, version: version, platform: plat})
+			}
+		}
+	}
+
+	return prefetch(ctx, c, paths, targets)
+}
+
+// prefetchPlatforms returns the platforms named by --platform, defaulting to the current one
+func prefetchPlatforms(c *urfavecli.Command) ([]string, error) {
+	current := platform.Detect().String()
+	var platforms []string
+	for _, plat := range c.StringSlice("platform") {
+		switch {
+		case plat == "current":
+			plat = current
+		case strings.Count(plat, "-") != 1:
+			return nil, fmt.Errorf("invalid platform %q: expected <os>-<arch>, e.g. darwin-arm64, or current", plat)
+		}
+		platforms = append(platforms, plat)
+	}
+	if len(platforms) == 0 {
+		platforms = []string{current}
+	}
+	return platforms, nil
+}
+
+// prefetchInstalled prefetches the latest version of every package installed for this
+// platform, so upgrades can be installed later without a network connection
+func prefetchInstalled(ctx context.Context, c *urfavecli.Command, paths platform.Paths, reg *registry.Registry) error {
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return err
+	}
+
+	plat := platform.Detect().String()
+	installed := st.Installed(plat)
+	fmt.Printf("\nPrefetching the latest versions of %d installed package(s)...\n", len(installed))
+	return prefetchLatest(ctx, c, paths, reg, installed)
+}
+
+// prefetchLatest prefetches the version of each named package that `nori install` would
+// pick for this platform.
+// Packages that can't be loaded or have no build here are skipped with a warning.
+func prefetchLatest(ctx context.Context, c *urfavecli.Command, paths platform.Paths, reg *registry.Registry, names []string) error {
+	plat := platform.Detect().String()
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return err
+	}
+	var targets []prefetchTarget
+	for _, name := range names {
+		m, err := reg.LoadPackage(ctx, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", name, err)
+			continue
+		}
+		version, _, err := defaultVersion(settings, m, plat)
+		if err == nil {
+			err = manifest.ValidateVersion(m, version, plat)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", name, err)
+			continue
+		}
+		targets = append(targets, prefetchTarget{m: m, version: version, platform: plat})
+	}
+
+	return prefetch(ctx, c, paths, targets)
+}
+
+// prefetch downloads each target's asset into the archive cache. Assets that are already
+// cached are skipped and interrupted downloads resume, so a failed or cancelled run can
+// simply be repeated. A failure for one asset doesn't stop the others.
+func prefetch(ctx context.Context, c *urfavecli.Command, paths platform.Paths, targets []prefetchTarget) error {
+	fetcher, err := newFetcher(c, paths)
+	if err != nil {
+		return err
+	}
+
+	log := events.FromContext(ctx)
+	var fetched, cached int
+	var errs []error
+	for _, target := range targets {
+		name := fmt.Sprintf("%s@%s for %s", target.m.Name, target.version, target.platform)
+
+		asset, err := target.m.GetAsset(target.version, target.platform)
+		if err == nil && target.platform == platform.Detect().String() {
+			asset, err = asset.ForHost(hostInfo())
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Rolling channels are cached at their current build
+		if asset.Checksum == "" {
+			if err := resolveReleaseAsset(ctx, fetcher, target.version, asset); err != nil {
+				errs = append(errs, fmt.Errorf("failed to find the %s download: %w", name, err))
+				continue
+			}
+			if asset.Checksum, err = fetcher.FetchChecksum(ctx, asset.ChecksumsURL, asset.URL); err != nil {
+				errs = append(errs, fmt.Errorf("failed to resolve the current %s build: %w", name, err))
+				continue
+			}
+		}
+
+		if cachedArchive(paths, asset.Checksum) != nil {
+			fmt.Printf("%s is already cached\n", name)
+			cached++
+			continue
+		}
+		if err := resolveReleaseAsset(ctx, fetcher, target.version, asset); err != nil {
+			errs = append(errs, fmt.Errorf("failed to find the %s download: %w", name, err))
+			continue
+		}
+
+		fmt.Printf("Prefetching %s...\n", name)
+		phase := log.Begin(events.Event{Package: target.m.Name, Version: target.version, Phase: "prefetch", URL: asset.URL})
+		bar := NewProgressBar(fetcher.ContentLength(ctx, asset.URL), "Downloading")
+		err = fetcher.FetchToFile(ctx, asset.URLs(), asset.Checksum, paths.ArchivePath(asset.Checksum), bar)
+		bar.Finish()
+		phase.End(err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("failed to prefetch %s: %w", name, err))
+			continue
+		}
+		fetched++
+	}
+
+	fmt.Printf("Prefetched %d asset(s), %d already cached\n", fetched, cached)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return errors.New("some assets could not be prefetched; run the command again to resume")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// interactive reports whether a user is at the terminal to answer prompts
+func interactive() bool {
+	return term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stderr.Fd())
+}
+
+// confirm asks question on stderr and reports whether the user agreed. An empty answer
+// agrees, so question should name what users almost always want.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [Y/n] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/provenance"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// checkProvenance verifies the provenance attestation of a planned asset under the
+// provenance setting of the registry its manifest comes from. In warn mode a failure is
+// printed and the install goes on; in enforce mode it stops the install.
+func checkProvenance(ctx context.Context, c *urfavecli.Command, paths platform.Paths, plan *installPlan, display installDisplay) error {
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return err
+	}
+	source := plan.source
+	policy := settings.ProvenanceFor(source.Name)
+	if policy.Mode == "" {
+		return nil
+	}
+	fetcher, err := newFetcher(c, paths)
+	if err != nil {
+		return err
+	}
+	result, err := provenance.VerifyAsset(ctx, fetcher, policy, plan.asset)
+	if err == nil {
+		display.Status(fmt.Sprintf("Verified provenance: built by %s", result.Builder))
+		return nil
+	}
+	err = fmt.Errorf("provenance of %s@%s from registry %s: %w", plan.m.Name, plan.version, source.Label(), err)
+	if policy.Mode == provenance.ModeEnforce {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	return nil
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// RegistryListCommand handles the `nori registry list` command. It lists the registries
+// packages are looked up in, in lookup order.
+func RegistryListCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	t := newTable("NAME", "PREFIX", "URL")
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	for _, source := range reg.Registries() {
+		prefix := source.Prefix
+		if prefix == "" {
+			prefix = "-"
+		}
+		t.addRow(source.Label(), prefix, source.BaseURL)
+	}
+	t.render(os.Stdout, terminalWidth())
+	if os.Getenv("NORI_REGISTRY_URL") != "" {
+		fmt.Println("\nThe default registry is set by NORI_REGISTRY_URL, which takes precedence over the registry_url setting")
+	}
+	return nil
+}
+
+// RegistryAddCommand handles the `nori registry add` command. It adds a registry to the
+// registries setting, looked up after those added before it and before the default one.
+func RegistryAddCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("usage: nori registry add <name> <url> [--prefix <prefix>]")
+	}
+	name, url := c.Args().Get(0), c.Args().Get(1)
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cfg := config.New(paths)
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return err
+	}
+	settings.Registries = append(settings.Registries, config.RegistrySource{Name: name, URL: url, Prefix: c.String("prefix")})
+	if err := registry.ValidateSources(registrySources(settings)); err != nil {
+		return err
+	}
+	if err := cfg.UpdateSettings(settings); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added registry %s at %s\n", name, url)
+	if prefix := c.String("prefix"); prefix != "" {
+		fmt.Printf("Its packages go by %s/<name>\n", prefix)
+	}
+	fmt.Println("Run `nori update` to fetch its index")
+	return nil
+}
+
+// RegistryRemoveCommand handles the `nori registry remove` command. It removes a registry
+// from the registries setting, along with its cache. Packages installed from it stay.
+func RegistryRemoveCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("usage: nori registry remove <name>")
+	}
+	name := c.Args().Get(0)
+	if name == registry.DefaultName {
+		return fmt.Errorf("the default registry can't be removed; point it elsewhere with `nori registry set-default <url>`")
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cfg := config.New(paths)
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(settings.Registries, func(source config.RegistrySource) bool { return source.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no registry is named %s; see `nori registry list`", name)
+	}
+	source := settings.Registries[i]
+	settings.Registries = slices.Delete(settings.Registries, i, i+1)
+	if err := cfg.UpdateSettings(settings); err != nil {
+		return err
+	}
+
+	if err := registry.New("", paths).AddRegistry(source.Name, source.URL, source.Prefix).ClearCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	fmt.Printf("Removed registry %s\n", name)
+	return nil
+}
+
+// RegistrySetDefaultCommand handles the `nori registry set-default` command. It saves the
+// URL of the default registry in the registry_url setting, or with --unset goes back to
+// nori's own registry, and drops what was cached from the previous one.
+func RegistrySetDefaultCommand(ctx context.Context, c *urfavecli.Command) error {
+	unset := c.Bool("unset")
+	if (unset && c.NArg() != 0) || (!unset && c.NArg() != 1) {
+		return fmt.Errorf("usage: nori registry set-default <url> or nori registry set-default --unset")
+	}
+	url := c.Args().Get(0)
+	if !unset {
+		if err := registry.ValidateURL(url); err != nil {
+			return err
+		}
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	cfg := config.New(paths)
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if settings.RegistryURL == url {
+		fmt.Println("The default registry is unchanged")
+		return nil
+	}
+	settings.RegistryURL = url
+	if err := cfg.UpdateSettings(settings); err != nil {
+		return err
+	}
+
+	if err := registry.New("", paths).ClearCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if unset {
+		fmt.Println("The default registry is nori's own again")
+	} else {
+		fmt.Printf("The default registry is %s now\n", url)
+	}
+	if os.Getenv("NORI_REGISTRY_URL") != "" {
+		fmt.Println("NORI_REGISTRY_URL is set, and takes precedence while it is")
+	}
+	fmt.Println("Run `nori update` to fetch its index")
+	return nil
+}
+
+// newRegistry returns a client for the registry of registry.DefaultURL that looks the
+// registries of the registries setting up first, sends the credentials of authHeaders,
+// goes through the HTTP proxy of the proxy setting, and trusts the CA and presents the
+// client certificate of the TLS settings
+func newRegistry(paths platform.Paths) (*registry.Registry, error) {
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	reg := registry.New(registry.DefaultURL(settings.RegistryURL), paths)
+	if err := registry.ValidateSources(registrySources(settings)); err != nil {
+		return nil, fmt.Errorf("registries setting: %w", err)
+	}
+	for _, source := range settings.Registries {
+		reg.AddRegistry(source.Name, source.URL, source.Prefix)
+	}
+	if headers := authHeaders(settings); len(headers) > 0 {
+		reg.SetAuth(headers)
+	}
+	if settings.Proxy != "" {
+		if err := reg.SetHTTPProxy(settings.Proxy, settings.NoProxy); err != nil {
+			return nil, fmt.Errorf("proxy setting: %w", err)
+		}
+	}
+	tlsConfig, err := fetch.TLSConfig(settings.CAFile, settings.ClientCert, settings.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("ca_file, client_cert or client_key setting: %w", err)
+	}
+	if tlsConfig != nil {
+		reg.SetTLSConfig(tlsConfig)
+	}
+	return reg, nil
+}
+
+// registrySources returns the registries of the registries setting
+func registrySources(settings *config.Settings) []registry.Source {
+	sources := make([]registry.Source, 0, len(settings.Registries))
+	for _, source := range settings.Registries {
+		sources = append(sources, registry.Source{Name: source.Name, URL: source.URL, Prefix: source.Prefix})
+	}
+	return sources
+}
+
+// authHeaders returns the headers to send to each host: those of the auth setting, and
+// the bearer token in NORI_REGISTRY_TOKEN for the registry's host, which takes
+// precedence over the setting's token for it, and the one in GITHUB_TOKEN for the GitHub
+// API unless the setting has a token for it. Hosts without a token or Authorization
+// header of their own use the login in .netrc, if it lists one; see fetch.NetrcPath.
+func authHeaders(settings *config.Settings) map[string]http.Header {
+	headers := make(map[string]http.Header)
+	for host, auth := range settings.Auth {
+		h := make(http.Header)
+		for name, value := range auth.Headers {
+			h.Set(name, value)
+		}
+		if auth.Token != "" {
+			h.Set("Authorization", "Bearer "+auth.Token)
+		}
+		if len(h) > 0 {
+			headers[strings.ToLower(host)] = h
+		}
+	}
+
+	if token := os.Getenv("NORI_REGISTRY_TOKEN"); token != "" {
+		if u, err := url.Parse(registry.DefaultURL(settings.RegistryURL)); err == nil && u.Host != "" {
+			host := strings.ToLower(u.Host)
+			if headers[host] == nil {
+				headers[host] = make(http.Header)
+			}
+			headers[host].Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	// Release assets are looked up with GITHUB_TOKEN, as gh does, for private repositories
+	// and a higher rate limit
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		host := strings.ToLower(strings.TrimPrefix(fetch.DefaultGitHubAPI, "https://"))
+		if headers[host] == nil {
+			headers[host] = make(http.Header)
+		}
+		if headers[host].Get("Authorization") == "" {
+			headers[host].Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	netrc, _ := fetch.NetrcHeaders(fetch.NetrcPath())
+	for host, h := range netrc {
+		if headers[host] == nil {
+			headers[host] = make(http.Header)
+		}
+		if headers[host].Get("Authorization") == "" {
+			headers[host].Set("Authorization", h.Get("Authorization"))
+		}
+	}
+	return headers
+}
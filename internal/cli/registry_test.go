@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/config"
+)
+
+func TestAuthHeaders(t *testing.T) {
+	t.Setenv("NORI_REGISTRY_URL", "https://Registry.example.com:8443/nori")
+	t.Setenv("NORI_REGISTRY_TOKEN", "from-env")
+	t.Setenv("GITHUB_TOKEN", "")
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	os.WriteFile(netrc, []byte("machine artifacts.example.com login ci password p4ss\nmachine files.example.com login ci password p4ss\n"), 0600)
+	t.Setenv("NETRC", netrc)
+	settings := &config.Settings{Auth: map[string]config.HostAuth{
+		"registry.example.com:8443": {Token: "from-config", Headers: map[string]string{"X-Team": "tools"}},
+		"Artifacts.example.com":     {Headers: map[string]string{"X-JFrog-Art-Api": "key"}},
+		"empty.example.com":         {},
+	}}
+
+	headers := authHeaders(settings)
+	if got := headers["registry.example.com:8443"]; got.Get("Authorization") != "Bearer from-env" || got.Get("X-Team") != "tools" {
+		t.Errorf("registry headers = %v, want the env token and the configured header", got)
+	}
+	if got := headers["artifacts.example.com"].Get("X-JFrog-Art-Api"); got != "key" {
+		t.Errorf("artifacts X-JFrog-Art-Api = %q, want key", got)
+	}
+	if _, ok := headers["empty.example.com"]; ok || len(headers) != 3 {
+		t.Errorf("authHeaders() = %v, want three hosts", headers)
+	}
+
+	// .netrc logins fill in for hosts without an Authorization header of their own
+	basic := "Basic Y2k6cDRzcw=="
+	if got := headers["artifacts.example.com"].Get("Authorization"); got != basic {
+		t.Errorf("artifacts Authorization = %q, want the .netrc login", got)
+	}
+	if got := headers["files.example.com"].Get("Authorization"); got != basic {
+		t.Errorf("files Authorization = %q, want the .netrc login", got)
+	}
+
+	// GITHUB_TOKEN is sent to the GitHub API, unless the auth setting has a token for it
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	if got := authHeaders(settings)["api.github.com"].Get("Authorization"); got != "Bearer gh-token" {
+		t.Errorf("api.github.com Authorization = %q, want GITHUB_TOKEN", got)
+	}
+	settings.Auth["api.github.com"] = config.HostAuth{Token: "from-config"}
+	if got := authHeaders(settings)["api.github.com"].Get("Authorization"); got != "Bearer from-config" {
+		t.Errorf("api.github.com Authorization = %q, want the configured token", got)
+	}
+}
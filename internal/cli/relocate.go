@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/relocate"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// RelocateCommand handles `nori relocate <old> <new>`. The root now at new used to be
+// at old; the shims, links and settings in it that point beneath old are rewritten to
+// point beneath new.
+func RelocateCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("usage: nori relocate <old> <new>")
+	}
+	old, err := filepath.Abs(c.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", c.Args().Get(0), err)
+	}
+	root, err := filepath.Abs(c.Args().Get(1))
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find the nori root at %s: %w", c.Args().Get(1), err)
+	}
+
+	paths := platform.NewPaths(root)
+	if settings, err := config.New(paths).LoadSettings(); err == nil && settings.SystemShims {
+		paths.Shims = platform.SystemShimsDir()
+	}
+	dryRun := c.Bool("dry-run")
+	changes, err := relocate.Run(paths, old, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Rewrote"
+	if dryRun {
+		verb = "Would rewrite"
+	}
+	for _, change := range changes {
+		fmt.Printf("%s %s %s\n", verb, change.Kind, change.Path)
+	}
+	if !dryRun {
+		fmt.Printf("Relocated %d path(s) from %s to %s\n", len(changes), old, root)
+	}
+
+	if current, err := platform.ResolveRoot(); err == nil && current != root {
+		fmt.Printf("Set NORI_ROOT=%s to use the relocated root\n", root)
+	}
+	if oldShims := filepath.Join(old, "shims"); onPath(oldShims) {
+		fmt.Printf("PATH still includes %s; run `nori init` to add the new shims directory\n", oldShims)
+	}
+	return nil
+}
+
+// checkRelocated records where the root is used, and warns when it was last used
+// somewhere else and still holds paths to that location
+func checkRelocated(paths platform.Paths) {
+	recorded, ok := relocate.Recorded(paths)
+	if !ok {
+		relocate.Record(paths)
+		return
+	}
+	if recorded != paths.Root {
+		fmt.Fprintf(os.Stderr, "Warning: this nori root was last used at %s; run `nori relocate %s %s` to update the paths it holds\n", recorded, recorded, paths.Root)
+	}
+}
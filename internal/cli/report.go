@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Exit codes of the read-only checks (verify, doctor, status). Each failure
+// category is a bit, so one exit status reports every kind of failure found;
+// 1 stays reserved for ordinary errors.
+const (
+	ExitDamaged       = 2  // installed files differ from their receipt
+	ExitUnverifiable  = 4  // an installation has no receipt to verify against
+	ExitMisconfigured = 8  // environment problems, e.g. shims not on PATH or stale shims
+	ExitMissing       = 16 // an active version or shim target is not installed
+)
+
+// categoryNames names each failure category in reports
+var categoryNames = map[int]string{
+	ExitDamaged:       "damaged",
+	ExitUnverifiable:  "unverifiable",
+	ExitMisconfigured: "misconfigured",
+	ExitMissing:       "missing",
+}
+
+// ExitError makes nori exit with a specific status
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+// Finding is one problem found by a check
+type Finding struct {
+	Category string `json:"category"`
+	Package  string `json:"package,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"` // a command that fixes the problem, if there is one
+}
+
+// report collects the findings of a check and renders them as text or JSON
+type report struct {
+	Command  string          `json:"command"`
+	Checked  int             `json:"checked"`
+	Findings []Finding       `json:"findings"`
+	Packages []packageStatus `json:"packages,omitempty"`
+	ExitCode int             `json:"exit_code"`
+
+	// out receives human-readable progress; it discards everything in JSON mode
+	out  io.Writer
+	json bool
+}
+
+// newReport creates a report for command, printing text output unless asJSON is set
+func newReport(command string, asJSON bool) *report {
+	r := &report{Command: command, Findings: []Finding{}, out: os.Stdout, json: asJSON}
+	if asJSON {
+		r.out = io.Discard
+	}
+	return r
+}
+
+// add records a finding in category, one of the Exit* codes
+func (r *report) add(category int, f Finding) {
+	f.Category = categoryNames[category]
+	r.Findings = append(r.Findings, f)
+	r.ExitCode |= category
+}
+
+// finish prints the JSON report if requested and turns any findings into an *ExitError
+func (r *report) finish() error {
+	if r.json {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(data))
+	}
+
+	if r.ExitCode == 0 {
+		return nil
+	}
+
+	var categories []string
+	for code, name := range categoryNames {
+		if r.ExitCode&code != 0 {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+
+	return &ExitError{
+		Code:    r.ExitCode,
+		Message: fmt.Sprintf("%s found %d problem(s): %s", r.Command, len(r.Findings), strings.Join(categories, ", ")),
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/project"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// RunCommand handles `nori run [<task> [<arg>...]]`. It finds the nearest nori.yaml and
+// runs the task's command in its directory with the shell, with the bin directories of
+// the versions pinned there first on PATH. Pinned versions that aren't installed are
+// installed first, without activating them. With no task, it lists the tasks.
+func RunCommand(ctx context.Context, c *urfavecli.Command) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	path, err := project.FindTasks(cwd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("no %s found in %s or any parent directory", project.ProjectFileName, cwd)
+	}
+	tasks, err := project.LoadTasks(path)
+	if err != nil {
+		return err
+	}
+
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		for _, name := range tasks.Names() {
+			fmt.Printf("%s\t%s\n", name, firstLine(tasks.Tasks[name]))
+		}
+		return nil
+	}
+	command, ok := tasks.Tasks[args[0]]
+	if !ok {
+		return fmt.Errorf("no task %q in %s; run `nori run` to list them", args[0], path)
+	}
+
+	dir := filepath.Dir(path)
+	if err := usePinnedVersions(ctx, c, dir); err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("failed to change to %s: %w", dir, err)
+	}
+
+	// Extra arguments are appended to a one-line command; multi-line commands get
+	// them as positional parameters
+	shell, shellArgs := "sh", []string{"-c", command, args[0]}
+	if !strings.Contains(strings.TrimSpace(command), "\n") {
+		shellArgs[1] = strings.TrimSpace(command) + ` "$@"`
+	}
+	if runtime.GOOS == "windows" {
+		shell, shellArgs = "cmd", []string{"/C", strings.Join(append([]string{command}, args[1:]...), " ")}
+	} else {
+		shellArgs = append(shellArgs, args[1:]...)
+	}
+	shellPath, err := exec.LookPath(shell)
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", shell, err)
+	}
+	return execBinary(shellPath, shellArgs)
+}
+
+// usePinnedVersions puts the bin directories of the versions pinned for dir first on
+// PATH and adds the env from their packages' settings, installing any that are missing.
+// Globally active versions are left to the shims.
+func usePinnedVersions(ctx context.Context, c *urfavecli.Command, dir string) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	result, err := project.ResolveCached(paths, dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve versions: %w", err)
+	}
+	settings, err := config.New(paths).LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(result.Versions))
+	for name, res := range result.Versions {
+		if res.Source != paths.ActiveConfigPath() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	plat := platform.Detect().String()
+	var dirs []string
+	for _, name := range names {
+		version := result.Versions[name].Version
+		installPath := paths.InstallPath(name, version, plat)
+		if !dirExists(installPath) {
+			reg, err := newRegistry(paths)
+			if err != nil {
+				return err
+			}
+			m, err := reg.LoadPackage(ctx, name)
+			if err != nil {
+				return fmt.Errorf("failed to load manifest: %w", err)
+			}
+			if _, err := ensureInstalled(ctx, c, paths, m, version, state.Reason{Project: result.Versions[name].Source}); err != nil {
+				return fmt.Errorf("failed to install %s@%s: %w", name, version, err)
+			}
+		}
+		bins, err := installedBins(ctx, paths, name, version, plat)
+		if err != nil {
+			return err
+		}
+		for _, binDir := range install.BinDirs(installPath, bins) {
+			if !slices.Contains(dirs, binDir) {
+				dirs = append(dirs, binDir)
+			}
+		}
+		vars, err := installedEnv(ctx, paths, name, version, plat)
+		if err != nil {
+			return err
+		}
+		if err := setVars(vars); err != nil {
+			return err
+		}
+		if err := setPackageEnv(settings, name); err != nil {
+			return err
+		}
+	}
+
+	pathList := append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	if err := os.Setenv("PATH", strings.Join(pathList, string(os.PathListSeparator))); err != nil {
+		return fmt.Errorf("failed to set PATH: %w", err)
+	}
+	return nil
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
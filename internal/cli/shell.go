@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// ShellCommand handles `nori shell <package>[@<version>]...`. It installs each package
+// if needed, without activating it, and starts the user's shell with the packages' bin
+// directories first on PATH and their env set. Nothing outside the subshell changes, so
+// exiting it restores the previous environment. NORI_SHELL lists the packages, for
+// prompts.
+func ShellCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori shell <package>[@<version>]...")
+	}
+
+	used, err := usePackages(ctx, c, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	all := used
+	if outer := os.Getenv("NORI_SHELL"); outer != "" {
+		all = append(strings.Fields(outer), used...)
+	}
+	if err := os.Setenv("NORI_SHELL", strings.Join(all, " ")); err != nil {
+		return fmt.Errorf("failed to set NORI_SHELL: %w", err)
+	}
+
+	shell := userShell()
+	shellPath, err := exec.LookPath(shell)
+	if err != nil {
+		return fmt.Errorf("failed to find your shell %s: %w", shell, err)
+	}
+	fmt.Fprintf(os.Stderr, "Starting %s with %s; exit to return\n", shell, strings.Join(used, ", "))
+	return execBinary(shellPath, nil)
+}
+
+// userShell returns the shell to start: $SHELL, else PowerShell on Windows and sh elsewhere
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "sh"
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/events"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+// checkInstall runs the manifest's smoke test against a version fetchAndInstall just
+// installed, after its shims are written if it was activated. On failure the version
+// that was active before is restored and the install removed, so a broken asset
+// neither replaces a working version nor counts as installed.
+func checkInstall(ctx context.Context, paths platform.Paths, plan *installPlan, installPath string) error {
+	if plan.m.SmokeTest == nil || plan.skipSmoke {
+		return nil
+	}
+	pkgName, version := plan.m.Name, plan.version
+
+	phase := events.FromContext(ctx).Begin(events.Event{Package: pkgName, Version: version, Phase: "smoke_test"})
+	err := install.SmokeTest(ctx, plan.m, version, installPath)
+	phase.End(err)
+	if err == nil {
+		return nil
+	}
+
+	replaced := plan.previous != ""
+	if rollbackErr := rollbackInstall(ctx, paths, plan); rollbackErr != nil {
+		return fmt.Errorf("%s@%s failed its smoke test, and undoing the install failed (%v): %w", pkgName, version, rollbackErr, err)
+	}
+	if replaced {
+		return fmt.Errorf("the new build of %s@%s failed its smoke test, so the build it replaced was restored: %w", pkgName, version, err)
+	}
+	if plan.activate && plan.active != "" {
+		return fmt.Errorf("%s@%s failed its smoke test, so it was removed and %s@%s is active again: %w", pkgName, version, pkgName, plan.active, err)
+	}
+	return fmt.Errorf("%s@%s failed its smoke test, so it was removed: %w", pkgName, version, err)
+}
+
+// rollbackInstall reactivates the version that was active before plan, or deactivates
+// the package if none was, and removes the version plan installed, or puts back the
+// build of a channel it replaced
+func rollbackInstall(ctx context.Context, paths platform.Paths, plan *installPlan) error {
+	pkgName, plat := plan.m.Name, plan.platform.String()
+	replaced := plan.previous != ""
+	if replaced {
+		if err := install.New(paths).Restore(pkgName, plan.version, plan.platform, plan.previous); err != nil {
+			return err
+		}
+		plan.previous = ""
+	}
+	if plan.activate {
+		if plan.active != "" {
+			bins, err := installedBins(ctx, paths, pkgName, plan.active, plat)
+			if err != nil {
+				return err
+			}
+			if err := activate(paths, pkgName, plan.active, bins, paths.InstallPath(pkgName, plan.active, plat)); err != nil {
+				return err
+			}
+		} else {
+			shimNames := make([]string, len(plan.m.Bins))
+			for i, bin := range plan.m.Bins {
+				shimNames[i] = filepath.Base(bin)
+			}
+			if err := deactivate(paths, pkgName, shimNames); err != nil {
+				return err
+			}
+		}
+	}
+	if replaced {
+		return nil
+	}
+	return install.New(paths).Uninstall(pkgName, plan.version, plan.platform, true)
+}
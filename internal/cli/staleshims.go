@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
+)
+
+// staleShim is a shim that doesn't run what its name promises, and why
+type staleShim struct {
+	Name   string
+	Reason string
+
+	unwanted bool // no active package provides it, so repairing it removes it
+}
+
+// activePackage is an active version installed for the platform, whose bins get shims
+type activePackage struct {
+	name    string
+	version string
+	bins    []string
+}
+
+// activePackages returns the active versions installed for p, sorted by name, and the
+// <package>@<version> of those that aren't installed. Active versions come from the
+// active config, machine-wide defaults included, and their bins from the state index.
+func activePackages(paths platform.Paths, p platform.Platform) ([]activePackage, []string, error) {
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	active, err := config.New(paths).ListActive()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(active))
+	for name := range active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pkgs []activePackage
+	var missing []string
+	for _, name := range names {
+		version := active[name]
+		inst := st.Find(name, version, p.String())
+		if inst == nil {
+			missing = append(missing, name+"@"+version)
+			continue
+		}
+		pkgs = append(pkgs, activePackage{name, version, inst.Bins})
+	}
+	return pkgs, missing, nil
+}
+
+// staleShims returns the shims that `nori reshim` would change: shims no active package
+// provides, such as those of a bin an upgrade dropped, fixed shims from earlier releases,
+// shims whose nori executable is gone, and bins of active packages that have no shim
+func staleShims(paths platform.Paths, p platform.Platform) ([]staleShim, error) {
+	pkgs, _, err := activePackages(paths, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bins that active versions provide, by shim name
+	provided := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, bin := range pkg.bins {
+			provided[filepath.Base(bin)] = pkg.name + "@" + pkg.version
+		}
+	}
+
+	shim := shims.New(paths.ShimsDir())
+	seen := make(map[string]bool)
+	var stale []staleShim
+	entries, _ := os.ReadDir(paths.ShimsDir())
+	for _, entry := range entries {
+		name, ok := shims.BinName(entry.Name())
+		if !ok || entry.IsDir() || strings.HasPrefix(name, ".") || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		target, _ := shim.Target(name)
+		switch {
+		case provided[name] == "":
+			stale = append(stale, staleShim{Name: name, Reason: "no active package provides it", unwanted: true})
+		case !shim.Dynamic(name) && !fileExists(target):
+			stale = append(stale, staleShim{Name: name, Reason: "runs a removed version: " + target})
+		case !shim.Dynamic(name):
+			stale = append(stale, staleShim{Name: name, Reason: "always runs " + target + ", ignoring project versions"})
+		case !fileExists(target):
+			stale = append(stale, staleShim{Name: name, Reason: "runs a nori executable that no longer exists: " + target})
+		}
+	}
+	for name, pkg := range provided {
+		if !seen[name] {
+			stale = append(stale, staleShim{Name: name, Reason: "missing, though " + pkg + " provides it"})
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	return stale, nil
+}
+
+// listStaleShims prints the stale shims, for `nori list --outdated-shims`
+func listStaleShims(paths platform.Paths, p platform.Platform, width int) error {
+	stale, err := staleShims(paths, p)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Println("All shims are up to date")
+		return nil
+	}
+	t := newTable("SHIM", "PROBLEM")
+	for _, s := range stale {
+		t.addRow(s.Name, s.Reason)
+	}
+	t.render(os.Stdout, width)
+	fmt.Println("\nRun `nori reshim` to repair them.")
+	return nil
+}
+
+// repairShims rewrites or removes the stale shims, so an upgrade or uninstall doesn't
+// leave commands that fail until the user notices, and leaves the rest alone. Failures
+// are only reported; the change that left the shims stale has already happened.
+func repairShims(paths platform.Paths, p platform.Platform) {
+	stale, err := staleShims(paths, p)
+	if err != nil || len(stale) == 0 {
+		return
+	}
+	shim := shims.New(paths.ShimsDir())
+	names := make([]string, len(stale))
+	for i, s := range stale {
+		names[i] = s.Name
+		if s.unwanted {
+			err = shim.RemoveShims([]string{s.Name})
+		} else {
+			err = shim.CreateShim(s.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %d shim(s) are stale and could not be repaired (run `nori reshim`): %v\n", len(stale), err)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Repaired %d stale shim(s): %s\n", len(stale), strings.Join(names, ", "))
+}
+
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return path != "" && err == nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// StateRebuildCommand handles the `nori state rebuild` command. It discards the state
+// index and re-derives it from the installs tree and active versions on disk.
+func StateRebuildCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+
+	st, err := state.New(paths).Rebuild()
+	if err != nil {
+		return err
+	}
+
+	installs := 0
+	for _, pkg := range st.Packages {
+		installs += len(pkg.Installs)
+	}
+	fmt.Printf("Rebuilt %s: %d package(s), %d install(s)\n", paths.StatePath(), len(st.Packages), installs)
+	return nil
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// packageStatus is the state of one package as reported by `nori status`
+type packageStatus struct {
+	Name      string   `json:"name"`
+	Active    string   `json:"active,omitempty"`
+	Installed []string `json:"installed"`
+	State     string   `json:"state"`
+}
+
+// StatusCommand handles the `nori status` command. It reports every active
+// package, or with --all every installed one, and whether its shims are sound.
+func StatusCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
+	rep := newReport("status", c.Bool("json"))
+
+	active, err := config.New(paths).ListActive()
+	if err != nil {
+		rep.add(ExitMisconfigured, Finding{Path: paths.ActiveConfigPath(), Message: err.Error()})
+		return rep.finish()
+	}
+
+	names := make(map[string]bool)
+	for name := range active {
+		names[name] = true
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return err
+	}
+	if c.Bool("all") {
+		for _, name := range st.Installed(p.String()) {
+			names[name] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	t := newTable("NAME", "ACTIVE", "INSTALLED", "STATE")
+	for _, name := range sorted {
+		rep.Checked++
+		status := packageStatus{
+			Name:      name,
+			Active:    active[name],
+			Installed: st.Versions(name, p.String()),
+			State:     checkActive(paths, reg, p, name, active[name], rep),
+		}
+		if status.Installed == nil {
+			status.Installed = []string{}
+		}
+		rep.Packages = append(rep.Packages, status)
+
+		activeCell := status.Active
+		if activeCell == "" {
+			activeCell = "-"
+		}
+		state := status.State
+		if state == "ok" {
+			state = activeStyle.Render(state)
+		} else {
+			state = staleStyle.Render(state)
+		}
+		t.addRow(style.Render(name), activeCell, strings.Join(status.Installed, ", "), state)
+	}
+
+	if len(sorted) == 0 {
+		msg := "No active packages"
+		if c.Bool("all") {
+			msg = "No packages installed"
+		}
+		fmt.Fprintln(rep.out, msg)
+	} else if !rep.json {
+		t.render(os.Stdout, terminalWidth())
+	}
+
+	return rep.finish()
+}
+
+// checkActive checks that the active version of a package is installed and that its
+// shims exist, and point into it if they run a fixed version, adding findings to rep and
+// returning a one-word state
+func checkActive(paths platform.Paths, reg *registry.Registry, p platform.Platform, name, version string, rep *report) string {
+	if version == "" {
+		return "inactive"
+	}
+
+	installPath := paths.InstallPath(name, version, p.String())
+	if !dirExists(installPath) {
+		rep.add(ExitMissing, Finding{Package: name, Version: version, Path: installPath, Message: "active version is not installed"})
+		return "not installed"
+	}
+
+	// Shims can only be checked when the manifest, and thus the bin list, is cached
+	m, err := reg.CachedPackage(name)
+	if err != nil {
+		return "ok"
+	}
+
+	state := "ok"
+	shim := shims.New(paths.ShimsDir())
+	for _, bin := range m.Bins {
+		binName := filepath.Base(bin)
+		target, err := shim.Target(binName)
+		if err != nil {
+			rep.add(ExitMissing, Finding{Package: name, Version: version, Path: binName, Message: "shim is missing"})
+			state = "shims missing"
+			continue
+		}
+		// Only shims written by earlier releases run a fixed version
+		if shim.Dynamic(binName) {
+			continue
+		}
+		if !strings.HasPrefix(target, installPath+string(filepath.Separator)) {
+			rep.add(ExitMisconfigured, Finding{Package: name, Version: version, Path: binName, Message: "shim points at " + target + " (run `nori reshim`)"})
+			if state == "ok" {
+				state = "shims stale"
+			}
+		}
+	}
+	return state
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+var (
+	headerStyle = lipgloss.NewStyle().Faint(true)
+	activeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	staleStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+// columnGap separates table columns
+const columnGap = "  "
+
+// table renders rows as aligned columns. The last column absorbs whatever
+// width is left and is truncated to fit the terminal.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+// newTable creates a table with the given column headers
+func newTable(headers ...string) *table {
+	return &table{headers: headers}
+}
+
+// addRow appends a row; cells may contain ANSI styling
+func (t *table) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// render writes the table to w. A width of 0 disables truncation.
+func (t *table) render(w io.Writer, width int) {
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		widths[i] = lipgloss.Width(h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if cw := lipgloss.Width(cell); cw > widths[i] {
+				widths[i] = cw
+			}
+		}
+	}
+
+	// Whatever the fixed columns leave over goes to the last one
+	last := len(t.headers) - 1
+	lastWidth := 0
+	if width > 0 {
+		used := 0
+		for _, cw := range widths[:last] {
+			used += cw + len(columnGap)
+		}
+		lastWidth = width - used
+		if lastWidth < 10 {
+			lastWidth = 10
+		}
+	}
+
+	writeRow := func(cells []string, style *lipgloss.Style) {
+		var b strings.Builder
+		for i, cell := range cells {
+			if i == last {
+				if lastWidth > 0 {
+					cell = ansi.Truncate(cell, lastWidth, "…")
+				}
+			} else {
+				cell += strings.Repeat(" ", widths[i]-lipgloss.Width(cell)) + columnGap
+			}
+			b.WriteString(cell)
+		}
+		line := strings.TrimRight(b.String(), " ")
+		if style != nil {
+			line = style.Render(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	writeRow(t.headers, &headerStyle)
+	for _, row := range t.rows {
+		writeRow(row, nil)
+	}
+}
+
+// terminalWidth returns the width available for table output, or 0 when
+// stdout is not a terminal and output should not be truncated.
+// $COLUMNS takes precedence when set.
+func terminalWidth() int {
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		return 0
+	}
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// formatCount abbreviates a count for display, e.g. 1234 as 1.2k; zero is shown as "-"
+func formatCount(n int64) string {
+	switch {
+	case n <= 0:
+		return "-"
+	case n >= 1_000_000:
+		return strconv.FormatFloat(float64(n)/1_000_000, 'f', 1, 64) + "M"
+	case n >= 1_000:
+		return strconv.FormatFloat(float64(n)/1_000, 'f', 1, 64) + "k"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// formatDate shows a date as YYYY-MM-DD; the zero time is shown as "-"
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
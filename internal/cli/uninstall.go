@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/shims"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// UninstallCommand handles the `nori uninstall` command. Removing the active version
+// also removes its shims and clears it from active.yaml. Uninstalling a group releases
+// its members, and --autoremove then removes the versions nothing needs anymore.
+func UninstallCommand(ctx context.Context, c *urfavecli.Command) error {
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
+	if c.NArg() == 0 {
+		if !c.Bool("autoremove") {
+			return fmt.Errorf("usage: nori uninstall <package>@<version>, nori uninstall <package> --all or nori uninstall --autoremove")
+		}
+		removed, err := removeOrphans(paths, p, c.Bool("force"))
+		if err == nil && removed == 0 {
+			fmt.Println("Nothing to remove: every installed version is still needed")
+		}
+		return err
+	}
+
+	pkgName, version, hasVersion := strings.Cut(c.Args().Get(0), "@")
+
+	var versions []string
+	switch {
+	case c.Bool("all"):
+		if hasVersion {
+			return fmt.Errorf("--all removes every version: use `nori uninstall %s --all`", pkgName)
+		}
+		st, err := state.New(paths).Load()
+		if err != nil {
+			return err
+		}
+		if versions = st.Versions(pkgName, p.String()); len(versions) == 0 {
+			return fmt.Errorf("%s is not installed for %s", pkgName, p.String())
+		}
+	case !hasVersion:
+		released, err := releaseGroup(paths, pkgName, p)
+		if err != nil {
+			return err
+		}
+		if !released {
+			return fmt.Errorf("invalid format: expected <package>@<version>, or --all to remove every version")
+		}
+		return finishUninstall(paths, p, c.Bool("autoremove"), c.Bool("force"))
+	default:
+		versions = []string{version}
+	}
+
+	active, err := config.New(paths).GetActive(pkgName)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if err := uninstallVersion(paths, pkgName, version, version == active, p, c.Bool("force")); err != nil {
+			return err
+		}
+		fmt.Printf("Uninstalled %s@%s\n", pkgName, version)
+	}
+	repairShims(paths, p)
+
+	if !c.Bool("all") && active == version {
+		// A machine-wide default takes over from the user's choice
+		if now, _ := config.New(paths).GetActive(pkgName); now != "" && now != version {
+			fmt.Printf("%s@%s, the machine-wide default, is active now\n", pkgName, now)
+		} else {
+			fmt.Printf("%s has no active version now; run `nori use %s@<version>` to pick another\n", pkgName, pkgName)
+		}
+	}
+	return finishUninstall(paths, p, c.Bool("autoremove"), c.Bool("force"))
+}
+
+// releaseGroup forgets that the installed members of group were installed for it,
+// reporting whether any were
+func releaseGroup(paths platform.Paths, group string, p platform.Platform) (bool, error) {
+	var members map[string][]string
+	err := state.New(paths).Update(func(st *state.State) error {
+		members = st.Release(group, p.String())
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update state index: %w", err)
+	}
+	if len(members) == 0 {
+		return false, nil
+	}
+	fmt.Printf("Uninstalled group %s\n", group)
+	return true, nil
+}
+
+// finishUninstall removes the versions nothing needs anymore with autoremove, and
+// otherwise points them out
+func finishUninstall(paths platform.Paths, p platform.Platform, autoremove, force bool) error {
+	if autoremove {
+		_, err := removeOrphans(paths, p, force)
+		return err
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return nil
+	}
+	if orphans := orphanRefs(st.Orphans(p.String())); len(orphans) > 0 {
+		fmt.Printf("No longer needed: %s; remove with `nori uninstall --autoremove`\n", strings.Join(orphans, ", "))
+	}
+	return nil
+}
+
+// removeOrphans uninstalls every version that was only installed for groups or projects
+// that no longer need it, returning how many it removed
+func removeOrphans(paths platform.Paths, p platform.Platform, force bool) (int, error) {
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load state: %w", err)
+	}
+	orphans := st.Orphans(p.String())
+	removed := 0
+	for _, pkgName := range slices.Sorted(maps.Keys(orphans)) {
+		active, err := config.New(paths).GetActive(pkgName)
+		if err != nil {
+			return removed, err
+		}
+		for _, version := range orphans[pkgName] {
+			if err := uninstallVersion(paths, pkgName, version, version == active, p, force); err != nil {
+				return removed, err
+			}
+			fmt.Printf("Removed %s@%s, which nothing needs anymore\n", pkgName, version)
+			removed++
+		}
+	}
+	if removed > 0 {
+		repairShims(paths, p)
+	}
+	return removed, nil
+}
+
+// orphanRefs returns orphans as sorted <package>@<version> strings
+func orphanRefs(orphans map[string][]string) []string {
+	var refs []string
+	for _, pkgName := range slices.Sorted(maps.Keys(orphans)) {
+		for _, version := range orphans[pkgName] {
+			refs = append(refs, pkgName+"@"+version)
+		}
+	}
+	return refs
+}
+
+// uninstallVersion removes one installed version, and its shims and active entry when
+// it is the active version
+func uninstallVersion(paths platform.Paths, pkgName, version string, active bool, p platform.Platform, force bool) error {
+	// Find the active version's shims before its binaries are gone
+	var shimNames []string
+	if active {
+		shimNames = shimsFor(paths, pkgName, version, p)
+	}
+
+	if err := install.New(paths).Uninstall(pkgName, version, p, force); err != nil {
+		var inUse *install.InUseError
+		if errors.As(err, &inUse) {
+			return fmt.Errorf("%w; stop it first or pass --force", err)
+		}
+		return err
+	}
+	if active {
+		return deactivate(paths, pkgName, shimNames)
+	}
+	return nil
+}
+
+// deactivate forgets the active version of pkgName and removes its shims
+func deactivate(paths platform.Paths, pkgName string, shimNames []string) error {
+	if err := shims.New(paths.ShimsDir()).RemoveShims(shimNames); err != nil {
+		return err
+	}
+	if err := config.New(paths).ClearActive(pkgName); err != nil {
+		return fmt.Errorf("failed to clear active version: %w", err)
+	}
+
+	err := state.New(paths).Update(func(st *state.State) error {
+		st.SetActive(pkgName, "")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update state index (run `nori state rebuild`): %w", err)
+	}
+	return nil
+}
+
+// shimsFor returns the names of the shims that run pkgName@version: shims named after
+// its bins, and shims written by earlier releases that point into its install
+func shimsFor(paths platform.Paths, pkgName, version string, p platform.Platform) []string {
+	entries, err := os.ReadDir(paths.ShimsDir())
+	if err != nil {
+		return nil
+	}
+
+	installPath := paths.InstallPath(pkgName, version, p.String())
+	bins := make(map[string]bool)
+	if st, err := state.New(paths).Load(); err == nil {
+		if inst := st.Find(pkgName, version, p.String()); inst != nil {
+			for _, bin := range inst.Bins {
+				bins[filepath.Base(bin)] = true
+			}
+		}
+	}
+
+	shim := shims.New(paths.ShimsDir())
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		name, ok := shims.BinName(entry.Name())
+		if !ok {
+			continue
+		}
+		if entry.IsDir() || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if shim.Dynamic(name) {
+			if bins[name] {
+				names = append(names, name)
+			}
+			continue
+		}
+		target, err := shim.Target(name)
+		if err == nil && strings.HasPrefix(target, installPath+string(filepath.Separator)) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// VerifyCommand handles the `nori verify` command. It checks installed files against
+// their install receipt and, with --repair, restores only the damaged ones.
+func VerifyCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 && !c.Bool("all") {
+		return fmt.Errorf("usage: nori verify <package>[@<version>] [--repair] | --all")
+	}
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	p := platform.Detect()
+	rep := newReport("verify", c.Bool("json"))
+
+	// Verification is about what is really on disk, so skip the state index
+	st, err := state.New(paths).Scan()
+	if err != nil {
+		return err
+	}
+
+	var targets [][2]string
+	if c.Bool("all") {
+		targets = allInstalls(st, p)
+	} else {
+		parts := strings.SplitN(c.Args().Get(0), "@", 2)
+		pkgName := parts[0]
+
+		versions := st.Versions(pkgName, p.String())
+		if len(parts) == 2 {
+			versions = []string{parts[1]}
+		}
+		if len(versions) == 0 {
+			return fmt.Errorf("%s is not installed", pkgName)
+		}
+		for _, version := range versions {
+			targets = append(targets, [2]string{pkgName, version})
+		}
+	}
+
+	for _, target := range targets {
+		if err := verifyVersion(ctx, paths, target[0], target[1], p, c.Bool("repair"), rep); err != nil {
+			return err
+		}
+	}
+
+	return rep.finish()
+}
+
+// allInstalls lists every installed package and version for p as (package, version) pairs
+func allInstalls(st *state.State, p platform.Platform) [][2]string {
+	var installs [][2]string
+	for _, name := range st.Installed(p.String()) {
+		for _, version := range st.Versions(name, p.String()) {
+			installs = append(installs, [2]string{name, version})
+		}
+	}
+	return installs
+}
+
+// verifyVersion verifies one installation, repairing it when asked, and adds what is still wrong to rep
+func verifyVersion(ctx context.Context, paths platform.Paths, pkgName, version string, p platform.Platform, repair bool, rep *report) error {
+	installPath := paths.InstallPath(pkgName, version, p.String())
+	if !dirExists(installPath) {
+		return fmt.Errorf("%s@%s is not installed", pkgName, version)
+	}
+	rep.Checked++
+
+	r, err := receipt.Load(installPath)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(rep.out, "%s@%s: no install receipt; reinstall to enable verification\n", pkgName, version)
+		rep.add(ExitUnverifiable, Finding{Package: pkgName, Version: version, Message: "no install receipt"})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	problems, err := r.Verify(installPath)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s@%s: %w", pkgName, version, err)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(rep.out, "%s@%s: ok (%d files)\n", pkgName, version, len(r.Files))
+		return nil
+	}
+
+	fmt.Fprintf(rep.out, "%s@%s: %d damaged file(s)\n", pkgName, version, len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(rep.out, "  %-8s %s\n", problem.Kind, problem.Path)
+	}
+
+	if repair {
+		damaged := make(map[string]receipt.File, len(problems))
+		for _, problem := range problems {
+			damaged[problem.Path] = r.Files[problem.Path]
+		}
+		if err := repairFiles(ctx, paths, r, installPath, damaged); err != nil {
+			return fmt.Errorf("failed to repair %s@%s: %w", pkgName, version, err)
+		}
+
+		// Confirm the repair actually took
+		if problems, err = r.Verify(installPath); err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(rep.out, "%s@%s: repaired %d file(s)\n", pkgName, version, len(damaged))
+			return nil
+		}
+		fmt.Fprintf(rep.out, "%s@%s: repair incomplete\n", pkgName, version)
+	}
+
+	for _, problem := range problems {
+		rep.add(ExitDamaged, Finding{Package: pkgName, Version: version, Path: problem.Path, Message: problem.Kind})
+	}
+	return nil
+}
+
+// repairFiles re-extracts the archive an installation came from and restores the damaged files,
+// downloading the archive again, from its mirrors if the primary URL fails, only if it is no
+// longer cached
+func repairFiles(ctx context.Context, paths platform.Paths, r *receipt.Receipt, installPath string, damaged map[string]receipt.File) error {
+	data := cachedArchive(paths, r.Checksum)
+	if data == nil {
+		urls := r.URLs()
+		if len(urls) == 0 {
+			return fmt.Errorf("the archive is no longer cached and its URL was not recorded; reinstall %s@%s", r.Package, r.Version)
+		}
+		fmt.Fprintf(os.Stderr, "Downloading %s...\n", urls[0])
+		fetcher, err := proxiedFetcher(paths)
+		if err != nil {
+			return err
+		}
+		fetcher.SetPayloadChecksum(r.Payload)
+		data, err = fetcher.FetchFromMirrors(ctx, urls, r.Checksum, nil)
+		if err != nil {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		saveArchive(paths, r.Checksum, data)
+	}
+
+	extractor := newExtractor(paths)
+	extractor.SetPayloadChecksum(r.Payload)
+	extractDir, err := extractor.Extract(data, r.Type, r.Checksum)
+	if err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+	defer os.RemoveAll(extractDir)
+	if _, err := extractor.ExtractInner(extractDir, r.Inner, nil); err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+
+	return install.Repair(extractDir, installPath, damaged)
+}
+
+// cachedArchive returns the cached archive with the given checksum, or nil if it
+// isn't cached or no longer matches
+func cachedArchive(paths platform.Paths, checksum string) []byte {
+	data, err := os.ReadFile(paths.ArchivePath(checksum))
+	if err != nil || fetch.VerifyChecksum(data, checksum) != nil {
+		return nil
+	}
+	return data
+}
+
+// saveArchive keeps a downloaded archive for later repairs. Failures are ignored;
+// a missing archive only means repair has to download it again. The cache is keyed by
+// archive checksum, so a recompressed archive accepted by its payload isn't kept.
+func saveArchive(paths platform.Paths, checksum string, data []byte) {
+	if fetch.VerifyChecksum(data, checksum) != nil {
+		return
+	}
+	archivePath := paths.ArchivePath(checksum)
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return
+	}
+	fsutil.WriteFileAtomic(archivePath, data, 0644)
+}
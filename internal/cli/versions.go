@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// versionInfo is one version as printed by `nori versions --json`
+type versionInfo struct {
+	Version   string   `json:"version"`
+	Platforms []string `json:"platforms"`
+	Installed bool     `json:"installed"`
+	Active    bool     `json:"active"`
+}
+
+// VersionsCommand handles the `nori versions` command. It prints the versions of a
+// package one per line in ascending semver order, for scripts; channels are left out.
+func VersionsCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: nori versions <package>")
+	}
+
+	pkgName := c.Args().Get(0)
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	reg, err := newRegistry(paths)
+	if err != nil {
+		return err
+	}
+	m, err := reg.LoadPackage(ctx, pkgName)
+	if err != nil {
+		return fmt.Errorf("failed to load package: %w", err)
+	}
+	if m.IsGroup() {
+		return fmt.Errorf("%s is a package group; run `nori info %s` to see its members", pkgName, pkgName)
+	}
+
+	plat, err := platformFilter(c)
+	if err != nil {
+		return err
+	}
+	versions := m.SortedVersions()
+	if plat != "" {
+		versions = m.VersionsFor(plat)
+	}
+
+	if spec := c.String("constraint"); spec != "" {
+		r, err := manifest.ParseRange(spec)
+		if err != nil {
+			return err
+		}
+		versions = slices.DeleteFunc(versions, func(v string) bool {
+			parsed, err := manifest.ParseVersion(v)
+			return err != nil || !r.Matches(parsed)
+		})
+	}
+
+	// Installed versions are those for the filtered platform, or this machine's
+	installedPlat := plat
+	if installedPlat == "" {
+		installedPlat = platform.Detect().String()
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return err
+	}
+	installed := st.Versions(pkgName, installedPlat)
+	if c.Bool("installed") {
+		versions = slices.DeleteFunc(versions, func(v string) bool {
+			return !slices.Contains(installed, v)
+		})
+	}
+
+	if !c.Bool("json") {
+		for _, version := range versions {
+			fmt.Println(version)
+		}
+		return nil
+	}
+
+	active, _ := config.New(paths).GetActive(pkgName)
+	infos := make([]versionInfo, 0, len(versions))
+	for _, version := range versions {
+		infos = append(infos, versionInfo{
+			Version:   version,
+			Platforms: m.PlatformsFor(version),
+			Installed: slices.Contains(installed, version),
+			Active:    version == active && installedPlat == platform.Detect().String(),
+		})
+	}
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal versions: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
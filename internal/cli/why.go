@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/state"
+	urfavecli "github.com/urfave/cli/v3"
+)
+
+// WhyCommand handles the `nori why` command. It explains why each installed version of
+// a package is there: asked for by name, installed with a group, or pinned by a project,
+// and whether it is the active version.
+func WhyCommand(ctx context.Context, c *urfavecli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("usage: nori why <package>[@<version>]")
+	}
+	pkgName, version, _ := strings.Cut(c.Args().Get(0), "@")
+
+	paths, err := loadPaths()
+	if err != nil {
+		return err
+	}
+	st, err := state.New(paths).Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	plat := platform.Detect().String()
+
+	versions := st.Versions(pkgName, plat)
+	if len(versions) == 0 {
+		return fmt.Errorf("%s is not installed", pkgName)
+	}
+	if version != "" {
+		if !slices.Contains(versions, version) {
+			return fmt.Errorf("%s@%s is not installed (installed: %s)", pkgName, version, strings.Join(versions, ", "))
+		}
+		versions = []string{version}
+	}
+
+	for i, v := range versions {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s@%s\n", style.Render(pkgName), v)
+		for _, reason := range installReasons(st.Find(pkgName, v, plat), st.Active(pkgName) == v) {
+			fmt.Printf("  %s\n", reason)
+		}
+	}
+	return nil
+}
+
+// installReasons describes why inst is installed, one reason per line
+func installReasons(inst *state.Install, active bool) []string {
+	var reasons []string
+	if inst.Requested {
+		reasons = append(reasons, "requested by name")
+	}
+	for _, group := range inst.Groups {
+		reasons = append(reasons, "member of group "+group)
+	}
+	for _, path := range inst.Projects {
+		reason := "pinned by " + path
+		if _, err := os.Stat(path); err != nil {
+			reason += " (no longer there)"
+		}
+		reasons = append(reasons, reason)
+	}
+	if !inst.Needed() {
+		reasons = append(reasons, "nothing needs it anymore; `nori uninstall --autoremove` removes it")
+	}
+	if active {
+		reasons = append(reasons, "the active version")
+	}
+	return reasons
+}
+
+// require records reason as one of the reasons version of pkgName is installed for this
+// platform. Failing to is only worth a warning, as the install itself went through.
+func require(paths platform.Paths, pkgName, version string, reason state.Reason) {
+	err := state.New(paths).Update(func(st *state.State) error {
+		st.Require(pkgName, version, platform.Detect().String(), reason)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record why %s@%s is installed: %v\n", pkgName, version, err)
+	}
+}
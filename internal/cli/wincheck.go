@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/shims"
+)
+
+// windowsSetup is what the Windows checks of `nori doctor` look at. readWindowsSetup
+// gathers it from the running system; tests fill it in, so the checks run anywhere.
+type windowsSetup struct {
+	shimsDir    string
+	systemShims bool          // shims live in %ProgramData%, see `nori init --system`
+	path        []string      // PATH entries, in order
+	nori        string        // the nori executable that shims run
+	profile     string        // %USERPROFILE%
+	policy      func() string // the effective PowerShell execution policy
+}
+
+// windowsProblem is a Windows setup problem and the command that fixes it
+type windowsProblem struct {
+	path    string
+	message string
+	fix     string
+}
+
+// shortNamePattern matches the ~N that marks an 8.3 short name, as in C:\PROGRA~1
+var shortNamePattern = regexp.MustCompile(`~[0-9]`)
+
+// readWindowsSetup returns the setup the Windows checks look at
+func readWindowsSetup(paths platform.Paths) windowsSetup {
+	nori, err := os.Executable()
+	if err != nil {
+		nori = ""
+	}
+	return windowsSetup{
+		shimsDir:    paths.ShimsDir(),
+		systemShims: paths.Shims != "",
+		path:        filepath.SplitList(os.Getenv("PATH")),
+		nori:        nori,
+		profile:     os.Getenv("USERPROFILE"),
+		policy:      executionPolicy,
+	}
+}
+
+// windowsProblems returns the ways s keeps shims from running on Windows: Microsoft
+// Store aliases ahead of them on PATH, an execution policy that blocks .ps1 shims, the
+// shims directory on PATH only by its 8.3 short name, and system shims that run a
+// nori executable other accounts can't reach
+func windowsProblems(s windowsSetup) []windowsProblem {
+	var problems []windowsProblem
+	problems = append(problems, aliasProblems(s)...)
+	problems = append(problems, shortNameProblems(s)...)
+	if p, ok := policyProblem(s); ok {
+		problems = append(problems, p)
+	}
+	if p, ok := layoutProblem(s); ok {
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// aliasProblems reports app execution aliases, such as the python.exe that opens the
+// Microsoft Store, in WindowsApps directories that come before the shims on PATH
+func aliasProblems(s windowsSetup) []windowsProblem {
+	bins := shimNames(s.shimsDir)
+	var problems []windowsProblem
+	for _, dir := range s.path {
+		if samePath(dir, s.shimsDir) {
+			break
+		}
+		if !isWindowsApps(dir) {
+			continue
+		}
+		var aliases []string
+		for _, bin := range bins {
+			// Aliases are reparse points that Stat can't follow, so look at the link itself
+			if _, err := os.Lstat(filepath.Join(dir, bin+".exe")); err == nil {
+				aliases = append(aliases, bin)
+			}
+		}
+		if len(aliases) == 0 {
+			continue
+		}
+		problems = append(problems, windowsProblem{
+			path: dir,
+			message: fmt.Sprintf("%s run%s the app execution alias in %s instead of nori's shim; turn it off under Settings > Apps > Advanced app settings > App execution aliases, or put the shims directory first on PATH",
+				strings.Join(aliases, ", "), pluralVerb(len(aliases)), dir),
+			fix: prependPathCommand(s.shimsDir, pathScope(s)),
+		})
+	}
+	return problems
+}
+
+// shortNameProblems reports the shims directory listed on PATH only by an 8.3 short
+// name, which PATH checks, and tools that compare paths, don't recognize
+func shortNameProblems(s windowsSetup) []windowsProblem {
+	var problems []windowsProblem
+	for _, dir := range s.path {
+		if samePath(dir, s.shimsDir) {
+			return nil
+		}
+		if !shortNamePattern.MatchString(dir) {
+			continue
+		}
+		long, err := filepath.EvalSymlinks(dir)
+		if err != nil || !samePath(long, s.shimsDir) {
+			continue
+		}
+		problems = append(problems, windowsProblem{
+			path:    dir,
+			message: fmt.Sprintf("PATH lists the shims directory by its 8.3 short name %s; list it as %s", dir, s.shimsDir),
+			fix: fmt.Sprintf("[Environment]::SetEnvironmentVariable('Path', [Environment]::GetEnvironmentVariable('Path', '%[1]s').Replace(%[2]s, %[3]s), '%[1]s')",
+				pathScope(s), psQuote(dir), psQuote(s.shimsDir)),
+		})
+	}
+	return problems
+}
+
+// policyProblem reports an execution policy under which PowerShell refuses to run the
+// .ps1 shims written by builds without the shim executable
+func policyProblem(s windowsSetup) (windowsProblem, bool) {
+	scripts, _ := filepath.Glob(filepath.Join(s.shimsDir, "*.ps1"))
+	if len(scripts) == 0 {
+		return windowsProblem{}, false
+	}
+	switch policy := s.policy(); policy {
+	case "Restricted", "AllSigned":
+		return windowsProblem{
+			path:    s.shimsDir,
+			message: fmt.Sprintf("PowerShell's execution policy is %s, so it won't run the .ps1 shims; allow local scripts, or use a nori build with the shim executable and run `nori reshim`", policy),
+			fix:     "Set-ExecutionPolicy -Scope CurrentUser RemoteSigned",
+		}, true
+	}
+	return windowsProblem{}, false
+}
+
+// layoutProblem reports system shims that run a nori executable in the user profile of
+// whoever ran `nori init --system`, where other accounts and services can't run it
+func layoutProblem(s windowsSetup) (windowsProblem, bool) {
+	if !s.systemShims || s.nori == "" || s.profile == "" || !withinDir(s.nori, s.profile) {
+		return windowsProblem{}, false
+	}
+	return windowsProblem{
+		path:    s.nori,
+		message: fmt.Sprintf("system shims run %s, inside a user profile that other accounts can't read; install nori for all users and rewrite the shims", s.nori),
+		fix: fmt.Sprintf(`New-Item -ItemType Directory -Force "$env:ProgramFiles\nori"; Copy-Item %s "$env:ProgramFiles\nori\nori.exe"; & "$env:ProgramFiles\nori\nori.exe" reshim`,
+			psQuote(s.nori)),
+	}, true
+}
+
+// executionPolicy returns the effective PowerShell execution policy, or "" if
+// PowerShell can't be asked
+func executionPolicy() string {
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "Get-ExecutionPolicy").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// shimNames returns the names of the binaries shimmed in shimsDir
+func shimNames(shimsDir string) []string {
+	entries, _ := os.ReadDir(shimsDir)
+	var names []string
+	for _, entry := range entries {
+		if name, ok := shims.BinName(entry.Name()); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isWindowsApps reports whether dir is a WindowsApps directory, where Windows keeps
+// app execution aliases
+func isWindowsApps(dir string) bool {
+	dir = strings.TrimRight(strings.ReplaceAll(dir, `\`, "/"), "/")
+	return strings.HasSuffix(strings.ToLower(dir), "/microsoft/windowsapps")
+}
+
+// withinDir reports whether path is inside dir, ignoring case as Windows does
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(strings.ToLower(filepath.Clean(dir)), strings.ToLower(filepath.Clean(path)))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// pathScope returns the environment variable scope whose Path holds the shims directory
+func pathScope(s windowsSetup) string {
+	if s.systemShims {
+		return "Machine"
+	}
+	return "User"
+}
+
+// prependPathCommand returns a PowerShell command that puts dir first in the Path of scope
+func prependPathCommand(dir, scope string) string {
+	return fmt.Sprintf("[Environment]::SetEnvironmentVariable('Path', %s + ';' + [Environment]::GetEnvironmentVariable('Path', '%s'), '%s')",
+		psQuote(dir), scope, scope)
+}
+
+// pluralVerb returns the "s" of a verb whose subject counts n things
+func pluralVerb(n int) string {
+	if n == 1 {
+		return "s"
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// windowsTestSetup returns a setup with an empty shims directory, no PATH and a policy
+// that fails the test if it is asked for
+func windowsTestSetup(t *testing.T) windowsSetup {
+	t.Helper()
+	shimsDir := filepath.Join(t.TempDir(), "shims")
+	if err := os.MkdirAll(shimsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return windowsSetup{
+		shimsDir: shimsDir,
+		policy: func() string {
+			t.Error("execution policy looked up without .ps1 shims")
+			return ""
+		},
+	}
+}
+
+func TestWindowsAliasProblems(t *testing.T) {
+	s := windowsTestSetup(t)
+	os.WriteFile(filepath.Join(s.shimsDir, "python"), nil, 0755)
+	os.WriteFile(filepath.Join(s.shimsDir, "node"), nil, 0755)
+	apps := filepath.Join(t.TempDir(), "Microsoft", "WindowsApps")
+	os.MkdirAll(apps, 0755)
+	os.WriteFile(filepath.Join(apps, "python.exe"), nil, 0755)
+
+	s.path = []string{apps, s.shimsDir}
+	problems := windowsProblems(s)
+	if len(problems) != 1 || !strings.HasPrefix(problems[0].message, "python runs the app execution alias") {
+		t.Fatalf("windowsProblems() = %+v, want the python alias", problems)
+	}
+	if !strings.Contains(problems[0].fix, "'"+s.shimsDir+"' + ';'") || !strings.Contains(problems[0].fix, "'User'") {
+		t.Errorf("fix = %q, want the shims directory prepended to the user PATH", problems[0].fix)
+	}
+
+	// Aliases after the shims don't matter
+	s.path = []string{s.shimsDir, apps}
+	if problems := windowsProblems(s); len(problems) != 0 {
+		t.Errorf("windowsProblems() = %+v, want none", problems)
+	}
+}
+
+func TestWindowsShortNameProblems(t *testing.T) {
+	s := windowsTestSetup(t)
+	short := filepath.Join(filepath.Dir(s.shimsDir), "SHIMS~1")
+	if err := os.Symlink(s.shimsDir, short); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	s.path = []string{short}
+	problems := windowsProblems(s)
+	if len(problems) != 1 || problems[0].path != short || !strings.Contains(problems[0].fix, ".Replace('"+short+"', '"+s.shimsDir+"')") {
+		t.Fatalf("windowsProblems() = %+v, want the short name replaced", problems)
+	}
+
+	// Listed by its long name as well, the shims are found
+	s.path = []string{s.shimsDir, short}
+	if problems := windowsProblems(s); len(problems) != 0 {
+		t.Errorf("windowsProblems() = %+v, want none", problems)
+	}
+}
+
+func TestWindowsPolicyProblem(t *testing.T) {
+	s := windowsTestSetup(t)
+	os.WriteFile(filepath.Join(s.shimsDir, "node.ps1"), nil, 0644)
+	for policy, want := range map[string]int{"Restricted": 1, "AllSigned": 1, "RemoteSigned": 0, "": 0} {
+		s.policy = func() string { return policy }
+		problems := windowsProblems(s)
+		if len(problems) != want {
+			t.Errorf("policy %q: windowsProblems() = %+v, want %d problem(s)", policy, problems, want)
+		}
+		if want > 0 && problems[0].fix != "Set-ExecutionPolicy -Scope CurrentUser RemoteSigned" {
+			t.Errorf("policy %q: fix = %q", policy, problems[0].fix)
+		}
+	}
+}
+
+func TestWindowsLayoutProblem(t *testing.T) {
+	s := windowsTestSetup(t)
+	s.profile = filepath.Join(t.TempDir(), "Users", "ana")
+	s.nori = filepath.Join(s.profile, "bin", "nori.exe")
+
+	// Per-user shims may run nori from the profile
+	if problems := windowsProblems(s); len(problems) != 0 {
+		t.Errorf("windowsProblems() = %+v, want none", problems)
+	}
+
+	s.systemShims = true
+	problems := windowsProblems(s)
+	if len(problems) != 1 || problems[0].path != s.nori || !strings.Contains(problems[0].fix, "reshim") {
+		t.Fatalf("windowsProblems() = %+v, want nori moved out of the profile", problems)
+	}
+
+	s.nori = filepath.Join(filepath.Dir(s.profile), "nori", "nori.exe")
+	if problems := windowsProblems(s); len(problems) != 0 {
+		t.Errorf("windowsProblems() = %+v, want none", problems)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/chirag-bruno/nori/internal/fsutil"
 	"github.com/chirag-bruno/nori/internal/platform"
 	"gopkg.in/yaml.v3"
 )
@@ -11,9 +12,27 @@ import (
 // ActiveConfig represents the active versions configuration
 type ActiveConfig map[string]string
 
+// Config reads and writes nori's configuration files
+type Config struct {
+	paths platform.Paths
+}
+
+// New creates a config store for the given nori paths
+func New(paths platform.Paths) *Config {
+	return &Config{
+		paths: paths,
+	}
+}
+
+// ActiveLayer is one file of active versions
+type ActiveLayer struct {
+	Path     string
+	Versions ActiveConfig
+}
+
 // GetActive returns the active version for a package
-func GetActive(pkg string) (string, error) {
-	active, err := loadActive()
+func (c *Config) GetActive(pkg string) (string, error) {
+	active, err := c.ListActive()
 	if err != nil {
 		return "", err
 	}
@@ -22,26 +41,85 @@ func GetActive(pkg string) (string, error) {
 }
 
 // SetActive sets the active version for a package
-func SetActive(pkg, version string) error {
-	active, err := loadActive()
+func (c *Config) SetActive(pkg, version string) error {
+	active, err := c.loadActive()
 	if err != nil {
 		active = make(ActiveConfig)
 	}
 	
 	active[pkg] = version
 	
-	return saveActive(active)
+	return c.saveActive(active)
 }
 
-// ListActive returns all active versions
-func ListActive() (ActiveConfig, error) {
-	return loadActive()
+// ClearActive removes the active version for a package
+func (c *Config) ClearActive(pkg string) error {
+	active, err := c.loadActive()
+	if err != nil {
+		return err
+	}
+	if _, ok := active[pkg]; !ok {
+		return nil
+	}
+	
+	delete(active, pkg)
+	
+	return c.saveActive(active)
 }
 
-// loadActive loads the active.yaml file
-func loadActive() (ActiveConfig, error) {
-	activePath := platform.ActiveConfigPath()
+// ListActive returns all active versions: the user's, and the machine-wide defaults for
+// packages the user hasn't picked a version of
+func (c *Config) ListActive() (ActiveConfig, error) {
+	layers, err := c.ActiveLayers()
+	if err != nil {
+		return nil, err
+	}
 	
+	active := make(ActiveConfig)
+	for i := len(layers) - 1; i >= 0; i-- {
+		for pkg, version := range layers[i].Versions {
+			active[pkg] = version
+		}
+	}
+	return active, nil
+}
+
+// ActiveLayers returns the files active versions come from, in order of precedence: the
+// user's active.yaml, then the machine-wide defaults. Only the user's are ever written.
+func (c *Config) ActiveLayers() ([]ActiveLayer, error) {
+	active, err := c.loadActive()
+	if err != nil {
+		return nil, err
+	}
+	layers := []ActiveLayer{{Path: c.paths.ActiveConfigPath(), Versions: active}}
+	
+	if systemPath := c.paths.SystemActiveConfigPath(); systemPath != "" {
+		defaults, err := c.SystemDefaults()
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, ActiveLayer{Path: systemPath, Versions: defaults})
+	}
+	return layers, nil
+}
+
+// SystemDefaults returns the machine-wide default versions, which base images and
+// administrators set in the system configuration directory
+func (c *Config) SystemDefaults() (ActiveConfig, error) {
+	systemPath := c.paths.SystemActiveConfigPath()
+	if systemPath == "" {
+		return make(ActiveConfig), nil
+	}
+	return loadActiveFile(systemPath)
+}
+
+// loadActive loads the user's active.yaml file
+func (c *Config) loadActive() (ActiveConfig, error) {
+	return loadActiveFile(c.paths.ActiveConfigPath())
+}
+
+// loadActiveFile loads a file of active versions, which may not exist
+func loadActiveFile(activePath string) (ActiveConfig, error) {
 	data, err := os.ReadFile(activePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -52,7 +130,7 @@ func loadActive() (ActiveConfig, error) {
 	
 	var active ActiveConfig
 	if err := yaml.Unmarshal(data, &active); err != nil {
-		return nil, fmt.Errorf("failed to parse active config: %w", err)
+		return nil, fmt.Errorf("failed to parse active config %s: %w", activePath, err)
 	}
 	
 	if active == nil {
@@ -63,12 +141,12 @@ func loadActive() (ActiveConfig, error) {
 }
 
 // saveActive saves the active.yaml file
-func saveActive(active ActiveConfig) error {
-	activePath := platform.ActiveConfigPath()
+func (c *Config) saveActive(active ActiveConfig) error {
+	activePath := c.paths.ActiveConfigPath()
 	
 	// Ensure config directory exists
-	configDir := platform.ConfigDir()
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	configDir := c.paths.ConfigDir()
+	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 	
@@ -77,7 +155,7 @@ func saveActive(active ActiveConfig) error {
 		return fmt.Errorf("failed to marshal active config: %w", err)
 	}
 	
-	if err := os.WriteFile(activePath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(activePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write active config: %w", err)
 	}
 	
@@ -8,102 +8,154 @@ import (
 )
 
 func TestGetActive(t *testing.T) {
-	// Use real config directory but clean up after
-	activePath := platform.ActiveConfigPath()
-	defer os.Remove(activePath)
+	paths := platform.NewPaths(t.TempDir())
+	cfg := New(paths)
+	activePath := paths.ActiveConfigPath()
 	
 	// Create active.yaml
-	configDir := platform.ConfigDir()
+	configDir := paths.ConfigDir()
 	os.MkdirAll(configDir, 0755)
 	os.WriteFile(activePath, []byte(`node: "22.2.0"
 python: "3.12.0"
 `), 0644)
 	
 	// Test reading
-	version, err := GetActive("node")
+	version, err := cfg.GetActive("node")
 	if err != nil {
-		t.Fatalf("GetActive() failed: %v", err)
+		t.Fatalf("cfg.GetActive() failed: %v", err)
 	}
 	if version != "22.2.0" {
-		t.Errorf("GetActive() = %q, want %q", version, "22.2.0")
+		t.Errorf("cfg.GetActive() = %q, want %q", version, "22.2.0")
 	}
 	
-	version, err = GetActive("python")
+	version, err = cfg.GetActive("python")
 	if err != nil {
-		t.Fatalf("GetActive() failed: %v", err)
+		t.Fatalf("cfg.GetActive() failed: %v", err)
 	}
 	if version != "3.12.0" {
-		t.Errorf("GetActive() = %q, want %q", version, "3.12.0")
+		t.Errorf("cfg.GetActive() = %q, want %q", version, "3.12.0")
 	}
 	
 	// Test non-existent package
-	version, err = GetActive("nonexistent")
+	version, err = cfg.GetActive("nonexistent")
 	if err != nil {
-		t.Fatalf("GetActive() should not fail for non-existent package")
+		t.Fatalf("cfg.GetActive() should not fail for non-existent package")
 	}
 	if version != "" {
-		t.Errorf("GetActive() for non-existent = %q, want empty", version)
+		t.Errorf("cfg.GetActive() for non-existent = %q, want empty", version)
 	}
 }
 
 func TestSetActive(t *testing.T) {
-	// Use real config directory but clean up after
-	activePath := platform.ActiveConfigPath()
-	defer os.Remove(activePath)
+	cfg := New(platform.NewPaths(t.TempDir()))
 	
-	err := SetActive("node", "22.2.0")
+	err := cfg.SetActive("node", "22.2.0")
 	if err != nil {
-		t.Fatalf("SetActive() failed: %v", err)
+		t.Fatalf("cfg.SetActive() failed: %v", err)
 	}
 	
 	// Verify it was written
-	version, err := GetActive("node")
+	version, err := cfg.GetActive("node")
 	if err != nil {
-		t.Fatalf("GetActive() failed: %v", err)
+		t.Fatalf("cfg.GetActive() failed: %v", err)
 	}
 	if version != "22.2.0" {
-		t.Errorf("GetActive() = %q, want %q", version, "22.2.0")
+		t.Errorf("cfg.GetActive() = %q, want %q", version, "22.2.0")
 	}
 	
 	// Update to new version
-	err = SetActive("node", "20.5.1")
+	err = cfg.SetActive("node", "20.5.1")
 	if err != nil {
-		t.Fatalf("SetActive() failed: %v", err)
+		t.Fatalf("cfg.SetActive() failed: %v", err)
 	}
 	
-	version, err = GetActive("node")
+	version, err = cfg.GetActive("node")
 	if err != nil {
-		t.Fatalf("GetActive() failed: %v", err)
+		t.Fatalf("cfg.GetActive() failed: %v", err)
 	}
 	if version != "20.5.1" {
-		t.Errorf("GetActive() = %q, want %q", version, "20.5.1")
+		t.Errorf("cfg.GetActive() = %q, want %q", version, "20.5.1")
+	}
+}
+
+func TestClearActive(t *testing.T) {
+	cfg := New(platform.NewPaths(t.TempDir()))
+	cfg.SetActive("node", "22.2.0")
+	cfg.SetActive("python", "3.12.0")
+	
+	if err := cfg.ClearActive("node"); err != nil {
+		t.Fatalf("cfg.ClearActive() failed: %v", err)
+	}
+	if err := cfg.ClearActive("nonexistent"); err != nil {
+		t.Fatalf("cfg.ClearActive() should not fail for non-existent package: %v", err)
+	}
+	
+	active, _ := cfg.ListActive()
+	if _, ok := active["node"]; ok || active["python"] != "3.12.0" {
+		t.Errorf("active after ClearActive(node) = %v, want only python", active)
 	}
 }
 
 func TestListActive(t *testing.T) {
-	// Use real config directory but clean up after
-	activePath := platform.ActiveConfigPath()
-	defer os.Remove(activePath)
+	cfg := New(platform.NewPaths(t.TempDir()))
 	
 	// Set multiple active versions
-	SetActive("node", "22.2.0")
-	SetActive("python", "3.12.0")
+	cfg.SetActive("node", "22.2.0")
+	cfg.SetActive("python", "3.12.0")
 	
-	active, err := ListActive()
+	active, err := cfg.ListActive()
 	if err != nil {
-		t.Fatalf("ListActive() failed: %v", err)
+		t.Fatalf("cfg.ListActive() failed: %v", err)
 	}
 	
 	if len(active) != 2 {
-		t.Errorf("ListActive() count = %d, want 2", len(active))
+		t.Errorf("cfg.ListActive() count = %d, want 2", len(active))
 	}
 	
 	if active["node"] != "22.2.0" {
-		t.Errorf("ListActive() node = %q, want %q", active["node"], "22.2.0")
+		t.Errorf("cfg.ListActive() node = %q, want %q", active["node"], "22.2.0")
 	}
 	
 	if active["python"] != "3.12.0" {
-		t.Errorf("ListActive() python = %q, want %q", active["python"], "3.12.0")
+		t.Errorf("cfg.ListActive() python = %q, want %q", active["python"], "3.12.0")
 	}
 }
 
+func TestSystemDefaults(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	paths.System = t.TempDir()
+	os.WriteFile(paths.SystemActiveConfigPath(), []byte("node: 20.0.0\ngo: 1.22.0\n"), 0644)
+	cfg := New(paths)
+	
+	// The user's choice overrides the default for the same package
+	cfg.SetActive("node", "22.2.0")
+	active, err := cfg.ListActive()
+	if err != nil {
+		t.Fatalf("cfg.ListActive() failed: %v", err)
+	}
+	if active["node"] != "22.2.0" || active["go"] != "1.22.0" {
+		t.Errorf("cfg.ListActive() = %v, want node from the user and go from the defaults", active)
+	}
+	if version, _ := cfg.GetActive("go"); version != "1.22.0" {
+		t.Errorf("cfg.GetActive(go) = %q, want the default", version)
+	}
+	
+	layers, err := cfg.ActiveLayers()
+	if err != nil || len(layers) != 2 || layers[0].Path != paths.ActiveConfigPath() || layers[1].Path != paths.SystemActiveConfigPath() {
+		t.Fatalf("cfg.ActiveLayers() = %+v, %v, want the user's file, then the defaults", layers, err)
+	}
+	if _, ok := layers[0].Versions["go"]; ok {
+		t.Error("defaults should not be written to the user's file")
+	}
+	
+	// Clearing the user's choice falls back to the default, which is never changed
+	cfg.ClearActive("node")
+	if version, _ := cfg.GetActive("node"); version != "20.0.0" {
+		t.Errorf("cfg.GetActive(node) after ClearActive = %q, want the default", version)
+	}
+	
+	// Without a system directory, there are no defaults
+	if active, _ := New(platform.NewPaths(paths.Root)).ListActive(); len(active) != 0 {
+		t.Errorf("ListActive() without a system directory = %v, want none", active)
+	}
+}
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings represents user preferences stored in config.yaml
+type Settings struct {
+	// AutoUse activates a version immediately after it is installed
+	AutoUse bool `yaml:"auto_use,omitempty"`
+
+	// Strict turns risky-operation warnings (such as downgrades) into errors
+	Strict bool `yaml:"strict,omitempty"`
+
+	// SystemShims places shims in the machine-wide directory set up by `nori init --system`
+	SystemShims bool `yaml:"system_shims,omitempty"`
+
+	// TmpDir is where archives are staged while they are extracted, instead of the tmp
+	// directory under the nori root. NORI_TMPDIR takes precedence.
+	TmpDir string `yaml:"tmp_dir,omitempty"`
+
+	// AssetProxy is the prefix of a read-through caching proxy that downloads are sent
+	// through, e.g. https://artifacts.example.com/nori-remote. NORI_ASSET_PROXY takes precedence.
+	AssetProxy string `yaml:"asset_proxy,omitempty"`
+
+	// Proxy is an HTTP(S) proxy, such as http://proxy.example.com:3128, that registry and
+	// download requests go through instead of the one in HTTPS_PROXY or HTTP_PROXY
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// NoProxy lists the hosts reached without Proxy, comma-separated like NO_PROXY
+	NoProxy string `yaml:"no_proxy,omitempty"`
+
+	// CAFile is a PEM file of CA certificates that registries and download servers may be
+	// signed by, on top of the system's trusted certificates
+	CAFile string `yaml:"ca_file,omitempty"`
+
+	// ClientCert and ClientKey are the PEM client certificate and key presented to
+	// servers that require mutual TLS
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+
+	// RegistryURL is the base URL of the default registry, instead of nori's own.
+	// NORI_REGISTRY_URL takes precedence.
+	RegistryURL string `yaml:"registry_url,omitempty"`
+
+	// Provenance is how the provenance attestations of the default registry's assets
+	// are checked
+	Provenance ProvenancePolicy `yaml:"provenance,omitempty"`
+
+	// Registries are looked up before the default registry, in order, such as a
+	// company registry whose packages take precedence over public ones
+	Registries []RegistrySource `yaml:"registries,omitempty"`
+
+	// Auth holds the credentials sent to registries and download servers, keyed by host
+	// name, such as raw.githubusercontent.com for a registry in a private GitHub repository
+	Auth map[string]HostAuth `yaml:"auth,omitempty"`
+
+	// DownloadConcurrency is how many connections large downloads are split across, for
+	// servers that support ranges. NORI_DOWNLOAD_CONCURRENCY takes precedence.
+	DownloadConcurrency int `yaml:"download_concurrency,omitempty"`
+
+	// LimitRate caps the speed of each download, as a rate such as 500k or 2M bytes per
+	// second. --limit-rate takes precedence.
+	LimitRate string `yaml:"limit_rate,omitempty"`
+
+	// NoPathCheck turns off the daily check that the shims directory is on PATH and
+	// not shadowed, which otherwise prints a hint from any command
+	NoPathCheck bool `yaml:"no_path_check,omitempty"`
+
+	// LogFile is where a JSON Lines event log is appended when --log-file isn't given
+	LogFile string `yaml:"log_file,omitempty"`
+
+	// UpgradeNotice prints a one-line note from any command when installed packages are
+	// behind the cached registry, at most once per UpgradeNoticeInterval
+	UpgradeNotice bool `yaml:"upgrade_notice,omitempty"`
+
+	// UpgradeNoticeInterval is how often the upgrade note may appear, as a duration such
+	// as 12h or 168h. It defaults to once a day.
+	UpgradeNoticeInterval string `yaml:"upgrade_notice_interval,omitempty"`
+
+	// DaemonPrefetch lists the packages whose latest versions `nori daemon` keeps cached
+	DaemonPrefetch []string `yaml:"daemon_prefetch,omitempty"`
+
+	// Packages holds the settings of individual packages, keyed by name
+	Packages map[string]PackageSettings `yaml:"packages,omitempty"`
+}
+
+// PackageSettings are the settings of one package. Channel and Constraint only apply
+// where no version is named, so `nori install node@20` still installs 20.
+type PackageSettings struct {
+	// Channel is installed instead of the latest release, e.g. nightly
+	Channel string `yaml:"channel,omitempty"`
+
+	// Constraint is a version range, such as ^22 or <1.23, that the latest release is picked from
+	Constraint string `yaml:"constraint,omitempty"`
+
+	// AutoUse overrides the auto_use setting for this package
+	AutoUse *bool `yaml:"auto_use,omitempty"`
+
+	// Env is added to the environment of the package's binaries when they run through shims
+	Env map[string]string `yaml:"env,omitempty"`
+}
+
+// RegistrySource is a registry configured in addition to the default one
+type RegistrySource struct {
+	// Name identifies the registry in search results and its cache directory
+	Name string `yaml:"name"`
+
+	// URL is the base URL the index and manifests are fetched from
+	URL string `yaml:"url"`
+
+	// Prefix, if set, namespaces the registry's packages as <prefix>/<name>, such as
+	// corp/tooling, instead of looking them up by their plain names
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Provenance is how the provenance attestations of the registry's assets are checked
+	Provenance ProvenancePolicy `yaml:"provenance,omitempty"`
+}
+
+// ProvenancePolicy is how the SLSA provenance attestations that a registry's manifests
+// reference are checked before their assets are installed
+type ProvenancePolicy struct {
+	// Mode is warn, to install an asset whose provenance doesn't verify with a warning,
+	// or enforce, to refuse it. Empty checks nothing.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Keys are PEM files of the public keys attestations are signed with, or of the CA
+	// certificates that the signing certificate of a Sigstore bundle chains to
+	Keys []string `yaml:"keys,omitempty"`
+
+	// Builders, if set, are the builder IDs an attestation must name, such as
+	// https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v2.0.0
+	Builders []string `yaml:"builders,omitempty"`
+
+	// Identities are the identities a signing certificate from a CA in Keys must be issued
+	// to: its URI or email subject alternative names, where * matches any run of
+	// characters, such as https://github.com/example/tool/.github/workflows/release.yml@*
+	Identities []string `yaml:"identities,omitempty"`
+
+	// Issuers are the OIDC issuers a signing certificate from a CA in Keys must record,
+	// such as https://token.actions.githubusercontent.com
+	Issuers []string `yaml:"issuers,omitempty"`
+
+	// TlogKeys are PEM files of the public keys of the transparency logs, such as
+	// Sigstore's Rekor, whose signed entry timestamps prove that a signing certificate
+	// from a CA in Keys was used while it was valid
+	TlogKeys []string `yaml:"tlog_keys,omitempty"`
+}
+
+// HostAuth are the credentials sent with every request to one host
+type HostAuth struct {
+	// Token is sent as a bearer token in the Authorization header
+	Token string `yaml:"token,omitempty"`
+
+	// Headers are sent as they are, e.g. X-JFrog-Art-Api for an Artifactory API key
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Package returns the settings of pkg, which are empty if it has none
+func (s *Settings) Package(pkg string) PackageSettings {
+	return s.Packages[pkg]
+}
+
+// AutoUseFor reports whether installing a version of pkg activates it
+func (s *Settings) AutoUseFor(pkg string) bool {
+	if autoUse := s.Package(pkg).AutoUse; autoUse != nil {
+		return *autoUse
+	}
+	return s.AutoUse
+}
+
+// ProvenanceFor returns the provenance setting of the registry named registry: that of its
+// entry in the registries setting, or the top-level one for the default registry, ""
+func (s *Settings) ProvenanceFor(registry string) ProvenancePolicy {
+	if registry == "" {
+		return s.Provenance
+	}
+	for _, configured := range s.Registries {
+		if configured.Name == registry {
+			return configured.Provenance
+		}
+	}
+	return ProvenancePolicy{}
+}
+
+// LoadSettings loads the config.yaml file, returning defaults if it does not exist
+func (c *Config) LoadSettings() (*Settings, error) {
+	settingsPath := c.paths.SettingsPath()
+
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	var settings Settings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// DecodeSettings parses config.yaml content into settings, on top of the values already
+// there. Unlike LoadSettings, it rejects keys that aren't settings, which are usually typos.
+func DecodeSettings(data []byte, settings *Settings) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(settings); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse settings: %w", err)
+	}
+	return nil
+}
+
+// SaveSettings saves the config.yaml file
+func (c *Config) SaveSettings(settings *Settings) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+	return c.WriteSettings(data)
+}
+
+// UpdateSettings saves settings to config.yaml like SaveSettings, but keeps the file's
+// comments and layout: settings whose values changed are rewritten where they are, those
+// no longer set are removed, and new ones are added at the end
+func (c *Config) UpdateSettings(settings *Settings) error {
+	data, err := os.ReadFile(c.paths.SettingsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read settings: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse settings: %w", err)
+	}
+	// A file of only comments has no document, so they are kept ahead of the settings
+	var comments []byte
+	if len(doc.Content) == 0 {
+		comments = data
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to update settings: %s is not a mapping", c.paths.SettingsPath())
+	}
+
+	var updated yaml.Node
+	if err := updated.Encode(settings); err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+	mergeMapping(root, &updated)
+
+	out := comments
+	if len(root.Content) > 0 {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return fmt.Errorf("failed to marshal settings: %w", err)
+		}
+		enc.Close()
+		out = append(out, buf.Bytes()...)
+	}
+	return c.WriteSettings(out)
+}
+
+// mergeMapping makes the entries of the mapping dst those of src. Entries whose values
+// are unchanged keep their comments and place, nested mappings are merged the same way,
+// other changed values are replaced where they are, entries src lacks are removed, and
+// new ones are added at the end in src's order.
+func mergeMapping(dst, src *yaml.Node) {
+	values := make(map[string]*yaml.Node, len(src.Content)/2)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		values[src.Content[i].Value] = src.Content[i+1]
+	}
+
+	seen := make(map[string]bool, len(values))
+	kept := dst.Content[:0]
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key, value := dst.Content[i], dst.Content[i+1]
+		updated, ok := values[key.Value]
+		if !ok || seen[key.Value] {
+			continue
+		}
+		seen[key.Value] = true
+		switch {
+		case value.Kind == yaml.MappingNode && updated.Kind == yaml.MappingNode:
+			mergeMapping(value, updated)
+		case !sameValue(value, updated):
+			updated.HeadComment, updated.LineComment, updated.FootComment = value.HeadComment, value.LineComment, value.FootComment
+			value = updated
+		}
+		kept = append(kept, key, value)
+	}
+	dst.Content = kept
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if !seen[src.Content[i].Value] {
+			dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+		}
+	}
+	if len(dst.Content) > 0 {
+		dst.Style = 0
+	}
+}
+
+// sameValue reports whether the nodes a and b hold the same value
+func sameValue(a, b *yaml.Node) bool {
+	var va, vb any
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// WriteSettings replaces the config.yaml file with data as it is, keeping its comments
+// and layout. The file is never made more readable than it was, and once it holds
+// credentials it is readable by its owner only.
+func (c *Config) WriteSettings(data []byte) error {
+	// Ensure config directory exists
+	configDir := c.paths.ConfigDir()
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(c.paths.SettingsPath()); err == nil {
+		perm &= info.Mode().Perm()
+	}
+	var settings Settings
+	if yaml.Unmarshal(data, &settings) == nil && len(settings.Auth) > 0 {
+		perm &= 0600
+	}
+
+	if err := fsutil.WriteFileAtomic(c.paths.SettingsPath(), data, perm); err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestLoadSettingsDefaults(t *testing.T) {
+	cfg := New(platform.NewPaths(t.TempDir()))
+
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		t.Fatalf("cfg.LoadSettings() failed: %v", err)
+	}
+	if settings.AutoUse {
+		t.Error("cfg.LoadSettings() AutoUse should default to false")
+	}
+	if settings.Strict {
+		t.Error("cfg.LoadSettings() Strict should default to false")
+	}
+}
+
+func TestSaveSettings(t *testing.T) {
+	cfg := New(platform.NewPaths(t.TempDir()))
+
+	if err := cfg.SaveSettings(&Settings{AutoUse: true, Strict: true, SystemShims: true}); err != nil {
+		t.Fatalf("cfg.SaveSettings() failed: %v", err)
+	}
+
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		t.Fatalf("cfg.LoadSettings() failed: %v", err)
+	}
+	if !settings.AutoUse {
+		t.Error("cfg.LoadSettings() AutoUse = false, want true")
+	}
+	if !settings.Strict {
+		t.Error("cfg.LoadSettings() Strict = false, want true")
+	}
+	if !settings.SystemShims {
+		t.Error("cfg.LoadSettings() SystemShims = false, want true")
+	}
+}
+
+func TestWriteSettingsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+	paths := platform.NewPaths(t.TempDir())
+	cfg := New(paths)
+	mode := func() os.FileMode {
+		info, _ := os.Stat(paths.SettingsPath())
+		return info.Mode().Perm()
+	}
+
+	if err := cfg.WriteSettings([]byte("auto_use: true\n")); err != nil || mode() != 0644 {
+		t.Errorf("cfg.WriteSettings() mode = %v, %v, want 0644", mode(), err)
+	}
+
+	// A stricter mode set by the user is kept
+	os.Chmod(paths.SettingsPath(), 0600)
+	if err := cfg.WriteSettings([]byte("strict: true\n")); err != nil || mode() != 0600 {
+		t.Errorf("cfg.WriteSettings() mode = %v, %v, want 0600 kept", mode(), err)
+	}
+
+	// Credentials make the file readable by its owner only
+	os.Chmod(paths.SettingsPath(), 0644)
+	if err := cfg.WriteSettings([]byte("auth:\n  example.com:\n    token: s3cret\n")); err != nil || mode() != 0600 {
+		t.Errorf("cfg.WriteSettings() with auth mode = %v, %v, want 0600", mode(), err)
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	cfg := New(paths)
+	os.MkdirAll(paths.ConfigDir(), 0755)
+	original := `# Settings for the build machines
+strict: true # fail on downgrades
+limit_rate: 2M
+auth:
+  # The artifact mirror
+  artifacts.example.com:
+    token: s3cret
+`
+	os.WriteFile(paths.SettingsPath(), []byte(original), 0644)
+
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		t.Fatalf("cfg.LoadSettings() failed: %v", err)
+	}
+	settings.LimitRate = ""
+	settings.Auth["files.example.com"] = HostAuth{Token: "other"}
+	settings.DownloadConcurrency = 4
+	if err := cfg.UpdateSettings(settings); err != nil {
+		t.Fatalf("cfg.UpdateSettings() failed: %v", err)
+	}
+
+	want := `# Settings for the build machines
+strict: true # fail on downgrades
+auth:
+  # The artifact mirror
+  artifacts.example.com:
+    token: s3cret
+  files.example.com:
+    token: other
+download_concurrency: 4
+`
+	if data, _ := os.ReadFile(paths.SettingsPath()); string(data) != want {
+		t.Errorf("config.yaml = %q, want %q", data, want)
+	}
+
+	// A file of only comments keeps them ahead of the settings
+	os.WriteFile(paths.SettingsPath(), []byte("# Nothing set yet\n"), 0644)
+	if err := cfg.UpdateSettings(&Settings{Strict: true}); err != nil {
+		t.Fatalf("cfg.UpdateSettings() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(paths.SettingsPath()); string(data) != "# Nothing set yet\nstrict: true\n" {
+		t.Errorf("config.yaml = %q, want the comment kept", data)
+	}
+}
+
+func TestPackageSettings(t *testing.T) {
+	cfg := New(platform.NewPaths(t.TempDir()))
+	no := false
+	err := cfg.SaveSettings(&Settings{
+		AutoUse: true,
+		Packages: map[string]PackageSettings{
+			"node":   {Constraint: "^22", AutoUse: &no, Env: map[string]string{"NODE_OPTIONS": "--max-old-space-size=4096"}},
+			"neovim": {Channel: "nightly"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("cfg.SaveSettings() failed: %v", err)
+	}
+
+	settings, err := cfg.LoadSettings()
+	if err != nil {
+		t.Fatalf("cfg.LoadSettings() failed: %v", err)
+	}
+	if got := settings.Package("node"); got.Constraint != "^22" || got.Env["NODE_OPTIONS"] != "--max-old-space-size=4096" {
+		t.Errorf("Package(node) = %+v, want the saved constraint and env", got)
+	}
+	if got := settings.Package("neovim").Channel; got != "nightly" {
+		t.Errorf("Package(neovim).Channel = %q, want nightly", got)
+	}
+	if settings.AutoUseFor("node") {
+		t.Error("AutoUseFor(node) = true, want the package override")
+	}
+	if !settings.AutoUseFor("go") {
+		t.Error("AutoUseFor(go) = false, want the global auto_use")
+	}
+}
+
+func TestDecodeSettings(t *testing.T) {
+	settings := &Settings{AutoUse: true, Packages: map[string]PackageSettings{"node": {Channel: "nightly"}}}
+	if err := DecodeSettings([]byte("strict: true\npackages:\n  go:\n    constraint: ^1.22\n"), settings); err != nil {
+		t.Fatalf("DecodeSettings() failed: %v", err)
+	}
+	if !settings.AutoUse || !settings.Strict {
+		t.Errorf("DecodeSettings() = %+v, want auto_use kept and strict added", settings)
+	}
+	if settings.Package("node").Channel != "nightly" || settings.Package("go").Constraint != "^1.22" {
+		t.Errorf("DecodeSettings() packages = %+v, want both packages", settings.Packages)
+	}
+
+	if err := DecodeSettings(nil, settings); err != nil {
+		t.Errorf("DecodeSettings(empty) = %v, want nil", err)
+	}
+	if err := DecodeSettings([]byte("auto-use: true\n"), &Settings{}); err == nil {
+		t.Error("DecodeSettings() accepted an unknown key")
+	}
+}
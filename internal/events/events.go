@@ -0,0 +1,132 @@
+// Package events writes a JSON Lines log of what nori commands do, one event per line,
+// so build systems can analyze failures without parsing human-readable output.
+//
+// The log travels in the context. Every method is a no-op on a nil *Log, so callers
+// emit events unconditionally and only pay for them when logging is enabled.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event kinds
+const (
+	CommandStart = "command_start"
+	CommandEnd   = "command_end"
+	PhaseStart   = "phase_start"
+	PhaseEnd     = "phase_end"
+	Retry        = "retry"
+	Failover     = "failover"
+)
+
+// Event is one line of the log. Fields that don't apply to an event are omitted.
+type Event struct {
+	Time    time.Time `json:"time"`
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Event   string    `json:"event"`
+
+	Args       []string `json:"args,omitempty"`
+	Package    string   `json:"package,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Phase      string   `json:"phase,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Bytes      int64    `json:"bytes,omitempty"`
+	Files      int      `json:"files,omitempty"`
+	Attempt    int      `json:"attempt,omitempty"`
+	DurationMS int64    `json:"duration_ms,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	ExitCode   int      `json:"exit_code,omitempty"`
+}
+
+// Log appends events for one command to a file
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	command string
+}
+
+// Open opens path for appending events from command, creating it if needed
+func Open(path, command string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return &Log{file: f, command: command}, nil
+}
+
+// Emit writes e, filling in the time, process and command
+func (l *Log) Emit(e Event) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	e.PID = os.Getpid()
+	e.Command = l.command
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	// One write per line keeps lines whole when several processes share the file
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file.Write(append(data, '\n'))
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Phase is a step of a command that is timed from Begin to End. Fields set on its
+// Event before End, such as Bytes, are included in the phase_end event.
+type Phase struct {
+	Event
+	log   *Log
+	start time.Time
+}
+
+// Begin emits a phase_start event for e and returns the phase to end later
+func (l *Log) Begin(e Event) *Phase {
+	e.Event = PhaseStart
+	l.Emit(e)
+	return &Phase{Event: e, log: l, start: time.Now()}
+}
+
+// End emits the phase_end event, recording err if the phase failed
+func (p *Phase) End(err error) {
+	e := p.Event
+	e.Event = PhaseEnd
+	e.DurationMS = time.Since(p.start).Milliseconds()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	p.log.Emit(e)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *Log) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the log carried by ctx, or nil if events are not being logged
+func FromContext(ctx context.Context) *Log {
+	l, _ := ctx.Value(contextKey{}).(*Log)
+	return l
+}
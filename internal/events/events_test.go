@@ -0,0 +1,86 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readEvents parses every line of the log at path
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "nori.jsonl")
+
+	log, err := Open(path, "nori install")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	log.Emit(Event{Event: CommandStart, Args: []string{"node@22.2.0"}})
+	phase := log.Begin(Event{Package: "node", Version: "22.2.0", Phase: "download"})
+	phase.Bytes = 1024
+	phase.End(errors.New("connection reset"))
+	log.Close()
+
+	// A second command appends to the same file
+	log, _ = Open(path, "nori list")
+	log.Emit(Event{Event: CommandEnd})
+	log.Close()
+
+	events := readEvents(t, path)
+	if len(events) != 4 {
+		t.Fatalf("log has %d events, want 4", len(events))
+	}
+	if e := events[0]; e.Command != "nori install" || e.Event != CommandStart || e.PID != os.Getpid() || e.Time.IsZero() {
+		t.Errorf("first event = %+v, want a stamped command_start", e)
+	}
+	if e := events[1]; e.Event != PhaseStart || e.Phase != "download" || e.Bytes != 0 {
+		t.Errorf("second event = %+v, want the download phase_start", e)
+	}
+	if e := events[2]; e.Event != PhaseEnd || e.Bytes != 1024 || e.Error != "connection reset" || e.Package != "node" {
+		t.Errorf("third event = %+v, want the failed download phase_end", e)
+	}
+	if e := events[3]; e.Command != "nori list" {
+		t.Errorf("fourth event = %+v, want it appended by the second command", e)
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var log *Log
+	log.Emit(Event{Event: CommandStart})
+	log.Begin(Event{Phase: "download"}).End(nil)
+	if err := log.Close(); err != nil {
+		t.Errorf("Close() on a nil log = %v", err)
+	}
+
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext() should be nil without a log")
+	}
+	log = &Log{}
+	if FromContext(NewContext(context.Background(), log)) != log {
+		t.Error("FromContext() should return the log from NewContext()")
+	}
+}
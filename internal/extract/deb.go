@@ -0,0 +1,65 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// arMagic starts an ar archive, the container of a deb
+const arMagic = "!<arch>\n"
+
+// arHeaderSize is the size of the header before each member of an ar archive
+const arHeaderSize = 60
+
+// extractDeb extracts the files of the Debian package in data into destDir, laid out as
+// they would be installed from the root of the filesystem. Only the data.tar member is
+// read: the control files and maintainer scripts are left out, and dpkg's database is
+// never touched.
+func (e *Extractor) extractDeb(data []byte, destDir string, progressCallback ProgressCallback) error {
+	payload, err := debData(data)
+	if err != nil {
+		return err
+	}
+	r, err := decompressed(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	links, err := newRootedLinker(destDir)
+	if err != nil {
+		return err
+	}
+	return e.extractTarLinks(r, links, progressCallback)
+}
+
+// debData returns the data.tar member of the ar archive in data, still compressed
+func debData(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(arMagic)) {
+		return nil, fmt.Errorf("not a Debian package: missing ar header")
+	}
+	rest := data[len(arMagic):]
+	for len(rest) > 0 {
+		if len(rest) < arHeaderSize {
+			return nil, fmt.Errorf("truncated ar member header")
+		}
+		hdr := rest[:arHeaderSize]
+		// GNU ar ends names with a slash
+		name := strings.TrimSuffix(strings.TrimSpace(string(hdr[:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || size < 0 || size > int64(len(rest)-arHeaderSize) {
+			return nil, fmt.Errorf("invalid size of ar member %q", name)
+		}
+		member := rest[arHeaderSize : arHeaderSize+size]
+		if strings.HasPrefix(name, "data.tar") {
+			return member, nil
+		}
+		// Members start on even offsets
+		next := arHeaderSize + size + size%2
+		if next > int64(len(rest)) {
+			next = int64(len(rest))
+		}
+		rest = rest[next:]
+	}
+	return nil, fmt.Errorf("the Debian package has no data.tar member")
+}
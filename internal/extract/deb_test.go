@@ -0,0 +1,128 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// systemTar builds a gzipped tarball laid out like a system package's payload, with a
+// binary in /opt and a link to it in /usr/bin
+func systemTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range []*tar.Header{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "./opt/vendor/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "./opt/vendor/bin/tool", Typeflag: tar.TypeReg, Mode: 0755, Size: 10},
+		{Name: "./usr/bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "./usr/bin/tool", Typeflag: tar.TypeSymlink, Linkname: "/opt/vendor/bin/tool"},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte("#!/bin/sh\n"))
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+// arArchive builds an ar archive of members, in order
+func arArchive(members ...[2]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, m := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m[0]+"/", 0, 0, 0, "100644", len(m[1]))
+		buf.WriteString(m[1])
+		if len(m[1])%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// checkSystemTree checks what systemTar's payload extracts to
+func checkSystemTree(t *testing.T, extractDir string) {
+	t.Helper()
+	if info, err := os.Stat(filepath.Join(extractDir, "opt", "vendor", "bin", "tool")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("opt/vendor/bin/tool = %v, %v, want it executable", info, err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// The absolute link leads to the package's own file
+	if target, err := os.Readlink(filepath.Join(extractDir, "usr", "bin", "tool")); err != nil || target != "../../opt/vendor/bin/tool" {
+		t.Errorf("usr/bin/tool links to %q, %v, want the package's binary", target, err)
+	}
+}
+
+func TestExtractDeb(t *testing.T) {
+	extractor := New()
+	extractor.SetTempDir(t.TempDir())
+
+	// The control member has an odd size, so data.tar starts after padding
+	data := arArchive(
+		[2]string{"debian-binary", "2.0\n"},
+		[2]string{"control.tar.gz", "control"},
+		[2]string{"data.tar.gz", string(systemTar(t))},
+	)
+	extractDir, err := extractor.Extract(data, "deb", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	checkSystemTree(t, extractDir)
+	if _, err := os.Stat(filepath.Join(extractDir, "debian-binary")); !os.IsNotExist(err) {
+		t.Errorf("a control member was extracted: %v", err)
+	}
+
+	// Debian has compressed data.tar with xz by default for years
+	tarball, err := gzip.NewReader(bytes.NewReader(systemTar(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := io.ReadAll(tarball)
+	data = arArchive([2]string{"debian-binary", "2.0\n"}, [2]string{"data.tar.xz", string(xzStored(payload))})
+	xzDir, err := extractor.Extract(data, "deb", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() of xz data failed: %v", err)
+	}
+	defer os.RemoveAll(xzDir)
+	checkSystemTree(t, xzDir)
+
+	for name, broken := range map[string][]byte{
+		"not an ar archive": []byte("PK\x03\x04"),
+		"no data member":    arArchive([2]string{"debian-binary", "2.0\n"}),
+		"corrupt xz data":   arArchive([2]string{"data.tar.xz", "\xfd7zXZ\x00..."}),
+		"escaping link": arArchive([2]string{"data.tar", string(tarOf(t, &tar.Header{
+			Name: "./usr/bin/tool", Typeflag: tar.TypeSymlink, Linkname: "/../../etc/passwd",
+		}))}),
+	} {
+		if _, err := extractor.Extract(broken, "deb", checksumOf(broken)); err == nil {
+			t.Errorf("Extract() of a deb with %s succeeded", name)
+		}
+	}
+}
+
+// tarOf builds an uncompressed tarball of entries without data
+func tarOf(t *testing.T, headers ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	return buf.Bytes()
+}
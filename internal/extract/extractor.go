@@ -3,6 +3,7 @@ package extract
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"fmt"
@@ -10,16 +11,29 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/chirag-bruno/nori/internal/fetch"
 )
 
+// paxXattrPrefix starts the PAX records that hold extended attributes
+const paxXattrPrefix = "SCHILY.xattr."
+
+// xattrNamespace is the namespace of the extended attributes kept from archives. Others,
+// such as security.* and trusted.*, need privileges or describe the packager's machine.
+const xattrNamespace = "user."
+
+// staleStaging is how old a staging directory must be before it is considered abandoned
+const staleStaging = 24 * time.Hour
+
 // ProgressCallback is called for each file extracted (for progress tracking)
 type ProgressCallback func()
 
 // Extractor handles safe extraction of archives
 type Extractor struct {
 	fetcher *fetch.Fetcher
+	tmpDir  string
+	payload string // payload checksum, see SetPayloadChecksum
 }
 
 // New creates a new extractor
@@ -29,8 +43,34 @@ func New() *Extractor {
 	}
 }
 
+// SetTempDir makes the extractor stage archives beneath dir instead of the system temp
+// directory. dir is created if needed, and staging directories that a killed nori
+// left behind in it are removed.
+func (e *Extractor) SetTempDir(dir string) {
+	e.tmpDir = dir
+}
+
+// SetPayloadChecksum makes archives whose own checksum doesn't match acceptable when
+// their decompressed payload matches sum, as for a recompressed upstream archive
+func (e *Extractor) SetPayloadChecksum(sum string) {
+	e.payload = sum
+}
+
+// removeStale removes staging directories in dir older than staleStaging
+func removeStale(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "nori-extract-") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > staleStaging {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
 // Extract extracts an archive to a temporary directory and returns the path
-// assetType can be "tar" or "zip"
+// assetType can be "tar", "zip", "deb", "rpm", or on macOS "dmg" or "pkg"
 // For tar files, it auto-detects .tar, .tar.gz, .tgz, .tar.xz
 func (e *Extractor) Extract(data []byte, assetType string, checksum string) (string, error) {
 	return e.ExtractWithProgress(data, assetType, checksum, nil)
@@ -41,19 +81,24 @@ func (e *Extractor) Extract(data []byte, assetType string, checksum string) (str
 func (e *Extractor) ExtractWithProgress(data []byte, assetType string, checksum string, progressCallback ProgressCallback) (string, error) {
 	// Verify checksum first
 	if err := fetch.VerifyChecksum(data, checksum); err != nil {
-		return "", fmt.Errorf("checksum verification failed: %w", err)
+		if e.payload == "" || assetType != "tar" {
+			return "", fmt.Errorf("checksum verification failed: %w", err)
+		}
+		if payloadErr := fetch.VerifyPayload(data, e.payload); payloadErr != nil {
+			return "", fmt.Errorf("checksum verification failed: %w (%v)", err, payloadErr)
+		}
 	}
 	
 	// Create temp directory
-	tmpDir, err := os.MkdirTemp("", "nori-extract-*")
+	tmpDir, err := e.stagingDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		return "", err
 	}
 	
 	// Extract based on type
 	switch assetType {
 	case "tar":
-		if err := e.extractTar(data, tmpDir, progressCallback); err != nil {
+		if err := e.extractTar(bytes.NewReader(data), tmpDir, progressCallback); err != nil {
 			os.RemoveAll(tmpDir)
 			return "", fmt.Errorf("failed to extract tar: %w", err)
 		}
@@ -62,6 +107,26 @@ func (e *Extractor) ExtractWithProgress(data []byte, assetType string, checksum
 			os.RemoveAll(tmpDir)
 			return "", fmt.Errorf("failed to extract zip: %w", err)
 		}
+	case "dmg":
+		if err := e.extractDMG(data, tmpDir, progressCallback); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to extract dmg: %w", err)
+		}
+	case "pkg":
+		if err := e.extractPkg(data, tmpDir, progressCallback); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to extract pkg: %w", err)
+		}
+	case "deb":
+		if err := e.extractDeb(data, tmpDir, progressCallback); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to extract deb: %w", err)
+		}
+	case "rpm":
+		if err := e.extractRpm(data, tmpDir, progressCallback); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to extract rpm: %w", err)
+		}
 	default:
 		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("unsupported asset type: %s", assetType)
@@ -70,12 +135,54 @@ func (e *Extractor) ExtractWithProgress(data []byte, assetType string, checksum
 	return tmpDir, nil
 }
 
-// extractTar extracts a tar archive (handles .tar, .tar.gz, .tgz, .tar.xz)
-func (e *Extractor) extractTar(data []byte, destDir string, progressCallback ProgressCallback) error {
-	var reader io.Reader = bytes.NewReader(data)
+// ExtractTarStream extracts a tar archive to a temporary directory as it is read from r
+// and returns the path, so extraction can keep pace with a download. Nothing is verified:
+// the caller must check the bytes read against the archive's checksum before using them.
+func (e *Extractor) ExtractTarStream(r io.Reader, progressCallback ProgressCallback) (string, error) {
+	tmpDir, err := e.stagingDir()
+	if err != nil {
+		return "", err
+	}
+	if err := e.extractTar(r, tmpDir, progressCallback); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to extract tar: %w", err)
+	}
+	return tmpDir, nil
+}
+
+// stagingDir creates a temporary directory to extract into
+func (e *Extractor) stagingDir() (string, error) {
+	if e.tmpDir != "" {
+		if err := os.MkdirAll(e.tmpDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		removeStale(e.tmpDir)
+	}
+	tmpDir, err := os.MkdirTemp(e.tmpDir, "nori-extract-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return tmpDir, nil
+}
+
+// extractTar extracts a tar archive read from r (handles .tar, .tar.gz, .tgz, .tar.xz)
+func (e *Extractor) extractTar(r io.Reader, destDir string, progressCallback ProgressCallback) error {
+	links, err := newLinker(destDir)
+	if err != nil {
+		return err
+	}
+	return e.extractTarLinks(r, links, progressCallback)
+}
+
+// extractTarLinks extracts a tar archive read from r beneath links.destDir, creating its
+// links with links
+func (e *Extractor) extractTarLinks(r io.Reader, links *linker, progressCallback ProgressCallback) error {
+	destDir := links.destDir
+	buffered := bufio.NewReader(r)
+	var reader io.Reader = buffered
 	
 	// Try to detect compression
-	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
 		// Gzip compressed
 		gzReader, err := gzip.NewReader(reader)
 		if err != nil {
@@ -86,8 +193,9 @@ func (e *Extractor) extractTar(data []byte, destDir string, progressCallback Pro
 	}
 	// TODO: Add xz support if needed
 	
+	// GNU long names and link names and PAX extended headers are read into hdr by
+	// the tar reader, so entries arrive with their full names
 	tr := tar.NewReader(reader)
-	
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -97,17 +205,28 @@ func (e *Extractor) extractTar(data []byte, destDir string, progressCallback Pro
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 		
+		// PAX global headers, devices, FIFOs and GNU volume labels hold no files
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeCont, tar.TypeGNUSparse, tar.TypeSymlink, tar.TypeLink:
+		default:
+			continue
+		}
+		
 		// Validate and sanitize path
 		path, err := sanitizePath(hdr.Name, destDir)
 		if err != nil {
 			return fmt.Errorf("invalid path %q: %w", hdr.Name, err)
 		}
+		if err := links.checkInside(path); err != nil {
+			return fmt.Errorf("invalid path %q: %w", hdr.Name, err)
+		}
 		
 		// Create directory if needed
 		if hdr.Typeflag == tar.TypeDir {
 			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)); err != nil {
 				return fmt.Errorf("failed to create directory: %w", err)
 			}
+			setXattrs(path, hdr.PAXRecords)
 			continue
 		}
 		
@@ -116,17 +235,28 @@ func (e *Extractor) extractTar(data []byte, destDir string, progressCallback Pro
 			return fmt.Errorf("failed to create parent directory: %w", err)
 		}
 		
-		// Extract file
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
-		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
+		// A later entry replaces an earlier one rather than writing through it
+		if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+			os.Remove(path)
 		}
 		
-		if _, err := io.Copy(f, tr); err != nil {
-			f.Close()
-			return fmt.Errorf("failed to write file: %w", err)
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if err := links.symlink(path, hdr.Linkname); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		case tar.TypeLink:
+			if err := links.hardlink(path, hdr.Linkname); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		default:
+			// Extract file. Sizes beyond the 8GiB of the classic header come from PAX
+			// records or GNU base-256 fields, which hdr.Size already reflects.
+			if err := writeFile(path, os.FileMode(hdr.Mode), tr, hdr.Size); err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			setXattrs(path, hdr.PAXRecords)
 		}
-		f.Close()
 		
 		// Update progress
 		if progressCallback != nil {
@@ -134,7 +264,7 @@ func (e *Extractor) extractTar(data []byte, destDir string, progressCallback Pro
 		}
 	}
 	
-	return nil
+	return links.finish()
 }
 
 // extractZip extracts a zip archive
@@ -170,21 +300,13 @@ func (e *Extractor) extractZip(data []byte, destDir string, progressCallback Pro
 			return fmt.Errorf("failed to open zip file: %w", err)
 		}
 		
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.FileInfo().Mode())
+		// Zip64 sizes and offsets are read into the 64-bit fields
+		err = writeFile(path, file.FileInfo().Mode(), rc, int64(file.UncompressedSize64))
+		rc.Close()
 		if err != nil {
-			rc.Close()
-			return fmt.Errorf("failed to create file: %w", err)
-		}
-		
-		if _, err := io.Copy(f, rc); err != nil {
-			f.Close()
-			rc.Close()
-			return fmt.Errorf("failed to write file: %w", err)
+			return fmt.Errorf("%s: %w", file.Name, err)
 		}
 		
-		f.Close()
-		rc.Close()
-		
 		// Update progress
 		if progressCallback != nil {
 			progressCallback()
@@ -194,6 +316,27 @@ func (e *Extractor) extractZip(data []byte, destDir string, progressCallback Pro
 	return nil
 }
 
+// writeFile writes an archive entry of size bytes from r to a new file at path. An entry
+// that ends early or runs long is an error rather than a truncated file.
+func writeFile(path string, mode os.FileMode, r io.Reader, size int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("archive entry holds %d bytes, but its header declares %d", n, size)
+	}
+	return nil
+}
+
 // sanitizePath validates and sanitizes a path to prevent path traversal attacks
 func sanitizePath(name, destDir string) (string, error) {
 	// Clean the path
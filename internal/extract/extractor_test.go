@@ -6,10 +6,16 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"hash/crc32"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func createTestTar(t *testing.T) []byte {
@@ -121,6 +127,54 @@ func TestExtractTar(t *testing.T) {
 	}
 }
 
+func TestExtractTempDir(t *testing.T) {
+	data := createTestTar(t)
+	hash := sha256.Sum256(data)
+	checksum := "sha256:" + hex.EncodeToString(hash[:])
+	
+	staging := filepath.Join(t.TempDir(), "staging")
+	extractor := New()
+	extractor.SetTempDir(staging)
+	extractDir, err := extractor.Extract(data, "tar", checksum)
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	
+	if filepath.Dir(extractDir) != staging {
+		t.Errorf("Extract() dir = %q, want it beneath %q", extractDir, staging)
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, "test.txt")); err != nil {
+		t.Errorf("test.txt not found in extracted directory: %v", err)
+	}
+	
+	// Staging directories abandoned long ago are cleaned up; recent ones may be in use
+	abandoned := filepath.Join(staging, "nori-extract-abandoned")
+	recent := filepath.Join(staging, "nori-extract-recent")
+	other := filepath.Join(staging, "other")
+	for _, dir := range []string{abandoned, recent, other} {
+		os.MkdirAll(dir, 0755)
+	}
+	old := time.Now().Add(-2 * staleStaging)
+	os.Chtimes(abandoned, old, old)
+	os.Chtimes(other, old, old)
+	
+	second, err := extractor.Extract(data, "tar", checksum)
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(second)
+	
+	if _, err := os.Stat(abandoned); !os.IsNotExist(err) {
+		t.Error("Extract() should remove abandoned staging directories")
+	}
+	for _, dir := range []string{recent, other, extractDir} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Extract() removed %s: %v", dir, err)
+		}
+	}
+}
+
 func TestExtractTarGz(t *testing.T) {
 	data := createTestTarGz(t)
 	hash := sha256.Sum256(data)
@@ -139,6 +193,58 @@ func TestExtractTarGz(t *testing.T) {
 	}
 }
 
+func TestExtractPayloadChecksum(t *testing.T) {
+	// Upstream recompressed the archive: the tar inside is unchanged
+	tarData := createTestTar(t)
+	var buf bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gw.Write(tarData)
+	gw.Close()
+	recompressed := buf.Bytes()
+	published := checksumOf(createTestTarGz(t))
+	
+	extractor := New()
+	if _, err := extractor.Extract(recompressed, "tar", published); err == nil {
+		t.Fatal("Extract() should reject a recompressed archive without a payload checksum")
+	}
+	
+	extractor.SetPayloadChecksum(checksumOf(tarData))
+	extractDir, err := extractor.Extract(recompressed, "tar", published)
+	if err != nil {
+		t.Fatalf("Extract() failed with a matching payload: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	if _, err := os.Stat(filepath.Join(extractDir, "test.txt")); err != nil {
+		t.Errorf("test.txt not extracted: %v", err)
+	}
+	
+	extractor.SetPayloadChecksum(checksumOf([]byte("other")))
+	if _, err := extractor.Extract(recompressed, "tar", published); err == nil || !strings.Contains(err.Error(), "payload checksum mismatch") {
+		t.Errorf("Extract() = %v, want a payload checksum mismatch", err)
+	}
+}
+
+func TestExtractTarStream(t *testing.T) {
+	for name, data := range map[string][]byte{"tar": createTestTar(t), "tar.gz": createTestTarGz(t)} {
+		extracted := 0
+		extractDir, err := New().ExtractTarStream(bytes.NewReader(data), func() { extracted++ })
+		if err != nil {
+			t.Fatalf("ExtractTarStream(%s) failed: %v", name, err)
+		}
+		defer os.RemoveAll(extractDir)
+		if content, err := os.ReadFile(filepath.Join(extractDir, "test.txt")); err != nil || string(content) != "hello world" {
+			t.Errorf("ExtractTarStream(%s) test.txt = %q, %v", name, content, err)
+		}
+		if extracted != 1 {
+			t.Errorf("ExtractTarStream(%s) reported %d files, want 1", name, extracted)
+		}
+	}
+	
+	if _, err := New().ExtractTarStream(strings.NewReader("not a tar"), nil); err == nil {
+		t.Error("ExtractTarStream() should fail for data that isn't a tar archive")
+	}
+}
+
 func TestExtractZip(t *testing.T) {
 	data := createTestZip(t)
 	hash := sha256.Sum256(data)
@@ -241,3 +347,298 @@ func TestExtractAbsolutePath(t *testing.T) {
 	}
 }
 
+// checksumOf returns the checksum of data in sha256:hex form
+func checksumOf(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func TestExtractTarExtensions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+	longDir := "sdk/" + strings.Repeat("platform-tools-", 8)
+	
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "0123abcd"}})
+	tw.WriteHeader(&tar.Header{Name: longDir + "/bin/tool-" + strings.Repeat("x", 80), Size: 5, Mode: 0755, Format: tar.FormatGNU})
+	tw.Write([]byte("hello"))
+	tw.WriteHeader(&tar.Header{Name: "sdk/bin/tool", Typeflag: tar.TypeSymlink, Linkname: "../" + longDir[4:] + "/bin/tool-" + strings.Repeat("x", 80), Format: tar.FormatGNU})
+	tw.WriteHeader(&tar.Header{Name: "sdk/bin/hard", Typeflag: tar.TypeLink, Linkname: "sdk/bin/tool"})
+	tw.WriteHeader(&tar.Header{Name: "sdk/fifo", Typeflag: tar.TypeFifo, Mode: 0644})
+	tw.Close()
+	data := buf.Bytes()
+	
+	extractDir, err := New().Extract(data, "tar", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	
+	if entries, _ := os.ReadDir(extractDir); len(entries) != 1 || entries[0].Name() != "sdk" {
+		t.Errorf("extracted %v, want only sdk and no pax_global_header", entries)
+	}
+	if content, err := os.ReadFile(filepath.Join(extractDir, longDir, "bin", "tool-"+strings.Repeat("x", 80))); err != nil || string(content) != "hello" {
+		t.Errorf("long name = %q, %v, want the file under its full GNU long name", content, err)
+	}
+	if info, err := os.Lstat(filepath.Join(extractDir, "sdk", "bin", "tool")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("sdk/bin/tool = %v, %v, want a symlink", info, err)
+	}
+	for _, name := range []string{"tool", "hard"} {
+		if content, err := os.ReadFile(filepath.Join(extractDir, "sdk", "bin", name)); err != nil || string(content) != "hello" {
+			t.Errorf("%s = %q, %v, want the link to reach the long-named file", name, content, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(extractDir, "sdk", "fifo")); !os.IsNotExist(err) {
+		t.Error("Extract() should skip FIFOs")
+	}
+}
+
+func TestExtractTarLinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+	
+	cases := map[string][]*tar.Header{
+		"absolute":  {{Name: "lib", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		"relative":  {{Name: "lib", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}},
+		"hard link": {{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "../etc/passwd"}},
+		"chained": {
+			{Name: "here", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "here/.."},
+		},
+		"written through": {
+			{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "here/.."},
+			{Name: "here", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "up/evil", Size: 4, Mode: 0644},
+		},
+		// A link whose target doesn't exist yet escapes once a later link is in place
+		"dangling": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "d/d/../../marker"},
+			{Name: "d", Typeflag: tar.TypeSymlink, Linkname: "."},
+		},
+		"cycle": {
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "b/x"},
+			{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a/x"},
+		},
+	}
+	for name, hdrs := range cases {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			tw.WriteHeader(hdr)
+			tw.Write(make([]byte, hdr.Size))
+		}
+		tw.Close()
+		data := buf.Bytes()
+		
+		if _, err := New().Extract(data, "tar", checksumOf(data)); err == nil {
+			t.Errorf("%s: Extract() should reject links that lead outside the archive", name)
+		}
+	}
+
+	// A link may come before its target
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "lib/libtool.so", Typeflag: tar.TypeSymlink, Linkname: "libtool.so.1"})
+	tw.WriteHeader(&tar.Header{Name: "lib/libtool.so.1", Size: 3, Mode: 0644})
+	tw.Write([]byte("elf"))
+	tw.Close()
+	data := buf.Bytes()
+	extractDir, err := New().Extract(data, "tar", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() of a link before its target failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	if content, err := os.ReadFile(filepath.Join(extractDir, "lib", "libtool.so")); err != nil || string(content) != "elf" {
+		t.Errorf("libtool.so = %q, %v, want the target's content", content, err)
+	}
+}
+
+// tarBlock returns a ustar header block for name. size is the octal size field, so
+// callers can leave it empty or replace it with an encoding of their own.
+func tarBlock(name string, typeflag byte, size []byte) []byte {
+	block := make([]byte, 512)
+	copy(block[0:], name)
+	copy(block[100:], "0000644\x00")
+	copy(block[108:], "0000000\x00")
+	copy(block[116:], "0000000\x00")
+	copy(block[124:136], size)
+	copy(block[136:], "00000000000\x00")
+	block[156] = typeflag
+	copy(block[257:], "ustar\x0000")
+	
+	copy(block[148:156], "        ")
+	sum := 0
+	for _, b := range block {
+		sum += int(b)
+	}
+	copy(block[148:], fmt.Sprintf("%06o\x00 ", sum))
+	return block
+}
+
+// pad pads data to a whole number of tar blocks
+func pad(data []byte) []byte {
+	return append(data, make([]byte, (512-len(data)%512)%512)...)
+}
+
+func TestExtractTarLargeSizeEncodings(t *testing.T) {
+	// A size in a PAX record overrides the header's, as it does for entries over 8GiB
+	paxRecord := []byte("10 size=5\n")
+	pax := append(tarBlock("PaxHeaders/tool", tar.TypeXHeader, []byte(fmt.Sprintf("%011o\x00", len(paxRecord)))), pad(paxRecord)...)
+	pax = append(pax, tarBlock("tool", tar.TypeReg, []byte("00000000000\x00"))...)
+	pax = append(pax, pad([]byte("hello"))...)
+	
+	// GNU tar stores sizes over 8GiB in base-256, flagged by the high bit
+	base256 := make([]byte, 12)
+	base256[0] = 0x80
+	base256[11] = 5
+	gnu := append(tarBlock("tool", tar.TypeReg, base256), pad([]byte("hello"))...)
+	
+	for name, data := range map[string][]byte{"pax": pax, "gnu": gnu} {
+		data = append(data, make([]byte, 1024)...)
+		hash := sha256.Sum256(data)
+		extractDir, err := New().Extract(data, "tar", "sha256:"+hex.EncodeToString(hash[:]))
+		if err != nil {
+			t.Errorf("%s: Extract() failed: %v", name, err)
+			continue
+		}
+		defer os.RemoveAll(extractDir)
+		if content, _ := os.ReadFile(filepath.Join(extractDir, "tool")); string(content) != "hello" {
+			t.Errorf("%s: tool = %q, want the 5 bytes the size field declares", name, content)
+		}
+	}
+}
+
+func TestExtractTarTruncatedLargeEntry(t *testing.T) {
+	for _, format := range []tar.Format{tar.FormatPAX, tar.FormatGNU} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Name: "sdk.img", Size: 9 << 30, Mode: 0644, Format: format}); err != nil {
+			t.Fatalf("WriteHeader() failed: %v", err)
+		}
+		tw.Write(bytes.Repeat([]byte("x"), 4096))
+		tw.Flush()
+		
+		data := buf.Bytes()
+		hash := sha256.Sum256(data)
+		if _, err := New().Extract(data, "tar", "sha256:"+hex.EncodeToString(hash[:])); err == nil {
+			t.Errorf("%v: Extract() should fail on a 9GiB entry cut short, not truncate it", format)
+		}
+	}
+}
+
+// zip64Archive returns a stored zip of one file whose sizes and offset are all kept in
+// Zip64 extra fields, as archivers write entries over 4GiB
+func zip64Archive(name string, content []byte) []byte {
+	var buf bytes.Buffer
+	le := func(v any) { binary.Write(&buf, binary.LittleEndian, v) }
+	crc := crc32.ChecksumIEEE(content)
+	size := uint64(len(content))
+	
+	le(uint32(0x04034b50))
+	le([]uint16{45, 0, 0, 0, 0x21})
+	le([]uint32{crc, 0xffffffff, 0xffffffff})
+	le([]uint16{uint16(len(name)), 20})
+	buf.WriteString(name)
+	le([]uint16{0x0001, 16})
+	le([]uint64{size, size})
+	buf.Write(content)
+	
+	dirOffset := uint64(buf.Len())
+	le(uint32(0x02014b50))
+	le([]uint16{0x032d, 45, 0, 0, 0, 0x21})
+	le([]uint32{crc, 0xffffffff, 0xffffffff})
+	le([]uint16{uint16(len(name)), 28, 0, 0, 0})
+	le(uint32(0100755 << 16))
+	le(uint32(0xffffffff))
+	buf.WriteString(name)
+	le([]uint16{0x0001, 24})
+	le([]uint64{size, size, 0})
+	dirSize := uint64(buf.Len()) - dirOffset
+	
+	endOffset := uint64(buf.Len())
+	le(uint32(0x06064b50))
+	le(uint64(44))
+	le([]uint16{45, 45})
+	le([]uint32{0, 0})
+	le([]uint64{1, 1, dirSize, dirOffset})
+	le(uint32(0x07064b50))
+	le(uint32(0))
+	le(endOffset)
+	le(uint32(1))
+	le(uint32(0x06054b50))
+	le([]uint16{0, 0, 0xffff, 0xffff})
+	le([]uint32{0xffffffff, 0xffffffff})
+	le(uint16(0))
+	return buf.Bytes()
+}
+
+func TestExtractZip64(t *testing.T) {
+	data := zip64Archive("sdk/bin/tool", []byte("#!/bin/sh\n"))
+	hash := sha256.Sum256(data)
+	checksum := "sha256:" + hex.EncodeToString(hash[:])
+	
+	extractDir, err := New().Extract(data, "zip", checksum)
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	
+	path := filepath.Join(extractDir, "sdk", "bin", "tool")
+	if content, _ := os.ReadFile(path); string(content) != "#!/bin/sh\n" {
+		t.Errorf("tool = %q, want the stored content", content)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0755) {
+		t.Errorf("tool mode = %v, %v, want 0755 from the central directory", info, err)
+	}
+	
+	// A Zip64 size that disagrees with the data fails rather than truncating
+	bad := bytes.Replace(data, []byte{0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a}, []byte{0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0b}, -1)
+	hash = sha256.Sum256(bad)
+	if _, err := New().Extract(bad, "zip", "sha256:"+hex.EncodeToString(hash[:])); err == nil {
+		t.Error("Extract() should fail when the Zip64 size doesn't match the data")
+	}
+}
+
+// createBenchTarGz builds a gzipped tar with files entries of size bytes each
+func createBenchTarGz(b *testing.B, files, size int) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	content := bytes.Repeat([]byte("nori"), size/4)
+	for i := 0; i < files; i++ {
+		hdr := &tar.Header{
+			Name: fmt.Sprintf("pkg/lib/file%03d", i),
+			Size: int64(len(content)),
+			Mode: 0644,
+		}
+		tw.WriteHeader(hdr)
+		tw.Write(content)
+	}
+	tw.Close()
+	gw.Close()
+
+	return buf.Bytes()
+}
+
+func BenchmarkExtractTarGz(b *testing.B) {
+	data := createBenchTarGz(b, 200, 64*1024)
+	hash := sha256.Sum256(data)
+	checksum := "sha256:" + hex.EncodeToString(hash[:])
+
+	extractor := New()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		extractDir, err := extractor.Extract(data, "tar", checksum)
+		if err != nil {
+			b.Fatalf("Extract() failed: %v", err)
+		}
+		os.RemoveAll(extractDir)
+	}
+}
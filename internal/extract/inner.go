@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// innerArchiveTypes maps the extensions of archives found inside others to their type
+var innerArchiveTypes = []struct {
+	ext       string
+	assetType string
+}{
+	{".tar.gz", "tar"},
+	{".tgz", "tar"},
+	{".tar", "tar"},
+	{".zip", "zip"},
+	{".dmg", "dmg"}, // macOS only
+	{".pkg", "pkg"}, // macOS only
+}
+
+// innerArchiveType returns the type of the archive name names, or "" if it isn't one
+func innerArchiveType(name string) string {
+	lower := strings.ToLower(name)
+	for _, t := range innerArchiveTypes {
+		if strings.HasSuffix(lower, t.ext) {
+			return t.assetType
+		}
+	}
+	return ""
+}
+
+// ExtractInner unwraps an archive that was packed inside the one extracted into
+// extractDir, such as a tarball inside a zip or a disk image inside a zip, replacing the
+// contents of extractDir with its own. pattern is a glob matching the inner archive's
+// path, or just its name; nothing is unwrapped when it is "". It reports the path of the
+// inner archive within extractDir, or "" if there was none to unwrap.
+func (e *Extractor) ExtractInner(extractDir, pattern string, progressCallback ProgressCallback) (string, error) {
+	if pattern == "" {
+		return "", nil
+	}
+	var files []string
+	err := filepath.WalkDir(extractDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(extractDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ok, _ := path.Match(pattern, rel); ok {
+			files = append(files, rel)
+		} else if ok, _ := path.Match(pattern, path.Base(rel)); ok && !strings.Contains(pattern, "/") {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look for an inner archive: %w", err)
+	}
+
+	if len(files) != 1 {
+		if len(files) == 0 {
+			return "", fmt.Errorf("no file in the archive matches inner %q", pattern)
+		}
+		return "", fmt.Errorf("files %s in the archive all match inner %q", strings.Join(files, ", "), pattern)
+	}
+	inner := files[0]
+	data, err := os.ReadFile(filepath.Join(extractDir, filepath.FromSlash(inner)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read inner archive: %w", err)
+	}
+	assetType := innerArchiveType(inner)
+	if assetType == "" {
+		// Fall back on the content for names without a known extension
+		assetType = "tar"
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			assetType = "zip"
+		}
+	}
+
+	// Unpack next to extractDir, then swap the contents so callers keep their path
+	unpacked, err := e.stagingDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(unpacked)
+	switch assetType {
+	case "zip":
+		err = e.extractZip(data, unpacked, progressCallback)
+	case "dmg":
+		err = e.extractDMG(data, unpacked, progressCallback)
+	case "pkg":
+		err = e.extractPkg(data, unpacked, progressCallback)
+	default:
+		err = e.extractTar(bytes.NewReader(data), unpacked, progressCallback)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract inner archive %s: %w", inner, err)
+	}
+	if err := replaceContents(extractDir, unpacked); err != nil {
+		return "", fmt.Errorf("failed to unwrap inner archive %s: %w", inner, err)
+	}
+	return inner, nil
+}
+
+// replaceContents empties dir and moves the entries of src into it
+func replaceContents(dir, src string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	entries, err = os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(src, entry.Name()), filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// zipOf builds a zip of files by name
+func zipOf(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// extractZipOf extracts a zip of files and returns the directory it was extracted to
+func extractZipOf(t *testing.T, extractor *Extractor, files map[string][]byte) string {
+	data := zipOf(t, files)
+	extractDir, err := extractor.Extract(data, "zip", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(extractDir) })
+	return extractDir
+}
+
+func TestExtractInner(t *testing.T) {
+	extractor := New()
+	extractor.SetTempDir(t.TempDir())
+	tarball := createTestTarGz(t)
+
+	// A lone archive is only unwrapped when inner names it; one without a known extension
+	// is told apart by its content
+	for name, pattern := range map[string]string{"tool-1.0.0.tar.gz": "tool-*.tar.gz", "dist/tool.tgz": "dist/tool.tgz", "tool.bin": "tool.bin"} {
+		extractDir := extractZipOf(t, extractor, map[string][]byte{name: tarball})
+		if inner, err := extractor.ExtractInner(extractDir, "", nil); err != nil || inner != "" {
+			t.Fatalf("ExtractInner(%s) without a pattern = %q, %v, want nothing unwrapped", name, inner, err)
+		}
+		inner, err := extractor.ExtractInner(extractDir, pattern, nil)
+		if err != nil || inner != name {
+			t.Fatalf("ExtractInner(%s, %q) = %q, %v, want %q", name, pattern, inner, err, name)
+		}
+		if content, err := os.ReadFile(filepath.Join(extractDir, "test.txt")); err != nil || string(content) != "hello world" {
+			t.Errorf("test.txt after unwrapping %s = %q, %v", name, content, err)
+		}
+		if _, err := os.Stat(filepath.Join(extractDir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("%s is still there after unwrapping it: %v", name, err)
+		}
+	}
+
+	// Next to other files, the inner archive is found by pattern, by path or by name
+	files := map[string][]byte{
+		"README.md":                  []byte("readme"),
+		"dist/tool-1.0.0.tar.gz":     tarball,
+		"dist/tool-1.0.0.zip":        zipOf(t, map[string][]byte{"test.txt": []byte("zipped")}),
+		"dist/tool-1.0.0.tar.gz.sig": []byte("signature"),
+	}
+	for pattern, want := range map[string]string{
+		"":                  "",
+		"dist/*.tar.gz":     "hello world",
+		"tool-*.zip":        "zipped",
+		"dist/tool-1.0.0.*": "",
+		"*.tar.xz":          "",
+	} {
+		extractDir := extractZipOf(t, extractor, files)
+		inner, err := extractor.ExtractInner(extractDir, pattern, nil)
+		switch {
+		case pattern == "":
+			if err != nil || inner != "" {
+				t.Errorf("ExtractInner() beside other files = %q, %v, want nothing unwrapped", inner, err)
+			}
+		case want == "":
+			if err == nil {
+				t.Errorf("ExtractInner(%q) = %q, want an error", pattern, inner)
+			}
+		default:
+			content, _ := os.ReadFile(filepath.Join(extractDir, "test.txt"))
+			if err != nil || string(content) != want {
+				t.Errorf("ExtractInner(%q) = %q, %v, test.txt = %q, want %q", pattern, inner, err, content, want)
+			}
+			if _, err := os.Stat(filepath.Join(extractDir, "README.md")); !os.IsNotExist(err) {
+				t.Errorf("README.md outside the inner archive is still there: %v", err)
+			}
+		}
+	}
+
+	// Disk images and installer packages inside an archive need macOS to unwrap
+	if runtime.GOOS != "darwin" {
+		extractDir := extractZipOf(t, extractor, map[string][]byte{"Tool.dmg": []byte("image")})
+		if _, err := extractor.ExtractInner(extractDir, "*.dmg", nil); err == nil || !strings.Contains(err.Error(), "macOS") {
+			t.Errorf("ExtractInner() of a disk image = %v, want it to need macOS", err)
+		}
+	}
+
+	// A file that matches but isn't an archive fails
+	extractDir := extractZipOf(t, extractor, map[string][]byte{"tool.tar.gz": []byte("not a tarball")})
+	if _, err := extractor.ExtractInner(extractDir, "*.tar.gz", nil); err == nil {
+		t.Error("ExtractInner() unwrapped a file that isn't an archive")
+	}
+}
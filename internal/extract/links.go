@@ -0,0 +1,200 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLinkHops bounds how many symlinks resolving one link target follows, as the kernel
+// does, so that cycles of links end
+const maxLinkHops = 40
+
+// linker creates the links in a tar archive beneath destDir. Links may not lead outside
+// destDir, and no entry may be written through a link that does, so an archive can't
+// use a link to place files elsewhere.
+type linker struct {
+	destDir  string
+	realDest string      // destDir with its own symlinks resolved
+	copies   [][2]string // (target, path) of symlinks the filesystem refused, copied at the end
+	symlinks []string    // paths of the symlinks created, checked again at the end
+
+	// rooted takes absolute link targets to be beneath destDir, as in a system
+	// package whose files are laid out from the root of the filesystem
+	rooted bool
+}
+
+// newLinker returns a linker for destDir
+func newLinker(destDir string) (*linker, error) {
+	realDest, err := filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve destination: %w", err)
+	}
+	return &linker{destDir: destDir, realDest: realDest}, nil
+}
+
+// checkInside returns an error if creating path would follow links already extracted out
+// of destDir. It resolves the deepest part of path that exists; the rest is created as
+// plain directories.
+func (l *linker) checkInside(path string) error {
+	// The archive's own root, as in tarballs of ., is destDir itself
+	if path == l.destDir {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	if !within(l.realDest, real) {
+		return fmt.Errorf("path leads outside the archive through a link")
+	}
+	return nil
+}
+
+// symlink creates a symlink at path to target, which is relative to the link's directory
+func (l *linker) symlink(path, target string) error {
+	if l.rooted && strings.HasPrefix(target, "/") {
+		rel, err := filepath.Rel(filepath.Dir(path), filepath.Join(l.destDir, filepath.FromSlash(target)))
+		if err != nil {
+			return fmt.Errorf("invalid link target %q: %w", target, err)
+		}
+		target = filepath.ToSlash(rel)
+	}
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("absolute link target %q is not allowed", target)
+	}
+	resolved := filepath.Join(filepath.Dir(path), target)
+	if !within(l.destDir, resolved) {
+		return fmt.Errorf("link target %q points outside the archive", target)
+	}
+	// The target may lie outside once links along it are followed
+	if err := l.checkTarget(filepath.Dir(path), target); err != nil {
+		return err
+	}
+
+	if err := os.Symlink(target, path); err != nil {
+		// Windows refuses symlinks without developer mode; copy the target instead
+		l.copies = append(l.copies, [2]string{resolved, path})
+		return nil
+	}
+	l.symlinks = append(l.symlinks, path)
+	return nil
+}
+
+// checkTarget returns an error if the link target, relative to dir, leads outside destDir
+// through the links extracted so far
+func (l *linker) checkTarget(dir, target string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	hops := 0
+	if _, err := l.resolve(realDir, target, &hops); err != nil {
+		return fmt.Errorf("link target %q %w", target, err)
+	}
+	return nil
+}
+
+// resolve returns where target leads from the resolved directory dir, following the links
+// along it. Parts that don't exist are taken as written, since a later entry may still
+// create them. It fails as soon as a step leads outside destDir.
+func (l *linker) resolve(dir, target string, hops *int) (string, error) {
+	current := dir
+	for _, part := range strings.Split(filepath.ToSlash(target), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			next := filepath.Join(current, part)
+			if info, err := os.Lstat(next); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if *hops++; *hops > maxLinkHops {
+					return "", fmt.Errorf("leads through a cycle of links")
+				}
+				linkTarget, err := os.Readlink(next)
+				if err != nil {
+					return "", fmt.Errorf("failed to read link %s: %w", next, err)
+				}
+				if filepath.IsAbs(linkTarget) {
+					return "", fmt.Errorf("leads through the absolute link %s", next)
+				}
+				if next, err = l.resolve(current, linkTarget, hops); err != nil {
+					return "", err
+				}
+			}
+			current = next
+		}
+		if !within(l.realDest, current) {
+			return "", fmt.Errorf("points outside the archive")
+		}
+	}
+	return current, nil
+}
+
+// hardlink creates a hard link at path to the entry named target, falling back to a copy
+// where hard links aren't supported
+func (l *linker) hardlink(path, target string) error {
+	targetPath, err := sanitizePath(target, l.destDir)
+	if err != nil {
+		return fmt.Errorf("invalid link target %q: %w", target, err)
+	}
+	if err := l.checkInside(targetPath); err != nil {
+		return err
+	}
+	if err := os.Link(targetPath, path); err == nil {
+		return nil
+	}
+	return copyEntry(targetPath, path)
+}
+
+// finish checks every symlink again, as one may lead outside through links extracted
+// after it, and copies the targets of the symlinks the filesystem refused
+func (l *linker) finish() error {
+	for _, path := range l.symlinks {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Errorf("failed to read link %s: %w", path, err)
+		}
+		if err := l.checkTarget(filepath.Dir(path), target); err != nil {
+			return err
+		}
+	}
+	for _, c := range l.copies {
+		if err := copyEntry(c[0], c[1]); err != nil {
+			return fmt.Errorf("failed to create link %s: %w", c[1], err)
+		}
+	}
+	return nil
+}
+
+// copyEntry copies the extracted file at src to dst, keeping its mode
+func copyEntry(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to read link target: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("link target %s is a directory", src)
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read link target: %w", err)
+	}
+	defer f.Close()
+	return writeFile(dst, info.Mode().Perm(), f, info.Size())
+}
+
+// within reports whether path is dir or beneath it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
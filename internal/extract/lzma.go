@@ -0,0 +1,479 @@
+package extract
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDictionarySize bounds the window that xz, lzma and zstd payloads may ask for, well
+// above the 64 MiB of `xz -9` and the 8 MiB of `zstd -19`
+const maxDictionarySize = 1 << 27
+
+// LZMA model sizes
+const (
+	lzmaStates        = 12
+	lzmaMaxPosBits    = 4
+	lzmaLenToPosState = 4
+	lzmaEndPosModel   = 14
+	lzmaFullDistances = 128
+	lzmaAlignBits     = 4
+	lzmaMatchMinLen   = 2
+	lzmaMatchMaxLen   = 273
+	lzmaProbInit      = 1 << 10
+)
+
+var errCorrupt = errors.New("corrupt lzma data")
+
+// rangeDecoder reads the range coded bits of LZMA data
+type rangeDecoder struct {
+	r    io.ByteReader
+	rng  uint32
+	code uint32
+	err  error
+}
+
+// init starts decoding from r, which must begin with a zero byte and the initial code
+func (rc *rangeDecoder) init(r io.ByteReader) error {
+	rc.r, rc.rng, rc.code, rc.err = r, 0xFFFFFFFF, 0, nil
+	if rc.readByte() != 0 {
+		return errCorrupt
+	}
+	for range 4 {
+		rc.code = rc.code<<8 | uint32(rc.readByte())
+	}
+	if rc.err != nil {
+		return rc.err
+	}
+	if rc.code == rc.rng {
+		return errCorrupt
+	}
+	return nil
+}
+
+func (rc *rangeDecoder) readByte() byte {
+	b, err := rc.r.ReadByte()
+	if err != nil && rc.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		rc.err = err
+	}
+	return b
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | uint32(rc.readByte())
+	}
+}
+
+// bit decodes one bit with the probability at p, adapting it
+func (rc *rangeDecoder) bit(p *uint16) uint32 {
+	bound := (rc.rng >> 11) * uint32(*p)
+	var bit uint32
+	if rc.code < bound {
+		rc.rng = bound
+		*p += (1<<11 - *p) >> 5
+	} else {
+		rc.rng -= bound
+		rc.code -= bound
+		*p -= *p >> 5
+		bit = 1
+	}
+	rc.normalize()
+	return bit
+}
+
+// direct decodes n bits of fixed probability
+func (rc *rangeDecoder) direct(n uint) uint32 {
+	var res uint32
+	for ; n > 0; n-- {
+		rc.rng >>= 1
+		bit := uint32(0)
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		res = res<<1 | bit
+		rc.normalize()
+	}
+	return res
+}
+
+// bitTree decodes n bits, most significant first, with the probabilities in probs
+func (rc *rangeDecoder) bitTree(probs []uint16, n uint) uint32 {
+	m := uint32(1)
+	for range n {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+	return m - 1<<n
+}
+
+// reverseBitTree decodes n bits, least significant first, with the probabilities in probs
+func (rc *rangeDecoder) reverseBitTree(probs []uint16, n uint) uint32 {
+	m, sym := uint32(1), uint32(0)
+	for i := range n {
+		bit := rc.bit(&probs[m])
+		m = m<<1 | bit
+		sym |= bit << i
+	}
+	return sym
+}
+
+// lenDecoder decodes the lengths of matches
+type lenDecoder struct {
+	choice, choice2 uint16
+	low, mid        [1 << lzmaMaxPosBits][1 << 3]uint16
+	high            [1 << 8]uint16
+}
+
+func (ld *lenDecoder) reset() {
+	ld.choice, ld.choice2 = lzmaProbInit, lzmaProbInit
+	for i := range ld.low {
+		fillProbs(ld.low[i][:])
+		fillProbs(ld.mid[i][:])
+	}
+	fillProbs(ld.high[:])
+}
+
+// decode returns the length of a match less lzmaMatchMinLen
+func (ld *lenDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.bit(&ld.choice) == 0 {
+		return rc.bitTree(ld.low[posState][:], 3)
+	}
+	if rc.bit(&ld.choice2) == 0 {
+		return 8 + rc.bitTree(ld.mid[posState][:], 3)
+	}
+	return 16 + rc.bitTree(ld.high[:], 8)
+}
+
+func fillProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = lzmaProbInit
+	}
+}
+
+// window is the dictionary of an LZMA decoder: the bytes decoded last, which matches
+// copy from, and those not yet read
+type window struct {
+	buf    []byte // grows to size, then wraps
+	size   int
+	pos    int   // where the next byte goes
+	total  int64 // bytes decoded since the dictionary was last reset
+	unread int
+}
+
+func newWindow(size int) *window {
+	return &window{size: size}
+}
+
+func (w *window) put(b byte) {
+	if w.pos < len(w.buf) {
+		w.buf[w.pos] = b
+	} else {
+		w.buf = append(w.buf, b)
+	}
+	if w.pos++; w.pos == w.size {
+		w.pos = 0
+	}
+	w.total++
+	w.unread++
+}
+
+// get returns the byte dist bytes back, where 1 is the last one
+func (w *window) get(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += len(w.buf)
+	}
+	return w.buf[i]
+}
+
+// valid reports whether a match may copy from dist bytes back
+func (w *window) valid(dist uint32) bool {
+	return int64(dist) <= w.total && int(dist) <= w.size
+}
+
+// room reports whether a match fits without overwriting bytes not yet read
+func (w *window) room() bool {
+	return w.unread+lzmaMatchMaxLen <= w.size
+}
+
+// read copies bytes not yet read into p
+func (w *window) read(p []byte) int {
+	start := w.pos - w.unread
+	if start < 0 {
+		start += len(w.buf)
+	}
+	n := copy(p, w.buf[start:min(len(w.buf), start+w.unread)])
+	if n < len(p) && n < w.unread {
+		n += copy(p[n:], w.buf[:w.unread-n])
+	}
+	w.unread -= n
+	return n
+}
+
+// lzmaProps are the literal context, literal position and position bits of LZMA data
+type lzmaProps struct {
+	lc, lp, pb uint
+}
+
+// decodeLzmaProps decodes the properties byte of LZMA data
+func decodeLzmaProps(b byte) (lzmaProps, error) {
+	if b >= 9*5*5 {
+		return lzmaProps{}, fmt.Errorf("invalid lzma properties %#x", b)
+	}
+	d := uint(b)
+	return lzmaProps{lc: d % 9, lp: d / 9 % 5, pb: d / 45}, nil
+}
+
+// lzmaDecoder decodes LZMA symbols into a window
+type lzmaDecoder struct {
+	rc    rangeDecoder
+	win   *window
+	props lzmaProps
+
+	literal    []uint16
+	isMatch    [lzmaStates << lzmaMaxPosBits]uint16
+	isRep      [lzmaStates]uint16
+	isRepG0    [lzmaStates]uint16
+	isRepG1    [lzmaStates]uint16
+	isRepG2    [lzmaStates]uint16
+	isRep0Long [lzmaStates << lzmaMaxPosBits]uint16
+	posSlot    [lzmaLenToPosState][1 << 6]uint16
+	posSpecial [1 + lzmaFullDistances - lzmaEndPosModel]uint16
+	align      [1 << lzmaAlignBits]uint16
+	matchLen   lenDecoder
+	repLen     lenDecoder
+
+	state uint32
+	rep   [4]uint32
+}
+
+// reset starts the model over with props, keeping the window
+func (d *lzmaDecoder) reset(props lzmaProps) {
+	d.props = props
+	if n := 0x300 << (props.lc + props.lp); cap(d.literal) >= n {
+		d.literal = d.literal[:n]
+	} else {
+		d.literal = make([]uint16, n)
+	}
+	fillProbs(d.literal)
+	fillProbs(d.isMatch[:])
+	fillProbs(d.isRep[:])
+	fillProbs(d.isRepG0[:])
+	fillProbs(d.isRepG1[:])
+	fillProbs(d.isRepG2[:])
+	fillProbs(d.isRep0Long[:])
+	for i := range d.posSlot {
+		fillProbs(d.posSlot[i][:])
+	}
+	fillProbs(d.posSpecial[:])
+	fillProbs(d.align[:])
+	d.matchLen.reset()
+	d.repLen.reset()
+	d.state, d.rep = 0, [4]uint32{}
+}
+
+// errEndMarker reports the end of payload marker of LZMA data that has one
+var errEndMarker = errors.New("lzma end marker")
+
+// decode decodes one literal or match into the window, returning errEndMarker at the
+// marker that ends some LZMA data. The window must have room for it.
+func (d *lzmaDecoder) decode() error {
+	rc, w := &d.rc, d.win
+	posState := uint32(w.total) & (1<<d.props.pb - 1)
+
+	if rc.bit(&d.isMatch[d.state<<lzmaMaxPosBits+posState]) == 0 {
+		d.decodeLiteral()
+		return rc.err
+	}
+
+	var length uint32
+	if rc.bit(&d.isRep[d.state]) == 0 {
+		d.rep[3], d.rep[2], d.rep[1] = d.rep[2], d.rep[1], d.rep[0]
+		length = d.matchLen.decode(rc, posState)
+		d.state = nextState(d.state, 7, 10)
+		d.rep[0] = d.decodeDistance(length)
+		if d.rep[0] == 0xFFFFFFFF {
+			if rc.err != nil {
+				return rc.err
+			}
+			return errEndMarker
+		}
+	} else {
+		if w.total == 0 {
+			return errCorrupt
+		}
+		if rc.bit(&d.isRepG0[d.state]) == 0 {
+			if rc.bit(&d.isRep0Long[d.state<<lzmaMaxPosBits+posState]) == 0 {
+				if !w.valid(d.rep[0] + 1) {
+					return errCorrupt
+				}
+				d.state = nextState(d.state, 9, 11)
+				w.put(w.get(d.rep[0] + 1))
+				return rc.err
+			}
+		} else {
+			var dist uint32
+			if rc.bit(&d.isRepG1[d.state]) == 0 {
+				dist = d.rep[1]
+			} else {
+				if rc.bit(&d.isRepG2[d.state]) == 0 {
+					dist = d.rep[2]
+				} else {
+					dist, d.rep[3] = d.rep[3], d.rep[2]
+				}
+				d.rep[2] = d.rep[1]
+			}
+			d.rep[1], d.rep[0] = d.rep[0], dist
+		}
+		length = d.repLen.decode(rc, posState)
+		d.state = nextState(d.state, 8, 11)
+	}
+	if rc.err != nil {
+		return rc.err
+	}
+
+	dist := d.rep[0] + 1
+	if dist == 0 || !w.valid(dist) {
+		return errCorrupt
+	}
+	for range length + lzmaMatchMinLen {
+		w.put(w.get(dist))
+	}
+	return nil
+}
+
+// nextState returns the state after a match or rep of the given kind, which is
+// afterLiteral when the last symbol was a literal and afterMatch when it wasn't
+func nextState(state, afterLiteral, afterMatch uint32) uint32 {
+	if state < 7 {
+		return afterLiteral
+	}
+	return afterMatch
+}
+
+func (d *lzmaDecoder) decodeLiteral() {
+	rc, w := &d.rc, d.win
+	var prev byte
+	if w.total > 0 {
+		prev = w.get(1)
+	}
+	litState := (uint32(w.total)&(1<<d.props.lp-1))<<d.props.lc + uint32(prev)>>(8-d.props.lc)
+	probs := d.literal[0x300*litState:]
+
+	sym := uint32(1)
+	if d.state >= 7 {
+		// After a match, the byte the last match would have copied next guides decoding
+		match := uint32(w.get(d.rep[0] + 1))
+		for sym < 0x100 {
+			matchBit := match >> 7 & 1
+			match <<= 1
+			bit := rc.bit(&probs[(1+matchBit)<<8+sym])
+			sym = sym<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for sym < 0x100 {
+		sym = sym<<1 | rc.bit(&probs[sym])
+	}
+	w.put(byte(sym))
+
+	switch {
+	case d.state < 4:
+		d.state = 0
+	case d.state < 10:
+		d.state -= 3
+	default:
+		d.state -= 6
+	}
+}
+
+// decodeDistance returns the distance of a match of length, less lzmaMatchMinLen
+func (d *lzmaDecoder) decodeDistance(length uint32) uint32 {
+	rc := &d.rc
+	slot := rc.bitTree(d.posSlot[min(length, lzmaLenToPosState-1)][:], 6)
+	if slot < 4 {
+		return slot
+	}
+	bits := uint(slot>>1 - 1)
+	dist := (2 | slot&1) << bits
+	if slot < lzmaEndPosModel {
+		return dist + rc.reverseBitTree(d.posSpecial[dist-slot:], bits)
+	}
+	dist += rc.direct(bits-lzmaAlignBits) << lzmaAlignBits
+	return dist + rc.reverseBitTree(d.align[:], lzmaAlignBits)
+}
+
+// lzmaReader decompresses .lzma data, as written by `lzma` and `xz --format=lzma`
+type lzmaReader struct {
+	dec       lzmaDecoder
+	remaining int64 // bytes left to decode, or -1 until the end marker
+	err       error
+}
+
+// newLzmaReader returns a reader of the .lzma data read from r, whose header gives its
+// properties, dictionary size and, unless it ends with a marker, its decoded size
+func newLzmaReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	var header [13]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read lzma header: %w", err)
+	}
+	props, err := decodeLzmaProps(header[0])
+	if err != nil {
+		return nil, err
+	}
+	dictSize := binary.LittleEndian.Uint32(header[1:5])
+	if dictSize > maxDictionarySize {
+		return nil, fmt.Errorf("lzma dictionary of %d bytes is too large", dictSize)
+	}
+
+	lr := &lzmaReader{remaining: int64(binary.LittleEndian.Uint64(header[5:]))}
+	lr.dec.win = newWindow(max(int(dictSize), 1<<12))
+	lr.dec.reset(props)
+	if err := lr.dec.rc.init(br); err != nil {
+		return nil, fmt.Errorf("invalid lzma data: %w", err)
+	}
+	return lr, nil
+}
+
+func (lr *lzmaReader) Read(p []byte) (int, error) {
+	w := lr.dec.win
+	for w.unread == 0 && lr.err == nil {
+		for w.room() && lr.remaining != 0 {
+			before := w.total
+			err := lr.dec.decode()
+			if err == errEndMarker {
+				err = io.EOF
+				if lr.remaining > 0 {
+					err = io.ErrUnexpectedEOF
+				}
+			}
+			if err != nil {
+				lr.err = err
+				break
+			}
+			if lr.remaining > 0 {
+				if lr.remaining -= w.total - before; lr.remaining < 0 {
+					lr.err = errCorrupt
+				}
+			}
+		}
+		if lr.remaining == 0 && lr.err == nil {
+			lr.err = io.EOF
+		}
+	}
+	n := w.read(p)
+	if n > 0 {
+		return n, nil
+	}
+	return 0, lr.err
+}
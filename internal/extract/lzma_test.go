@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// testText(50) + testNoise(100) in the .lzma format, with an end marker rather than a size
+const lzmaSmall = "XQAAgAD//////////wA2GkofCKAmVk363DVAWCnn+A4G1GRHvrt51mWk8qY1eMlX1NRPaG4rdDDtpl1RccAqbqn1mxQvGxecQHyk" +
+	"nW6UXBIQpMXjo/q8Kj8n/UgsLf1Uyealle1C/8I5P4mNiWFKeIbE/DfIlIv2YIC3VSXXs8W3PoeE/unSXfU1h3DrZZaIqDO0ODw5" +
+	"ieantB3ONL7aSvXwCnCV3EaHWA+9maga+Ve3XsjMXO4fDSeh5qJAkFWL2OodNWyUQI5ydB8fv4YVTQAJUoorebwjbtl8Y/CkmJus" +
+	"Bs6MdouZUQu0TZbZIpJU+czHe2j1GMm1wG0drqG8O4NK2C2+IGGXKUMi2qcqkzEJoYUsdagAfcS9d9Ai5kdMCgSrHKAf7tTX/q43" +
+	"EURbRZDf3/2FcEA="
+
+func TestLzmaReader(t *testing.T) {
+	data := fixture(t, lzmaSmall)
+	want := append(testText(50), testNoise(100)...)
+	r, err := newLzmaReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("newLzmaReader() failed: %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("reading lzma data = %d bytes, %v, want %d bytes", len(got), err, len(want))
+	}
+
+	// With its size in the header, the data needs no end marker
+	sized := bytes.Clone(data)
+	copy(sized[5:13], []byte{byte(len(want)), byte(len(want) >> 8), 0, 0, 0, 0, 0, 0})
+	r, err = newLzmaReader(bytes.NewReader(sized))
+	if err != nil {
+		t.Fatalf("newLzmaReader() failed: %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("reading sized lzma data = %d bytes, %v, want %d bytes", len(got), err, len(want))
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[40] ^= 0x40
+	huge := bytes.Clone(data)
+	huge[4] = 0xff
+	for name, broken := range map[string][]byte{
+		"truncated":          data[:len(data)/2],
+		"no end marker":      data[:len(data)-6],
+		"corrupt data":       corrupt,
+		"a huge dictionary":  huge,
+		"invalid properties": append([]byte{0xff}, data[1:]...),
+	} {
+		r, err := newLzmaReader(bytes.NewReader(broken))
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		if err == nil {
+			t.Errorf("reading lzma data with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package extract
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// volumeFiles are the files macOS keeps at the root of a volume, which aren't part of
+// what a disk image ships
+var volumeFiles = map[string]bool{
+	".DS_Store":               true,
+	".DocumentRevisions-V100": true,
+	".Spotlight-V100":         true,
+	".TemporaryItems":         true,
+	".Trashes":                true,
+	".VolumeIcon.icns":        true,
+	".background":             true,
+	".fseventsd":              true,
+}
+
+// extractDMG copies the files on the disk image in data into destDir. The image is
+// attached read-only at a mount point of its own and detached again afterwards.
+func (e *Extractor) extractDMG(data []byte, destDir string, progressCallback ProgressCallback) error {
+	work, err := e.stagingDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	image := filepath.Join(work, "image.dmg")
+	if err := os.WriteFile(image, data, 0600); err != nil {
+		return fmt.Errorf("failed to write disk image: %w", err)
+	}
+	mountPoint := filepath.Join(work, "volume")
+	if err := os.Mkdir(mountPoint, 0700); err != nil {
+		return fmt.Errorf("failed to create mount point: %w", err)
+	}
+
+	detach, err := attachDMG(image, mountPoint)
+	if err != nil {
+		return err
+	}
+	err = copyTree(mountPoint, destDir, func(rel string) bool { return volumeFiles[rel] }, progressCallback)
+	if detachErr := detach(); err == nil {
+		err = detachErr
+	}
+	return err
+}
+
+// extractPkg copies the payload of the flat installer package in data into destDir. Every
+// component's payload is copied into destDir; the package's scripts are not run.
+func (e *Extractor) extractPkg(data []byte, destDir string, progressCallback ProgressCallback) error {
+	work, err := e.stagingDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	pkg := filepath.Join(work, "installer.pkg")
+	if err := os.WriteFile(pkg, data, 0600); err != nil {
+		return fmt.Errorf("failed to write package: %w", err)
+	}
+
+	// The expanded package must not exist beforehand
+	expanded := filepath.Join(work, "expanded")
+	if err := expandPkg(pkg, expanded); err != nil {
+		return err
+	}
+	payloads, err := pkgPayloads(expanded)
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		if err := copyTree(payload, destDir, nil, progressCallback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pkgPayloads returns the expanded payload directories of the package expanded into
+// dir: the one of a component package, or those of a distribution's components
+func pkgPayloads(dir string) ([]string, error) {
+	if info, err := os.Stat(filepath.Join(dir, "Payload")); err == nil && info.IsDir() {
+		return []string{filepath.Join(dir, "Payload")}, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.pkg", "Payload"))
+	if err != nil {
+		return nil, err
+	}
+	var payloads []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			payloads = append(payloads, match)
+		}
+	}
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("the package has no payload to install")
+	}
+	sort.Strings(payloads)
+	return payloads, nil
+}
+
+// copyTree copies the directories, files and symlinks beneath src into destDir, leaving
+// out the top-level entries skip reports. Symlinks to absolute paths, such as the link
+// to /Applications that disk images carry, are left out; others must stay inside
+// destDir, as for archives.
+func copyTree(src, destDir string, skip func(rel string) bool, progressCallback ProgressCallback) error {
+	links, err := newLinker(destDir)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if skip != nil && filepath.Dir(rel) == "." && skip(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		path := filepath.Join(destDir, rel)
+		if err := links.checkInside(path); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(path, info.Mode().Perm()|0700); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if filepath.IsAbs(target) {
+				return nil
+			}
+			if err := links.symlink(path, filepath.ToSlash(target)); err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+		case d.Type().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			err = writeFile(path, info.Mode().Perm(), f, info.Size())
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+		default:
+			// Devices, sockets and FIFOs are skipped, as in tar archives
+			return nil
+		}
+		if progressCallback != nil {
+			progressCallback()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return links.finish()
+}
@@ -0,0 +1,47 @@
+//go:build darwin
+
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// attachDMG attaches the disk image at image read-only at mountPoint, without showing it
+// in the Finder, and returns a function that detaches it. An image with a license
+// agreement is refused: accepting it is for the user to do, not nori.
+func attachDMG(image, mountPoint string) (func() error, error) {
+	info, err := exec.Command("hdiutil", "imageinfo", image).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk image: %w: %s", err, bytes.TrimSpace(info))
+	}
+	if strings.Contains(string(info), "Software License Agreement: true") {
+		return nil, fmt.Errorf("disk image asks to accept a license agreement; open it to read and accept the license, and install it by hand")
+	}
+
+	// Without a terminal on stdin, an agreement hdiutil still asks about is declined
+	cmd := exec.Command("hdiutil", "attach", "-nobrowse", "-readonly", "-noautoopen", "-mountpoint", mountPoint, image)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to attach disk image: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return func() error {
+		if exec.Command("hdiutil", "detach", "-quiet", mountPoint).Run() == nil {
+			return nil
+		}
+		// Spotlight may still be indexing the volume
+		if out, err := exec.Command("hdiutil", "detach", "-force", mountPoint).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to detach disk image: %w: %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	}, nil
+}
+
+// expandPkg expands the flat installer package at pkg into dest, unpacking the payloads
+func expandPkg(pkg, dest string) error {
+	if out, err := exec.Command("pkgutil", "--expand-full", pkg, dest).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to expand package: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
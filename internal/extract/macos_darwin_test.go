@@ -0,0 +1,58 @@
+//go:build darwin
+
+package extract
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// macOSAsset builds a dmg or pkg of a tree holding bin/tool with the system's tools
+func macOSAsset(t *testing.T, assetType string) []byte {
+	tool := "hdiutil"
+	if assetType == "pkg" {
+		tool = "pkgbuild"
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		t.Skipf("%s is not available", tool)
+	}
+
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "bin"), 0755)
+	if err := os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("#!/bin/sh\necho tool\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "tool."+assetType)
+	cmd := exec.Command("hdiutil", "create", "-quiet", "-srcfolder", src, "-volname", "Tool", "-format", "UDZO", out)
+	if assetType == "pkg" {
+		cmd = exec.Command("pkgbuild", "--quiet", "--root", src, "--identifier", "dev.nori.test", "--version", "1.0.0", "--install-location", "/usr/local", out)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("%s failed: %v: %s", tool, err, output)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExtractMacOSTypes(t *testing.T) {
+	extractor := New()
+	extractor.SetTempDir(t.TempDir())
+	for _, assetType := range []string{"dmg", "pkg"} {
+		data := macOSAsset(t, assetType)
+		extractDir, err := extractor.Extract(data, assetType, checksumOf(data))
+		if err != nil {
+			t.Fatalf("Extract(%s) failed: %v", assetType, err)
+		}
+		defer os.RemoveAll(extractDir)
+
+		info, err := os.Stat(filepath.Join(extractDir, "bin", "tool"))
+		if err != nil || info.Mode().Perm()&0100 == 0 {
+			t.Errorf("bin/tool from the %s = %v, %v, want it executable", assetType, info, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !darwin
+
+package extract
+
+import "fmt"
+
+// attachDMG fails: disk images are attached with hdiutil, which only macOS has
+func attachDMG(image, mountPoint string) (func() error, error) {
+	return nil, fmt.Errorf("dmg assets can only be extracted on macOS")
+}
+
+// expandPkg fails: installer packages are expanded with pkgutil, which only macOS has
+func expandPkg(pkg, dest string) error {
+	return fmt.Errorf("pkg assets can only be extracted on macOS")
+}
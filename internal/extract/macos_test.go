@@ -0,0 +1,107 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need developer mode on Windows")
+	}
+
+	// A volume as a disk image mounts it
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"Tool.app/Contents/MacOS/tool": "#!/bin/sh\n",
+		"README.txt":                   "readme",
+		".Trashes/junk":                "junk",
+		".DS_Store":                    "junk",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Symlink("/Applications", filepath.Join(src, "Applications"))
+	os.Symlink("Contents/MacOS/tool", filepath.Join(src, "Tool.app", "tool"))
+
+	destDir := t.TempDir()
+	files := 0
+	if err := copyTree(src, destDir, func(rel string) bool { return volumeFiles[rel] }, func() { files++ }); err != nil {
+		t.Fatalf("copyTree() failed: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "Tool.app", "Contents", "MacOS", "tool")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("tool = %v, %v, want it executable", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(destDir, "Tool.app", "tool")); err != nil || target != "Contents/MacOS/tool" {
+		t.Errorf("link = %q, %v, want the relative link kept", target, err)
+	}
+	for _, name := range []string{"Applications", ".Trashes", ".DS_Store"} {
+		if _, err := os.Lstat(filepath.Join(destDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was copied: %v", name, err)
+		}
+	}
+	if files != 3 {
+		t.Errorf("progress counted %d entries, want 3", files)
+	}
+
+	// A relative link out of the tree fails, as in archives
+	os.Symlink("../../etc", filepath.Join(src, "escape"))
+	if err := copyTree(src, t.TempDir(), nil, nil); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("copyTree() with an escaping link = %v, want an error", err)
+	}
+	os.Remove(filepath.Join(src, "escape"))
+
+	// So does one that only escapes through a link copied after it
+	os.Symlink("z/z/../../marker", filepath.Join(src, "a"))
+	os.Symlink(".", filepath.Join(src, "z"))
+	if err := copyTree(src, t.TempDir(), nil, nil); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("copyTree() with a link escaping through a later one = %v, want an error", err)
+	}
+}
+
+func TestPkgPayloads(t *testing.T) {
+	// A component package has its payload at the top
+	component := t.TempDir()
+	os.MkdirAll(filepath.Join(component, "Payload", "usr", "local", "bin"), 0755)
+	if payloads, err := pkgPayloads(component); err != nil || len(payloads) != 1 || payloads[0] != filepath.Join(component, "Payload") {
+		t.Errorf("pkgPayloads(component) = %v, %v", payloads, err)
+	}
+
+	// A distribution has one per component package
+	distribution := t.TempDir()
+	os.WriteFile(filepath.Join(distribution, "Distribution"), []byte("<installer-gui-script/>"), 0644)
+	for _, name := range []string{"tool.pkg", "docs.pkg"} {
+		os.MkdirAll(filepath.Join(distribution, name, "Payload"), 0755)
+	}
+	os.MkdirAll(filepath.Join(distribution, "Resources"), 0755)
+	payloads, err := pkgPayloads(distribution)
+	if err != nil || len(payloads) != 2 || filepath.Base(filepath.Dir(payloads[0])) != "docs.pkg" {
+		t.Errorf("pkgPayloads(distribution) = %v, %v, want both, sorted", payloads, err)
+	}
+
+	if _, err := pkgPayloads(t.TempDir()); err == nil {
+		t.Error("pkgPayloads() of a package without a payload succeeded")
+	}
+}
+
+func TestExtractMacOSTypesElsewhere(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("macOS can extract them")
+	}
+
+	extractor := New()
+	extractor.SetTempDir(t.TempDir())
+	data := []byte("koly")
+	for _, assetType := range []string{"dmg", "pkg"} {
+		_, err := extractor.Extract(data, assetType, checksumOf(data))
+		if err == nil || !strings.Contains(err.Error(), "only be extracted on macOS") {
+			t.Errorf("Extract(%s) = %v, want it refused", assetType, err)
+		}
+	}
+}
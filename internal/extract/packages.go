@@ -0,0 +1,53 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMagic starts a zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// decompressed returns the payload of a deb or rpm read from r, decompressing it as its
+// leading bytes call for: gzip, bzip2, xz, zstd or lzma
+func decompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(buffered), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		return newXzReader(buffered)
+	case bytes.HasPrefix(magic, zstdMagic):
+		// Decoded as it is read, without the goroutines of concurrent decoding
+		dec, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxDictionarySize))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd data: %w", err)
+		}
+		return dec, nil
+	case bytes.HasPrefix(magic, []byte{0x5d, 0x00, 0x00}):
+		// The properties byte of .lzma data from `lzma` and `xz --format=lzma`
+		return newLzmaReader(buffered)
+	}
+	return buffered, nil
+}
+
+// newRootedLinker returns a linker for destDir that takes absolute link targets, such as
+// /opt/vendor/bin/tool, to be beneath it, as a system package lays its files out from
+// the root of the filesystem
+func newRootedLinker(destDir string) (*linker, error) {
+	links, err := newLinker(destDir)
+	if err != nil {
+		return nil, err
+	}
+	links.rooted = true
+	return links, nil
+}
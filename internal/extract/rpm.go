@@ -0,0 +1,212 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// RPM layout: a fixed lead, then the signature and main headers, then the payload
+const (
+	rpmLeadSize = 96
+	rpmMagic    = "\xed\xab\xee\xdb"
+	rpmHeader   = "\x8e\xad\xe8\x01"
+)
+
+// cpio entries in the newc format rpm uses, with or without checksums
+const (
+	cpioNewc       = "070701"
+	cpioNewcCRC    = "070702"
+	cpioHeaderSize = 110
+	cpioTrailer    = "TRAILER!!!"
+	cpioMaxPath    = 4096 // PATH_MAX, which bounds entry names and link targets
+)
+
+// Types of cpio entries, from their modes
+const (
+	cpioTypeMask    = 0170000
+	cpioTypeDir     = 0040000
+	cpioTypeReg     = 0100000
+	cpioTypeSymlink = 0120000
+)
+
+// extractRpm extracts the files of the RPM package in data into destDir, laid out as they
+// would be installed from the root of the filesystem. Only the payload is read: the
+// package's scriptlets are not run, and the rpm database is never touched.
+func (e *Extractor) extractRpm(data []byte, destDir string, progressCallback ProgressCallback) error {
+	payload, err := rpmPayload(data)
+	if err != nil {
+		return err
+	}
+	r, err := decompressed(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	links, err := newRootedLinker(destDir)
+	if err != nil {
+		return err
+	}
+	return extractCpio(r, links, progressCallback)
+}
+
+// rpmPayload returns the compressed payload of the RPM package in data, which follows the
+// lead, the signature header padded to 8 bytes and the main header
+func rpmPayload(data []byte) ([]byte, error) {
+	if len(data) < rpmLeadSize || string(data[:4]) != rpmMagic {
+		return nil, fmt.Errorf("not an RPM package: missing lead")
+	}
+	offset := rpmLeadSize
+	for i, name := range []string{"signature", "main"} {
+		if len(data) < offset+16 || string(data[offset:offset+4]) != rpmHeader {
+			return nil, fmt.Errorf("invalid RPM %s header", name)
+		}
+		entries := int(binary.BigEndian.Uint32(data[offset+8:]))
+		size := int(binary.BigEndian.Uint32(data[offset+12:]))
+		offset += 16 + entries*16 + size
+		if i == 0 {
+			offset += (8 - offset%8) % 8
+		}
+		if entries < 0 || size < 0 || offset > len(data) {
+			return nil, fmt.Errorf("truncated RPM %s header", name)
+		}
+	}
+	return data[offset:], nil
+}
+
+// extractCpio extracts a cpio archive in newc format read from r beneath links.destDir.
+// Files hard linked together share the data of the last entry among them.
+func extractCpio(r io.Reader, links *linker, progressCallback ProgressCallback) error {
+	br := bufio.NewReader(r)
+	pending := make(map[uint64][]string) // hard links waiting for their data, by inode
+	var read int64                       // entries are aligned to 4 bytes from the start
+	align := func() error {
+		n := (4 - read%4) % 4
+		read += n
+		_, err := br.Discard(int(n))
+		return err
+	}
+
+	for {
+		var hdr [cpioHeaderSize]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return fmt.Errorf("failed to read cpio header: %w", err)
+		}
+		read += cpioHeaderSize
+		if magic := string(hdr[:6]); magic != cpioNewc && magic != cpioNewcCRC {
+			return fmt.Errorf("unsupported cpio format %q", magic)
+		}
+		var fields [13]uint64
+		for i := range fields {
+			v, err := strconv.ParseUint(string(hdr[6+i*8:14+i*8]), 16, 32)
+			if err != nil {
+				return fmt.Errorf("invalid cpio header: %w", err)
+			}
+			fields[i] = v
+		}
+		ino, mode, nlink, size, nameSize := fields[0], fields[1], fields[4], int64(fields[6]), fields[11]
+
+		if nameSize > cpioMaxPath {
+			return fmt.Errorf("invalid cpio header: a name of %d bytes is too long", nameSize)
+		}
+		nameBuf := make([]byte, nameSize)
+		if _, err := io.ReadFull(br, nameBuf); err != nil {
+			return fmt.Errorf("failed to read cpio entry name: %w", err)
+		}
+		read += int64(nameSize)
+		if err := align(); err != nil {
+			return fmt.Errorf("failed to read cpio entry: %w", err)
+		}
+		name := string(bytes.TrimRight(nameBuf, "\x00"))
+		if name == cpioTrailer {
+			break
+		}
+
+		if err := extractCpioEntry(br, links, name, mode, size, ino, nlink, pending); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		read += size
+		if err := align(); err != nil {
+			return fmt.Errorf("failed to read cpio entry: %w", err)
+		}
+		if kind := mode & cpioTypeMask; progressCallback != nil && (kind == cpioTypeReg || kind == cpioTypeSymlink) {
+			progressCallback()
+		}
+	}
+
+	// Hard links whose data never came are empty files
+	for _, paths := range pending {
+		for _, path := range paths {
+			if err := writeFile(path, 0644, bytes.NewReader(nil), 0); err != nil {
+				return err
+			}
+		}
+	}
+	return links.finish()
+}
+
+// extractCpioEntry creates the cpio entry name, whose size bytes of data r holds next
+func extractCpioEntry(r *bufio.Reader, links *linker, name string, mode uint64, size int64, ino, nlink uint64, pending map[uint64][]string) error {
+	path, err := sanitizePath(name, links.destDir)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if err := links.checkInside(path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	perm := os.FileMode(mode & 0777)
+
+	switch mode & cpioTypeMask {
+	case cpioTypeDir:
+		// Directories such as /usr/lib may be read-only, but files still go in them
+		if err := os.MkdirAll(path, perm|0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		return nil
+	case cpioTypeSymlink, cpioTypeReg:
+	default:
+		// Devices, sockets and FIFOs are skipped, as in tar archives
+		_, err := r.Discard(int(size))
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		os.Remove(path)
+	}
+
+	if mode&cpioTypeMask == cpioTypeSymlink {
+		if size > cpioMaxPath {
+			return fmt.Errorf("link target of %d bytes is too long", size)
+		}
+		target := make([]byte, size)
+		if _, err := io.ReadFull(r, target); err != nil {
+			return fmt.Errorf("failed to read link target: %w", err)
+		}
+		return links.symlink(path, string(target))
+	}
+
+	// The data of files hard linked together comes with the last of them
+	if size == 0 && nlink > 1 {
+		pending[ino] = append(pending[ino], path)
+		return nil
+	}
+	if err := writeFile(path, perm, io.LimitReader(r, size), size); err != nil {
+		return err
+	}
+	for _, link := range pending[ino] {
+		if err := os.Link(path, link); err != nil {
+			if err := copyEntry(path, link); err != nil {
+				return err
+			}
+		}
+	}
+	delete(pending, ino)
+	return nil
+}
@@ -0,0 +1,131 @@
+package extract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// cpioEntry is an entry of a cpio archive built by cpioArchive
+type cpioEntry struct {
+	name  string
+	mode  uint32
+	ino   uint32
+	nlink uint32
+	data  string
+}
+
+// cpioArchive builds a cpio archive in newc format, as rpm payloads are
+func cpioArchive(entries ...cpioEntry) []byte {
+	var buf bytes.Buffer
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	entries = append(entries, cpioEntry{name: cpioTrailer, nlink: 1})
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			cpioNewc, e.ino, e.mode, 0, 0, e.nlink, 0, len(e.data), 0, 0, 0, 0, len(e.name)+1, 0)
+		buf.WriteString(e.name + "\x00")
+		pad()
+		buf.WriteString(e.data)
+		pad()
+	}
+	return buf.Bytes()
+}
+
+// rpmPackage wraps a payload in an RPM lead and empty signature and main headers
+func rpmPackage(payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(rpmMagic)
+	buf.Write(make([]byte, rpmLeadSize-len(rpmMagic)))
+	for range 2 {
+		buf.WriteString(rpmHeader)
+		buf.Write(make([]byte, 12))
+	}
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestExtractRpm(t *testing.T) {
+	extractor := New()
+	extractor.SetTempDir(t.TempDir())
+
+	var payload bytes.Buffer
+	gw := gzip.NewWriter(&payload)
+	gw.Write(cpioArchive(
+		cpioEntry{name: "./opt/vendor/bin", mode: 0040755, ino: 1, nlink: 2},
+		cpioEntry{name: "./opt/vendor/bin/tool", mode: 0100755, ino: 2, nlink: 1, data: "#!/bin/sh\n"},
+		cpioEntry{name: "./usr/bin", mode: 0040555, ino: 3, nlink: 2},
+		cpioEntry{name: "./usr/bin/tool", mode: 0120777, ino: 4, nlink: 1, data: "/opt/vendor/bin/tool"},
+		// Hard links carry their data on the last of them
+		cpioEntry{name: "./usr/lib/tool/a", mode: 0100644, ino: 5, nlink: 2},
+		cpioEntry{name: "./usr/lib/tool/b", mode: 0100644, ino: 5, nlink: 2, data: "shared"},
+		cpioEntry{name: "./usr/lib/tool/fifo", mode: 0010644, ino: 6, nlink: 1},
+	))
+	gw.Close()
+	data := rpmPackage(payload.Bytes())
+
+	files := 0
+	extractDir, err := extractor.ExtractWithProgress(data, "rpm", checksumOf(data), func() { files++ })
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+	checkSystemTree(t, extractDir)
+	for _, name := range []string{"a", "b"} {
+		if content, err := os.ReadFile(filepath.Join(extractDir, "usr", "lib", "tool", name)); err != nil || string(content) != "shared" {
+			t.Errorf("hard link %s = %q, %v, want the shared data", name, content, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(extractDir, "usr", "lib", "tool", "fifo")); !os.IsNotExist(err) {
+		t.Errorf("a FIFO was extracted: %v", err)
+	}
+	if files != 4 {
+		t.Errorf("progress counted %d entries, want 4", files)
+	}
+
+	// Fedora and RHEL compress payloads with zstd, and older releases with xz
+	cpio := cpioArchive(cpioEntry{name: "./usr/bin/tool", mode: 0100755, nlink: 1, data: "#!/bin/sh\n"})
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, payload := range map[string][]byte{
+		"zstd": enc.EncodeAll(cpio, nil),
+		"xz":   xzStored(cpio),
+	} {
+		data := rpmPackage(payload)
+		extractDir, err := extractor.Extract(data, "rpm", checksumOf(data))
+		if err != nil {
+			t.Errorf("Extract() of a %s payload failed: %v", name, err)
+			continue
+		}
+		defer os.RemoveAll(extractDir)
+		if content, err := os.ReadFile(filepath.Join(extractDir, "usr", "bin", "tool")); err != nil || string(content) != "#!/bin/sh\n" {
+			t.Errorf("usr/bin/tool of a %s payload = %q, %v", name, content, err)
+		}
+	}
+
+	for name, broken := range map[string][]byte{
+		"no lead":          []byte("\x1f\x8b"),
+		"truncated header": rpmPackage(nil)[:rpmLeadSize+8],
+		"corrupt zstd":     rpmPackage([]byte("\x28\xb5\x2f\xfd...")),
+		"odc payload":      rpmPackage([]byte("070707" + strings.Repeat("0", 104))),
+		"traversal":        rpmPackage(cpioArchive(cpioEntry{name: "../etc/passwd", mode: 0100644, nlink: 1, data: "x"})),
+		// Sizes near 4 GiB are refused before anything is allocated for them
+		"a huge name":   rpmPackage([]byte(cpioNewc + strings.Repeat("0", 88) + "ffffffff" + strings.Repeat("0", 8))),
+		"a huge target": rpmPackage([]byte(cpioNewc + "00000001" + "0000a1ff" + strings.Repeat("0", 16) + "00000001" + "00000000" + "ffffffff" + strings.Repeat("0", 32) + "0000000f" + "00000000" + "./usr/bin/tool\x00\x00\x00\x00")),
+	} {
+		if _, err := extractor.Extract(broken, "rpm", checksumOf(broken)); err == nil {
+			t.Errorf("Extract() of an rpm with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin
+
+package extract
+
+// setXattrs does nothing where nori doesn't preserve extended attributes
+func setXattrs(path string, records map[string]string) {}
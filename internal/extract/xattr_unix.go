@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package extract
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// setXattrs sets the user extended attributes recorded in PAX records on the file at
+// path. Filesystems without extended attributes are left as they are.
+func setXattrs(path string, records map[string]string) {
+	for key, value := range records {
+		name, ok := strings.CutPrefix(key, paxXattrPrefix)
+		if !ok || !strings.HasPrefix(name, xattrNamespace) {
+			continue
+		}
+		unix.Setxattr(path, name, []byte(value), 0)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin
+
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestExtractTarXattrs(t *testing.T) {
+	probe := filepath.Join(t.TempDir(), "probe")
+	os.WriteFile(probe, nil, 0644)
+	if err := unix.Setxattr(probe, "user.nori.probe", []byte("1"), 0); err != nil {
+		t.Skipf("the temp filesystem has no user extended attributes: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "tool", Size: 5, Mode: 0644, PAXRecords: map[string]string{
+		"SCHILY.xattr.user.mime_type":      "text/plain",
+		"SCHILY.xattr.security.capability": "raw",
+	}})
+	tw.Write([]byte("hello"))
+	tw.Close()
+	data := buf.Bytes()
+
+	extractDir, err := New().Extract(data, "tar", checksumOf(data))
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	defer os.RemoveAll(extractDir)
+
+	path := filepath.Join(extractDir, "tool")
+	value := make([]byte, 64)
+	if n, err := unix.Getxattr(path, "user.mime_type", value); err != nil || string(value[:n]) != "text/plain" {
+		t.Errorf("user.mime_type = %q, %v, want it kept", value[:max(n, 0)], err)
+	}
+	if _, err := unix.Getxattr(path, "security.capability", value); err == nil {
+		t.Error("security.capability should not be kept")
+	}
+}
@@ -0,0 +1,525 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+// xz streams: a header, blocks of LZMA2 data, an index of the blocks and a footer
+var (
+	xzMagic       = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	xzFooterMagic = []byte{'Y', 'Z'}
+)
+
+const xzFilterLZMA2 = 0x21
+
+var errXzCorrupt = errors.New("corrupt xz data")
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// xzByteReader counts the bytes read from an xz stream, and hashes them into crc when set
+type xzByteReader struct {
+	r   *bufio.Reader
+	n   int64
+	crc hash.Hash32
+}
+
+func (b *xzByteReader) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	b.n++
+	if b.crc != nil {
+		b.crc.Write([]byte{c})
+	}
+	return c, nil
+}
+
+func (b *xzByteReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.crc != nil {
+		b.crc.Write(p[:n])
+	}
+	return n, err
+}
+
+// readFull reads n bytes, failing on a short read
+func (b *xzByteReader) readFull(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(b, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// xzRecord is what the index records of a block: its size without padding, and the size
+// of its decompressed data
+type xzRecord struct {
+	unpadded, uncompressed int64
+}
+
+// xzReader decompresses .xz data, as written by `xz`. Blocks must use the LZMA2 filter
+// alone, which is what xz uses unless told otherwise.
+type xzReader struct {
+	r     *xzByteReader
+	flags []byte // the stream flags, which name the check of each block
+
+	block        *lzma2Reader
+	blockStart   int64 // offset of the block header
+	headerSize   int64
+	declared     [2]int64 // compressed and uncompressed sizes from the block header, or -1
+	uncompressed int64
+	check        hash.Hash
+	records      []xzRecord
+
+	err error
+}
+
+// newXzReader returns a reader of the .xz data read from r
+func newXzReader(r io.Reader) (io.Reader, error) {
+	x := &xzReader{r: &xzByteReader{r: bufio.NewReader(r)}}
+	if err := x.readStreamHeader(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xzReader) Read(p []byte) (int, error) {
+	for x.err == nil {
+		if x.block == nil {
+			if x.err = x.nextBlock(); x.err != nil {
+				break
+			}
+		}
+		n, err := x.block.Read(p)
+		x.uncompressed += int64(n)
+		if x.check != nil {
+			x.check.Write(p[:n])
+		}
+		switch {
+		case err == io.EOF:
+			x.err = x.endBlock()
+			x.block = nil
+		case err != nil:
+			x.err = err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+	if x.err != io.EOF && !errors.Is(x.err, io.ErrUnexpectedEOF) {
+		return 0, fmt.Errorf("invalid xz data: %w", x.err)
+	}
+	return 0, x.err
+}
+
+// readStreamHeader reads the header of a stream, keeping its flags
+func (x *xzReader) readStreamHeader() error {
+	header, err := x.r.readFull(12)
+	if err != nil {
+		return fmt.Errorf("failed to read xz header: %w", err)
+	}
+	if !bytes.Equal(header[:6], xzMagic) {
+		return fmt.Errorf("not xz data")
+	}
+	if crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return fmt.Errorf("invalid xz header: %w", errXzCorrupt)
+	}
+	if header[6] != 0 || header[7] > 0x0F {
+		return fmt.Errorf("unsupported xz stream flags %#x", header[6:8])
+	}
+	x.flags = header[6:8]
+	x.records = nil
+	return nil
+}
+
+// checkSize returns the size of the check at the end of each block of the stream
+func (x *xzReader) checkSize() int {
+	if t := int(x.flags[1]); t > 0 {
+		return 4 << ((t - 1) / 3)
+	}
+	return 0
+}
+
+// newCheck returns the hash that the check of each block is made with, or nil for
+// checks that nothing decodes them with but xz itself
+func (x *xzReader) newCheck() hash.Hash {
+	switch x.flags[1] {
+	case 0x01:
+		return crc32.NewIEEE()
+	case 0x04:
+		return crc64.New(crc64Table)
+	case 0x0A:
+		return sha256.New()
+	}
+	return nil
+}
+
+// nextBlock starts the next block, reading the index and footer of the stream first when
+// it has no more blocks, and returns io.EOF after the last stream
+func (x *xzReader) nextBlock() error {
+	x.blockStart = x.r.n
+	size, err := x.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if size == 0 {
+		if err := x.readIndex(); err != nil {
+			return err
+		}
+		if err := x.nextStream(); err != nil {
+			return err
+		}
+		return x.nextBlock()
+	}
+
+	x.headerSize = (int64(size) + 1) * 4
+	rest, err := x.r.readFull(int(x.headerSize) - 1)
+	if err != nil {
+		return err
+	}
+	header := append([]byte{size}, rest...)
+	body, sum := header[:len(header)-4], header[len(header)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return errXzCorrupt
+	}
+	flags := header[1]
+	if flags&0x3C != 0 {
+		return fmt.Errorf("unsupported xz block flags %#x", flags)
+	}
+	fields := bytes.NewReader(body[2:])
+	x.declared = [2]int64{-1, -1}
+	for i, present := range []bool{flags&0x40 != 0, flags&0x80 != 0} {
+		if present {
+			if x.declared[i], err = readXzVarint(fields); err != nil {
+				return err
+			}
+		}
+	}
+	if flags&0x03 != 0 {
+		return fmt.Errorf("xz blocks with filters other than LZMA2 are not supported")
+	}
+	id, errID := readXzVarint(fields)
+	propsSize, errSize := readXzVarint(fields)
+	props, errProps := fields.ReadByte()
+	if err := errors.Join(errID, errSize, errProps); err != nil {
+		return err
+	}
+	if id != xzFilterLZMA2 || propsSize != 1 {
+		return fmt.Errorf("xz filter %#x is not supported", id)
+	}
+	for fields.Len() > 0 {
+		if b, _ := fields.ReadByte(); b != 0 {
+			return errXzCorrupt
+		}
+	}
+
+	dictSize, err := lzma2DictSize(props)
+	if err != nil {
+		return err
+	}
+	x.block = newLzma2Reader(x.r, dictSize)
+	x.uncompressed = 0
+	x.check = x.newCheck()
+	return nil
+}
+
+// endBlock reads the padding and check at the end of a block and records it for the index
+func (x *xzReader) endBlock() error {
+	compressed := x.r.n - x.blockStart - x.headerSize
+	if (x.declared[0] >= 0 && x.declared[0] != compressed) || (x.declared[1] >= 0 && x.declared[1] != x.uncompressed) {
+		return errXzCorrupt
+	}
+	padding, err := x.r.readFull(int(-compressed & 3))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(padding, make([]byte, len(padding))) {
+		return errXzCorrupt
+	}
+	sum, err := x.r.readFull(x.checkSize())
+	if err != nil {
+		return err
+	}
+	if x.check != nil {
+		// CRC32 and CRC64 are stored little-endian
+		want := x.check.Sum(nil)
+		if len(want) <= 8 {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		if !bytes.Equal(sum, want) {
+			return fmt.Errorf("xz block check failed: %w", errXzCorrupt)
+		}
+	}
+	x.records = append(x.records, xzRecord{x.headerSize + compressed + int64(len(sum)), x.uncompressed})
+	return nil
+}
+
+// readIndex reads the index of a stream, whose indicator has been read, and its footer,
+// checking both against the blocks read
+func (x *xzReader) readIndex() error {
+	start := x.r.n - 1
+	x.r.crc = crc32.NewIEEE()
+	x.r.crc.Write([]byte{0})
+	count, err := readXzVarint(x.r)
+	if err != nil {
+		return err
+	}
+	if count != int64(len(x.records)) {
+		return errXzCorrupt
+	}
+	for _, record := range x.records {
+		unpadded, errUnpadded := readXzVarint(x.r)
+		uncompressed, errUncompressed := readXzVarint(x.r)
+		if err := errors.Join(errUnpadded, errUncompressed); err != nil {
+			return err
+		}
+		if unpadded != record.unpadded || uncompressed != record.uncompressed {
+			return errXzCorrupt
+		}
+	}
+	padding, err := x.r.readFull(int(-(x.r.n - start) & 3))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(padding, make([]byte, len(padding))) {
+		return errXzCorrupt
+	}
+	indexSum := x.r.crc.Sum32()
+	x.r.crc = nil
+	sum, err := x.r.readFull(4)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(sum) != indexSum {
+		return fmt.Errorf("xz index check failed: %w", errXzCorrupt)
+	}
+	indexSize := x.r.n - start
+
+	footer, err := x.r.readFull(12)
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(footer[4:10]) != binary.LittleEndian.Uint32(footer) || !bytes.Equal(footer[10:], xzFooterMagic) {
+		return errXzCorrupt
+	}
+	if (int64(binary.LittleEndian.Uint32(footer[4:]))+1)*4 != indexSize || !bytes.Equal(footer[8:10], x.flags) {
+		return errXzCorrupt
+	}
+	return nil
+}
+
+// nextStream skips the padding after a stream and reads the header of the next one, or
+// returns io.EOF if there is none
+func (x *xzReader) nextStream() error {
+	for {
+		b, err := x.r.r.Peek(4)
+		if len(b) == 0 && err == io.EOF {
+			return io.EOF
+		}
+		if !bytes.Equal(b, []byte{0, 0, 0, 0}) {
+			break
+		}
+		x.r.r.Discard(4)
+	}
+	return x.readStreamHeader()
+}
+
+// readXzVarint reads a variable-length integer of an xz header or index
+func readXzVarint(r io.ByteReader) (int64, error) {
+	var v uint64
+	for i := range 9 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		v |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			if b == 0 && i > 0 {
+				return 0, errXzCorrupt
+			}
+			return int64(v), nil
+		}
+	}
+	return 0, errXzCorrupt
+}
+
+// lzma2DictSize returns the dictionary size that the LZMA2 filter properties give
+func lzma2DictSize(props byte) (int, error) {
+	if props > 40 {
+		return 0, fmt.Errorf("invalid lzma2 dictionary size %#x", props)
+	}
+	size := uint64(2|props&1) << (props/2 + 11)
+	if size > maxDictionarySize {
+		return 0, fmt.Errorf("lzma2 dictionary of %d bytes is too large", size)
+	}
+	return int(size), nil
+}
+
+// lzma2Reader decompresses LZMA2 data: chunks of LZMA data or of bytes stored as they
+// are, ending with a zero byte
+type lzma2Reader struct {
+	r   io.ByteReader
+	dec lzmaDecoder
+
+	packed     limitedByteReader // what is left of an LZMA chunk
+	compressed bool              // the chunk is LZMA data
+	remaining  int               // decompressed bytes left in the chunk
+
+	needDictReset, needProps bool
+	err                      error
+}
+
+func newLzma2Reader(r io.ByteReader, dictSize int) *lzma2Reader {
+	lr := &lzma2Reader{r: r, needDictReset: true, needProps: true}
+	lr.dec.win = newWindow(max(dictSize, 1<<12))
+	return lr
+}
+
+func (lr *lzma2Reader) Read(p []byte) (int, error) {
+	w := lr.dec.win
+	for w.unread == 0 && lr.err == nil {
+		lr.err = lr.fill()
+	}
+	if n := w.read(p); n > 0 {
+		return n, nil
+	}
+	return 0, lr.err
+}
+
+// fill decodes until the window has no room for another match, or the data ends
+func (lr *lzma2Reader) fill() error {
+	w := lr.dec.win
+	for w.room() {
+		if lr.remaining == 0 {
+			if lr.compressed && (lr.packed.n != 0 || lr.dec.rc.code != 0) {
+				return errXzCorrupt
+			}
+			if err := lr.nextChunk(); err != nil {
+				return err
+			}
+			continue
+		}
+		if !lr.compressed {
+			b, err := lr.r.ReadByte()
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			w.put(b)
+			lr.remaining--
+			continue
+		}
+		before := w.total
+		if err := lr.dec.decode(); err != nil {
+			if err == errEndMarker {
+				err = errXzCorrupt
+			}
+			return err
+		}
+		if lr.remaining -= int(w.total - before); lr.remaining < 0 {
+			return errXzCorrupt
+		}
+	}
+	return nil
+}
+
+// nextChunk reads the header of the next chunk, returning io.EOF at the end of the data
+func (lr *lzma2Reader) nextChunk() error {
+	control, err := lr.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if control == 0 {
+		return io.EOF
+	}
+	if control >= 0xE0 || control == 0x01 {
+		lr.needDictReset, lr.needProps = false, true
+		lr.dec.win.total = 0
+	} else if lr.needDictReset {
+		return errXzCorrupt
+	}
+
+	// Sizes are stored big-endian, less one
+	readSize := func() (int, error) {
+		hi, errHi := lr.r.ReadByte()
+		lo, errLo := lr.r.ReadByte()
+		if errHi != nil || errLo != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return int(hi)<<8 | int(lo) + 1, nil
+	}
+	if control < 0x80 {
+		if control > 0x02 {
+			return errXzCorrupt
+		}
+		lr.compressed = false
+		lr.remaining, err = readSize()
+		return err
+	}
+
+	unpacked, err := readSize()
+	if err != nil {
+		return err
+	}
+	packed, err := readSize()
+	if err != nil {
+		return err
+	}
+	lr.compressed, lr.remaining = true, int(control&0x1F)<<16+unpacked
+	switch control >> 5 & 3 {
+	case 0:
+		if lr.needProps {
+			return errXzCorrupt
+		}
+	case 1:
+		if lr.needProps {
+			return errXzCorrupt
+		}
+		lr.dec.reset(lr.dec.props)
+	default:
+		b, err := lr.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		props, err := decodeLzmaProps(b)
+		if err != nil {
+			return err
+		}
+		if props.lc+props.lp > 4 {
+			return errXzCorrupt
+		}
+		lr.needProps = false
+		lr.dec.reset(props)
+	}
+	lr.packed = limitedByteReader{lr.r, packed}
+	return lr.dec.rc.init(&lr.packed)
+}
+
+// limitedByteReader reads up to n bytes from r
+type limitedByteReader struct {
+	r io.ByteReader
+	n int
+}
+
+func (l *limitedByteReader) ReadByte() (byte, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	l.n--
+	return l.r.ReadByte()
+}
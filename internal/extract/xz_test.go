@@ -0,0 +1,206 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Fixtures written by Python's lzma module, which uses liblzma as xz does. They hold
+// testText and testNoise.
+const (
+	// testText(300) + testNoise(600), with the default CRC64 check
+	xzMixed = "/Td6WFoAAATm1rRGAgAhARYAAAB0L+Wj4BT0BIVdADYaSh8IoCZWTfrcNUBYKef4DgbUZEe+u3nWZaTypjV4yVfU1E9obit0MO2m" +
+		"XVFxwCpuqfWbFC8bF5xAfKSdbpRcEhCkxeOj+rwqPyf9SCwt/VTJ5qWV7UL/wjk/iY2JYUp4hsT8N8iUi/ZggLdVJdezxbc+h4T+" +
+		"6dJd9TWHcOtlloioM7Q4PDmJ5qe0Hc40vtpK9fAKcJXcRodYD72ZqBr5V7deyMxc7h8NJ6HmokCQVYvY7BcpIF8+a3maE8tltWRZ" +
+		"uivoN/+uQXAVPXjIaGXG35+f3Ff6647xszlG9P9EmUngdgSEFBa12MyLKFkXb+pzgXVNNteLPnPLnVrPSxPd4rxM8XsUReJRHhTI" +
+		"/QM9WYx+9FX2ljCdt+g7VNGE6TtRBdUd87A5EEdn8T8wlBLX7tuUaRscecGUplNqsdJ69htvoHsaf/O05DWNhZY/3U+/7kkYL7tQ" +
+		"RS6rcamSmzkeWDwfTwoc8ZaZESxhfF8R14vO74xyu/l9bzKpU/zOEiDuFX81cc0dA1d4XtbKfd8rVfsntSxzvF3NOv8jon4TmTmC" +
+		"EpJ4h0miehr8ejBHXoL9kzfRRTeh9O4QmAK86qmuD5l2fEhoiSEvouMprmaFKsc/+FDELvSKeW3xlVC3y4I4BzJNQjR6pauvQyHu" +
+		"BbbDzRIea5ULpLZWcrWv7O9a1mAxxKt/UD8p1xlbdqmrz0ZGA3IYNMyj6FqVrZQnfgvYz32u43tMEr3QWg/MmGFJuoBgTr29ptY5" +
+		"4cmRXz0k9fPt/5PFvnDpn8U9tHdvR57rl5kUlDMnbyxlJJ8+Uff4+A54AHWk4mwtBE1WRLlf1en79Jh8JwyiXyJpVgoqm77M1MPQ" +
+		"L9fUsakgtNxBk4FFszU5P/0vnGQS7J/15L80Vs3WdtiklytJ+qKhpHn6mJhuL2eGRVfBs9HM636I2T5k/lI5sON+DYtGiqYMyU+B" +
+		"7fhhRk19tJb1njt3VAGYO5+3OP9rbo6p5deRBayvhNmEhyVG9Hi8YtD1o10Re2iBSJioK8xD8O46zRh/OGeyHwe13IiFbyOlRZeN" +
+		"y48jCCSSrgJLTLTsdHAihbtEo8DcBRg4tY0GXW1XIUveii5JajVDQdEJk2rCZh9D+j7D+c2XKBuSF6Zq4g8eIobuXmzHppMZQbCZ" +
+		"p5nOv1gtL/M4jYyRN4fGomNeHwcuOY5gGmS8Y4h4OeccZf1vjXuYEvut9ETSrtlUPLQY0no5+mEcnacpsrwIyUlIEikDCZiKpLWW" +
+		"MlWKB8t+x4EKnbeBu9bvlrZ360OwTqT3YQ7HF9GsVyTqhaVFEXYyb6jCYyqZE/QVnK2K1sxaoB/Yy19eVTIfLTeXELf2uReaGXQC" +
+		"CsCu/6UDj/SXH9LSuJE/aPiffMWlueg98HuXC+sY7WbnAW934K7t7CR9bahO/NNEm/PeNcFKso2aP+5rVUXCc9MXbm/U119DRD12" +
+		"4kYPSKOBOjvwsn9DuJg//7wCjFziImPlb73QoIqxpG8mCb6M7VczK+PUpuBEkQPU0IEPolb3MBrdmHRHvxzAAAAAAEmDFgtq/J4N" +
+		"AAGhCfUpAAAd7yGascRn+wIAAAAABFla"
+	// testText(50), with each kind of check
+	xzCRC32 = "/Td6WFoAAAFpIt42AgAhARYAAAB0L+Wj4ALvALhdADYaSh8IoCZWTfrcNUBYKef4DgbUZEe+u3nWZaTypjV4yVfU1E9obit0MO2m" +
+		"XVFxwCpuqfWbFC8bF5xAfKSdbpRcEhCkxeOj+rwqPyf9SCwt/VTJ5qWV7UL/wjk/iY2JYUp4hsT8N8iUi/ZggLdVJdezxbc+h4T+" +
+		"6dJd9TWHcOtlloioM7Q4PDmJ5qe0Hc40vtpK9fAKcJXcRodYD72ZqBr5V7deyMxc7h8NJ6HmokCQVYvY6gb/iAAAClf/IQAB0AHw" +
+		"BQAADjWmbD4wDYsCAAAAAAFZWg=="
+	xzSHA256 = "/Td6WFoAAArh+wyhAgAhARYAAAB0L+Wj4ALvALhdADYaSh8IoCZWTfrcNUBYKef4DgbUZEe+u3nWZaTypjV4yVfU1E9obit0MO2m" +
+		"XVFxwCpuqfWbFC8bF5xAfKSdbpRcEhCkxeOj+rwqPyf9SCwt/VTJ5qWV7UL/wjk/iY2JYUp4hsT8N8iUi/ZggLdVJdezxbc+h4T+" +
+		"6dJd9TWHcOtlloioM7Q4PDmJ5qe0Hc40vtpK9fAKcJXcRodYD72ZqBr5V7deyMxc7h8NJ6HmokCQVYvY6gb/iAAA61BGzLJTUbqK" +
+		"COzXWjvlw1pnsyr8HaAiM1hwicp4+tAAAewB8AUAANjyHh+26d8cAgAAAAAKWVo="
+	xzNoCheck = "/Td6WFoAAAD/EtlBAgAhARYAAAB0L+Wj4ALvALhdADYaSh8IoCZWTfrcNUBYKef4DgbUZEe+u3nWZaTypjV4yVfU1E9obit0MO2m" +
+		"XVFxwCpuqfWbFC8bF5xAfKSdbpRcEhCkxeOj+rwqPyf9SCwt/VTJ5qWV7UL/wjk/iY2JYUp4hsT8N8iUi/ZggLdVJdezxbc+h4T+" +
+		"6dJd9TWHcOtlloioM7Q4PDmJ5qe0Hc40vtpK9fAKcJXcRodYD72ZqBr5V7deyMxc7h8NJ6HmokCQVYvY6gb/iAAAAAHMAfAFAADu" +
+		"97IYqAAK/AIAAAAAAFla"
+	// testText(300) in blocks of 4 KiB
+	xzBlocks = "/Td6WFoAAATm1rRGA8DxA4AgIQEWAAAAq6iFc+AP/wHpXQA2GkofCKAmVk363DVAWCnn+A4G1GRHvrt51mWk8qY1eMlX1NRPaG4r" +
+		"dDDtpl1RccAqbqn1mxQvGxecQHyknW6UXBIQpMXjo/q8Kj8n/UgsLf1Uyealle1C/8I5P4mNiWFKeIbE/DfIlIv2YIC3VSXXs8W3" +
+		"PoeE/unSXfU1h3DrZZaIqDO0ODw5ieantB3ONL7aSvXwCnCV3EaHWA+9maga+Ve3XsjMXO4fDSeh5qJAkFWL2OwXKSBfPmt5mhPL" +
+		"ZbVkWbor6Df/rkFwFT14yGhlxt+fn9xX+uuO8bM5RvT/RJlJ4HYEhBQWtdjMiyhZF2/qc4F1TTbXiz5zy51az0sT3eK8TPF7FEXi" +
+		"UR4UyP0DPVmMfvRV9pYwnbfoO1TRhOk7UQXVHfOwORBHZ/E/MJQS1+7blGkbHHnBlKZTarHSevYbb6B7Gn/ztOQ1jYWWP91Pv+5J" +
+		"GC+7UEUuq3Gpkps5Hlg8H08KHPGWmREsYXxfEdeLzu+Mcrv5fW8yqVP8zhIg7hV/NXHNHQNXeF7Wyn3fK1X7J7Usc7xdzTr/I6J+" +
+		"E5k5ghKSeIdJonoa/HowR16C/ZM30UU3ofTuEJgCvOqprg+ZdnxIaIkhL6LjKa5mhSrHP/hQxC70inlt8ZVQt8uCOAcyMpyFIpIA" +
+		"AAAAUxO3l6sW4ogDwKYBnQUhARYAAADdL4B84AKcAJ5dAAUcCmPF2cOQobJvyzPK7Wz3qMex5ixdq9GXgPgKai9SrQdq8eAHNyQe" +
+		"XFM++qWaqyrmrV6xsur6ir8loLHCmvsHNu/QSBkaNNC3A6rf5vOUubLR7JHFa+ofQtxwqucI7mI2MAqF2Eh2x7IlmqwbKS/95U84" +
+		"pLJrD5Ie2+7LNA54eu43vTEB2j3DGA8zD51E1T+EPoF6VJVTJnODeZoAAAAATOD++AEXiucAAokEgCC+AZ0FAAAIEIKsFBc7MAMA" +
+		"AAAABFla"
+	// testText(300) 800 times, in many chunks
+	xzChunks = "/Td6WFoAAATm1rRGAgAhARYAAAB0L+Wj//+OA4BdADYaSh8IoCZWTfrcNUBYKef4DgbUZEe+u3nWZaTypjV4yVfU1E9obit0MO2m" +
+		"XVFxwCpuqfWbFC8bF5xAfKSdbpRcEhCkxeOj+rwqPyf9SCwt/VTJ5qWV7UL/wjk/iY2JYUp4hsT8N8iUi/ZggLdVJdezxbc+h4T+" +
+		"6dJd9TWHcOtlloioM7Q4PDmJ5qe0Hc40vtpK9fAKcJXcRodYD72ZqBr5V7deyMxc7h8NJ6HmokCQVYvY7BcpIF8+a3maE8tltWRZ" +
+		"uivoN/+uQXAVPXjIaGXG35+f3Ff6647xszlG9P9EmUngdgSEFBa12MyLKFkXb+pzgXVNNteLPnPLnVrPSxPd4rxM8XsUReJRHhTI" +
+		"/QM9WYx+9FX2ljCdt+g7VNGE6TtRBdUd87A5EEdn8T8wlBLX7tuUaRscecGUplNqsdJ69htvoHsaf/O05DWNhZY/3U+/7kkYL7tQ" +
+		"RS6rcamSmzkeWDwfTwoc8ZaZESxhfF8R14vO74xyu/l9bzKpU/zOEiDuFX81cc0dA1d4XtbKfd8rVfsntSxzvF3NOv8jon4TmTmC" +
+		"EpJ4h0miehr8ejBHXoL9kzfRRTeh9O4QmAK86qmuD5l2fEhoiSEvouMprmaFKsc/+FDELvSKeW3xlVC3y4I4BzJNQjR6pauvQyHu" +
+		"BbbDzRIea5ULpLZWcrWv7O9a1mAxxKt/UD8p1xlbdqmr1/i23zH/1hDc1kWgJglfzEORJGOVa4f7kyj/JJpVgHccphmINlMf4vb+" +
+		"frN/4D+COhc77gp9JVJeXirAEmVgizdk1JuGPsyEX6gzCxm7VdFRXwMK5rCHR9KJwQqEAGz5ZXK9Ut/hyP55IbWoM49ihH1aUWKS" +
+		"dElcxG2ykuegyM4HBO6f3qVCy4g/XACP0E6vJiiUcR89jyThcJ6nI1/sKMuF0ZWYin4qkfIndfcZwAaYTZj92K/VkA/EJVP49ZE2" +
+		"MQWlsO5vwXBNRwzRkRGqrWAdus6xJxhcWYbpZlJYvul2rFnk5VsFCPnH2q38+1IrdM0eWyBC+d1TPfgpZAk7gMsqbN+1O/DEvS5f" +
+		"qg8+S2ZCkBMO/xCT+HF4WfgLzf+VKEYPqfx83vuaMC5WwI+F84OBwGXEJVP49ZE2MQWlsO5vwXBNRwzRkRGqrWAdus6xJxhcWYbp" +
+		"ZlJYvul2rFnk5VsFCPnH2q38+1IrdMzTVogCmisQAPcA7HNTp/2+rnwxGp+3jTFucJ6nI1/sKMuF0ZWYin4qkfIndfcZwAaYTZj9" +
+		"2K/VkA/EJVP49ZE2MQWlsO5vwXBNRwzRkRGqrWAdus6xJxhcWYbpZlJYvul2rFnk5VsFCPnH2q38+1IrdM0eWyBC+d1TPfgpZAk7" +
+		"gMsqbN+1O/DEvS5fqg8+S2ZCkBMO/xCT+HF4WfgLzf+VKEYPqfx83vuaMC5WwI+F84OBwGXEJVP49ZE2MQWlsO5vwXBNRwzRkRGq" +
+		"rWAdus6xJxhcWYbpZlJYvul2rFnk5VsFCPnH2q38+1IrdM0eWyBC+d1TPfgpZAk7gMmX6G4AAAAAAABmWwfgDGCjFQABmQmg1egB" +
+		"i1al07HEZ/sCAAAAAARZWg=="
+	// testNoise(300), which doesn't compress and is stored as it is
+	xzStoredNoise = "/Td6WFoAAATm1rRGAgAhARYAAAB0L+WjAQErxn6Ba0v74vtU9r3ffBzhhwG/Md5Wcg9HZ2aHWaqIPFnqVhN70oWh2DxUVS83rmVb" +
+		"2gJ5mMzjGnaOX9mZjx8/Nu5DeE0N+r6m2uSGjtwpbU7/VuFwIPuPsVgFkMUJ3FPNqjtImVLTUp0Gn+q1wgYTmEmyAR6sMogxnFJG" +
+		"lXE2j1f2OR0W+oh09Zh8F1xBu21xjg9wWccBGy8zPZHAHaUNDaszjX5ejz7maHSmOrHDkxGoZMfbyuBg4fO/CQBnouMloCExh9Vi" +
+		"xahPfi4Ja5SfsG2pnloLRnCAts9HDKalKtis+6Drt3kkciOSSIDFpqeFt9eMkOSrY0RSZuOcMyX5Xqq6c2BdS3F+vqmMVxlxw8pe" +
+		"5SozrIhRZqF7dWdkmmnvb1ZCoB1RxQL3u5JFAMhBz2JZ59dOAAHEAqwCAADMqdU3scRn+wIAAAAABFla"
+)
+
+// testText returns n lines of text that compress well
+func testText(n int) []byte {
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "line %d: %s\n", i, words[i*7%len(words)])
+	}
+	return []byte(b.String())
+}
+
+// testNoise returns n bytes that barely compress
+func testNoise(n int) []byte {
+	x := uint64(1)
+	noise := make([]byte, n)
+	for i := range noise {
+		x = (x*1103515245 + 12345) % (1 << 31)
+		noise[i] = byte(x >> 16)
+	}
+	return noise
+}
+
+func fixture(t *testing.T, b64 string) []byte {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// xzStored builds .xz data holding data in stored LZMA2 chunks, with a CRC32 check
+func xzStored(data []byte) []byte {
+	var buf bytes.Buffer
+	le32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	buf.Write(xzMagic)
+	buf.Write([]byte{0x00, 0x01})
+	buf.Write(le32(crc32.ChecksumIEEE([]byte{0x00, 0x01})))
+
+	// One LZMA2 filter, with a 1 MiB dictionary
+	header := []byte{0x02, 0x00, xzFilterLZMA2, 0x01, 0x10, 0x00, 0x00, 0x00}
+	buf.Write(append(header, le32(crc32.ChecksumIEEE(header))...))
+	compressed := 1
+	control := byte(0x01)
+	for rest := data; len(rest) > 0; control = 0x02 {
+		chunk := rest[:min(len(rest), 1<<16)]
+		rest = rest[len(chunk):]
+		buf.Write([]byte{control, byte((len(chunk) - 1) >> 8), byte(len(chunk) - 1)})
+		buf.Write(chunk)
+		compressed += 3 + len(chunk)
+	}
+	buf.WriteByte(0x00)
+	buf.Write(make([]byte, -compressed&3))
+	buf.Write(le32(crc32.ChecksumIEEE(data)))
+
+	index := []byte{0x00, 0x01}
+	index = binary.AppendUvarint(index, uint64(len(header)+4+compressed+4))
+	index = binary.AppendUvarint(index, uint64(len(data)))
+	index = append(index, make([]byte, -len(index)&3)...)
+	index = append(index, le32(crc32.ChecksumIEEE(index))...)
+	buf.Write(index)
+
+	footer := append(le32(uint32(len(index)/4-1)), 0x00, 0x01)
+	buf.Write(le32(crc32.ChecksumIEEE(footer)))
+	buf.Write(footer)
+	buf.Write(xzFooterMagic)
+	return buf.Bytes()
+}
+
+func TestXzReader(t *testing.T) {
+	mixed := append(testText(300), testNoise(600)...)
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"lzma2 data", fixture(t, xzMixed), mixed},
+		{"a crc32 check", fixture(t, xzCRC32), testText(50)},
+		{"a sha256 check", fixture(t, xzSHA256), testText(50)},
+		{"no check", fixture(t, xzNoCheck), testText(50)},
+		{"several blocks", fixture(t, xzBlocks), testText(300)},
+		{"many chunks", fixture(t, xzChunks), bytes.Repeat(testText(300), 800)},
+		{"a stored chunk", fixture(t, xzStoredNoise), testNoise(300)},
+		{"stored chunks", xzStored(bytes.Repeat(testNoise(1000), 100)), bytes.Repeat(testNoise(1000), 100)},
+		// xz decompresses concatenated streams, with padding between them, as one
+		{"concatenated streams", slices.Concat(fixture(t, xzNoCheck), make([]byte, 8), fixture(t, xzMixed)), append(testText(50), mixed...)},
+	} {
+		r, err := newXzReader(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("newXzReader() of %s failed: %v", tt.name, err)
+			continue
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("reading xz data with %s = %d bytes, %v, want %d bytes", tt.name, len(got), err, len(tt.want))
+		}
+	}
+
+	data := fixture(t, xzMixed)
+	corrupt := func(i int) []byte {
+		c := bytes.Clone(data)
+		c[i] ^= 0x40
+		return c
+	}
+	for name, broken := range map[string][]byte{
+		"truncated":        data[:len(data)/2],
+		"no footer":        data[:len(data)-12],
+		"corrupt data":     corrupt(200),
+		"a corrupt check":  corrupt(len(data) - 30),
+		"a corrupt index":  corrupt(len(data) - 20),
+		"uneven padding":   append(bytes.Clone(data), 0, 0),
+		"trailing garbage": append(bytes.Clone(data), "garbage!"...),
+		"a corrupt header": corrupt(7),
+	} {
+		r, err := newXzReader(bytes.NewReader(broken))
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		if err == nil {
+			t.Errorf("reading xz data with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"net/http"
+	"strings"
+)
+
+// authTransport adds the credentials of each request's host to it. Credentials are
+// looked up per request, so a redirect to another host, such as a storage bucket
+// serving a release asset, doesn't carry them along.
+type authTransport struct {
+	base    http.RoundTripper
+	headers map[string]http.Header // by lower-case host, with or without a port
+}
+
+// RoundTrip sends req with the headers configured for its host
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	headers := t.headers[strings.ToLower(req.URL.Host)]
+	if headers == nil {
+		headers = t.headers[strings.ToLower(req.URL.Hostname())]
+	}
+	if len(headers) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, values := range headers {
+		if req.Header.Get(name) == "" {
+			req.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// AuthTransport returns base, or http.DefaultTransport if base is nil, sending the
+// headers in headers, keyed by host name, with every request to that host. A key may
+// include a port to only match requests to it.
+func AuthTransport(base http.RoundTripper, headers map[string]http.Header) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if auth, ok := base.(*authTransport); ok {
+		base = auth.base
+	}
+	lower := make(map[string]http.Header, len(headers))
+	for host, h := range headers {
+		lower[strings.ToLower(host)] = h
+	}
+	return &authTransport{base: base, headers: lower}
+}
+
+// BearerToken returns the header that authenticates with token
+func BearerToken(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// SetAuth sends the headers in headers, keyed by host name, with every request to that
+// host, replacing those of an earlier call
+func (f *Fetcher) SetAuth(headers map[string]http.Header) {
+	client := *f.client
+	client.Transport = AuthTransport(f.client.Transport, headers)
+	f.client = &client
+}
+
+// configureTransport returns a copy of base, or of http.DefaultTransport if base is nil,
+// with configure applied, keeping the credentials of SetAuth
+func configureTransport(base http.RoundTripper, configure func(*http.Transport)) http.RoundTripper {
+	if auth, ok := base.(*authTransport); ok {
+		return &authTransport{base: configureTransport(auth.base, configure), headers: auth.headers}
+	}
+	transport := cloneTransport(base)
+	configure(transport)
+	return transport
+}
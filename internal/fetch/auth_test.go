@@ -0,0 +1,54 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthTransport(t *testing.T) {
+	data := []byte("payload")
+
+	// Assets often redirect to storage on another host, which must not see the token
+	var storageAuth []string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = append(storageAuth, r.Header.Get("Authorization")+r.Header.Get("X-Api-Key"))
+		w.Write(data)
+	}))
+	defer storage.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Api-Key") != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// localhost and 127.0.0.1 are different hosts to the client
+		http.Redirect(w, r, strings.Replace(storage.URL, "127.0.0.1", "localhost", 1)+r.URL.Path, http.StatusFound)
+	}))
+	defer registry.Close()
+
+	headers := BearerToken("s3cret")
+	headers.Set("X-Api-Key", "key")
+	f := New()
+	f.SetAuth(map[string]http.Header{strings.TrimPrefix(registry.URL, "http://"): headers})
+
+	// Later transport settings keep the credentials
+	if err := f.SetHTTPProxy("http://proxy.example.com:3128", "*"); err != nil {
+		t.Fatalf("SetHTTPProxy() failed: %v", err)
+	}
+
+	got, err := f.Fetch(context.Background(), registry.URL+"/tool.tar.gz", checksumOf(data))
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("Fetch() with credentials = %q, %v, want %q", got, err, data)
+	}
+	if len(storageAuth) != 1 || storageAuth[0] != "" {
+		t.Errorf("storage saw credentials %q, want none", storageAuth)
+	}
+
+	// Without credentials for its host, the registry refuses
+	f.SetAuth(map[string]http.Header{"other.example.com": headers})
+	if _, err := f.Fetch(context.Background(), registry.URL+"/tool.tar.gz", checksumOf(data)); err == nil {
+		t.Error("Fetch() without credentials for the host should fail")
+	}
+}
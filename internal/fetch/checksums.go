@@ -0,0 +1,56 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// checksumLine matches GNU (`<hex>  <file>`, `<hex> *<file>`) and BSD (`SHA256 (<file>) = <hex>`) checksum lines
+var checksumLine = regexp.MustCompile(`^(?:([a-fA-F0-9]{64})\s+\*?(\S.*)|SHA256 \((.+)\) = ([a-fA-F0-9]{64}))$`)
+
+// FetchChecksum downloads a checksums file and returns the sha256 it lists for the file
+// at assetURL, as sha256:hex. It is used for rolling builds, whose checksum changes with each build.
+func (f *Fetcher) FetchChecksum(ctx context.Context, checksumsURL, assetURL string) (string, error) {
+	u, err := url.Parse(assetURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid asset URL %q: %w", assetURL, err)
+	}
+
+	data, err := f.cachedGet(ctx, checksumsURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksums: %w", err)
+	}
+	return ParseChecksums(data, path.Base(u.Path))
+}
+
+// ParseChecksums finds the sha256 of fileName in sha256sum output. A file holding a
+// single bare hash, as published next to many release artifacts, also works.
+func ParseChecksums(data []byte, fileName string) (string, error) {
+	if fields := strings.Fields(string(data)); len(fields) == 1 && len(fields[0]) == 64 {
+		return "sha256:" + strings.ToLower(fields[0]), nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		match := checksumLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		hash, name := match[1], match[2]
+		if hash == "" {
+			hash, name = match[4], match[3]
+		}
+		// Names may carry a directory, e.g. ./dist/tool.tar.gz
+		if name == fileName || strings.HasSuffix(name, "/"+fileName) {
+			return "sha256:" + strings.ToLower(hash), nil
+		}
+	}
+
+	return "", fmt.Errorf("no checksum for %s in checksums file", fileName)
+}
@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("B", 64)
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"gnu", a + "  tool-linux.tar.gz\n" + b + "  tool-darwin.tar.gz\n", "sha256:" + strings.ToLower(b)},
+		{"binary mode", b + " *tool-darwin.tar.gz\n", "sha256:" + strings.ToLower(b)},
+		{"directory", a + "  ./dist/tool-darwin.tar.gz\n", "sha256:" + a},
+		{"bsd", "SHA256 (tool-darwin.tar.gz) = " + a + "\n", "sha256:" + a},
+		{"bare", a + "\n", "sha256:" + a},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseChecksums([]byte(tt.data), "tool-darwin.tar.gz")
+		if err != nil {
+			t.Errorf("%s: ParseChecksums() failed: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: ParseChecksums() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseChecksums([]byte(a+"  other.tar.gz\n"), "tool-darwin.tar.gz"); err == nil {
+		t.Error("ParseChecksums() should fail when the file is not listed")
+	}
+}
+
+func TestFetchChecksum(t *testing.T) {
+	hash := strings.Repeat("c", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hash + "  nightly.tar.gz\n"))
+	}))
+	defer server.Close()
+
+	got, err := New().FetchChecksum(context.Background(), server.URL+"/SHA256SUMS", "https://example.com/builds/nightly.tar.gz?build=42")
+	if err != nil {
+		t.Fatalf("FetchChecksum() failed: %v", err)
+	}
+	if got != "sha256:"+hash {
+		t.Errorf("FetchChecksum() = %q, want sha256:%s", got, hash)
+	}
+}
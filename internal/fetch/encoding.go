@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Downloads ask for files as published, with Accept-Encoding: identity. Left to itself,
+// Go's transport asks for gzip and decodes it, so a server that compresses a .tar.gz
+// again, or stores it with Content-Encoding: gzip, would hand over bytes that no longer
+// match the published checksum. Some servers encode responses anyway; their bodies are
+// kept as sent and only decoded when the bytes as sent don't verify.
+
+// contentEncoding returns the Content-Encoding of a response, or "" for identity
+func contentEncoding(h http.Header) string {
+	encoding := strings.ToLower(strings.TrimSpace(h.Get("Content-Encoding")))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
+
+// maxEncodingRatio bounds how many times its size a response body may expand to when its
+// Content-Encoding is undone, past the first MiB. Published files come nowhere near it;
+// a compression bomb does.
+const maxEncodingRatio = 64
+
+// decodeBody undoes the Content-Encoding of a response body
+func decodeBody(data []byte, encoding string) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case "":
+		return data, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", encoding, err)
+	}
+	defer r.Close()
+	limit := max(int64(len(data))*maxEncodingRatio, 1<<20)
+	decoded, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", encoding, err)
+	}
+	if int64(len(decoded)) > limit {
+		return nil, fmt.Errorf("failed to decode %s response: it expands to more than %d bytes", encoding, limit)
+	}
+	return decoded, nil
+}
+
+// verifyDecoded verifies data, a body served with encoding, against expected and returns
+// the bytes that match: the body as sent, or else the decoded body, reporting which.
+// With a payload checksum set, bytes whose payload matches it are accepted too.
+func (f *Fetcher) verifyDecoded(data []byte, encoding, expected string) ([]byte, bool, error) {
+	err := VerifyChecksum(data, expected)
+	if err == nil {
+		return data, false, nil
+	}
+	if f.verifyPayload(data) {
+		return data, false, nil
+	}
+	if encoding == "" {
+		return nil, false, f.payloadMismatch(err)
+	}
+	decoded, decodeErr := decodeBody(data, encoding)
+	if decodeErr != nil {
+		return nil, false, fmt.Errorf("%w (served with Content-Encoding %s: %v)", f.payloadMismatch(err), encoding, decodeErr)
+	}
+	if err := VerifyChecksum(decoded, expected); err == nil || f.verifyPayload(decoded) {
+		return decoded, true, nil
+	}
+	return nil, false, fmt.Errorf("%w (also after undoing Content-Encoding %s)", f.payloadMismatch(err), encoding)
+}
+
+// verifyDecodedFile is verifyDecoded for the file at path, which it rewrites with the
+// decoded bytes if those are the ones that match
+func (f *Fetcher) verifyDecodedFile(path, encoding, expected string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	verified, decoded, err := f.verifyDecoded(data, encoding, expected)
+	if err != nil || !decoded {
+		return err
+	}
+	if err := os.WriteFile(path, verified, 0644); err != nil {
+		return fmt.Errorf("failed to save decoded download: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gzipped returns data compressed with gzip
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(data)
+	gw.Close()
+	return buf.Bytes()
+}
+
+// encodingServer serves body with Content-Encoding encoding, whatever the request asks
+// for, and records the Accept-Encoding of each request
+func encodingServer(t *testing.T, body []byte, encoding string, accepted *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*accepted = append(*accepted, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchKeepsEncodedArchive(t *testing.T) {
+	// An archive stored with Content-Encoding: gzip, as object stores do, must arrive
+	// compressed, because that is what its checksum covers
+	archive := gzipped([]byte("tar contents"))
+	var accepted []string
+	server := encodingServer(t, archive, "gzip", &accepted)
+
+	data, err := New().Fetch(context.Background(), server.URL+"/tool.tar.gz", checksumOf(archive))
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if !bytes.Equal(data, archive) {
+		t.Errorf("Fetch() = %q, want the archive as published", data)
+	}
+	if len(accepted) != 1 || accepted[0] != "identity" {
+		t.Errorf("Accept-Encoding = %v, want identity", accepted)
+	}
+}
+
+func TestFetchDecodesEncodedResponse(t *testing.T) {
+	// A server that compresses on the fly, though asked not to
+	archive := []byte("uncompressed tar contents")
+	var accepted []string
+	server := encodingServer(t, gzipped(archive), "gzip", &accepted)
+
+	data, err := New().Fetch(context.Background(), server.URL+"/tool.tar", checksumOf(archive))
+	if err != nil || !bytes.Equal(data, archive) {
+		t.Errorf("Fetch() = %q, %v, want the decoded archive", data, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "archive")
+	if err := New().FetchToFile(context.Background(), []string{server.URL + "/tool.tar"}, checksumOf(archive), path, nil); err != nil {
+		t.Fatalf("FetchToFile() failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, archive) {
+		t.Errorf("downloaded file = %q, want the decoded archive", got)
+	}
+
+	if _, err := New().Fetch(context.Background(), server.URL+"/tool.tar", checksumOf([]byte("other"))); err == nil || !strings.Contains(err.Error(), "Content-Encoding gzip") {
+		t.Errorf("Fetch() = %v, want a mismatch that mentions the encoding", err)
+	}
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	// 64 MiB of zeros gzips to about 64 KiB, well past the ratio a real file reaches
+	bomb := gzipped(make([]byte, 64<<20))
+	if _, err := decodeBody(bomb, "gzip"); err == nil || !strings.Contains(err.Error(), "expands to more than") {
+		t.Errorf("decodeBody() of a compression bomb = %v, want it refused", err)
+	}
+
+	data := bytes.Repeat([]byte("tool.tar.gz\n"), 1000)
+	if got, err := decodeBody(gzipped(data), "gzip"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("decodeBody() = %d bytes, %v, want the %d bytes as published", len(got), err, len(data))
+	}
+}
+
+func TestFetchChecksumDecodesResponse(t *testing.T) {
+	sums := strings.Repeat("a", 64) + "  tool.tar.gz\n"
+	var accepted []string
+	server := encodingServer(t, gzipped([]byte(sums)), "gzip", &accepted)
+
+	f := New()
+	f.SetCacheDir(t.TempDir())
+	got, err := f.FetchChecksum(context.Background(), server.URL+"/SHA256SUMS", "https://example.com/tool.tar.gz")
+	if err != nil || got != "sha256:"+strings.Repeat("a", 64) {
+		t.Errorf("FetchChecksum() = %q, %v, want the checksum from the decoded file", got, err)
+	}
+}
@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -9,6 +10,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/chirag-bruno/nori/internal/events"
 )
 
 const (
@@ -20,7 +23,19 @@ const (
 
 // Fetcher handles HTTP downloads with retries and checksum verification
 type Fetcher struct {
-	client *http.Client
+	client   *http.Client
+	verbose  io.Writer
+	proxy    string // caching proxy prefix, see SetProxy
+	rate     int64  // bytes per second, see SetRateLimit
+	cacheDir string // HTTP cache, see SetCacheDir
+	payload  string // payload checksum, see SetPayloadChecksum
+
+	githubAPI string // see SetGitHubAPI
+
+	concurrency int    // connections per download, see SetConcurrency
+	downloadDir string // where segmented downloads are assembled, see SetDownloadDir
+
+	health *HostHealth // mirror health, see SetHostHealth
 }
 
 // New creates a new fetcher
@@ -30,6 +45,27 @@ func New() *Fetcher {
 			// No timeout - allow large binaries to download
 			// Context cancellation still works for user-initiated cancellation
 		},
+		health: NewHostHealth(),
+	}
+}
+
+// NewWithClient creates a new fetcher that uses the given HTTP client
+func NewWithClient(client *http.Client) *Fetcher {
+	return &Fetcher{
+		client: client,
+		health: NewHostHealth(),
+	}
+}
+
+// SetVerbose makes the fetcher report mirror selection and failover to w
+func (f *Fetcher) SetVerbose(w io.Writer) {
+	f.verbose = w
+}
+
+// logf writes a verbose message if verbose output is enabled
+func (f *Fetcher) logf(format string, args ...interface{}) {
+	if f.verbose != nil {
+		fmt.Fprintf(f.verbose, format, args...)
 	}
 }
 
@@ -45,6 +81,8 @@ func (f *Fetcher) FetchWithProgress(ctx context.Context, url, expectedChecksum s
 	
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
+			events.FromContext(ctx).Emit(events.Event{Event: events.Retry, URL: url, Attempt: attempt + 1, Error: lastErr.Error()})
+			
 			// Wait before retry
 			select {
 			case <-ctx.Done():
@@ -53,7 +91,7 @@ func (f *Fetcher) FetchWithProgress(ctx context.Context, url, expectedChecksum s
 			}
 		}
 		
-		data, err := f.fetchOnce(ctx, url, progressWriter)
+		data, encoding, err := f.fetchFrom(ctx, url, 0, progressWriter)
 		if err != nil {
 			lastErr = err
 			// Retry on network errors or 5xx errors
@@ -64,7 +102,8 @@ func (f *Fetcher) FetchWithProgress(ctx context.Context, url, expectedChecksum s
 		}
 		
 		// Verify checksum
-		if err := VerifyChecksum(data, expectedChecksum); err != nil {
+		data, _, err = f.verifyDecoded(data, encoding, expectedChecksum)
+		if err != nil {
 			return nil, fmt.Errorf("checksum verification failed: %w", err)
 		}
 		
@@ -74,35 +113,58 @@ func (f *Fetcher) FetchWithProgress(ctx context.Context, url, expectedChecksum s
 	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-// fetchOnce performs a single HTTP GET request
-func (f *Fetcher) fetchOnce(ctx context.Context, url string, progressWriter io.Writer) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// fetchFrom performs a single HTTP GET request for the bytes of url from offset onwards,
+// returning them as sent along with the response's Content-Encoding (see encoding.go).
+// If the transfer breaks off, the bytes received so far are returned along with the error.
+func (f *Fetcher) fetchFrom(ctx context.Context, url string, offset int64, progressWriter io.Writer) ([]byte, string, error) {
+	var buf bytes.Buffer
+	encoding, err := f.copyFrom(ctx, url, offset, &buf, progressWriter)
+	return buf.Bytes(), encoding, err
+}
+
+// copyFrom performs a single HTTP GET request for the bytes of url from offset onwards,
+// writing them to w as they arrive, and returns the response's Content-Encoding
+func (f *Fetcher) copyFrom(ctx context.Context, url string, offset int64, w io.Writer, progressWriter io.Writer) (string, error) {
+	req, err := f.newRequest(ctx, "GET", url)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 	
 	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer resp.Body.Close()
 	
+	if offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return "", errNothingPastOffset
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	encoding := contentEncoding(resp.Header)
+	if encoding != "" {
+		f.logf("%s was served with Content-Encoding %s\n", url, encoding)
 	}
 	
-	// Read with progress tracking if progressWriter is provided
-	var reader io.Reader = resp.Body
-	if progressWriter != nil {
-		reader = io.TeeReader(resp.Body, progressWriter)
+	// A server that ignores Range sends the whole file; skip what we already have
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return encoding, err
+		}
 	}
 	
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, err
+	// Read with progress tracking if progressWriter is provided
+	reader := f.throttle(ctx, resp.Body)
+	if progressWriter != nil {
+		reader = io.TeeReader(reader, progressWriter)
 	}
 	
-	return data, nil
+	_, err = io.Copy(w, reader)
+	return encoding, err
 }
 
 // isRetryableError determines if an error should trigger a retry
@@ -124,6 +186,11 @@ func isRetryableError(err error) bool {
 
 // VerifyChecksum verifies that data matches the expected SHA256 checksum
 func VerifyChecksum(data []byte, expected string) error {
+	return verifySum(sha256.Sum256(data), expected)
+}
+
+// verifySum verifies that hash, a SHA256 digest, matches the expected checksum
+func verifySum(hash [sha256.Size]byte, expected string) error {
 	// Parse checksum format: sha256:hex
 	if !strings.HasPrefix(expected, "sha256:") {
 		return fmt.Errorf("invalid checksum format: must start with 'sha256:'")
@@ -140,9 +207,6 @@ func VerifyChecksum(data []byte, expected string) error {
 		return fmt.Errorf("invalid checksum hex: %w", err)
 	}
 	
-	// Compute actual checksum
-	hash := sha256.Sum256(data)
-	
 	// Compare
 	if !equalBytes(hash[:], expectedBytes) {
 		return fmt.Errorf("checksum mismatch: expected %s, got sha256:%s",
@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -151,3 +152,18 @@ func TestFetchTimeout(t *testing.T) {
 	// Just verify we got an error - could be timeout or connection refused
 }
 
+
+func BenchmarkVerifyChecksum(b *testing.B) {
+	data := bytes.Repeat([]byte("nori"), 4*1024*1024)
+	hash := sha256.Sum256(data)
+	checksum := "sha256:" + hex.EncodeToString(hash[:])
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := VerifyChecksum(data, checksum); err != nil {
+			b.Fatalf("VerifyChecksum() failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultGitHubAPI is the GitHub API that release assets are looked up in, see SetGitHubAPI
+const DefaultGitHubAPI = "https://api.github.com"
+
+// SetGitHubAPI looks release assets up in the GitHub API at baseURL, such as
+// https://github.example.com/api/v3 for GitHub Enterprise Server, instead of DefaultGitHubAPI
+func (f *Fetcher) SetGitHubAPI(baseURL string) {
+	f.githubAPI = baseURL
+}
+
+// ResolveReleaseAsset returns the download URL of the one asset whose name matches the
+// glob pattern in the release of repo (owner/repo) tagged tag, which like the URLs of
+// manifests must be HTTPS. Releases are kept in the HTTP cache like checksums files, so
+// looking one up again is usually a conditional request.
+func (f *Fetcher) ResolveReleaseAsset(ctx context.Context, repo, tag, pattern string) (string, error) {
+	api := f.githubAPI
+	if api == "" {
+		api = DefaultGitHubAPI
+	}
+	releaseURL := strings.TrimSuffix(api, "/") + "/repos/" + repo + "/releases/tags/" + url.PathEscape(tag)
+	data, err := f.cachedGet(ctx, releaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up release %s of %s: %w", tag, repo, err)
+	}
+
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &release); err != nil {
+		return "", fmt.Errorf("invalid release %s of %s: %w", tag, repo, err)
+	}
+	var matched, names []string
+	var downloadURL string
+	for _, asset := range release.Assets {
+		names = append(names, asset.Name)
+		if ok, _ := path.Match(pattern, asset.Name); ok {
+			matched = append(matched, asset.Name)
+			downloadURL = asset.URL
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("release %s of %s has no asset matching %s (it has %s)", tag, repo, pattern, strings.Join(names, ", "))
+	case 1:
+		if u, err := url.Parse(downloadURL); err != nil || u.Scheme != "https" {
+			return "", fmt.Errorf("asset %s of release %s of %s must be downloaded over HTTPS, not from %q", matched[0], tag, repo, downloadURL)
+		}
+		return downloadURL, nil
+	default:
+		return "", fmt.Errorf("assets %s of release %s of %s all match %s", strings.Join(matched, ", "), tag, repo, pattern)
+	}
+}
@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveReleaseAsset(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/repos/example/tool/releases/tags/v1.2.0" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v1.2.0", "assets": [
+			{"name": "tool-1.2.0-linux-amd64.tar.gz", "browser_download_url": "https://github.com/example/tool/releases/download/v1.2.0/tool-1.2.0-linux-amd64.tar.gz"},
+			{"name": "tool-1.2.0-linux-arm64.tar.gz", "browser_download_url": "https://github.com/example/tool/releases/download/v1.2.0/tool-1.2.0-linux-arm64.tar.gz"},
+			{"name": "SHA256SUMS", "browser_download_url": "https://github.com/example/tool/releases/download/v1.2.0/SHA256SUMS"},
+			{"name": "tool-1.2.0.zip", "browser_download_url": "http://github.com/example/tool/releases/download/v1.2.0/tool-1.2.0.zip"}
+		]}`))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetGitHubAPI(server.URL)
+	ctx := context.Background()
+
+	got, err := f.ResolveReleaseAsset(ctx, "example/tool", "v1.2.0", "tool-*-linux-amd64.tar.gz")
+	if err != nil {
+		t.Fatalf("ResolveReleaseAsset() failed: %v", err)
+	}
+	if got != "https://github.com/example/tool/releases/download/v1.2.0/tool-1.2.0-linux-amd64.tar.gz" {
+		t.Errorf("ResolveReleaseAsset() = %q", got)
+	}
+
+	for pattern, want := range map[string]string{
+		"tool-*-darwin-*.tar.gz": "has no asset matching",
+		"tool-1.2.0-linux-*":     "all match",
+		"*.zip":                  "over HTTPS",
+	} {
+		if _, err := f.ResolveReleaseAsset(ctx, "example/tool", "v1.2.0", pattern); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ResolveReleaseAsset(%s) = %v, want an error containing %q", pattern, err, want)
+		}
+	}
+	if _, err := f.ResolveReleaseAsset(ctx, "example/tool", "v9.9.9", "*"); err == nil || !strings.Contains(err.Error(), "release v9.9.9 of example/tool") {
+		t.Errorf("ResolveReleaseAsset() of a missing release = %v", err)
+	}
+	if paths[0] != "/repos/example/tool/releases/tags/v1.2.0" {
+		t.Errorf("requested %s, want the release by tag", paths[0])
+	}
+}
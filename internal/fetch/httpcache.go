@@ -0,0 +1,226 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+)
+
+// releaseAssetURL matches GitHub release assets whose tag names a version. Those are
+// never replaced, unlike assets of moving tags such as nightly or latest.
+var releaseAssetURL = regexp.MustCompile(`^https://github\.com/[^/]+/[^/]+/releases/download/v?[0-9]+(\.[0-9]+)+[^/]*/`)
+
+// cacheEntry is a response kept by the HTTP cache, see SetCacheDir
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires,omitempty"`
+	Immutable    bool      `json:"immutable,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	Body         []byte    `json:"body,omitempty"`
+}
+
+// SetCacheDir keeps checksums files and asset sizes in dir. Entries for immutable URLs,
+// such as versioned GitHub release assets or responses marked Cache-Control: immutable,
+// are reused without asking the server again; others are reused for as long as their
+// Cache-Control or Expires headers allow and then revalidated with a conditional request.
+func (f *Fetcher) SetCacheDir(dir string) {
+	f.cacheDir = dir
+}
+
+// IsImmutableURL reports whether the file at rawURL can never change
+func IsImmutableURL(rawURL string) bool {
+	return releaseAssetURL.MatchString(rawURL)
+}
+
+// Get returns the body of a small file at rawURL, such as a provenance attestation,
+// without verifying it. Like checksums files, it is kept in the HTTP cache.
+func (f *Fetcher) Get(ctx context.Context, rawURL string) ([]byte, error) {
+	return f.cachedGet(ctx, rawURL)
+}
+
+// cachedGet returns the body of rawURL, from the HTTP cache when it is still fresh. The
+// body is read, not verified, so any Content-Encoding is undone. Checksums files,
+// attestations and release listings vouch for downloads, so unlike them they are never
+// fetched through the caching proxy of SetProxy: a proxy that could swap both a
+// download and its checksum would make verifying it meaningless.
+func (f *Fetcher) cachedGet(ctx context.Context, rawURL string) ([]byte, error) {
+	var entry *cacheEntry
+	if f.cacheDir != "" {
+		entry = f.loadEntry("GET", rawURL)
+		if entry != nil && f.fresh(entry) {
+			return entry.Body, nil
+		}
+	}
+
+	req, err := f.directRequest(ctx, "GET", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		f.logf("%s is not modified\n", rawURL)
+		if applyCacheHeaders(entry, resp.Header, time.Now()) {
+			f.saveEntry("GET", entry)
+		}
+		return entry.Body, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if body, err = decodeBody(body, contentEncoding(resp.Header)); err != nil {
+		return nil, err
+	}
+	entry = &cacheEntry{URL: rawURL, Size: int64(len(body)), Body: body}
+	if applyCacheHeaders(entry, resp.Header, time.Now()) && f.cacheDir != "" {
+		f.saveEntry("GET", entry)
+	}
+	return body, nil
+}
+
+// cachedLength returns the size of the file at rawURL recorded by an earlier request,
+// or 0 if there is none that is still fresh
+func (f *Fetcher) cachedLength(rawURL string) int64 {
+	if f.cacheDir == "" {
+		return 0
+	}
+	if entry := f.loadEntry("HEAD", rawURL); entry != nil && f.fresh(entry) {
+		return entry.Size
+	}
+	return 0
+}
+
+// saveLength records the size of the file at rawURL from a HEAD response
+func (f *Fetcher) saveLength(rawURL string, resp *http.Response) {
+	if f.cacheDir == "" || resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 {
+		return
+	}
+	entry := &cacheEntry{URL: rawURL, Size: resp.ContentLength}
+	if applyCacheHeaders(entry, resp.Header, time.Now()) {
+		f.saveEntry("HEAD", entry)
+	}
+}
+
+// fresh reports whether entry can be used without asking the server
+func (f *Fetcher) fresh(entry *cacheEntry) bool {
+	switch {
+	case entry.Immutable:
+		f.logf("Using cached %s (immutable)\n", entry.URL)
+		return true
+	case time.Now().Before(entry.Expires):
+		f.logf("Using cached %s (fresh for %s)\n", entry.URL, time.Until(entry.Expires).Round(time.Second))
+		return true
+	}
+	return false
+}
+
+// applyCacheHeaders updates entry from the caching headers of a response. It reports
+// false when the response must not be stored.
+func applyCacheHeaders(entry *cacheEntry, h http.Header, now time.Time) bool {
+	if etag := h.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+	if modified := h.Get("Last-Modified"); modified != "" {
+		entry.LastModified = modified
+	}
+	entry.Immutable = entry.Immutable || IsImmutableURL(entry.URL)
+
+	// Cache-Control takes precedence over Expires
+	maxAge, hasMaxAge := time.Duration(0), false
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return false
+		case "no-cache":
+			maxAge, hasMaxAge = 0, true
+		case "immutable":
+			entry.Immutable = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && !hasMaxAge {
+				maxAge, hasMaxAge = time.Duration(seconds)*time.Second, true
+			}
+		}
+	}
+
+	switch {
+	case hasMaxAge:
+		// Time already spent in shared caches counts against max-age
+		if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+			maxAge -= time.Duration(age) * time.Second
+		}
+		entry.Expires = now.Add(maxAge)
+	case h.Get("Expires") != "":
+		// An invalid date, such as 0, means already expired
+		expires, _ := http.ParseTime(h.Get("Expires"))
+		entry.Expires = expires
+	default:
+		entry.Expires = time.Time{}
+	}
+
+	// Without a validator or a lifetime, a stored response could never be reused
+	return entry.Immutable || entry.ETag != "" || entry.LastModified != "" || entry.Expires.After(now)
+}
+
+// entryPath returns where the cache entry for a request is kept
+func (f *Fetcher) entryPath(method, rawURL string) string {
+	sum := sha256.Sum256([]byte(method + " " + rawURL))
+	return filepath.Join(f.cacheDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadEntry reads the cache entry for a request, or returns nil if there is none
+func (f *Fetcher) loadEntry(method, rawURL string) *cacheEntry {
+	data, err := os.ReadFile(f.entryPath(method, rawURL))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != rawURL {
+		return nil
+	}
+	return &entry
+}
+
+// saveEntry writes a cache entry. Failures are ignored; the cache only saves requests.
+func (f *Fetcher) saveEntry(method string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+		return
+	}
+	fsutil.WriteFileAtomic(f.entryPath(method, entry.URL), data, 0644)
+}
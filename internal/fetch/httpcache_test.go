@@ -0,0 +1,145 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsImmutableURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://github.com/owner/tool/releases/download/v1.2.3/tool-linux-amd64.tar.gz", true},
+		{"https://github.com/owner/tool/releases/download/14.1.0/SHA256SUMS", true},
+		{"https://github.com/owner/tool/releases/download/nightly/SHA256SUMS", false},
+		{"https://github.com/owner/tool/releases/latest/download/SHA256SUMS", false},
+		{"https://example.com/releases/download/v1.2.3/tool.tar.gz", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsImmutableURL(tt.url); got != tt.want {
+			t.Errorf("IsImmutableURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCacheHeaders(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		store     bool
+		expires   time.Time
+		immutable bool
+	}{
+		{"max-age", map[string]string{"Cache-Control": "public, max-age=60"}, true, now.Add(time.Minute), false},
+		{"max-age less age", map[string]string{"Cache-Control": "max-age=60", "Age": "20"}, true, now.Add(40 * time.Second), false},
+		{"max-age over expires", map[string]string{"Cache-Control": "max-age=60", "Expires": now.Add(time.Hour).UTC().Format(http.TimeFormat)}, true, now.Add(time.Minute), false},
+		{"no-cache", map[string]string{"Cache-Control": "no-cache, max-age=60", "ETag": `"v1"`}, true, now, false},
+		{"no-store", map[string]string{"Cache-Control": "no-store", "ETag": `"v1"`}, false, time.Time{}, false},
+		{"immutable", map[string]string{"Cache-Control": "max-age=31536000, immutable"}, true, now.Add(31536000 * time.Second), true},
+		{"validator only", map[string]string{"Last-Modified": "Mon, 01 Jan 2024 00:00:00 GMT"}, true, time.Time{}, false},
+		{"nothing", map[string]string{}, false, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			entry := &cacheEntry{URL: "https://example.com/SHA256SUMS"}
+			if got := applyCacheHeaders(entry, h, now); got != tt.store {
+				t.Errorf("applyCacheHeaders() = %v, want %v", got, tt.store)
+			}
+			if tt.store && !entry.Expires.Equal(tt.expires) {
+				t.Errorf("Expires = %v, want %v", entry.Expires, tt.expires)
+			}
+			if entry.Immutable != tt.immutable {
+				t.Errorf("Immutable = %v, want %v", entry.Immutable, tt.immutable)
+			}
+		})
+	}
+}
+
+func TestFetchChecksumRevalidates(t *testing.T) {
+	hash := strings.Repeat("c", 64)
+	var full, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"build-1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"build-1"`)
+		w.Write([]byte(hash + "  nightly.tar.gz\n"))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetCacheDir(t.TempDir())
+	for range 2 {
+		got, err := f.FetchChecksum(context.Background(), server.URL+"/SHA256SUMS", "https://example.com/nightly.tar.gz")
+		if err != nil {
+			t.Fatalf("FetchChecksum() failed: %v", err)
+		}
+		if got != "sha256:"+hash {
+			t.Errorf("FetchChecksum() = %q, want sha256:%s", got, hash)
+		}
+	}
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("server sent %d full and %d not modified responses, want 1 of each", full.Load(), notModified.Load())
+	}
+}
+
+func TestFetchChecksumMaxAge(t *testing.T) {
+	hash := strings.Repeat("c", 64)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.Write([]byte(hash + "  nightly.tar.gz\n"))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetCacheDir(t.TempDir())
+	for range 2 {
+		if _, err := f.FetchChecksum(context.Background(), server.URL+"/SHA256SUMS", "https://example.com/nightly.tar.gz"); err != nil {
+			t.Fatalf("FetchChecksum() failed: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("server got %d requests, want 1 while the response is fresh", requests.Load())
+	}
+}
+
+func TestContentLengthCachesImmutable(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/immutable.tar.gz" {
+			w.Header().Set("Cache-Control", "public, max-age=0, immutable")
+		}
+		w.Header().Set("Content-Length", "1234")
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetCacheDir(t.TempDir())
+	for _, path := range []string{"/immutable.tar.gz", "/immutable.tar.gz", "/plain.tar.gz", "/plain.tar.gz"} {
+		if got := f.ContentLength(context.Background(), server.URL+path); got != 1234 {
+			t.Errorf("ContentLength(%s) = %d, want 1234", path, got)
+		}
+	}
+	if requests.Load() != 3 {
+		t.Errorf("server got %d requests, want 3 (one for the immutable file, two for the other)", requests.Load())
+	}
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SetHTTPProxy sends requests through the HTTP(S) proxy at proxyURL, except to the hosts
+// in noProxy. Unlike the caching proxy of SetProxy, requests keep their URLs; the proxy
+// forwards them, or tunnels HTTPS. Without a call, the fetcher uses the proxy in
+// $HTTPS_PROXY or $HTTP_PROXY, as Go programs do.
+func (f *Fetcher) SetHTTPProxy(proxyURL, noProxy string) error {
+	transport, err := ProxyTransport(f.client.Transport, proxyURL, noProxy)
+	if err != nil {
+		return err
+	}
+	client := *f.client
+	client.Transport = transport
+	f.client = &client
+	return nil
+}
+
+// ProxyTransport returns a copy of base, or of http.DefaultTransport if base is nil, that
+// sends requests through proxyURL, except to hosts in noProxy: a comma-separated list like
+// $NO_PROXY, where example.com and .example.com both match the domain and its subdomains
+// and * matches every host
+func ProxyTransport(base http.RoundTripper, proxyURL, noProxy string) (http.RoundTripper, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: want a URL such as http://proxy.example.com:3128", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: want an http, https or socks5 URL", proxyURL)
+	}
+
+	proxy := func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return u, nil
+	}
+	return configureTransport(base, func(t *http.Transport) { t.Proxy = proxy }), nil
+}
+
+// cloneTransport returns a copy of base, or of http.DefaultTransport if base is nil, to
+// change the settings of. A base that isn't an *http.Transport is replaced by a new one.
+func cloneTransport(base http.RoundTripper) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if transport, ok := base.(*http.Transport); ok {
+		return transport.Clone()
+	}
+	return &http.Transport{}
+}
+
+// bypassProxy reports whether host is listed in noProxy
+func bypassProxy(host, noProxy string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if entry != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		host    string
+		noProxy string
+		want    bool
+	}{
+		{"example.com", "", false},
+		{"example.com", "example.com", true},
+		{"dl.example.com", "example.com", true},
+		{"dl.example.com", " other.org, .example.com", true},
+		{"DL.Example.com", "*.example.com", true},
+		{"badexample.com", "example.com", false},
+		{"10.0.0.1", "10.0.0.1", true},
+		{"anything", "*", true},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q, %q) = %v, want %v", tt.host, tt.noProxy, got, tt.want)
+		}
+	}
+}
+
+func TestSetHTTPProxyInvalid(t *testing.T) {
+	for _, proxy := range []string{"proxy.example.com:3128", "ftp://proxy.example.com", "http://"} {
+		if err := New().SetHTTPProxy(proxy, ""); err == nil {
+			t.Errorf("SetHTTPProxy(%q) should fail", proxy)
+		}
+	}
+}
+
+func TestFetchThroughHTTPProxy(t *testing.T) {
+	data := []byte("payload")
+	var requested []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+		w.Write(data)
+	}))
+	defer proxy.Close()
+
+	f := New()
+	if err := f.SetHTTPProxy(proxy.URL, "127.0.0.1"); err != nil {
+		t.Fatalf("SetHTTPProxy() failed: %v", err)
+	}
+	got, err := f.Fetch(context.Background(), "http://downloads.example.com/tool.tar.gz", checksumOf(data))
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("Fetch() through proxy = %q, %v, want %q", got, err, data)
+	}
+	if len(requested) != 1 || requested[0] != "http://downloads.example.com/tool.tar.gz" {
+		t.Errorf("proxy requests = %v, want the full upstream URL", requested)
+	}
+
+	// Hosts in the no-proxy list are reached directly
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer direct.Close()
+	requested = nil
+	if _, err := f.Fetch(context.Background(), direct.URL+"/tool.tar.gz", checksumOf(data)); err != nil || len(requested) != 0 {
+		t.Errorf("Fetch() of a no-proxy host = %v with proxy requests %v, want a direct connection", err, requested)
+	}
+}
@@ -0,0 +1,209 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/events"
+)
+
+// probeTimeout bounds each mirror health probe
+const probeTimeout = 2 * time.Second
+
+// healthTTL is how long a probe result, or a failed download, decides a host's rank
+// before the host is probed again
+const healthTTL = 5 * time.Minute
+
+// health is the result of probing a mirror host
+type health struct {
+	ok      bool
+	latency time.Duration
+	checked time.Time
+}
+
+// HostHealth caches the health of mirror hosts, so installing several packages from the
+// same mirrors probes each host once. Results expire after a few minutes, so a host that
+// failed once is tried again in a long-running process.
+type HostHealth struct {
+	mu    sync.Mutex
+	hosts map[string]health
+}
+
+// NewHostHealth creates an empty mirror health cache
+func NewHostHealth() *HostHealth {
+	return &HostHealth{hosts: make(map[string]health)}
+}
+
+// Reset forgets the health of every host
+func (h *HostHealth) Reset() {
+	h.mu.Lock()
+	clear(h.hosts)
+	h.mu.Unlock()
+}
+
+// get returns the cached health of host, if it hasn't expired
+func (h *HostHealth) get(host string) (health, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.hosts[host]
+	if !ok || time.Since(r.checked) > healthTTL {
+		return health{}, false
+	}
+	return r, true
+}
+
+// set records the health of host
+func (h *HostHealth) set(host string, r health) {
+	r.checked = time.Now()
+	h.mu.Lock()
+	h.hosts[host] = r
+	h.mu.Unlock()
+}
+
+// SetHostHealth makes the fetcher rank mirrors with health, which other fetchers may
+// share. Each fetcher starts with a cache of its own.
+func (f *Fetcher) SetHostHealth(health *HostHealth) {
+	f.health = health
+}
+
+// RankMirrors orders urls with healthy hosts first, fastest first. Each host is probed
+// with a HEAD request; unhealthy hosts keep their original order at the end.
+func (f *Fetcher) RankMirrors(ctx context.Context, urls []string) []string {
+	results := make([]health, len(urls))
+
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			results[i] = f.probe(ctx, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	order := make([]int, len(urls))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := results[order[a]], results[order[b]]
+		if ra.ok != rb.ok {
+			return ra.ok
+		}
+		return ra.ok && ra.latency < rb.latency
+	})
+
+	ranked := make([]string, len(urls))
+	for i, idx := range order {
+		ranked[i] = urls[idx]
+		if results[idx].ok {
+			f.logf("mirror %s: %v\n", hostOf(urls[idx]), results[idx].latency.Round(time.Millisecond))
+		} else {
+			f.logf("mirror %s: unreachable\n", hostOf(urls[idx]))
+		}
+	}
+	return ranked
+}
+
+// probe returns the health of the host serving rawURL, probing it if it has not been seen yet
+func (f *Fetcher) probe(ctx context.Context, rawURL string) health {
+	host := hostOf(rawURL)
+	h, ok := f.health.get(host)
+	if ok {
+		return h
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := f.newRequest(ctx, "HEAD", rawURL)
+	if err == nil {
+		start := time.Now()
+		resp, err := f.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			h = health{ok: resp.StatusCode < 400, latency: time.Since(start)}
+		}
+	}
+
+	f.health.set(host, h)
+	return h
+}
+
+// FetchFromMirrors downloads a file available at several URLs and verifies its checksum.
+// With more than one URL the mirrors are ranked by health and latency, and the next
+// one is tried whenever a download fails. A download that breaks off partway resumes
+// on the next mirror with a Range request; the checksum covers the whole file, so a
+// bad mix of mirrors is still caught, and the download then restarts from scratch.
+func (f *Fetcher) FetchFromMirrors(ctx context.Context, urls []string, expectedChecksum string, progressWriter io.Writer) ([]byte, error) {
+	if data := f.trySegmentedData(ctx, urls, expectedChecksum, progressWriter); data != nil {
+		return data, nil
+	}
+	if len(urls) == 1 {
+		return f.FetchWithProgress(ctx, urls[0], expectedChecksum, progressWriter)
+	}
+
+	var data []byte
+	var encoding string
+	var lastErr error
+	for _, u := range f.RankMirrors(ctx, urls) {
+		if len(data) > 0 {
+			f.logf("resuming from %s at byte %d\n", u, len(data))
+		} else {
+			f.logf("downloading from %s\n", u)
+		}
+
+		part, partEncoding, err := f.fetchFrom(ctx, u, int64(len(data)), progressWriter)
+		if errors.Is(err, errNothingPastOffset) {
+			// A mirror that broke off after the last byte left the download complete;
+			// anything else is started over
+			if verified, _, err := f.verifyDecoded(data, encoding, expectedChecksum); err == nil {
+				return verified, nil
+			}
+			f.logf("discarding the partial download of %s\n", u)
+			data = nil
+			part, partEncoding, err = f.fetchFrom(ctx, u, 0, progressWriter)
+		}
+		data = append(data, part...)
+		if len(part) > 0 || err == nil {
+			encoding = partEncoding
+		}
+		if err == nil {
+			var verified []byte
+			if verified, _, err = f.verifyDecoded(data, encoding, expectedChecksum); err == nil {
+				return verified, nil
+			}
+			data = nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		f.logf("mirror %s failed: %v\n", hostOf(u), err)
+		events.FromContext(ctx).Emit(events.Event{Event: events.Failover, URL: u, Bytes: int64(len(data)), Error: err.Error()})
+		f.markUnhealthy(u)
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("all %d mirrors failed: %w", len(urls), lastErr)
+}
+
+// markUnhealthy records that a host failed, so later downloads try it last
+func (f *Fetcher) markUnhealthy(rawURL string) {
+	f.health.set(hostOf(rawURL), health{})
+}
+
+// hostOf returns the host:port of rawURL, or rawURL itself if it cannot be parsed
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
@@ -0,0 +1,266 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/events"
+)
+
+// mirrorServer serves data after delay, or fails every request when broken
+func mirrorServer(t *testing.T, data []byte, delay time.Duration, broken bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if broken {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func checksumOf(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func TestRankMirrors(t *testing.T) {
+	data := []byte("payload")
+	slow := mirrorServer(t, data, 50*time.Millisecond, false)
+	fast := mirrorServer(t, data, 0, false)
+	broken := mirrorServer(t, data, 0, true)
+
+	urls := []string{broken.URL + "/a", slow.URL + "/a", fast.URL + "/a"}
+	got := New().RankMirrors(context.Background(), urls)
+
+	want := []string{fast.URL + "/a", slow.URL + "/a", broken.URL + "/a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("RankMirrors() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRankMirrorsCachesHostHealth(t *testing.T) {
+	var probes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			probes++
+		}
+	}))
+	defer server.Close()
+
+	f := New()
+	f.RankMirrors(context.Background(), []string{server.URL + "/a", server.URL + "/b"})
+	f.RankMirrors(context.Background(), []string{server.URL + "/c", "https://mirror.invalid/c"})
+
+	if probes > 2 {
+		t.Errorf("host probed %d times, want results reused within the session", probes)
+	}
+}
+
+func TestHostHealthScope(t *testing.T) {
+	var probes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			probes++
+		}
+	}))
+	defer server.Close()
+	urls := []string{server.URL + "/a", "https://mirror.invalid/a"}
+
+	// A failure seen by one fetcher doesn't demote the host for another
+	f := New()
+	f.markUnhealthy(server.URL + "/a")
+	if got := New().RankMirrors(context.Background(), urls); got[0] != server.URL+"/a" || probes != 1 {
+		t.Errorf("RankMirrors() = %v after %d probe(s), want the host probed afresh and first", got, probes)
+	}
+
+	// Fetchers sharing a cache share what they found
+	shared := NewHostHealth()
+	f.SetHostHealth(shared)
+	f.RankMirrors(context.Background(), urls)
+	g := New()
+	g.SetHostHealth(shared)
+	g.RankMirrors(context.Background(), urls)
+	if probes != 2 {
+		t.Errorf("host probed %d times, want once more for the shared cache", probes)
+	}
+
+	// Results expire, and Reset forgets them at once
+	shared.mu.Lock()
+	for host, h := range shared.hosts {
+		h.checked = time.Now().Add(-healthTTL - time.Second)
+		shared.hosts[host] = h
+	}
+	shared.mu.Unlock()
+	g.RankMirrors(context.Background(), urls)
+	if probes != 3 {
+		t.Errorf("host probed %d times, want an expired result probed again", probes)
+	}
+	shared.Reset()
+	g.RankMirrors(context.Background(), urls)
+	if probes != 4 {
+		t.Errorf("host probed %d times, want a reset cache probed again", probes)
+	}
+}
+
+func TestFetchFromMirrorsFailover(t *testing.T) {
+	data := []byte("payload")
+	// The corrupt mirror answers fastest but serves the wrong bytes
+	corrupt := mirrorServer(t, []byte("tampered"), 0, false)
+	good := mirrorServer(t, data, 20*time.Millisecond, false)
+
+	var log bytes.Buffer
+	f := New()
+	f.SetVerbose(&log)
+
+	logPath := filepath.Join(t.TempDir(), "events.jsonl")
+	eventLog, err := events.Open(logPath, "test")
+	if err != nil {
+		t.Fatalf("events.Open() failed: %v", err)
+	}
+	ctx := events.NewContext(context.Background(), eventLog)
+
+	got, err := f.FetchFromMirrors(ctx, []string{corrupt.URL + "/f", good.URL + "/f"}, checksumOf(data), nil)
+	if err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("FetchFromMirrors() = %q, want %q", got, data)
+	}
+	if !strings.Contains(log.String(), "downloading from "+good.URL) {
+		t.Errorf("verbose log = %q, want the chosen mirror reported", log.String())
+	}
+
+	eventLog.Close()
+	logged, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(logged), `"event":"failover","url":"`+corrupt.URL+`/f"`) {
+		t.Errorf("event log = %q, want a failover away from the corrupt mirror", logged)
+	}
+}
+
+func TestFetchFromMirrorsAllFail(t *testing.T) {
+	broken := mirrorServer(t, nil, 0, true)
+	alsoBroken := mirrorServer(t, nil, 0, true)
+
+	_, err := New().FetchFromMirrors(context.Background(), []string{broken.URL, alsoBroken.URL}, checksumOf([]byte("x")), nil)
+	if err == nil {
+		t.Error("FetchFromMirrors() should fail when every mirror fails")
+	}
+}
+
+func TestFetchFromMirrorsResumes(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	half := len(data) / 2
+
+	// The fastest mirror drops the connection halfway through the body
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == "HEAD" {
+			return
+		}
+		w.Write(data[:half])
+	}))
+	defer dropping.Close()
+
+	var ranges []string
+	resuming := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		if r.Method == "GET" {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer resuming.Close()
+
+	got, err := New().FetchFromMirrors(context.Background(), []string{dropping.URL + "/f", resuming.URL + "/f"}, checksumOf(data), nil)
+	if err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("FetchFromMirrors() returned %d bytes, want %d", len(got), len(data))
+	}
+	if want := fmt.Sprintf("bytes=%d-", half); len(ranges) != 1 || ranges[0] != want {
+		t.Errorf("second mirror got Range %q, want %q", ranges, want)
+	}
+}
+
+func TestFetchFromMirrorsCompleteAfterDrop(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// The fastest mirror sends every byte, then drops the connection short of the
+	// length it announced
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)+1))
+		if r.Method == "HEAD" {
+			return
+		}
+		w.Write(data)
+	}))
+	defer dropping.Close()
+
+	// The other mirrors have nothing past the end of the file
+	var ranges []string
+	resuming := func() *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			if r.Method == "GET" {
+				ranges = append(ranges, r.Header.Get("Range"))
+			}
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	urls := []string{dropping.URL + "/f", resuming().URL + "/f", resuming().URL + "/f"}
+
+	got, err := New().FetchFromMirrors(context.Background(), urls, checksumOf(data), nil)
+	if err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("FetchFromMirrors() returned %d bytes, want %d", len(got), len(data))
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(data)); len(ranges) != 1 || ranges[0] != want {
+		t.Errorf("other mirrors got Range %q, want one request for %q", ranges, want)
+	}
+}
+
+func TestFetchFromMirrorsIgnoredRange(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefghij"), 1000)
+	half := len(data) / 2
+
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == "HEAD" {
+			return
+		}
+		w.Write(data[:half])
+	}))
+	defer dropping.Close()
+
+	// This mirror ignores Range and always sends the whole file
+	whole := mirrorServer(t, data, 20*time.Millisecond, false)
+
+	got, err := New().FetchFromMirrors(context.Background(), []string{dropping.URL + "/f", whole.URL + "/f"}, checksumOf(data), nil)
+	if err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("FetchFromMirrors() returned %d bytes, want %d", len(got), len(data))
+	}
+}
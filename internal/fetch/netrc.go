@@ -0,0 +1,114 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// NetrcPath returns the .netrc file credentials are read from: $NETRC, or else .netrc in
+// the home directory, or _netrc on Windows if there is no .netrc, as curl does
+func NetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || !filepath.IsAbs(home) {
+		return ""
+	}
+	path := filepath.Join(home, ".netrc")
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(home, "_netrc")
+		}
+	}
+	return path
+}
+
+// NetrcHeaders returns the Authorization header for each machine in the .netrc file at
+// path, for AuthTransport. A missing file has no credentials. The default entry is
+// ignored, since credentials for every host would reach any server a download
+// redirects to.
+func NetrcHeaders(path string) (map[string]http.Header, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	headers := make(map[string]http.Header)
+	for _, entry := range parseNetrc(string(data)) {
+		if entry.machine == "" || entry.login == "" {
+			continue
+		}
+		host := strings.ToLower(entry.machine)
+		if headers[host] != nil {
+			continue // the first entry for a machine wins
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(entry.login + ":" + entry.password))
+		headers[host] = http.Header{"Authorization": {"Basic " + credentials}}
+	}
+	return headers, nil
+}
+
+// netrcEntry is a machine's entry in a .netrc file; machine is "" for the default entry
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// parseNetrc returns the entries of the .netrc file in data. Macro definitions, which
+// run until a blank line, are skipped.
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var current *netrcEntry
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			if strings.HasPrefix(fields[j], "#") {
+				break
+			}
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+			switch fields[j] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: next()})
+				current = &entries[len(entries)-1]
+			case "default":
+				entries = append(entries, netrcEntry{})
+				current = &entries[len(entries)-1]
+			case "login":
+				if login := next(); current != nil {
+					current.login = login
+				}
+			case "password":
+				if password := next(); current != nil {
+					current.password = password
+				}
+			case "account":
+				next()
+			case "macdef":
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	return entries
+}
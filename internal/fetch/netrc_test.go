@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	data := `# credentials
+machine github.com login octocat password ghp_token
+machine Artifacts.example.com
+  login deploy
+  account ops
+  password s3cret
+
+macdef init
+cd /pub
+machine not.a.machine login macro
+
+default login anonymous password me@example.com
+`
+	want := []netrcEntry{
+		{machine: "github.com", login: "octocat", password: "ghp_token"},
+		{machine: "Artifacts.example.com", login: "deploy", password: "s3cret"},
+		{login: "anonymous", password: "me@example.com"},
+	}
+	if got := parseNetrc(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNetrc() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNetrcHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if headers, err := NetrcHeaders(path); headers != nil || err != nil {
+		t.Errorf("NetrcHeaders() of a missing file = %v, %v, want nothing", headers, err)
+	}
+
+	os.WriteFile(path, []byte("machine Example.com login user password pass\nmachine example.com login other password x\ndefault login anonymous password x\n"), 0600)
+	headers, err := NetrcHeaders(path)
+	if err != nil {
+		t.Fatalf("NetrcHeaders() failed: %v", err)
+	}
+	if len(headers) != 1 || headers["example.com"].Get("Authorization") != "Basic dXNlcjpwYXNz" {
+		t.Errorf("NetrcHeaders() = %v, want the first example.com login only", headers)
+	}
+
+	t.Setenv("NETRC", path)
+	if got := NetrcPath(); got != path {
+		t.Errorf("NetrcPath() = %q, want $NETRC", got)
+	}
+}
@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// A payload checksum covers the tar stream inside an archive's compression. Upstreams
+// that recompress their archives, with another level or a newer gzip, change the
+// archive's digest but not its payload's, so archives verify by either.
+
+// SetPayloadChecksum makes downloads whose own checksum doesn't match acceptable when
+// their decompressed payload matches sum. Empty requires the archive checksum.
+func (f *Fetcher) SetPayloadChecksum(sum string) {
+	f.payload = sum
+}
+
+// PayloadChecksum returns the sha256:hex digest of an archive's payload: its contents
+// once gzip compression is undone, or the archive itself when it isn't compressed
+func PayloadChecksum(data []byte) (string, error) {
+	return payloadSum(bytes.NewReader(data))
+}
+
+// payloadSum is PayloadChecksum for an archive read from r
+func payloadSum(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if err := copyPayload(hash, r); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyPayload verifies that the payload of data matches the expected checksum
+func VerifyPayload(data []byte, expected string) error {
+	actual, err := PayloadChecksum(data)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("payload checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// copyPayload writes the payload of the archive read from r to w
+func copyPayload(w io.Writer, r io.Reader) error {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		_, err := io.Copy(w, buffered)
+		return err
+	}
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		return fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	defer gz.Close()
+	if _, err := io.Copy(w, gz); err != nil {
+		return fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	return nil
+}
+
+// verifyPayload reports whether data's payload matches the payload checksum, if one is set
+func (f *Fetcher) verifyPayload(data []byte) bool {
+	return f.payload != "" && VerifyPayload(data, f.payload) == nil
+}
+
+// payloadMismatch adds to err, an archive checksum mismatch, that the payload checksum
+// didn't match either, if one is set
+func (f *Fetcher) payloadMismatch(err error) error {
+	if f.payload == "" {
+		return err
+	}
+	return fmt.Errorf("%w, nor does its payload match %s", err, f.payload)
+}
@@ -0,0 +1,62 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPayloadChecksum(t *testing.T) {
+	payload := []byte("tar contents")
+	for name, data := range map[string][]byte{"plain": payload, "gzip": gzipped(payload)} {
+		if got, err := PayloadChecksum(data); err != nil || got != checksumOf(payload) {
+			t.Errorf("PayloadChecksum(%s) = %q, %v, want %s", name, got, err, checksumOf(payload))
+		}
+	}
+	if _, err := PayloadChecksum([]byte{0x1f, 0x8b, 0}); err == nil {
+		t.Error("PayloadChecksum() should fail for corrupt gzip")
+	}
+}
+
+func TestFetchAcceptsMatchingPayload(t *testing.T) {
+	// The published archive and the one served hold the same tar, compressed differently
+	payload := []byte("tar contents")
+	published := gzipped(payload)
+	var buf bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gw.Write(payload)
+	gw.Close()
+	served := buf.Bytes()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(served)
+	}))
+	defer server.Close()
+
+	f := New()
+	if _, err := f.Fetch(context.Background(), server.URL, checksumOf(published)); err == nil {
+		t.Fatal("Fetch() should reject a recompressed archive without a payload checksum")
+	}
+
+	f.SetPayloadChecksum(checksumOf(payload))
+	data, err := f.Fetch(context.Background(), server.URL, checksumOf(published))
+	if err != nil || !bytes.Equal(data, served) {
+		t.Errorf("Fetch() = %q, %v, want the archive as served", data, err)
+	}
+	path := filepath.Join(t.TempDir(), "archive")
+	if err := f.FetchToFile(context.Background(), []string{server.URL}, checksumOf(published), path, nil); err != nil {
+		t.Errorf("FetchToFile() failed: %v", err)
+	} else if got, _ := os.ReadFile(path); !bytes.Equal(got, served) {
+		t.Errorf("downloaded file = %q, want the archive as served", got)
+	}
+
+	f.SetPayloadChecksum(checksumOf([]byte("other")))
+	if _, err := f.Fetch(context.Background(), server.URL, checksumOf(published)); err == nil || !strings.Contains(err.Error(), "nor does its payload match") {
+		t.Errorf("Fetch() = %v, want a mismatch of both checksums", err)
+	}
+}
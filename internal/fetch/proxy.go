@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginalURLHeader carries the upstream URL of a download sent through a caching proxy
+const OriginalURLHeader = "X-Nori-Original-URL"
+
+// SetProxy routes downloads through a read-through caching proxy, such as an Artifactory
+// remote repository. See ProxyURL for how URLs map onto prefix.
+func (f *Fetcher) SetProxy(prefix string) error {
+	u, err := url.Parse(prefix)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid asset proxy %q: want an http(s) URL", prefix)
+	}
+	f.proxy = strings.TrimSuffix(prefix, "/")
+	return nil
+}
+
+// ProxyURL returns where rawURL is fetched from through the proxy prefix: the upstream
+// host and path are appended to the prefix, so https://example.com/v1/tool.tar.gz
+// becomes <prefix>/example.com/v1/tool.tar.gz
+func ProxyURL(prefix, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	proxied := strings.TrimSuffix(prefix, "/") + "/" + u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		proxied += "?" + u.RawQuery
+	}
+	return proxied, nil
+}
+
+// newRequest creates a request for rawURL, sent through the proxy when one is set. It
+// asks for the file as published, without a Content-Encoding (see encoding.go).
+func (f *Fetcher) newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	if f.proxy == "" {
+		return f.directRequest(ctx, method, rawURL)
+	}
+	proxied, err := ProxyURL(f.proxy, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := f.directRequest(ctx, method, proxied)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(OriginalURLHeader, rawURL)
+	return req, nil
+}
+
+// directRequest creates a request for rawURL that bypasses the caching proxy, asking
+// for the file as published
+func (f *Fetcher) directRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	return req, nil
+}
+
+// ContentLength returns the size of the file at rawURL, or 0 if it can't be determined.
+// Sizes of immutable files are kept in the HTTP cache, if one is set.
+func (f *Fetcher) ContentLength(ctx context.Context, rawURL string) int64 {
+	if size := f.cachedLength(rawURL); size > 0 {
+		return size
+	}
+	req, err := f.newRequest(ctx, "HEAD", rawURL)
+	if err != nil {
+		return 0
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	f.saveLength(rawURL, resp)
+	return max(resp.ContentLength, 0)
+}
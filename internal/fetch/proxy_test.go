@@ -0,0 +1,91 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProxyURL(t *testing.T) {
+	tests := []struct {
+		prefix string
+		url    string
+		want   string
+	}{
+		{"https://proxy.example.com/nori", "https://example.com/v1/tool.tar.gz", "https://proxy.example.com/nori/example.com/v1/tool.tar.gz"},
+		{"https://proxy.example.com/nori/", "https://example.com:8443/a%20b.zip?x=1", "https://proxy.example.com/nori/example.com:8443/a%20b.zip?x=1"},
+	}
+	for _, tt := range tests {
+		got, err := ProxyURL(tt.prefix, tt.url)
+		if err != nil || got != tt.want {
+			t.Errorf("ProxyURL(%q, %q) = %q, %v, want %q", tt.prefix, tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetProxyInvalid(t *testing.T) {
+	for _, prefix := range []string{"", "proxy.example.com", "ftp://proxy.example.com", "https://"} {
+		if err := New().SetProxy(prefix); err == nil {
+			t.Errorf("SetProxy(%q) should fail", prefix)
+		}
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	data := []byte("payload")
+	var paths, originals []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		originals = append(originals, r.Header.Get(OriginalURLHeader))
+		w.Write(data)
+	}))
+	defer proxy.Close()
+
+	f := New()
+	if err := f.SetProxy(proxy.URL + "/remote"); err != nil {
+		t.Fatalf("SetProxy() failed: %v", err)
+	}
+
+	original := "https://downloads.example.com/v1/tool.tar.gz"
+	got, err := f.Fetch(context.Background(), original, checksumOf(data))
+	if err != nil {
+		t.Fatalf("Fetch() through proxy failed: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Fetch() = %q, want %q", got, data)
+	}
+	if len(paths) != 1 || paths[0] != "/remote/downloads.example.com/v1/tool.tar.gz" {
+		t.Errorf("proxy paths = %v, want the upstream host and path under the prefix", paths)
+	}
+	if len(originals) != 1 || originals[0] != original {
+		t.Errorf("%s = %v, want %q", OriginalURLHeader, originals, original)
+	}
+}
+
+func TestChecksumsBypassProxy(t *testing.T) {
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.Write([]byte("0000000000000000000000000000000000000000000000000000000000000000  tool.tar.gz\n"))
+	}))
+	defer proxy.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1111111111111111111111111111111111111111111111111111111111111111  tool.tar.gz\n"))
+	}))
+	defer upstream.Close()
+
+	// The proxy could otherwise vouch for whatever it serves as the archive
+	f := New()
+	if err := f.SetProxy(proxy.URL + "/remote"); err != nil {
+		t.Fatalf("SetProxy() failed: %v", err)
+	}
+	sum, err := f.FetchChecksum(context.Background(), upstream.URL+"/SHA256SUMS", upstream.URL+"/tool.tar.gz")
+	if err != nil || sum != "sha256:"+strings.Repeat("1", 64) {
+		t.Errorf("FetchChecksum() = %q, %v, want the upstream checksum", sum, err)
+	}
+	if proxied != 0 {
+		t.Errorf("the checksums file was requested through the proxy %d times", proxied)
+	}
+}
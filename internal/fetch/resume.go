@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/events"
+)
+
+// errNothingPastOffset is returned when a server has no bytes past the offset a download
+// is resumed at: the partial download is either complete or not of the file
+var errNothingPastOffset = errors.New("the server has nothing past the partial download")
+
+// FetchToFile downloads a file available at urls into path and verifies its checksum.
+// Bytes are appended to path+".part" as they arrive, so a download interrupted by a
+// failure or a killed process resumes where it stopped the next time it is fetched.
+// Mirrors are tried in turn as in FetchFromMirrors. Large files are downloaded in
+// segments into the same .part file when the fetcher's concurrency allows.
+func (f *Fetcher) FetchToFile(ctx context.Context, urls []string, expectedChecksum, path string, progressWriter io.Writer) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+	partPath := path + ".part"
+
+	// A partial download left by an earlier attempt is resumed over one connection
+	if info, err := os.Stat(partPath); err != nil || info.Size() == 0 {
+		if f.trySegmented(ctx, urls, expectedChecksum, path, progressWriter) {
+			return nil
+		}
+	}
+
+	if len(urls) > 1 {
+		urls = f.RankMirrors(ctx, urls)
+	}
+
+	var lastErr error
+	for _, u := range urls {
+		encoding, err := f.appendFrom(ctx, u, partPath, progressWriter)
+		if errors.Is(err, errNothingPastOffset) {
+			// A download killed before it was saved is complete already; anything
+			// else is started over
+			if f.verifyDecodedFile(partPath, "", expectedChecksum) == nil {
+				if err := os.Rename(partPath, path); err != nil {
+					return fmt.Errorf("failed to save download: %w", err)
+				}
+				return nil
+			}
+			f.logf("discarding the partial download of %s\n", u)
+			os.Remove(partPath)
+			encoding, err = f.appendFrom(ctx, u, partPath, progressWriter)
+		}
+		if err == nil {
+			if err = f.verifyDecodedFile(partPath, encoding, expectedChecksum); err == nil {
+				if err := os.Rename(partPath, path); err != nil {
+					return fmt.Errorf("failed to save download: %w", err)
+				}
+				return nil
+			}
+			// The bytes kept from an earlier attempt did not belong to this file
+			os.Remove(partPath)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		f.logf("%s failed: %v\n", hostOf(u), err)
+		if len(urls) > 1 {
+			events.FromContext(ctx).Emit(events.Event{Event: events.Failover, URL: u, Error: err.Error()})
+			f.markUnhealthy(u)
+		}
+		lastErr = err
+	}
+
+	if len(urls) > 1 {
+		return fmt.Errorf("all %d mirrors failed: %w", len(urls), lastErr)
+	}
+	return lastErr
+}
+
+// appendFrom downloads the rest of url onto the partial file at partPath, returning the
+// response's Content-Encoding
+func (f *Fetcher) appendFrom(ctx context.Context, url, partPath string, progressWriter io.Writer) (string, error) {
+	part, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open partial download: %w", err)
+	}
+	defer part.Close()
+
+	info, err := part.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat partial download: %w", err)
+	}
+	if info.Size() > 0 {
+		f.logf("resuming %s at byte %d\n", url, info.Size())
+	}
+	return f.copyFrom(ctx, url, info.Size(), part, progressWriter)
+}
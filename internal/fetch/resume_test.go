@@ -0,0 +1,105 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetchToFileResumes(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		var offset int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset)
+		if offset > 0 {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(data[offset:])
+	}))
+	defer server.Close()
+
+	// A previous run was interrupted after the first 8 bytes
+	path := filepath.Join(t.TempDir(), "cache", "archive")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path+".part", data[:8], 0644)
+
+	if err := New().FetchToFile(context.Background(), []string{server.URL + "/a"}, checksumOf(data), path, nil); err != nil {
+		t.Fatalf("FetchToFile() failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(data) {
+		t.Errorf("downloaded file = %q, want %q", got, data)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=8-" {
+		t.Errorf("Range headers = %v, want a single request resuming at byte 8", ranges)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("partial file should be gone after a complete download")
+	}
+}
+
+func TestFetchToFileDiscardsBadPartial(t *testing.T) {
+	data := []byte("payload")
+	server := mirrorServer(t, data, 0, false)
+
+	path := filepath.Join(t.TempDir(), "archive")
+	os.WriteFile(path+".part", []byte("junk"), 0644)
+
+	err := New().FetchToFile(context.Background(), []string{server.URL + "/a"}, checksumOf(data), path, nil)
+	if err == nil {
+		t.Fatal("FetchToFile() should fail when the partial file doesn't match")
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("a partial file that fails verification should be removed")
+	}
+
+	// The next attempt starts over and succeeds
+	if err := New().FetchToFile(context.Background(), []string{server.URL + "/a"}, checksumOf(data), path, nil); err != nil {
+		t.Fatalf("FetchToFile() retry failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(data) {
+		t.Errorf("downloaded file = %q, want %q", got, data)
+	}
+}
+
+func TestFetchToFileCompletePartial(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeContent(w, r, "archive", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name     string
+		part     []byte
+		requests int
+	}{
+		// Killed after the last byte arrived, but before the file was saved
+		{"a complete partial file", data, 1},
+		// Longer than the file, so not of it, and downloaded again from the start
+		{"an overlong partial file", append(bytes.Clone(data), "junk"...), 2},
+	} {
+		requests = 0
+		path := filepath.Join(t.TempDir(), "archive")
+		os.WriteFile(path+".part", tt.part, 0644)
+		if err := New().FetchToFile(context.Background(), []string{server.URL + "/a"}, checksumOf(data), path, nil); err != nil {
+			t.Errorf("FetchToFile() with %s failed: %v", tt.name, err)
+			continue
+		}
+		if got, _ := os.ReadFile(path); string(got) != string(data) {
+			t.Errorf("downloaded file with %s = %q, want %q", tt.name, got, data)
+		}
+		if requests != tt.requests {
+			t.Errorf("FetchToFile() with %s made %d requests, want %d", tt.name, requests, tt.requests)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// segmentThreshold is the size from which a download is split into segments, when the
+// fetcher's concurrency allows. Smaller files gain little from more connections.
+var segmentThreshold int64 = 32 << 20
+
+// SetConcurrency makes downloads of 32MiB or more use up to n connections at once, each
+// fetching one range of the file into a partial file on disk: beside the destination of
+// FetchToFile, and in the download directory for downloads returned in memory. This
+// speeds up large downloads on high-latency links. 1 or less uses one connection, as
+// does a server that doesn't support ranges.
+func (f *Fetcher) SetConcurrency(n int) {
+	f.concurrency = n
+}
+
+// SetDownloadDir assembles segmented downloads that are returned in memory in dir, which
+// needs room for them. Without one, such downloads use one connection.
+func (f *Fetcher) SetDownloadDir(dir string) {
+	f.downloadDir = dir
+}
+
+// ParseConcurrency parses a number of download connections, such as the value of
+// NORI_DOWNLOAD_CONCURRENCY
+func ParseConcurrency(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid download concurrency %q: want a number of connections, 1 or more", s)
+	}
+	return n, nil
+}
+
+// trySegmented downloads the first of urls into path in segments if the fetcher's
+// concurrency, the file's size and the server allow, assembling them in path+".part" and
+// verifying it before it is renamed into place. It reports whether it did; when it
+// didn't or the download failed, the caller uses one connection.
+func (f *Fetcher) trySegmented(ctx context.Context, urls []string, expectedChecksum, path string, progressWriter io.Writer) bool {
+	if f.concurrency <= 1 {
+		return false
+	}
+	u := urls[0]
+	if len(urls) > 1 {
+		u = f.RankMirrors(ctx, urls)[0]
+	}
+
+	size := f.rangeSize(ctx, u)
+	if size < segmentThreshold {
+		return false
+	}
+	if err := f.fetchSegments(ctx, u, size, expectedChecksum, path, progressWriter); err != nil {
+		f.logf("segmented download of %s failed: %v; downloading it over one connection\n", u, err)
+		return false
+	}
+	return true
+}
+
+// trySegmentedData is trySegmented for a download returned in memory, assembled in the
+// download directory. It returns nil when it didn't download the file.
+func (f *Fetcher) trySegmentedData(ctx context.Context, urls []string, expectedChecksum string, progressWriter io.Writer) []byte {
+	if f.concurrency <= 1 || f.downloadDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(f.downloadDir, 0755); err != nil {
+		return nil
+	}
+	file, err := os.CreateTemp(f.downloadDir, "nori-segments-*")
+	if err != nil {
+		return nil
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if !f.trySegmented(ctx, urls, expectedChecksum, file.Name(), progressWriter) {
+		return nil
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// rangeSize returns the size of the file at url if the server serves ranges of it as
+// published, and otherwise 0
+func (f *Fetcher) rangeSize(ctx context.Context, url string) int64 {
+	req, err := f.newRequest(ctx, "HEAD", url)
+	if err != nil {
+		return 0
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || contentEncoding(resp.Header) != "" {
+		return 0
+	}
+	return max(resp.ContentLength, 0)
+}
+
+// fetchSegments downloads the size bytes of url in f.concurrency ranges at once,
+// writing each at its offset in path+".part", and renames the file to path once it
+// verifies
+func (f *Fetcher) fetchSegments(ctx context.Context, url string, size int64, expectedChecksum, path string, progressWriter io.Writer) error {
+	partPath := path + ".part"
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer os.Remove(partPath)
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if progressWriter != nil {
+		progressWriter = &lockedWriter{w: progressWriter}
+	}
+	// Each connection gets its share of the rate limit
+	segmenter := *f
+	if f.rate > 0 {
+		segmenter.rate = max(f.rate/int64(f.concurrency), 1)
+	}
+
+	segment := (size + int64(f.concurrency) - 1) / int64(f.concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for start := int64(0); start < size; start += segment {
+		end := min(start+segment, size) - 1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := segmenter.fetchRange(ctx, url, start, end, io.NewOffsetWriter(file, start), progressWriter); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("bytes %d-%d: %w", start, end, err)
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write download file: %w", err)
+	}
+
+	if err := f.verifyFile(partPath, expectedChecksum); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return fmt.Errorf("failed to save download: %w", err)
+	}
+	return nil
+}
+
+// verifyFile verifies the file at path against expected, or its payload against the
+// payload checksum, reading it as a stream rather than into memory
+func (f *Fetcher) verifyFile(path, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open download file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read download file: %w", err)
+	}
+	err = verifySum([sha256.Size]byte(hash.Sum(nil)), expected)
+	if err == nil || f.payload == "" {
+		return err
+	}
+	if _, serr := file.Seek(0, io.SeekStart); serr != nil {
+		return fmt.Errorf("failed to read download file: %w", serr)
+	}
+	if sum, perr := payloadSum(file); perr == nil && sum == f.payload {
+		return nil
+	}
+	return f.payloadMismatch(err)
+}
+
+// fetchRange downloads bytes start to end of url, inclusive, to w
+func (f *Fetcher) fetchRange(ctx context.Context, url string, start, end int64, w io.Writer, progressWriter io.Writer) error {
+	req, err := f.newRequest(ctx, "GET", url)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("HTTP %d: %s, want a range", resp.StatusCode, resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-%d/", start, end)) {
+		return fmt.Errorf("server sent range %q", resp.Header.Get("Content-Range"))
+	}
+
+	reader := f.throttle(ctx, resp.Body)
+	if progressWriter != nil {
+		reader = io.TeeReader(reader, progressWriter)
+	}
+	n, err := io.Copy(w, io.LimitReader(reader, end-start+1))
+	if err != nil {
+		return err
+	}
+	if n != end-start+1 {
+		return fmt.Errorf("got %d bytes, want %d", n, end-start+1)
+	}
+	return nil
+}
+
+// lockedWriter serializes writes to w, for progress shared by several connections
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
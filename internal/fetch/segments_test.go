@@ -0,0 +1,132 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer serves content with range support, recording the Range of each GET
+func rangeServer(t *testing.T, content []byte, ranges *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			mu.Lock()
+			*ranges = append(*ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		http.ServeContent(w, r, "archive", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseConcurrency(t *testing.T) {
+	if n, err := ParseConcurrency(" 4 "); err != nil || n != 4 {
+		t.Errorf("ParseConcurrency(4) = %d, %v", n, err)
+	}
+	for _, s := range []string{"", "0", "-2", "many"} {
+		if _, err := ParseConcurrency(s); err == nil {
+			t.Errorf("ParseConcurrency(%q) should fail", s)
+		}
+	}
+}
+
+func TestFetchSegmented(t *testing.T) {
+	defer func(old int64) { segmentThreshold = old }(segmentThreshold)
+	segmentThreshold = 1000
+
+	content := []byte(strings.Repeat("0123456789abcdef", 1000))
+	var ranges []string
+	server := rangeServer(t, content, &ranges)
+
+	f := New()
+	f.SetConcurrency(4)
+	downloadDir := t.TempDir()
+	f.SetDownloadDir(downloadDir)
+	var progress bytes.Buffer
+	data, err := f.FetchFromMirrors(context.Background(), []string{server.URL}, checksumOf(content), &progress)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("FetchFromMirrors() = %d bytes, %v, want the file", len(data), err)
+	}
+	if len(ranges) != 4 || progress.Len() != len(content) {
+		t.Errorf("ranges = %v, progress = %d bytes, want 4 segments covering the file", ranges, progress.Len())
+	}
+	if entries, _ := os.ReadDir(downloadDir); len(entries) != 0 {
+		t.Errorf("download directory holds %d files, want none left", len(entries))
+	}
+
+	// FetchToFile assembles the segments beside the file
+	ranges = nil
+	path := filepath.Join(t.TempDir(), "archive")
+	if err := f.FetchToFile(context.Background(), []string{server.URL}, checksumOf(content), path, nil); err != nil {
+		t.Fatalf("FetchToFile() failed: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, content) || len(ranges) != 4 {
+		t.Errorf("FetchToFile() wrote %d bytes in %v, want the file in 4 segments", len(got), ranges)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial download left behind: %v", err)
+	}
+
+	// Without a download directory, downloads returned in memory use one connection
+	ranges = nil
+	f.SetDownloadDir("")
+	if _, err := f.FetchFromMirrors(context.Background(), []string{server.URL}, checksumOf(content), nil); err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "" {
+		t.Errorf("ranges = %v, want one plain request", ranges)
+	}
+	f.SetDownloadDir(downloadDir)
+
+	// Files below the threshold use one connection
+	ranges = nil
+	segmentThreshold = int64(len(content)) + 1
+	if _, err := f.FetchFromMirrors(context.Background(), []string{server.URL}, checksumOf(content), nil); err != nil {
+		t.Fatalf("FetchFromMirrors() failed: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "" {
+		t.Errorf("ranges = %v, want one plain request", ranges)
+	}
+}
+
+func TestFetchSegmentedFallsBack(t *testing.T) {
+	defer func(old int64) { segmentThreshold = old }(segmentThreshold)
+	segmentThreshold = 1000
+
+	// A server whose ranges are wrong: the merged file fails its checksum
+	content := []byte(strings.Repeat("0123456789abcdef", 1000))
+	var requests []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "archive", time.Time{}, bytes.NewReader(bytes.ToUpper(content)))
+			return
+		}
+		http.ServeContent(w, r, "archive", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	f := New()
+	f.SetConcurrency(2)
+	f.SetDownloadDir(t.TempDir())
+	data, err := f.FetchFromMirrors(context.Background(), []string{server.URL}, checksumOf(content), nil)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("FetchFromMirrors() = %d bytes, %v, want the file over one connection", len(data), err)
+	}
+	if last := requests[len(requests)-1]; last != "GET " {
+		t.Errorf("last request = %q, want a plain GET; requests = %v", last, requests)
+	}
+}
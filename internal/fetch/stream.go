@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// FetchStreaming downloads a file available at urls and verifies its checksum, like
+// FetchFromMirrors, while handing its bytes to consume as they arrive, so work such as
+// extraction overlaps the download. consume's result counts only once the whole file has
+// arrived and verified, which streamed reports. Otherwise, if the download fails, doesn't
+// verify or consume returns an error, data is still the verified file, fetched again
+// from the mirrors when needed, for the caller to process after all.
+func (f *Fetcher) FetchStreaming(ctx context.Context, urls []string, expectedChecksum string, progressWriter io.Writer, consume func(io.Reader) error) (data []byte, streamed bool, err error) {
+	// Segments arrive out of order, so they can't be streamed
+	if data := f.trySegmentedData(ctx, urls, expectedChecksum, progressWriter); data != nil {
+		return data, false, nil
+	}
+
+	u := urls[0]
+	if len(urls) > 1 {
+		u = f.RankMirrors(ctx, urls)[0]
+	}
+
+	pr, pw := io.Pipe()
+	consumed := make(chan error, 1)
+	go func() {
+		err := consume(pr)
+		// Whatever consume left unread still has to arrive to be verified
+		io.Copy(io.Discard, pr)
+		consumed <- err
+	}()
+
+	var buf bytes.Buffer
+	encoding, fetchErr := f.copyFrom(ctx, u, 0, io.MultiWriter(&buf, pw), progressWriter)
+	pw.CloseWithError(fetchErr)
+	consumeErr := <-consumed
+
+	if fetchErr == nil {
+		verified, decoded, err := f.verifyDecoded(buf.Bytes(), encoding, expectedChecksum)
+		if err == nil {
+			// Decoded bytes aren't the ones consume saw
+			return verified, consumeErr == nil && !decoded, nil
+		}
+		fetchErr = err
+	}
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+
+	f.logf("streaming %s failed: %v; downloading it again\n", u, fetchErr)
+	data, err = f.FetchFromMirrors(ctx, urls, expectedChecksum, progressWriter)
+	return data, false, err
+}
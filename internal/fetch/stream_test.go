@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchStreaming(t *testing.T) {
+	content := bytes.Repeat([]byte("archive bytes "), 10000)
+	var requests atomic.Int32
+	var corruptFirst atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 && corruptFirst.Load() {
+			w.Write(bytes.ToUpper(content))
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+	checksum := checksumOf(content)
+
+	readAll := func(seen *[]byte) func(io.Reader) error {
+		return func(r io.Reader) error {
+			var err error
+			*seen, err = io.ReadAll(r)
+			return err
+		}
+	}
+
+	t.Run("streams a verified download", func(t *testing.T) {
+		requests.Store(0)
+		var seen []byte
+		data, streamed, err := New().FetchStreaming(context.Background(), []string{server.URL}, checksum, nil, readAll(&seen))
+		if err != nil || !streamed {
+			t.Fatalf("FetchStreaming() = %v, %v, want a streamed download", streamed, err)
+		}
+		if !bytes.Equal(data, content) || !bytes.Equal(seen, content) {
+			t.Error("FetchStreaming() should return and stream the whole file")
+		}
+		if requests.Load() != 1 {
+			t.Errorf("requests = %d, want 1", requests.Load())
+		}
+	})
+
+	t.Run("a consumer that stops early still gets a verified download", func(t *testing.T) {
+		requests.Store(0)
+		stop := errors.New("not an archive")
+		data, streamed, err := New().FetchStreaming(context.Background(), []string{server.URL}, checksum, nil, func(r io.Reader) error {
+			r.Read(make([]byte, 10))
+			return stop
+		})
+		if err != nil || streamed || !bytes.Equal(data, content) {
+			t.Errorf("FetchStreaming() = %v, %v, want the unstreamed file", streamed, err)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("requests = %d, want the file downloaded once", requests.Load())
+		}
+	})
+
+	t.Run("downloads again when the stream doesn't verify", func(t *testing.T) {
+		requests.Store(0)
+		corruptFirst.Store(true)
+		defer corruptFirst.Store(false)
+		var seen []byte
+		data, streamed, err := New().FetchStreaming(context.Background(), []string{server.URL}, checksum, nil, readAll(&seen))
+		if err != nil || streamed || !bytes.Equal(data, content) {
+			t.Errorf("FetchStreaming() = %v, %v, want the file fetched again without streaming", streamed, err)
+		}
+		if requests.Load() != 2 {
+			t.Errorf("requests = %d, want 2", requests.Load())
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetRateLimit caps each download at bytesPerSec. Zero removes the limit.
+func (f *Fetcher) SetRateLimit(bytesPerSec int64) {
+	f.rate = bytesPerSec
+}
+
+// ParseRate parses a download rate such as 500k or 2M into bytes per second.
+// The suffixes k, M and G are powers of 1024; a bare number is bytes.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			multiplier = 1 << 10
+		case 'm', 'M':
+			multiplier = 1 << 20
+		case 'g', 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: want a positive number of bytes per second, like 500k or 2M", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// throttle wraps r so it is read no faster than the fetcher's rate limit
+func (f *Fetcher) throttle(ctx context.Context, r io.Reader) io.Reader {
+	if f.rate <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, rate: f.rate, start: time.Now()}
+}
+
+// throttledReader sleeps between reads to keep its average rate at or below rate
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth rather than bursting a whole buffer at once
+	if chunk := max(t.rate/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	due := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+	if wait := due - time.Since(t.start); wait > 0 {
+		select {
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]int64{
+		"100":  100,
+		"500k": 500 << 10,
+		"2M":   2 << 20,
+		"1.5m": 3 << 19,
+		"1G":   1 << 30,
+	}
+	for in, want := range tests {
+		if got, err := ParseRate(in); err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "fast", "-1k", "0"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) should fail", in)
+		}
+	}
+}
+
+func TestFetchRateLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 4000)
+	server := mirrorServer(t, data, 0, false)
+
+	f := New()
+	f.SetRateLimit(20000)
+	start := time.Now()
+	if _, err := f.Fetch(context.Background(), server.URL+"/a", checksumOf(data)); err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+
+	// 4000 bytes at 20000 bytes/s take at least 200ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("rate-limited Fetch() took %v, want about 200ms", elapsed)
+	}
+}
@@ -0,0 +1,63 @@
+package fetch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSConfig returns the TLS settings for servers of a private PKI: the system's trusted
+// certificates plus the PEM certificates in caFile, and the client certificate in
+// certFile, with its key in keyFile, for servers that require one. Each file is optional,
+// but a certificate needs its key. It returns nil if none are given.
+func TLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case certFile != "":
+		return nil, fmt.Errorf("client certificate %s has no key file", certFile)
+	case keyFile != "":
+		return nil, fmt.Errorf("client key %s has no certificate file", keyFile)
+	}
+	return config, nil
+}
+
+// TLSTransport returns a copy of base, or of http.DefaultTransport if base is nil, that
+// makes HTTPS connections with config
+func TLSTransport(base http.RoundTripper, config *tls.Config) http.RoundTripper {
+	return configureTransport(base, func(t *http.Transport) { t.TLSClientConfig = config })
+}
+
+// SetTLSConfig makes HTTPS connections with config, such as one from TLSConfig, instead
+// of trusting only the system's certificates
+func (f *Fetcher) SetTLSConfig(config *tls.Config) {
+	client := *f.client
+	client.Transport = TLSTransport(f.client.Transport, config)
+	f.client = &client
+}
@@ -0,0 +1,120 @@
+package fetch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir, returning
+// their paths and the certificate
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nori test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile, cert
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeClientCert(t, dir)
+	notPEM := filepath.Join(dir, "ca.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+
+	if config, err := TLSConfig("", "", ""); config != nil || err != nil {
+		t.Errorf("TLSConfig() without files = %v, %v, want nil", config, err)
+	}
+	for _, files := range [][3]string{
+		{filepath.Join(dir, "missing.pem"), "", ""},
+		{notPEM, "", ""},
+		{"", certFile, ""},
+		{"", "", keyFile},
+		{"", keyFile, certFile},
+	} {
+		if _, err := TLSConfig(files[0], files[1], files[2]); err == nil {
+			t.Errorf("TLSConfig(%q, %q, %q) should fail", files[0], files[1], files[2])
+		}
+	}
+}
+
+func TestFetchWithClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	data := []byte("payload")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	clients := x509.NewCertPool()
+	clients.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+
+	// The server's private CA alone isn't enough; it wants the client certificate too
+	trusting, err := TLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("TLSConfig() failed: %v", err)
+	}
+	f := New()
+	f.SetTLSConfig(trusting)
+	if _, err := f.Fetch(context.Background(), server.URL+"/tool.tar.gz", checksumOf(data)); err == nil {
+		t.Error("Fetch() without a client certificate should fail")
+	}
+
+	config, err := TLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("TLSConfig() failed: %v", err)
+	}
+	f = New()
+	f.SetTLSConfig(config)
+	got, err := f.Fetch(context.Background(), server.URL+"/tool.tar.gz", checksumOf(data))
+	if err != nil || string(got) != string(data) {
+		t.Errorf("Fetch() with a client certificate = %q, %v, want %q", got, err, data)
+	}
+}
@@ -0,0 +1,77 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.yaml")
+
+	if err := WriteFileAtomic(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() failed: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("second"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic() overwrite failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Errorf("file = %q, %v, want %q", data, err, "second")
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// No temp files are left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the target", len(entries))
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "x"), nil, 0644); err == nil {
+		t.Error("WriteFileAtomic() should fail when the directory does not exist")
+	}
+}
+
+func TestWriteFileAtomicConcurrentReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	a := make([]byte, 64*1024)
+	b := make([]byte, 128*1024)
+	for i := range b {
+		b[i] = 'b'
+	}
+	for i := range a {
+		a[i] = 'a'
+	}
+	if err := WriteFileAtomic(path, a, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			data := a
+			if i%2 == 0 {
+				data = b
+			}
+			WriteFileAtomic(path, data, 0644)
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // Windows may refuse to open a file mid-rename
+		}
+		if len(data) != len(a) && len(data) != len(b) {
+			t.Fatalf("reader saw a partial file of %d bytes", len(data))
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,42 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockTimeout = 10 * time.Second
+	lockPoll    = 25 * time.Millisecond
+	// A lock older than this was left behind by a process that died while holding it
+	staleLock = time.Minute
+)
+
+// Lock takes an exclusive lock on path by creating path+".lock", waiting for other
+// holders to release it. It returns a function that releases the lock.
+func Lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+		time.Sleep(lockPoll)
+	}
+}
@@ -0,0 +1,82 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+
+	// A second holder waits until the first releases the lock
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := Lock(path)
+		if err != nil {
+			t.Errorf("second Lock() failed: %v", err)
+		} else {
+			unlock2()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Lock() succeeded while the lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	<-acquired
+
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("lock file should be removed after unlocking")
+	}
+}
+
+func TestLockSerializesWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	os.WriteFile(path, []byte{0}, 0644)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(path)
+			if err != nil {
+				t.Errorf("Lock() failed: %v", err)
+				return
+			}
+			defer unlock()
+
+			data, _ := os.ReadFile(path)
+			WriteFileAtomic(path, []byte{data[0] + 1}, 0644)
+		}()
+	}
+	wg.Wait()
+
+	if data, _ := os.ReadFile(path); data[0] != 8 {
+		t.Errorf("counter = %d, want 8", data[0])
+	}
+}
+
+func TestLockBreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	os.WriteFile(path+".lock", []byte("12345\n"), 0644)
+	old := time.Now().Add(-2 * staleLock)
+	os.Chtimes(path+".lock", old, old)
+
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() should break a stale lock: %v", err)
+	}
+	unlock()
+}
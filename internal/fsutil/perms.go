@@ -0,0 +1,26 @@
+package fsutil
+
+import (
+	"os"
+	"runtime"
+)
+
+// WritableByOthers reports whether path exists and can be written by its group or by
+// other users. It is always false on Windows, where access is governed by ACLs.
+func WritableByOthers(path string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm()&0022 != 0
+}
+
+// ReadableByOthers reports whether path exists and can be read by its group or by other
+// users. It is always false on Windows, where access is governed by ACLs.
+func ReadableByOthers(path string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm()&0044 != 0
+}
@@ -0,0 +1,64 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWritableByOthers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		mode os.FileMode
+		want bool
+	}{
+		{0700, false},
+		{0755, false},
+		{0775, true},
+		{0757, true},
+	}
+	for _, tt := range tests {
+		os.Chmod(dir, tt.mode)
+		if got := WritableByOthers(dir); got != tt.want {
+			t.Errorf("WritableByOthers() with mode %v = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+	os.Chmod(dir, 0700)
+
+	if WritableByOthers(filepath.Join(dir, "missing")) {
+		t.Error("WritableByOthers() should be false for a missing path")
+	}
+}
+
+func TestReadableByOthers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are ACLs on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, nil, 0600)
+	tests := []struct {
+		mode os.FileMode
+		want bool
+	}{
+		{0600, false},
+		{0622, false},
+		{0640, true},
+		{0604, true},
+	}
+	for _, tt := range tests {
+		os.Chmod(path, tt.mode)
+		if got := ReadableByOthers(path); got != tt.want {
+			t.Errorf("ReadableByOthers() with mode %v = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+
+	if ReadableByOthers(path + ".missing") {
+		t.Error("ReadableByOthers() should be false for a missing path")
+	}
+}
@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// DefaultBrewAPI is the Homebrew formula JSON API
+const DefaultBrewAPI = "https://formulae.brew.sh/api"
+
+// relocatable is the cellar value of bottles that run from any prefix
+const relocatable = ":any_skip_relocation"
+
+// macOSReleases lists macOS bottle tags newest first; the newest available bottle is used
+var macOSReleases = []string{"tahoe", "sequoia", "sonoma", "ventura", "monterey", "big_sur", "catalina"}
+
+// brewFormula is the subset of the Homebrew formula JSON used to seed a manifest
+type brewFormula struct {
+	Name     string `json:"name"`
+	Desc     string `json:"desc"`
+	Homepage string `json:"homepage"`
+	License  string `json:"license"`
+	Versions struct {
+		Stable string `json:"stable"`
+	} `json:"versions"`
+	Revision     int      `json:"revision"`
+	Dependencies []string `json:"dependencies"`
+	Bottle       struct {
+		Stable struct {
+			Files map[string]brewBottle `json:"files"`
+		} `json:"stable"`
+	} `json:"bottle"`
+}
+
+// brewBottle is one prebuilt bottle of a formula
+type brewBottle struct {
+	Cellar string `json:"cellar"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Brew fetches formulae from the Homebrew API
+type Brew struct {
+	BaseURL string
+	client  *http.Client
+}
+
+// NewBrew creates a Homebrew API client for baseURL
+func NewBrew(baseURL string) *Brew {
+	return &Brew{
+		BaseURL: baseURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Formula fetches a formula and converts it into a draft manifest
+func (b *Brew) Formula(ctx context.Context, name string) (*Draft, error) {
+	url := strings.TrimSuffix(b.BaseURL, "/") + "/formula/" + name + ".json"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch formula: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("formula %q not found", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch formula: HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch formula: %w", err)
+	}
+	return FromBrew(data)
+}
+
+// FromBrew converts Homebrew formula JSON into a draft manifest with one version,
+// the stable one, and an asset per platform that has a bottle
+func FromBrew(data []byte) (*Draft, error) {
+	var f brewFormula
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse formula: %w", err)
+	}
+	if f.Name == "" || f.Versions.Stable == "" {
+		return nil, fmt.Errorf("formula has no name or stable version")
+	}
+
+	d := &Draft{
+		Source: "Homebrew formula " + f.Name,
+		Manifest: &manifest.Manifest{
+			Schema:      1,
+			Name:        f.Name,
+			Description: f.Desc,
+			Homepage:    f.Homepage,
+			License:     f.License,
+			Versions:    map[string]manifest.Version{},
+		},
+	}
+
+	// Bottles unpack to <name>/<version>[_<revision>]/; the installer strips the
+	// single top-level directory, leaving the versioned one
+	bottleDir := f.Versions.Stable
+	if f.Revision > 0 {
+		bottleDir = fmt.Sprintf("%s_%d", f.Versions.Stable, f.Revision)
+	}
+	d.Manifest.Bins = []string{bottleDir + "/bin/" + f.Name}
+	d.reviewf("bins are guessed as %s; list every executable the formula installs", d.Manifest.Bins[0])
+
+	if _, err := manifest.ParseVersion(f.Versions.Stable); err != nil {
+		d.reviewf("version %q is not semver; rename it", f.Versions.Stable)
+	}
+
+	tags := bottleTags(f.Bottle.Stable.Files)
+	plats := make([]string, 0, len(tags))
+	for plat := range tags {
+		plats = append(plats, plat)
+	}
+	sort.Strings(plats)
+
+	platforms := make(map[string]manifest.Asset)
+	ghcr := false
+	for _, plat := range plats {
+		bottle := f.Bottle.Stable.Files[tags[plat]]
+		platforms[plat] = manifest.Asset{
+			Type:     "tar",
+			URL:      bottle.URL,
+			Checksum: "sha256:" + bottle.SHA256,
+		}
+		if bottle.Cellar != relocatable {
+			d.reviewf("the %s bottle (%s) expects to live in %s and may not run from nori's install directory", plat, tags[plat], bottle.Cellar)
+		}
+		ghcr = ghcr || strings.Contains(bottle.URL, "ghcr.io")
+	}
+
+	if len(platforms) == 0 {
+		d.reviewf("the formula has no bottles; add assets by hand")
+	} else {
+		d.Manifest.Versions[f.Versions.Stable] = manifest.Version{Platforms: platforms}
+	}
+	if ghcr {
+		d.reviewf("bottles on ghcr.io need an `Authorization: Bearer QQ==` header; mirror them to a host nori can download from")
+	}
+
+	if len(f.Dependencies) > 0 {
+		d.reviewf("depends on %s, which nori does not install", strings.Join(f.Dependencies, ", "))
+	}
+
+	return d, nil
+}
+
+// bottleTags picks the bottle tag to use for each nori platform
+func bottleTags(files map[string]brewBottle) map[string]string {
+	tags := make(map[string]string)
+	if _, ok := files["all"]; ok {
+		for _, plat := range []string{"darwin-amd64", "darwin-arm64", "linux-amd64", "linux-arm64"} {
+			tags[plat] = "all"
+		}
+	}
+	if _, ok := files["x86_64_linux"]; ok {
+		tags["linux-amd64"] = "x86_64_linux"
+	}
+	if _, ok := files["arm64_linux"]; ok {
+		tags["linux-arm64"] = "arm64_linux"
+	}
+
+	// Prefer the newest macOS release, the one most users run
+	for i := len(macOSReleases) - 1; i >= 0; i-- {
+		release := macOSReleases[i]
+		if _, ok := files[release]; ok {
+			tags["darwin-amd64"] = release
+		}
+		if _, ok := files["arm64_"+release]; ok {
+			tags["darwin-arm64"] = "arm64_" + release
+		}
+	}
+	return tags
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const wgetFormula = `{
+  "name": "wget",
+  "desc": "Internet file retriever",
+  "homepage": "https://www.gnu.org/software/wget/",
+  "license": "GPL-3.0-or-later",
+  "versions": {"stable": "1.24.5", "head": "HEAD", "bottle": true},
+  "revision": 1,
+  "dependencies": ["libidn2", "openssl@3"],
+  "bottle": {"stable": {"files": {
+    "arm64_sequoia": {"cellar": "/opt/homebrew/Cellar", "url": "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:aaaa", "sha256": "1111111111111111111111111111111111111111111111111111111111111111"},
+    "arm64_sonoma": {"cellar": "/opt/homebrew/Cellar", "url": "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:bbbb", "sha256": "2222222222222222222222222222222222222222222222222222222222222222"},
+    "sonoma": {"cellar": ":any_skip_relocation", "url": "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:cccc", "sha256": "3333333333333333333333333333333333333333333333333333333333333333"},
+    "x86_64_linux": {"cellar": ":any_skip_relocation", "url": "https://ghcr.io/v2/homebrew/core/wget/blobs/sha256:dddd", "sha256": "4444444444444444444444444444444444444444444444444444444444444444"}
+  }}}
+}`
+
+func TestFromBrew(t *testing.T) {
+	d, err := FromBrew([]byte(wgetFormula))
+	if err != nil {
+		t.Fatalf("FromBrew() failed: %v", err)
+	}
+
+	m := d.Manifest
+	if m.Name != "wget" || m.License != "GPL-3.0-or-later" || m.Homepage == "" {
+		t.Errorf("FromBrew() metadata = %+v", m)
+	}
+	if len(m.Bins) != 1 || m.Bins[0] != "1.24.5_1/bin/wget" {
+		t.Errorf("Bins = %v, want [1.24.5_1/bin/wget]", m.Bins)
+	}
+
+	platforms := m.Versions["1.24.5"].Platforms
+	if len(platforms) != 3 {
+		t.Fatalf("platforms = %v, want darwin-arm64, darwin-amd64 and linux-amd64", platforms)
+	}
+	if got := platforms["darwin-arm64"].Checksum; got != "sha256:1111111111111111111111111111111111111111111111111111111111111111" {
+		t.Errorf("darwin-arm64 checksum = %q, want the newest macOS bottle", got)
+	}
+	if platforms["linux-amd64"].Type != "tar" {
+		t.Errorf("linux-amd64 type = %q, want tar", platforms["linux-amd64"].Type)
+	}
+
+	review := strings.Join(d.Review, "\n")
+	for _, want := range []string{"bins are guessed", "darwin-arm64 bottle", "ghcr.io", "libidn2, openssl@3"} {
+		if !strings.Contains(review, want) {
+			t.Errorf("Review = %q, want an item mentioning %q", review, want)
+		}
+	}
+	if strings.Contains(review, "linux-amd64 bottle") {
+		t.Errorf("Review = %q, relocatable bottles need no review", review)
+	}
+}
+
+func TestFromBrewInvalid(t *testing.T) {
+	if _, err := FromBrew([]byte("{")); err == nil {
+		t.Error("FromBrew() should fail for malformed JSON")
+	}
+	if _, err := FromBrew([]byte(`{"name": "x"}`)); err == nil {
+		t.Error("FromBrew() should fail without a stable version")
+	}
+}
+
+func TestBrewFormula(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/formula/wget.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(wgetFormula))
+	}))
+	defer server.Close()
+
+	b := NewBrew(server.URL)
+	d, err := b.Formula(context.Background(), "wget")
+	if err != nil {
+		t.Fatalf("Formula() failed: %v", err)
+	}
+	if d.Source != "Homebrew formula wget" {
+		t.Errorf("Source = %q", d.Source)
+	}
+
+	if _, err := b.Formula(context.Background(), "nope"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Formula(nope) error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Package importer converts package metadata from other package managers into
+// draft nori manifests for registry maintainers to review and publish.
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Draft is a generated manifest along with the things a maintainer must check by hand
+type Draft struct {
+	Manifest *manifest.Manifest
+	Source   string   // where the metadata came from, e.g. "Homebrew formula wget"
+	Review   []string // items needing manual review
+}
+
+// reviewf adds an item needing manual review
+func (d *Draft) reviewf(format string, args ...interface{}) {
+	d.Review = append(d.Review, fmt.Sprintf(format, args...))
+}
+
+// YAML renders the draft as a manifest, with the review items as a leading comment
+func (d *Draft) YAML() ([]byte, error) {
+	if err := manifest.Validate(d.Manifest); err != nil {
+		d.reviewf("the manifest does not validate yet: %v", err)
+	}
+
+	body, err := yaml.Marshal(d.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Draft generated from %s.\n", d.Source)
+	if len(d.Review) > 0 {
+		out.WriteString("# REVIEW before publishing:\n")
+		for _, item := range d.Review {
+			fmt.Fprintf(&out, "#   - %s\n", item)
+		}
+	}
+	out.Write(body)
+	return []byte(out.String()), nil
+}
@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+func TestDraftYAML(t *testing.T) {
+	d := &Draft{
+		Source: "a test",
+		Manifest: &manifest.Manifest{
+			Schema: 1,
+			Name:   "tool",
+			Bins:   []string{"bin/tool"},
+			Versions: map[string]manifest.Version{
+				"1.0.0": {Platforms: map[string]manifest.Asset{
+					"linux-amd64": {Type: "tar", URL: "https://example.com/tool.tar.gz", Checksum: "sha256:" + strings.Repeat("a", 64)},
+				}},
+			},
+		},
+	}
+	d.reviewf("check %s", "bins")
+
+	data, err := d.YAML()
+	if err != nil {
+		t.Fatalf("YAML() failed: %v", err)
+	}
+	out := string(data)
+	if !strings.HasPrefix(out, "# Draft generated from a test.\n# REVIEW before publishing:\n#   - check bins\n") {
+		t.Errorf("YAML() header = %q", out)
+	}
+	if strings.Contains(out, "does not validate") {
+		t.Errorf("YAML() = %q, a valid manifest needs no validation note", out)
+	}
+
+	// The rendered manifest loads back and validates
+	m, err := manifest.LoadFromBytes(data)
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := manifest.Validate(m); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+
+	d.Manifest.Versions = map[string]manifest.Version{}
+	data, _ = d.YAML()
+	if !strings.Contains(string(data), "does not validate yet") {
+		t.Errorf("YAML() = %q, want a note that the manifest is incomplete", data)
+	}
+}
@@ -0,0 +1,273 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// scoopArchitectures maps Scoop architectures to nori platforms
+var scoopArchitectures = map[string]string{
+	"64bit": "windows-amd64",
+	"arm64": "windows-arm64",
+}
+
+// sha256Hex matches a bare sha256 hash, the Scoop default
+var sha256Hex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// scoopManifest is the subset of a Scoop app manifest used to seed a manifest.
+// Several fields may be a string or a list, so they are decoded later.
+type scoopManifest struct {
+	Version      string                       `json:"version"`
+	Description  string                       `json:"description"`
+	Homepage     string                       `json:"homepage"`
+	License      json.RawMessage              `json:"license"`
+	URL          json.RawMessage              `json:"url"`
+	Hash         json.RawMessage              `json:"hash"`
+	ExtractDir   json.RawMessage              `json:"extract_dir"`
+	Bin          json.RawMessage              `json:"bin"`
+	Architecture map[string]scoopArchitecture `json:"architecture"`
+	Depends      json.RawMessage              `json:"depends"`
+	Installer    json.RawMessage              `json:"installer"`
+	PreInstall   json.RawMessage              `json:"pre_install"`
+	PostInstall  json.RawMessage              `json:"post_install"`
+	EnvAddPath   json.RawMessage              `json:"env_add_path"`
+}
+
+// scoopArchitecture overrides fields for one architecture
+type scoopArchitecture struct {
+	URL        json.RawMessage `json:"url"`
+	Hash       json.RawMessage `json:"hash"`
+	ExtractDir json.RawMessage `json:"extract_dir"`
+	Bin        json.RawMessage `json:"bin"`
+}
+
+// LoadScoop reads a Scoop app manifest from a file or an http(s) URL and converts it.
+// The package is named after the manifest file, as Scoop names apps.
+func LoadScoop(ctx context.Context, src string) (*Draft, error) {
+	var data []byte
+	if strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "http://") {
+		req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch Scoop manifest: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch Scoop manifest: HTTP %d: %s", resp.StatusCode, resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("failed to fetch Scoop manifest: %w", err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(src); err != nil {
+			return nil, fmt.Errorf("failed to read Scoop manifest: %w", err)
+		}
+	}
+
+	name := strings.TrimSuffix(path.Base(strings.ReplaceAll(src, "\\", "/")), ".json")
+	return FromScoop(name, data)
+}
+
+// FromScoop converts a Scoop app manifest into a draft manifest with one version and
+// an asset per Windows architecture. Scoop manifests already carry URLs, sha256
+// hashes and bin paths; install scripts and dependencies are flagged for review.
+func FromScoop(name string, data []byte) (*Draft, error) {
+	var s scoopManifest
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse Scoop manifest: %w", err)
+	}
+	if s.Version == "" {
+		return nil, fmt.Errorf("Scoop manifest has no version")
+	}
+
+	d := &Draft{
+		Source: "Scoop manifest " + name,
+		Manifest: &manifest.Manifest{
+			Schema:      1,
+			Name:        name,
+			Description: s.Description,
+			Homepage:    s.Homepage,
+			License:     scoopLicense(s.License),
+			Versions:    map[string]manifest.Version{},
+		},
+	}
+
+	if _, err := manifest.ParseVersion(s.Version); err != nil {
+		d.reviewf("version %q is not semver; rename it", s.Version)
+	}
+
+	// Architecture-specific fields override the top-level ones
+	archs := make([]string, 0, len(scoopArchitectures))
+	for arch := range scoopArchitectures {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+
+	platforms := make(map[string]manifest.Asset)
+	bins, aliases := scoopBins(s.Bin)
+	for _, arch := range archs {
+		plat := scoopArchitectures[arch]
+		urls, hashes, extractDir := stringList(s.URL), stringList(s.Hash), stringList(s.ExtractDir)
+		if override, ok := s.Architecture[arch]; ok {
+			if u := stringList(override.URL); len(u) > 0 {
+				urls, hashes = u, stringList(override.Hash)
+			}
+			if e := stringList(override.ExtractDir); len(e) > 0 {
+				extractDir = e
+			}
+			// nori lists bins once for all platforms, so the first override wins
+			if archBins, archAliases := scoopBins(override.Bin); len(archBins) > 0 && len(bins) == 0 {
+				bins, aliases = archBins, archAliases
+			}
+		} else if len(s.Architecture) > 0 {
+			continue
+		}
+		if len(urls) == 0 {
+			continue
+		}
+
+		platforms[plat] = d.scoopAsset(plat, urls, hashes)
+		if len(extractDir) > 0 && strings.ContainsAny(extractDir[0], `/\`) {
+			d.reviewf("the %s archive extracts from %q; check that bins are relative to it", plat, extractDir[0])
+		}
+	}
+	if len(platforms) > 0 {
+		d.Manifest.Versions[s.Version] = manifest.Version{Platforms: platforms}
+	} else if _, ok := s.Architecture["32bit"]; ok {
+		d.reviewf("only a 32-bit build is available, which nori does not support")
+	} else {
+		d.reviewf("no usable 64-bit assets; add them by hand")
+	}
+
+	d.Manifest.Bins = bins
+	if len(bins) == 0 {
+		d.Manifest.Bins = []string{name + ".exe"}
+		d.reviewf("the Scoop manifest lists no bin; %s is a guess", d.Manifest.Bins[0])
+	}
+	for _, alias := range aliases {
+		d.reviewf("Scoop exposes %s; nori names shims after the file, so add a wrapper if the name matters", alias)
+	}
+
+	scripts := []struct {
+		field string
+		raw   json.RawMessage
+	}{{"installer", s.Installer}, {"pre_install", s.PreInstall}, {"post_install", s.PostInstall}}
+	for _, script := range scripts {
+		if len(script.raw) > 0 {
+			d.reviewf("Scoop runs a %s script, which nori does not; check the app works without it", script.field)
+		}
+	}
+	if deps := stringList(s.Depends); len(deps) > 0 {
+		d.reviewf("depends on %s, which nori does not install", strings.Join(deps, ", "))
+	}
+	if dirs := stringList(s.EnvAddPath); len(dirs) > 0 {
+		d.reviewf("Scoop adds %s to PATH; list the executables there as bins", strings.Join(dirs, ", "))
+	}
+
+	return d, nil
+}
+
+// scoopAsset converts the download of one architecture into an asset, reporting
+// anything nori cannot install as it stands
+func (d *Draft) scoopAsset(plat string, urls, hashes []string) manifest.Asset {
+	if len(urls) > 1 {
+		d.reviewf("the %s build downloads %d files; nori installs a single archive", plat, len(urls))
+	}
+
+	// Scoop renames downloads with a #/name fragment
+	url, _, _ := strings.Cut(urls[0], "#")
+	asset := manifest.Asset{URL: url}
+
+	lower := strings.ToLower(url)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		asset.Type = "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".tar.xz"), strings.HasSuffix(lower, ".tar"):
+		asset.Type = "tar"
+	default:
+		d.reviewf("the %s download %s is not a zip or tar archive; repackage it", plat, path.Base(url))
+		asset.Type = "zip"
+	}
+
+	if len(hashes) == 0 {
+		d.reviewf("the %s download has no hash; compute its sha256", plat)
+		return asset
+	}
+	hash := strings.TrimPrefix(hashes[0], "sha256:")
+	if !sha256Hex.MatchString(hash) {
+		d.reviewf("the %s hash %q is not sha256; compute its sha256", plat, hashes[0])
+		return asset
+	}
+	asset.Checksum = "sha256:" + strings.ToLower(hash)
+	return asset
+}
+
+// scoopLicense returns a Scoop license, which is an SPDX string or an object with an identifier
+func scoopLicense(raw json.RawMessage) string {
+	var license string
+	if json.Unmarshal(raw, &license) == nil {
+		return license
+	}
+	var obj struct {
+		Identifier string `json:"identifier"`
+	}
+	json.Unmarshal(raw, &obj)
+	return obj.Identifier
+}
+
+// scoopBins returns the bin paths of a Scoop bin field, slash separated, and the
+// aliases of entries given as a [path, alias, args...] list
+func scoopBins(raw json.RawMessage) (bins, aliases []string) {
+	if one := stringList(raw); len(one) > 0 {
+		for _, bin := range one {
+			bins = append(bins, strings.ReplaceAll(bin, "\\", "/"))
+		}
+		return bins, nil
+	}
+
+	var entries []json.RawMessage
+	if json.Unmarshal(raw, &entries) != nil {
+		return nil, nil
+	}
+	for _, entry := range entries {
+		parts := stringList(entry)
+		if len(parts) == 0 {
+			continue
+		}
+		bins = append(bins, strings.ReplaceAll(parts[0], "\\", "/"))
+		if len(parts) > 1 {
+			aliases = append(aliases, fmt.Sprintf("%s as %q", parts[0], parts[1]))
+		}
+	}
+	return bins, aliases
+}
+
+// stringList decodes a JSON value that may be a string or a list of strings
+func stringList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return []string{one}
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		return many
+	}
+	return nil
+}
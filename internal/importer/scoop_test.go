@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ripgrepScoop = `{
+  "version": "14.1.0",
+  "description": "Recursively searches directories for a regex pattern",
+  "homepage": "https://github.com/BurntSushi/ripgrep",
+  "license": {"identifier": "MIT", "url": "https://github.com/BurntSushi/ripgrep/blob/master/LICENSE-MIT"},
+  "architecture": {
+    "64bit": {
+      "url": "https://github.com/BurntSushi/ripgrep/releases/download/14.1.0/ripgrep-14.1.0-x86_64-pc-windows-msvc.zip",
+      "hash": "D0F534024C42AFD6CB4D38907C25CD2B249B79BBE6CC1DBEE8E3E37C2B6E25A1",
+      "extract_dir": "ripgrep-14.1.0-x86_64-pc-windows-msvc"
+    },
+    "32bit": {
+      "url": "https://github.com/BurntSushi/ripgrep/releases/download/14.1.0/ripgrep-14.1.0-i686-pc-windows-msvc.zip",
+      "hash": "sha1:0000000000000000000000000000000000000000"
+    }
+  },
+  "bin": ["rg.exe", ["complete\\rg.bat", "rg-complete"]],
+  "post_install": "Write-Host done",
+  "depends": "vcredist2022"
+}`
+
+func TestFromScoop(t *testing.T) {
+	d, err := FromScoop("ripgrep", []byte(ripgrepScoop))
+	if err != nil {
+		t.Fatalf("FromScoop() failed: %v", err)
+	}
+
+	m := d.Manifest
+	if m.Name != "ripgrep" || m.License != "MIT" || m.Description == "" {
+		t.Errorf("FromScoop() metadata = %+v", m)
+	}
+	if got := strings.Join(m.Bins, " "); got != "rg.exe complete/rg.bat" {
+		t.Errorf("Bins = %q, want %q", got, "rg.exe complete/rg.bat")
+	}
+
+	platforms := m.Versions["14.1.0"].Platforms
+	if len(platforms) != 1 {
+		t.Fatalf("platforms = %v, want windows-amd64 only", platforms)
+	}
+	asset := platforms["windows-amd64"]
+	if asset.Type != "zip" || asset.Checksum != "sha256:d0f534024c42afd6cb4d38907c25cd2b249b79bbe6cc1dbee8e3e37c2b6e25a1" {
+		t.Errorf("windows-amd64 asset = %+v", asset)
+	}
+
+	review := strings.Join(d.Review, "\n")
+	for _, want := range []string{`"rg-complete"`, "post_install", "vcredist2022"} {
+		if !strings.Contains(review, want) {
+			t.Errorf("Review = %q, want an item mentioning %q", review, want)
+		}
+	}
+	if strings.Contains(review, "extracts from") {
+		t.Errorf("Review = %q, a single extract_dir is stripped by the installer", review)
+	}
+}
+
+func TestFromScoopTopLevelURL(t *testing.T) {
+	d, err := FromScoop("tool", []byte(`{
+  "version": "2024.1",
+  "url": "https://example.com/tool.exe#/tool.exe",
+  "hash": "sha1:0000000000000000000000000000000000000000",
+  "bin": "tool.exe"
+}`))
+	if err != nil {
+		t.Fatalf("FromScoop() failed: %v", err)
+	}
+
+	asset := d.Manifest.Versions["2024.1"].Platforms["windows-amd64"]
+	if asset.URL != "https://example.com/tool.exe" {
+		t.Errorf("URL = %q, want the rename fragment stripped", asset.URL)
+	}
+	if _, ok := d.Manifest.Versions["2024.1"].Platforms["windows-arm64"]; !ok {
+		t.Error("a top-level URL should apply to every architecture")
+	}
+
+	review := strings.Join(d.Review, "\n")
+	for _, want := range []string{"not semver", "not a zip or tar archive", "not sha256"} {
+		if !strings.Contains(review, want) {
+			t.Errorf("Review = %q, want an item mentioning %q", review, want)
+		}
+	}
+}
+
+func TestLoadScoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ripgrep.json")
+	if err := os.WriteFile(path, []byte(ripgrepScoop), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := LoadScoop(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadScoop() failed: %v", err)
+	}
+	if d.Manifest.Name != "ripgrep" {
+		t.Errorf("Name = %q, want it taken from the file name", d.Manifest.Name)
+	}
+
+	if _, err := LoadScoop(context.Background(), filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadScoop() should fail for a missing file")
+	}
+	if _, err := FromScoop("x", []byte(`{"description": "no version"}`)); err == nil {
+		t.Error("FromScoop() should fail without a version")
+	}
+}
@@ -7,68 +7,263 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/chirag-bruno/nori/internal/extract"
 	"github.com/chirag-bruno/nori/internal/manifest"
 	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"github.com/chirag-bruno/nori/internal/state"
 )
 
+// copyWorkers bounds how many files are copied at once when a move has to fall back to copying
+const copyWorkers = 8
+
 // Installer handles package installation
-type Installer struct{}
+type Installer struct {
+	paths platform.Paths
+}
 
-// New creates a new installer
-func New() *Installer {
-	return &Installer{}
+// New creates a new installer that installs beneath paths
+func New(paths platform.Paths) *Installer {
+	return &Installer{
+		paths: paths,
+	}
 }
 
 // Install installs a package from an extracted directory to the install location
 func (i *Installer) Install(ctx context.Context, m *manifest.Manifest, version string, p platform.Platform, extractDir string) (string, error) {
+	installPath, _, err := i.install(m, version, p, extractDir, false)
+	return installPath, err
+}
+
+// Replace installs version over the build of it that is already installed, such as an
+// earlier build of a channel. The new build is staged beside the installs and swapped in
+// by renames, so the version is never missing or half written. The old build is kept at
+// the returned path: the caller removes it once the new build works, or puts it back
+// with Restore.
+func (i *Installer) Replace(ctx context.Context, m *manifest.Manifest, version string, p platform.Platform, extractDir string) (string, string, error) {
+	return i.install(m, version, p, extractDir, true)
+}
+
+// install installs version from extractDir, replacing the installed build if replace is
+// set, and returns its install path and where the replaced build is kept
+func (i *Installer) install(m *manifest.Manifest, version string, p platform.Platform, extractDir string, replace bool) (string, string, error) {
 	// Validate version and platform
 	if err := manifest.ValidateVersion(m, version, p.String()); err != nil {
-		return "", err
+		return "", "", err
 	}
 	
 	// Detect archive root
 	rootDir, err := extract.DetectRoot(extractDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to detect archive root: %w", err)
+		return "", "", fmt.Errorf("failed to detect archive root: %w", err)
 	}
 	
 	// Validate that all bins exist
 	for _, bin := range m.Bins {
 		binPath := filepath.Join(rootDir, bin)
 		if _, err := os.Stat(binPath); os.IsNotExist(err) {
-			return "", fmt.Errorf("bin %q not found in extracted archive", bin)
+			return "", "", fmt.Errorf("bin %q not found in extracted archive", bin)
 		}
 	}
 	
-	// Create install directory
-	installPath := platform.InstallPath(m.Name, version, p.String())
-	if err := os.MkdirAll(installPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create install directory: %w", err)
+	installPath := i.paths.InstallPath(m.Name, version, p.String())
+	var previous string
+	if replace {
+		if previous, err = i.swapIn(rootDir, installPath, m.Bins); err != nil {
+			return "", "", err
+		}
+	} else {
+		// Create install directory
+		if err := os.MkdirAll(installPath, 0755); err != nil {
+			return "", "", fmt.Errorf("failed to create install directory: %w", err)
+		}
+		
+		// Move contents from rootDir to installPath
+		if err := moveContents(rootDir, installPath); err != nil {
+			// Cleanup on failure
+			os.RemoveAll(installPath)
+			return "", "", fmt.Errorf("failed to move contents: %w", err)
+		}
+		setBinModes(installPath, m.Bins)
 	}
 	
-	// Move contents from rootDir to installPath
-	if err := moveContents(rootDir, installPath); err != nil {
-		// Cleanup on failure
+	// Record the install; an install the index doesn't know about is rolled back
+	inst := state.Install{
+		Version:     version,
+		Platform:    p.String(),
+		Bins:        m.Bins,
+		Env:         m.Env,
+		InstalledAt: time.Now().UTC(),
+	}
+	if asset, err := m.GetAsset(version, p.String()); err == nil {
+		inst.Checksum = asset.Checksum
+	}
+	err = state.New(i.paths).Update(func(st *state.State) error {
+		st.AddInstall(m.Name, inst)
+		return nil
+	})
+	if err != nil {
 		os.RemoveAll(installPath)
+		if previous != "" {
+			os.Rename(previous, installPath)
+		}
+		return "", "", fmt.Errorf("failed to update state index: %w", err)
+	}
+	
+	return installPath, previous, nil
+}
+
+// swapIn stages the contents of rootDir and swaps them in for the build at installPath,
+// returning where the old build is kept
+func (i *Installer) swapIn(rootDir, installPath string, bins []string) (string, error) {
+	if err := os.MkdirAll(i.paths.TmpDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(i.paths.TmpDir(), "install-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := moveContents(rootDir, staging); err != nil {
+		os.RemoveAll(staging)
 		return "", fmt.Errorf("failed to move contents: %w", err)
 	}
+	setBinModes(staging, bins)
 	
-	// Set executable bits on bin files (POSIX only)
-	if runtime.GOOS != "windows" {
-		for _, bin := range m.Bins {
-			binPath := filepath.Join(installPath, bin)
-			if info, err := os.Stat(binPath); err == nil {
-				mode := info.Mode()
-				if mode&0111 == 0 {
-					os.Chmod(binPath, mode|0111)
-				}
+	// The staging directory's name is unique, so the old build can take it with a suffix
+	previous := staging + ".previous"
+	if err := os.Rename(installPath, previous); err != nil {
+		os.RemoveAll(staging)
+		return "", fmt.Errorf("failed to set the installed build aside: %w", err)
+	}
+	if err := os.Rename(staging, installPath); err != nil {
+		os.Rename(previous, installPath)
+		os.RemoveAll(staging)
+		return "", fmt.Errorf("failed to move the new build into place: %w", err)
+	}
+	return previous, nil
+}
+
+// Restore puts back the build of version that Replace kept at previous, removing the one
+// that replaced it, and records it in the state index again
+func (i *Installer) Restore(pkg, version string, p platform.Platform, previous string) error {
+	installPath := i.paths.InstallPath(pkg, version, p.String())
+	if err := os.RemoveAll(installPath); err != nil {
+		return fmt.Errorf("failed to remove the new build: %w", err)
+	}
+	if err := os.Rename(previous, installPath); err != nil {
+		return fmt.Errorf("failed to restore the previous build: %w", err)
+	}
+	
+	// The receipt of the old build says what the index recorded for it
+	inst := state.Install{Version: version, Platform: p.String(), InstalledAt: time.Now().UTC()}
+	if r, err := receipt.Load(installPath); err == nil {
+		inst.Checksum, inst.Bins, inst.Env, inst.InstalledAt = r.Checksum, r.Bins, r.Env, r.InstalledAt
+	}
+	return state.New(i.paths).Update(func(st *state.State) error {
+		st.AddInstall(pkg, inst)
+		return nil
+	})
+}
+
+// setBinModes sets the executable bits of the bins beneath dir (POSIX only)
+func setBinModes(dir string, bins []string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	for _, bin := range bins {
+		binPath := filepath.Join(dir, bin)
+		if info, err := os.Stat(binPath); err == nil {
+			mode := info.Mode()
+			if mode&0111 == 0 {
+				os.Chmod(binPath, mode|0111)
+			}
+		}
+	}
+}
+
+// Uninstall removes an installed version. It refuses with an *InUseError while any
+// process, including nori itself, is running a binary from the installation,
+// unless force is set.
+func (i *Installer) Uninstall(pkg, version string, p platform.Platform, force bool) error {
+	installPath := i.paths.InstallPath(pkg, version, p.String())
+	if _, err := os.Stat(installPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s@%s is not installed for %s", pkg, version, p.String())
+		}
+		return fmt.Errorf("failed to stat install directory: %w", err)
+	}
+	
+	if !force {
+		running, err := RunningFrom(installPath)
+		if err != nil {
+			return fmt.Errorf("failed to check for running processes: %w", err)
+		}
+		if len(running) > 0 {
+			return &InUseError{Path: installPath, Processes: running}
+		}
+	}
+	
+	if err := os.RemoveAll(installPath); err != nil {
+		return fmt.Errorf("failed to remove install directory: %w", err)
+	}
+	
+	// Drop the now-empty version and package directories so listings stay clean
+	os.Remove(filepath.Dir(installPath))
+	os.Remove(filepath.Dir(filepath.Dir(installPath)))
+	if strings.Contains(pkg, "/") {
+		os.Remove(filepath.Dir(i.paths.PackageDir(pkg)))
+	}
+	
+	err := state.New(i.paths).Update(func(st *state.State) error {
+		st.RemoveInstall(pkg, version, p.String())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update state index (run `nori state rebuild`): %w", err)
+	}
+	
+	return nil
+}
+
+// Repair restores the given files of the installation at installPath from a fresh
+// extraction of the archive it was installed from, keeping the recorded modes
+func Repair(extractDir, installPath string, files map[string]receipt.File) error {
+	rootDir, err := extract.DetectRoot(extractDir)
+	if err != nil {
+		return fmt.Errorf("failed to detect archive root: %w", err)
+	}
+	
+	for rel, want := range files {
+		src := filepath.Join(rootDir, filepath.FromSlash(rel))
+		dst := filepath.Join(installPath, filepath.FromSlash(rel))
+		
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove damaged %s: %w", rel, err)
+		}
+		
+		if want.Link != "" {
+			if err := os.Symlink(want.Link, dst); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", rel, err)
 			}
+			continue
+		}
+		
+		if err := copyRecursive(src, dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+		if err := os.Chmod(dst, want.Mode); err != nil {
+			return fmt.Errorf("failed to restore mode of %s: %w", rel, err)
 		}
 	}
 	
-	return installPath, nil
+	return nil
 }
 
 // moveContents moves all contents from src to dst
@@ -94,32 +289,93 @@ func moveContents(src, dst string) error {
 	return nil
 }
 
-// copyRecursive copies a file or directory recursively
+// copyRecursive copies a file or directory recursively. The directories are created
+// first and the files are then copied by a pool of workers, which pays off for
+// toolchains made of many small files.
 func copyRecursive(src, dst string) error {
-	info, err := os.Stat(src)
+	var files [][2]string
+	if err := planCopy(src, dst, &files); err != nil {
+		return err
+	}
+	
+	jobs := make(chan [2]string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for w := 0; w < min(len(files), copyWorkers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := copyFile(job[0], job[1]); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, job := range files {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+	
+	return firstErr
+}
+
+// planCopy creates the directories of the tree at src beneath dst and collects the
+// (source, destination) pairs of the files and symlinks to copy. Symlinks are not
+// followed, so a link to a parent directory doesn't make the copy recurse.
+func planCopy(src, dst string, files *[][2]string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	
+	if !info.IsDir() {
+		*files = append(*files, [2]string{src, dst})
+		return nil
+	}
+	
+	if err := os.MkdirAll(dst, info.Mode()); err != nil {
+		return err
+	}
+	
+	entries, err := os.ReadDir(src)
 	if err != nil {
 		return err
 	}
 	
-	if info.IsDir() {
-		if err := os.MkdirAll(dst, info.Mode()); err != nil {
+	for _, entry := range entries {
+		if err := planCopy(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), files); err != nil {
 			return err
 		}
-		
-		entries, err := os.ReadDir(src)
+	}
+	return nil
+}
+
+// copyFile copies a single file, keeping its mode. A symlink is recreated pointing at
+// the same target rather than copied as the file it points to.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
 		if err != nil {
 			return err
 		}
-		
-		for _, entry := range entries {
-			if err := copyRecursive(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
-				return err
-			}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
 		}
-		return nil
+		return os.Symlink(target, dst)
 	}
 	
-	// Copy file
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -130,9 +386,10 @@ func copyRecursive(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
 	
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
 }
-
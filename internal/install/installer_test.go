@@ -2,6 +2,7 @@ package install
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -9,9 +10,13 @@ import (
 
 	"github.com/chirag-bruno/nori/internal/manifest"
 	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"github.com/chirag-bruno/nori/internal/state"
 )
 
 func TestInstall(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	
 	// Create a temporary extract directory with test files
 	// Simulate an archive with a single top-level directory
 	extractDir := t.TempDir()
@@ -53,7 +58,7 @@ func TestInstall(t *testing.T) {
 		},
 	}
 	
-	installer := New()
+	installer := New(paths)
 	ctx := context.Background()
 	
 	installPath, err := installer.Install(ctx, m, "1.0.0", p, extractDir)
@@ -62,7 +67,7 @@ func TestInstall(t *testing.T) {
 	}
 	
 	// Verify install path
-	expectedPath := platform.InstallPath("testpkg", "1.0.0", p.String())
+	expectedPath := paths.InstallPath("testpkg", "1.0.0", p.String())
 	if installPath != expectedPath {
 		t.Errorf("Install() path = %q, want %q", installPath, expectedPath)
 	}
@@ -80,9 +85,28 @@ func TestInstall(t *testing.T) {
 			t.Error("bin file should be executable")
 		}
 	}
+	
+	// The install is recorded in the state index, and forgotten on uninstall
+	st, err := state.New(paths).Load()
+	if err != nil {
+		t.Fatalf("state Load() failed: %v", err)
+	}
+	inst := st.Find("testpkg", "1.0.0", platformStr)
+	if inst == nil || inst.Checksum != m.Versions["1.0.0"].Platforms[platformStr].Checksum || len(inst.Bins) != 1 {
+		t.Errorf("state record = %+v, want the checksum and bins", inst)
+	}
+	
+	if err := installer.Uninstall("testpkg", "1.0.0", p, false); err != nil {
+		t.Fatalf("Uninstall() failed: %v", err)
+	}
+	st, _ = state.New(paths).Load()
+	if st.Find("testpkg", "1.0.0", platformStr) != nil {
+		t.Error("Uninstall() should remove the state record")
+	}
 }
 
 func TestInstallMissingBin(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
 	extractDir := t.TempDir()
 	
 	m := &manifest.Manifest{
@@ -102,7 +126,7 @@ func TestInstallMissingBin(t *testing.T) {
 		},
 	}
 	
-	installer := New()
+	installer := New(paths)
 	ctx := context.Background()
 	p := platform.Detect()
 	
@@ -112,3 +136,151 @@ func TestInstallMissingBin(t *testing.T) {
 	}
 }
 
+func TestReplace(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	p := platform.Detect()
+	build := func(content string) string {
+		extractDir := t.TempDir()
+		os.MkdirAll(filepath.Join(extractDir, "tool", "bin"), 0755)
+		os.WriteFile(filepath.Join(extractDir, "tool", "bin", "tool"), []byte(content), 0755)
+		return extractDir
+	}
+	m := &manifest.Manifest{
+		Schema: 1,
+		Name:   "tool",
+		Bins:   []string{"bin/tool"},
+		Channels: map[string]manifest.Version{
+			"nightly": {Platforms: map[string]manifest.Asset{p.String(): {Type: "tar", URL: "https://example.com/tool.tar.gz"}}},
+		},
+	}
+	installer := New(paths)
+	ctx := context.Background()
+	installPath, err := installer.Install(ctx, m, "nightly", p, build("build-1"))
+	if err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+	
+	if _, previous, err := installer.Replace(ctx, m, "nightly", p, build("build-2")); err != nil {
+		t.Fatalf("Replace() failed: %v", err)
+	} else if data, _ := os.ReadFile(filepath.Join(installPath, "bin", "tool")); string(data) != "build-2" {
+		t.Errorf("bin/tool after Replace() = %q, want build-2", data)
+	} else if err := installer.Restore("tool", "nightly", p, previous); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(installPath, "bin", "tool")); string(data) != "build-1" {
+		t.Errorf("bin/tool after Restore() = %q, want build-1", data)
+	}
+	
+	// A build that can't be installed leaves the installed one alone
+	if _, _, err := installer.Replace(ctx, m, "nightly", p, t.TempDir()); err == nil {
+		t.Error("Replace() of a build without its bins should fail")
+	}
+	if data, _ := os.ReadFile(filepath.Join(installPath, "bin", "tool")); string(data) != "build-1" {
+		t.Errorf("bin/tool after a failed Replace() = %q, want build-1", data)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	extractDir := t.TempDir()
+	pkgDir := filepath.Join(extractDir, "tool-1.0.0")
+	os.MkdirAll(filepath.Join(pkgDir, "bin"), 0755)
+	os.WriteFile(filepath.Join(pkgDir, "bin", "tool"), []byte("original"), 0644)
+	os.WriteFile(filepath.Join(pkgDir, "README"), []byte("readme"), 0644)
+
+	installPath := t.TempDir()
+	os.MkdirAll(filepath.Join(installPath, "bin"), 0755)
+	os.WriteFile(filepath.Join(installPath, "bin", "tool"), []byte("corrupted"), 0755)
+	os.WriteFile(filepath.Join(installPath, "README"), []byte("edited by user"), 0644)
+
+	files := map[string]receipt.File{"bin/tool": {Mode: 0755}}
+	if err := Repair(extractDir, installPath, files); err != nil {
+		t.Fatalf("Repair() failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(installPath, "bin", "tool"))
+	if string(data) != "original" {
+		t.Errorf("bin/tool = %q, want %q", data, "original")
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(filepath.Join(installPath, "bin", "tool")); info.Mode().Perm() != 0755 {
+			t.Errorf("bin/tool mode = %v, want 0755 from the receipt", info.Mode().Perm())
+		}
+	}
+
+	// Files that were not listed are left alone
+	data, _ = os.ReadFile(filepath.Join(installPath, "README"))
+	if string(data) != "edited by user" {
+		t.Errorf("README = %q, want it untouched", data)
+	}
+}
+
+func TestCopyRecursive(t *testing.T) {
+	src := t.TempDir()
+	want := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		rel := filepath.Join(fmt.Sprintf("dir%d", i%5), "sub", fmt.Sprintf("file%d", i))
+		want[rel] = fmt.Sprintf("content %d", i)
+		os.MkdirAll(filepath.Join(src, filepath.Dir(rel)), 0755)
+		os.WriteFile(filepath.Join(src, rel), []byte(want[rel]), 0755)
+	}
+	os.MkdirAll(filepath.Join(src, "empty"), 0755)
+	
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := copyRecursive(src, dst); err != nil {
+		t.Fatalf("copyRecursive() failed: %v", err)
+	}
+	
+	for rel, content := range want {
+		data, err := os.ReadFile(filepath.Join(dst, rel))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", rel, data, err, content)
+			continue
+		}
+		if info, _ := os.Stat(filepath.Join(dst, rel)); runtime.GOOS != "windows" && info.Mode().Perm() != 0755 {
+			t.Errorf("%s mode = %v, want 0755", rel, info.Mode().Perm())
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dst, "empty")); err != nil || !info.IsDir() {
+		t.Error("copyRecursive() should copy empty directories")
+	}
+	
+	// A single file is copied as is
+	if err := copyRecursive(filepath.Join(src, "dir0", "sub", "file0"), filepath.Join(dst, "single")); err != nil {
+		t.Fatalf("copyRecursive() of a file failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "single")); string(data) != "content 0" {
+		t.Errorf("single file = %q, want %q", data, "content 0")
+	}
+}
+
+func TestCopyRecursiveSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "lib"), 0755)
+	os.WriteFile(filepath.Join(src, "lib", "libfoo.so.1"), []byte("library"), 0755)
+	os.Symlink("libfoo.so.1", filepath.Join(src, "lib", "libfoo.so"))
+	os.Symlink("..", filepath.Join(src, "lib", "up"))
+	
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := copyRecursive(src, dst); err != nil {
+		t.Fatalf("copyRecursive() failed: %v", err)
+	}
+	
+	for name, want := range map[string]string{"libfoo.so": "libfoo.so.1", "up": ".."} {
+		path := filepath.Join(dst, "lib", name)
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("lib/%s should be copied as a symlink", name)
+			continue
+		}
+		if target, _ := os.Readlink(path); target != want {
+			t.Errorf("lib/%s -> %q, want %q", name, target, want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "lib", "libfoo.so")); string(data) != "library" {
+		t.Errorf("lib/libfoo.so = %q, want %q", data, "library")
+	}
+}
@@ -0,0 +1,150 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Process is a running process whose executable lives inside an installation
+type Process struct {
+	PID int
+	Exe string
+}
+
+// InUseError is returned when an installation cannot be removed because it is running
+type InUseError struct {
+	Path      string
+	Processes []Process
+}
+
+func (e *InUseError) Error() string {
+	pids := make([]string, len(e.Processes))
+	for i, p := range e.Processes {
+		pids[i] = fmt.Sprintf("%s (pid %d)", filepath.Base(p.Exe), p.PID)
+	}
+	return fmt.Sprintf("%s is in use by %s", e.Path, strings.Join(pids, ", "))
+}
+
+// RunningFrom lists processes whose executable is inside dir, including nori itself.
+// Detection is best effort: platforms without a process listing only report nori.
+func RunningFrom(dir string) ([]Process, error) {
+	dir = canonical(dir)
+
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	// Always consider our own executable, even when the process list is unavailable
+	if exe, err := os.Executable(); err == nil {
+		procs = append(procs, Process{PID: os.Getpid(), Exe: exe})
+	}
+
+	seen := make(map[int]bool)
+	var running []Process
+	for _, p := range procs {
+		if seen[p.PID] || !within(canonical(p.Exe), dir) {
+			continue
+		}
+		seen[p.PID] = true
+		running = append(running, p)
+	}
+	return running, nil
+}
+
+// listProcesses returns the executable of every visible process
+func listProcesses() ([]Process, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return listProcProcesses()
+	case "darwin":
+		return listPSProcesses()
+	case "windows":
+		return listWindowsProcesses()
+	}
+	return nil, nil
+}
+
+// listProcProcesses reads /proc/<pid>/exe for every process we are allowed to inspect
+func listProcProcesses() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
+	}
+
+	var procs []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join("/proc", entry.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Process{PID: pid, Exe: strings.TrimSuffix(exe, " (deleted)")})
+	}
+	return procs, nil
+}
+
+// listPSProcesses uses ps, which reports full executable paths on macOS
+func listPSProcesses() ([]Process, error) {
+	out, err := exec.Command("ps", "-axo", "pid=,comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return parseProcessList(string(out)), nil
+}
+
+// listWindowsProcesses asks PowerShell for the image path of every process
+func listWindowsProcesses() ([]Process, error) {
+	script := `Get-Process | Where-Object Path | ForEach-Object { "$($_.Id) $($_.Path)" }`
+	out, err := exec.Command("powershell", "-NoProfile", "-Command", script).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return parseProcessList(string(out)), nil
+}
+
+// parseProcessList parses "<pid> <path>" lines
+func parseProcessList(out string) []Process {
+	var procs []Process
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Process{PID: pid, Exe: strings.TrimSpace(fields[1])})
+	}
+	return procs
+}
+
+// canonical resolves symlinks so paths can be compared, falling back to the cleaned path
+func canonical(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	path = filepath.Clean(path)
+	if runtime.GOOS == "windows" {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
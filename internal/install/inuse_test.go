@@ -0,0 +1,126 @@
+package install
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestRunningFromSelf(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skipf("os.Executable() unavailable: %v", err)
+	}
+
+	running, err := RunningFrom(filepath.Dir(exe))
+	if err != nil {
+		t.Fatalf("RunningFrom() failed: %v", err)
+	}
+
+	found := false
+	for _, p := range running {
+		if p.PID == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("RunningFrom(%q) = %v, want it to include the test process", filepath.Dir(exe), running)
+	}
+}
+
+func TestRunningFromUnrelatedDir(t *testing.T) {
+	running, err := RunningFrom(t.TempDir())
+	if err != nil {
+		t.Fatalf("RunningFrom() failed: %v", err)
+	}
+	if len(running) != 0 {
+		t.Errorf("RunningFrom(empty dir) = %v, want none", running)
+	}
+}
+
+func TestParseProcessList(t *testing.T) {
+	out := "  1 /sbin/launchd\n 42 /Users/me/.nori/installs/node/22.2.0/darwin-arm64/bin/node\nbogus\n"
+	procs := parseProcessList(out)
+
+	if len(procs) != 2 {
+		t.Fatalf("parseProcessList() returned %d processes, want 2", len(procs))
+	}
+	if procs[1].PID != 42 || procs[1].Exe != "/Users/me/.nori/installs/node/22.2.0/darwin-arm64/bin/node" {
+		t.Errorf("parseProcessList()[1] = %+v", procs[1])
+	}
+}
+
+func TestWithin(t *testing.T) {
+	dir := filepath.Join("installs", "node", "22.2.0")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{dir, true},
+		{filepath.Join(dir, "bin", "node"), true},
+		{filepath.Join("installs", "node", "22.2.0-rc"), false},
+		{filepath.Join("installs", "node"), false},
+	}
+
+	for _, tt := range tests {
+		if got := within(tt.path, dir); got != tt.want {
+			t.Errorf("within(%q, %q) = %v, want %v", tt.path, dir, got, tt.want)
+		}
+	}
+}
+
+func TestUninstallRefusesRunningBinary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses /proc to observe the child process")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+
+	paths := platform.NewPaths(t.TempDir())
+	p := platform.Detect()
+	installPath := paths.InstallPath("sleeper", "1.0.0", p.String())
+	binPath := filepath.Join(installPath, "bin", "sleeper")
+	if err := os.MkdirAll(filepath.Dir(binPath), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := copyRecursive(sleep, binPath); err != nil {
+		t.Fatalf("failed to copy sleep: %v", err)
+	}
+
+	cmd := exec.Command(binPath, "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start binary: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	installer := New(paths)
+	err = installer.Uninstall("sleeper", "1.0.0", p, false)
+	var inUse *InUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("Uninstall() error = %v, want *InUseError", err)
+	}
+	if len(inUse.Processes) != 1 || inUse.Processes[0].PID != cmd.Process.Pid {
+		t.Errorf("InUseError.Processes = %v, want pid %d", inUse.Processes, cmd.Process.Pid)
+	}
+
+	if err := installer.Uninstall("sleeper", "1.0.0", p, true); err != nil {
+		t.Fatalf("Uninstall(force) failed: %v", err)
+	}
+	if _, err := os.Stat(installPath); !os.IsNotExist(err) {
+		t.Errorf("install path still exists after forced uninstall")
+	}
+}
+
+func TestUninstallNotInstalled(t *testing.T) {
+	installer := New(platform.NewPaths(t.TempDir()))
+	if err := installer.Uninstall("missing", "1.0.0", platform.Detect(), false); err == nil {
+		t.Error("Uninstall() of a missing version should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// maxSmokeOutput is how much of a failed smoke test's output is reported
+const maxSmokeOutput = 500
+
+// SmokeTest runs m's smoke test against version installed at installPath, with the
+// package's bin directories first on PATH and its env set
+func SmokeTest(ctx context.Context, m *manifest.Manifest, version, installPath string) error {
+	test := m.SmokeTest
+	timeout, err := test.TimeoutDuration()
+	if err != nil {
+		return err
+	}
+	expected, err := test.Expected(version)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	bin := filepath.Join(installPath, filepath.FromSlash(test.Bin(m.Bins)))
+	cmd := exec.CommandContext(ctx, bin, test.Command[1:]...)
+	cmd.Env = append(os.Environ(), "PATH="+strings.Join(append(BinDirs(installPath, m.Bins), os.Getenv("PATH")), string(os.PathListSeparator)))
+	for key, value := range m.EnvFor(installPath) {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	// Don't wait on children that keep the output open once the command has exited
+	cmd.WaitDelay = time.Second
+
+	commandLine := strings.Join(test.Command, " ")
+	out, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("`%s` did not finish within %s", commandLine, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("`%s` failed: %w%s", commandLine, err, smokeOutput(out))
+	}
+	if expected != nil && !expected.MatchString(strings.TrimSpace(string(out))) {
+		return fmt.Errorf("the output of `%s` doesn't match %q%s", commandLine, expected, smokeOutput(out))
+	}
+	return nil
+}
+
+// smokeOutput formats the output of a failed smoke test for its error
+func smokeOutput(out []byte) string {
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return " (no output)"
+	}
+	if len(text) > maxSmokeOutput {
+		text = text[:maxSmokeOutput] + "..."
+	}
+	return ":\n" + text
+}
+
+// BinDirs returns the distinct directories beneath installPath that hold bins, in manifest order
+func BinDirs(installPath string, bins []string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, bin := range bins {
+		dir := filepath.Join(installPath, filepath.Dir(filepath.FromSlash(bin)))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,44 @@
+package install
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+func TestSmokeTest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the smoke test runs a shell script")
+	}
+
+	installPath := t.TempDir()
+	os.MkdirAll(filepath.Join(installPath, "bin"), 0755)
+	os.WriteFile(filepath.Join(installPath, "bin", "tool"), []byte("#!/bin/sh\necho \"tool $TOOL_HOME\"\n"), 0755)
+	m := &manifest.Manifest{
+		Name: "tool",
+		Bins: []string{"bin/tool"},
+		Env:  map[string]string{"TOOL_HOME": "{install}"},
+	}
+
+	for _, tt := range []struct {
+		expect, want string
+	}{
+		{"^tool " + regexp.QuoteMeta(installPath) + "$", ""},
+		{"^tool {version}$", "doesn't match"},
+	} {
+		m.SmokeTest = &manifest.SmokeTest{Command: []string{"tool"}, Expect: tt.expect}
+		err := SmokeTest(context.Background(), m, "1.0.0", installPath)
+		if tt.want == "" && err != nil {
+			t.Errorf("SmokeTest() with expect %q failed: %v", tt.expect, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("SmokeTest() with expect %q = %v, want %q", tt.expect, err, tt.want)
+		}
+	}
+}
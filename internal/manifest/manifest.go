@@ -1,5 +1,13 @@
 package manifest
 
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
 // Manifest represents a package manifest
 type Manifest struct {
 	Schema      int               `yaml:"schema" json:"schema"`
@@ -7,8 +15,150 @@ type Manifest struct {
 	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
 	Homepage    string            `yaml:"homepage,omitempty" json:"homepage,omitempty"`
 	License     string            `yaml:"license,omitempty" json:"license,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty" json:"tags,omitempty"` // search keywords, e.g. javascript
 	Bins        []string          `yaml:"bins" json:"bins"`
 	Versions    map[string]Version `yaml:"versions" json:"versions"`
+	Members     map[string]string  `yaml:"members,omitempty" json:"members,omitempty"` // package group: member name -> version
+	Channels    map[string]Version `yaml:"channels,omitempty" json:"channels,omitempty"` // rolling builds, e.g. nightly
+	Env         map[string]string  `yaml:"env,omitempty" json:"env,omitempty"` // environment the package needs, e.g. GOROOT: "{install}"
+	SmokeTest   *SmokeTest         `yaml:"smoke_test,omitempty" json:"smoke_test,omitempty"` // run after install to catch broken assets
+	Registry    string             `yaml:"-" json:"-"` // the registry the manifest was loaded from, see registry.Registry.Label
+}
+
+// InstallPlaceholder stands for a version's install directory in env values
+const InstallPlaceholder = "{install}"
+
+// EnvFor returns the package's env for the version installed at installPath
+func (m *Manifest) EnvFor(installPath string) map[string]string {
+	return ExpandEnv(m.Env, installPath)
+}
+
+// ExpandEnv returns env, as a manifest declares it, for the version installed at installPath
+func ExpandEnv(env map[string]string, installPath string) map[string]string {
+	expanded := make(map[string]string, len(env))
+	for key, value := range env {
+		expanded[key] = strings.ReplaceAll(value, InstallPlaceholder, installPath)
+	}
+	return expanded
+}
+
+// IsGroup reports whether the manifest describes a package group rather than an installable package
+func (m *Manifest) IsGroup() bool {
+	return len(m.Members) > 0
+}
+
+// IsChannel reports whether name is a rolling channel rather than a fixed version.
+// Channel contents change over time, so installs of them are not reproducible.
+func (m *Manifest) IsChannel(name string) bool {
+	_, ok := m.Channels[name]
+	return ok
+}
+
+// SortedVersions returns the manifest's versions in ascending semver order
+func (m *Manifest) SortedVersions() []string {
+	return sortedKeys(m.Versions)
+}
+
+// VersionsFor returns the versions that ship an asset for platform, in ascending semver order
+func (m *Manifest) VersionsFor(platform string) []string {
+	var versions []string
+	for _, v := range m.SortedVersions() {
+		if _, ok := m.Versions[v].Platforms[platform]; ok {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// PlatformsFor returns the platforms version, or channel, ships an asset for, sorted
+func (m *Manifest) PlatformsFor(version string) []string {
+	ver, ok := m.Versions[version]
+	if !ok {
+		ver = m.Channels[version]
+	}
+	platforms := make([]string, 0, len(ver.Platforms))
+	for plat := range ver.Platforms {
+		platforms = append(platforms, plat)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// FindDigest returns the version, or channel, whose asset for platform has the checksum
+// digest (sha256:hex). A version is preferred to a channel that currently ships the same
+// build, but a digest shared by several versions, or several channels, is ambiguous. If
+// only other platforms' assets match, the error names them.
+func (m *Manifest) FindDigest(digest, platform string) (string, error) {
+	digest = strings.ToLower(digest)
+	var elsewhere []string
+	find := func(versions map[string]Version) []string {
+		var matched []string
+		for _, name := range sortedKeys(versions) {
+			assets := versions[name].Platforms
+			for _, plat := range slices.Sorted(maps.Keys(assets)) {
+				if strings.ToLower(assets[plat].Checksum) != digest {
+					continue
+				}
+				if plat == platform {
+					matched = append(matched, name)
+					continue
+				}
+				elsewhere = append(elsewhere, name+" for "+plat)
+			}
+		}
+		return matched
+	}
+
+	for _, versions := range []map[string]Version{m.Versions, m.Channels} {
+		switch matched := find(versions); len(matched) {
+		case 0:
+			continue
+		case 1:
+			return matched[0], nil
+		default:
+			return "", fmt.Errorf("digest %s is the %s build of %s %s; install one by name", digest, platform, m.Name, strings.Join(matched, ", "))
+		}
+	}
+	if len(elsewhere) > 0 {
+		return "", fmt.Errorf("digest %s belongs to %s, not %s", digest, strings.Join(elsewhere, ", "), platform)
+	}
+	return "", fmt.Errorf("no %s asset has digest %s", m.Name, digest)
+}
+
+// sortedKeys returns the keys of versions in ascending semver order
+func sortedKeys(versions map[string]Version) []string {
+	keys := make([]string, 0, len(versions))
+	for k := range versions {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return CompareVersions(keys[i], keys[j]) < 0
+	})
+	return keys
+}
+
+// LatestVersion returns the highest stable version, falling back to the highest prerelease
+func (m *Manifest) LatestVersion() string {
+	return latest(m.SortedVersions())
+}
+
+// LatestVersionFor returns the highest stable version with an asset for platform,
+// falling back to the highest prerelease. It returns "" if none ships for platform.
+func (m *Manifest) LatestVersionFor(platform string) string {
+	return latest(m.VersionsFor(platform))
+}
+
+// latest returns the highest stable version of the ascending versions, falling back to the highest prerelease
+func latest(versions []string) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v, err := ParseVersion(versions[i]); err == nil && v.Prerelease == "" {
+			return versions[i]
+		}
+	}
+	if len(versions) > 0 {
+		return versions[len(versions)-1]
+	}
+	return ""
 }
 
 // Version represents a specific version of a package
@@ -18,8 +168,69 @@ type Version struct {
 
 // Asset represents a downloadable asset for a specific platform
 type Asset struct {
-	Type     string `yaml:"type" json:"type"`     // tar or zip
+	Type     string `yaml:"type" json:"type"`     // tar, zip, deb or rpm, or dmg or pkg on macOS
 	URL      string `yaml:"url" json:"url"`       // HTTPS URL
 	Checksum string `yaml:"checksum" json:"checksum"` // sha256:hex format
+	Mirrors  []string `yaml:"mirrors,omitempty" json:"mirrors,omitempty"` // alternative HTTPS URLs for the same file
+
+	// GitHub is the owner/repo whose releases an asset without a URL is downloaded from.
+	// The release is the one tagged Tag, v{version} by default, and the asset the one
+	// whose name matches the glob AssetPattern; both may use {version}. The download
+	// URL is looked up through the GitHub Releases API at install time.
+	GitHub       string `yaml:"github,omitempty" json:"github,omitempty"`
+	Tag          string `yaml:"tag,omitempty" json:"tag,omitempty"`
+	AssetPattern string `yaml:"asset_pattern,omitempty" json:"asset_pattern,omitempty"`
+
+	// Inner is a glob matching the archive packed inside the asset, such as
+	// tool-{version}.tar.gz inside a zip, that holds the files to install. An archive
+	// that is the only file in the asset is unwrapped without it.
+	Inner string `yaml:"inner,omitempty" json:"inner,omitempty"`
+
+	// ChecksumsURL lists the current checksum of a channel asset, in sha256sum format
+	ChecksumsURL string `yaml:"checksums_url,omitempty" json:"checksums_url,omitempty"`
+
+	// PayloadChecksum is the digest of a tar asset's payload once decompressed, in
+	// sha256:hex format. An archive that upstream has recompressed still verifies by it.
+	PayloadChecksum string `yaml:"payload_checksum,omitempty" json:"payload_checksum,omitempty"`
+
+	// Provenance is the HTTPS URL of a SLSA provenance attestation whose subject is the
+	// asset, such as a release's .intoto.jsonl file or a GitHub artifact attestation
+	Provenance string `yaml:"provenance,omitempty" json:"provenance,omitempty"`
+
+	// CPUFeatures lists the instruction set extensions the asset's binaries need, such as avx2
+	CPUFeatures []string `yaml:"cpu_features,omitempty" json:"cpu_features,omitempty"`
+
+	// OSMin is the oldest OS release the asset runs on, as a dotted version: the macOS
+	// version, the Windows build (10.0.17763) or the Linux kernel release
+	OSMin string `yaml:"os_min,omitempty" json:"os_min,omitempty"`
+
+	// Baseline is installed instead on machines that don't meet CPUFeatures or OSMin
+	Baseline *Asset `yaml:"baseline,omitempty" json:"baseline,omitempty"`
+}
+
+// URLs returns the primary URL followed by any mirrors
+func (a *Asset) URLs() []string {
+	return append([]string{a.URL}, a.Mirrors...)
+}
+
+// ReleaseTag returns the tag of the GitHub release that holds the asset of version
+func (a *Asset) ReleaseTag(version string) string {
+	tag := a.Tag
+	if tag == "" {
+		tag = "v{version}"
+	}
+	return strings.ReplaceAll(tag, "{version}", version)
+}
+
+// InnerPattern returns the glob that the path of the archive inside the asset of
+// version matches, or "" to unwrap only a lone archive
+func (a *Asset) InnerPattern(version string) string {
+	return strings.ReplaceAll(a.Inner, "{version}", version)
+}
+
+// ReleaseAssetPattern returns the glob that the name of the GitHub release asset of
+// version matches
+func (a *Asset) ReleaseAssetPattern(version string) string {
+	return strings.ReplaceAll(a.AssetPattern, "{version}", version)
 }
 
@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -135,3 +137,155 @@ versions:
 	}
 }
 
+
+func BenchmarkLoadAndValidate(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("schema: 1\nname: node\nbins:\n  - bin/node\nversions:\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "  \"%d.0.0\":\n    platforms:\n", i)
+		for _, p := range []string{"linux-amd64", "linux-arm64", "darwin-amd64", "darwin-arm64", "windows-amd64"} {
+			fmt.Fprintf(&sb, "      %s:\n        type: tar\n        url: https://example.com/node-%d-%s.tar.gz\n        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef\n", p, i, p)
+		}
+	}
+	data := []byte(sb.String())
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m, err := LoadFromBytes(data)
+		if err != nil {
+			b.Fatalf("LoadFromBytes() failed: %v", err)
+		}
+		if err := Validate(m); err != nil {
+			b.Fatalf("Validate() failed: %v", err)
+		}
+	}
+}
+
+func TestSortedVersions(t *testing.T) {
+	m := &Manifest{Versions: map[string]Version{
+		"1.10.0":     {},
+		"1.2.0":      {},
+		"2.0.0-rc.1": {},
+		"1.9.3":      {},
+	}}
+
+	got := strings.Join(m.SortedVersions(), " ")
+	want := "1.2.0 1.9.3 1.10.0 2.0.0-rc.1"
+	if got != want {
+		t.Errorf("SortedVersions() = %q, want %q", got, want)
+	}
+}
+
+func TestVersionsFor(t *testing.T) {
+	linux := map[string]Asset{"linux-amd64": {}}
+	both := map[string]Asset{"linux-amd64": {}, "darwin-arm64": {}}
+	m := &Manifest{Versions: map[string]Version{
+		"1.10.0": {Platforms: both},
+		"1.2.0":  {Platforms: linux},
+		"1.9.0":  {Platforms: both},
+	}}
+
+	if got := strings.Join(m.VersionsFor("darwin-arm64"), " "); got != "1.9.0 1.10.0" {
+		t.Errorf("VersionsFor(darwin-arm64) = %q, want %q", got, "1.9.0 1.10.0")
+	}
+	if got := m.VersionsFor("windows-amd64"); len(got) != 0 {
+		t.Errorf("VersionsFor(windows-amd64) = %v, want none", got)
+	}
+}
+
+func TestPlatformsFor(t *testing.T) {
+	m := &Manifest{
+		Versions: map[string]Version{"1.0.0": {Platforms: map[string]Asset{"linux-amd64": {}, "darwin-arm64": {}}}},
+		Channels: map[string]Version{"nightly": {Platforms: map[string]Asset{"linux-arm64": {}}}},
+	}
+
+	if got := strings.Join(m.PlatformsFor("1.0.0"), " "); got != "darwin-arm64 linux-amd64" {
+		t.Errorf("PlatformsFor(1.0.0) = %q, want %q", got, "darwin-arm64 linux-amd64")
+	}
+	if got := strings.Join(m.PlatformsFor("nightly"), " "); got != "linux-arm64" {
+		t.Errorf("PlatformsFor(nightly) = %q, want linux-arm64", got)
+	}
+	if got := m.PlatformsFor("9.9.9"); len(got) != 0 {
+		t.Errorf("PlatformsFor(9.9.9) = %v, want none", got)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{[]string{"1.2.0", "1.10.0", "2.0.0-rc.1"}, "1.10.0"},
+		{[]string{"2.0.0-rc.1", "2.0.0-rc.2"}, "2.0.0-rc.2"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		m := &Manifest{Versions: map[string]Version{}}
+		for _, v := range tt.versions {
+			m.Versions[v] = Version{}
+		}
+		if got := m.LatestVersion(); got != tt.want {
+			t.Errorf("LatestVersion() with %v = %q, want %q", tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestLatestVersionFor(t *testing.T) {
+	m := &Manifest{Versions: map[string]Version{
+		"1.0.0":     {Platforms: map[string]Asset{"linux-amd64": {}, "darwin-arm64": {}}},
+		"1.1.0":     {Platforms: map[string]Asset{"linux-amd64": {}}},
+		"2.0.0-rc1": {Platforms: map[string]Asset{"darwin-arm64": {}}},
+	}}
+
+	tests := map[string]string{
+		"linux-amd64":   "1.1.0",
+		"darwin-arm64":  "1.0.0",
+		"windows-amd64": "",
+	}
+	for plat, want := range tests {
+		if got := m.LatestVersionFor(plat); got != want {
+			t.Errorf("LatestVersionFor(%s) = %q, want %q", plat, got, want)
+		}
+	}
+}
+
+func TestFindDigest(t *testing.T) {
+	a := "sha256:" + strings.Repeat("a", 64)
+	b := "sha256:" + strings.Repeat("b", 64)
+	c := "sha256:" + strings.Repeat("c", 64)
+	m := &Manifest{
+		Name: "tool",
+		Versions: map[string]Version{
+			"1.0.0": {Platforms: map[string]Asset{"linux-amd64": {Checksum: a}, "darwin-arm64": {Checksum: b}}},
+		},
+		Channels: map[string]Version{
+			"nightly": {Platforms: map[string]Asset{"linux-amd64": {Checksum: c}}},
+		},
+	}
+
+	if got, err := m.FindDigest(strings.ToUpper(a[:7])+a[7:], "linux-amd64"); err != nil || got != "1.0.0" {
+		t.Errorf("FindDigest(a) = %q, %v, want 1.0.0", got, err)
+	}
+	if got, err := m.FindDigest(c, "linux-amd64"); err != nil || got != "nightly" {
+		t.Errorf("FindDigest(c) = %q, %v, want nightly", got, err)
+	}
+	if _, err := m.FindDigest(b, "linux-amd64"); err == nil || !strings.Contains(err.Error(), "1.0.0 for darwin-arm64") {
+		t.Errorf("FindDigest(b) error = %v, want it to name the darwin-arm64 build", err)
+	}
+	if _, err := m.FindDigest("sha256:"+strings.Repeat("d", 64), "linux-amd64"); err == nil {
+		t.Error("FindDigest() should fail for an unknown digest")
+	}
+
+	// A version is preferred to a channel with the same build, but two versions are ambiguous
+	m.Channels["stable"] = Version{Platforms: map[string]Asset{"linux-amd64": {Checksum: a}}}
+	if got, err := m.FindDigest(a, "linux-amd64"); err != nil || got != "1.0.0" {
+		t.Errorf("FindDigest(a) = %q, %v, want 1.0.0 over the stable channel", got, err)
+	}
+	m.Versions["1.0.1"] = Version{Platforms: map[string]Asset{"linux-amd64": {Checksum: a}}}
+	if _, err := m.FindDigest(a, "linux-amd64"); err == nil || !strings.Contains(err.Error(), "tool 1.0.0, 1.0.1") {
+		t.Errorf("FindDigest() of a digest of two versions = %v, want them named", err)
+	}
+}
@@ -0,0 +1,235 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is a set of acceptable versions, such as 22, ^20.1, ~1.2.3 or ">=1.2 <2".
+// It is a union of alternatives separated by ||, each an intersection of comparators.
+type Range struct {
+	spec string
+	alts [][]comparator
+}
+
+// comparator is a single bound such as >=1.2.0
+type comparator struct {
+	op string
+	v  Semver
+}
+
+// ParseRange parses a version range. Supported forms are exact versions, partial
+// versions (22, 1.22, 1.x), caret (^20.1) and tilde (~1.2) ranges, comparisons
+// (>=, >, <=, <, =) separated by spaces, and alternatives joined by ||.
+func ParseRange(spec string) (Range, error) {
+	r := Range{spec: spec}
+	for _, alt := range strings.Split(spec, "||") {
+		var comps []comparator
+		for _, term := range strings.Fields(alt) {
+			c, err := parseTerm(term)
+			if err != nil {
+				return Range{}, fmt.Errorf("invalid version range %q: %w", spec, err)
+			}
+			comps = append(comps, c...)
+		}
+		if len(comps) == 0 {
+			return Range{}, fmt.Errorf("invalid version range %q: empty alternative", spec)
+		}
+		r.alts = append(r.alts, comps)
+	}
+	return r, nil
+}
+
+// String returns the range as written
+func (r Range) String() string {
+	return r.spec
+}
+
+// Matches reports whether v is in the range. Prereleases only match comparators
+// that name a prerelease of the same version, as in npm.
+func (r Range) Matches(v Semver) bool {
+	for _, alt := range r.alts {
+		if matchesAll(alt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Highest returns the highest of versions in the range, or "" if none is
+func (r Range) Highest(versions []string) string {
+	best := ""
+	var bestV Semver
+	for _, s := range versions {
+		v, err := ParseVersion(s)
+		if err != nil || !r.Matches(v) {
+			continue
+		}
+		if best == "" || v.Compare(bestV) > 0 {
+			best, bestV = s, v
+		}
+	}
+	return best
+}
+
+// matchesAll reports whether v satisfies every comparator
+func matchesAll(comps []comparator, v Semver) bool {
+	if v.Prerelease != "" {
+		allowed := false
+		for _, c := range comps {
+			if c.v.Prerelease != "" && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	for _, c := range comps {
+		cmp := v.Compare(c.v)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTerm turns one term of a range into comparators
+func parseTerm(term string) ([]comparator, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(term, op); ok {
+			v, n, err := parsePartial(rest)
+			if err != nil {
+				return nil, err
+			}
+			// A partial upper bound covers the whole release line: <=1.2 is <1.3.0
+			switch {
+			case op == "<=" && n < 3:
+				return []comparator{{"<", bump(v, n)}}, nil
+			case op == ">" && n < 3:
+				return []comparator{{">=", bump(v, n)}}, nil
+			case op == "=" && n < 3:
+				return []comparator{{">=", v}, {"<", bump(v, n)}}, nil
+			}
+			return []comparator{{op, v}}, nil
+		}
+	}
+
+	switch {
+	case term == "*" || term == "x" || term == "latest":
+		return []comparator{{">=", Semver{}}}, nil
+	case strings.HasPrefix(term, "^"):
+		v, n, err := parsePartial(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		// ^ allows changes that do not modify the left-most non-zero part
+		upper := Semver{Major: v.Major + 1}
+		switch {
+		case v.Major == 0 && (v.Minor > 0 || n == 2):
+			upper = Semver{Minor: v.Minor + 1}
+		case v.Major == 0 && n == 3:
+			upper = Semver{Patch: v.Patch + 1}
+		case v.Major == 0:
+			upper = Semver{Major: 1}
+		}
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	case strings.HasPrefix(term, "~"):
+		v, n, err := parsePartial(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		// ~ allows patch changes, or minor ones when only a major is given
+		if n == 1 {
+			return []comparator{{">=", v}, {"<", Semver{Major: v.Major + 1}}}, nil
+		}
+		return []comparator{{">=", v}, {"<", Semver{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	}
+
+	v, n, err := parsePartial(term)
+	if err != nil {
+		return nil, err
+	}
+	if n == 3 {
+		return []comparator{{"=", v}}, nil
+	}
+	return []comparator{{">=", v}, {"<", bump(v, n)}}, nil
+}
+
+// parsePartial parses a version that may omit its minor and patch numbers, or give
+// them as x or *, returning the version and how many numbers were given
+func parsePartial(s string) (Semver, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if v, err := ParseVersion(s); err == nil {
+		return v, 3, nil
+	}
+
+	var nums []int
+	for _, part := range strings.Split(s, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Semver{}, 0, fmt.Errorf("%q is not a version", s)
+		}
+		nums = append(nums, n)
+	}
+	if len(nums) == 0 || len(nums) > 3 {
+		return Semver{}, 0, fmt.Errorf("%q is not a version", s)
+	}
+
+	v := Semver{Major: nums[0]}
+	if len(nums) > 1 {
+		v.Minor = nums[1]
+	}
+	if len(nums) > 2 {
+		v.Patch = nums[2]
+	}
+	return v, len(nums), nil
+}
+
+// bump returns the first version after the release line v names with n numbers
+func bump(v Semver, n int) Semver {
+	if n == 1 {
+		return Semver{Major: v.Major + 1}
+	}
+	return Semver{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// ResolveVersion returns the version named by spec for platform: spec itself when it
+// is a version or channel the manifest has, the version whose asset for platform has
+// the checksum when spec is a sha256: digest, or else the highest version with an
+// asset for platform in the range spec.
+func (m *Manifest) ResolveVersion(spec, platform string) (string, error) {
+	if _, ok := m.Versions[spec]; ok || m.IsChannel(spec) {
+		return spec, nil
+	}
+	if IsDigest(spec) {
+		return m.FindDigest(spec, platform)
+	}
+
+	r, err := ParseRange(spec)
+	if err != nil {
+		return "", err
+	}
+	if version := r.Highest(m.VersionsFor(platform)); version != "" {
+		return version, nil
+	}
+	return "", fmt.Errorf("no version of %s matching %s is available for %s", m.Name, spec, platform)
+}
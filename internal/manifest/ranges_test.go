@@ -0,0 +1,99 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRangeHighest(t *testing.T) {
+	versions := []string{"0.2.1", "0.2.5", "0.3.0", "1.2.0", "1.2.9", "1.10.0", "20.0.0", "20.1.0", "20.11.1", "21.0.0", "22.2.0"}
+
+	tests := map[string]string{
+		"22":             "22.2.0",
+		"20":             "20.11.1",
+		"1.2":            "1.2.9",
+		"1.x":            "1.10.0",
+		"20.1.0":         "20.1.0",
+		"^20.1":          "20.11.1",
+		"^0.2":           "0.2.5",
+		"^0.2.1":         "0.2.5",
+		"~1.2":           "1.2.9",
+		"~1":             "1.10.0",
+		">=1.2 <20":      "1.10.0",
+		"<=1.2":          "1.2.9",
+		">20.1":          "22.2.0",
+		"=1.2":           "1.2.9",
+		"<1.2.5":         "1.2.0",
+		"^21 || ^1.2":    "21.0.0",
+		"^9 || ~0.3":     "0.3.0",
+		"*":              "22.2.0",
+		"latest":         "22.2.0",
+		"^23":            "",
+		"1.2.3":          "",
+		">=21.0.0 <21.0": "",
+	}
+	for spec, want := range tests {
+		r, err := ParseRange(spec)
+		if err != nil {
+			t.Errorf("ParseRange(%q) failed: %v", spec, err)
+			continue
+		}
+		if got := r.Highest(versions); got != want {
+			t.Errorf("ParseRange(%q).Highest() = %q, want %q", spec, got, want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, spec := range []string{"", "node", "^", ">=abc", "1.2.3.4", "^1 ||"} {
+		if _, err := ParseRange(spec); err == nil {
+			t.Errorf("ParseRange(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRangePrerelease(t *testing.T) {
+	r, _ := ParseRange(">=2.0.0-rc.1")
+	for v, want := range map[string]bool{"2.0.0-rc.2": true, "2.0.0": true, "2.1.0-rc.1": false} {
+		sv, _ := ParseVersion(v)
+		if got := r.Matches(sv); got != want {
+			t.Errorf("Matches(%s) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	m := &Manifest{
+		Name: "node",
+		Versions: map[string]Version{
+			"20.10.0": {Platforms: map[string]Asset{"linux-amd64": {}}},
+			"20.11.0": {Platforms: map[string]Asset{"darwin-arm64": {}}},
+			"22.2.0":  {Platforms: map[string]Asset{"linux-amd64": {Checksum: "sha256:" + strings.Repeat("ab", 32)}}},
+		},
+		Channels: map[string]Version{"nightly": {Platforms: map[string]Asset{"linux-amd64": {}}}},
+	}
+
+	tests := []struct {
+		spec, platform, want string
+	}{
+		{"20.11.0", "linux-amd64", "20.11.0"},
+		{"nightly", "linux-amd64", "nightly"},
+		{"20", "linux-amd64", "20.10.0"},
+		{"20", "darwin-arm64", "20.11.0"},
+		{"^20.1", "linux-amd64", "20.10.0"},
+		{">=20", "linux-amd64", "22.2.0"},
+		{"sha256:" + strings.Repeat("AB", 32), "linux-amd64", "22.2.0"},
+	}
+	for _, tt := range tests {
+		if got, err := m.ResolveVersion(tt.spec, tt.platform); err != nil || got != tt.want {
+			t.Errorf("ResolveVersion(%q, %s) = %q, %v, want %q", tt.spec, tt.platform, got, err, tt.want)
+		}
+	}
+
+	if _, err := m.ResolveVersion("^21", "linux-amd64"); err == nil {
+		t.Error("ResolveVersion() should fail when no version matches")
+	}
+	if _, err := m.ResolveVersion("sha256:"+strings.Repeat("ab", 32), "darwin-arm64"); err == nil {
+		t.Error("ResolveVersion() should fail for a digest of another platform's build")
+	}
+}
@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Host describes what a machine offers to the requirements of an asset
+type Host struct {
+	OSVersion   string          // "" if unknown, in which case os_min isn't checked
+	CPUFeatures map[string]bool // keyed by lowercase feature name
+}
+
+// Unmet returns the requirements of the asset that host doesn't meet, such as avx2 or
+// "OS 11.0", or nil if it meets them all
+func (a *Asset) Unmet(host Host) []string {
+	var unmet []string
+	for _, feature := range a.CPUFeatures {
+		if !host.CPUFeatures[strings.ToLower(feature)] {
+			unmet = append(unmet, feature)
+		}
+	}
+	if a.OSMin != "" && host.OSVersion != "" && compareDotted(host.OSVersion, a.OSMin) < 0 {
+		unmet = append(unmet, "OS "+a.OSMin)
+	}
+	return unmet
+}
+
+// ForHost returns the build of the asset to install on host: the asset itself, or else
+// the first of its baselines whose requirements host meets
+func (a *Asset) ForHost(host Host) (*Asset, error) {
+	for asset := a; asset != nil; asset = asset.Baseline {
+		if len(asset.Unmet(host)) == 0 {
+			return asset, nil
+		}
+	}
+	msg := fmt.Sprintf("this machine lacks %s, which the build requires", strings.Join(a.Unmet(host), ", "))
+	if a.Baseline != nil {
+		msg += ", and no baseline build fits it either"
+	}
+	return nil, fmt.Errorf("%s", msg)
+}
+
+// HasChecksum reports whether the asset or one of its baselines has checksum
+func (a *Asset) HasChecksum(checksum string) bool {
+	for asset := a; asset != nil; asset = asset.Baseline {
+		if strings.EqualFold(asset.Checksum, checksum) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareDotted compares two dotted versions numerically, treating missing parts as 0
+func compareDotted(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
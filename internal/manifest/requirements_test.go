@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+const requirementsManifest = `
+schema: 1
+name: test
+bins:
+  - bin/test
+versions:
+  "1.0.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/test-avx2.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+        cpu_features: [avx2, bmi2]
+        os_min: "4.18"
+        baseline:
+          type: tar
+          url: https://example.com/test.tar.gz
+          checksum: sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef
+`
+
+func TestValidateRequirements(t *testing.T) {
+	m, err := LoadFromBytes([]byte(requirementsManifest))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Fatalf("Validate() failed for an asset with a baseline: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(a *Asset)
+		want   string
+	}{
+		{"feature name", func(a *Asset) { a.CPUFeatures = []string{"AVX 2"} }, "invalid cpu feature"},
+		{"os_min", func(a *Asset) { a.OSMin = "Big Sur" }, "invalid os_min"},
+		{"unused baseline", func(a *Asset) { a.CPUFeatures, a.OSMin = nil, "" }, "never used"},
+		{"baseline asset", func(a *Asset) { a.Baseline.URL = "http://example.com/test.tar.gz" }, "baseline: URL must use HTTPS"},
+	}
+	for _, tt := range tests {
+		m, _ := LoadFromBytes([]byte(requirementsManifest))
+		asset := m.Versions["1.0.0"].Platforms["linux-amd64"]
+		tt.change(&asset)
+		m.Versions["1.0.0"].Platforms["linux-amd64"] = asset
+		if err := Validate(m); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate() = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAssetForHost(t *testing.T) {
+	m, err := LoadFromBytes([]byte(requirementsManifest))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	asset, _ := m.GetAsset("1.0.0", "linux-amd64")
+
+	modern := Host{OSVersion: "6.1.0", CPUFeatures: map[string]bool{"avx2": true, "bmi2": true}}
+	if got, err := asset.ForHost(modern); err != nil || got != asset {
+		t.Errorf("ForHost(modern) = %+v, %v, want the asset itself", got, err)
+	}
+	// An unknown OS release doesn't block the install
+	if got, err := asset.ForHost(Host{CPUFeatures: modern.CPUFeatures}); err != nil || got != asset {
+		t.Errorf("ForHost(unknown OS) = %+v, %v, want the asset itself", got, err)
+	}
+
+	old := Host{OSVersion: "4.4.0", CPUFeatures: map[string]bool{"avx2": true}}
+	if unmet := asset.Unmet(old); strings.Join(unmet, ",") != "bmi2,OS 4.18" {
+		t.Errorf("Unmet(old) = %v, want bmi2 and the OS", unmet)
+	}
+	if got, err := asset.ForHost(old); err != nil || got != asset.Baseline {
+		t.Errorf("ForHost(old) = %+v, %v, want the baseline", got, err)
+	}
+	if !asset.HasChecksum(asset.Baseline.Checksum) || asset.HasChecksum("sha256:00") {
+		t.Error("HasChecksum() should match the asset and its baseline only")
+	}
+
+	asset.Baseline = nil
+	if _, err := asset.ForHost(old); err == nil || !strings.Contains(err.Error(), "lacks bmi2, OS 4.18") {
+		t.Errorf("ForHost(old) without a baseline = %v, want the unmet requirements", err)
+	}
+}
+
+func TestCompareDotted(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"10.0.22631", "10.0.17763", 1},
+		{"11", "11.0", 0},
+		{"4.9", "4.18", -1},
+	}
+	for _, tt := range tests {
+		if got := compareDotted(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareDotted(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Semver represents a parsed semantic version
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a MAJOR.MINOR.PATCH[-prerelease][+build] version string
+func ParseVersion(s string) (Semver, error) {
+	var v Semver
+
+	core := strings.TrimPrefix(s, "v")
+	if i := strings.Index(core, "+"); i >= 0 {
+		core = core[:i]
+	}
+	if i := strings.Index(core, "-"); i >= 0 {
+		v.Prerelease = core[i+1:]
+		core = core[:i]
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Semver{}, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Semver{}, fmt.Errorf("invalid version %q: %q is not a number", s, part)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+
+	return v, nil
+}
+
+// String returns the version in MAJOR.MINOR.PATCH[-prerelease] form
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower, equal or higher than o
+func (v Semver) Compare(o Semver) int {
+	if c := compareInts(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// CompareVersions compares two version strings, returning -1, 0 or 1.
+// Unparseable versions sort before valid ones and are compared lexically among themselves.
+func CompareVersions(a, b string) int {
+	va, errA := ParseVersion(a)
+	vb, errB := ParseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+// compareInts compares two integers
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares prerelease strings using semver precedence rules
+func comparePrerelease(a, b string) int {
+	// A release has higher precedence than any prerelease
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInts(aNum, bNum); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareInts(len(aParts), len(bParts))
+}
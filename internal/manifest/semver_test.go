@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Semver
+		wantErr bool
+	}{
+		{"1.2.3", Semver{Major: 1, Minor: 2, Patch: 3}, false},
+		{"v22.2.0", Semver{Major: 22, Minor: 2, Patch: 0}, false},
+		{"1.0.0-rc.1", Semver{Major: 1, Prerelease: "rc.1"}, false},
+		{"1.0.0+build.5", Semver{Major: 1}, false},
+		{"1.2", Semver{}, true},
+		{"1.x.0", Semver{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseVersion(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseVersion(%q) should fail", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"2.0.0", "1.9.9", 1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"invalid", "1.0.0", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			if got := CompareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
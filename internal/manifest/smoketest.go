@@ -0,0 +1,80 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// VersionPlaceholder stands for the version being installed in a smoke test's expect
+const VersionPlaceholder = "{version}"
+
+// DefaultSmokeTimeout is how long a smoke test may run when it doesn't say
+const DefaultSmokeTimeout = 30 * time.Second
+
+// SmokeTest is a quick check that an installed version runs, such as `node --version`
+type SmokeTest struct {
+	// Command runs one of the package's bins, named first, with the arguments that follow
+	Command []string `yaml:"command" json:"command"`
+	// Expect is a regular expression the command's output, trimmed of surrounding
+	// whitespace, must match, if set. {version} stands for the version installed.
+	Expect string `yaml:"expect,omitempty" json:"expect,omitempty"`
+	// Timeout is how long the command may run, as a duration such as 10s
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Bin returns the manifest bin path the smoke test runs, or "" if no bin has its name
+func (s *SmokeTest) Bin(bins []string) string {
+	if len(s.Command) == 0 {
+		return ""
+	}
+	for _, bin := range bins {
+		if filepath.Base(bin) == s.Command[0] {
+			return bin
+		}
+	}
+	return ""
+}
+
+// Expected returns the pattern the output of version must match, or nil if any will do
+func (s *SmokeTest) Expected(version string) (*regexp.Regexp, error) {
+	if s.Expect == "" {
+		return nil, nil
+	}
+	pattern := strings.ReplaceAll(s.Expect, VersionPlaceholder, regexp.QuoteMeta(version))
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smoke_test expect: %w", err)
+	}
+	return re, nil
+}
+
+// TimeoutDuration returns how long the command may run
+func (s *SmokeTest) TimeoutDuration() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultSmokeTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid smoke_test timeout %q: must be a positive duration such as 10s", s.Timeout)
+	}
+	return timeout, nil
+}
+
+// validateSmokeTest checks that a smoke test runs one of bins and that its expect and
+// timeout parse
+func validateSmokeTest(s *SmokeTest, bins []string) error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("smoke_test: command is required")
+	}
+	if s.Bin(bins) == "" {
+		return fmt.Errorf("smoke_test: command %q is not one of the package's bins", s.Command[0])
+	}
+	if _, err := s.Expected("0.0.0"); err != nil {
+		return err
+	}
+	_, err := s.TimeoutDuration()
+	return err
+}
@@ -0,0 +1,71 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSmokeTest(t *testing.T) {
+	test := &SmokeTest{Command: []string{"node", "--version"}, Expect: `^v{version}\s*$`}
+	if got := test.Bin([]string{"bin/npm", "bin/node"}); got != "bin/node" {
+		t.Errorf("Bin() = %q, want bin/node", got)
+	}
+
+	re, err := test.Expected("18.17.0")
+	if err != nil {
+		t.Fatalf("Expected() failed: %v", err)
+	}
+	if !re.MatchString("v18.17.0\n") || re.MatchString("v18a17a0\n") {
+		t.Errorf("Expected() = %s, want the version matched literally", re)
+	}
+
+	if timeout, err := test.TimeoutDuration(); err != nil || timeout != DefaultSmokeTimeout {
+		t.Errorf("TimeoutDuration() = %v, %v, want the default", timeout, err)
+	}
+	test.Timeout = "5s"
+	if timeout, err := test.TimeoutDuration(); err != nil || timeout != 5*time.Second {
+		t.Errorf("TimeoutDuration() = %v, %v, want 5s", timeout, err)
+	}
+}
+
+func TestValidateSmokeTest(t *testing.T) {
+	yamlData := `
+schema: 1
+name: node
+bins:
+  - bin/node
+smoke_test:
+  command: [node, --version]
+  expect: "^v{version}"
+  timeout: 10s
+versions:
+  "18.17.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/node.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+`
+
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for a smoke test: %v", err)
+	}
+
+	for name, test := range map[string]SmokeTest{
+		"no command":  {},
+		"unknown bin": {Command: []string{"npm", "--version"}},
+		"bad expect":  {Command: []string{"node"}, Expect: "("},
+		"bad timeout": {Command: []string{"node"}, Timeout: "soon"},
+		"no timeout":  {Command: []string{"node"}, Timeout: "0s"},
+	} {
+		m.SmokeTest = &test
+		if err := Validate(m); err == nil || !strings.Contains(err.Error(), "smoke_test") {
+			t.Errorf("Validate() with %s = %v, want a smoke_test error", name, err)
+		}
+	}
+}
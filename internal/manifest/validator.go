@@ -3,9 +3,27 @@ package manifest
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strings"
 )
 
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-_]{1,63}$`)
+
+var platformPattern = regexp.MustCompile(`^(linux|darwin|windows)-(amd64|arm64)$`)
+
+var checksumPattern = regexp.MustCompile(`^sha256:[a-fA-F0-9]{64}$`)
+
+var channelPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+var cpuFeaturePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._]*$`)
+
+var osMinPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var githubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
 // Validate validates a manifest with basic YAML validation rules
 func Validate(m *Manifest) error {
 	// Validate required fields
@@ -20,16 +38,20 @@ func Validate(m *Manifest) error {
 		return fmt.Errorf("missing required field: name")
 	}
 
+	// Package groups only list their members
+	if m.IsGroup() {
+		return validateGroup(m)
+	}
+
 	if len(m.Bins) == 0 {
 		return fmt.Errorf("missing required field: bins (at least one binary required)")
 	}
 
-	if len(m.Versions) == 0 {
+	if len(m.Versions) == 0 && len(m.Channels) == 0 {
 		return fmt.Errorf("missing required field: versions (at least one version required)")
 	}
 
 	// Validate name pattern
-	namePattern := regexp.MustCompile(`^[a-z0-9][a-z0-9-_]{1,63}$`)
 	if !namePattern.MatchString(m.Name) {
 		return fmt.Errorf("invalid package name: must match pattern ^[a-z0-9][a-z0-9-_]{1,63}$")
 	}
@@ -41,9 +63,21 @@ func Validate(m *Manifest) error {
 		}
 	}
 
+	// PATH is managed by nori
+	for key := range m.Env {
+		if !envNamePattern.MatchString(key) || strings.EqualFold(key, "PATH") {
+			return fmt.Errorf("invalid env name %q: must match pattern %s and not be PATH", key, envNamePattern)
+		}
+	}
+
+	if m.SmokeTest != nil {
+		if err := validateSmokeTest(m.SmokeTest, m.Bins); err != nil {
+			return err
+		}
+	}
+
 	// Validate version format and platform keys
 	versionPattern := regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
-	platformPattern := regexp.MustCompile(`^(linux|darwin|windows)-(amd64|arm64)$`)
 
 	for version, ver := range m.Versions {
 		if !versionPattern.MatchString(version) {
@@ -55,64 +89,252 @@ func Validate(m *Manifest) error {
 		}
 
 		for platform, asset := range ver.Platforms {
-			if !platformPattern.MatchString(platform) {
-				return fmt.Errorf("invalid platform %q: must match pattern (linux|darwin|windows)-(amd64|arm64)", platform)
+			if err := validateAsset(version, platform, asset, false); err != nil {
+				return err
 			}
+		}
+	}
 
-			// Validate asset type
-			if asset.Type != "tar" && asset.Type != "zip" {
-				return fmt.Errorf("invalid asset type %q for %s/%s: must be 'tar' or 'zip'", asset.Type, version, platform)
+	// Channels are named, not numbered, and may take their checksum from upstream
+	for channel, ver := range m.Channels {
+		if !channelPattern.MatchString(channel) {
+			return fmt.Errorf("invalid channel name %q: must match pattern %s", channel, channelPattern)
+		}
+		if len(ver.Platforms) == 0 {
+			return fmt.Errorf("channel %q has no platforms", channel)
+		}
+		for platform, asset := range ver.Platforms {
+			if err := validateAsset(channel, platform, asset, true); err != nil {
+				return err
 			}
+		}
+	}
 
-			// Validate URL is HTTPS
-			if asset.URL == "" {
-				return fmt.Errorf("missing URL for %s/%s", version, platform)
-			}
+	return nil
+}
 
-			u, err := url.Parse(asset.URL)
-			if err != nil {
-				return fmt.Errorf("invalid URL %q for %s/%s: %w", asset.URL, version, platform, err)
-			}
-			if u.Scheme != "https" {
-				return fmt.Errorf("URL must use HTTPS: %q for %s/%s", asset.URL, version, platform)
-			}
+// IsDigest reports whether s is an asset digest in sha256:hex form
+func IsDigest(s string) bool {
+	return checksumPattern.MatchString(s)
+}
 
-			// Validate checksum format
-			if asset.Checksum == "" {
-				return fmt.Errorf("missing checksum for %s/%s", version, platform)
-			}
+// validateAsset validates the asset of version, or channel, for platform. Channel assets may
+// omit the checksum when they name a checksums_url to look it up at install time.
+func validateAsset(version, platform string, asset Asset, channel bool) error {
+	if !platformPattern.MatchString(platform) {
+		return fmt.Errorf("invalid platform %q: must match pattern (linux|darwin|windows)-(amd64|arm64)", platform)
+	}
 
-			checksumPattern := regexp.MustCompile(`^sha256:[a-fA-F0-9]{64}$`)
-			if !checksumPattern.MatchString(asset.Checksum) {
-				return fmt.Errorf("invalid checksum format for %s/%s: must be sha256:hex (64 chars)", version, platform)
-			}
+	// Requirements, and the baseline build for machines that don't meet them
+	for _, feature := range asset.CPUFeatures {
+		if !cpuFeaturePattern.MatchString(feature) {
+			return fmt.Errorf("invalid cpu feature %q for %s/%s: must be a lowercase name such as avx2", feature, version, platform)
+		}
+	}
+	if asset.OSMin != "" && !osMinPattern.MatchString(asset.OSMin) {
+		return fmt.Errorf("invalid os_min %q for %s/%s: must be a dotted version such as 11.0", asset.OSMin, version, platform)
+	}
+	if asset.Baseline != nil {
+		if len(asset.CPUFeatures) == 0 && asset.OSMin == "" {
+			return fmt.Errorf("baseline for %s/%s is never used: the asset has no cpu_features or os_min", version, platform)
+		}
+		if err := validateAsset(version, platform, *asset.Baseline, channel); err != nil {
+			return fmt.Errorf("baseline: %w", err)
 		}
 	}
 
+	// Validate asset type
+	switch asset.Type {
+	case "tar", "zip":
+	case "deb", "rpm":
+		// Packages of Linux distributions
+		if !strings.HasPrefix(platform, "linux-") {
+			return fmt.Errorf("%s asset for %s/%s can only be installed on linux platforms", asset.Type, version, platform)
+		}
+	case "dmg", "pkg":
+		// Disk images and installer packages are opened with macOS's own tools
+		if !strings.HasPrefix(platform, "darwin-") {
+			return fmt.Errorf("%s asset for %s/%s can only be installed on darwin platforms", asset.Type, version, platform)
+		}
+	default:
+		return fmt.Errorf("invalid asset type %q for %s/%s: must be 'tar', 'zip', 'deb', 'rpm', 'dmg' or 'pkg'", asset.Type, version, platform)
+	}
+
+	// The payload digest covers the tar stream inside any compression
+	if asset.PayloadChecksum != "" {
+		switch {
+		case asset.Type != "tar":
+			return fmt.Errorf("payload_checksum for %s/%s only applies to tar assets", version, platform)
+		case channel:
+			return fmt.Errorf("payload_checksum for %s/%s can't be fixed for a channel, whose builds change", version, platform)
+		case !checksumPattern.MatchString(asset.PayloadChecksum):
+			return fmt.Errorf("invalid payload_checksum format for %s/%s: must be sha256:hex (64 chars)", version, platform)
+		}
+	}
+
+	if err := validateRelease(version, platform, asset); err != nil {
+		return err
+	}
+	if _, err := path.Match(asset.InnerPattern(version), ""); err != nil {
+		return fmt.Errorf("invalid inner %q for %s/%s: %w", asset.Inner, version, platform, err)
+	}
+	if inner := strings.ToLower(asset.Inner); (strings.HasSuffix(inner, ".dmg") || strings.HasSuffix(inner, ".pkg")) && !strings.HasPrefix(platform, "darwin-") {
+		return fmt.Errorf("inner %q for %s/%s can only be unwrapped on darwin platforms", asset.Inner, version, platform)
+	}
+
+	// Validate URL is HTTPS
+	if asset.URL == "" && asset.GitHub == "" {
+		return fmt.Errorf("missing URL for %s/%s", version, platform)
+	}
+
+	urls := asset.Mirrors
+	if asset.URL != "" {
+		urls = asset.URLs()
+	}
+	if asset.ChecksumsURL != "" {
+		urls = append(urls, asset.ChecksumsURL)
+	}
+	if asset.Provenance != "" {
+		urls = append(urls, asset.Provenance)
+	}
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q for %s/%s: %w", rawURL, version, platform, err)
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("URL must use HTTPS: %q for %s/%s", rawURL, version, platform)
+		}
+	}
+
+	// Validate checksum format
+	if asset.Checksum == "" {
+		if channel && asset.ChecksumsURL != "" {
+			return nil
+		}
+		return fmt.Errorf("missing checksum for %s/%s", version, platform)
+	}
+
+	if !checksumPattern.MatchString(asset.Checksum) {
+		return fmt.Errorf("invalid checksum format for %s/%s: must be sha256:hex (64 chars)", version, platform)
+	}
 	return nil
 }
 
-// ValidateVersion checks if a version exists for the given platform
+// validateRelease validates where an asset downloaded from GitHub releases is found
+func validateRelease(version, platform string, asset Asset) error {
+	if asset.GitHub == "" {
+		if asset.Tag != "" || asset.AssetPattern != "" {
+			return fmt.Errorf("tag and asset_pattern for %s/%s only apply to github assets", version, platform)
+		}
+		return nil
+	}
+	if asset.URL != "" {
+		return fmt.Errorf("asset for %s/%s has both a url and github: give one", version, platform)
+	}
+	if !githubRepoPattern.MatchString(asset.GitHub) {
+		return fmt.Errorf("invalid github %q for %s/%s: must be owner/repo", asset.GitHub, version, platform)
+	}
+	if asset.AssetPattern == "" {
+		return fmt.Errorf("missing asset_pattern for %s/%s, such as tool-{version}-linux-x64.tar.gz", version, platform)
+	}
+	if _, err := path.Match(asset.ReleaseAssetPattern(version), ""); err != nil {
+		return fmt.Errorf("invalid asset_pattern %q for %s/%s: %w", asset.AssetPattern, version, platform, err)
+	}
+	return nil
+}
+
+// validateGroup validates a package group manifest
+func validateGroup(m *Manifest) error {
+	if !namePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid package name: must match pattern ^[a-z0-9][a-z0-9-_]{1,63}$")
+	}
+
+	if len(m.Bins) > 0 || len(m.Versions) > 0 || len(m.Channels) > 0 {
+		return fmt.Errorf("package group %q must not declare bins, versions or channels", m.Name)
+	}
+
+	for member, version := range m.Members {
+		if !namePattern.MatchString(member) {
+			return fmt.Errorf("invalid group member name %q", member)
+		}
+		if member == m.Name {
+			return fmt.Errorf("package group %q cannot include itself", m.Name)
+		}
+		if _, err := ParseRange(version); err != nil {
+			return fmt.Errorf("invalid version for group member %q: %w", member, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVersion checks if a version, or channel, exists for the given platform
 func ValidateVersion(m *Manifest, version, platform string) error {
 	ver, ok := m.Versions[version]
+	if !ok {
+		ver, ok = m.Channels[version]
+	}
 	if !ok {
 		return fmt.Errorf("version %q not found for package %q", version, m.Name)
 	}
 
 	_, ok = ver.Platforms[platform]
 	if !ok {
-		return fmt.Errorf("platform %q not available for package %q version %q", platform, m.Name, version)
+		return &PlatformError{
+			Package:   m.Name,
+			Version:   version,
+			Platform:  platform,
+			Supported: m.PlatformsFor(version),
+			Versions:  m.VersionsFor(platform),
+		}
 	}
 
 	return nil
 }
 
+// PlatformError is returned when a version has no asset for the requested platform.
+// It lists what is available instead, so callers can suggest an alternative.
+type PlatformError struct {
+	Package   string
+	Version   string
+	Platform  string
+	Supported []string // platforms the version ships for, sorted
+	Versions  []string // versions that ship for Platform, in ascending semver order
+}
+
+// maxSuggestedVersions bounds how many alternative versions the error message names
+const maxSuggestedVersions = 5
+
+func (e *PlatformError) Error() string {
+	msg := fmt.Sprintf("platform %q not available for package %q version %q", e.Platform, e.Package, e.Version)
+	if len(e.Supported) > 0 {
+		msg += fmt.Sprintf(" (available for %s)", strings.Join(e.Supported, ", "))
+	}
+
+	if len(e.Versions) == 0 {
+		return msg + fmt.Sprintf("; no version of %s ships for %s", e.Package, e.Platform)
+	}
+	// Suggest the newest versions first
+	var suggested []string
+	for i := len(e.Versions) - 1; i >= 0 && len(suggested) < maxSuggestedVersions; i-- {
+		suggested = append(suggested, e.Versions[i])
+	}
+	if len(e.Versions) > len(suggested) {
+		suggested = append(suggested, fmt.Sprintf("and %d more", len(e.Versions)-len(suggested)))
+	}
+	return msg + fmt.Sprintf("; versions for %s: %s", e.Platform, strings.Join(suggested, ", "))
+}
+
 // GetAsset returns the asset for a specific version and platform
 func (m *Manifest) GetAsset(version, platform string) (*Asset, error) {
 	if err := ValidateVersion(m, version, platform); err != nil {
 		return nil, err
 	}
 
-	asset := m.Versions[version].Platforms[platform]
+	asset, ok := m.Versions[version].Platforms[platform]
+	if !ok {
+		asset = m.Channels[version].Platforms[platform]
+	}
 	return &asset, nil
 }
@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -156,6 +158,43 @@ versions:
 	}
 }
 
+func TestValidatePlatformAssetTypes(t *testing.T) {
+	for assetType, platforms := range map[string][2]string{
+		"dmg": {"darwin-arm64", "linux-amd64"},
+		"pkg": {"darwin-arm64", "linux-amd64"},
+		"deb": {"linux-amd64", "darwin-arm64"},
+		"rpm": {"linux-arm64", "windows-amd64"},
+	} {
+		yamlData := `
+schema: 1
+name: test
+bins:
+  - bin/test
+versions:
+  "1.0.0":
+    platforms:
+      ` + platforms[0] + `:
+        type: ` + assetType + `
+        url: https://example.com/test.` + assetType + `
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+`
+		
+		m, err := LoadFromBytes([]byte(yamlData))
+		if err != nil {
+			t.Fatalf("LoadFromBytes() failed: %v", err)
+		}
+		if err := Validate(m); err != nil {
+			t.Errorf("Validate() failed for a %s asset: %v", assetType, err)
+		}
+		
+		// Each is for the one OS
+		m.Versions["1.0.0"].Platforms[platforms[1]] = m.Versions["1.0.0"].Platforms[platforms[0]]
+		if err := Validate(m); err == nil {
+			t.Errorf("Validate() should fail for a %s asset for %s", assetType, platforms[1])
+		}
+	}
+}
+
 func TestValidateNonHTTPSURL(t *testing.T) {
 	yamlData := `
 schema: 1
@@ -181,6 +220,239 @@ versions:
 	}
 }
 
+func TestValidateMirrors(t *testing.T) {
+	yamlData := `
+schema: 1
+name: test
+bins:
+  - bin/test
+versions:
+  "1.0.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/test.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+        mirrors:
+          - https://mirror.example.org/test.tar.gz
+`
+	
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for HTTPS mirrors: %v", err)
+	}
+	
+	asset := m.Versions["1.0.0"].Platforms["linux-amd64"]
+	if urls := asset.URLs(); len(urls) != 2 || urls[1] != "https://mirror.example.org/test.tar.gz" {
+		t.Errorf("URLs() = %v, want primary then mirror", urls)
+	}
+	
+	asset.Mirrors = []string{"http://mirror.example.org/test.tar.gz"}
+	m.Versions["1.0.0"].Platforms["linux-amd64"] = asset
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should fail for a non-HTTPS mirror")
+	}
+}
+
+func TestValidateGitHubRelease(t *testing.T) {
+	yamlData := `
+schema: 1
+name: test
+bins:
+  - bin/test
+versions:
+  "1.2.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        github: example/test
+        asset_pattern: test-{version}-linux-*.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+`
+	
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for a GitHub release asset: %v", err)
+	}
+	
+	asset := m.Versions["1.2.0"].Platforms["linux-amd64"]
+	if tag, pattern := asset.ReleaseTag("1.2.0"), asset.ReleaseAssetPattern("1.2.0"); tag != "v1.2.0" || pattern != "test-1.2.0-linux-*.tar.gz" {
+		t.Errorf("ReleaseTag(), ReleaseAssetPattern() = %q, %q", tag, pattern)
+	}
+	asset.Tag = "release-{version}"
+	if tag := asset.ReleaseTag("1.2.0"); tag != "release-1.2.0" {
+		t.Errorf("ReleaseTag() with a tag template = %q", tag)
+	}
+	
+	for name, broken := range map[string]func(a *Asset){
+		"url and github":   func(a *Asset) { a.URL = "https://example.com/test.tar.gz" },
+		"invalid repo":     func(a *Asset) { a.GitHub = "https://github.com/example/test" },
+		"no pattern":       func(a *Asset) { a.AssetPattern = "" },
+		"invalid pattern":  func(a *Asset) { a.AssetPattern = "test-[.tar.gz" },
+		"invalid inner":    func(a *Asset) { a.Inner = "test-{version}-[.tar.gz" },
+		"inner disk image": func(a *Asset) { a.Inner = "Test-{version}.dmg" },
+		"tag without github": func(a *Asset) {
+			a.GitHub, a.URL = "", "https://example.com/test.tar.gz"
+		},
+	} {
+		a := asset
+		broken(&a)
+		m.Versions["1.2.0"].Platforms["linux-amd64"] = a
+		if err := Validate(m); err == nil {
+			t.Errorf("Validate() should fail for %s", name)
+		}
+	}
+}
+
+func TestValidateChannels(t *testing.T) {
+	yamlData := `
+schema: 1
+name: test
+bins:
+  - bin/test
+versions:
+  "1.0.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/test.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+channels:
+  nightly:
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/nightly/test.tar.gz
+        checksums_url: https://example.com/nightly/SHA256SUMS
+`
+	
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for a channel with a checksums_url: %v", err)
+	}
+	if !m.IsChannel("nightly") || m.IsChannel("1.0.0") {
+		t.Error("IsChannel() should only report channels")
+	}
+	if err := ValidateVersion(m, "nightly", "linux-amd64"); err != nil {
+		t.Errorf("ValidateVersion() failed for a channel: %v", err)
+	}
+	if asset, err := m.GetAsset("nightly", "linux-amd64"); err != nil || asset.ChecksumsURL == "" {
+		t.Errorf("GetAsset(nightly) = %+v, %v", asset, err)
+	}
+	
+	asset := m.Channels["nightly"].Platforms["linux-amd64"]
+	asset.ChecksumsURL = ""
+	m.Channels["nightly"].Platforms["linux-amd64"] = asset
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should fail for a channel asset with no checksum source")
+	}
+	
+	asset.ChecksumsURL = "https://example.com/nightly/SHA256SUMS"
+	m.Channels["nightly"].Platforms["linux-amd64"] = asset
+	m.Channels["Nightly Build"] = m.Channels["nightly"]
+	delete(m.Channels, "nightly")
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should fail for an invalid channel name")
+	}
+	
+	// Fixed versions still need a checksum of their own
+	ver := m.Versions["1.0.0"]
+	fixed := ver.Platforms["linux-amd64"]
+	fixed.Checksum = ""
+	fixed.ChecksumsURL = "https://example.com/SHA256SUMS"
+	ver.Platforms["linux-amd64"] = fixed
+	m.Channels = nil
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should require a checksum for fixed versions")
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	yamlData := `
+schema: 1
+name: go
+bins:
+  - bin/go
+env:
+  GOROOT: "{install}"
+  GOTOOLCHAIN: local
+versions:
+  "1.22.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/go.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+`
+	
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for a manifest with env: %v", err)
+	}
+	env := m.EnvFor("/opt/nori/installs/go/1.22.0/linux-amd64")
+	if env["GOROOT"] != "/opt/nori/installs/go/1.22.0/linux-amd64" || env["GOTOOLCHAIN"] != "local" {
+		t.Errorf("EnvFor() = %v, want {install} replaced", env)
+	}
+	
+	for _, name := range []string{"PATH", "Path", "GO ROOT", "1GO"} {
+		m.Env = map[string]string{name: "x"}
+		if err := Validate(m); err == nil {
+			t.Errorf("Validate() should reject env name %q", name)
+		}
+	}
+}
+
+func TestValidatePayloadChecksum(t *testing.T) {
+	yamlData := `
+schema: 1
+name: node
+bins:
+  - bin/node
+versions:
+  "18.17.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/node.tar.gz
+        checksum: sha256:abcd1234567890abcdef1234567890abcdef1234567890abcdef1234567890ef
+        payload_checksum: sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef
+`
+	
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for a payload checksum: %v", err)
+	}
+	
+	asset := m.Versions["18.17.0"].Platforms["linux-amd64"]
+	asset.PayloadChecksum = "md5:abc"
+	m.Versions["18.17.0"].Platforms["linux-amd64"] = asset
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should reject a malformed payload checksum")
+	}
+	
+	asset.PayloadChecksum = "sha256:1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"
+	asset.Type = "zip"
+	m.Versions["18.17.0"].Platforms["linux-amd64"] = asset
+	if err := Validate(m); err == nil {
+		t.Error("Validate() should reject a payload checksum on a zip asset")
+	}
+}
+
 func TestValidateInvalidChecksumFormat(t *testing.T) {
 	yamlData := `
 schema: 1
@@ -256,3 +528,111 @@ versions:
 	}
 }
 
+
+func TestValidateGroup(t *testing.T) {
+	yamlData := `
+schema: 1
+name: frontend-toolchain
+description: Node.js, pnpm and Deno
+members:
+  node: 22.2.0
+  pnpm: ^9.1
+  deno: ">=1.44 <2"
+`
+
+	m, err := LoadFromBytes([]byte(yamlData))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() failed: %v", err)
+	}
+
+	if !m.IsGroup() {
+		t.Fatal("IsGroup() = false, want true")
+	}
+	if err := Validate(m); err != nil {
+		t.Errorf("Validate() failed for valid group: %v", err)
+	}
+}
+
+func TestValidateGroupInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		yamlData string
+	}{
+		{"bins", `
+schema: 1
+name: toolchain
+bins:
+  - bin/node
+members:
+  node: 22.2.0
+`},
+		{"channels", `
+schema: 1
+name: toolchain
+channels:
+  nightly:
+    platforms:
+      linux-amd64:
+        url: https://example.com/nightly.tar.gz
+        checksum: sha256:0000000000000000000000000000000000000000000000000000000000000000
+        type: tar.gz
+members:
+  node: 22.2.0
+`},
+		{"member version", `
+schema: 1
+name: toolchain
+members:
+  node: nightly
+`},
+		{"self reference", `
+schema: 1
+name: toolchain
+members:
+  toolchain: 1.0.0
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := LoadFromBytes([]byte(tt.yamlData))
+			if err != nil {
+				t.Fatalf("LoadFromBytes() failed: %v", err)
+			}
+			if err := Validate(m); err == nil {
+				t.Error("Validate() should fail for invalid group")
+			}
+		})
+	}
+}
+
+func TestValidateVersionPlatformError(t *testing.T) {
+	linux := map[string]Asset{"linux-amd64": {}}
+	both := map[string]Asset{"linux-amd64": {}, "darwin-arm64": {}}
+	m := &Manifest{Name: "tool", Versions: map[string]Version{}}
+	for _, v := range []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0", "1.5.0"} {
+		m.Versions[v] = Version{Platforms: both}
+	}
+	m.Versions["2.0.0"] = Version{Platforms: linux}
+
+	err := ValidateVersion(m, "2.0.0", "darwin-arm64")
+	var platErr *PlatformError
+	if !errors.As(err, &platErr) {
+		t.Fatalf("ValidateVersion() = %v, want a *PlatformError", err)
+	}
+	if got := strings.Join(platErr.Supported, " "); got != "linux-amd64" {
+		t.Errorf("Supported = %q, want linux-amd64", got)
+	}
+	if len(platErr.Versions) != 6 || platErr.Versions[5] != "1.5.0" {
+		t.Errorf("Versions = %v, want 1.0.0 through 1.5.0", platErr.Versions)
+	}
+	want := `platform "darwin-arm64" not available for package "tool" version "2.0.0" (available for linux-amd64); versions for darwin-arm64: 1.5.0, 1.4.0, 1.3.0, 1.2.0, 1.1.0, and 1 more`
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	err = ValidateVersion(m, "2.0.0", "windows-amd64")
+	if err == nil || !strings.HasSuffix(err.Error(), `no version of tool ships for windows-amd64`) {
+		t.Errorf("ValidateVersion() = %v, want it to say no version ships for windows-amd64", err)
+	}
+}
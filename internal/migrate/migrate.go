@@ -0,0 +1,125 @@
+// Package migrate keeps the on-disk formats of nori's registry cache and
+// configuration in step with the running nori. Each directory records its format
+// in a marker file; older formats are migrated, or rebuilt when they are only a cache.
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+// Current formats. Bump one when its files change incompatibly, and for the config
+// add a migration from the previous format.
+const (
+	RegistryFormat = 1
+	ConfigFormat   = 1
+)
+
+// MarkerName is the file recording a directory's format
+const MarkerName = ".format"
+
+// Migration upgrades the config from one format to the next
+type Migration func(paths platform.Paths) error
+
+// configMigrations maps a config format to the migration that upgrades it by one
+var configMigrations = map[int]Migration{}
+
+// Run brings the registry cache and config under paths to the current formats,
+// returning notes for the user about anything it changed. Directories that do not
+// exist yet are left alone; those written before formats were recorded are format 1.
+func Run(paths platform.Paths) ([]string, error) {
+	var notes []string
+
+	// The registry is a cache, so any other format is simply discarded and refetched
+	registryDir := paths.RegistryDir()
+	if format, ok := readFormat(registryDir); ok && format != RegistryFormat {
+		if err := clearRegistry(paths); err != nil {
+			return nil, fmt.Errorf("failed to clear registry cache: %w", err)
+		}
+		notes = append(notes, fmt.Sprintf("registry cache was in format %d, this nori uses %d; cleared it (run `nori update` to refetch)", format, RegistryFormat))
+	}
+	if err := writeFormat(registryDir, RegistryFormat); err != nil {
+		return nil, err
+	}
+
+	// The config holds user choices, so it is migrated step by step and never downgraded
+	configDir := paths.ConfigDir()
+	if format, ok := readFormat(configDir); ok && format != ConfigFormat {
+		if format > ConfigFormat {
+			return nil, fmt.Errorf("config in %s was written by a newer nori (format %d, this nori understands %d); upgrade nori", configDir, format, ConfigFormat)
+		}
+		for ; format < ConfigFormat; format++ {
+			migration, ok := configMigrations[format]
+			if !ok {
+				return nil, fmt.Errorf("no migration for config format %d", format)
+			}
+			if err := migration(paths); err != nil {
+				return nil, fmt.Errorf("failed to migrate config from format %d: %w", format, err)
+			}
+			// Record progress so an interrupted migration resumes where it stopped
+			if err := writeFormat(configDir, format+1); err != nil {
+				return nil, err
+			}
+		}
+		notes = append(notes, fmt.Sprintf("migrated config to format %d", ConfigFormat))
+	}
+	if err := writeFormat(configDir, ConfigFormat); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// readFormat returns the format recorded in dir. ok is false if dir does not exist.
+// A directory without a marker predates format markers and is format 1.
+func readFormat(dir string) (format int, ok bool) {
+	if _, err := os.Stat(dir); err != nil {
+		return 0, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, MarkerName))
+	if err != nil {
+		return 1, true
+	}
+	format, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		// An unreadable marker is treated as an unknown, old format
+		return 0, true
+	}
+	return format, true
+}
+
+// writeFormat records format in dir, if dir exists and does not already record it
+func writeFormat(dir string, format int) error {
+	if _, err := os.Stat(dir); err != nil {
+		return nil
+	}
+	marker := filepath.Join(dir, MarkerName)
+	if data, err := os.ReadFile(marker); err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(format) {
+		return nil
+	}
+	if err := fsutil.WriteFileAtomic(marker, []byte(strconv.Itoa(format)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record format of %s: %w", dir, err)
+	}
+	return nil
+}
+
+// clearRegistry removes every cached registry file, keeping the directory itself
+func clearRegistry(paths platform.Paths) error {
+	entries, err := os.ReadDir(paths.RegistryDir())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(paths.RegistryDir(), entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestRunFreshRoot(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+
+	notes, err := Run(paths)
+	if err != nil || len(notes) != 0 {
+		t.Fatalf("Run() = %v, %v, want nothing to do", notes, err)
+	}
+	if _, err := os.Stat(paths.RegistryDir()); !os.IsNotExist(err) {
+		t.Error("Run() should not create the registry directory")
+	}
+}
+
+func TestRunMarksLegacyDirs(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	os.MkdirAll(paths.RegistryDir(), 0755)
+	os.MkdirAll(paths.ConfigDir(), 0755)
+	os.WriteFile(paths.IndexPath(), []byte("packages: []\n"), 0644)
+	os.WriteFile(paths.ActiveConfigPath(), []byte("node: 22.2.0\n"), 0644)
+
+	notes, err := Run(paths)
+	if err != nil || len(notes) != 0 {
+		t.Fatalf("Run() = %v, %v, want unmarked dirs treated as format 1", notes, err)
+	}
+
+	for _, dir := range []string{paths.RegistryDir(), paths.ConfigDir()} {
+		data, err := os.ReadFile(filepath.Join(dir, MarkerName))
+		if err != nil || strings.TrimSpace(string(data)) != "1" {
+			t.Errorf("marker in %s = %q, %v, want 1", dir, data, err)
+		}
+	}
+	if _, err := os.Stat(paths.IndexPath()); err != nil {
+		t.Error("Run() should keep a registry cache in the current format")
+	}
+}
+
+func TestRunClearsOtherRegistryFormats(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	os.MkdirAll(filepath.Join(paths.RegistryDir(), "packages"), 0755)
+	os.WriteFile(paths.IndexPath(), []byte("packages: []\n"), 0644)
+	os.WriteFile(paths.PackageManifestPath("node"), []byte("schema: 9\n"), 0644)
+	os.WriteFile(filepath.Join(paths.RegistryDir(), MarkerName), []byte("9\n"), 0644)
+
+	notes, err := Run(paths)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "format 9") {
+		t.Errorf("Run() notes = %v, want the registry cache clearing reported", notes)
+	}
+	if _, err := os.Stat(paths.IndexPath()); !os.IsNotExist(err) {
+		t.Error("Run() should clear a registry cache in another format")
+	}
+	if _, err := os.Stat(paths.PackageManifestPath("node")); !os.IsNotExist(err) {
+		t.Error("Run() should clear cached manifests in another format")
+	}
+}
+
+func TestRunMigratesConfig(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	os.MkdirAll(paths.ConfigDir(), 0755)
+	os.WriteFile(filepath.Join(paths.ConfigDir(), MarkerName), []byte("0\n"), 0644)
+
+	var ran []int
+	configMigrations = map[int]Migration{
+		0: func(platform.Paths) error { ran = append(ran, 0); return nil },
+	}
+	t.Cleanup(func() { configMigrations = map[int]Migration{} })
+
+	notes, err := Run(paths)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(ran) != 1 || len(notes) != 1 {
+		t.Errorf("Run() ran migrations %v with notes %v, want the 0 -> 1 migration", ran, notes)
+	}
+
+	// Config written by a newer nori is left untouched
+	os.WriteFile(filepath.Join(paths.ConfigDir(), MarkerName), []byte("7\n"), 0644)
+	if _, err := Run(paths); err == nil || !strings.Contains(err.Error(), "newer nori") {
+		t.Errorf("Run() error = %v, want a newer-format error", err)
+	}
+}
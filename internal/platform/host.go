@@ -0,0 +1,72 @@
+package platform
+
+import (
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+)
+
+// CPUFeatures returns the instruction set extensions of this CPU that manifests can
+// require, keyed by the lowercase names used in cpu_features
+func CPUFeatures() map[string]bool {
+	switch runtime.GOARCH {
+	case "amd64":
+		return map[string]bool{
+			"sse2":     cpu.X86.HasSSE2,
+			"sse3":     cpu.X86.HasSSE3,
+			"ssse3":    cpu.X86.HasSSSE3,
+			"sse4.1":   cpu.X86.HasSSE41,
+			"sse4.2":   cpu.X86.HasSSE42,
+			"popcnt":   cpu.X86.HasPOPCNT,
+			"cx16":     cpu.X86.HasCX16,
+			"aes":      cpu.X86.HasAES,
+			"pclmul":   cpu.X86.HasPCLMULQDQ,
+			"avx":      cpu.X86.HasAVX,
+			"avx2":     cpu.X86.HasAVX2,
+			"fma":      cpu.X86.HasFMA,
+			"bmi1":     cpu.X86.HasBMI1,
+			"bmi2":     cpu.X86.HasBMI2,
+			"adx":      cpu.X86.HasADX,
+			"avx512f":  cpu.X86.HasAVX512F,
+			"avx512bw": cpu.X86.HasAVX512BW,
+			"avx512cd": cpu.X86.HasAVX512CD,
+			"avx512dq": cpu.X86.HasAVX512DQ,
+			"avx512vl": cpu.X86.HasAVX512VL,
+		}
+	case "arm64":
+		return map[string]bool{
+			"asimd":   cpu.ARM64.HasASIMD,
+			"aes":     cpu.ARM64.HasAES,
+			"pmull":   cpu.ARM64.HasPMULL,
+			"sha1":    cpu.ARM64.HasSHA1,
+			"sha2":    cpu.ARM64.HasSHA2,
+			"sha3":    cpu.ARM64.HasSHA3,
+			"sha512":  cpu.ARM64.HasSHA512,
+			"crc32":   cpu.ARM64.HasCRC32,
+			"atomics": cpu.ARM64.HasATOMICS,
+			"dotprod": cpu.ARM64.HasASIMDDP,
+			"i8mm":    cpu.ARM64.HasI8MM,
+			"sve":     cpu.ARM64.HasSVE,
+			"sve2":    cpu.ARM64.HasSVE2,
+		}
+	}
+	return map[string]bool{}
+}
+
+// OSVersion returns the release of the running OS that manifests compare with os_min:
+// the macOS product version such as 14.2, the Windows build such as 10.0.22631, or the
+// Linux kernel release such as 6.1.0. It returns "" if the release can't be determined.
+func OSVersion() string {
+	return leadingVersion(osRelease())
+}
+
+// leadingVersion returns the dotted number at the start of release, e.g. 6.1.0 of
+// 6.1.0-13-amd64
+func leadingVersion(release string) string {
+	end := 0
+	for end < len(release) && (release[end] == '.' || release[end] >= '0' && release[end] <= '9') {
+		end++
+	}
+	return strings.Trim(release[:end], ".")
+}
@@ -0,0 +1,12 @@
+package platform
+
+import "golang.org/x/sys/unix"
+
+// osRelease returns the macOS product version
+func osRelease() string {
+	version, err := unix.Sysctl("kern.osproductversion")
+	if err != nil {
+		return ""
+	}
+	return version
+}
@@ -0,0 +1,12 @@
+package platform
+
+import "golang.org/x/sys/unix"
+
+// osRelease returns the kernel release
+func osRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
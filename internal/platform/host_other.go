@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package platform
+
+// osRelease is unknown on other systems, so os_min is not checked there
+func osRelease() string {
+	return ""
+}
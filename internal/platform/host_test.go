@@ -0,0 +1,34 @@
+package platform
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCPUFeatures(t *testing.T) {
+	features := CPUFeatures()
+	switch runtime.GOARCH {
+	case "amd64":
+		if !features["sse2"] {
+			t.Error("CPUFeatures() should report sse2, which every amd64 CPU has")
+		}
+	case "arm64":
+		if !features["asimd"] {
+			t.Error("CPUFeatures() should report asimd, which every arm64 CPU has")
+		}
+	}
+}
+
+func TestLeadingVersion(t *testing.T) {
+	tests := map[string]string{
+		"6.1.0-13-amd64":            "6.1.0",
+		"5.15.153.1-microsoft-WSL2": "5.15.153.1",
+		"14.2.1":                    "14.2.1",
+		"unknown":                   "",
+	}
+	for release, want := range tests {
+		if got := leadingVersion(release); got != want {
+			t.Errorf("leadingVersion(%q) = %q, want %q", release, got, want)
+		}
+	}
+}
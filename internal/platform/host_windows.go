@@ -0,0 +1,13 @@
+package platform
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// osRelease returns the Windows version and build
+func osRelease() string {
+	info := windows.RtlGetVersion()
+	return fmt.Sprintf("%d.%d.%d", info.MajorVersion, info.MinorVersion, info.BuildNumber)
+}
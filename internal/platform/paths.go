@@ -1,57 +1,248 @@
 package platform
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// NoriRoot returns the root directory for nori (~/.nori)
-func NoriRoot() string {
-	home, err := os.UserHomeDir()
+// Paths resolves every location nori uses beneath a single root directory
+type Paths struct {
+	Root string
+
+	// Shims overrides the shims directory, e.g. with a machine-wide location
+	Shims string
+
+	// System is the machine-wide configuration directory, whose active.yaml sets default
+	// versions beneath the user's own; "" leaves them out
+	System string
+}
+
+// NewPaths creates paths rooted at root
+func NewPaths(root string) Paths {
+	return Paths{Root: root}
+}
+
+// DefaultPaths creates paths rooted at ResolveRoot
+func DefaultPaths() (Paths, error) {
+	root, err := ResolveRoot()
 	if err != nil {
-		// Fallback to current directory if home is unavailable
-		return ".nori"
+		return Paths{}, err
 	}
-	return filepath.Join(home, ".nori")
+	paths := NewPaths(root)
+	paths.System = SystemConfigDir()
+	return paths, nil
+}
+
+// resolvedRoots caches ResolveRoot by the absolute root it resolved
+var resolvedRoots sync.Map
+
+// ResolveRoot returns the nori root, $NORI_ROOT or else defaultRoot, as an absolute
+// path with symlinks resolved. A home directory that is a symlink to another device
+// would otherwise put the root's files on both sides of renames that can't cross it.
+// The root itself need not exist yet.
+func ResolveRoot() (string, error) {
+	root := os.Getenv("NORI_ROOT")
+	if root == "" {
+		var err error
+		if root, err = defaultRoot(); err != nil {
+			return "", err
+		}
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the nori root %s: %w", root, err)
+	}
+	if resolved, ok := resolvedRoots.Load(abs); ok {
+		return resolved.(string), nil
+	}
+
+	resolved, err := resolveExisting(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the nori root %s: %w", root, err)
+	}
+	resolvedRoots.Store(abs, resolved)
+	return resolved, nil
+}
+
+// defaultRoot returns the nori root when $NORI_ROOT isn't set: ~/.nori, or else nori in
+// the user config directory, which services and containers without $HOME may still have
+// through $XDG_CONFIG_HOME. Relative directories don't count, since they would scatter
+// installs across whatever directories nori runs in.
+func defaultRoot() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil && filepath.IsAbs(home) {
+		return filepath.Join(home, ".nori"), nil
+	}
+	if dir, err := os.UserConfigDir(); err == nil && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "nori"), nil
+	}
+	return "", fmt.Errorf("failed to find a home or user config directory for the nori root, as happens for services and containers without $HOME; set NORI_ROOT to where nori should keep its installs, such as /var/lib/nori")
+}
+
+// resolveExisting resolves the symlinks in as much of the absolute path abs as exists
+func resolveExisting(abs string) (string, error) {
+	existing, missing := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+}
+
+// DefaultRootMode is the permission mode of a newly created nori root, which keeps
+// other users from reading or tampering with installs
+const DefaultRootMode os.FileMode = 0700
+
+// RootPerm returns the permission mode for a newly created nori root: $NORI_ROOT_MODE,
+// an octal mode such as 0755 for roots shared between users, or DefaultRootMode
+func RootPerm() (os.FileMode, error) {
+	value := os.Getenv("NORI_ROOT_MODE")
+	if value == "" {
+		return DefaultRootMode, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid NORI_ROOT_MODE %q: want an octal mode such as 0700", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// SystemShimsDir returns the machine-wide shims directory, %ProgramData%\nori\shims.
+// It is only meaningful on Windows.
+func SystemShimsDir() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "nori", "shims")
+}
+
+// SystemConfigDir returns the machine-wide configuration directory: $NORI_SYSTEM_CONFIG_DIR,
+// or else /etc/nori, or %ProgramData%\nori on Windows
+func SystemConfigDir() string {
+	if dir := os.Getenv("NORI_SYSTEM_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Dir(SystemShimsDir())
+	}
+	return "/etc/nori"
 }
 
 // InstallsDir returns the directory where packages are installed
-func InstallsDir() string {
-	return filepath.Join(NoriRoot(), "installs")
+func (p Paths) InstallsDir() string {
+	return filepath.Join(p.Root, "installs")
 }
 
 // ShimsDir returns the directory where shims are created
-func ShimsDir() string {
-	return filepath.Join(NoriRoot(), "shims")
+func (p Paths) ShimsDir() string {
+	if p.Shims != "" {
+		return p.Shims
+	}
+	return filepath.Join(p.Root, "shims")
 }
 
 // RegistryDir returns the directory where registry data is cached
-func RegistryDir() string {
-	return filepath.Join(NoriRoot(), "registry")
+func (p Paths) RegistryDir() string {
+	return filepath.Join(p.Root, "registry")
 }
 
 // ConfigDir returns the directory where configuration files are stored
-func ConfigDir() string {
-	return filepath.Join(NoriRoot(), "config")
+func (p Paths) ConfigDir() string {
+	return filepath.Join(p.Root, "config")
+}
+
+// CacheDir returns the directory where derived, disposable data is cached
+func (p Paths) CacheDir() string {
+	return filepath.Join(p.Root, "cache")
+}
+
+// TmpDir returns the directory where archives are staged while they are extracted.
+// It sits beside the installs so that staged files can be renamed into place.
+func (p Paths) TmpDir() string {
+	return filepath.Join(p.Root, "tmp")
+}
+
+// DaemonSocket returns the socket `nori daemon` serves its JSON-RPC API on
+func (p Paths) DaemonSocket() string {
+	return filepath.Join(p.Root, "daemon.sock")
 }
 
 // InstallPath returns the full path for a package installation
-func InstallPath(pkg, version, platform string) string {
-	return filepath.Join(InstallsDir(), pkg, version, platform)
+func (p Paths) InstallPath(pkg, version, platform string) string {
+	return filepath.Join(p.PackageDir(pkg), version, platform)
+}
+
+// PackageDir returns the directory holding every installed version of pkg. A package
+// from a registry with a prefix, such as corp/tooling, is kept under @corp/tooling.
+func (p Paths) PackageDir(pkg string) string {
+	if prefix, name, ok := strings.Cut(pkg, "/"); ok {
+		return filepath.Join(p.InstallsDir(), "@"+prefix, name)
+	}
+	return filepath.Join(p.InstallsDir(), pkg)
 }
 
 // PackageManifestPath returns the path to a cached package manifest
-func PackageManifestPath(pkg string) string {
-	return filepath.Join(RegistryDir(), "packages", pkg+".yaml")
+func (p Paths) PackageManifestPath(pkg string) string {
+	return filepath.Join(p.RegistryDir(), "packages", pkg+".yaml")
 }
 
 // IndexPath returns the path to the cached registry index
-func IndexPath() string {
-	return filepath.Join(RegistryDir(), "index.yaml")
+func (p Paths) IndexPath() string {
+	return filepath.Join(p.RegistryDir(), "index.yaml")
+}
+
+// SearchIndexPath returns the path to the search index built from the cached manifests
+func (p Paths) SearchIndexPath() string {
+	return filepath.Join(p.RegistryDir(), "search.yaml")
 }
 
 // ActiveConfigPath returns the path to the active versions configuration
-func ActiveConfigPath() string {
-	return filepath.Join(ConfigDir(), "active.yaml")
+func (p Paths) ActiveConfigPath() string {
+	return filepath.Join(p.ConfigDir(), "active.yaml")
+}
+
+// SystemActiveConfigPath returns the path to the machine-wide default versions, or ""
+// if the paths have no system configuration directory
+func (p Paths) SystemActiveConfigPath() string {
+	if p.System == "" {
+		return ""
+	}
+	return filepath.Join(p.System, "active.yaml")
 }
 
+// SettingsPath returns the path to the user settings file
+func (p Paths) SettingsPath() string {
+	return filepath.Join(p.ConfigDir(), "config.yaml")
+}
+
+// StatePath returns the path to the index of installed packages
+func (p Paths) StatePath() string {
+	return filepath.Join(p.Root, "state.yaml")
+}
+
+// ArchiveDir returns the directory of the archive cache, which holds downloaded archives
+// by checksum
+func (p Paths) ArchiveDir() string {
+	return filepath.Join(p.CacheDir(), "sha256")
+}
+
+// ArchivePath returns where a downloaded archive is kept, keyed by its sha256:hex checksum.
+// Manifests may spell the digest in either case; both name the same file.
+func (p Paths) ArchivePath(checksum string) string {
+	return filepath.Join(p.ArchiveDir(), strings.ToLower(strings.TrimPrefix(checksum, "sha256:")))
+}
@@ -3,75 +3,219 @@ package platform
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
-func TestNoriRoot(t *testing.T) {
-	got := NoriRoot()
-	
+// testRoot is the root used by the path layout tests
+var testRoot = filepath.Join("home", "user", ".nori")
+
+func TestDefaultPaths(t *testing.T) {
+	t.Setenv("NORI_ROOT", "")
+	p, err := DefaultPaths()
+	if err != nil {
+		t.Fatalf("DefaultPaths() failed: %v", err)
+	}
+	got := p.Root
+
 	// Should be ~/.nori
 	home, err := os.UserHomeDir()
 	if err != nil {
 		t.Fatalf("os.UserHomeDir() failed: %v", err)
 	}
-	
+
 	want := filepath.Join(home, ".nori")
 	if got != want {
-		t.Errorf("NoriRoot() = %q, want %q", got, want)
+		t.Errorf("DefaultPaths().Root = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultPathsFromEnv(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("NORI_ROOT", root)
+
+	p, err := DefaultPaths()
+	if err != nil || p.Root != root {
+		t.Errorf("DefaultPaths() = %q, %v, want %q", p.Root, err, root)
+	}
+	if got, want := p.ShimsDir(), filepath.Join(root, "shims"); got != want {
+		t.Errorf("ShimsDir() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveRoot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "real"), 0755)
+	os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "home"))
+
+	// Symlinks are resolved in as much of the root as exists
+	t.Setenv("NORI_ROOT", filepath.Join(dir, "home", ".nori"))
+	if got, err := DefaultPaths(); err != nil || got.Root != filepath.Join(dir, "real", ".nori") {
+		t.Errorf("DefaultPaths() = %q, %v, want %q", got.Root, err, filepath.Join(dir, "real", ".nori"))
+	}
+
+	// Relative roots are made absolute
+	t.Chdir(dir)
+	t.Setenv("NORI_ROOT", "relative")
+	if got, err := ResolveRoot(); err != nil || got != filepath.Join(dir, "relative") {
+		t.Errorf("ResolveRoot() = %q, %v, want %q", got, err, filepath.Join(dir, "relative"))
+	}
+
+	// Without a home directory there is no default root to fall back to
+	t.Setenv("NORI_ROOT", "")
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if got, err := ResolveRoot(); err == nil || !strings.Contains(err.Error(), "set NORI_ROOT") {
+		t.Errorf("ResolveRoot() without a home = %q, %v, want an error", got, err)
+	}
+	if got, err := DefaultPaths(); err == nil {
+		t.Errorf("DefaultPaths() without a home = %q, want an error", got.Root)
+	}
+
+	// Nor is a relative home, which would put the root wherever nori runs
+	t.Setenv("HOME", ".")
+	if got, err := ResolveRoot(); err == nil {
+		t.Errorf("ResolveRoot() with a relative home = %q, want an error", got)
+	}
+}
+
+func TestResolveRootConfigDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user config directory only outlives $HOME through $XDG_CONFIG_HOME")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NORI_ROOT", "")
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if got, err := ResolveRoot(); err != nil || got != filepath.Join(dir, "nori") {
+		t.Errorf("ResolveRoot() without a home = %q, %v, want %q", got, err, filepath.Join(dir, "nori"))
+	}
+}
+
+func TestRootPerm(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"", DefaultRootMode, false},
+		{"0755", 0755, false},
+		{"750", 0750, false},
+		{"rwx", 0, true},
+		{"01777", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("NORI_ROOT_MODE", tt.value)
+		got, err := RootPerm()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("RootPerm() for %q = %v, %v, want %v (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
 	}
 }
 
 func TestInstallsDir(t *testing.T) {
-	got := InstallsDir()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "installs")
+	got := NewPaths(testRoot).InstallsDir()
+	want := filepath.Join(testRoot, "installs")
 	if got != want {
 		t.Errorf("InstallsDir() = %q, want %q", got, want)
 	}
 }
 
 func TestShimsDir(t *testing.T) {
-	got := ShimsDir()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "shims")
+	got := NewPaths(testRoot).ShimsDir()
+	want := filepath.Join(testRoot, "shims")
 	if got != want {
 		t.Errorf("ShimsDir() = %q, want %q", got, want)
 	}
 }
 
+func TestShimsDirOverride(t *testing.T) {
+	p := NewPaths(testRoot)
+	p.Shims = filepath.Join("programdata", "nori", "shims")
+
+	if got := p.ShimsDir(); got != p.Shims {
+		t.Errorf("ShimsDir() = %q, want %q", got, p.Shims)
+	}
+}
+
+func TestSystemShimsDir(t *testing.T) {
+	programData := filepath.Join("programdata")
+	t.Setenv("ProgramData", programData)
+
+	got := SystemShimsDir()
+	want := filepath.Join(programData, "nori", "shims")
+	if got != want {
+		t.Errorf("SystemShimsDir() = %q, want %q", got, want)
+	}
+}
+
 func TestRegistryDir(t *testing.T) {
-	got := RegistryDir()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "registry")
+	got := NewPaths(testRoot).RegistryDir()
+	want := filepath.Join(testRoot, "registry")
 	if got != want {
 		t.Errorf("RegistryDir() = %q, want %q", got, want)
 	}
 }
 
 func TestConfigDir(t *testing.T) {
-	got := ConfigDir()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "config")
+	got := NewPaths(testRoot).ConfigDir()
+	want := filepath.Join(testRoot, "config")
 	if got != want {
 		t.Errorf("ConfigDir() = %q, want %q", got, want)
 	}
 }
 
+func TestCacheDir(t *testing.T) {
+	got := NewPaths(testRoot).CacheDir()
+	want := filepath.Join(testRoot, "cache")
+	if got != want {
+		t.Errorf("CacheDir() = %q, want %q", got, want)
+	}
+}
+
+func TestTmpDir(t *testing.T) {
+	got := NewPaths(testRoot).TmpDir()
+	want := filepath.Join(testRoot, "tmp")
+	if got != want {
+		t.Errorf("TmpDir() = %q, want %q", got, want)
+	}
+}
+
+func TestStatePath(t *testing.T) {
+	got := NewPaths(testRoot).StatePath()
+	want := filepath.Join(testRoot, "state.yaml")
+	if got != want {
+		t.Errorf("StatePath() = %q, want %q", got, want)
+	}
+}
+
 func TestInstallPath(t *testing.T) {
+	p := NewPaths(testRoot)
 	tests := []struct {
 		pkg      string
 		version  string
 		platform string
 		want     string
 	}{
-		{"node", "22.2.0", "linux-amd64", filepath.Join(NoriRoot(), "installs", "node", "22.2.0", "linux-amd64")},
-		{"python", "3.12.0", "darwin-arm64", filepath.Join(NoriRoot(), "installs", "python", "3.12.0", "darwin-arm64")},
-		{"deno", "2.0.0", "windows-amd64", filepath.Join(NoriRoot(), "installs", "deno", "2.0.0", "windows-amd64")},
+		{"node", "22.2.0", "linux-amd64", filepath.Join(testRoot, "installs", "node", "22.2.0", "linux-amd64")},
+		{"python", "3.12.0", "darwin-arm64", filepath.Join(testRoot, "installs", "python", "3.12.0", "darwin-arm64")},
+		{"deno", "2.0.0", "windows-amd64", filepath.Join(testRoot, "installs", "deno", "2.0.0", "windows-amd64")},
+		{"corp/tooling", "1.0.0", "linux-amd64", filepath.Join(testRoot, "installs", "@corp", "tooling", "1.0.0", "linux-amd64")},
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.pkg+"-"+tt.version+"-"+tt.platform, func(t *testing.T) {
-			got := InstallPath(tt.pkg, tt.version, tt.platform)
+			got := p.InstallPath(tt.pkg, tt.version, tt.platform)
 			if got != tt.want {
 				t.Errorf("InstallPath(%q, %q, %q) = %q, want %q", tt.pkg, tt.version, tt.platform, got, tt.want)
 			}
@@ -80,42 +224,73 @@ func TestInstallPath(t *testing.T) {
 }
 
 func TestPackageManifestPath(t *testing.T) {
-	got := PackageManifestPath("node")
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "registry", "packages", "node.yaml")
+	got := NewPaths(testRoot).PackageManifestPath("node")
+	want := filepath.Join(testRoot, "registry", "packages", "node.yaml")
 	if got != want {
 		t.Errorf("PackageManifestPath(%q) = %q, want %q", "node", got, want)
 	}
 }
 
 func TestIndexPath(t *testing.T) {
-	got := IndexPath()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "registry", "index.yaml")
+	got := NewPaths(testRoot).IndexPath()
+	want := filepath.Join(testRoot, "registry", "index.yaml")
 	if got != want {
 		t.Errorf("IndexPath() = %q, want %q", got, want)
 	}
 }
 
+func TestSearchIndexPath(t *testing.T) {
+	got := NewPaths(testRoot).SearchIndexPath()
+	want := filepath.Join(testRoot, "registry", "search.yaml")
+	if got != want {
+		t.Errorf("SearchIndexPath() = %q, want %q", got, want)
+	}
+}
+
 func TestActiveConfigPath(t *testing.T) {
-	got := ActiveConfigPath()
-	home, _ := os.UserHomeDir()
-	want := filepath.Join(home, ".nori", "config", "active.yaml")
+	got := NewPaths(testRoot).ActiveConfigPath()
+	want := filepath.Join(testRoot, "config", "active.yaml")
 	if got != want {
 		t.Errorf("ActiveConfigPath() = %q, want %q", got, want)
 	}
 }
 
+func TestSettingsPath(t *testing.T) {
+	got := NewPaths(testRoot).SettingsPath()
+	want := filepath.Join(testRoot, "config", "config.yaml")
+	if got != want {
+		t.Errorf("SettingsPath() = %q, want %q", got, want)
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	got := NewPaths(testRoot).ArchivePath("sha256:abc123")
+	want := filepath.Join(testRoot, "cache", "sha256", "abc123")
+	if got != want {
+		t.Errorf("ArchivePath() = %q, want %q", got, want)
+	}
+	if upper := NewPaths(testRoot).ArchivePath("sha256:ABC123"); upper != want {
+		t.Errorf("ArchivePath(upper case) = %q, want %q", upper, want)
+	}
+	if dir := NewPaths(testRoot).ArchiveDir(); dir != filepath.Dir(want) {
+		t.Errorf("ArchiveDir() = %q, want %q", dir, filepath.Dir(want))
+	}
+}
+
 // Test that paths use correct separators for the OS
 func TestPathSeparators(t *testing.T) {
+	p, err := DefaultPaths()
+	if err != nil {
+		t.Fatalf("DefaultPaths() failed: %v", err)
+	}
 	paths := []string{
-		NoriRoot(),
-		InstallsDir(),
-		ShimsDir(),
-		RegistryDir(),
-		ConfigDir(),
+		p.Root,
+		p.InstallsDir(),
+		p.ShimsDir(),
+		p.RegistryDir(),
+		p.ConfigDir(),
 	}
-	
+
 	for _, path := range paths {
 		// Just verify it's a valid path - filepath.Join handles separators
 		if path == "" {
@@ -125,4 +300,3 @@ func TestPathSeparators(t *testing.T) {
 		// But filepath.Join handles this, so we just verify it's not empty
 	}
 }
-
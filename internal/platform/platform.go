@@ -8,6 +8,10 @@ type Platform struct {
 	Arch string
 }
 
+// Common lists the platforms most users run on. Registry updates warn about
+// packages whose latest version does not ship for one of them.
+var Common = []string{"linux-amd64", "linux-arm64", "darwin-amd64", "darwin-arm64", "windows-amd64"}
+
 // Detect returns the current platform
 func Detect() Platform {
 	return Platform{
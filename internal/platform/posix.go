@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Unix-like layers that run on top of Windows
+const (
+	LayerMSYS   = "msys"   // Git Bash and MSYS2
+	LayerCygwin = "cygwin" // Cygwin
+)
+
+// DetectPOSIXLayer reports which Unix-like layer nori was started from on Windows,
+// or "" when running natively
+func DetectPOSIXLayer() string {
+	return detectPOSIXLayer(runtime.GOOS)
+}
+
+func detectPOSIXLayer(goos string) string {
+	if goos != "windows" {
+		return ""
+	}
+
+	// Git Bash and MSYS2 always export MSYSTEM (MINGW64, UCRT64, MSYS, ...)
+	if os.Getenv("MSYSTEM") != "" {
+		return LayerMSYS
+	}
+
+	// Cygwin shells export SHELL, which native Windows shells never set
+	if os.Getenv("SHELL") != "" {
+		return LayerCygwin
+	}
+
+	return ""
+}
+
+// POSIXPath converts a Windows path such as C:\Users\me into the form used by layer,
+// /c/Users/me for MSYS or /cygdrive/c/Users/me for Cygwin
+func POSIXPath(path, layer string) string {
+	path = strings.ReplaceAll(path, `\`, "/")
+
+	if len(path) < 2 || path[1] != ':' {
+		return path
+	}
+
+	drive := strings.ToLower(path[:1])
+	rest := path[2:]
+	if layer == LayerCygwin {
+		return "/cygdrive/" + drive + rest
+	}
+	return "/" + drive + rest
+}
@@ -0,0 +1,48 @@
+package platform
+
+import "testing"
+
+func TestDetectPOSIXLayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		goos    string
+		msystem string
+		shell   string
+		want    string
+	}{
+		{"git bash", "windows", "MINGW64", "/usr/bin/bash", LayerMSYS},
+		{"cygwin", "windows", "", "/bin/bash", LayerCygwin},
+		{"powershell", "windows", "", "", ""},
+		{"linux", "linux", "", "/bin/bash", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MSYSTEM", tt.msystem)
+			t.Setenv("SHELL", tt.shell)
+
+			if got := detectPOSIXLayer(tt.goos); got != tt.want {
+				t.Errorf("detectPOSIXLayer(%q) = %q, want %q", tt.goos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPOSIXPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		layer string
+		want  string
+	}{
+		{`C:\Users\me\.nori\shims`, LayerMSYS, "/c/Users/me/.nori/shims"},
+		{`D:\tools\nori`, LayerMSYS, "/d/tools/nori"},
+		{`C:\Users\me\.nori\shims`, LayerCygwin, "/cygdrive/c/Users/me/.nori/shims"},
+		{"/home/me/.nori/shims", LayerMSYS, "/home/me/.nori/shims"},
+	}
+
+	for _, tt := range tests {
+		if got := POSIXPath(tt.path, tt.layer); got != tt.want {
+			t.Errorf("POSIXPath(%q, %q) = %q, want %q", tt.path, tt.layer, got, tt.want)
+		}
+	}
+}
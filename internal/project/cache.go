@@ -0,0 +1,133 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+// stamp records the state of a file that a cached resolution depends on
+type stamp struct {
+	Path    string `json:"path"`
+	Exists  bool   `json:"exists"`
+	ModTime int64  `json:"mod_time,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+}
+
+// cacheEntry is the cached resolution for one directory.
+// It is stored as JSON rather than YAML because it is read on every shim exec.
+type cacheEntry struct {
+	Dir    string  `json:"dir"`
+	Stamps []stamp `json:"stamps"`
+	Chain  []*File `json:"chain"`
+}
+
+// ResolveCached behaves like Resolve but reuses the result of a previous resolution for dir
+// as long as no version file it depends on was created, modified or removed since.
+// Environment overrides are always applied fresh.
+func ResolveCached(paths platform.Paths, dir string) (*Result, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stamps := currentStamps(paths, dir)
+	cachePath := resolveCachePath(paths, dir)
+
+	if entry, ok := loadCacheEntry(cachePath); ok && entry.Dir == dir && stampsEqual(entry.Stamps, stamps) {
+		return withEnv(entry.Chain), nil
+	}
+
+	files, err := resolveFiles(paths, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Caching is best effort; a failed write only costs the next lookup
+	saveCacheEntry(cachePath, &cacheEntry{Dir: dir, Stamps: stamps, Chain: files})
+
+	return withEnv(files), nil
+}
+
+// currentStamps stats every candidate version file from dir up to the root, plus the global
+// active config and the machine-wide defaults
+func currentStamps(paths platform.Paths, dir string) []stamp {
+	var stamps []stamp
+	for {
+		for _, vf := range versionFiles {
+			stamps = append(stamps, statStamp(filepath.Join(dir, vf.name)))
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	stamps = append(stamps, statStamp(paths.ActiveConfigPath()))
+	if systemPath := paths.SystemActiveConfigPath(); systemPath != "" {
+		stamps = append(stamps, statStamp(systemPath))
+	}
+	return stamps
+}
+
+// statStamp captures the current state of path
+func statStamp(path string) stamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{Path: path}
+	}
+	return stamp{Path: path, Exists: true, ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+}
+
+// stampsEqual reports whether two stamp lists describe the same file states
+func stampsEqual(a, b []stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCachePath returns the cache file for dir
+func resolveCachePath(paths platform.Paths, dir string) string {
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(paths.CacheDir(), "resolve", hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadCacheEntry reads a cache entry, reporting false if it is missing or unreadable
+func loadCacheEntry(path string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// saveCacheEntry writes a cache entry, ignoring errors
+func saveCacheEntry(path string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	// Write atomically so concurrent shims never read a partial entry
+	fsutil.WriteFileAtomic(path, data, 0644)
+}
@@ -0,0 +1,135 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestResolveCachedReusesEntry(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	dir := t.TempDir()
+	writeVersions(t, dir, "nori-test-pkg: 1.0.0\n")
+
+	first, err := ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := first.Versions["nori-test-pkg"].Version; got != "1.0.0" {
+		t.Fatalf("first resolution = %q, want %q", got, "1.0.0")
+	}
+
+	if _, err := os.Stat(resolveCachePath(paths, dir)); err != nil {
+		t.Fatalf("cache entry was not written: %v", err)
+	}
+
+	second, err := ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := second.Versions["nori-test-pkg"].Version; got != "1.0.0" {
+		t.Errorf("cached resolution = %q, want %q", got, "1.0.0")
+	}
+}
+
+func TestResolveCachedInvalidatesOnChange(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	root := t.TempDir()
+	dir := filepath.Join(root, "sub")
+	writeVersions(t, dir, "nori-test-pkg: 1.0.0\n")
+
+	if _, err := ResolveCached(paths, dir); err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+
+	// Modify the file with a distinct mtime
+	path := filepath.Join(dir, FileName)
+	os.WriteFile(path, []byte("nori-test-pkg: 2.0.0\n"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	result, err := ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := result.Versions["nori-test-pkg"].Version; got != "2.0.0" {
+		t.Errorf("after modification = %q, want %q", got, "2.0.0")
+	}
+
+	// A new file in a parent directory must also invalidate the entry
+	writeVersions(t, root, "nori-test-parent: 3.0.0\n")
+
+	result, err = ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := result.Versions["nori-test-parent"].Version; got != "3.0.0" {
+		t.Errorf("after new parent file = %q, want %q", got, "3.0.0")
+	}
+
+	// So must a new .tool-versions
+	os.WriteFile(filepath.Join(dir, ToolVersionsName), []byte("nori-test-asdf 4.0.0\n"), 0644)
+
+	result, err = ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := result.Versions["nori-test-asdf"].Version; got != "4.0.0" {
+		t.Errorf("after new %s = %q, want %q", ToolVersionsName, got, "4.0.0")
+	}
+}
+
+func TestResolveCachedAppliesEnv(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	dir := t.TempDir()
+	writeVersions(t, dir, "nori-test-pkg: 1.0.0\n")
+
+	if _, err := ResolveCached(paths, dir); err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+
+	t.Setenv(EnvVarName("nori-test-pkg"), "9.9.9")
+
+	result, err := ResolveCached(paths, dir)
+	if err != nil {
+		t.Fatalf("ResolveCached() failed: %v", err)
+	}
+	if got := result.Versions["nori-test-pkg"].Version; got != "9.9.9" {
+		t.Errorf("with env override = %q, want %q", got, "9.9.9")
+	}
+}
+
+// benchmarkDir creates an empty nori root and a nested project tree, returning its deepest directory
+func benchmarkDir(b *testing.B) (platform.Paths, string) {
+	b.Helper()
+	paths := platform.NewPaths(b.TempDir())
+	root := b.TempDir()
+	dir := filepath.Join(root, "apps", "web", "src", "components")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(root, FileName), []byte("node: 20.5.1\ndeno: 1.44.0\n"), 0644)
+	os.WriteFile(filepath.Join(root, "apps", "web", FileName), []byte("node: 22.2.0\n"), 0644)
+	return paths, dir
+}
+
+func BenchmarkResolve(b *testing.B) {
+	paths, dir := benchmarkDir(b)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Resolve(paths, dir); err != nil {
+			b.Fatalf("Resolve() failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkResolveCached(b *testing.B) {
+	paths, dir := benchmarkDir(b)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ResolveCached(paths, dir); err != nil {
+			b.Fatalf("ResolveCached() failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package project
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Detected is a tool version found in a file another version manager or toolchain reads
+type Detected struct {
+	Package string
+	Version string
+	Source  string
+}
+
+// detector reads the version of one package from a file, returning "" if it names none
+type detector struct {
+	pkg  string
+	file string
+	read func(data []byte) string
+}
+
+// detectors are checked in order; the first one that yields a version for a package wins
+var detectors = []detector{
+	{"node", ".nvmrc", firstLine},
+	{"node", ".node-version", firstLine},
+	{"go", "go.mod", goModVersion},
+	{"go", ".go-version", firstLine},
+	{"rust", "rust-toolchain.toml", rustToolchainTOML},
+	{"rust", "rust-toolchain", firstLine},
+	{"python", ".python-version", firstLine},
+	{"ruby", ".ruby-version", firstLine},
+	{"terraform", ".terraform-version", firstLine},
+}
+
+// Detect finds tool versions declared in dir by files such as .nvmrc, the toolchain
+// line of go.mod and rust-toolchain.toml, sorted by package. Versions are returned as
+// written apart from a leading "v" or "go"; they may be partial, like 20 or 1.22.
+func Detect(dir string) ([]Detected, error) {
+	found := make(map[string]Detected)
+	for _, d := range detectors {
+		if _, ok := found[d.pkg]; ok {
+			continue
+		}
+		path := filepath.Join(dir, d.file)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if version := normalizeVersion(d.read(data)); version != "" {
+			found[d.pkg] = Detected{Package: d.pkg, Version: version, Source: path}
+		}
+	}
+
+	detected := make([]Detected, 0, len(found))
+	for _, d := range found {
+		detected = append(detected, d)
+	}
+	sort.Slice(detected, func(i, j int) bool {
+		return detected[i].Package < detected[j].Package
+	})
+	return detected, nil
+}
+
+// firstLine returns the first non-empty, non-comment line of data
+func firstLine(data []byte) string {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// goModVersion returns the toolchain a go.mod asks for, falling back to its go directive
+func goModVersion(data []byte) string {
+	var goLine, toolchain string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			goLine = fields[1]
+		case "toolchain":
+			toolchain = fields[1]
+		}
+	}
+	if toolchain != "" && toolchain != "default" {
+		return toolchain
+	}
+	return goLine
+}
+
+// rustToolchainTOML returns the channel in the [toolchain] table of a rust-toolchain.toml
+func rustToolchainTOML(data []byte) string {
+	table := ""
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && table == "toolchain" && strings.TrimSpace(key) == "channel" {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// normalizeVersion strips the prefixes tools write before version numbers. Anything
+// that doesn't start with a digit, such as lts/iron or stable, is not a version nori
+// can pin and yields "".
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	version = strings.TrimPrefix(version, "go")
+	version = strings.TrimPrefix(version, "v")
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return ""
+	}
+	return version
+}
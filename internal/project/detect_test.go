@@ -0,0 +1,54 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".nvmrc":              "v20.10.0\n",
+		".node-version":       "18.0.0\n",
+		"go.mod":              "module example.com/app\n\ngo 1.22\n\ntoolchain go1.22.5\n",
+		"rust-toolchain.toml": "[toolchain]\nchannel = \"1.78.0\"\ncomponents = [\"clippy\"]\n",
+		".python-version":     "# pyenv\n3.12\n",
+		".ruby-version":       "ruby-3.3.0\n",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	detected, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect() failed: %v", err)
+	}
+
+	// .nvmrc wins over .node-version, and an unpinnable ruby-3.3.0 is skipped
+	want := []Detected{
+		{Package: "go", Version: "1.22.5", Source: filepath.Join(dir, "go.mod")},
+		{Package: "node", Version: "20.10.0", Source: filepath.Join(dir, ".nvmrc")},
+		{Package: "python", Version: "3.12", Source: filepath.Join(dir, ".python-version")},
+		{Package: "rust", Version: "1.78.0", Source: filepath.Join(dir, "rust-toolchain.toml")},
+	}
+	if !reflect.DeepEqual(detected, want) {
+		t.Errorf("Detect() = %+v, want %+v", detected, want)
+	}
+}
+
+func TestDetectGoDirective(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21.3\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".nvmrc"), []byte("lts/iron\n"), 0644)
+
+	detected, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect() failed: %v", err)
+	}
+	want := []Detected{{Package: "go", Version: "1.21.3", Source: filepath.Join(dir, "go.mod")}}
+	if !reflect.DeepEqual(detected, want) {
+		t.Errorf("Detect() = %+v, want %+v", detected, want)
+	}
+}
@@ -0,0 +1,88 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the name of the lockfile written by `nori lock`
+const LockFileName = "nori.lock"
+
+// lockHeader starts every lockfile
+const lockHeader = "# Generated by `nori lock`; do not edit. Install exactly these builds with `nori sync`.\n"
+
+// Lock records the exact build of every version pinned for a project
+type Lock struct {
+	Path     string                   `yaml:"-"`
+	Packages map[string]LockedPackage `yaml:"packages"`
+}
+
+// LockedPackage is the locked version of one package and its build for each platform
+type LockedPackage struct {
+	Version   string                 `yaml:"version"`
+	Platforms map[string]LockedAsset `yaml:"platforms"`
+}
+
+// LockedAsset is the archive of one build
+type LockedAsset struct {
+	URL      string `yaml:"url"`
+	Checksum string `yaml:"checksum"` // sha256:hex
+}
+
+// LoadLock loads a lockfile from path
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	lock := &Lock{Path: path}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Packages == nil {
+		lock.Packages = make(map[string]LockedPackage)
+	}
+	return lock, nil
+}
+
+// Save writes the lockfile to its path
+func (l *Lock) Save() error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", l.Path, err)
+	}
+	if err := os.WriteFile(l.Path, append([]byte(lockHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// FindLock walks up from dir and returns the path of the nearest lockfile, or "" if there is none
+func FindLock(dir string) (string, error) {
+	return findUp(dir, LockFileName)
+}
+
+// findUp walks up from dir and returns the path of the nearest file called name, or "" if there is none
+func findUp(dir, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
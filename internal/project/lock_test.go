@@ -0,0 +1,49 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+	lock := &Lock{Path: path, Packages: map[string]LockedPackage{
+		"node": {Version: "22.2.0", Platforms: map[string]LockedAsset{
+			"linux-amd64": {URL: "https://example.com/node.tar.gz", Checksum: "sha256:" + strings.Repeat("a", 64)},
+		}},
+	}}
+	if err := lock.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# Generated by `nori lock`") {
+		t.Errorf("lockfile = %q, want it to start with the generated header", data)
+	}
+
+	loaded, err := LoadLock(path)
+	if err != nil {
+		t.Fatalf("LoadLock() failed: %v", err)
+	}
+	got := loaded.Packages["node"]
+	if got.Version != "22.2.0" || got.Platforms["linux-amd64"] != lock.Packages["node"].Platforms["linux-amd64"] {
+		t.Errorf("LoadLock() node = %+v, want %+v", got, lock.Packages["node"])
+	}
+}
+
+func TestFindLock(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "apps", "web")
+	os.MkdirAll(sub, 0755)
+
+	if path, err := FindLock(sub); err != nil || path != "" {
+		t.Errorf("FindLock() = %q, %v, want none", path, err)
+	}
+
+	os.WriteFile(filepath.Join(root, LockFileName), []byte("packages: {}\n"), 0644)
+	if path, err := FindLock(sub); err != nil || path != filepath.Join(root, LockFileName) {
+		t.Errorf("FindLock() = %q, %v, want the lockfile at the root", path, err)
+	}
+}
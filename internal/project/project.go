@@ -0,0 +1,322 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the per-directory version file
+const FileName = ".nori-versions"
+
+// EnvSource is the chain entry name for NORI_<PKG>_VERSION overrides
+const EnvSource = "environment"
+
+// File is a set of package versions declared by a single file
+type File struct {
+	Path     string            `json:"path"`
+	Versions map[string]string `json:"versions"`
+}
+
+// Resolution is the effective version of a package and the file that declared it
+type Resolution struct {
+	Package string
+	Version string
+	Source  string
+}
+
+// Result is the outcome of resolving versions for a directory
+type Result struct {
+	// Versions maps package names to their effective version
+	Versions map[string]Resolution
+
+	// Chain lists the files consulted, nearest first, ending with the global active config
+	// and the machine-wide defaults
+	Chain []*File
+}
+
+// Load loads a version file from path
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var versions map[string]string
+	if err := yaml.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if versions == nil {
+		versions = make(map[string]string)
+	}
+
+	return &File{Path: path, Versions: versions}, nil
+}
+
+// LoadProject loads the versions pinned under versions in the nori.yaml file at path
+func LoadProject(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var doc struct {
+		Versions map[string]string `yaml:"versions"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if doc.Versions == nil {
+		doc.Versions = make(map[string]string)
+	}
+
+	return &File{Path: path, Versions: doc.Versions}, nil
+}
+
+// template is written in place of an empty version file, so it shows how to add entries
+const template = "# <package>: <version>, e.g.\n# node: 22.2.0\n"
+
+// projectTemplate starts a new nori.yaml, whose versions Save fills in
+const projectTemplate = "# Tool versions pinned for this project, and tasks for `nori run`\nversions: {}\n# tasks:\n#   test: go test ./...\n"
+
+// Save writes the file's versions to its path, under versions for a nori.yaml file. An
+// existing file is edited in place, so its comments, the order of its entries and the
+// rest of a nori.yaml are kept.
+func (f *File) Save() error {
+	project := filepath.Base(f.Path) == ProjectFileName
+	data, err := os.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 && project {
+		data = []byte(projectTemplate)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	// A file of only comments has no document, so they are kept ahead of the entries
+	var comments []byte
+	if len(doc.Content) == 0 {
+		comments = data
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to update %s: it is not a mapping", f.Path)
+	}
+
+	versions := root
+	if project {
+		versions = mappingEntry(root, "versions")
+	}
+	setVersions(versions, f.Versions)
+
+	out := []byte(template)
+	if len(root.Content) > 0 {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", f.Path, err)
+		}
+		enc.Close()
+		out = append(comments, buf.Bytes()...)
+	}
+	if err := os.WriteFile(f.Path, out, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// mappingEntry returns the mapping under key in m, adding it if m has none
+func mappingEntry(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			value := m.Content[i+1]
+			if value.Kind != yaml.MappingNode {
+				*value = yaml.Node{Kind: yaml.MappingNode, HeadComment: value.HeadComment, LineComment: value.LineComment}
+			}
+			return value
+		}
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// setVersions makes the entries of m those of versions: entries it no longer has are
+// removed, changed ones are updated where they are, and new ones are added in order at
+// the end
+func setVersions(m *yaml.Node, versions map[string]string) {
+	seen := make(map[string]bool, len(versions))
+	kept := m.Content[:0]
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i], m.Content[i+1]
+		version, ok := versions[key.Value]
+		if !ok || seen[key.Value] {
+			continue
+		}
+		if value.Kind != yaml.ScalarNode || value.Value != version {
+			value.SetString(version)
+		}
+		seen[key.Value] = true
+		kept = append(kept, key, value)
+	}
+	m.Content = kept
+
+	var added []string
+	for pkg := range versions {
+		if !seen[pkg] {
+			added = append(added, pkg)
+		}
+	}
+	slices.Sort(added)
+	for _, pkg := range added {
+		value := &yaml.Node{}
+		value.SetString(versions[pkg])
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: pkg}, value)
+	}
+	if len(m.Content) > 0 {
+		m.Style = 0
+	}
+}
+
+// versionFiles are the version files read in each directory, in order of precedence
+var versionFiles = []struct {
+	name string
+	load func(path string) (*File, error)
+}{
+	{FileName, Load},
+	{ProjectFileName, LoadProject},
+	{ToolVersionsName, LoadToolVersions},
+}
+
+// Find walks up from dir to the filesystem root and returns every version file found, nearest
+// first. Within a directory .nori-versions comes before nori.yaml and an asdf .tool-versions,
+// so it wins.
+func Find(dir string) ([]*File, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	var files []*File
+	for {
+		for _, vf := range versionFiles {
+			path := filepath.Join(dir, vf.name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				f, err := vf.load(path)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, f)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return files, nil
+}
+
+// Merge combines files ordered nearest first: for each package the nearest file declaring it wins
+func Merge(files []*File) map[string]Resolution {
+	versions := make(map[string]Resolution)
+	for _, f := range files {
+		for pkg, version := range f.Versions {
+			if _, ok := versions[pkg]; ok {
+				continue
+			}
+			versions[pkg] = Resolution{Package: pkg, Version: version, Source: f.Path}
+		}
+	}
+	return versions
+}
+
+// Resolve determines the effective package versions for dir. NORI_<PKG>_VERSION environment
+// variables take precedence over version files, and the global active versions come last.
+func Resolve(paths platform.Paths, dir string) (*Result, error) {
+	files, err := resolveFiles(paths, dir)
+	if err != nil {
+		return nil, err
+	}
+	return withEnv(files), nil
+}
+
+// resolveFiles returns the version files for dir, nearest first, followed by the global
+// active versions and the machine-wide defaults beneath them
+func resolveFiles(paths platform.Paths, dir string) ([]*File, error) {
+	files, err := Find(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := config.New(paths).ActiveLayers()
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		files = append(files, &File{Path: layer.Path, Versions: layer.Versions})
+	}
+	return files, nil
+}
+
+// withEnv layers environment overrides on top of files and merges the result
+func withEnv(files []*File) *Result {
+	if env := envOverrides(Merge(files)); len(env.Versions) > 0 {
+		files = append([]*File{env}, files...)
+	}
+	return &Result{Versions: Merge(files), Chain: files}
+}
+
+// EnvVarName returns the environment variable that overrides the version of pkg
+func EnvVarName(pkg string) string {
+	return "NORI_" + strings.ToUpper(strings.ReplaceAll(pkg, "-", "_")) + "_VERSION"
+}
+
+// envOverrides collects NORI_<PKG>_VERSION variables. Packages already known from version files
+// are matched exactly; any other variable maps to a package name by lowercasing and using dashes.
+func envOverrides(known map[string]Resolution) *File {
+	versions := make(map[string]string)
+	matched := make(map[string]bool)
+
+	for pkg := range known {
+		name := EnvVarName(pkg)
+		if version := os.Getenv(name); version != "" {
+			versions[pkg] = version
+			matched[name] = true
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		name, version, ok := strings.Cut(kv, "=")
+		if !ok || version == "" || matched[name] {
+			continue
+		}
+		if !strings.HasPrefix(name, "NORI_") || !strings.HasSuffix(name, "_VERSION") {
+			continue
+		}
+		pkg := strings.TrimSuffix(strings.TrimPrefix(name, "NORI_"), "_VERSION")
+		if pkg == "" {
+			continue
+		}
+		versions[strings.ToLower(strings.ReplaceAll(pkg, "_", "-"))] = version
+	}
+
+	return &File{Path: EnvSource, Versions: versions}
+}
@@ -0,0 +1,201 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func writeVersions(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write version file: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeVersions(t, dir, "node: 22.2.0\npython: 3.12.0\n")
+
+	f, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if f.Versions["node"] != "22.2.0" {
+		t.Errorf("Versions[node] = %q, want %q", f.Versions["node"], "22.2.0")
+	}
+	if f.Versions["python"] != "3.12.0" {
+		t.Errorf("Versions[python] = %q, want %q", f.Versions["python"], "3.12.0")
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	// An empty file is written as a commented template that still loads
+	if err := (&File{Path: path}).Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if f, err := Load(path); err != nil || len(f.Versions) != 0 {
+		t.Errorf("Load() of the template = %+v, %v, want no versions", f, err)
+	}
+
+	f := &File{Path: path, Versions: map[string]string{"node": "22.2.0", "go": "1.22.5"}}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != template+"go: 1.22.5\nnode: 22.2.0\n" {
+		t.Errorf("saved file = %q, want sorted entries after the template", data)
+	}
+
+	// Edits keep the comments and order of what was there
+	os.WriteFile(path, []byte("# Team pins\nnode: 20.5.1 # LTS\ngo: 1.22.5\nrust: 1.79.0\n"), 0644)
+	f = &File{Path: path, Versions: map[string]string{"node": "22.2.0", "go": "1.22.5", "deno": "1.44.0"}}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "# Team pins\nnode: 22.2.0 # LTS\ngo: 1.22.5\ndeno: 1.44.0\n" {
+		t.Errorf("edited file = %q, want its comments kept", data)
+	}
+}
+
+func TestSaveProject(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ProjectFileName)
+
+	// A new nori.yaml is scaffolded around the versions
+	if err := (&File{Path: path, Versions: map[string]string{"node": "22.2.0"}}).Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if f, err := LoadProject(path); err != nil || f.Versions["node"] != "22.2.0" {
+		t.Errorf("LoadProject() of a new file = %+v, %v, want node pinned", f, err)
+	}
+
+	// The tasks and comments of an existing one are kept
+	os.WriteFile(path, []byte("# Project\ntasks:\n  test: go test ./... # all of it\n"), 0644)
+	if err := (&File{Path: path, Versions: map[string]string{"go": "1.22.5"}}).Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "# Project\ntasks:\n  test: go test ./... # all of it\nversions:\n  go: 1.22.5\n" {
+		t.Errorf("saved nori.yaml = %q, want the versions added", data)
+	}
+	if tasks, err := LoadTasks(path); err != nil || tasks.Tasks["test"] != "go test ./..." {
+		t.Errorf("LoadTasks() after Save() = %+v, %v", tasks, err)
+	}
+
+	// Its versions are read with the other version files of the directory
+	writeVersions(t, dir, "node: 22.2.0\n")
+	files, err := Find(dir)
+	if err != nil || len(files) != 2 || files[0].Path != filepath.Join(dir, FileName) || files[1].Versions["go"] != "1.22.5" {
+		t.Errorf("Find() = %+v, %v, want .nori-versions and then nori.yaml", files, err)
+	}
+}
+
+func TestFindNearestFirst(t *testing.T) {
+	root := t.TempDir()
+	app := filepath.Join(root, "apps", "web")
+	writeVersions(t, root, "node: 20.5.1\n")
+	writeVersions(t, app, "node: 22.2.0\n")
+
+	files, err := Find(filepath.Join(app, "src"))
+	if err != nil {
+		t.Fatalf("Find() failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Find() returned %d files, want 2", len(files))
+	}
+	if files[0].Path != filepath.Join(app, FileName) {
+		t.Errorf("files[0] = %q, want nearest file", files[0].Path)
+	}
+	if files[1].Path != filepath.Join(root, FileName) {
+		t.Errorf("files[1] = %q, want root file", files[1].Path)
+	}
+}
+
+func TestMergeNearestWins(t *testing.T) {
+	files := []*File{
+		{Path: "/repo/app/" + FileName, Versions: map[string]string{"node": "22.2.0"}},
+		{Path: "/repo/" + FileName, Versions: map[string]string{"node": "20.5.1", "deno": "1.44.0"}},
+	}
+
+	versions := Merge(files)
+
+	if got := versions["node"]; got.Version != "22.2.0" || got.Source != files[0].Path {
+		t.Errorf("node = %+v, want 22.2.0 from nearest file", got)
+	}
+	if got := versions["deno"]; got.Version != "1.44.0" || got.Source != files[1].Path {
+		t.Errorf("deno = %+v, want 1.44.0 from parent file", got)
+	}
+}
+
+func TestResolveEndsWithGlobal(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	dir := t.TempDir()
+	writeVersions(t, dir, "nori-test-pkg: 1.0.0\n")
+
+	result, err := Resolve(paths, dir)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+
+	last := result.Chain[len(result.Chain)-1]
+	if last.Path != paths.ActiveConfigPath() {
+		t.Errorf("last chain entry = %q, want global active config", last.Path)
+	}
+	if got := result.Versions["nori-test-pkg"]; got.Version != "1.0.0" {
+		t.Errorf("nori-test-pkg = %+v, want 1.0.0", got)
+	}
+}
+
+func TestEnvVarName(t *testing.T) {
+	tests := []struct {
+		pkg  string
+		want string
+	}{
+		{"node", "NORI_NODE_VERSION"},
+		{"frontend-toolchain", "NORI_FRONTEND_TOOLCHAIN_VERSION"},
+		{"my_tool", "NORI_MY_TOOL_VERSION"},
+	}
+
+	for _, tt := range tests {
+		if got := EnvVarName(tt.pkg); got != tt.want {
+			t.Errorf("EnvVarName(%q) = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEnvOverride(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	dir := t.TempDir()
+	writeVersions(t, dir, "nori-test-pkg: 1.0.0\nnori_test_other: 1.0.0\n")
+
+	t.Setenv("NORI_NORI_TEST_PKG_VERSION", "2.0.0")
+	t.Setenv("NORI_NORI_TEST_OTHER_VERSION", "3.0.0")
+	t.Setenv("NORI_NORI_TEST_NEW_VERSION", "4.0.0")
+
+	result, err := Resolve(paths, dir)
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+
+	if result.Chain[0].Path != EnvSource {
+		t.Errorf("first chain entry = %q, want %q", result.Chain[0].Path, EnvSource)
+	}
+
+	want := map[string]string{
+		"nori-test-pkg":   "2.0.0",
+		"nori_test_other": "3.0.0",
+		"nori-test-new":   "4.0.0",
+	}
+	for pkg, version := range want {
+		got := result.Versions[pkg]
+		if got.Version != version || got.Source != EnvSource {
+			t.Errorf("%s = %+v, want %s from environment", pkg, got, version)
+		}
+	}
+}
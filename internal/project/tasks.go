@@ -0,0 +1,53 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the name of the project file, which pins tool versions under
+// versions and defines tasks for `nori run` under tasks
+const ProjectFileName = "nori.yaml"
+
+// Tasks are the named commands defined in a nori.yaml file
+type Tasks struct {
+	Path  string            `yaml:"-"`
+	Tasks map[string]string `yaml:"tasks"`
+}
+
+// LoadTasks loads the tasks file at path
+func LoadTasks(path string) (*Tasks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	tasks := &Tasks{Path: path}
+	if err := yaml.Unmarshal(data, tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for name, command := range tasks.Tasks {
+		if name == "" || command == "" {
+			return nil, fmt.Errorf("invalid task %q in %s: tasks need a name and a command", name, path)
+		}
+	}
+	return tasks, nil
+}
+
+// Names returns the names of the tasks in alphabetical order
+func (t *Tasks) Names() []string {
+	names := make([]string, 0, len(t.Tasks))
+	for name := range t.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindTasks walks up from dir and returns the path of the nearest tasks file, or "" if there is none
+func FindTasks(dir string) (string, error) {
+	return findUp(dir, ProjectFileName)
+}
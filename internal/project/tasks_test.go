@@ -0,0 +1,40 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadTasks(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "apps", "web")
+	os.MkdirAll(sub, 0755)
+
+	if path, err := FindTasks(sub); err != nil || path != "" {
+		t.Errorf("FindTasks() = %q, %v, want none", path, err)
+	}
+
+	path := filepath.Join(root, ProjectFileName)
+	os.WriteFile(path, []byte("tasks:\n  test: go test ./...\n  build: |\n    go build ./...\n    go vet ./...\n"), 0644)
+	if found, err := FindTasks(sub); err != nil || found != path {
+		t.Fatalf("FindTasks() = %q, %v, want the file at the root", found, err)
+	}
+
+	tasks, err := LoadTasks(path)
+	if err != nil {
+		t.Fatalf("LoadTasks() failed: %v", err)
+	}
+	if names := tasks.Names(); !slices.Equal(names, []string{"build", "test"}) {
+		t.Errorf("Names() = %v, want [build test]", names)
+	}
+	if tasks.Tasks["build"] != "go build ./...\ngo vet ./...\n" {
+		t.Errorf("build = %q, want both lines", tasks.Tasks["build"])
+	}
+
+	os.WriteFile(path, []byte("tasks:\n  test:\n"), 0644)
+	if _, err := LoadTasks(path); err == nil {
+		t.Error("LoadTasks() should reject a task without a command")
+	}
+}
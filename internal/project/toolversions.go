@@ -0,0 +1,54 @@
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ToolVersionsName is the name of asdf's version file, read where no .nori-versions
+// entry pins a package
+const ToolVersionsName = ".tool-versions"
+
+// asdfPlugins maps asdf plugin names that differ from nori package names
+var asdfPlugins = map[string]string{
+	"golang": "go",
+	"nodejs": "node",
+}
+
+// LoadToolVersions loads an asdf .tool-versions file. Each line names a plugin followed
+// by one or more versions, the first preferred; the first one nori can pin is used, so
+// entries like system or ref:main are skipped.
+func LoadToolVersions(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	versions := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		pkg := fields[0]
+		if name, ok := asdfPlugins[pkg]; ok {
+			pkg = name
+		}
+		for _, v := range fields[1:] {
+			if version := normalizeVersion(v); version != "" {
+				versions[pkg] = version
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &File{Path: path, Versions: versions}, nil
+}
@@ -0,0 +1,53 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadToolVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ToolVersionsName)
+	content := `# asdf versions
+nodejs 22.2.0 20.10.0
+golang 1.22.5   # toolchain
+python system 3.12.0
+ruby ref:main
+terraform
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", ToolVersionsName, err)
+	}
+
+	f, err := LoadToolVersions(path)
+	if err != nil {
+		t.Fatalf("LoadToolVersions() failed: %v", err)
+	}
+	want := map[string]string{"node": "22.2.0", "go": "1.22.5", "python": "3.12.0"}
+	if len(f.Versions) != len(want) {
+		t.Errorf("Versions = %v, want %v", f.Versions, want)
+	}
+	for pkg, version := range want {
+		if f.Versions[pkg] != version {
+			t.Errorf("Versions[%s] = %q, want %q", pkg, f.Versions[pkg], version)
+		}
+	}
+}
+
+func TestFindPrefersNoriVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeVersions(t, dir, "node: 22.2.0\n")
+	os.WriteFile(filepath.Join(dir, ToolVersionsName), []byte("nodejs 20.10.0\npython 3.12.0\n"), 0644)
+
+	files, err := Find(dir)
+	if err != nil {
+		t.Fatalf("Find() failed: %v", err)
+	}
+	versions := Merge(files)
+	if got := versions["node"]; got.Version != "22.2.0" || got.Source != filepath.Join(dir, FileName) {
+		t.Errorf("node = %+v, want 22.2.0 from %s", got, FileName)
+	}
+	if got := versions["python"]; got.Version != "3.12.0" || got.Source != filepath.Join(dir, ToolVersionsName) {
+		t.Errorf("python = %+v, want 3.12.0 from %s", got, ToolVersionsName)
+	}
+}
@@ -0,0 +1,204 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sigstore certificate extensions that record the OIDC issuer of the identity a signing
+// certificate was issued to
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1} // raw string, deprecated
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8} // DER UTF8String
+)
+
+// verifyCertificate checks the signing certificate of s: that a trusted transparency log
+// recorded it while it was valid, that it chains to a trusted CA as of then, and that it
+// was issued to a trusted identity by a trusted OIDC issuer
+func (v *Verifier) verifyCertificate(s signedEnvelope) error {
+	leaf := s.certs[0]
+	logged, err := v.loggedAt(s.tlogEntries, leaf)
+	if err != nil {
+		return err
+	}
+
+	intermediates := v.intermediates.Clone()
+	for _, cert := range s.certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	// Signing certificates are short-lived, so the chain is checked as of when the
+	// transparency log recorded them
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   logged,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("signing certificate: %w", err)
+	}
+
+	identities := certIdentities(leaf)
+	if len(identities) == 0 {
+		return fmt.Errorf("signing certificate names no identity")
+	}
+	trusted := func(identity string) bool {
+		return slices.ContainsFunc(v.identities, func(pattern string) bool { return matchIdentity(pattern, identity) })
+	}
+	if !slices.ContainsFunc(identities, trusted) {
+		return fmt.Errorf("signing certificate is issued to %s, not a trusted identity", strings.Join(identities, ", "))
+	}
+	if issuer := certIssuer(leaf); !slices.Contains(v.issuers, issuer) {
+		return fmt.Errorf("signing certificate is from OIDC issuer %q, not a trusted issuer", issuer)
+	}
+	return nil
+}
+
+// loggedAt returns when a trusted transparency log recorded leaf, from the signed entry
+// timestamp of one of entries
+func (v *Verifier) loggedAt(entries []tlogEntry, leaf *x509.Certificate) (time.Time, error) {
+	if len(entries) == 0 {
+		return time.Time{}, fmt.Errorf("signing certificate has no transparency log entry")
+	}
+	var firstErr error
+	for _, entry := range entries {
+		logged, err := v.verifyTlogEntry(entry, leaf)
+		if err == nil {
+			return logged, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// verifyTlogEntry checks that entry is signed by a trusted transparency log and records
+// leaf, and returns when it was recorded
+func (v *Verifier) verifyTlogEntry(entry tlogEntry, leaf *x509.Certificate) (time.Time, error) {
+	if entry.InclusionPromise == nil {
+		return time.Time{}, fmt.Errorf("transparency log entry has no signed entry timestamp")
+	}
+	integrated, errTime := entry.IntegratedTime.Int64()
+	index, errIndex := entry.LogIndex.Int64()
+	logID, errID := decodeBase64(entry.LogID.KeyID)
+	set, errSET := decodeBase64(entry.InclusionPromise.SignedEntryTimestamp)
+	body, errBody := decodeBase64(entry.CanonicalizedBody)
+	if err := errors.Join(errTime, errIndex, errID, errSET, errBody); err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+
+	// The signed entry timestamp is a signature of the entry's canonical JSON
+	payload, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{base64.StdEncoding.EncodeToString(body), integrated, hex.EncodeToString(logID), index})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	if !slices.ContainsFunc(v.tlogKeys, func(key crypto.PublicKey) bool { return verifySignature(key, payload, set) }) {
+		return time.Time{}, fmt.Errorf("transparency log entry is not signed by a trusted log")
+	}
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil || !holdsCertificate(decoded, leaf) {
+		return time.Time{}, fmt.Errorf("transparency log entry is not of the signing certificate")
+	}
+	return time.Unix(integrated, 0), nil
+}
+
+// holdsCertificate reports whether the decoded JSON body of a transparency log entry
+// holds cert, which dsse and intoto entries record as base64 PEM
+func holdsCertificate(v any, cert *x509.Certificate) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, value := range v {
+			if holdsCertificate(value, cert) {
+				return true
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if holdsCertificate(value, cert) {
+				return true
+			}
+		}
+	case string:
+		data, err := decodeBase64(v)
+		if err != nil {
+			return false
+		}
+		for {
+			var block *pem.Block
+			if block, data = pem.Decode(data); block == nil {
+				break
+			}
+			if block.Type == "CERTIFICATE" && bytes.Equal(block.Bytes, cert.Raw) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// certIdentities returns the URI and email subject alternative names of cert
+func certIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return append(identities, cert.EmailAddresses...)
+}
+
+// certIssuer returns the OIDC issuer that Sigstore recorded in cert, or "" if none
+func certIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuerV2) {
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		}
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuer) {
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+// matchIdentity reports whether identity matches pattern, where * matches any run of
+// characters
+func matchIdentity(pattern, identity string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == identity
+	}
+	rest, ok := strings.CutPrefix(identity, parts[0])
+	if !ok {
+		return false
+	}
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return len(rest) >= len(last) && strings.HasSuffix(rest, last)
+}
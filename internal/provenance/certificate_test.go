@@ -0,0 +1,84 @@
+package provenance
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyCertificateIdentity(t *testing.T) {
+	ca, log := newTestCA(t), newTestLog()
+	v, err := New(ca.policy(t, log))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	logged := time.Now().Add(-25 * time.Minute)
+
+	for _, tt := range []struct {
+		name, identity, issuer, want string
+	}{
+		{"another repository", "https://github.com/attacker/tool/.github/workflows/release.yml@refs/heads/main", testIssuer, "not a trusted identity"},
+		{"another issuer", testIdentity, "https://accounts.example.com", "not a trusted issuer"},
+	} {
+		der, key := ca.issue(t, tt.identity, tt.issuer)
+		signed := bundle(signEnvelope(t, key, testStatement(testChecksum, testBuilder)), der, log.entry(t, der, logged))
+		if _, err := v.Verify(marshal(t, signed), testChecksum); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Verify() of a certificate from %s = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyTransparencyLog(t *testing.T) {
+	ca, log := newTestCA(t), newTestLog()
+	v, err := New(ca.policy(t, log))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	der, key := ca.issue(t, testIdentity, testIssuer)
+	otherDER, _ := ca.issue(t, testIdentity, testIssuer)
+	env := signEnvelope(t, key, testStatement(testChecksum, testBuilder))
+	logged := time.Now().Add(-25 * time.Minute)
+
+	for _, tt := range []struct {
+		name    string
+		entries []map[string]any
+		want    string
+	}{
+		{"no entry", nil, "no transparency log entry"},
+		{"an untrusted log", []map[string]any{newTestLog().entry(t, der, logged)}, "not signed by a trusted log"},
+		{"an entry of another certificate", []map[string]any{log.entry(t, otherDER, logged)}, "not of the signing certificate"},
+		// A certificate that leaked after it expired can't be used to sign
+		{"an entry after expiry", []map[string]any{log.entry(t, der, time.Now())}, "expired"},
+	} {
+		signed := bundle(env, der, tt.entries...)
+		if _, err := v.Verify(marshal(t, signed), testChecksum); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Verify() with %s = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// A tampered entry doesn't verify
+	entry := log.entry(t, der, logged)
+	entry["integratedTime"] = "1"
+	if _, err := v.Verify(marshal(t, bundle(env, der, entry)), testChecksum); err == nil || !strings.Contains(err.Error(), "trusted log") {
+		t.Errorf("Verify() with a tampered entry = %v", err)
+	}
+}
+
+func TestMatchIdentity(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, identity string
+		want              bool
+	}{
+		{testIdentity, testIdentity, true},
+		{testIdentity, testIdentity + "x", false},
+		{"https://github.com/example/tool/*@refs/tags/*", testIdentity, true},
+		{"https://github.com/example/tool/*@refs/tags/*", "https://github.com/example/tool/.github/workflows/ci.yml@refs/heads/main", false},
+		{"https://github.com/example/*", "https://github.com/example-fork/tool", false},
+		{"release@example.com", "release@example.com", true},
+		{"*@example.com", "release@example.com.attacker.net", false},
+	} {
+		if got := matchIdentity(tt.pattern, tt.identity); got != tt.want {
+			t.Errorf("matchIdentity(%q, %q) = %v, want %v", tt.pattern, tt.identity, got, tt.want)
+		}
+	}
+}
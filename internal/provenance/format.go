@@ -0,0 +1,172 @@
+package provenance
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+)
+
+// envelope is a DSSE envelope
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// rawCert is a DER certificate in a Sigstore bundle
+type rawCert struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+// tlogEntry is a transparency log entry of a Sigstore bundle. Its integers may be
+// encoded as JSON strings, as protobuf's JSON mapping does.
+type tlogEntry struct {
+	LogIndex json.Number `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   json.Number `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// document is one JSON document of an attestation file: a DSSE envelope, a Sigstore
+// bundle, or a response of the GitHub attestations API listing bundles
+type document struct {
+	envelope
+
+	DSSEEnvelope         *envelope `json:"dsseEnvelope"`
+	VerificationMaterial struct {
+		Certificate          *rawCert `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []rawCert `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+
+	Attestations []struct {
+		Bundle document `json:"bundle"`
+	} `json:"attestations"`
+}
+
+// signedEnvelope is an envelope along with the certificates of a bundle it came in,
+// leaf first, and the bundle's transparency log entries
+type signedEnvelope struct {
+	envelope    envelope
+	certs       []*x509.Certificate
+	tlogEntries []tlogEntry
+}
+
+// statement is an in-toto statement, with the parts of SLSA provenance v1 and v0.2 that
+// name the builder
+type statement struct {
+	Type          string    `json:"_type"`
+	Subject       []subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     struct {
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"predicate"`
+}
+
+// subject is an artifact an in-toto statement is about
+type subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// parse returns the envelopes in an attestation file: one JSON document, or JSON Lines
+// of them
+func parse(data []byte) ([]signedEnvelope, error) {
+	var docs []document
+	var doc document
+	if err := json.Unmarshal(data, &doc); err == nil {
+		docs = append(docs, doc)
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var doc document
+			if err := json.Unmarshal(line, &doc); err != nil {
+				return nil, fmt.Errorf("invalid attestation: %w", err)
+			}
+			docs = append(docs, doc)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("invalid attestation: %w", err)
+		}
+	}
+
+	var signed []signedEnvelope
+	for _, doc := range docs {
+		envelopes, err := doc.envelopes()
+		if err != nil {
+			return nil, err
+		}
+		signed = append(signed, envelopes...)
+	}
+	if len(signed) == 0 {
+		return nil, fmt.Errorf("no attestation found")
+	}
+	return signed, nil
+}
+
+// envelopes returns the envelopes in doc
+func (doc *document) envelopes() ([]signedEnvelope, error) {
+	if len(doc.Attestations) > 0 {
+		var signed []signedEnvelope
+		for _, a := range doc.Attestations {
+			envelopes, err := a.Bundle.envelopes()
+			if err != nil {
+				return nil, err
+			}
+			signed = append(signed, envelopes...)
+		}
+		return signed, nil
+	}
+	if doc.DSSEEnvelope == nil {
+		if doc.PayloadType == "" {
+			return nil, nil
+		}
+		return []signedEnvelope{{envelope: doc.envelope}}, nil
+	}
+
+	// A bundle holds either the signing certificate or its chain
+	material := doc.VerificationMaterial
+	var raw []rawCert
+	if material.Certificate != nil {
+		raw = []rawCert{*material.Certificate}
+	} else if material.X509CertificateChain != nil {
+		raw = material.X509CertificateChain.Certificates
+	}
+	certs := make([]*x509.Certificate, 0, len(raw))
+	for _, r := range raw {
+		der, err := decodeBase64(r.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in bundle: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in bundle: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return []signedEnvelope{{envelope: *doc.DSSEEnvelope, certs: certs, tlogEntries: material.TlogEntries}}, nil
+}
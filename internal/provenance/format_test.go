@@ -0,0 +1,202 @@
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+)
+
+const (
+	testIdentity = "https://github.com/example/tool/.github/workflows/release.yml@refs/tags/v1.0.0"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// testCA issues short-lived code signing certificates, like Sigstore's Fulcio
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a code signing certificate for identity from the OIDC issuer, which
+// expired long ago as Sigstore's do, along with its key
+func (ca *testCA) issue(t *testing.T, identity, issuer string) ([]byte, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	uri, _ := url.Parse(identity)
+	issuerExt, _ := asn1.MarshalWithParams(issuer, "utf8")
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-30 * time.Minute),
+		NotAfter:        time.Now().Add(-20 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerExt}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, key
+}
+
+// writeCert writes the PEM certificate of ca into dir and returns its path
+func (ca *testCA) writeCert(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "root.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// policy returns a policy that trusts certificates of ca for the test identity and
+// issuer, as recorded by log
+func (ca *testCA) policy(t *testing.T, log *testLog) config.ProvenancePolicy {
+	t.Helper()
+	return config.ProvenancePolicy{
+		Mode:       ModeEnforce,
+		Keys:       []string{ca.writeCert(t, t.TempDir())},
+		Identities: []string{"https://github.com/example/tool/.github/workflows/release.yml@*"},
+		Issuers:    []string{testIssuer},
+		TlogKeys:   []string{writePublicKey(t, t.TempDir(), log.key)},
+	}
+}
+
+// testLog signs entry timestamps, like Sigstore's Rekor
+type testLog struct {
+	key *ecdsa.PrivateKey
+}
+
+func newTestLog() *testLog {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return &testLog{key: key}
+}
+
+// entry returns a transparency log entry of a dsse record signed with the certificate
+// der, integrated at the given time
+func (l *testLog) entry(t *testing.T, der []byte, integrated time.Time) map[string]any {
+	t.Helper()
+	verifier := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	body := base64.StdEncoding.EncodeToString(marshal(t, map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec":       map[string]any{"signatures": []map[string]string{{"signature": "c2ln", "verifier": verifier}}},
+	}))
+	pub, _ := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+	logID := sha256.Sum256(pub)
+	payload := fmt.Sprintf(`{"body":%q,"integratedTime":%d,"logID":%q,"logIndex":%d}`, body, integrated.Unix(), hex.EncodeToString(logID[:]), 42)
+	sum := sha256.Sum256([]byte(payload))
+	set, err := l.key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]any{
+		"logIndex":          "42",
+		"logId":             map[string]string{"keyId": base64.StdEncoding.EncodeToString(logID[:])},
+		"integratedTime":    strconv.FormatInt(integrated.Unix(), 10),
+		"inclusionPromise":  map[string]string{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(set)},
+		"canonicalizedBody": body,
+	}
+}
+
+// bundle returns a Sigstore bundle of env signed with the certificate der, along with
+// its transparency log entries
+func bundle(env envelope, der []byte, entries ...map[string]any) map[string]any {
+	return map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": map[string]any{
+			"certificate": map[string]string{"rawBytes": base64.StdEncoding.EncodeToString(der)},
+			"tlogEntries": entries,
+		},
+		"dsseEnvelope": env,
+	}
+}
+
+func TestVerifySigstoreBundle(t *testing.T) {
+	ca, log := newTestCA(t), newTestLog()
+	der, key := ca.issue(t, testIdentity, testIssuer)
+	v, err := New(ca.policy(t, log))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	logged := time.Now().Add(-25 * time.Minute)
+	signed := bundle(signEnvelope(t, key, testStatement(testChecksum, testBuilder)), der, log.entry(t, der, logged))
+
+	if _, err := v.Verify(marshal(t, signed), testChecksum); err != nil {
+		t.Errorf("Verify() of a bundle failed: %v", err)
+	}
+
+	// The GitHub attestations API lists bundles
+	api := map[string]any{"attestations": []map[string]any{{"bundle": signed}}}
+	if result, err := v.Verify(marshal(t, api), testChecksum); err != nil || result.Builder != testBuilder {
+		t.Errorf("Verify() of an API response = %+v, %v", result, err)
+	}
+
+	// A certificate from another CA isn't trusted
+	otherDER, otherKey := newTestCA(t).issue(t, testIdentity, testIssuer)
+	untrusted := bundle(signEnvelope(t, otherKey, testStatement(testChecksum, testBuilder)), otherDER, log.entry(t, otherDER, logged))
+	if _, err := v.Verify(marshal(t, untrusted), testChecksum); err == nil || !strings.Contains(err.Error(), "trusted key") {
+		t.Errorf("Verify() of a bundle from another CA = %v", err)
+	}
+}
+
+func TestVerifyJSONLines(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	v, err := New(config.ProvenancePolicy{Mode: ModeEnforce, Keys: []string{writePublicKey(t, t.TempDir(), key)}})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	// Of several attestations, one that verifies is enough
+	first := marshal(t, signEnvelope(t, otherKey, testStatement(testChecksum, testBuilder)))
+	second := marshal(t, signEnvelope(t, key, testStatement(testChecksum, testBuilder)))
+	if _, err := v.Verify([]byte(string(first)+"\n"+string(second)+"\n"), testChecksum); err != nil {
+		t.Errorf("Verify() of JSON Lines failed: %v", err)
+	}
+	_, err = v.Verify([]byte(string(first)+"\n"+string(first)+"\n"), testChecksum)
+	if err == nil || !strings.Contains(err.Error(), "none of the 2 attestations") {
+		t.Errorf("Verify() of untrusted JSON Lines = %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+// Package provenance verifies SLSA provenance attestations: in-toto statements, signed in
+// DSSE envelopes, that record how an artifact was built. Envelopes are read as published
+// next to release assets (.intoto.jsonl), inside Sigstore bundles, or as listed by the
+// GitHub attestations API.
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// Modes of a provenance policy
+const (
+	ModeWarn    = "warn"
+	ModeEnforce = "enforce"
+)
+
+const (
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	statementTypePrefix = "https://in-toto.io/Statement/"
+	slsaPredicatePrefix = "https://slsa.dev/provenance/"
+)
+
+// ValidatePolicy checks a provenance setting: a known mode, and keys that can be loaded,
+// along with who may sign through the CA certificates among them
+func ValidatePolicy(policy config.ProvenancePolicy) error {
+	switch policy.Mode {
+	case "":
+		return nil
+	case ModeWarn, ModeEnforce:
+	default:
+		return fmt.Errorf("invalid provenance mode %q: expected warn or enforce", policy.Mode)
+	}
+	if len(policy.Keys) == 0 {
+		return fmt.Errorf("provenance mode %s needs the keys attestations are signed with", policy.Mode)
+	}
+	_, err := New(policy)
+	return err
+}
+
+// Verifier checks attestations against the keys and builders of a policy
+type Verifier struct {
+	keys          []crypto.PublicKey
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	identities    []string
+	issuers       []string
+	tlogKeys      []crypto.PublicKey
+	builders      []string
+}
+
+// Result is what a verified attestation says about an artifact
+type Result struct {
+	Builder       string // the builder ID, such as a GitHub Actions workflow
+	PredicateType string // such as https://slsa.dev/provenance/v1
+}
+
+// New creates a verifier that trusts the keys and builders of policy. CA certificates
+// among the keys are only trusted along with the identities and issuers they may issue
+// signing certificates to and the transparency logs that must record them.
+func New(policy config.ProvenancePolicy) (*Verifier, error) {
+	v := &Verifier{identities: policy.Identities, issuers: policy.Issuers, builders: policy.Builders}
+	for _, path := range policy.Keys {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read provenance key: %w", err)
+		}
+		if err := v.addPEM(data); err != nil {
+			return nil, fmt.Errorf("provenance key %s: %w", path, err)
+		}
+	}
+	for _, path := range policy.TlogKeys {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transparency log key: %w", err)
+		}
+		keys, err := parsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("transparency log key %s: %w", path, err)
+		}
+		v.tlogKeys = append(v.tlogKeys, keys...)
+	}
+	if v.roots != nil && (len(v.identities) == 0 || len(v.issuers) == 0 || len(v.tlogKeys) == 0) {
+		return nil, fmt.Errorf("provenance CA certificates need identities, issuers and tlog_keys, so that not every certificate they issue is trusted")
+	}
+	return v, nil
+}
+
+// parsePublicKeys returns the PEM public keys in data
+func parsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM public key found")
+	}
+	return keys, nil
+}
+
+// addPEM trusts the public keys and CA certificates in data. Self-signed certificates
+// are roots; others are intermediates that signing certificates may chain through.
+func (v *Verifier) addPEM(data []byte) error {
+	found := false
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("invalid public key: %w", err)
+			}
+			v.keys = append(v.keys, key)
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("invalid certificate: %w", err)
+			}
+			if v.roots == nil {
+				v.roots, v.intermediates = x509.NewCertPool(), x509.NewCertPool()
+			}
+			if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+				v.roots.AddCert(cert)
+			} else {
+				v.intermediates.AddCert(cert)
+			}
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no PEM public key or certificate found")
+	}
+	return nil
+}
+
+// Verify checks that data holds a SLSA provenance attestation of the artifact with the
+// given sha256:hex checksum, signed by a trusted key and naming a trusted builder. Of
+// several attestations, one that verifies is enough.
+func (v *Verifier) Verify(data []byte, checksum string) (*Result, error) {
+	digest, ok := strings.CutPrefix(checksum, "sha256:")
+	if !ok {
+		return nil, fmt.Errorf("invalid checksum %q: must start with 'sha256:'", checksum)
+	}
+	signed, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, s := range signed {
+		result, err := v.verify(s, strings.ToLower(digest))
+		if err == nil {
+			return result, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if len(signed) > 1 {
+		return nil, fmt.Errorf("none of the %d attestations verifies: %w", len(signed), firstErr)
+	}
+	return nil, firstErr
+}
+
+// VerifyAsset fetches the attestation asset references with fetcher and verifies it
+// against policy
+func VerifyAsset(ctx context.Context, fetcher *fetch.Fetcher, policy config.ProvenancePolicy, asset *manifest.Asset) (*Result, error) {
+	if asset.Provenance == "" {
+		return nil, fmt.Errorf("the manifest references no attestation")
+	}
+	verifier, err := New(policy)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fetcher.Get(ctx, asset.Provenance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attestation: %w", err)
+	}
+	return verifier.Verify(data, asset.Checksum)
+}
+
+// verify checks one attestation: its signature first, then what it claims
+func (v *Verifier) verify(s signedEnvelope, digest string) (*Result, error) {
+	if s.envelope.PayloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("attestation has payload type %q, not an in-toto statement", s.envelope.PayloadType)
+	}
+	payload, err := decodeBase64(s.envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation payload: %w", err)
+	}
+	if err := v.verifySignatures(s, pae(s.envelope.PayloadType, payload)); err != nil {
+		return nil, err
+	}
+
+	var st statement
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, fmt.Errorf("invalid in-toto statement: %w", err)
+	}
+	if !strings.HasPrefix(st.Type, statementTypePrefix) {
+		return nil, fmt.Errorf("attestation holds %q, not an in-toto statement", st.Type)
+	}
+	if !strings.HasPrefix(st.PredicateType, slsaPredicatePrefix) {
+		return nil, fmt.Errorf("attestation is %s, not SLSA provenance", st.PredicateType)
+	}
+	if !slices.ContainsFunc(st.Subject, func(s subject) bool { return strings.EqualFold(s.Digest["sha256"], digest) }) {
+		return nil, fmt.Errorf("attestation is not about sha256:%s", digest)
+	}
+
+	builder := st.Predicate.RunDetails.Builder.ID
+	if builder == "" {
+		builder = st.Predicate.Builder.ID // SLSA provenance v0.2
+	}
+	if len(v.builders) > 0 && !slices.Contains(v.builders, builder) {
+		return nil, fmt.Errorf("built by %q, which is not a trusted builder", builder)
+	}
+	return &Result{Builder: builder, PredicateType: st.PredicateType}, nil
+}
+
+// verifySignatures checks that a signature of s over msg verifies against a trusted key,
+// or against a signing certificate that a trusted CA issued to a trusted identity
+func (v *Verifier) verifySignatures(s signedEnvelope, msg []byte) error {
+	keys := v.keys
+	var certErr error
+	if len(s.certs) > 0 && v.roots != nil {
+		if certErr = v.verifyCertificate(s); certErr == nil {
+			keys = append(slices.Clone(keys), s.certs[0].PublicKey)
+		}
+	}
+	if len(s.envelope.Signatures) == 0 {
+		return fmt.Errorf("attestation is not signed")
+	}
+	for _, sig := range s.envelope.Signatures {
+		raw, err := decodeBase64(sig.Sig)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if verifySignature(key, msg, raw) {
+				return nil
+			}
+		}
+	}
+	if certErr != nil {
+		return fmt.Errorf("attestation is not signed by a trusted key: %w", certErr)
+	}
+	return fmt.Errorf("attestation is not signed by a trusted key")
+}
+
+// pae returns the DSSE pre-authentication encoding of a payload, which is what is signed
+func pae(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
+
+// verifySignature reports whether sig is key's signature of msg
+func verifySignature(key crypto.PublicKey, msg, sig []byte) bool {
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, msg, sig)
+	case *ecdsa.PublicKey:
+		var digest []byte
+		switch key.Curve.Params().BitSize {
+		case 384:
+			sum := sha512.Sum384(msg)
+			digest = sum[:]
+		case 521:
+			sum := sha512.Sum512(msg)
+			digest = sum[:]
+		default:
+			sum := sha256.Sum256(msg)
+			digest = sum[:]
+		}
+		return ecdsa.VerifyASN1(key, digest, sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(msg)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil ||
+			rsa.VerifyPSS(key, crypto.SHA256, sum[:], sig, nil) == nil
+	}
+	return false
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64")
+}
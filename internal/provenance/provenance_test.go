@@ -0,0 +1,169 @@
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/config"
+)
+
+const testBuilder = "https://github.com/example/tool/.github/workflows/release.yml@refs/tags/v1.0.0"
+
+// testChecksum is the checksum of the artifact the test attestations are about
+var testChecksum = func() string {
+	sum := sha256.Sum256([]byte("archive"))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}()
+
+// testStatement returns a SLSA provenance v1 statement about the artifact with checksum
+func testStatement(checksum, builder string) []byte {
+	st := map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"subject":       []map[string]any{{"name": "tool.tar.gz", "digest": map[string]string{"sha256": strings.TrimPrefix(checksum, "sha256:")}}},
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate":     map[string]any{"runDetails": map[string]any{"builder": map[string]string{"id": builder}}},
+	}
+	data, _ := json.Marshal(st)
+	return data
+}
+
+// signEnvelope returns a DSSE envelope of payload signed by signer
+func signEnvelope(t *testing.T, signer crypto.Signer, payload []byte) envelope {
+	t.Helper()
+	msg := pae(inTotoPayloadType, payload)
+	var sig []byte
+	var err error
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		sig, err = signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		sum := sha256.Sum256(msg)
+		sig, err = signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := envelope{PayloadType: inTotoPayloadType, Payload: base64.StdEncoding.EncodeToString(payload)}
+	env.Signatures = append(env.Signatures, struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	}{Sig: base64.StdEncoding.EncodeToString(sig)})
+	return env
+}
+
+// writePublicKey writes the PEM public key of signer into dir and returns its path
+func writePublicKey(t *testing.T, dir string, signer crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyWithKeys(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for name, signer := range map[string]crypto.Signer{"ed25519": edKey, "ecdsa": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			v, err := New(config.ProvenancePolicy{Mode: ModeEnforce, Keys: []string{writePublicKey(t, t.TempDir(), signer)}})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			data := marshal(t, signEnvelope(t, signer, testStatement(testChecksum, testBuilder)))
+			result, err := v.Verify(data, testChecksum)
+			if err != nil {
+				t.Fatalf("Verify() failed: %v", err)
+			}
+			if result.Builder != testBuilder || result.PredicateType != "https://slsa.dev/provenance/v1" {
+				t.Errorf("Verify() = %+v", result)
+			}
+		})
+	}
+
+	v, err := New(config.ProvenancePolicy{Mode: ModeWarn, Keys: []string{writePublicKey(t, t.TempDir(), edKey)}, Builders: []string{testBuilder}})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	other := sha256.Sum256([]byte("other"))
+	for name, tt := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"other key":      {marshal(t, signEnvelope(t, otherKey, testStatement(testChecksum, testBuilder))), "not signed by a trusted key"},
+		"other artifact": {marshal(t, signEnvelope(t, edKey, testStatement("sha256:"+hex.EncodeToString(other[:]), testBuilder))), "is not about"},
+		"other builder":  {marshal(t, signEnvelope(t, edKey, testStatement(testChecksum, "https://evil.example.com"))), "not a trusted builder"},
+		"unsigned":       {marshal(t, envelope{PayloadType: inTotoPayloadType, Payload: base64.StdEncoding.EncodeToString(testStatement(testChecksum, testBuilder))}), "not signed"},
+		"not json":       {[]byte("<html>"), "invalid attestation"},
+		"empty":          {[]byte("{}"), "no attestation found"},
+	} {
+		if _, err := v.Verify(tt.data, testChecksum); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Verify() = %v, want an error containing %q", name, err, tt.want)
+		}
+	}
+
+	// Payloads are checked only once their signature verifies
+	tampered := signEnvelope(t, edKey, testStatement(testChecksum, testBuilder))
+	tampered.Payload = base64.StdEncoding.EncodeToString(testStatement(testChecksum, testBuilder+"x"))
+	if _, err := v.Verify(marshal(t, tampered), testChecksum); err == nil || !strings.Contains(err.Error(), "trusted key") {
+		t.Errorf("Verify() of a tampered payload = %v", err)
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	keyPath := writePublicKey(t, t.TempDir(), key)
+	notPEM := filepath.Join(t.TempDir(), "key.txt")
+	os.WriteFile(notPEM, []byte("not a key"), 0644)
+
+	for _, policy := range []config.ProvenancePolicy{
+		{},
+		{Mode: ModeWarn, Keys: []string{keyPath}},
+		{Mode: ModeEnforce, Keys: []string{keyPath}, Builders: []string{testBuilder}},
+		newTestCA(t).policy(t, newTestLog()),
+	} {
+		if err := ValidatePolicy(policy); err != nil {
+			t.Errorf("ValidatePolicy(%+v) failed: %v", policy, err)
+		}
+	}
+	for _, policy := range []config.ProvenancePolicy{
+		{Mode: "strict", Keys: []string{keyPath}},
+		{Mode: ModeEnforce},
+		{Mode: ModeEnforce, Keys: []string{filepath.Join(t.TempDir(), "missing.pem")}},
+		{Mode: ModeEnforce, Keys: []string{notPEM}},
+		// A CA alone would trust every certificate it issues
+		{Mode: ModeEnforce, Keys: []string{newTestCA(t).writeCert(t, t.TempDir())}},
+		{Mode: ModeEnforce, Keys: []string{keyPath}, TlogKeys: []string{notPEM}},
+	} {
+		if err := ValidatePolicy(policy); err == nil {
+			t.Errorf("ValidatePolicy(%+v) should fail", policy)
+		}
+	}
+}
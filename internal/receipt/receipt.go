@@ -0,0 +1,204 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// FileName is the receipt stored at the root of every installation
+const FileName = ".nori-receipt.json"
+
+// File records the expected state of one installed file
+type File struct {
+	SHA256 string      `json:"sha256,omitempty"`
+	Size   int64       `json:"size,omitempty"`
+	Mode   fs.FileMode `json:"mode,omitempty"`
+	Link   string      `json:"link,omitempty"` // symlink target, instead of a hash
+}
+
+// Receipt describes how an installation was produced and what it contains
+type Receipt struct {
+	Package     string            `json:"package"`
+	Version     string            `json:"version"`
+	Platform    string            `json:"platform"`
+	Type        string            `json:"type"` // archive type, such as tar or zip
+	URL         string            `json:"url"`
+	Mirrors     []string          `json:"mirrors,omitempty"`          // alternative URLs for the archive
+	Checksum    string            `json:"checksum"`                   // archive checksum, sha256:hex
+	Payload     string            `json:"payload_checksum,omitempty"` // decompressed payload checksum, if declared
+	Inner       string            `json:"inner,omitempty"`            // glob of the archive inside the asset, if declared
+	InstalledAt time.Time         `json:"installed_at"`
+	Bins        []string          `json:"bins,omitempty"` // manifest bin paths, relative to the install root
+	Env         map[string]string `json:"env,omitempty"`  // manifest env, with {install} placeholders
+
+	// Files maps slash-separated paths relative to the install root to their state
+	Files map[string]File `json:"files"`
+}
+
+// Problem kinds reported by Verify
+const (
+	Missing  = "missing"
+	Modified = "modified"
+	Mode     = "mode"
+)
+
+// Problem is a file that no longer matches the receipt
+type Problem struct {
+	Path string
+	Kind string
+}
+
+// New creates a receipt for an installation of asset and records the hash of every file beneath installPath
+func New(pkg, version, platform string, asset *manifest.Asset, installPath string) (*Receipt, error) {
+	r := &Receipt{
+		Package:     pkg,
+		Version:     version,
+		Platform:    platform,
+		Type:        asset.Type,
+		URL:         asset.URL,
+		Mirrors:     asset.Mirrors,
+		Checksum:    asset.Checksum,
+		Payload:     asset.PayloadChecksum,
+		Inner:       asset.InnerPattern(version),
+		InstalledAt: time.Now().UTC(),
+		Files:       make(map[string]File),
+	}
+
+	err := filepath.WalkDir(installPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(installPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == FileName {
+			return nil
+		}
+
+		f, err := stat(path)
+		if err != nil {
+			return err
+		}
+		r.Files[rel] = f
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash installation: %w", err)
+	}
+
+	return r, nil
+}
+
+// URLs returns the URLs the archive can be downloaded from again, primary first. A
+// GitHub release asset installed from the archive cache has no URL recorded.
+func (r *Receipt) URLs() []string {
+	if r.URL == "" {
+		return r.Mirrors
+	}
+	return append([]string{r.URL}, r.Mirrors...)
+}
+
+// Load reads the receipt of the installation at installPath
+func Load(installPath string) (*Receipt, error) {
+	data, err := os.ReadFile(filepath.Join(installPath, FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var r Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt: %w", err)
+	}
+	return &r, nil
+}
+
+// Save writes the receipt into the installation at installPath
+func (r *Receipt) Save(installPath string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(installPath, FileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write receipt: %w", err)
+	}
+	return nil
+}
+
+// Verify compares the installation at installPath with the receipt, returning the
+// damaged files sorted by path. Files added after install are not reported.
+func (r *Receipt) Verify(installPath string) ([]Problem, error) {
+	var problems []Problem
+	for rel, want := range r.Files {
+		got, err := stat(filepath.Join(installPath, filepath.FromSlash(rel)))
+		if os.IsNotExist(err) {
+			problems = append(problems, Problem{Path: rel, Kind: Missing})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case got.SHA256 != want.SHA256 || got.Size != want.Size || got.Link != want.Link:
+			problems = append(problems, Problem{Path: rel, Kind: Modified})
+		case got.Mode != want.Mode:
+			problems = append(problems, Problem{Path: rel, Kind: Mode})
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// stat captures the current state of the file at path
+func stat(path string) (File, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return File{}, err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return File{}, err
+		}
+		return File{Link: target}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Size:   size,
+		Mode:   info.Mode().Perm(),
+	}, nil
+}
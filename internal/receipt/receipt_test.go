@@ -0,0 +1,137 @@
+package receipt
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+var testAsset = &manifest.Asset{
+	Type:     "tar",
+	URL:      "https://example.com/tool.tar.gz",
+	Checksum: "sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab",
+}
+
+// newInstall creates a fake installation with a binary and a library file
+func newInstall(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	os.MkdirAll(filepath.Join(dir, "lib"), 0755)
+	if err := os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("#!/bin/sh\necho tool\n"), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib", "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to write library: %v", err)
+	}
+	return dir
+}
+
+func TestNewRecordsFiles(t *testing.T) {
+	dir := newInstall(t)
+
+	r, err := New("tool", "1.0.0", "linux-amd64", testAsset, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	if len(r.Files) != 2 {
+		t.Fatalf("New() recorded %d files, want 2: %v", len(r.Files), r.Files)
+	}
+	f, ok := r.Files["bin/tool"]
+	if !ok {
+		t.Fatal("New() did not record bin/tool")
+	}
+	if f.SHA256 == "" || f.Size != int64(len("#!/bin/sh\necho tool\n")) {
+		t.Errorf("bin/tool = %+v, want hash and size", f)
+	}
+	if r.Type != "tar" || r.Checksum != testAsset.Checksum {
+		t.Errorf("receipt source = %q %q, want asset type and checksum", r.Type, r.Checksum)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := newInstall(t)
+
+	r, err := New("tool", "1.0.0", "linux-amd64", testAsset, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := r.Save(dir); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if loaded.Package != "tool" || len(loaded.Files) != 2 {
+		t.Errorf("Load() = %+v, want the saved receipt", loaded)
+	}
+	if urls := loaded.URLs(); len(urls) != 1 || urls[0] != testAsset.URL {
+		t.Errorf("URLs() = %v, want the asset's URL", urls)
+	}
+
+	// The receipt itself is never part of the verified tree
+	problems, err := loaded.Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Verify() of an intact install = %v, want none", problems)
+	}
+}
+
+func TestVerifyDetectsDamage(t *testing.T) {
+	dir := newInstall(t)
+
+	r, err := New("tool", "1.0.0", "linux-amd64", testAsset, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("corrupted"), 0755)
+	os.Remove(filepath.Join(dir, "lib", "data.txt"))
+	os.WriteFile(filepath.Join(dir, "lib", "extra.txt"), []byte("added later"), 0644)
+
+	problems, err := r.Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	want := []Problem{{Path: "bin/tool", Kind: Modified}, {Path: "lib/data.txt", Kind: Missing}}
+	if len(problems) != len(want) {
+		t.Fatalf("Verify() = %v, want %v", problems, want)
+	}
+	for i := range want {
+		if problems[i] != want[i] {
+			t.Errorf("Verify()[%d] = %v, want %v", i, problems[i], want[i])
+		}
+	}
+}
+
+func TestVerifyDetectsModeChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on Windows")
+	}
+
+	dir := newInstall(t)
+	r, err := New("tool", "1.0.0", "linux-amd64", testAsset, dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	os.Chmod(filepath.Join(dir, "bin", "tool"), 0644)
+
+	problems, err := r.Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if len(problems) != 1 || problems[0] != (Problem{Path: "bin/tool", Kind: Mode}) {
+		t.Errorf("Verify() = %v, want a mode problem for bin/tool", problems)
+	}
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+)
+
+// validator identifies the version of a file that a registry served, so a later request
+// can ask for it only if it has changed
+type validator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// validators are the validators of a registry's cached index and manifests, by URL
+type validators map[string]validator
+
+// validatorsPath returns where the validators of this registry's cached files are kept
+func (r *Registry) validatorsPath() string {
+	return filepath.Join(r.dir, "validators.json")
+}
+
+// loadValidators reads the validators recorded by the last update. Missing or unreadable
+// ones just make every request unconditional.
+func (r *Registry) loadValidators() validators {
+	v := make(validators)
+	if data, err := os.ReadFile(r.validatorsPath()); err == nil {
+		json.Unmarshal(data, &v)
+	}
+	return v
+}
+
+// saveValidators records v for the next update
+func (r *Registry) saveValidators(v validators) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal validators: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(r.validatorsPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write validators: %w", err)
+	}
+	return nil
+}
+
+// record keeps the validators that url was served with, once what it served is cached.
+// Without any, the next request for url is unconditional.
+func (v validators) record(url string, served validator) {
+	if served == (validator{}) {
+		delete(v, url)
+		return
+	}
+	v[url] = served
+}
+
+// fetchConditional performs an HTTP GET request, or reads the file a file URL names. Given
+// the validators known of the copy cached at cachedPath, it asks for url only if it has
+// changed, reporting whether the registry answered 304 Not Modified and returning the
+// cached copy. It also returns the validators of what it fetched, which the caller
+// records only once it has cached that.
+func (r *Registry) fetchConditional(ctx context.Context, url, cachedPath string, known validator) ([]byte, validator, bool, error) {
+	if path, ok := localPath(url); ok {
+		data, err := readLocal(path)
+		return data, validator{}, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, validator{}, false, err
+	}
+	var cached []byte
+	if known != (validator{}) {
+		if cached, err = os.ReadFile(cachedPath); err == nil {
+			if known.ETag != "" {
+				req.Header.Set("If-None-Match", known.ETag)
+			}
+			if known.LastModified != "" {
+				req.Header.Set("If-Modified-Since", known.LastModified)
+			}
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, validator{}, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match")+req.Header.Get("If-Modified-Since") != "" {
+		return cached, known, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, validator{}, false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, validator{}, false, err
+	}
+	return data, validator{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, false, nil
+}
@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestRegistryConditionalUpdate(t *testing.T) {
+	var mu sync.Mutex
+	files := map[string]string{
+		"/index.yaml":          "packages:\n  - name: tool\n  - name: other\n",
+		"/packages/tool.yaml":  conditionalManifest("tool", "1.0.0"),
+		"/packages/other.yaml": conditionalManifest("other", "1.0.0"),
+	}
+	full := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// other only has a modification time; the rest have ETags of their content
+		if r.URL.Path == "/packages/other.yaml" {
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			if r.Header.Get("If-Modified-Since") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else {
+			etag := fmt.Sprintf(`"%x"`, len(body))
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		full[r.URL.Path]++
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	paths := platform.NewPaths(t.TempDir())
+	reg := New(server.URL, paths)
+	ctx := context.Background()
+	if summary, err := reg.Update(ctx); err != nil || summary.Packages != 2 || summary.Unchanged != 0 {
+		t.Fatalf("first Update() = %+v, %v, want both packages fetched", summary, err)
+	}
+
+	// Nothing changed, so nothing is transferred again
+	summary, err := reg.Update(ctx)
+	if err != nil || summary.Packages != 2 || summary.Unchanged != 2 {
+		t.Fatalf("second Update() = %+v, %v, want both packages unchanged", summary, err)
+	}
+	for path, n := range full {
+		if n != 1 {
+			t.Errorf("%s was transferred %d times, want once", path, n)
+		}
+	}
+	if m, err := reg.cachedPackage("tool"); err != nil || m.LatestVersion() != "1.0.0" {
+		t.Errorf("cached tool = %v, %v", m, err)
+	}
+
+	// A changed manifest is transferred in full
+	mu.Lock()
+	files["/packages/tool.yaml"] = conditionalManifest("tool", "1.10.0")
+	mu.Unlock()
+	if summary, err := reg.Update(ctx); err != nil || summary.Unchanged != 1 {
+		t.Fatalf("Update() after a change = %+v, %v, want only other unchanged", summary, err)
+	}
+	if m, err := reg.cachedPackage("tool"); err != nil || m.LatestVersion() != "1.10.0" {
+		t.Errorf("cached tool after a change = %v, %v, want 1.10.0", m, err)
+	}
+
+	// Without the cached copy the request is unconditional
+	if err := os.Remove(reg.manifestPath("other")); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := reg.Update(ctx); err != nil || summary.Unchanged != 1 || full["/packages/other.yaml"] != 2 {
+		t.Errorf("Update() without other's cache = %+v, %v, other transferred %d times", summary, err, full["/packages/other.yaml"])
+	}
+
+	// A manifest that fails validation isn't cached, so neither are its validators
+	mu.Lock()
+	files["/packages/tool.yaml"] = "schema: 1\nname: tool\n"
+	mu.Unlock()
+	if summary, err := reg.Update(ctx); err != nil || summary.Skipped != 1 {
+		t.Fatalf("Update() of an invalid manifest = %+v, %v, want it skipped", summary, err)
+	}
+	if v, ok := reg.loadValidators()[server.URL+"/packages/tool.yaml"]; ok {
+		t.Errorf("validators of an invalid manifest were recorded: %+v", v)
+	}
+	if m, err := reg.cachedPackage("tool"); err != nil || m.LatestVersion() != "1.10.0" {
+		t.Errorf("cached tool after an invalid update = %v, %v, want 1.10.0", m, err)
+	}
+	if err := reg.ClearCache(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(reg.validatorsPath()); !os.IsNotExist(err) {
+		t.Errorf("ClearCache() left the validators: %v", err)
+	}
+}
+
+// conditionalManifest returns the manifest of name with one version
+func conditionalManifest(name, version string) string {
+	return `schema: 1
+name: ` + name + `
+bins:
+  - bin/` + name + `
+versions:
+  "` + version + `":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/` + name + `.tar.gz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`
+}
@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// windowsDrivePath matches the path of a file URL on a Windows drive, such as /C:/registry
+var windowsDrivePath = regexp.MustCompile(`^/[A-Za-z]:`)
+
+// normalizeBaseURL returns baseURL with a plain directory path, such as
+// ./nori-registry, turned into the file URL of its absolute path
+func normalizeBaseURL(baseURL string) string {
+	if baseURL == "" || strings.Contains(baseURL, "://") {
+		return baseURL
+	}
+	abs, err := filepath.Abs(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	path := filepath.ToSlash(abs)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// localPath returns the file a file URL names, and whether rawURL is one
+func localPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	path := u.Path
+	if windowsDrivePath.MatchString(path) {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), true
+}
+
+// readLocal reads a file of a registry on disk, as fetch would download it
+func readLocal(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s does not exist", path)
+	}
+	return data, err
+}
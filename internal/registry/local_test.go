@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func TestRegistryFromDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my registry")
+	if err := os.MkdirAll(filepath.Join(dir, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "index.yaml"), []byte("packages:\n  - name: node\n    description: Node.js runtime\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "packages", "node.yaml"), []byte(`schema: 1
+name: node
+description: Node.js runtime
+bins:
+  - bin/node
+versions:
+  "22.2.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://nodejs.org/dist/v22.2.0/node-v22.2.0-linux-x64.tar.xz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`), 0644)
+
+	// A plain path and the file URL it stands for read the same registry
+	plain := New(dir, platform.NewPaths(t.TempDir()))
+	if !strings.HasPrefix(plain.BaseURL, "file:///") {
+		t.Fatalf("BaseURL = %q, want a file URL", plain.BaseURL)
+	}
+	for _, reg := range []*Registry{plain, New(plain.BaseURL, platform.NewPaths(t.TempDir()))} {
+		summary, err := reg.Update(context.Background())
+		if err != nil {
+			t.Fatalf("Update() from %s failed: %v", reg.BaseURL, err)
+		}
+		if summary.Packages != 1 {
+			t.Errorf("Update() from %s summary = %+v, want 1 package", reg.BaseURL, summary)
+		}
+		m, err := reg.LoadPackage(context.Background(), "node")
+		if err != nil || m.Name != "node" {
+			t.Errorf("LoadPackage() from %s = %v, %v", reg.BaseURL, m, err)
+		}
+	}
+
+	// Relative paths are resolved against the working directory
+	t.Chdir(filepath.Dir(dir))
+	if got := New("my registry", platform.NewPaths(t.TempDir())).BaseURL; got != plain.BaseURL {
+		t.Errorf("relative path BaseURL = %q, want %q", got, plain.BaseURL)
+	}
+
+	missing := New(filepath.Join(dir, "missing"), platform.NewPaths(t.TempDir()))
+	if _, err := missing.Update(context.Background()); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Update() from a missing directory = %v, want it to not exist", err)
+	}
+}
+
+func TestValidateLocalURL(t *testing.T) {
+	for _, rawURL := range []string{"file:///srv/nori-registry", t.TempDir()} {
+		if err := ValidateURL(rawURL); err != nil {
+			t.Errorf("ValidateURL(%q) failed: %v", rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{"file://", "nori-registry", "registry.example.com"} {
+		if err := ValidateURL(rawURL); err == nil {
+			t.Errorf("ValidateURL(%q) should fail", rawURL)
+		}
+	}
+}
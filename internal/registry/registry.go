@@ -2,14 +2,18 @@ package registry
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/fsutil"
 	"github.com/chirag-bruno/nori/internal/manifest"
 	"github.com/chirag-bruno/nori/internal/platform"
 	"gopkg.in/yaml.v3"
@@ -23,8 +27,29 @@ const (
 type PackageMeta struct {
 	Name        string `yaml:"name"`
 	Description string `yaml:"description"`
+
+	// Optional stats published by the registry
+	Downloads int64     `yaml:"downloads,omitempty"`
+	Updated   time.Time `yaml:"updated,omitempty"`
+
+	// Filled in from the package's manifest by the search index; see BuildSearchIndex
+	Homepage  string   `yaml:"homepage,omitempty"`
+	Bins      []string `yaml:"bins,omitempty"` // base names, such as node
+	Tags      []string `yaml:"tags,omitempty"`
+	Latest    string   `yaml:"latest,omitempty"`
+	Platforms []string `yaml:"platforms,omitempty"` // that any version ships for
+
+	// Registry is the name of the registry the package comes from, such as default;
+	// see Registry.Label
+	Registry string `yaml:"-"`
 }
 
+// Sort orders accepted by SortPackages
+const (
+	SortPopularity = "popularity"
+	SortUpdated    = "updated"
+)
+
 // Index represents the registry index
 type Index struct {
 	Packages []PackageMeta `yaml:"packages"`
@@ -33,54 +58,212 @@ type Index struct {
 // Registry represents a registry client
 type Registry struct {
 	BaseURL string
+	Name    string // of a configured registry; empty for the default registry
+	Prefix  string // that namespaces the packages of a configured registry; see Qualify
+
+	paths   platform.Paths
+	dir     string // where the index and manifests are cached
 	client  *http.Client
+
+	// configured are the registries looked up before this one, in order; see AddRegistry
+	configured []*Registry
 }
 
-// New creates a new registry client with the given base URL
-func New(baseURL string) *Registry {
+// New creates a new registry client with the given base URL, caching under paths. A
+// file URL or a plain directory path reads the registry from disk.
+func New(baseURL string, paths platform.Paths) *Registry {
 	return &Registry{
-		BaseURL: baseURL,
+		BaseURL: normalizeBaseURL(baseURL),
+		paths:   paths,
+		dir:     paths.RegistryDir(),
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
-// NewFromEnv creates a new registry client using NORI_REGISTRY_URL env var or default
-func NewFromEnv() *Registry {
-	baseURL := os.Getenv("NORI_REGISTRY_URL")
-	if baseURL == "" {
-		baseURL = defaultRegistryURL
+// NewWithClient creates a new registry client that uses the given HTTP client
+func NewWithClient(baseURL string, paths platform.Paths, client *http.Client) *Registry {
+	return &Registry{
+		BaseURL: normalizeBaseURL(baseURL),
+		paths:   paths,
+		dir:     paths.RegistryDir(),
+		client:  client,
 	}
-	return New(baseURL)
 }
 
-// Update fetches the registry index and caches package manifests
-func (r *Registry) Update(ctx context.Context) error {
-	// Fetch index.yaml
+// Find returns the index entry for the named package, or nil if it is not listed
+func (i *Index) Find(name string) *PackageMeta {
+	for n := range i.Packages {
+		if i.Packages[n].Name == name {
+			return &i.Packages[n]
+		}
+	}
+	return nil
+}
+
+// SortPackages orders pkgs by SortPopularity (most downloads first) or SortUpdated
+// (most recently updated first). Packages without stats keep their index order at the end.
+func SortPackages(pkgs []PackageMeta, by string) error {
+	var less func(a, b PackageMeta) bool
+	switch by {
+	case SortPopularity:
+		less = func(a, b PackageMeta) bool { return a.Downloads > b.Downloads }
+	case SortUpdated:
+		less = func(a, b PackageMeta) bool { return a.Updated.After(b.Updated) }
+	default:
+		return fmt.Errorf("unknown sort order %q (want %s or %s)", by, SortPopularity, SortUpdated)
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return less(pkgs[i], pkgs[j])
+	})
+	return nil
+}
+
+// ParseIndex parses registry index YAML
+func ParseIndex(data []byte) (*Index, error) {
+	var index Index
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	return &index, nil
+}
+
+// DefaultURL returns the URL of the default registry: the one in NORI_REGISTRY_URL, else
+// configured, the registry_url setting, else nori's own registry
+func DefaultURL(configured string) string {
+	if baseURL := os.Getenv("NORI_REGISTRY_URL"); baseURL != "" {
+		return baseURL
+	}
+	if configured != "" {
+		return configured
+	}
+	return defaultRegistryURL
+}
+
+// SetAuth sends the headers in headers, keyed by host name, with requests to that host;
+// see fetch.AuthTransport
+func (r *Registry) SetAuth(headers map[string]http.Header) {
+	client := *r.client
+	client.Transport = fetch.AuthTransport(r.client.Transport, headers)
+	r.client = &client
+	for _, other := range r.configured {
+		other.client = r.client
+	}
+}
+
+// SetTLSConfig makes HTTPS connections with tlsConfig; see fetch.TLSConfig
+func (r *Registry) SetTLSConfig(tlsConfig *tls.Config) {
+	client := *r.client
+	client.Transport = fetch.TLSTransport(r.client.Transport, tlsConfig)
+	r.client = &client
+	for _, other := range r.configured {
+		other.client = r.client
+	}
+}
+
+// SetHTTPProxy sends requests through the HTTP(S) proxy at proxyURL, except to the hosts
+// in noProxy; see fetch.ProxyTransport. Without a call, the proxy in $HTTPS_PROXY or
+// $HTTP_PROXY is used.
+func (r *Registry) SetHTTPProxy(proxyURL, noProxy string) error {
+	transport, err := fetch.ProxyTransport(r.client.Transport, proxyURL, noProxy)
+	if err != nil {
+		return err
+	}
+	client := *r.client
+	client.Transport = transport
+	r.client = &client
+	for _, other := range r.configured {
+		other.client = r.client
+	}
+	return nil
+}
+
+// UpdateSummary counts what a registry update refreshed, for registry operators
+type UpdateSummary struct {
+	Packages  int // manifests cached
+	Skipped   int // manifests that failed to fetch, parse or validate
+	Unchanged int // manifests the registry reported unchanged since they were cached
+	Versions  int
+	Assets    int // version and platform pairs
+	Platforms int // distinct platforms across all assets
+
+	// Gaps lists packages whose latest version lacks a common platform
+	Gaps []CoverageGap
+}
+
+// CoverageGap is a package whose latest version does not ship for some of platform.Common
+type CoverageGap struct {
+	Package string
+	Version string
+	Missing []string
+}
+
+// summarize adds the validated manifest of the package name to the summary
+func (s *UpdateSummary) summarize(name string, m *manifest.Manifest, platforms map[string]bool) {
+	s.Packages++
+	if m.IsGroup() {
+		return
+	}
+
+	for _, ver := range m.Versions {
+		s.Versions++
+		s.Assets += len(ver.Platforms)
+		for plat := range ver.Platforms {
+			platforms[plat] = true
+		}
+	}
+	s.Platforms = len(platforms)
+
+	latest := m.LatestVersion()
+	var missing []string
+	for _, plat := range platform.Common {
+		if _, ok := m.Versions[latest].Platforms[plat]; !ok {
+			missing = append(missing, plat)
+		}
+	}
+	if len(missing) > 0 {
+		s.Gaps = append(s.Gaps, CoverageGap{Package: name, Version: latest, Missing: missing})
+	}
+}
+
+// update fetches this registry's index and caches its package manifests, adding what was
+// refreshed to summary
+func (r *Registry) update(ctx context.Context, summary *UpdateSummary, platforms map[string]bool) error {
+	// Fetch index.yaml, or only learn that the cached one is current
+	validators := r.loadValidators()
 	indexURL := strings.TrimSuffix(r.BaseURL, "/") + "/index.yaml"
-	indexData, err := r.fetch(ctx, indexURL)
+	indexPath := r.indexPath()
+	indexData, indexServed, indexUnchanged, err := r.fetchConditional(ctx, indexURL, indexPath, validators[indexURL])
 	if err != nil {
 		return fmt.Errorf("failed to fetch index: %w", err)
 	}
 	
 	// Parse index
-	var index Index
-	if err := yaml.Unmarshal(indexData, &index); err != nil {
-		return fmt.Errorf("failed to parse index: %w", err)
+	index, err := ParseIndex(indexData)
+	if err != nil {
+		// A cached index that no longer parses is fetched in full next time
+		if _, ok := validators[indexURL]; ok {
+			delete(validators, indexURL)
+			r.saveValidators(validators)
+		}
+		return err
 	}
 	
 	// Ensure registry directory exists
-	registryDir := platform.RegistryDir()
+	registryDir := r.dir
 	if err := os.MkdirAll(registryDir, 0755); err != nil {
 		return fmt.Errorf("failed to create registry directory: %w", err)
 	}
 	
 	// Save index.yaml
-	indexPath := platform.IndexPath()
-	if err := os.WriteFile(indexPath, indexData, 0644); err != nil {
-		return fmt.Errorf("failed to write index: %w", err)
+	if !indexUnchanged {
+		if err := fsutil.WriteFileAtomic(indexPath, indexData, 0644); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
 	}
+	validators.record(indexURL, indexServed)
 	
 	// Fetch and cache each package manifest
 	packagesDir := filepath.Join(registryDir, "packages")
@@ -88,50 +271,114 @@ func (r *Registry) Update(ctx context.Context) error {
 		return fmt.Errorf("failed to create packages directory: %w", err)
 	}
 	
+	manifests := make(map[string]*manifest.Manifest, len(index.Packages))
 	for _, pkg := range index.Packages {
 		manifestURL := strings.TrimSuffix(r.BaseURL, "/") + "/packages/" + pkg.Name + ".yaml"
-		manifestData, err := r.fetch(ctx, manifestURL)
+		manifestPath := r.manifestPath(pkg.Name)
+		manifestData, served, unchanged, err := r.fetchConditional(ctx, manifestURL, manifestPath, validators[manifestURL])
 		if err != nil {
 			// Log error but continue with other packages
-			fmt.Printf("Warning: failed to fetch manifest for %s: %v\n", pkg.Name, err)
+			fmt.Printf("Warning: failed to fetch manifest for %s: %v\n", r.Qualify(pkg.Name), err)
+			summary.Skipped++
 			continue
 		}
 		
 		// Validate manifest
+		// Validators are kept only for a manifest that was cached, so one that failed
+		// is fetched in full next time
 		m, err := manifest.LoadFromBytes(manifestData)
 		if err != nil {
-			fmt.Printf("Warning: failed to parse manifest for %s: %v\n", pkg.Name, err)
+			fmt.Printf("Warning: failed to parse manifest for %s: %v\n", r.Qualify(pkg.Name), err)
+			delete(validators, manifestURL)
+			summary.Skipped++
 			continue
 		}
 		
 		if err := manifest.Validate(m); err != nil {
-			fmt.Printf("Warning: invalid manifest for %s: %v\n", pkg.Name, err)
+			fmt.Printf("Warning: invalid manifest for %s: %v\n", r.Qualify(pkg.Name), err)
+			delete(validators, manifestURL)
+			summary.Skipped++
 			continue
 		}
 		
 		// Save manifest
-		manifestPath := platform.PackageManifestPath(pkg.Name)
-		if err := os.WriteFile(manifestPath, manifestData, 0644); err != nil {
-			fmt.Printf("Warning: failed to write manifest for %s: %v\n", pkg.Name, err)
+		if unchanged {
+			summary.Unchanged++
+		} else if err := fsutil.WriteFileAtomic(manifestPath, manifestData, 0644); err != nil {
+			fmt.Printf("Warning: failed to write manifest for %s: %v\n", r.Qualify(pkg.Name), err)
+			delete(validators, manifestURL)
+			summary.Skipped++
 			continue
 		}
+		validators.record(manifestURL, served)
+		
+		summary.summarize(r.Qualify(m.Name), m, platforms)
+		manifests[pkg.Name] = m
+	}
+
+	// Search, list and which read the search index instead of every manifest
+	if err := r.writeSearchIndex(BuildSearchIndex(index, manifests)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := r.saveValidators(validators); err != nil {
+		fmt.Printf("Warning: %v\n", err)
 	}
 	
 	return nil
 }
 
-// LoadPackage loads a package manifest (from cache or remote)
-func (r *Registry) LoadPackage(ctx context.Context, name string) (*manifest.Manifest, error) {
-	// Try to load from cache first
-	manifestPath := platform.PackageManifestPath(name)
-	if data, err := os.ReadFile(manifestPath); err == nil {
-		m, err := manifest.LoadFromBytes(data)
-		if err == nil {
-			// Validate cached manifest
-			if err := manifest.Validate(m); err == nil {
-				return m, nil
+// cachedPackage loads a package manifest from this registry's cache only, without touching the network.
+// A cached manifest that no longer parses or validates, e.g. one truncated by a crash, is
+// reported as corrupt and left for loadPackage or Update to replace.
+func (r *Registry) cachedPackage(name string) (*manifest.Manifest, error) {
+	data, err := os.ReadFile(r.manifestPath(name))
+	if err != nil {
+		return nil, err
+	}
+	
+	m, err := manifest.LoadFromBytes(data)
+	if err == nil {
+		// Validate cached manifest
+		err = manifest.Validate(m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cached manifest for %s: %w", name, err)
+	}
+	
+	return m, nil
+}
+
+// CachedPackages loads the cached manifests of names in parallel, without touching the
+// network. Packages that aren't cached, or whose cached manifest is corrupt, are left out.
+func (r *Registry) CachedPackages(names []string) map[string]*manifest.Manifest {
+	return loadAll(names, r.CachedPackage)
+}
+
+// loadAll calls load for each of names in parallel, returning the manifests it found
+func loadAll(names []string, load func(string) (*manifest.Manifest, error)) map[string]*manifest.Manifest {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	manifests := make(map[string]*manifest.Manifest, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if m, err := load(name); err == nil {
+				mu.Lock()
+				manifests[name] = m
+				mu.Unlock()
 			}
-		}
+		}(name)
+	}
+	wg.Wait()
+	return manifests
+}
+
+// loadPackage loads a package manifest of this registry (from cache or remote)
+func (r *Registry) loadPackage(ctx context.Context, name string) (*manifest.Manifest, error) {
+	// Try to load from cache first
+	if m, err := r.cachedPackage(name); err == nil {
+		return m, nil
 	}
 	
 	// If cache miss or invalid, fetch from remote
@@ -151,46 +398,51 @@ func (r *Registry) LoadPackage(ctx context.Context, name string) (*manifest.Mani
 	}
 	
 	// Cache the manifest
-	manifestPath = platform.PackageManifestPath(name)
-	registryDir := platform.RegistryDir()
-	packagesDir := filepath.Join(registryDir, "packages")
+	manifestPath := r.manifestPath(name)
+	packagesDir := filepath.Join(r.dir, "packages")
 	if err := os.MkdirAll(packagesDir, 0755); err == nil {
-		_ = os.WriteFile(manifestPath, manifestData, 0644)
+		_ = fsutil.WriteFileAtomic(manifestPath, manifestData, 0644)
 	}
 	
 	return m, nil
 }
 
-// Search searches the registry index for packages matching the query
-func (r *Registry) Search(ctx context.Context, query string) ([]PackageMeta, error) {
-	// Load index from cache or fetch
-	indexPath := platform.IndexPath()
-	var indexData []byte
+// cachedIndex loads this registry's index from the local cache only, without touching the network.
+// A cached index that no longer parses is reported as corrupt and left for Update to replace.
+func (r *Registry) cachedIndex() (*Index, error) {
+	data, err := os.ReadFile(r.indexPath())
+	if err != nil {
+		return nil, err
+	}
 	
-	if data, err := os.ReadFile(indexPath); err == nil {
-		indexData = data
-	} else {
-		// Fetch index
+	index, err := ParseIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cached index (run `nori update` to replace it): %w", err)
+	}
+	return index, nil
+}
+
+// search returns this registry's packages whose name, description, bins or tags contain
+// query, from the cached search index, or the registry index if nothing is cached yet
+func (r *Registry) search(ctx context.Context, query string) ([]PackageMeta, error) {
+	// Load index from cache or fetch
+	index, err := r.cachedSearchIndex()
+	if err != nil {
 		indexURL := strings.TrimSuffix(r.BaseURL, "/") + "/index.yaml"
-		var err error
-		indexData, err = r.fetch(ctx, indexURL)
+		indexData, err := r.fetch(ctx, indexURL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch index: %w", err)
 		}
-	}
-	
-	// Parse index
-	var index Index
-	if err := yaml.Unmarshal(indexData, &index); err != nil {
-		return nil, fmt.Errorf("failed to parse index: %w", err)
+		if index, err = ParseIndex(indexData); err != nil {
+			return nil, err
+		}
 	}
 	
 	// Search for matching packages
 	query = strings.ToLower(query)
 	var results []PackageMeta
 	for _, pkg := range index.Packages {
-		if strings.Contains(strings.ToLower(pkg.Name), query) ||
-			strings.Contains(strings.ToLower(pkg.Description), query) {
+		if pkg.Matches(query) {
 			results = append(results, pkg)
 		}
 	}
@@ -198,28 +450,9 @@ func (r *Registry) Search(ctx context.Context, query string) ([]PackageMeta, err
 	return results, nil
 }
 
-// fetch performs an HTTP GET request
+// fetch performs an HTTP GET request, or reads the file a file URL names
 func (r *Registry) fetch(ctx context.Context, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
-	
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	
-	return data, nil
+	data, _, _, err := r.fetchConditional(ctx, url, "", validator{})
+	return data, err
 }
 
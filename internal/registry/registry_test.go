@@ -2,6 +2,10 @@ package registry
 
 import (
 	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -9,6 +13,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/chirag-bruno/nori/internal/fetch"
 	"github.com/chirag-bruno/nori/internal/platform"
 	"gopkg.in/yaml.v3"
 )
@@ -47,25 +52,41 @@ versions:
 	}))
 	defer server.Close()
 
-	// Create temp directory for registry cache
-	tmpDir := t.TempDir()
-	registryDir := filepath.Join(tmpDir, "registry")
-	os.MkdirAll(registryDir, 0755)
+	// Keep the registry cache out of the real home directory
+	paths := platform.NewPaths(t.TempDir())
 
-	// Override the registry directory for testing
-	originalRegistryDir := platform.RegistryDir
-	defer func() {
-		// Can't easily override, so we'll test with actual paths
-		_ = originalRegistryDir
-	}()
-
-	reg := New(server.URL)
+	reg := New(server.URL, paths)
 
 	ctx := context.Background()
-	err := reg.Update(ctx)
+	summary, err := reg.Update(ctx)
 	if err != nil {
 		t.Fatalf("Update() failed: %v", err)
 	}
+
+	// python has no manifest; node ships 22.2.0 for linux-amd64 only
+	if summary.Packages != 1 || summary.Skipped != 1 || summary.Versions != 1 || summary.Assets != 1 || summary.Platforms != 1 {
+		t.Errorf("Update() summary = %+v, want 1 package, 1 skipped, 1 version, 1 asset, 1 platform", summary)
+	}
+	if len(summary.Gaps) != 1 || summary.Gaps[0].Package != "node" || summary.Gaps[0].Version != "22.2.0" {
+		t.Fatalf("Update() gaps = %+v, want node@22.2.0", summary.Gaps)
+	}
+	if got := strings.Join(summary.Gaps[0].Missing, " "); got != "linux-arm64 darwin-amd64 darwin-arm64 windows-amd64" {
+		t.Errorf("node missing platforms = %q", got)
+	}
+
+	if _, err := os.Stat(filepath.Join(paths.Root, "registry", "index.yaml")); err != nil {
+		t.Errorf("index.yaml was not cached under the nori root: %v", err)
+	}
+	if _, err := os.Stat(paths.PackageManifestPath("node")); err != nil {
+		t.Errorf("node manifest was not cached: %v", err)
+	}
+	search, err := reg.CachedSearchIndex()
+	if err != nil {
+		t.Fatalf("CachedSearchIndex() after Update() failed: %v", err)
+	}
+	if node := search.Find("node"); node == nil || node.Latest != "22.2.0" || len(node.Bins) != 1 || node.Bins[0] != "node" {
+		t.Errorf("search index entry for node = %+v", node)
+	}
 }
 
 func TestRegistryLoadPackage(t *testing.T) {
@@ -93,7 +114,7 @@ versions:
 	}))
 	defer server.Close()
 
-	reg := New(server.URL)
+	reg := New(server.URL, platform.NewPaths(t.TempDir()))
 
 	ctx := context.Background()
 	m, err := reg.LoadPackage(ctx, "testnode")
@@ -128,7 +149,7 @@ func TestRegistrySearch(t *testing.T) {
 	}))
 	defer server.Close()
 
-	reg := New(server.URL)
+	reg := New(server.URL, platform.NewPaths(t.TempDir()))
 
 	ctx := context.Background()
 
@@ -159,6 +180,60 @@ func TestRegistrySearch(t *testing.T) {
 	}
 }
 
+func TestRegistryHTTPProxy(t *testing.T) {
+	var requested []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+		w.Write([]byte("packages:\n  - name: node\n    description: Node.js runtime\n"))
+	}))
+	defer proxy.Close()
+
+	paths := platform.NewPaths(t.TempDir())
+	reg := New("http://registry.example.com", paths)
+	if err := reg.SetHTTPProxy(proxy.URL, ""); err != nil {
+		t.Fatalf("SetHTTPProxy() failed: %v", err)
+	}
+
+	results, err := reg.Search(context.Background(), "node")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search() through proxy = %v, %v, want node", results, err)
+	}
+	if len(requested) != 1 || requested[0] != "http://registry.example.com/index.yaml" {
+		t.Errorf("proxy requests = %v, want the index", requested)
+	}
+
+	if err := New(proxy.URL, paths).SetHTTPProxy("not a url", ""); err == nil {
+		t.Error("SetHTTPProxy() should reject an invalid proxy")
+	}
+}
+
+func TestRegistryCAFile(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("packages:\n  - name: node\n    description: Node.js runtime\n"))
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	paths := platform.NewPaths(t.TempDir())
+
+	// The test server's certificate isn't one the system trusts
+	if _, err := New(server.URL, paths).Search(context.Background(), "node"); err == nil {
+		t.Fatal("Search() trusted an unknown CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	tlsConfig, err := fetch.TLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("TLSConfig() failed: %v", err)
+	}
+	reg := New(server.URL, paths)
+	reg.SetTLSConfig(tlsConfig)
+	if results, err := reg.Search(context.Background(), "node"); err != nil || len(results) != 1 {
+		t.Errorf("Search() with the CA trusted = %v, %v, want node", results, err)
+	}
+}
+
 func TestRegistryBaseURLFromEnv(t *testing.T) {
 	// Test that registry URL can be loaded from environment
 	originalURL := os.Getenv("NORI_REGISTRY_URL")
@@ -171,10 +246,8 @@ func TestRegistryBaseURLFromEnv(t *testing.T) {
 	}()
 
 	os.Setenv("NORI_REGISTRY_URL", "https://custom-registry.example.com")
-	reg := NewFromEnv()
-
-	if reg.BaseURL != "https://custom-registry.example.com" {
-		t.Errorf("NewFromEnv() BaseURL = %q, want %q", reg.BaseURL, "https://custom-registry.example.com")
+	if got := DefaultURL(""); got != "https://custom-registry.example.com" {
+		t.Errorf("DefaultURL() = %q, want %q", got, "https://custom-registry.example.com")
 	}
 }
 
@@ -190,18 +263,26 @@ func TestRegistryDefaultURL(t *testing.T) {
 	}()
 
 	os.Unsetenv("NORI_REGISTRY_URL")
-	reg := NewFromEnv()
 
 	// Should have a default URL (not empty)
-	if reg.BaseURL == "" {
-		t.Error("NewFromEnv() BaseURL should not be empty when env var is not set")
+	if DefaultURL("") == "" {
+		t.Error("DefaultURL() should not be empty when env var is not set")
+	}
+
+	// The registry_url setting replaces it, and NORI_REGISTRY_URL the setting
+	if got := DefaultURL("https://mirror.example.com"); got != "https://mirror.example.com" {
+		t.Errorf("DefaultURL() = %q, want the registry_url setting", got)
+	}
+	t.Setenv("NORI_REGISTRY_URL", "https://custom-registry.example.com")
+	if got := DefaultURL("https://mirror.example.com"); got != "https://custom-registry.example.com" {
+		t.Errorf("DefaultURL() = %q, want NORI_REGISTRY_URL", got)
 	}
 }
 
 // TestGitHubURLConstruction verifies that URLs are constructed correctly for GitHub raw content
 func TestGitHubURLConstruction(t *testing.T) {
 	baseURL := "https://raw.githubusercontent.com/user/repo/main"
-	reg := New(baseURL)
+	reg := New(baseURL, platform.NewPaths(t.TempDir()))
 
 	// Test index URL construction
 	expectedIndexURL := baseURL + "/index.yaml"
@@ -219,7 +300,7 @@ func TestGitHubURLConstruction(t *testing.T) {
 
 	// Test with trailing slash
 	baseURLWithSlash := baseURL + "/"
-	reg2 := New(baseURLWithSlash)
+	reg2 := New(baseURLWithSlash, platform.NewPaths(t.TempDir()))
 	actualIndexURL2 := strings.TrimSuffix(reg2.BaseURL, "/") + "/index.yaml"
 	if actualIndexURL2 != expectedIndexURL {
 		t.Errorf("Index URL with trailing slash = %q, want %q", actualIndexURL2, expectedIndexURL)
@@ -232,7 +313,7 @@ func TestGitHubRawContentFormat(t *testing.T) {
 	// It doesn't make actual HTTP requests, but verifies URL format
 
 	baseURL := "https://raw.githubusercontent.com/chirag-bruno/nori-registry/main"
-	reg := New(baseURL)
+	reg := New(baseURL, platform.NewPaths(t.TempDir()))
 
 	// Expected structure:
 	// https://raw.githubusercontent.com/chirag-bruno/nori-registry/main/index.yaml
@@ -273,7 +354,8 @@ func TestRegistryIntegrationWithGitHub(t *testing.T) {
 		t.Fatalf("NORI_TEST_REGISTRY_URL must be a GitHub raw content URL, got: %q", testRegistryURL)
 	}
 
-	reg := New(testRegistryURL)
+	paths := platform.NewPaths(t.TempDir())
+	reg := New(testRegistryURL, paths)
 	ctx := context.Background()
 
 	// Test fetching index via Search (which fetches index.yaml)
@@ -287,7 +369,7 @@ func TestRegistryIntegrationWithGitHub(t *testing.T) {
 	}
 
 	// Verify index was cached
-	indexData, err := os.ReadFile(platform.IndexPath())
+	indexData, err := os.ReadFile(paths.IndexPath())
 	if err != nil {
 		t.Fatalf("Failed to read cached index: %v", err)
 	}
@@ -330,3 +412,200 @@ func TestRegistryIntegrationWithGitHub(t *testing.T) {
 		}
 	}
 }
+
+func TestParseIndex(t *testing.T) {
+	index, err := ParseIndex([]byte(`packages:
+  - name: node
+    description: Node.js runtime
+  - name: python
+    description: Python
+`))
+	if err != nil {
+		t.Fatalf("ParseIndex() failed: %v", err)
+	}
+	if len(index.Packages) != 2 {
+		t.Fatalf("ParseIndex() packages = %d, want 2", len(index.Packages))
+	}
+	if index.Packages[1].Name != "python" {
+		t.Errorf("Packages[1].Name = %q, want %q", index.Packages[1].Name, "python")
+	}
+
+	if _, err := ParseIndex([]byte("packages: [")); err == nil {
+		t.Error("ParseIndex() should fail for malformed YAML")
+	}
+}
+
+func TestRegistryCachedLookups(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	reg := New("https://registry.invalid", paths)
+
+	if _, err := reg.CachedIndex(); err == nil {
+		t.Error("CachedIndex() should fail before the index is cached")
+	}
+	if _, err := reg.CachedPackage("node"); err == nil {
+		t.Error("CachedPackage() should fail before the manifest is cached")
+	}
+
+	os.MkdirAll(filepath.Dir(paths.PackageManifestPath("node")), 0755)
+	os.WriteFile(paths.IndexPath(), []byte("packages:\n  - name: node\n"), 0644)
+	os.WriteFile(paths.PackageManifestPath("node"), []byte(`schema: 1
+name: node
+bins:
+  - bin/node
+versions:
+  "22.2.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/node.tar.gz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`), 0644)
+
+	index, err := reg.CachedIndex()
+	if err != nil {
+		t.Fatalf("CachedIndex() failed: %v", err)
+	}
+	if len(index.Packages) != 1 || index.Packages[0].Name != "node" {
+		t.Errorf("CachedIndex() packages = %v, want [node]", index.Packages)
+	}
+
+	m, err := reg.CachedPackage("node")
+	if err != nil {
+		t.Fatalf("CachedPackage() failed: %v", err)
+	}
+	if m.LatestVersion() != "22.2.0" {
+		t.Errorf("CachedPackage() latest = %q, want %q", m.LatestVersion(), "22.2.0")
+	}
+
+	manifests := reg.CachedPackages([]string{"node", "python"})
+	if len(manifests) != 1 || manifests["node"] == nil {
+		t.Errorf("CachedPackages() = %v, want only node", manifests)
+	}
+}
+
+func BenchmarkParseIndex(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("packages:\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "  - name: package-%d\n    description: Test package number %d\n", i, i)
+	}
+	data := []byte(sb.String())
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseIndex(data); err != nil {
+			b.Fatalf("ParseIndex() failed: %v", err)
+		}
+	}
+}
+
+func TestSortPackages(t *testing.T) {
+	index, err := ParseIndex([]byte(`packages:
+  - name: plain
+    description: No stats
+  - name: old
+    description: Popular but stale
+    downloads: 90000
+    updated: 2023-01-10
+  - name: new
+    description: Recently updated
+    downloads: 1200
+    updated: 2024-06-01T12:00:00Z
+`))
+	if err != nil {
+		t.Fatalf("ParseIndex() failed: %v", err)
+	}
+	if got := index.Find("old"); got == nil || got.Downloads != 90000 {
+		t.Fatalf("Find(old) = %+v, want 90000 downloads", got)
+	}
+	if index.Find("missing") != nil {
+		t.Error("Find(missing) should return nil")
+	}
+
+	names := func(pkgs []PackageMeta) string {
+		var out []string
+		for _, pkg := range pkgs {
+			out = append(out, pkg.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{SortPopularity, "old,new,plain"},
+		{SortUpdated, "new,old,plain"},
+	}
+	for _, tt := range tests {
+		pkgs := append([]PackageMeta(nil), index.Packages...)
+		if err := SortPackages(pkgs, tt.by); err != nil {
+			t.Fatalf("SortPackages(%s) failed: %v", tt.by, err)
+		}
+		if got := names(pkgs); got != tt.want {
+			t.Errorf("SortPackages(%s) = %s, want %s", tt.by, got, tt.want)
+		}
+	}
+
+	if err := SortPackages(index.Packages, "stars"); err == nil {
+		t.Error("SortPackages() should reject unknown orders")
+	}
+}
+
+func TestRegistryReplacesTruncatedCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			w.Write([]byte("packages:\n  - name: node\n    description: Node.js runtime\n"))
+		case "/packages/node.yaml":
+			w.Write([]byte(`schema: 1
+name: node
+bins:
+  - bin/node
+versions:
+  "22.2.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/node.tar.gz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	paths := platform.NewPaths(t.TempDir())
+	reg := New(server.URL, paths)
+
+	// Simulate files cut short by a crash mid-write
+	os.MkdirAll(filepath.Dir(paths.PackageManifestPath("node")), 0755)
+	os.WriteFile(paths.IndexPath(), []byte("packages:\n  - name: node\n    descr"), 0644)
+	os.WriteFile(paths.PackageManifestPath("node"), []byte("schema: 1\nname: node\nbins:\n  - bin/node\nversions:\n  \"22.2.0\":\n    platf"), 0644)
+
+	if _, err := reg.CachedPackage("node"); err == nil {
+		t.Fatal("CachedPackage() should reject a truncated manifest")
+	}
+	if _, err := reg.CachedPackage("node"); err == nil || !strings.Contains(err.Error(), "corrupt cached manifest") {
+		t.Errorf("CachedPackage() of a truncated manifest again = %v, want it left in place", err)
+	}
+
+	m, err := reg.LoadPackage(context.Background(), "node")
+	if err != nil || m.LatestVersion() != "22.2.0" {
+		t.Errorf("LoadPackage() = %v, %v, want the manifest refetched", m, err)
+	}
+
+	results, err := reg.Search(context.Background(), "node")
+	if err != nil || len(results) != 1 || results[0].Description != "Node.js runtime" {
+		t.Errorf("Search() = %v, %v, want the index refetched", results, err)
+	}
+	if _, err := reg.CachedPackage("node"); err != nil {
+		t.Errorf("CachedPackage() after LoadPackage() = %v, want the refetched manifest cached", err)
+	}
+	if data, _ := os.ReadFile(paths.IndexPath()); !strings.HasSuffix(string(data), "descr") {
+		t.Errorf("Search() changed the cached index to %q, want it left for update", data)
+	}
+}
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Matches reports whether query, in lower case, appears in the package's name,
+// description, bins or tags. The empty query matches every package.
+func (p *PackageMeta) Matches(query string) bool {
+	if strings.Contains(strings.ToLower(p.Name), query) || strings.Contains(strings.ToLower(p.Description), query) {
+		return true
+	}
+	for _, word := range append(append([]string(nil), p.Bins...), p.Tags...) {
+		if strings.Contains(strings.ToLower(word), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildSearchIndex returns index with each entry filled in from the package's manifest
+// in manifests: its homepage, bin names, tags, latest version and the platforms any
+// version ships for. A group ships for the platforms all its members ship for at the
+// versions it pins. Packages without a manifest keep their index entry as it is.
+func BuildSearchIndex(index *Index, manifests map[string]*manifest.Manifest) *Index {
+	search := &Index{Packages: make([]PackageMeta, len(index.Packages))}
+	for i, pkg := range index.Packages {
+		m := manifests[pkg.Name]
+		if m == nil {
+			search.Packages[i] = pkg
+			continue
+		}
+		pkg.Homepage = m.Homepage
+		pkg.Tags = m.Tags
+		pkg.Bins = nil
+		for _, bin := range m.Bins {
+			pkg.Bins = append(pkg.Bins, baseName(bin))
+		}
+		if !m.IsGroup() {
+			pkg.Latest = m.LatestVersion()
+		}
+		pkg.Platforms = shipsFor(m, "", manifests)
+		search.Packages[i] = pkg
+	}
+	return search
+}
+
+// shipsFor returns the sorted platforms that m ships for in a version matching the range
+// version, or in any version if version is "", looking up the members of groups in
+// manifests
+func shipsFor(m *manifest.Manifest, version string, manifests map[string]*manifest.Manifest) []string {
+	if m.IsGroup() {
+		var common []string
+		first := true
+		for name, pinned := range m.Members {
+			member := manifests[name]
+			if member == nil || member.IsGroup() {
+				return nil
+			}
+			plats := shipsFor(member, pinned, manifests)
+			if first {
+				common, first = plats, false
+				continue
+			}
+			common = slices.DeleteFunc(common, func(plat string) bool { return !slices.Contains(plats, plat) })
+		}
+		return common
+	}
+
+	var r manifest.Range
+	if version != "" {
+		var err error
+		if r, err = manifest.ParseRange(version); err != nil {
+			return nil
+		}
+	}
+	seen := make(map[string]bool)
+	for v, ver := range m.Versions {
+		if version != "" {
+			if sv, err := manifest.ParseVersion(v); err != nil || !r.Matches(sv) {
+				continue
+			}
+		}
+		for plat := range ver.Platforms {
+			seen[plat] = true
+		}
+	}
+	plats := make([]string, 0, len(seen))
+	for plat := range seen {
+		plats = append(plats, plat)
+	}
+	sort.Strings(plats)
+	return plats
+}
+
+// baseName returns the last element of a manifest bin path, such as node for bin/node
+func baseName(bin string) string {
+	return bin[strings.LastIndex(bin, "/")+1:]
+}
+
+// cachedSearchIndex loads the search index that update builds for this registry, without
+// touching the network. When it is missing or older than the cached index, as after an
+// update by an earlier release, it is rebuilt from the cached index and manifests.
+func (r *Registry) cachedSearchIndex() (*Index, error) {
+	searchInfo, searchErr := os.Stat(r.searchIndexPath())
+	indexInfo, indexErr := os.Stat(r.indexPath())
+	if searchErr == nil && (indexErr != nil || !indexInfo.ModTime().After(searchInfo.ModTime())) {
+		data, err := os.ReadFile(r.searchIndexPath())
+		if err == nil {
+			if search, err := ParseIndex(data); err == nil {
+				return search, nil
+			}
+		}
+	}
+
+	index, err := r.cachedIndex()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(index.Packages))
+	for i, pkg := range index.Packages {
+		names[i] = pkg.Name
+	}
+	search := BuildSearchIndex(index, loadAll(names, r.cachedPackage))
+	_ = r.writeSearchIndex(search)
+	return search, nil
+}
+
+// writeSearchIndex caches search as the search index
+func (r *Registry) writeSearchIndex(search *Index) error {
+	data, err := yaml.Marshal(search)
+	if err != nil {
+		return fmt.Errorf("failed to marshal search index: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(r.searchIndexPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+func versionFor(platforms ...string) manifest.Version {
+	ver := manifest.Version{Platforms: make(map[string]manifest.Asset)}
+	for _, plat := range platforms {
+		ver.Platforms[plat] = manifest.Asset{Type: "tar", URL: "https://example.com/" + plat + ".tar.gz"}
+	}
+	return ver
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	index := &Index{Packages: []PackageMeta{
+		{Name: "node", Description: "Node.js runtime"},
+		{Name: "pnpm", Description: "Package manager"},
+		{Name: "web", Description: "Web toolchain"},
+		{Name: "python", Description: "Python"},
+	}}
+	manifests := map[string]*manifest.Manifest{
+		"node": {
+			Name:     "node",
+			Homepage: "https://nodejs.org",
+			Bins:     []string{"bin/node", "bin/npm"},
+			Tags:     []string{"javascript"},
+			Versions: map[string]manifest.Version{
+				"20.0.0": versionFor("linux-amd64", "darwin-arm64"),
+				"22.2.0": versionFor("linux-amd64"),
+			},
+		},
+		"pnpm": {
+			Name: "pnpm",
+			Bins: []string{"pnpm"},
+			Versions: map[string]manifest.Version{
+				"9.0.0": versionFor("linux-amd64", "darwin-arm64"),
+			},
+		},
+		"web": {
+			Name:    "web",
+			Members: map[string]string{"node": "22.2.0", "pnpm": "9.0.0"},
+		},
+	}
+
+	search := BuildSearchIndex(index, manifests)
+	node := search.Find("node")
+	if node.Homepage != "https://nodejs.org" || node.Latest != "22.2.0" {
+		t.Errorf("node homepage, latest = %q, %q", node.Homepage, node.Latest)
+	}
+	if !reflect.DeepEqual(node.Bins, []string{"node", "npm"}) || !reflect.DeepEqual(node.Tags, []string{"javascript"}) {
+		t.Errorf("node bins, tags = %v, %v", node.Bins, node.Tags)
+	}
+	// Any version counts for a package
+	if !reflect.DeepEqual(node.Platforms, []string{"darwin-arm64", "linux-amd64"}) {
+		t.Errorf("node platforms = %v", node.Platforms)
+	}
+	// A group needs every member at its pinned version
+	if web := search.Find("web"); !reflect.DeepEqual(web.Platforms, []string{"linux-amd64"}) || web.Latest != "" {
+		t.Errorf("web platforms, latest = %v, %q", web.Platforms, web.Latest)
+	}
+	if python := search.Find("python"); !reflect.DeepEqual(*python, index.Packages[3]) {
+		t.Errorf("python without a manifest = %+v, want it unchanged", *python)
+	}
+	if index.Packages[0].Bins != nil {
+		t.Error("BuildSearchIndex() modified the index")
+	}
+
+	for query, want := range map[string]bool{"": true, "npm": true, "javascript": true, "runtime": true, "node": true, "python": false} {
+		if got := node.Matches(query); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestCachedSearchIndex(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	reg := New("https://registry.invalid", paths)
+
+	if _, err := reg.CachedSearchIndex(); err == nil {
+		t.Error("CachedSearchIndex() should fail before the index is cached")
+	}
+
+	// An index cached without a search index, as by an earlier release
+	os.MkdirAll(filepath.Dir(paths.PackageManifestPath("node")), 0755)
+	os.WriteFile(paths.IndexPath(), []byte("packages:\n  - name: node\n"), 0644)
+	os.WriteFile(paths.PackageManifestPath("node"), []byte(`schema: 1
+name: node
+bins:
+  - bin/node
+tags:
+  - javascript
+versions:
+  "22.2.0":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/node.tar.gz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`), 0644)
+
+	search, err := reg.CachedSearchIndex()
+	if err != nil {
+		t.Fatalf("CachedSearchIndex() failed: %v", err)
+	}
+	if node := search.Find("node"); node == nil || !node.Matches("javascript") {
+		t.Fatalf("rebuilt search index = %+v, want node tagged javascript", search.Packages)
+	}
+	if _, err := os.Stat(paths.SearchIndexPath()); err != nil {
+		t.Errorf("rebuilt search index was not cached: %v", err)
+	}
+
+	// A newer index makes the search index stale
+	os.WriteFile(paths.IndexPath(), []byte("packages:\n  - name: node\n  - name: python\n"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(paths.IndexPath(), later, later)
+	search, err = reg.CachedSearchIndex()
+	if err != nil {
+		t.Fatalf("CachedSearchIndex() failed: %v", err)
+	}
+	if len(search.Packages) != 2 || search.Find("python") == nil {
+		t.Errorf("stale search index was not rebuilt: %+v", search.Packages)
+	}
+}
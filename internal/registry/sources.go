@@ -0,0 +1,351 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+)
+
+// DefaultName is the name the default registry goes by next to configured ones
+const DefaultName = "default"
+
+// sourceNamePattern is what registry names and prefixes look like
+var sourceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-_]{0,63}$`)
+
+// Source is a registry looked up before the default one; see AddRegistry
+type Source struct {
+	Name   string
+	URL    string
+	Prefix string
+}
+
+// ValidateSources checks the registries setting: each registry needs a unique name, a
+// URL that ValidateURL accepts and, optionally, a unique prefix
+func ValidateSources(sources []Source) error {
+	names := map[string]bool{DefaultName: true}
+	prefixes := make(map[string]bool)
+	for _, source := range sources {
+		if err := validateSource(source); err != nil {
+			return err
+		}
+		if names[source.Name] {
+			return fmt.Errorf("registry name %q is already taken", source.Name)
+		}
+		names[source.Name] = true
+		if source.Prefix != "" {
+			if prefixes[source.Prefix] {
+				return fmt.Errorf("registry prefix %q is used by two registries", source.Prefix)
+			}
+			prefixes[source.Prefix] = true
+		}
+	}
+	return nil
+}
+
+// validateSource checks one entry of the registries setting on its own
+func validateSource(source Source) error {
+	if !sourceNamePattern.MatchString(source.Name) {
+		return fmt.Errorf("invalid registry name %q: use lower-case letters, digits, - and _", source.Name)
+	}
+	if source.Prefix != "" && !sourceNamePattern.MatchString(source.Prefix) {
+		return fmt.Errorf("invalid prefix %q of registry %s: use lower-case letters, digits, - and _", source.Prefix, source.Name)
+	}
+	if err := ValidateURL(source.URL); err != nil {
+		return fmt.Errorf("registry %s: %w", source.Name, err)
+	}
+	return nil
+}
+
+// ValidateURL checks that rawURL can be the base URL of a registry: an HTTP(S) URL, or a
+// file URL or absolute path of a registry on disk
+func ValidateURL(rawURL string) error {
+	if filepath.IsAbs(rawURL) {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	switch {
+	case err != nil:
+	case u.Scheme == "https" || u.Scheme == "http":
+		if u.Host != "" {
+			return nil
+		}
+	case u.Scheme == "file":
+		if u.Path != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid URL %q: expected one such as https://registry.example.com or file:///srv/nori-registry", rawURL)
+}
+
+// AddRegistry looks packages up in the registry at baseURL, called name, before r and
+// any registry added earlier, sharing r's HTTP client. With a prefix, its packages are
+// only found by their qualified names, such as corp/tooling for prefix corp.
+func (r *Registry) AddRegistry(name, baseURL, prefix string) *Registry {
+	source := &Registry{
+		BaseURL: normalizeBaseURL(baseURL),
+		Name:    name,
+		Prefix:  prefix,
+		paths:   r.paths,
+		dir:     filepath.Join(r.paths.RegistryDir(), "registries", name),
+		client:  r.client,
+	}
+	r.configured = append(r.configured, source)
+	return source
+}
+
+// Registries returns the registries packages are looked up in, in order: the configured
+// ones, then r
+func (r *Registry) Registries() []*Registry {
+	return append(append([]*Registry(nil), r.configured...), r)
+}
+
+// Label returns the name of the registry as shown to users
+func (r *Registry) Label() string {
+	if r.Name == "" {
+		return DefaultName
+	}
+	return r.Name
+}
+
+// Qualify returns the name the package name of this registry goes by: name itself, or
+// prefix/name for a registry with a prefix
+func (r *Registry) Qualify(name string) string {
+	if r.Prefix == "" {
+		return name
+	}
+	return r.Prefix + "/" + name
+}
+
+// qualifyManifest records that m comes from r and renames it, and the members of a
+// group, to their qualified names
+func (r *Registry) qualifyManifest(m *manifest.Manifest) *manifest.Manifest {
+	m.Registry = r.Label()
+	if r.Prefix == "" {
+		return m
+	}
+	m.Name = r.Qualify(m.Name)
+	if m.IsGroup() {
+		members := make(map[string]string, len(m.Members))
+		for member, version := range m.Members {
+			members[r.Qualify(member)] = version
+		}
+		m.Members = members
+	}
+	return m
+}
+
+// candidates returns the registries that may have the package name, in lookup order,
+// and its name within them. A qualified name, such as corp/tooling, names the registry
+// with that prefix; a plain name is looked up in every registry without one.
+func (r *Registry) candidates(name string) ([]*Registry, string, error) {
+	if prefix, plain, ok := strings.Cut(name, "/"); ok {
+		for _, source := range r.configured {
+			if source.Prefix == prefix {
+				return []*Registry{source}, plain, nil
+			}
+		}
+		return nil, "", fmt.Errorf("no registry is configured with the prefix %q", prefix)
+	}
+
+	var sources []*Registry
+	for _, source := range r.Registries() {
+		if source.Prefix == "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources, name, nil
+}
+
+// indexPath returns where this registry's index is cached
+func (r *Registry) indexPath() string {
+	return filepath.Join(r.dir, "index.yaml")
+}
+
+// manifestPath returns where this registry's manifest of the package name is cached
+func (r *Registry) manifestPath(name string) string {
+	return filepath.Join(r.dir, "packages", name+".yaml")
+}
+
+// searchIndexPath returns where this registry's search index is cached
+func (r *Registry) searchIndexPath() string {
+	return filepath.Join(r.dir, "search.yaml")
+}
+
+// ClearCache removes this registry's cached index, search index and manifests, as when it
+// is removed or its URL changes. The cache of a configured registry goes altogether.
+func (r *Registry) ClearCache() error {
+	cached := []string{r.dir}
+	if r.Name == "" {
+		// The configured registries are cached inside the default one's directory
+		cached = []string{r.indexPath(), r.searchIndexPath(), r.validatorsPath(), filepath.Join(r.dir, "packages")}
+	}
+	for _, path := range cached {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to clear the cache of registry %s: %w", r.Label(), err)
+		}
+	}
+	return nil
+}
+
+// Update fetches the index of every registry and caches their package manifests,
+// returning a summary of what was refreshed. A configured registry that can't be
+// reached is reported and skipped, unless none can be.
+func (r *Registry) Update(ctx context.Context) (*UpdateSummary, error) {
+	summary := &UpdateSummary{}
+	platforms := make(map[string]bool)
+	sources := r.Registries()
+	var firstErr error
+	failed := 0
+	for _, source := range sources {
+		err := source.update(ctx, summary, platforms)
+		if err == nil {
+			continue
+		}
+		if len(sources) == 1 {
+			return nil, err
+		}
+		fmt.Printf("Warning: failed to update registry %s: %v\n", source.Label(), err)
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+	}
+	if failed == len(sources) {
+		return nil, firstErr
+	}
+	return summary, nil
+}
+
+// CachedPackage loads a package manifest from the local cache only, without touching the
+// network, from the first registry that has it cached.
+// A cached manifest that no longer parses or validates, e.g. one truncated by a crash, is removed.
+func (r *Registry) CachedPackage(name string) (*manifest.Manifest, error) {
+	sources, plain, err := r.candidates(name)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, source := range sources {
+		m, err := source.cachedPackage(plain)
+		if err == nil {
+			return source.qualifyManifest(m), nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// Source returns the registry a manifest was loaded from, by the label it records, or
+// nil if no registry goes by it
+func (r *Registry) Source(m *manifest.Manifest) *Registry {
+	for _, source := range r.Registries() {
+		if source.Label() == m.Registry {
+			return source
+		}
+	}
+	return nil
+}
+
+// LoadPackage loads a package manifest (from cache or remote) from the first registry
+// that has it. Registries whose cached index doesn't list the package are passed over.
+func (r *Registry) LoadPackage(ctx context.Context, name string) (*manifest.Manifest, error) {
+	sources, plain, err := r.candidates(name)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, source := range sources {
+		if len(sources) > 1 {
+			if index, err := source.cachedIndex(); err == nil && index.Find(plain) == nil {
+				continue
+			}
+		}
+		m, err := source.loadPackage(ctx, plain)
+		if err == nil {
+			return source.qualifyManifest(m), nil
+		}
+		if len(sources) > 1 {
+			err = fmt.Errorf("registry %s: %w", source.Label(), err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("package %s is in none of the %d registries", name, len(sources))
+	}
+	return nil, firstErr
+}
+
+// CachedIndex loads the index of every registry from the local cache only, without
+// touching the network, with each package under its qualified name in lookup order.
+// A cached index that no longer parses is removed.
+func (r *Registry) CachedIndex() (*Index, error) {
+	return r.merge(func(source *Registry) ([]PackageMeta, error) {
+		index, err := source.cachedIndex()
+		if err != nil {
+			return nil, err
+		}
+		return index.Packages, nil
+	})
+}
+
+// CachedSearchIndex loads the search index of every registry, without touching the
+// network, as CachedIndex does
+func (r *Registry) CachedSearchIndex() (*Index, error) {
+	return r.merge(func(source *Registry) ([]PackageMeta, error) {
+		index, err := source.cachedSearchIndex()
+		if err != nil {
+			return nil, err
+		}
+		return index.Packages, nil
+	})
+}
+
+// Search returns the packages whose name, description, bins or tags contain query, from
+// every registry in lookup order, with the registry each comes from. Each registry's
+// cached search index is used, or its index if nothing is cached yet.
+func (r *Registry) Search(ctx context.Context, query string) ([]PackageMeta, error) {
+	index, err := r.merge(func(source *Registry) ([]PackageMeta, error) {
+		return source.search(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index.Packages, nil
+}
+
+// merge joins the packages list returns for each registry, qualifying their names and
+// noting their registry. Registries it fails for are left out, unless it fails for all.
+func (r *Registry) merge(list func(*Registry) ([]PackageMeta, error)) (*Index, error) {
+	merged := &Index{}
+	var firstErr error
+	found := false
+	for _, source := range r.Registries() {
+		pkgs, err := list(source)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, pkg := range pkgs {
+			pkg.Name = source.Qualify(pkg.Name)
+			pkg.Registry = source.Label()
+			merged.Packages = append(merged.Packages, pkg)
+		}
+	}
+	if !found {
+		return nil, firstErr
+	}
+	return merged, nil
+}
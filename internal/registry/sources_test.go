@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+)
+
+// sourceServer serves an index of the packages in manifests, in name order, and their manifests
+func sourceServer(t *testing.T, manifests map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.yaml" {
+			w.Write([]byte("packages:\n"))
+			for _, name := range slices.Sorted(maps.Keys(manifests)) {
+				w.Write([]byte("  - name: " + name + "\n"))
+			}
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/packages/"), ".yaml")
+		if m, ok := manifests[name]; ok {
+			w.Write([]byte(m))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// sourceManifest returns a manifest of name at version
+func sourceManifest(name, version string) string {
+	return `schema: 1
+name: ` + name + `
+bins:
+  - bin/` + name + `
+versions:
+  "` + version + `":
+    platforms:
+      linux-amd64:
+        type: tar
+        url: https://example.com/` + name + `.tar.gz
+        checksum: sha256:5f4a1234567890abcdef1234567890abcdef1234567890abcdef1234567890ab
+`
+}
+
+func TestRegistrySources(t *testing.T) {
+	public := sourceServer(t, map[string]string{
+		"node": sourceManifest("node", "22.2.0"),
+		"jq":   sourceManifest("jq", "1.7.1"),
+	})
+	corp := sourceServer(t, map[string]string{
+		"node": sourceManifest("node", "20.0.0"),
+	})
+	team := sourceServer(t, map[string]string{
+		"lint": sourceManifest("lint", "1.0.0"),
+		"kit":  "schema: 1\nname: kit\nmembers:\n  lint: 1.0.0\n",
+	})
+
+	reg := New(public.URL, platform.NewPaths(t.TempDir()))
+	reg.AddRegistry("corp", corp.URL, "")
+	reg.AddRegistry("team", team.URL, "team")
+	ctx := context.Background()
+
+	summary, err := reg.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if summary.Packages != 5 {
+		t.Errorf("Update() cached %d packages, want 5 across the registries", summary.Packages)
+	}
+
+	// Registries are looked up in order
+	if m, err := reg.LoadPackage(ctx, "node"); err != nil || m.LatestVersion() != "20.0.0" {
+		t.Errorf("LoadPackage(node) = %v, %v, want corp's 20.0.0", m, err)
+	}
+	if m, err := reg.LoadPackage(ctx, "jq"); err != nil || m.Name != "jq" {
+		t.Errorf("LoadPackage(jq) = %v, %v, want the default registry's", m, err)
+	}
+
+	// A prefix namespaces a registry's packages, including group members
+	if _, err := reg.LoadPackage(ctx, "lint"); err == nil {
+		t.Error("LoadPackage(lint) found a package of a prefixed registry by its plain name")
+	}
+	if m, err := reg.CachedPackage("team/kit"); err != nil || m.Name != "team/kit" || m.Members["team/lint"] != "1.0.0" {
+		t.Errorf("CachedPackage(team/kit) = %+v, %v, want qualified names", m, err)
+	}
+	if _, err := reg.LoadPackage(ctx, "other/lint"); err == nil {
+		t.Error("LoadPackage() accepted an unknown prefix")
+	}
+
+	// Manifests are traced back to the registry they come from
+	for name, want := range map[string]string{"node": "corp", "jq": DefaultName, "team/kit": "team"} {
+		m, err := reg.CachedPackage(name)
+		if err != nil {
+			t.Fatalf("CachedPackage(%s) failed: %v", name, err)
+		}
+		if source := reg.Source(m); source == nil || source.Label() != want {
+			t.Errorf("Source(%s) = %v, want %s", name, source, want)
+		}
+	}
+	if source := reg.Source(&manifest.Manifest{Name: "jq", Registry: "gone"}); source != nil {
+		t.Errorf("Source() of a removed registry = %s, want none", source.Label())
+	}
+
+	results, err := reg.Search(ctx, "")
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	var got []string
+	for _, pkg := range results {
+		got = append(got, pkg.Registry+":"+pkg.Name)
+	}
+	if want := "corp:node team:team/kit team:team/lint default:jq default:node"; strings.Join(got, " ") != want {
+		t.Errorf("Search() = %v, want %s", got, want)
+	}
+
+	// Clearing the default registry's cache leaves the configured registries' alone
+	if err := reg.ClearCache(); err != nil {
+		t.Fatalf("ClearCache() failed: %v", err)
+	}
+	if _, err := reg.cachedIndex(); err == nil {
+		t.Error("ClearCache() kept the default registry's index")
+	}
+	if _, err := reg.CachedPackage("team/kit"); err != nil {
+		t.Errorf("ClearCache() of the default registry removed team's cache: %v", err)
+	}
+	if err := reg.Registries()[1].ClearCache(); err != nil {
+		t.Fatalf("ClearCache() of team failed: %v", err)
+	}
+	if _, err := reg.CachedPackage("team/kit"); err == nil {
+		t.Error("ClearCache() of team kept its manifests")
+	}
+
+	// An unreachable registry is skipped as long as another answers
+	reg.AddRegistry("down", "http://127.0.0.1:1", "")
+	if _, err := reg.Update(ctx); err != nil {
+		t.Errorf("Update() with one registry down failed: %v", err)
+	}
+}
+
+func TestValidateSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []Source
+		wantErr string
+	}{
+		{"valid", []Source{{Name: "corp", URL: "https://registry.corp.example.com", Prefix: "corp"}, {Name: "team", URL: "http://10.0.0.5/registry"}}, ""},
+		{"reserved name", []Source{{Name: "default", URL: "https://example.com"}}, "already taken"},
+		{"duplicate name", []Source{{Name: "corp", URL: "https://a.example.com"}, {Name: "corp", URL: "https://b.example.com"}}, "already taken"},
+		{"duplicate prefix", []Source{{Name: "a", URL: "https://a.example.com", Prefix: "x"}, {Name: "b", URL: "https://b.example.com", Prefix: "x"}}, "two registries"},
+		{"path in name", []Source{{Name: "../corp", URL: "https://example.com"}}, "invalid registry name"},
+		{"slash in prefix", []Source{{Name: "corp", URL: "https://example.com", Prefix: "a/b"}}, "invalid prefix"},
+		{"missing URL", []Source{{Name: "corp"}}, "invalid URL"},
+		{"FTP URL", []Source{{Name: "corp", URL: "ftp://example.com"}}, "invalid URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSources(tt.sources)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateSources() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateSources() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
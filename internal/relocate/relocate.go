@@ -0,0 +1,234 @@
+// Package relocate updates the absolute paths a nori root holds to itself after the
+// root has moved, such as when a home directory is renamed or a backup is restored
+// somewhere else. Installs, receipts and the state index only hold relative paths; what
+// remains is shims, links inside installs, settings and caches.
+package relocate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+)
+
+// RecordName is the file in the config directory recording where the root was last used
+const RecordName = ".root"
+
+// Change is a file or link that Run rewrote, or would rewrite
+type Change struct {
+	Kind string // shim, link or settings
+	Path string
+}
+
+// Recorded returns where the root at paths was last used, if it has been recorded
+func Recorded(paths platform.Paths) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(paths.ConfigDir(), RecordName))
+	if err != nil {
+		return "", false
+	}
+	root := strings.TrimSpace(string(data))
+	return root, root != ""
+}
+
+// Record records paths.Root as where the root is used
+func Record(paths platform.Paths) error {
+	if err := os.MkdirAll(paths.ConfigDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(paths.ConfigDir(), RecordName), []byte(paths.Root+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record nori root: %w", err)
+	}
+	return nil
+}
+
+// Run rewrites the paths beneath old in the root at paths to point beneath paths.Root
+// instead, and records paths.Root as the root's location. With dryRun it only reports
+// what it would change.
+func Run(paths platform.Paths, old string, dryRun bool) ([]Change, error) {
+	r := &relocation{old: filepath.Clean(old), new: paths.Root, dryRun: dryRun}
+	if r.old == r.new {
+		return nil, fmt.Errorf("the root is already at %s", r.new)
+	}
+
+	if err := r.shims(paths.ShimsDir()); err != nil {
+		return nil, err
+	}
+	if err := r.installs(paths.InstallsDir()); err != nil {
+		return nil, err
+	}
+	if err := r.text(paths.SettingsPath(), "settings"); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return r.changes, nil
+	}
+
+	// Cached project resolutions are keyed by paths under the old root
+	if err := os.RemoveAll(filepath.Join(paths.CacheDir(), "resolve")); err != nil {
+		return nil, fmt.Errorf("failed to clear resolution cache: %w", err)
+	}
+	return r.changes, Record(paths)
+}
+
+// relocation is one pass of Run
+type relocation struct {
+	old, new string
+	dryRun   bool
+	changes  []Change
+}
+
+// shims rewrites symlinked shims and the paths in wrapper scripts
+func (r *relocation) shims(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read shims directory: %w", err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			if _, err := r.link(path, "shim"); err != nil {
+				return err
+			}
+		case entry.Type().IsRegular() && filepath.Ext(path) != ".exe":
+			if err := r.text(path, "shim"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// installs relinks absolute symlinks inside each install and updates its receipt to match
+func (r *relocation) installs(dir string) error {
+	installPaths, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+	for _, installPath := range installPaths {
+		rec, err := receipt.Load(installPath)
+		if err != nil {
+			rec = nil
+		}
+		err = filepath.WalkDir(installPath, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.Type()&os.ModeSymlink == 0 {
+				return err
+			}
+			target, err := r.link(path, "link")
+			if err != nil || target == "" || rec == nil {
+				return err
+			}
+			rel, err := filepath.Rel(installPath, path)
+			if err != nil {
+				return err
+			}
+			if file, ok := rec.Files[filepath.ToSlash(rel)]; ok {
+				file.Link = target
+				rec.Files[filepath.ToSlash(rel)] = file
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to relocate links in %s: %w", installPath, err)
+		}
+		if rec != nil && !r.dryRun {
+			if err := rec.Save(installPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// link points the symlink at path beneath the new root if it points beneath the old one,
+// returning its new target, or "" if it was left alone
+func (r *relocation) link(path, kind string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read link: %w", err)
+	}
+	moved, ok := r.movePath(target)
+	if !ok {
+		return "", nil
+	}
+	r.changes = append(r.changes, Change{Kind: kind, Path: path})
+	if r.dryRun {
+		return moved, nil
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("failed to replace link %s: %w", path, err)
+	}
+	if err := os.Symlink(moved, path); err != nil {
+		return "", fmt.Errorf("failed to replace link %s: %w", path, err)
+	}
+	return moved, nil
+}
+
+// text rewrites the paths beneath the old root in the file at path
+func (r *relocation) text(path, kind string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	moved, n := r.moveText(string(data))
+	if n == 0 {
+		return nil
+	}
+	r.changes = append(r.changes, Change{Kind: kind, Path: path})
+	if r.dryRun {
+		return nil
+	}
+	if err := fsutil.WriteFileAtomic(path, []byte(moved), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to rewrite %s: %w", path, err)
+	}
+	return nil
+}
+
+// movePath returns path beneath the new root if it is the old root or beneath it
+func (r *relocation) movePath(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, r.old)
+	if !ok || (rest != "" && !isSeparator(rest[0])) {
+		return path, false
+	}
+	return r.new + rest, true
+}
+
+// moveText replaces each path in text that is the old root or beneath it, returning the
+// new text and how many paths it replaced. A match must end the path or be followed by a
+// separator, so a root of /home/al leaves /home/alice alone.
+func (r *relocation) moveText(text string) (string, int) {
+	var b strings.Builder
+	n := 0
+	for {
+		i := strings.Index(text, r.old)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String(), n
+		}
+		end := i + len(r.old)
+		if end < len(text) && !isSeparator(text[end]) && !strings.ContainsRune("\"' \t\r\n", rune(text[end])) {
+			b.WriteString(text[:end])
+		} else {
+			b.WriteString(text[:i])
+			b.WriteString(r.new)
+			n++
+		}
+		text = text[end:]
+	}
+}
+
+// isSeparator reports whether c separates path elements on any platform nori writes for
+func isSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
@@ -0,0 +1,114 @@
+package relocate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+)
+
+// movedRoot creates a root at new whose shims, links and settings point beneath old,
+// as a root moved from old would
+func movedRoot(t *testing.T) (old string, paths platform.Paths) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on Windows")
+	}
+
+	dir := t.TempDir()
+	old = filepath.Join(dir, "old")
+	paths = platform.NewPaths(filepath.Join(dir, "new"))
+
+	installPath := paths.InstallPath("tool", "1.0.0", "linux-amd64")
+	os.MkdirAll(filepath.Join(installPath, "bin"), 0755)
+	os.WriteFile(filepath.Join(installPath, "bin", "tool"), []byte("#!/bin/sh\n"), 0755)
+	os.Symlink(filepath.Join(old, "installs", "tool", "1.0.0", "linux-amd64", "bin", "tool"), filepath.Join(installPath, "bin", "tool-link"))
+	rec, err := receipt.New("tool", "1.0.0", "linux-amd64", &manifest.Asset{Type: "tar"}, installPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Save(installPath)
+
+	os.MkdirAll(paths.ShimsDir(), 0755)
+	os.Symlink(filepath.Join(old, "installs", "tool", "1.0.0", "linux-amd64", "bin", "tool"), filepath.Join(paths.ShimsDir(), "tool"))
+	os.WriteFile(filepath.Join(paths.ShimsDir(), "wrapper"), []byte("#!/bin/sh\nexec \""+old+"/bin/nori\" exec-shim \"wrapper\" \"$@\"\n"), 0755)
+	os.WriteFile(filepath.Join(paths.ShimsDir(), "other"), []byte("#!/bin/sh\nexec \""+old+"er/bin/other\" \"$@\"\n"), 0755)
+
+	os.MkdirAll(paths.ConfigDir(), 0700)
+	os.WriteFile(paths.SettingsPath(), []byte("# staging\ntmp_dir: "+old+"/tmp\n"), 0644)
+	return old, paths
+}
+
+func TestRun(t *testing.T) {
+	old, paths := movedRoot(t)
+	os.MkdirAll(filepath.Join(paths.CacheDir(), "resolve"), 0755)
+
+	changes, err := Run(paths, old, false)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("Run() changed %v, want the shim link, the wrapper, the install's link and the settings", changes)
+	}
+
+	installPath := paths.InstallPath("tool", "1.0.0", "linux-amd64")
+	want := filepath.Join(installPath, "bin", "tool")
+	if target, _ := os.Readlink(filepath.Join(paths.ShimsDir(), "tool")); target != want {
+		t.Errorf("shim link = %q, want %q", target, want)
+	}
+	if target, _ := os.Readlink(filepath.Join(installPath, "bin", "tool-link")); target != want {
+		t.Errorf("install link = %q, want %q", target, want)
+	}
+	if rec, err := receipt.Load(installPath); err != nil || rec.Files["bin/tool-link"].Link != want {
+		t.Errorf("receipt = %v, %v, want the link's new target recorded", rec, err)
+	} else if problems, _ := rec.Verify(installPath); len(problems) != 0 {
+		t.Errorf("Verify() = %v, want the relocated install intact", problems)
+	}
+
+	wrapper, _ := os.ReadFile(filepath.Join(paths.ShimsDir(), "wrapper"))
+	if !strings.Contains(string(wrapper), `"`+paths.Root+`/bin/nori"`) {
+		t.Errorf("wrapper = %q, want the path under the new root", wrapper)
+	}
+	if info, _ := os.Stat(filepath.Join(paths.ShimsDir(), "wrapper")); info.Mode().Perm()&0100 == 0 {
+		t.Error("wrapper lost its executable bit")
+	}
+	other, _ := os.ReadFile(filepath.Join(paths.ShimsDir(), "other"))
+	if !strings.Contains(string(other), old+"er/bin/other") {
+		t.Errorf("other = %q, want paths that only share a prefix left alone", other)
+	}
+	settings, _ := os.ReadFile(paths.SettingsPath())
+	if string(settings) != "# staging\ntmp_dir: "+paths.Root+"/tmp\n" {
+		t.Errorf("settings = %q, want tmp_dir moved and the comment kept", settings)
+	}
+
+	if _, err := os.Stat(filepath.Join(paths.CacheDir(), "resolve")); !os.IsNotExist(err) {
+		t.Error("Run() should clear the resolution cache")
+	}
+	if root, ok := Recorded(paths); !ok || root != paths.Root {
+		t.Errorf("Recorded() = %q, %v, want the new root", root, ok)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	old, paths := movedRoot(t)
+
+	changes, err := Run(paths, old, true)
+	if err != nil || len(changes) != 4 {
+		t.Fatalf("Run() = %v, %v, want 4 changes reported", changes, err)
+	}
+	if target, _ := os.Readlink(filepath.Join(paths.ShimsDir(), "tool")); !strings.HasPrefix(target, old) {
+		t.Errorf("shim link = %q, want it left alone by a dry run", target)
+	}
+	if _, ok := Recorded(paths); ok {
+		t.Error("a dry run should not record the root")
+	}
+
+	if _, err := Run(paths, paths.Root, false); err == nil {
+		t.Error("Run() to the same root should fail")
+	}
+}
@@ -1,74 +1,99 @@
 package shims
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
 )
 
-// Shims manages shim creation and updates
+// Shims manages shim creation and updates. A shim runs `nori exec-shim <bin>`, which
+// picks the version to run each time the shim is invoked.
 type Shims struct {
 	shimsDir string
+	nori     string // the nori executable shims run
+	stub     []byte // the Windows shim executable built into nori
 }
 
-// New creates a new shims manager
+// New creates a new shims manager whose shims run the current nori executable
 func New(shimsDir string) *Shims {
+	nori, err := os.Executable()
+	if err != nil {
+		nori = "nori"
+	}
 	return &Shims{
 		shimsDir: shimsDir,
+		nori:     nori,
+		stub:     embeddedStub(),
 	}
 }
 
+// SetExecutable changes the nori executable that new shims run
+func (s *Shims) SetExecutable(path string) {
+	s.nori = path
+}
+
 // CreateShim creates a shim for a binary
-func (s *Shims) CreateShim(binName, targetPath string) error {
+func (s *Shims) CreateShim(binName string) error {
 	// Ensure shims directory exists
 	if err := os.MkdirAll(s.shimsDir, 0755); err != nil {
 		return fmt.Errorf("failed to create shims directory: %w", err)
 	}
 	
+	// Anyone who can write to the shims directory can replace the tools run through it
+	if fsutil.WritableByOthers(s.shimsDir) {
+		info, _ := os.Stat(s.shimsDir)
+		if err := os.Chmod(s.shimsDir, info.Mode().Perm()&^0022); err != nil {
+			return fmt.Errorf("shims directory %s is writable by other users: %w", s.shimsDir, err)
+		}
+	}
+	
 	if runtime.GOOS == "windows" {
-		return s.createWindowsShim(binName, targetPath)
+		return s.createExeShim(binName)
 	}
 	
-	return s.createUnixShim(binName, targetPath)
+	return s.createUnixShim(binName)
 }
 
-// createUnixShim creates a symlink or wrapper script on Unix
-func (s *Shims) createUnixShim(binName, targetPath string) error {
+// createUnixShim creates a wrapper script on Unix
+func (s *Shims) createUnixShim(binName string) error {
 	shimPath := filepath.Join(s.shimsDir, binName)
-	
-	// Try symlink first
-	if err := os.Symlink(targetPath, shimPath); err == nil {
-		return nil
-	}
-	
-	// Fallback to wrapper script if symlink fails
 	script := fmt.Sprintf(`#!/bin/sh
-exec "%s" "$@"
-`, targetPath)
+exec %s exec-shim %s "$@"
+`, shellQuote(s.nori), shellQuote(binName))
 	
-	return os.WriteFile(shimPath, []byte(script), 0755)
+	// Renaming over an existing shim replaces it, and never writes through an old symlink
+	return fsutil.WriteFileAtomic(shimPath, []byte(script), 0755)
 }
 
-// createWindowsShim creates .cmd and .ps1 wrappers on Windows
-func (s *Shims) createWindowsShim(binName, targetPath string) error {
-	// Create .cmd wrapper
-	cmdPath := filepath.Join(s.shimsDir, binName+".cmd")
-	cmdScript := fmt.Sprintf(`@echo off
-"%s" %%*
-`, targetPath)
-	if err := os.WriteFile(cmdPath, []byte(cmdScript), 0644); err != nil {
-		return fmt.Errorf("failed to create .cmd shim: %w", err)
-	}
+// createExeShim writes the shim executable as <bin>.exe and the command it runs to
+// <bin>.shim beside it
+func (s *Shims) createExeShim(binName string) error {
+	base := filepath.Join(s.shimsDir, binName)
 	
-	// Create .ps1 wrapper
-	ps1Path := filepath.Join(s.shimsDir, binName+".ps1")
-	ps1Script := fmt.Sprintf(`& "%s" $args
-`, targetPath)
-	if err := os.WriteFile(ps1Path, []byte(ps1Script), 0644); err != nil {
-		return fmt.Errorf("failed to create .ps1 shim: %w", err)
+	// A running executable can't be replaced on Windows, so leave an identical one alone
+	if data, err := os.ReadFile(base + ".exe"); err != nil || !bytes.Equal(data, s.stub) {
+		if err := fsutil.WriteFileAtomic(base+".exe", s.stub, 0755); err != nil {
+			return fmt.Errorf("failed to create .exe shim: %w", err)
+		}
+	}
+	// The shim executable splits the command on spaces outside double quotes, which
+	// Windows doesn't allow in file names anyway
+	if strings.ContainsAny(s.nori+binName, "\"\r\n") {
+		return fmt.Errorf("failed to create .shim file: %q or %q has a double quote or line break", s.nori, binName)
+	}
+	command := fmt.Sprintf("\"%s\" exec-shim \"%s\"\n", s.nori, binName)
+	if err := fsutil.WriteFileAtomic(base+".shim", []byte(command), 0644); err != nil {
+		return fmt.Errorf("failed to create .shim file: %w", err)
 	}
 	
+	// .cmd and .ps1 wrappers from older builds would otherwise still be found
+	os.Remove(base + ".cmd")
+	os.Remove(base + ".ps1")
 	return nil
 }
 
@@ -98,7 +123,7 @@ func (s *Shims) UpdateShims(pkg, version string, bins []string, installRoot stri
 		}
 		
 		// Create or update shim
-		if err := s.CreateShim(binName, targetPath); err != nil {
+		if err := s.CreateShim(binName); err != nil {
 			return fmt.Errorf("failed to create shim for %q: %w", binName, err)
 		}
 	}
@@ -114,13 +139,114 @@ func (s *Shims) RemoveShims(binNames []string) error {
 			return fmt.Errorf("failed to remove shim %q: %w", binName, err)
 		}
 		
-		// On Windows, also remove .cmd and .ps1
+		// On Windows, also remove the executable and wrappers
 		if runtime.GOOS == "windows" {
-			os.Remove(shimPath + ".cmd")
-			os.Remove(shimPath + ".ps1")
+			for _, ext := range []string{".exe", ".shim", ".cmd", ".ps1"} {
+				os.Remove(shimPath + ext)
+			}
 		}
 	}
 	
 	return nil
 }
 
+
+// Target returns the file a shim runs: the nori executable for shims that run
+// `nori exec-shim`, or the binary that a fixed shim written by an earlier release points at
+func (s *Shims) Target(binName string) (string, error) {
+	shimPath := s.shimPath(binName)
+	
+	if target, err := os.Readlink(shimPath); err == nil {
+		return target, nil
+	}
+	
+	// Wrapper scripts quote the target first: exec 'path' ..., or exec "path" ... and
+	// "path" ... as earlier releases wrote them
+	data, err := os.ReadFile(shimPath)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		start := strings.IndexAny(line, `'"`)
+		if start < 0 {
+			continue
+		}
+		if line[start] == '\'' {
+			if target, ok := shellUnquote(line[start:]); ok {
+				return target, nil
+			}
+			continue
+		}
+		end := strings.Index(line[start+1:], `"`)
+		if end < 0 {
+			continue
+		}
+		return line[start+1 : start+1+end], nil
+	}
+	
+	return "", fmt.Errorf("shim %q has no target", binName)
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellUnquote returns the sh word that shellQuote wrote at the start of s
+func shellUnquote(s string) (string, bool) {
+	var word strings.Builder
+	for {
+		if rest, ok := strings.CutPrefix(s, `\'`); ok {
+			word.WriteByte('\'')
+			s = rest
+			continue
+		}
+		rest, ok := strings.CutPrefix(s, "'")
+		if !ok {
+			return word.String(), word.Len() > 0
+		}
+		end := strings.IndexByte(rest, '\'')
+		if end < 0 {
+			return "", false
+		}
+		word.WriteString(rest[:end])
+		s = rest[end+1:]
+	}
+}
+
+// Dynamic reports whether the shim for binName runs `nori exec-shim`, rather than a
+// fixed binary like the symlinks and wrappers written by earlier releases
+func (s *Shims) Dynamic(binName string) bool {
+	shimPath := s.shimPath(binName)
+	if info, err := os.Lstat(shimPath); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return false
+	}
+	data, err := os.ReadFile(shimPath)
+	return err == nil && strings.Contains(string(data), " exec-shim ")
+}
+
+// shimPath returns the path of the shim for binName; on Windows, the .shim file of its
+// executable or else its .cmd wrapper
+func (s *Shims) shimPath(binName string) string {
+	shimPath := filepath.Join(s.shimsDir, binName)
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(shimPath + ".shim"); err == nil {
+			return shimPath + ".shim"
+		}
+		shimPath += ".cmd"
+	}
+	return shimPath
+}
+
+// BinName returns the name of the binary that fileName, an entry of the shims directory,
+// is the shim of. On Windows only one file of each shim counts, its .shim or .cmd file.
+func BinName(fileName string) (string, bool) {
+	if runtime.GOOS != "windows" {
+		return fileName, true
+	}
+	switch ext := filepath.Ext(fileName); ext {
+	case ".shim", ".cmd":
+		return strings.TrimSuffix(fileName, ext), true
+	}
+	return "", false
+}
@@ -2,11 +2,10 @@ package shims
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"testing"
-
-	"github.com/chirag-bruno/nori/internal/platform"
 )
 
 func TestCreateShimUnix(t *testing.T) {
@@ -18,25 +17,74 @@ func TestCreateShimUnix(t *testing.T) {
 	shimsDir := filepath.Join(tmpDir, "shims")
 	os.MkdirAll(shimsDir, 0755)
 	
-	// Override shims directory for test
-	originalShimsDir := platform.ShimsDir
-	defer func() {
-		_ = originalShimsDir
-	}()
-	
-	targetPath := filepath.Join(tmpDir, "bin", "test")
-	os.MkdirAll(filepath.Dir(targetPath), 0755)
-	os.WriteFile(targetPath, []byte("#!/bin/sh\necho test"), 0755)
-	
 	shim := New(shimsDir)
-	err := shim.CreateShim("test", targetPath)
+	shim.SetExecutable("/opt/nori/bin/nori")
+	err := shim.CreateShim("test")
 	if err != nil {
 		t.Fatalf("CreateShim() failed: %v", err)
 	}
 	
 	shimPath := filepath.Join(shimsDir, "test")
-	if _, err := os.Lstat(shimPath); os.IsNotExist(err) {
-		t.Errorf("Shim was not created at %q", shimPath)
+	data, err := os.ReadFile(shimPath)
+	if err != nil {
+		t.Fatalf("Shim was not created at %q", shimPath)
+	}
+	want := "#!/bin/sh\nexec '/opt/nori/bin/nori' exec-shim 'test' \"$@\"\n"
+	if string(data) != want {
+		t.Errorf("shim = %q, want %q", data, want)
+	}
+	if info, _ := os.Stat(shimPath); info.Mode().Perm()&0111 == 0 {
+		t.Errorf("shim mode = %v, want it executable", info.Mode())
+	}
+}
+
+func TestCreateShimQuotesPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix wrapper scripts only")
+	}
+	
+	// The shell must see the path as it is, not expand or split it
+	nori := filepath.Join(t.TempDir(), `it's "$(echo no)" $HOME`, "nori")
+	os.MkdirAll(filepath.Dir(nori), 0755)
+	os.WriteFile(nori, []byte("#!/bin/sh\necho \"$@\"\n"), 0755)
+	
+	shimsDir := t.TempDir()
+	shim := New(shimsDir)
+	shim.SetExecutable(nori)
+	if err := shim.CreateShim("tool"); err != nil {
+		t.Fatalf("CreateShim() failed: %v", err)
+	}
+	out, err := exec.Command(filepath.Join(shimsDir, "tool"), "a b").Output()
+	if err != nil || string(out) != "exec-shim tool a b\n" {
+		t.Errorf("shim output = %q, %v, want the arguments passed on", out, err)
+	}
+	if got, err := shim.Target("tool"); err != nil || got != nori {
+		t.Errorf("Target() = %q, %v, want %q", got, err, nori)
+	}
+	
+	shim.stub = []byte("stub executable")
+	if err := shim.createExeShim("tool"); err == nil {
+		t.Error("createExeShim() accepted a path with a double quote")
+	}
+}
+
+func TestCreateShimTightensShimsDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping Unix test on Windows")
+	}
+	
+	tmpDir := t.TempDir()
+	shimsDir := filepath.Join(tmpDir, "shims")
+	os.MkdirAll(shimsDir, 0755)
+	os.Chmod(shimsDir, 0777)
+	
+	if err := New(shimsDir).CreateShim("test"); err != nil {
+		t.Fatalf("CreateShim() failed: %v", err)
+	}
+	
+	info, _ := os.Stat(shimsDir)
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("shims directory mode = %v, want 0755", info.Mode().Perm())
 	}
 }
 
@@ -49,12 +97,8 @@ func TestCreateShimWindows(t *testing.T) {
 	shimsDir := filepath.Join(tmpDir, "shims")
 	os.MkdirAll(shimsDir, 0755)
 	
-	targetPath := filepath.Join(tmpDir, "bin", "test.exe")
-	os.MkdirAll(filepath.Dir(targetPath), 0755)
-	os.WriteFile(targetPath, []byte("test binary"), 0644)
-	
 	shim := New(shimsDir)
-	err := shim.CreateShim("test", targetPath)
+	err := shim.CreateShim("test")
 	if err != nil {
 		t.Fatalf("CreateShim() failed: %v", err)
 	}
@@ -103,3 +147,103 @@ func TestUpdateShims(t *testing.T) {
 	}
 }
 
+
+func TestCreateShimReplacesExisting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping Unix test on Windows")
+	}
+	
+	tmpDir := t.TempDir()
+	shimsDir := filepath.Join(tmpDir, "shims")
+	os.MkdirAll(shimsDir, 0755)
+	
+	// Earlier releases linked shims straight to a version's binary
+	oldTarget := filepath.Join(tmpDir, "1.0.0", "test")
+	os.MkdirAll(filepath.Dir(oldTarget), 0755)
+	os.WriteFile(oldTarget, []byte("#!/bin/sh\necho test"), 0755)
+	os.Symlink(oldTarget, filepath.Join(shimsDir, "test"))
+	
+	shim := New(shimsDir)
+	if shim.Dynamic("test") {
+		t.Error("Dynamic() = true for a symlinked shim")
+	}
+	if err := shim.CreateShim("test"); err != nil {
+		t.Fatalf("CreateShim() failed: %v", err)
+	}
+	if !shim.Dynamic("test") {
+		t.Error("Dynamic() = false after CreateShim()")
+	}
+	
+	// The previous target must not have been overwritten
+	data, _ := os.ReadFile(oldTarget)
+	if string(data) != "#!/bin/sh\necho test" {
+		t.Errorf("old target was modified: %q", string(data))
+	}
+}
+
+func TestShimTarget(t *testing.T) {
+	shimsDir := t.TempDir()
+	nori := filepath.Join(t.TempDir(), "bin", "nori")
+	
+	shim := New(shimsDir)
+	shim.SetExecutable(nori)
+	if err := shim.CreateShim("tool"); err != nil {
+		t.Fatalf("CreateShim() failed: %v", err)
+	}
+	
+	got, err := shim.Target("tool")
+	if err != nil {
+		t.Fatalf("Target() failed: %v", err)
+	}
+	if got != nori {
+		t.Errorf("Target() = %q, want %q", got, nori)
+	}
+	
+	if _, err := shim.Target("missing"); err == nil {
+		t.Error("Target() of a missing shim should fail")
+	}
+}
+
+func TestShimTargetWrapperScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix wrapper scripts only")
+	}
+	
+	shimsDir := t.TempDir()
+	os.WriteFile(filepath.Join(shimsDir, "tool"), []byte("#!/bin/sh\nexec \"/opt/tool/bin/tool\" \"$@\"\n"), 0755)
+	
+	got, err := New(shimsDir).Target("tool")
+	if err != nil {
+		t.Fatalf("Target() failed: %v", err)
+	}
+	if got != "/opt/tool/bin/tool" {
+		t.Errorf("Target() = %q, want %q", got, "/opt/tool/bin/tool")
+	}
+	if New(shimsDir).Dynamic("tool") {
+		t.Error("Dynamic() = true for a fixed wrapper script")
+	}
+}
+
+func TestCreateExeShim(t *testing.T) {
+	shimsDir := t.TempDir()
+	os.WriteFile(filepath.Join(shimsDir, "test.cmd"), []byte("@echo off\n"), 0644)
+	
+	shim := New(shimsDir)
+	shim.SetExecutable(`C:\Program Files\nori\nori.exe`)
+	shim.stub = []byte("stub executable")
+	if err := shim.createExeShim("test"); err != nil {
+		t.Fatalf("createExeShim() failed: %v", err)
+	}
+	
+	exe, _ := os.ReadFile(filepath.Join(shimsDir, "test.exe"))
+	if string(exe) != "stub executable" {
+		t.Errorf("test.exe = %q, want a copy of the stub", exe)
+	}
+	command, _ := os.ReadFile(filepath.Join(shimsDir, "test.shim"))
+	if want := "\"C:\\Program Files\\nori\\nori.exe\" exec-shim \"test\"\n"; string(command) != want {
+		t.Errorf("test.shim = %q, want %q", command, want)
+	}
+	if _, err := os.Stat(filepath.Join(shimsDir, "test.cmd")); !os.IsNotExist(err) {
+		t.Error("createExeShim() should remove the .cmd wrapper it replaces")
+	}
+}
@@ -0,0 +1,106 @@
+package shims
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chirag-bruno/nori/internal/fsutil"
+)
+
+// shimExtensions are the suffixes of the files that may make up a shim, on any platform
+var shimExtensions = []string{"", ".exe", ".shim", ".cmd", ".ps1"}
+
+// Snapshot is the state of some shims at one point, so a failed update can put them back
+type Snapshot struct {
+	files map[string]*savedFile // by path; nil where there was no file
+}
+
+// savedFile is the content of one shim file, or the target of a symlink shim
+type savedFile struct {
+	data []byte
+	mode os.FileMode
+	link string
+}
+
+// Snapshot records the shims of binNames as they are now
+func (s *Shims) Snapshot(binNames []string) (*Snapshot, error) {
+	snap := &Snapshot{files: make(map[string]*savedFile)}
+	for _, binName := range binNames {
+		for _, ext := range shimExtensions {
+			path := filepath.Join(s.shimsDir, binName+ext)
+			// A directory is no shim, and nothing writes over it
+			if info, err := os.Lstat(path); err == nil && info.IsDir() {
+				continue
+			}
+			saved, err := saveFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read shim %q: %w", binName, err)
+			}
+			snap.files[path] = saved
+		}
+	}
+	return snap, nil
+}
+
+// saveFile returns the content of the file at path, or nil if there is none
+func saveFile(path string) (*savedFile, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return &savedFile{link: link}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &savedFile{data: data, mode: info.Mode().Perm()}, nil
+}
+
+// Restore puts the shims back as they were when the snapshot was taken, removing shim
+// files written since
+func (snap *Snapshot) Restore() error {
+	for path, saved := range snap.files {
+		current, err := saveFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to restore shim %s: %w", path, err)
+		}
+		if sameFile(current, saved) {
+			continue
+		}
+
+		if saved == nil || saved.link != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to restore shim %s: %w", path, err)
+			}
+		}
+		switch {
+		case saved == nil:
+		case saved.link != "":
+			err = os.Symlink(saved.link, path)
+		default:
+			err = fsutil.WriteFileAtomic(path, saved.data, saved.mode)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore shim %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// sameFile reports whether two saved states of a file are the same
+func sameFile(a, b *savedFile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.link == b.link && a.mode == b.mode && string(a.data) == string(b.data)
+}
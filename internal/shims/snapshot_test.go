@@ -0,0 +1,51 @@
+package shims
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink shims need privileges on Windows")
+	}
+
+	shimsDir := t.TempDir()
+	shim := New(shimsDir)
+	shim.SetExecutable("/opt/nori/bin/nori")
+	if err := shim.CreateShim("kept"); err != nil {
+		t.Fatalf("CreateShim() failed: %v", err)
+	}
+	kept, _ := os.ReadFile(filepath.Join(shimsDir, "kept"))
+	// A fixed shim written by an earlier release
+	if err := os.Symlink("/opt/tool/bin/linked", filepath.Join(shimsDir, "linked")); err != nil {
+		t.Fatalf("failed to create symlink shim: %v", err)
+	}
+
+	snap, err := shim.Snapshot([]string{"kept", "linked", "added"})
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+
+	shim.SetExecutable("/usr/local/bin/nori")
+	for _, name := range []string{"kept", "linked", "added"} {
+		if err := shim.CreateShim(name); err != nil {
+			t.Fatalf("CreateShim(%s) failed: %v", name, err)
+		}
+	}
+
+	if err := snap.Restore(); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(shimsDir, "kept")); string(data) != string(kept) {
+		t.Errorf("kept shim = %q, want %q", data, kept)
+	}
+	if target, err := os.Readlink(filepath.Join(shimsDir, "linked")); err != nil || target != "/opt/tool/bin/linked" {
+		t.Errorf("linked shim = %q, %v, want the symlink back", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(shimsDir, "added")); !os.IsNotExist(err) {
+		t.Errorf("shim created after the snapshot should be removed, stat = %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package shims
+
+// embeddedStub returns nil: shims are shell scripts outside Windows
+func embeddedStub() []byte {
+	return nil
+}
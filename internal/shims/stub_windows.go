@@ -0,0 +1,14 @@
+package shims
+
+// Windows builds embed the shim executable, built for the same target by running
+//
+//	GOOS=windows GOARCH=<arch> go generate ./internal/shims
+//
+// before building nori. Without it the build fails, naming the missing
+// stub/nori-shim-windows-<arch>.exe.
+//go:generate go build -trimpath -ldflags=-s -o stub/nori-shim-$GOOS-$GOARCH.exe ../../cmd/nori-shim
+
+// embeddedStub returns the shim executable built into nori
+func embeddedStub() []byte {
+	return stub
+}
@@ -0,0 +1,8 @@
+package shims
+
+import _ "embed"
+
+// stub is the shim executable for windows/386, built by go generate
+//
+//go:embed stub/nori-shim-windows-386.exe
+var stub []byte
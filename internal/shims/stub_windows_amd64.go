@@ -0,0 +1,8 @@
+package shims
+
+import _ "embed"
+
+// stub is the shim executable for windows/amd64, built by go generate
+//
+//go:embed stub/nori-shim-windows-amd64.exe
+var stub []byte
@@ -0,0 +1,484 @@
+// Package state keeps an index of what nori has installed and activated, so that
+// queries like `nori list` read one small file instead of walking the installs tree.
+//
+// The installs tree and active.yaml remain the source of truth: the index is updated
+// alongside them on install, uninstall and use, and can always be rebuilt from disk.
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the version of the state file layout. A file in format 1, which predates
+// complete install reasons, is upgraded on read; a file in any other format is rebuilt.
+const Format = 2
+
+// Install is one installed version of a package for one platform
+type Install struct {
+	Version     string            `yaml:"version"`
+	Platform    string            `yaml:"platform"`
+	Checksum    string            `yaml:"checksum,omitempty"`
+	Bins        []string          `yaml:"bins,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"` // manifest env, with {install} placeholders
+	InstalledAt time.Time         `yaml:"installed_at,omitempty"`
+
+	// Why the version is installed: asked for by name, as a member of groups, or for
+	// projects that pin it. See Require; an install without any reason left is one
+	// nothing needs anymore.
+	Requested bool     `yaml:"requested,omitempty"`
+	Groups    []string `yaml:"groups,omitempty"`
+	Projects  []string `yaml:"projects,omitempty"` // version or lock files
+}
+
+// Reason is why a version was installed. The zero Reason is a request by name.
+type Reason struct {
+	Group   string // a group whose install included the version
+	Project string // the version file or lock file of a project that pins it
+}
+
+// HasReason reports whether anything is recorded about why inst was installed
+func (inst *Install) HasReason() bool {
+	return inst.Requested || len(inst.Groups) > 0 || len(inst.Projects) > 0
+}
+
+// Package is everything recorded about one package
+type Package struct {
+	Active   string    `yaml:"active,omitempty"`
+	Installs []Install `yaml:"installs,omitempty"`
+}
+
+// State is the contents of the state file
+type State struct {
+	Format   int                 `yaml:"format"`
+	Packages map[string]*Package `yaml:"packages"`
+}
+
+// Versions returns the versions of pkg installed for plat, in ascending semver order
+func (s *State) Versions(pkg, plat string) []string {
+	p := s.Packages[pkg]
+	if p == nil {
+		return nil
+	}
+
+	var versions []string
+	for _, inst := range p.Installs {
+		if inst.Platform == plat {
+			versions = append(versions, inst.Version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return manifest.CompareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// Find returns the record of version of pkg for plat, or nil if it isn't installed
+func (s *State) Find(pkg, version, plat string) *Install {
+	p := s.Packages[pkg]
+	if p == nil {
+		return nil
+	}
+	for i := range p.Installs {
+		if p.Installs[i].Version == version && p.Installs[i].Platform == plat {
+			return &p.Installs[i]
+		}
+	}
+	return nil
+}
+
+// Installed returns the names of packages with at least one version installed for plat, sorted
+func (s *State) Installed(plat string) []string {
+	var names []string
+	for name := range s.Packages {
+		if len(s.Versions(name, plat)) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Active returns the active version of pkg, or "" if none is active
+func (s *State) Active(pkg string) string {
+	if p := s.Packages[pkg]; p != nil {
+		return p.Active
+	}
+	return ""
+}
+
+// Provides returns the installed package whose active version for plat ships a binary
+// named bin, along with that binary's path relative to the install root
+func (s *State) Provides(bin, plat string) (string, string) {
+	names := make([]string, 0, len(s.Packages))
+	for name := range s.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := s.Packages[name]
+		for _, inst := range p.Installs {
+			if inst.Version != p.Active || inst.Platform != plat {
+				continue
+			}
+			for _, b := range inst.Bins {
+				if filepath.Base(b) == bin {
+					return name, b
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// AddInstall records inst for pkg, replacing any earlier record of the same version and
+// platform but keeping the reasons it was installed for, which a reinstall doesn't change
+func (s *State) AddInstall(pkg string, inst Install) {
+	if prev := s.Find(pkg, inst.Version, inst.Platform); prev != nil {
+		inst.Requested = inst.Requested || prev.Requested
+		inst.Groups = mergeReasons(prev.Groups, inst.Groups)
+		inst.Projects = mergeReasons(prev.Projects, inst.Projects)
+	}
+	s.RemoveInstall(pkg, inst.Version, inst.Platform)
+	p := s.pkg(pkg)
+	p.Installs = append(p.Installs, inst)
+	sort.Slice(p.Installs, func(i, j int) bool {
+		a, b := p.Installs[i], p.Installs[j]
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		return manifest.CompareVersions(a.Version, b.Version) < 0
+	})
+}
+
+// mergeReasons returns the groups or projects in either a or b, sorted and without duplicates
+func mergeReasons(a, b []string) []string {
+	merged := slices.Concat(a, b)
+	if len(merged) == 0 {
+		return nil
+	}
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
+
+// Require records reason as one of the reasons version of pkg for plat is installed. It
+// does nothing if that version isn't installed.
+func (s *State) Require(pkg, version, plat string, reason Reason) {
+	inst := s.Find(pkg, version, plat)
+	if inst == nil {
+		return
+	}
+	switch {
+	case reason.Group != "":
+		if !slices.Contains(inst.Groups, reason.Group) {
+			inst.Groups = append(inst.Groups, reason.Group)
+			sort.Strings(inst.Groups)
+		}
+	case reason.Project != "":
+		if !slices.Contains(inst.Projects, reason.Project) {
+			inst.Projects = append(inst.Projects, reason.Project)
+			sort.Strings(inst.Projects)
+		}
+	default:
+		inst.Requested = true
+	}
+}
+
+// Needed reports whether anything still holds inst: a request by name, a group, or a
+// project whose version or lock file is still there
+func (inst *Install) Needed() bool {
+	if inst.Requested || len(inst.Groups) > 0 {
+		return true
+	}
+	for _, path := range inst.Projects {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Release drops group from the reasons of every install for plat, returning the versions
+// of each package that were installed as its members
+func (s *State) Release(group, plat string) map[string][]string {
+	members := make(map[string][]string)
+	for name, p := range s.Packages {
+		for i := range p.Installs {
+			inst := &p.Installs[i]
+			if inst.Platform != plat || !slices.Contains(inst.Groups, group) {
+				continue
+			}
+			inst.Groups = slices.DeleteFunc(inst.Groups, func(g string) bool { return g == group })
+			members[name] = append(members[name], inst.Version)
+		}
+	}
+	return members
+}
+
+// Orphans returns the versions of each package installed for plat that nothing needs
+// anymore (see Needed), in ascending order
+func (s *State) Orphans(plat string) map[string][]string {
+	orphans := make(map[string][]string)
+	for name, p := range s.Packages {
+		for _, inst := range p.Installs {
+			if inst.Platform == plat && !inst.Needed() {
+				orphans[name] = append(orphans[name], inst.Version)
+			}
+		}
+	}
+	return orphans
+}
+
+// RemoveInstall forgets version of pkg for plat
+func (s *State) RemoveInstall(pkg, version, plat string) {
+	p := s.Packages[pkg]
+	if p == nil {
+		return
+	}
+
+	kept := p.Installs[:0]
+	for _, inst := range p.Installs {
+		if inst.Version != version || inst.Platform != plat {
+			kept = append(kept, inst)
+		}
+	}
+	p.Installs = kept
+	s.prune(pkg)
+}
+
+// SetActive records version as the active version of pkg
+func (s *State) SetActive(pkg, version string) {
+	s.pkg(pkg).Active = version
+	s.prune(pkg)
+}
+
+// pkg returns the record for name, creating it if needed
+func (s *State) pkg(name string) *Package {
+	if s.Packages == nil {
+		s.Packages = make(map[string]*Package)
+	}
+	p := s.Packages[name]
+	if p == nil {
+		p = &Package{}
+		s.Packages[name] = p
+	}
+	return p
+}
+
+// prune drops the record for name once nothing is left in it
+func (s *State) prune(name string) {
+	if p := s.Packages[name]; p != nil && p.Active == "" && len(p.Installs) == 0 {
+		delete(s.Packages, name)
+	}
+}
+
+// Store reads and writes the state file
+type Store struct {
+	paths platform.Paths
+}
+
+// New creates a state store for the given nori paths
+func New(paths platform.Paths) *Store {
+	return &Store{
+		paths: paths,
+	}
+}
+
+// Load returns the current state. A missing, unreadable or outdated state file is
+// rebuilt from disk first. Packages without an active version get the machine-wide
+// default, which is never saved, so a changed default applies at once.
+func (s *Store) Load() (*State, error) {
+	st, err := s.read()
+	if err != nil {
+		err = s.Update(func(current *State) error {
+			st = current
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	defaults, err := config.New(s.paths).SystemDefaults()
+	if err != nil {
+		return nil, err
+	}
+	for pkg, version := range defaults {
+		if p := st.Packages[pkg]; p != nil && p.Active == "" {
+			p.Active = version
+		}
+	}
+	return st, nil
+}
+
+// Update applies fn to the state and saves the result. Concurrent updates from other
+// nori processes are serialized, and readers only ever see complete files.
+func (s *Store) Update(fn func(*State) error) error {
+	statePath := s.paths.StatePath()
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	unlock, err := fsutil.Lock(statePath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := s.read()
+	if err != nil {
+		if st, err = s.Scan(); err != nil {
+			return err
+		}
+	}
+	if err := fn(st); err != nil {
+		return err
+	}
+	return s.save(st)
+}
+
+// Rebuild re-derives the state from the installs tree and active versions on disk
+// and saves it, discarding whatever was recorded before except why the installs still
+// on disk were installed, which only the state records
+func (s *Store) Rebuild() (*State, error) {
+	var st *State
+	err := s.Update(func(current *State) error {
+		scanned, err := s.Scan()
+		if err != nil {
+			return err
+		}
+		for name, p := range scanned.Packages {
+			for i := range p.Installs {
+				inst := &p.Installs[i]
+				if old := current.Find(name, inst.Version, inst.Platform); old != nil {
+					inst.Requested, inst.Groups, inst.Projects = old.Requested, old.Groups, old.Projects
+				}
+			}
+		}
+		*current = *scanned
+		st = current
+		return nil
+	})
+	return st, err
+}
+
+// Scan derives the state from disk without saving it
+func (s *Store) Scan() (*State, error) {
+	st := &State{Format: Format, Packages: make(map[string]*Package)}
+
+	pkgs, err := os.ReadDir(s.paths.InstallsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read installs: %w", err)
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		if !pkg.IsDir() {
+			continue
+		}
+		// Packages of a registry with a prefix are grouped under @<prefix>
+		prefix, ok := strings.CutPrefix(pkg.Name(), "@")
+		if !ok {
+			names = append(names, pkg.Name())
+			continue
+		}
+		namespaced, _ := os.ReadDir(filepath.Join(s.paths.InstallsDir(), pkg.Name()))
+		for _, entry := range namespaced {
+			if entry.IsDir() {
+				names = append(names, prefix+"/"+entry.Name())
+			}
+		}
+	}
+
+	for _, name := range names {
+		versions, _ := os.ReadDir(s.paths.PackageDir(name))
+		for _, version := range versions {
+			if !version.IsDir() {
+				continue
+			}
+			plats, _ := os.ReadDir(filepath.Join(s.paths.PackageDir(name), version.Name()))
+			for _, plat := range plats {
+				if !plat.IsDir() {
+					continue
+				}
+				// Nothing is known of why an install found on disk is there, so it
+				// counts as asked for
+				inst := Install{Version: version.Name(), Platform: plat.Name(), Requested: true}
+				if r, err := receipt.Load(s.paths.InstallPath(name, version.Name(), plat.Name())); err == nil {
+					inst.Checksum = r.Checksum
+					inst.Bins = r.Bins
+					inst.Env = r.Env
+					inst.InstalledAt = r.InstalledAt
+				}
+				st.AddInstall(name, inst)
+			}
+		}
+	}
+
+	// Only the user's choices are recorded; Load adds the machine-wide defaults
+	layers, err := config.New(s.paths).ActiveLayers()
+	if err != nil {
+		return nil, err
+	}
+	for pkg, version := range layers[0].Versions {
+		st.SetActive(pkg, version)
+	}
+
+	return st, nil
+}
+
+// read loads the state file, failing if it is missing, corrupt or in another format
+func (s *Store) read() (*State, error) {
+	data, err := os.ReadFile(s.paths.StatePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var st State
+	if err := yaml.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	if st.Format == 1 {
+		// Installs recorded before nori tracked why were asked for by name
+		for _, p := range st.Packages {
+			for i := range p.Installs {
+				if !p.Installs[i].HasReason() {
+					p.Installs[i].Requested = true
+				}
+			}
+		}
+		st.Format = Format
+	}
+	if st.Format != Format {
+		return nil, fmt.Errorf("state is in format %d, want %d", st.Format, Format)
+	}
+	if st.Packages == nil {
+		st.Packages = make(map[string]*Package)
+	}
+	return &st, nil
+}
+
+// save writes the state file
+func (s *Store) save(st *State) error {
+	st.Format = Format
+	data, err := yaml.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.paths.StatePath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/receipt"
+)
+
+func TestStateQueries(t *testing.T) {
+	var st State
+	st.AddInstall("node", Install{Version: "22.2.0", Platform: "linux-amd64", Bins: []string{"bin/node", "bin/npm"}})
+	st.AddInstall("node", Install{Version: "20.10.0", Platform: "linux-amd64", Bins: []string{"bin/node"}})
+	st.AddInstall("node", Install{Version: "22.2.0", Platform: "darwin-arm64"})
+	st.AddInstall("go", Install{Version: "1.22.0", Platform: "darwin-arm64"})
+	st.SetActive("node", "22.2.0")
+
+	if got := st.Versions("node", "linux-amd64"); !reflect.DeepEqual(got, []string{"20.10.0", "22.2.0"}) {
+		t.Errorf("Versions() = %v, want ascending versions for the platform", got)
+	}
+	if got := st.Installed("linux-amd64"); !reflect.DeepEqual(got, []string{"node"}) {
+		t.Errorf("Installed(linux-amd64) = %v, want [node]", got)
+	}
+	if got := st.Installed("darwin-arm64"); !reflect.DeepEqual(got, []string{"go", "node"}) {
+		t.Errorf("Installed(darwin-arm64) = %v, want [go node]", got)
+	}
+	if pkg, bin := st.Provides("npm", "linux-amd64"); pkg != "node" || bin != "bin/npm" {
+		t.Errorf("Provides(npm) = %q, %q, want node, bin/npm", pkg, bin)
+	}
+	if pkg, _ := st.Provides("npm", "darwin-arm64"); pkg != "" {
+		t.Errorf("Provides(npm) on darwin = %q, want none", pkg)
+	}
+
+	// Re-recording an install replaces it
+	st.AddInstall("node", Install{Version: "22.2.0", Platform: "linux-amd64", Checksum: "sha256:new"})
+	if inst := st.Find("node", "22.2.0", "linux-amd64"); inst == nil || inst.Checksum != "sha256:new" {
+		t.Errorf("Find() = %+v, want the replaced record", inst)
+	}
+	if got := st.Versions("node", "linux-amd64"); len(got) != 2 {
+		t.Errorf("Versions() = %v, want no duplicates", got)
+	}
+
+	// Reasons are recorded once each, and only for installs that exist
+	st.Require("node", "22.2.0", "linux-amd64", Reason{Project: "/work/app/.nori-version"})
+	st.Require("node", "22.2.0", "linux-amd64", Reason{Group: "web"})
+	st.Require("node", "22.2.0", "linux-amd64", Reason{Group: "web"})
+	st.Require("node", "18.0.0", "linux-amd64", Reason{})
+	inst := st.Find("node", "22.2.0", "linux-amd64")
+	if inst.Requested || !reflect.DeepEqual(inst.Groups, []string{"web"}) || !reflect.DeepEqual(inst.Projects, []string{"/work/app/.nori-version"}) {
+		t.Errorf("Require() = %+v, want one group and one project", inst)
+	}
+	if st.Find("node", "18.0.0", "linux-amd64") != nil {
+		t.Error("Require() recorded a version that is not installed")
+	}
+	if st.Find("node", "20.10.0", "linux-amd64").HasReason() {
+		t.Error("HasReason() = true for an install without reasons")
+	}
+
+	// Releasing a group leaves versions that nothing else needs as orphans
+	st.Require("node", "20.10.0", "linux-amd64", Reason{Group: "web"})
+	st.Require("node", "20.10.0", "linux-amd64", Reason{Group: "backend"})
+	if got := st.Release("web", "linux-amd64"); !reflect.DeepEqual(got, map[string][]string{"node": {"20.10.0", "22.2.0"}}) {
+		t.Errorf("Release(web) = %v, want both node versions", got)
+	}
+	if got := st.Orphans("linux-amd64"); !reflect.DeepEqual(got, map[string][]string{"node": {"22.2.0"}}) {
+		t.Errorf("Orphans() = %v, want 22.2.0, whose project file doesn't exist", got)
+	}
+	st.Release("backend", "linux-amd64")
+	if got := st.Orphans("linux-amd64")["node"]; len(got) != 2 {
+		t.Errorf("Orphans() after releasing backend = %v, want both versions", got)
+	}
+	st.Require("go", "1.22.0", "darwin-arm64", Reason{})
+	if got := st.Orphans("darwin-arm64"); !reflect.DeepEqual(got, map[string][]string{"node": {"22.2.0"}}) {
+		t.Errorf("Orphans(darwin-arm64) = %v, want only node, which has no reason", got)
+	}
+
+	// A package is forgotten once it has no installs and no active version
+	st.RemoveInstall("go", "1.22.0", "darwin-arm64")
+	if _, ok := st.Packages["go"]; ok {
+		t.Error("RemoveInstall() should drop an empty package")
+	}
+	st.RemoveInstall("node", "22.2.0", "linux-amd64")
+	st.RemoveInstall("node", "20.10.0", "linux-amd64")
+	st.RemoveInstall("node", "22.2.0", "darwin-arm64")
+	if st.Active("node") != "22.2.0" {
+		t.Error("RemoveInstall() should keep the active version")
+	}
+}
+
+func TestAddInstallKeepsReasons(t *testing.T) {
+	var st State
+	st.AddInstall("node", Install{Version: "22.2.0", Platform: "linux-amd64", Checksum: "sha256:old"})
+	st.Require("node", "22.2.0", "linux-amd64", Reason{})
+	st.Require("node", "22.2.0", "linux-amd64", Reason{Project: "/work/app/nori.lock"})
+
+	// A reinstall, as of a new channel build, is still needed for the same reasons
+	st.AddInstall("node", Install{Version: "22.2.0", Platform: "linux-amd64", Checksum: "sha256:new", Groups: []string{"web"}})
+	inst := st.Find("node", "22.2.0", "linux-amd64")
+	if inst.Checksum != "sha256:new" || !inst.Requested || !reflect.DeepEqual(inst.Groups, []string{"web"}) || !reflect.DeepEqual(inst.Projects, []string{"/work/app/nori.lock"}) {
+		t.Errorf("AddInstall() over an earlier record = %+v, want the new checksum and every reason", inst)
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	store := New(paths)
+
+	err := store.Update(func(st *State) error {
+		st.AddInstall("node", Install{Version: "22.2.0", Platform: "linux-amd64"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := st.Versions("node", "linux-amd64"); !reflect.DeepEqual(got, []string{"22.2.0"}) {
+		t.Errorf("Versions() after Update() = %v, want [22.2.0]", got)
+	}
+
+	// Concurrent updates are not lost
+	var wg sync.WaitGroup
+	for _, version := range []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"} {
+		wg.Add(1)
+		go func(version string) {
+			defer wg.Done()
+			err := store.Update(func(st *State) error {
+				st.AddInstall("go", Install{Version: version, Platform: "linux-amd64"})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update() failed: %v", err)
+			}
+		}(version)
+	}
+	wg.Wait()
+
+	st, _ = store.Load()
+	if got := st.Versions("go", "linux-amd64"); len(got) != 5 {
+		t.Errorf("Versions() after concurrent updates = %v, want 5 versions", got)
+	}
+}
+
+func TestStoreRebuild(t *testing.T) {
+	paths := platform.NewPaths(t.TempDir())
+	store := New(paths)
+
+	installPath := paths.InstallPath("node", "22.2.0", "linux-amd64")
+	os.MkdirAll(installPath, 0755)
+	r := &receipt.Receipt{Package: "node", Version: "22.2.0", Platform: "linux-amd64", Checksum: "sha256:abc", Bins: []string{"bin/node"}}
+	if err := r.Save(installPath); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	os.MkdirAll(paths.InstallPath("node", "20.10.0", "linux-amd64"), 0755)
+	os.MkdirAll(paths.InstallPath("corp/tooling", "1.0.0", "linux-amd64"), 0755)
+	if err := config.New(paths).SetActive("node", "22.2.0"); err != nil {
+		t.Fatalf("SetActive() failed: %v", err)
+	}
+
+	// A missing state file is rebuilt on first load
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := st.Versions("node", "linux-amd64"); !reflect.DeepEqual(got, []string{"20.10.0", "22.2.0"}) {
+		t.Errorf("Versions() = %v, want both installs on disk", got)
+	}
+	if got := st.Versions("corp/tooling", "linux-amd64"); !reflect.DeepEqual(got, []string{"1.0.0"}) {
+		t.Errorf("Versions() of a prefixed package = %v, want [1.0.0]", got)
+	}
+	if inst := st.Find("node", "22.2.0", "linux-amd64"); inst == nil || inst.Checksum != "sha256:abc" || len(inst.Bins) != 1 {
+		t.Errorf("Find() = %+v, want the receipt's checksum and bins", inst)
+	}
+	if st.Active("node") != "22.2.0" {
+		t.Errorf("Active() = %q, want 22.2.0", st.Active("node"))
+	}
+	if _, err := os.Stat(paths.StatePath()); err != nil {
+		t.Errorf("Load() should save the rebuilt state: %v", err)
+	}
+
+	// Changes made behind nori's back are picked up by Rebuild, keeping install reasons
+	store.Update(func(st *State) error {
+		st.Require("node", "22.2.0", "linux-amd64", Reason{Group: "web"})
+		return nil
+	})
+	os.RemoveAll(filepath.Join(paths.InstallsDir(), "node", "20.10.0"))
+	if st, _ := store.Load(); len(st.Versions("node", "linux-amd64")) != 2 {
+		t.Error("Load() should trust the saved state")
+	}
+	st, err = store.Rebuild()
+	if err != nil {
+		t.Fatalf("Rebuild() failed: %v", err)
+	}
+	if got := st.Versions("node", "linux-amd64"); !reflect.DeepEqual(got, []string{"22.2.0"}) {
+		t.Errorf("Versions() after Rebuild() = %v, want [22.2.0]", got)
+	}
+	if inst := st.Find("node", "22.2.0", "linux-amd64"); !reflect.DeepEqual(inst.Groups, []string{"web"}) {
+		t.Errorf("Rebuild() groups = %v, want [web] kept", inst.Groups)
+	}
+
+	// Installs found on disk count as asked for
+	if inst := st.Find("node", "22.2.0", "linux-amd64"); !inst.Requested {
+		t.Errorf("Rebuild() = %+v, want the install counted as requested", inst)
+	}
+
+	// A format 1 file is upgraded, counting installs without reasons as asked for
+	os.WriteFile(paths.StatePath(), []byte("format: 1\npackages:\n  node:\n    installs:\n      - version: 22.2.0\n        platform: linux-amd64\n      - version: 23.0.0\n        platform: linux-amd64\n        groups: [web]\n"), 0644)
+	st, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load() failed for format 1: %v", err)
+	}
+	if inst := st.Find("node", "22.2.0", "linux-amd64"); inst == nil || !inst.Requested {
+		t.Errorf("Load() of format 1 = %+v, want 22.2.0 requested", inst)
+	}
+	if inst := st.Find("node", "23.0.0", "linux-amd64"); inst == nil || inst.Requested {
+		t.Errorf("Load() of format 1 = %+v, want 23.0.0 only in its group", inst)
+	}
+
+	// A corrupt or foreign state file is rebuilt too
+	for _, data := range []string{"packages: [", "format: 99\npackages: {}\n"} {
+		os.WriteFile(paths.StatePath(), []byte(data), 0644)
+		st, err := store.Load()
+		if err != nil {
+			t.Fatalf("Load() failed for %q: %v", data, err)
+		}
+		if got := st.Versions("node", "linux-amd64"); !reflect.DeepEqual(got, []string{"22.2.0"}) {
+			t.Errorf("Versions() for %q = %v, want [22.2.0]", data, got)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package testsupport provides fixtures for tests that exercise nori end to end:
+// an isolated nori root, an in-process HTTPS registry and generated archives.
+//
+// It only depends on the standard library so that tests of any internal package can use it.
+package testsupport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// IsolateRoot points NORI_ROOT at a fresh temporary directory for the duration of the
+// test, the system configuration directory at its system subdirectory, and NETRC at its
+// netrc file, so the user's credentials stay out of tests
+func IsolateRoot(t testing.TB) string {
+	t.Helper()
+	// nori resolves symlinks in its root, as in macOS's /var/folders
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	t.Setenv("NORI_ROOT", root)
+	t.Setenv("NORI_SYSTEM_CONFIG_DIR", filepath.Join(root, "system"))
+	t.Setenv("NETRC", filepath.Join(root, "netrc"))
+	return root
+}
+
+// Package describes a fixture package published by a Registry
+type Package struct {
+	Name        string
+	Description string
+	Versions    []string
+	Bins        []string // relative bin paths, defaults to bin/<name>
+	Platforms   []string // platforms each version ships for, defaults to Platform()
+	Downloads   int64    // optional registry stats published in the index
+	Updated     string   // YYYY-MM-DD
+}
+
+// Registry is an in-process HTTPS registry serving fixture packages
+type Registry struct {
+	URL string
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	files    map[string][]byte
+	index    []Package
+	requests map[string]int
+	required http.Header // see RequireHeader
+}
+
+// NewRegistry starts a registry serving pkgs for the current platform. For the duration of the
+// test, NORI_REGISTRY_URL points at it and http.DefaultTransport trusts its certificate, as
+// well as those of registries started before it.
+func NewRegistry(t testing.TB, pkgs ...Package) *Registry {
+	t.Helper()
+
+	r := &Registry{
+		files:    make(map[string][]byte),
+		requests: make(map[string]int),
+	}
+	r.server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	r.URL = r.server.URL
+	t.Cleanup(r.server.Close)
+
+	trustServer(t, r.server)
+	t.Setenv("NORI_REGISTRY_URL", r.URL)
+
+	for _, pkg := range pkgs {
+		r.AddPackage(pkg)
+	}
+
+	return r
+}
+
+// AddPackage generates an archive per version of pkg and publishes its manifest and index entry
+func (r *Registry) AddPackage(pkg Package) {
+	if len(pkg.Bins) == 0 {
+		pkg.Bins = []string{"bin/" + pkg.Name}
+	}
+	if len(pkg.Platforms) == 0 {
+		pkg.Platforms = []string{Platform()}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var manifest strings.Builder
+	fmt.Fprintf(&manifest, "schema: 1\nname: %s\ndescription: %s\nbins:\n", pkg.Name, pkg.Description)
+	for _, bin := range pkg.Bins {
+		fmt.Fprintf(&manifest, "  - %s\n", bin)
+	}
+	manifest.WriteString("versions:\n")
+
+	for _, version := range pkg.Versions {
+		files := make(map[string]string)
+		for _, bin := range pkg.Bins {
+			files[pkg.Name+"-"+version+"/"+bin] = BinScript(pkg.Name, version)
+		}
+		archive := TarGz(files)
+
+		assetPath := "/assets/" + pkg.Name + "-" + version + ".tar.gz"
+		r.files[assetPath] = archive
+
+		fmt.Fprintf(&manifest, "  %q:\n    platforms:\n", version)
+		for _, plat := range pkg.Platforms {
+			fmt.Fprintf(&manifest, "      %s:\n        type: tar\n        url: %s%s\n        checksum: %s\n",
+				plat, r.URL, assetPath, Checksum(archive))
+		}
+	}
+
+	r.files["/packages/"+pkg.Name+".yaml"] = []byte(manifest.String())
+	r.index = append(r.index, pkg)
+	r.files["/index.yaml"] = r.renderIndex()
+}
+
+// SetFile publishes arbitrary content at path, replacing any generated file
+func (r *Registry) SetFile(path string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = data
+}
+
+// Requests returns how many times path was requested
+func (r *Registry) Requests(path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[path]
+}
+
+// RequireHeader makes the registry answer 401 Unauthorized to requests without the
+// header name set to value, as private registries do without credentials
+func (r *Registry) RequireHeader(name, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.required == nil {
+		r.required = make(http.Header)
+	}
+	r.required.Set(name, value)
+}
+
+// Client returns an HTTP client that trusts the registry's certificate
+func (r *Registry) Client() *http.Client {
+	return r.server.Client()
+}
+
+// serve handles registry HTTP requests
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests[req.URL.Path]++
+	data, ok := r.files[req.URL.Path]
+	required := r.required
+	r.mu.Unlock()
+
+	for name := range required {
+		if req.Header.Get(name) != required.Get(name) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if req.Method == http.MethodHead {
+		return
+	}
+	w.Write(data)
+}
+
+// renderIndex renders index.yaml for the published packages
+func (r *Registry) renderIndex() []byte {
+	pkgs := append([]Package(nil), r.index...)
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+
+	var index strings.Builder
+	index.WriteString("packages:\n")
+	for _, pkg := range pkgs {
+		fmt.Fprintf(&index, "  - name: %s\n    description: %s\n", pkg.Name, pkg.Description)
+		if pkg.Downloads > 0 {
+			fmt.Fprintf(&index, "    downloads: %d\n", pkg.Downloads)
+		}
+		if pkg.Updated != "" {
+			fmt.Fprintf(&index, "    updated: %s\n", pkg.Updated)
+		}
+	}
+	return []byte(index.String())
+}
+
+// trustServer makes http.DefaultTransport trust server's certificate, on top of those it
+// already trusts, until the test ends
+func trustServer(t testing.TB, server *httptest.Server) {
+	t.Helper()
+
+	original := http.DefaultTransport
+	transport := original.(*http.Transport).Clone()
+	pool := x509.NewCertPool()
+	if transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil {
+		pool = transport.TLSClientConfig.RootCAs.Clone()
+	}
+	pool.AddCert(server.Certificate())
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	http.DefaultTransport = transport
+
+	t.Cleanup(func() {
+		http.DefaultTransport = original
+	})
+}
+
+// Platform returns the current platform in nori's os-arch form
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// BinScript returns the content of a fixture binary that prints its package and version
+func BinScript(name, version string) string {
+	return fmt.Sprintf("#!/bin/sh\necho %s %s\n", name, version)
+}
+
+// TarGz builds a gzipped tarball from a map of paths to contents.
+// Files are created executable so they can stand in for binaries.
+func TarGz(files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		tw.WriteHeader(&tar.Header{
+			Name: name,
+			Size: int64(len(files[name])),
+			Mode: 0755,
+		})
+		io.WriteString(tw, files[name])
+	}
+	tw.Close()
+	gw.Close()
+
+	return buf.Bytes()
+}
+
+// Zip builds a zip archive from a map of paths to contents, with files executable like
+// TarGz's. Contents may themselves be archives, as for an asset that wraps a tarball.
+func Zip(files map[string]string) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(0755)
+		w, _ := zw.CreateHeader(hdr)
+		io.WriteString(w, files[name])
+	}
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+// Deb builds a Debian package whose payload holds files, a map of paths from the root
+// of the filesystem, such as usr/bin/tool, to contents
+func Deb(files map[string]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, member := range [][2]string{
+		{"debian-binary", "2.0\n"},
+		{"control.tar.gz", string(TarGz(map[string]string{"./control": "Package: fixture\n"}))},
+		{"data.tar.gz", string(TarGz(files))},
+	} {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", member[0], 0, 0, 0, "100644", len(member[1]))
+		buf.WriteString(member[1])
+		if len(member[1])%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// Checksum returns the nori checksum string (sha256:hex) for data
+func Checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// CaptureStdout runs fn and returns everything it wrote to os.Stdout
+func CaptureStdout(t testing.TB, fn func()) string {
+	t.Helper()
+	return capture(t, &os.Stdout, fn)
+}
+
+// CaptureStderr runs fn and returns everything it wrote to os.Stderr
+func CaptureStderr(t testing.TB, fn func()) string {
+	t.Helper()
+	return capture(t, &os.Stderr, fn)
+}
+
+// capture runs fn with *file redirected to a pipe and returns what was written to it
+func capture(t testing.TB, file **os.File, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+
+	original := *file
+	*file = w
+	defer func() {
+		*file = original
+	}()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	fn()
+
+	w.Close()
+	return string(<-done)
+}
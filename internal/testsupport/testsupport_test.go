@@ -0,0 +1,133 @@
+package testsupport
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsolateRoot(t *testing.T) {
+	root := IsolateRoot(t)
+
+	if got := os.Getenv("NORI_ROOT"); got != root {
+		t.Errorf("NORI_ROOT = %q, want %q", got, root)
+	}
+}
+
+func TestTarGz(t *testing.T) {
+	data := TarGz(map[string]string{"pkg/bin/tool": "hello"})
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() failed: %v", err)
+	}
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("tar.Next() failed: %v", err)
+	}
+	if hdr.Name != "pkg/bin/tool" {
+		t.Errorf("entry name = %q, want %q", hdr.Name, "pkg/bin/tool")
+	}
+	content, _ := io.ReadAll(tr)
+	if string(content) != "hello" {
+		t.Errorf("entry content = %q, want %q", string(content), "hello")
+	}
+}
+
+func TestZip(t *testing.T) {
+	data := Zip(map[string]string{"pkg/bin/tool": "hello"})
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() failed: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "pkg/bin/tool" || zr.File[0].Mode()&0100 == 0 {
+		t.Fatalf("entries = %v, want pkg/bin/tool executable", zr.File)
+	}
+	r, _ := zr.File[0].Open()
+	content, _ := io.ReadAll(r)
+	if string(content) != "hello" {
+		t.Errorf("entry content = %q, want %q", string(content), "hello")
+	}
+}
+
+func TestDeb(t *testing.T) {
+	data := Deb(map[string]string{"usr/bin/tool": "hello"})
+
+	if !strings.HasPrefix(string(data), "!<arch>\ndebian-binary") {
+		t.Errorf("Deb() = %q..., want an ar archive starting with debian-binary", data[:32])
+	}
+	if !bytes.Contains(data, TarGz(map[string]string{"usr/bin/tool": "hello"})) {
+		t.Error("Deb() doesn't hold the payload tarball")
+	}
+}
+
+func TestRegistryServesPackages(t *testing.T) {
+	reg := NewRegistry(t, Package{Name: "hello", Description: "greeter", Versions: []string{"1.0.0"}})
+
+	if got := os.Getenv("NORI_REGISTRY_URL"); got != reg.URL {
+		t.Errorf("NORI_REGISTRY_URL = %q, want %q", got, reg.URL)
+	}
+
+	// The default client must trust the registry
+	resp, err := http.Get(reg.URL + "/index.yaml")
+	if err != nil {
+		t.Fatalf("GET index.yaml failed: %v", err)
+	}
+	index, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(index), "name: hello") {
+		t.Errorf("index.yaml = %q, want hello entry", string(index))
+	}
+
+	resp, err = reg.Client().Get(reg.URL + "/packages/hello.yaml")
+	if err != nil {
+		t.Fatalf("GET manifest failed: %v", err)
+	}
+	manifest, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(manifest), Platform()+":") {
+		t.Errorf("manifest = %q, want current platform asset", string(manifest))
+	}
+
+	resp, err = http.Get(reg.URL + "/assets/hello-1.0.0.tar.gz")
+	if err != nil {
+		t.Fatalf("GET asset failed: %v", err)
+	}
+	archive, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(manifest), Checksum(archive)) {
+		t.Error("manifest checksum does not match served archive")
+	}
+
+	if got := reg.Requests("/index.yaml"); got != 1 {
+		t.Errorf("Requests(index.yaml) = %d, want 1", got)
+	}
+}
+
+func TestCaptureStdout(t *testing.T) {
+	out := CaptureStdout(t, func() {
+		fmt.Println("captured")
+	})
+	if out != "captured\n" {
+		t.Errorf("CaptureStdout() = %q, want %q", out, "captured\n")
+	}
+}
+
+func TestCaptureStderr(t *testing.T) {
+	out := CaptureStderr(t, func() {
+		fmt.Fprintln(os.Stderr, "captured")
+	})
+	if out != "captured\n" {
+		t.Errorf("CaptureStderr() = %q, want %q", out, "captured\n")
+	}
+}
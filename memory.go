@@ -0,0 +1,242 @@
+package nori
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryRegistryURL is the base URL a MemoryRegistry answers for. The .invalid domain
+// can't resolve, so a request that escapes the registry's client fails.
+const MemoryRegistryURL = "https://registry.nori.invalid"
+
+// MemoryPackage is one version of a package published by a MemoryRegistry
+type MemoryPackage struct {
+	Name        string
+	Description string
+	Version     string
+
+	// Bins are the paths of the package's executables in Files, such as bin/hello
+	Bins []string
+
+	// Files are the contents of the version's install directory by path. Every file
+	// is executable, so a shell script can stand in for a binary.
+	Files map[string]string
+
+	// Platforms are those the version ships for, such as linux-amd64; none means the
+	// platform nori runs on
+	Platforms []string
+
+	// SmokeTest is the command Install runs to check an installed version, a bin's name
+	// and its arguments such as hello --version; none runs nothing
+	SmokeTest []string
+}
+
+// MemoryRegistry is a registry held in memory, for tests of code built on nori. It
+// publishes an index, manifests and archives like a registry served over HTTPS, but
+// its Client answers requests itself and refuses every other host.
+type MemoryRegistry struct {
+	mu        sync.Mutex
+	manifests map[string]*manifest.Manifest
+	index     map[string]string // descriptions by package name
+	files     map[string][]byte // set with SetFile, or archives, by path
+	requests  map[string]int
+}
+
+// NewMemoryRegistry creates an empty registry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		manifests: make(map[string]*manifest.Manifest),
+		index:     make(map[string]string),
+		files:     make(map[string][]byte),
+		requests:  make(map[string]int),
+	}
+}
+
+// URL returns the registry's base URL, to pass as Options.RegistryURL
+func (r *MemoryRegistry) URL() string {
+	return MemoryRegistryURL
+}
+
+// AddPackage publishes a version of a package, adding it to the index and building its
+// archive. Versions of the same package may be added one at a time.
+func (r *MemoryRegistry) AddPackage(pkg MemoryPackage) error {
+	if pkg.Name == "" || pkg.Version == "" {
+		return fmt.Errorf("a package needs a name and a version")
+	}
+	for _, bin := range pkg.Bins {
+		if _, ok := pkg.Files[bin]; !ok {
+			return fmt.Errorf("bin %s of %s@%s is not one of its files", bin, pkg.Name, pkg.Version)
+		}
+	}
+	plats := pkg.Platforms
+	if len(plats) == 0 {
+		plats = []string{platform.Detect().String()}
+	}
+
+	// Like most release archives, the files sit in one top-level directory
+	files := make(map[string]string, len(pkg.Files))
+	for name, content := range pkg.Files {
+		files[pkg.Name+"-"+pkg.Version+"/"+name] = content
+	}
+	archive, err := tarGz(files)
+	if err != nil {
+		return fmt.Errorf("failed to build the archive of %s@%s: %w", pkg.Name, pkg.Version, err)
+	}
+	sum := sha256.Sum256(archive)
+	assetPath := "/assets/" + pkg.Name + "-" + pkg.Version + ".tar.gz"
+	version := manifest.Version{Platforms: make(map[string]manifest.Asset)}
+	for _, plat := range plats {
+		version.Platforms[plat] = manifest.Asset{
+			Type:     "tar",
+			URL:      MemoryRegistryURL + assetPath,
+			Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.manifests[pkg.Name]
+	if m == nil {
+		m = &manifest.Manifest{Schema: 1, Name: pkg.Name, Versions: make(map[string]manifest.Version)}
+	}
+	m.Description = pkg.Description
+	m.Bins = pkg.Bins
+	m.SmokeTest = nil
+	if len(pkg.SmokeTest) > 0 {
+		m.SmokeTest = &manifest.SmokeTest{Command: pkg.SmokeTest}
+	}
+	m.Versions[pkg.Version] = version
+	if err := manifest.Validate(m); err != nil {
+		return fmt.Errorf("invalid package %s: %w", pkg.Name, err)
+	}
+	r.manifests[pkg.Name] = m
+	r.index[pkg.Name] = pkg.Description
+	r.files[assetPath] = archive
+	return nil
+}
+
+// SetFile publishes data at path, such as /packages/hello.yaml, replacing what the
+// registry would generate there
+func (r *MemoryRegistry) SetFile(path string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = data
+}
+
+// Requests returns how many times path was requested
+func (r *MemoryRegistry) Requests(path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[path]
+}
+
+// Client returns an HTTP client that is served by the registry, to pass as
+// Options.HTTPClient. Requests for any other host fail without touching the network.
+func (r *MemoryRegistry) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip answers a request for the registry's host from memory
+func (r *MemoryRegistry) RoundTrip(req *http.Request) (*http.Response, error) {
+	if "https://"+req.URL.Host != MemoryRegistryURL {
+		return nil, fmt.Errorf("hermetic client refuses a request to %s", req.URL.Host)
+	}
+	data, ok := r.file(req.URL.Path)
+
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	if !ok {
+		resp.Status, resp.StatusCode = "404 Not Found", http.StatusNotFound
+		data = []byte("not found\n")
+	}
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Length", fmt.Sprint(len(data)))
+	if req.Method == http.MethodHead {
+		data = nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// file returns the content published at urlPath, counting the request
+func (r *MemoryRegistry) file(urlPath string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	urlPath = path.Clean(urlPath)
+	r.requests[urlPath]++
+
+	if data, ok := r.files[urlPath]; ok {
+		return data, true
+	}
+	if urlPath == "/index.yaml" {
+		return r.renderIndex(), true
+	}
+	if name, ok := strings.CutPrefix(urlPath, "/packages/"); ok {
+		if m := r.manifests[strings.TrimSuffix(name, ".yaml")]; m != nil {
+			data, err := yaml.Marshal(m)
+			return data, err == nil
+		}
+	}
+	return nil, false
+}
+
+// renderIndex renders index.yaml for the published packages
+func (r *MemoryRegistry) renderIndex() []byte {
+	index := &registry.Index{Packages: []registry.PackageMeta{}}
+	for name, description := range r.index {
+		index.Packages = append(index.Packages, registry.PackageMeta{Name: name, Description: description})
+	}
+	sort.Slice(index.Packages, func(i, j int) bool { return index.Packages[i].Name < index.Packages[j].Name })
+	data, _ := yaml.Marshal(index)
+	return data
+}
+
+// tarGz builds a gzipped tarball of files, with every file executable
+func tarGz(files map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(files[name])), Mode: 0755}); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,382 @@
+// Package nori runs nori in-process, for programs and tests that bootstrap tools with
+// it. A Client made by NewHermetic reads nothing from the environment, the user's home
+// directory or the machine-wide configuration: everything it writes stays beneath the
+// root it is given, and every request goes through the HTTP client it is given. Paired
+// with a MemoryRegistry, installs need neither the network nor a registry server.
+//
+//	reg := nori.NewMemoryRegistry()
+//	reg.AddPackage(nori.MemoryPackage{Name: "hello", Version: "1.0.0", Bins: []string{"bin/hello"},
+//		Files: map[string]string{"bin/hello": "#!/bin/sh\necho hello\n"}})
+//	client, err := nori.NewHermetic(nori.Options{Root: t.TempDir(), RegistryURL: reg.URL(), HTTPClient: reg.Client()})
+//	...
+//	path, err := client.Install(ctx, "hello", "")
+package nori
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chirag-bruno/nori/internal/config"
+	"github.com/chirag-bruno/nori/internal/extract"
+	"github.com/chirag-bruno/nori/internal/fetch"
+	"github.com/chirag-bruno/nori/internal/fsutil"
+	"github.com/chirag-bruno/nori/internal/install"
+	"github.com/chirag-bruno/nori/internal/manifest"
+	"github.com/chirag-bruno/nori/internal/platform"
+	"github.com/chirag-bruno/nori/internal/provenance"
+	"github.com/chirag-bruno/nori/internal/receipt"
+	"github.com/chirag-bruno/nori/internal/registry"
+	"github.com/chirag-bruno/nori/internal/state"
+)
+
+// Options configure a hermetic Client
+type Options struct {
+	// Root is the nori root that installs, the registry cache, active versions and
+	// settings are kept under, such as a test's temporary directory. It is required.
+	Root string
+
+	// RegistryURL is the base URL of the registry packages are looked up in, such as a
+	// MemoryRegistry's URL. It is required.
+	RegistryURL string
+
+	// HTTPClient sends registry requests and downloads. Nil refuses every request, so
+	// only what is already cached under Root can be installed.
+	HTTPClient *http.Client
+
+	// Platform is the platform packages are installed for, such as linux-amd64; "" is
+	// the platform nori runs on
+	Platform string
+
+	// Verifiers check each archive, after its checksum and before it is extracted, in
+	// order. An archive any of them rejects is not installed.
+	Verifiers []Verifier
+
+	// SkipSmokeTest installs versions without running the smoke tests of their
+	// manifests, which otherwise run when installing for the platform nori runs on
+	SkipSmokeTest bool
+
+	// Log receives warnings, such as an attestation that doesn't verify under a
+	// provenance setting in warn mode. Nil discards them.
+	Log io.Writer
+}
+
+// Client installs and activates packages beneath one nori root
+type Client struct {
+	paths     platform.Paths
+	platform  platform.Platform
+	registry  *registry.Registry
+	fetcher   *fetch.Fetcher
+	verifiers []Verifier
+	skipSmoke bool
+	log       io.Writer
+}
+
+// NewHermetic creates a Client that only touches what opts give it; see the package
+// documentation
+func NewHermetic(opts Options) (*Client, error) {
+	if opts.Root == "" {
+		return nil, fmt.Errorf("a hermetic client needs a root")
+	}
+	root, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root %s: %w", opts.Root, err)
+	}
+	if err := registry.ValidateURL(opts.RegistryURL); err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+
+	p := platform.Detect()
+	if opts.Platform != "" {
+		osName, arch, ok := strings.Cut(opts.Platform, "-")
+		if !ok || osName == "" || arch == "" {
+			return nil, fmt.Errorf("invalid platform %q: expected one such as linux-amd64", opts.Platform)
+		}
+		p = platform.Platform{OS: osName, Arch: arch}
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Transport: refuseTransport{}}
+	}
+	log := opts.Log
+	if log == nil {
+		log = io.Discard
+	}
+
+	// Paths without a system directory leave the machine-wide defaults out
+	paths := platform.NewPaths(root)
+	return &Client{
+		paths:     paths,
+		platform:  p,
+		registry:  registry.NewWithClient(opts.RegistryURL, paths, client),
+		fetcher:   fetch.NewWithClient(client),
+		verifiers: opts.Verifiers,
+		skipSmoke: opts.SkipSmokeTest,
+		log:       log,
+	}, nil
+}
+
+// refuseTransport fails every request, for a Client without an HTTP client
+type refuseTransport struct{}
+
+// RoundTrip refuses req
+func (refuseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("hermetic client has no HTTP client for %s", req.URL.Host)
+}
+
+// Root returns the nori root the client works in
+func (c *Client) Root() string {
+	return c.paths.Root
+}
+
+// Update fetches the registry's index and caches its package manifests
+func (c *Client) Update(ctx context.Context) error {
+	_, err := c.registry.Update(ctx)
+	return err
+}
+
+// Install installs version of pkg, or its latest version for the client's platform if
+// version is "", and returns where it is installed. A version that is already installed
+// is left as it is. The first version installed of a package becomes its active one.
+//
+// Installs are checked as the nori command checks them: for the platform nori runs on,
+// the build this machine meets the requirements of is installed and its smoke test run,
+// and the provenance setting in the root's config.yaml applies.
+func (c *Client) Install(ctx context.Context, pkg, version string) (string, error) {
+	m, err := c.registry.LoadPackage(ctx, pkg)
+	if err != nil {
+		return "", err
+	}
+	if m.IsGroup() {
+		return "", fmt.Errorf("%s is a group; install its members one by one", pkg)
+	}
+	plat := c.platform.String()
+	if version == "" {
+		if version = m.LatestVersionFor(plat); version == "" {
+			return "", fmt.Errorf("package %q has no versions for %s", pkg, plat)
+		}
+	}
+	if err := manifest.ValidateVersion(m, version, plat); err != nil {
+		return "", err
+	}
+	asset, err := m.GetAsset(version, plat)
+	if err != nil {
+		return "", err
+	}
+	if c.native() {
+		host := manifest.Host{OSVersion: platform.OSVersion(), CPUFeatures: platform.CPUFeatures()}
+		if asset, err = asset.ForHost(host); err != nil {
+			return "", fmt.Errorf("cannot install %s@%s: %w", m.Name, version, err)
+		}
+	}
+
+	installPath := c.paths.InstallPath(m.Name, version, plat)
+	if _, err := os.Stat(installPath); err != nil {
+		if installPath, err = c.fetchAndInstall(ctx, m, version, asset); err != nil {
+			return "", err
+		}
+	}
+	if err := c.require(m.Name, version); err != nil {
+		return "", err
+	}
+
+	if active, err := c.Active(m.Name); err == nil && active == "" {
+		if err := c.Use(m.Name, version); err != nil {
+			return "", err
+		}
+	}
+	return installPath, nil
+}
+
+// fetchAndInstall downloads, verifies, extracts and installs version of m. Archives from
+// the cache go through the verifiers too.
+func (c *Client) fetchAndInstall(ctx context.Context, m *manifest.Manifest, version string, asset *manifest.Asset) (string, error) {
+	if asset.Checksum == "" {
+		return "", fmt.Errorf("%s@%s has no checksum to verify its download against", m.Name, version)
+	}
+	if err := c.checkProvenance(ctx, m, version, asset); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(c.paths.ArchivePath(asset.Checksum))
+	if err != nil || fetch.VerifyChecksum(data, asset.Checksum) != nil {
+		if asset.URL == "" && asset.GitHub != "" {
+			if asset.URL, err = c.fetcher.ResolveReleaseAsset(ctx, asset.GitHub, asset.ReleaseTag(version), asset.ReleaseAssetPattern(version)); err != nil {
+				return "", fmt.Errorf("failed to find the %s@%s download: %w", m.Name, version, err)
+			}
+		}
+		c.fetcher.SetPayloadChecksum(asset.PayloadChecksum)
+		if data, err = c.fetcher.FetchFromMirrors(ctx, asset.URLs(), asset.Checksum, nil); err != nil {
+			return "", fmt.Errorf("download of %s@%s failed: %w", m.Name, version, err)
+		}
+	}
+	err = c.verify(ctx, &Artifact{
+		Package:         m.Name,
+		Version:         version,
+		Platform:        c.platform.String(),
+		URL:             asset.URL,
+		Type:            asset.Type,
+		Checksum:        asset.Checksum,
+		PayloadChecksum: asset.PayloadChecksum,
+		Data:            data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	extractor := extract.New()
+	extractor.SetTempDir(c.paths.TmpDir())
+	extractor.SetPayloadChecksum(asset.PayloadChecksum)
+	extractDir, err := extractor.Extract(data, asset.Type, asset.Checksum)
+	if err != nil {
+		return "", fmt.Errorf("extraction of %s@%s failed: %w", m.Name, version, err)
+	}
+	defer os.RemoveAll(extractDir)
+	if _, err := extractor.ExtractInner(extractDir, asset.InnerPattern(version), nil); err != nil {
+		return "", fmt.Errorf("extraction of %s@%s failed: %w", m.Name, version, err)
+	}
+
+	installer := install.New(c.paths)
+	installPath, err := installer.Install(ctx, m, version, c.platform, extractDir)
+	if err != nil {
+		return "", fmt.Errorf("installation of %s@%s failed: %w", m.Name, version, err)
+	}
+	if m.SmokeTest != nil && !c.skipSmoke && c.native() {
+		if err := install.SmokeTest(ctx, m, version, installPath); err != nil {
+			if rollbackErr := installer.Uninstall(m.Name, version, c.platform, true); rollbackErr != nil {
+				return "", fmt.Errorf("%s@%s failed its smoke test, and undoing the install failed (%v): %w", m.Name, version, rollbackErr, err)
+			}
+			return "", fmt.Errorf("%s@%s failed its smoke test, so it was removed: %w", m.Name, version, err)
+		}
+	}
+
+	// The receipt lets the nori command verify and repair the install later
+	r, err := receipt.New(m.Name, version, c.platform.String(), asset, installPath)
+	if err == nil {
+		r.Bins, r.Env = m.Bins, m.Env
+		err = r.Save(installPath)
+	}
+	if err != nil {
+		return "", err
+	}
+	archivePath := c.paths.ArchivePath(asset.Checksum)
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err == nil {
+		_ = fsutil.WriteFileAtomic(archivePath, data, 0644)
+	}
+	return installPath, nil
+}
+
+// native reports whether the client installs for the platform nori runs on, whose
+// binaries can be run
+func (c *Client) native() bool {
+	return c.platform == platform.Detect()
+}
+
+// checkProvenance verifies the attestation of asset under the provenance setting of the
+// root's config.yaml. In warn mode a failure is logged and the install goes on; in
+// enforce mode it stops the install.
+func (c *Client) checkProvenance(ctx context.Context, m *manifest.Manifest, version string, asset *manifest.Asset) error {
+	settings, err := config.New(c.paths).LoadSettings()
+	if err != nil {
+		return err
+	}
+	policy := settings.ProvenanceFor("")
+	if policy.Mode == "" {
+		return nil
+	}
+	if _, err := provenance.VerifyAsset(ctx, c.fetcher, policy, asset); err != nil {
+		err = fmt.Errorf("provenance of %s@%s: %w", m.Name, version, err)
+		if policy.Mode == provenance.ModeEnforce {
+			return err
+		}
+		fmt.Fprintf(c.log, "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// require records that pkg@version was asked for by name
+func (c *Client) require(pkg, version string) error {
+	return state.New(c.paths).Update(func(st *state.State) error {
+		st.Require(pkg, version, c.platform.String(), state.Reason{})
+		return nil
+	})
+}
+
+// Installed returns the installed versions of pkg for the client's platform, oldest first
+func (c *Client) Installed(pkg string) ([]string, error) {
+	st, err := state.New(c.paths).Load()
+	if err != nil {
+		return nil, err
+	}
+	return st.Versions(pkg, c.platform.String()), nil
+}
+
+// Use makes version of pkg, which must be installed, its active version. Shims are left
+// to the nori command; run binaries by the path Which returns instead.
+func (c *Client) Use(pkg, version string) error {
+	if _, err := os.Stat(c.paths.InstallPath(pkg, version, c.platform.String())); err != nil {
+		return fmt.Errorf("%s@%s is not installed", pkg, version)
+	}
+	if err := config.New(c.paths).SetActive(pkg, version); err != nil {
+		return err
+	}
+	return state.New(c.paths).Update(func(st *state.State) error {
+		st.SetActive(pkg, version)
+		return nil
+	})
+}
+
+// Active returns the active version of pkg, or "" if none is active
+func (c *Client) Active(pkg string) (string, error) {
+	return config.New(c.paths).GetActive(pkg)
+}
+
+// Which returns the path of the binary bin, such as node, in the active version of pkg
+func (c *Client) Which(pkg, bin string) (string, error) {
+	version, err := c.Active(pkg)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		return "", fmt.Errorf("%s has no active version", pkg)
+	}
+	st, err := state.New(c.paths).Load()
+	if err != nil {
+		return "", err
+	}
+	inst := st.Find(pkg, version, c.platform.String())
+	if inst == nil {
+		return "", fmt.Errorf("%s@%s is not installed", pkg, version)
+	}
+	for _, b := range inst.Bins {
+		if name := filepath.Base(b); name == bin || strings.TrimSuffix(name, filepath.Ext(name)) == bin {
+			return filepath.Join(c.paths.InstallPath(pkg, version, c.platform.String()), b), nil
+		}
+	}
+	return "", fmt.Errorf("%s@%s has no binary named %s", pkg, version, bin)
+}
+
+// Uninstall removes version of pkg, clearing it as the active version if it was
+func (c *Client) Uninstall(pkg, version string) error {
+	active, err := c.Active(pkg)
+	if err != nil {
+		return err
+	}
+	if err := install.New(c.paths).Uninstall(pkg, version, c.platform, false); err != nil {
+		return err
+	}
+	if active != version {
+		return nil
+	}
+	if err := config.New(c.paths).ClearActive(pkg); err != nil {
+		return err
+	}
+	return state.New(c.paths).Update(func(st *state.State) error {
+		st.SetActive(pkg, "")
+		return nil
+	})
+}
@@ -0,0 +1,200 @@
+package nori_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori"
+)
+
+// helloRegistry publishes hello 1.0.0 and 2.0.0, whose bin prints its version
+func helloRegistry(t *testing.T) *nori.MemoryRegistry {
+	t.Helper()
+	reg := nori.NewMemoryRegistry()
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		err := reg.AddPackage(nori.MemoryPackage{
+			Name:        "hello",
+			Description: "prints a greeting",
+			Version:     version,
+			Bins:        []string{"bin/hello"},
+			Files:       map[string]string{"bin/hello": "#!/bin/sh\necho hello " + version + "\n"},
+		})
+		if err != nil {
+			t.Fatalf("AddPackage() failed: %v", err)
+		}
+	}
+	return reg
+}
+
+func TestHermeticClient(t *testing.T) {
+	// Nothing the nori command reads from the environment may leak in
+	elsewhere := t.TempDir()
+	t.Setenv("NORI_ROOT", elsewhere)
+	t.Setenv("NORI_REGISTRY_URL", "https://registry.example.com")
+	t.Setenv("NORI_SYSTEM_CONFIG_DIR", elsewhere)
+
+	reg := helloRegistry(t)
+	root := t.TempDir()
+	client, err := nori.NewHermetic(nori.Options{Root: root, RegistryURL: reg.URL(), HTTPClient: reg.Client()})
+	if err != nil {
+		t.Fatalf("NewHermetic() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := client.Update(ctx); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	installPath, err := client.Install(ctx, "hello", "")
+	if err != nil {
+		t.Fatalf("Install() failed: %v", err)
+	}
+	if !strings.HasPrefix(installPath, root) || !strings.Contains(installPath, "2.0.0") {
+		t.Errorf("Install() = %q, want the latest version under the root", installPath)
+	}
+	if active, _ := client.Active("hello"); active != "2.0.0" {
+		t.Errorf("Active() = %q, want the first version installed", active)
+	}
+
+	bin, err := client.Which("hello", "hello")
+	if err != nil {
+		t.Fatalf("Which() failed: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if out, err := exec.Command(bin).Output(); err != nil || string(out) != "hello 2.0.0\n" {
+			t.Errorf("%s printed %q, %v, want hello 2.0.0", bin, out, err)
+		}
+	}
+
+	// Installing another version leaves the active one alone until Use
+	if _, err := client.Install(ctx, "hello", "1.0.0"); err != nil {
+		t.Fatalf("Install(1.0.0) failed: %v", err)
+	}
+	if versions, _ := client.Installed("hello"); !reflect.DeepEqual(versions, []string{"1.0.0", "2.0.0"}) {
+		t.Errorf("Installed() = %v, want both versions", versions)
+	}
+	if err := client.Use("hello", "1.0.0"); err != nil {
+		t.Fatalf("Use() failed: %v", err)
+	}
+	if bin, _ := client.Which("hello", "hello"); !strings.Contains(bin, "1.0.0") {
+		t.Errorf("Which() after Use() = %q, want 1.0.0's", bin)
+	}
+
+	// A reinstall is served from the archive cache
+	downloads := reg.Requests("/assets/hello-1.0.0.tar.gz")
+	if err := client.Uninstall("hello", "1.0.0"); err != nil {
+		t.Fatalf("Uninstall() failed: %v", err)
+	}
+	if active, _ := client.Active("hello"); active != "" {
+		t.Errorf("Active() after uninstalling it = %q, want none", active)
+	}
+	if _, err := client.Install(ctx, "hello", "1.0.0"); err != nil {
+		t.Fatalf("Install() again failed: %v", err)
+	}
+	if reg.Requests("/assets/hello-1.0.0.tar.gz") != downloads {
+		t.Error("reinstalling downloaded the archive again")
+	}
+
+	if err := client.Use("hello", "3.0.0"); err == nil {
+		t.Error("Use() accepted a version that isn't installed")
+	}
+	if _, err := client.Install(ctx, "other", ""); err == nil {
+		t.Error("Install() found a package the registry doesn't have")
+	}
+	if entries, _ := os.ReadDir(elsewhere); len(entries) != 0 {
+		t.Errorf("NORI_ROOT was written to: %v", entries)
+	}
+}
+
+func TestHermeticClientChecks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the smoke test runs a shell script")
+	}
+
+	reg := nori.NewMemoryRegistry()
+	for _, pkg := range []nori.MemoryPackage{
+		{Name: "works", Version: "1.0.0", Bins: []string{"bin/works"}, Files: map[string]string{"bin/works": "#!/bin/sh\necho ok\n"}, SmokeTest: []string{"works"}},
+		{Name: "broken", Version: "1.0.0", Bins: []string{"bin/broken"}, Files: map[string]string{"bin/broken": "#!/bin/sh\nexit 3\n"}, SmokeTest: []string{"broken", "--version"}},
+	} {
+		if err := reg.AddPackage(pkg); err != nil {
+			t.Fatalf("AddPackage() failed: %v", err)
+		}
+	}
+	root := t.TempDir()
+	client, err := nori.NewHermetic(nori.Options{Root: root, RegistryURL: reg.URL(), HTTPClient: reg.Client()})
+	if err != nil {
+		t.Fatalf("NewHermetic() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	// A version that fails its smoke test is not left installed
+	if _, err := client.Install(ctx, "works", ""); err != nil {
+		t.Errorf("Install() of a version that passes its smoke test failed: %v", err)
+	}
+	if _, err := client.Install(ctx, "broken", ""); err == nil || !strings.Contains(err.Error(), "failed its smoke test") {
+		t.Errorf("Install() of a version that fails its smoke test = %v", err)
+	}
+	if versions, _ := client.Installed("broken"); len(versions) != 0 {
+		t.Errorf("Installed() = %v after a failed smoke test, want none", versions)
+	}
+	skipping, _ := nori.NewHermetic(nori.Options{Root: root, RegistryURL: reg.URL(), HTTPClient: reg.Client(), SkipSmokeTest: true})
+	if _, err := skipping.Install(ctx, "broken", ""); err != nil {
+		t.Errorf("Install() with SkipSmokeTest failed: %v", err)
+	}
+	if err := skipping.Uninstall("broken", "1.0.0"); err != nil {
+		t.Fatalf("Uninstall() failed: %v", err)
+	}
+
+	// The root's provenance setting applies; works publishes no attestation
+	os.MkdirAll(filepath.Join(root, "config"), 0755)
+	os.WriteFile(filepath.Join(root, "config", "config.yaml"), []byte("provenance:\n  mode: enforce\n  keys: [/nonexistent/key.pem]\n"), 0644)
+	if err := client.Uninstall("works", "1.0.0"); err != nil {
+		t.Fatalf("Uninstall() failed: %v", err)
+	}
+	if _, err := client.Install(ctx, "works", ""); err == nil || !strings.Contains(err.Error(), "provenance of works@1.0.0") {
+		t.Errorf("Install() without an attestation under enforce = %v", err)
+	}
+}
+
+func TestHermeticClientOffline(t *testing.T) {
+	reg := helloRegistry(t)
+	root := t.TempDir()
+
+	// Without an HTTP client nothing is fetched
+	client, err := nori.NewHermetic(nori.Options{Root: root, RegistryURL: reg.URL()})
+	if err != nil {
+		t.Fatalf("NewHermetic() failed: %v", err)
+	}
+	if err := client.Update(context.Background()); err == nil {
+		t.Error("Update() without an HTTP client succeeded")
+	}
+
+	// The registry's client only answers for the registry
+	if _, err := reg.Client().Get("https://example.com/index.yaml"); err == nil {
+		t.Error("the registry's client reached another host")
+	}
+	if resp, err := reg.Client().Get(reg.URL() + "/packages/missing.yaml"); err != nil || resp.StatusCode != 404 {
+		t.Errorf("missing manifest = %v, %v, want 404", resp, err)
+	}
+
+	for _, opts := range []nori.Options{
+		{RegistryURL: reg.URL()},
+		{Root: root},
+		{Root: root, RegistryURL: reg.URL(), Platform: "linux"},
+	} {
+		if _, err := nori.NewHermetic(opts); err == nil {
+			t.Errorf("NewHermetic(%+v) should fail", opts)
+		}
+	}
+	if err := reg.AddPackage(nori.MemoryPackage{Name: "broken", Version: "1.0.0", Bins: []string{"bin/broken"}}); err == nil {
+		t.Error("AddPackage() accepted a bin that isn't one of the files")
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs")); !os.IsNotExist(err) {
+		t.Errorf("installs directory exists after failed operations: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package nori
+
+import (
+	"context"
+	"fmt"
+)
+
+// Artifact is a downloaded archive that is about to be extracted and installed
+type Artifact struct {
+	Package  string
+	Version  string
+	Platform string // such as linux-amd64
+
+	// URL is where the registry publishes the archive; it may have been downloaded
+	// from one of its mirrors or served from the archive cache instead. It is "" for a
+	// GitHub release asset served from the cache, which isn't looked up.
+	URL string
+
+	Type     string // tar, zip, deb, rpm, dmg or pkg
+	Checksum string // sha256:hex, which Data has been checked against
+
+	// PayloadChecksum is the digest of the archive's decompressed payload, if the
+	// manifest gives one; a recompressed archive is accepted by it instead
+	PayloadChecksum string
+
+	Data []byte
+}
+
+// Verifier checks an archive before a Client installs it, such as against a signature,
+// an in-toto attestation or SLSA provenance kept by the organization. A Client always
+// checks the manifest's checksum first, so verifiers only run on archives that match it.
+type Verifier interface {
+	Verify(ctx context.Context, artifact *Artifact) error
+}
+
+// VerifierFunc adapts a function to a Verifier
+type VerifierFunc func(ctx context.Context, artifact *Artifact) error
+
+// Verify calls f
+func (f VerifierFunc) Verify(ctx context.Context, artifact *Artifact) error {
+	return f(ctx, artifact)
+}
+
+// verify runs the client's verifiers on artifact in order, stopping at the first that
+// rejects it
+func (c *Client) verify(ctx context.Context, artifact *Artifact) error {
+	for _, v := range c.verifiers {
+		if err := v.Verify(ctx, artifact); err != nil {
+			return fmt.Errorf("verification of %s@%s failed: %w", artifact.Package, artifact.Version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package nori_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chirag-bruno/nori"
+)
+
+func TestVerifiers(t *testing.T) {
+	reg := helloRegistry(t)
+	root := t.TempDir()
+	errUnsigned := errors.New("no signature")
+
+	var seen []nori.Artifact
+	record := nori.VerifierFunc(func(ctx context.Context, a *nori.Artifact) error {
+		seen = append(seen, *a)
+		return nil
+	})
+	reject := nori.VerifierFunc(func(ctx context.Context, a *nori.Artifact) error {
+		if a.Version == "1.0.0" {
+			return errUnsigned
+		}
+		return nil
+	})
+	client, err := nori.NewHermetic(nori.Options{
+		Root:        root,
+		RegistryURL: reg.URL(),
+		HTTPClient:  reg.Client(),
+		Verifiers:   []nori.Verifier{record, reject},
+	})
+	if err != nil {
+		t.Fatalf("NewHermetic() failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.Install(ctx, "hello", "2.0.0"); err != nil {
+		t.Fatalf("Install(2.0.0) failed: %v", err)
+	}
+	if len(seen) != 1 {
+		t.Fatalf("verifiers saw %d artifacts, want 1", len(seen))
+	}
+	a := seen[0]
+	if a.Package != "hello" || a.Version != "2.0.0" || a.Type != "tar" || !strings.HasPrefix(a.Checksum, "sha256:") || len(a.Data) == 0 {
+		t.Errorf("artifact = %+v, want hello@2.0.0 with its checksum and data", a)
+	}
+	if !strings.HasSuffix(a.URL, "/assets/hello-2.0.0.tar.gz") {
+		t.Errorf("artifact URL = %q", a.URL)
+	}
+
+	// A rejected archive is not installed, and the verifier's error is kept
+	_, err = client.Install(ctx, "hello", "1.0.0")
+	if !errors.Is(err, errUnsigned) {
+		t.Fatalf("Install(1.0.0) = %v, want the verifier's error", err)
+	}
+	if versions, _ := client.Installed("hello"); len(versions) != 1 {
+		t.Errorf("Installed() = %v, want only 2.0.0", versions)
+	}
+	if _, err := os.Stat(filepath.Join(root, "installs", "hello", "1.0.0")); !os.IsNotExist(err) {
+		t.Errorf("rejected version was installed: %v", err)
+	}
+
+	// Archives from the cache are verified again
+	if err := client.Uninstall("hello", "2.0.0"); err != nil {
+		t.Fatalf("Uninstall() failed: %v", err)
+	}
+	downloads := reg.Requests("/assets/hello-2.0.0.tar.gz")
+	if _, err := client.Install(ctx, "hello", "2.0.0"); err != nil {
+		t.Fatalf("Install(2.0.0) again failed: %v", err)
+	}
+	if reg.Requests("/assets/hello-2.0.0.tar.gz") != downloads {
+		t.Error("reinstalling downloaded the archive again")
+	}
+	if len(seen) != 3 {
+		t.Errorf("verifiers saw %d artifacts, want the cached archive verified again", len(seen))
+	}
+}